@@ -352,7 +352,7 @@ func (s *testClientSuite) TestGetStore(c *C) {
 	c.Assert(stores, DeepEquals, stores)
 
 	// Mark the store as offline.
-	err = cluster.RemoveStore(store.GetId())
+	err = cluster.RemoveStore(store.GetId(), nil)
 	c.Assert(err, IsNil)
 	offlineStore := proto.Clone(store).(*metapb.Store)
 	offlineStore.State = metapb.StoreState_Offline
@@ -0,0 +1,318 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tier is the HTTP client of the PD APIs of the storage tiers, such
+// as the store types, the range labels and the tier statistics, so that the
+// tools do not depend on the layout of the API responses.
+package tier
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// StoreType is the type of a store.
+type StoreType string
+
+// The types of the stores.
+const (
+	// StoreTypePerformance is the type of stores for latency-sensitive data.
+	StoreTypePerformance StoreType = "performance"
+	// StoreTypeStorage is the type of stores with large capacity and slow
+	// disks, which are used for cold data.
+	StoreTypeStorage StoreType = "storage"
+)
+
+// StoreTypeLabelKey is the label key PD uses to specify the type of a store.
+const StoreTypeLabelKey = "store-type"
+
+// The labels of the ranges.
+const (
+	// LabelHotServing is the label of latency-sensitive ranges, whose leaders
+	// are kept on performance stores.
+	LabelHotServing = "hot-serving"
+	// LabelCold is the label of cold ranges, whose leaders are allowed to be
+	// on storage stores.
+	LabelCold = "cold"
+)
+
+const apiPrefix = "/pd/api/v1"
+
+// ErrNotFound is returned if the requested object does not exist.
+var ErrNotFound = errors.New("[pd] not found")
+
+// LabelRule binds a label to the key range [StartKey, EndKey). An empty
+// EndKey means the end of the key space.
+type LabelRule struct {
+	ID       string
+	Label    string
+	StartKey []byte
+	EndKey   []byte
+}
+
+type labelRule struct {
+	ID       string `json:"id"`
+	Label    string `json:"label"`
+	StartKey string `json:"start_key"`
+	EndKey   string `json:"end_key"`
+}
+
+func (r *labelRule) decode() (*LabelRule, error) {
+	startKey, err := hex.DecodeString(r.StartKey)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	endKey, err := hex.DecodeString(r.EndKey)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &LabelRule{ID: r.ID, Label: r.Label, StartKey: startKey, EndKey: endKey}, nil
+}
+
+// StoreStats is the statistics of the stores of a type.
+type StoreStats struct {
+	StoreType      StoreType `json:"store_type"`
+	StoreCount     int       `json:"store_count"`
+	LeaderCount    int       `json:"leader_count"`
+	RegionCount    int       `json:"region_count"`
+	RegionSize     int64     `json:"region_size"`
+	Capacity       uint64    `json:"capacity"`
+	Available      uint64    `json:"available"`
+	BytesWriteRate float64   `json:"bytes_write_rate"`
+	BytesReadRate  float64   `json:"bytes_read_rate"`
+}
+
+// RangeStats is the statistics of the regions with a range label. The regions
+// without hot-serving or cold labels are counted with an empty label.
+type RangeStats struct {
+	Label       string `json:"label"`
+	RegionCount int    `json:"region_count"`
+	RegionSize  int64  `json:"region_size"`
+	// StorageLeaderCount is the number of the regions whose leaders are on
+	// storage stores.
+	StorageLeaderCount int `json:"storage_leader_count"`
+}
+
+// MigrationStatus is the progress of moving the leaders of hot-serving ranges
+// off storage stores.
+type MigrationStatus struct {
+	// PendingLeaders is the number of the hot-serving regions whose leaders
+	// are still on storage stores.
+	PendingLeaders int `json:"pending_leaders"`
+	// MigratingLeaders is the number of the pending regions which have
+	// running operators.
+	MigratingLeaders int `json:"migrating_leaders"`
+}
+
+// Stats is the statistics of the tiers of a cluster.
+type Stats struct {
+	Stores    []*StoreStats    `json:"stores"`
+	Ranges    []*RangeStats    `json:"ranges"`
+	Migration *MigrationStatus `json:"migration"`
+}
+
+// Client is the HTTP client of the tier APIs. It is safe for concurrent use.
+type Client struct {
+	addr string
+	cli  *http.Client
+}
+
+// Option configures a Client.
+type Option func(c *Client)
+
+// WithHTTPClient sets the HTTP client used to send the requests, such as a
+// client with TLS configured.
+func WithHTTPClient(cli *http.Client) Option {
+	return func(c *Client) { c.cli = cli }
+}
+
+// NewClient creates a Client which sends the requests to the PD server at
+// addr, such as "http://127.0.0.1:2379". The requests sent to a follower are
+// redirected to the leader by PD.
+func NewClient(addr string, opts ...Option) *Client {
+	if !strings.HasPrefix(addr, "http://") && !strings.HasPrefix(addr, "https://") {
+		addr = "http://" + addr
+	}
+	c := &Client{addr: strings.TrimSuffix(addr, "/"), cli: http.DefaultClient}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+type store struct {
+	Store struct {
+		ID     uint64 `json:"id"`
+		Labels []struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		} `json:"labels"`
+	} `json:"store"`
+}
+
+func (s *store) storeType() StoreType {
+	for _, label := range s.Store.Labels {
+		if label.Key == StoreTypeLabelKey && strings.EqualFold(label.Value, string(StoreTypeStorage)) {
+			return StoreTypeStorage
+		}
+	}
+	return StoreTypePerformance
+}
+
+// GetStoreType returns the type of the store. Stores without the type label
+// are performance stores.
+func (c *Client) GetStoreType(ctx context.Context, storeID uint64) (StoreType, error) {
+	var s store
+	if err := c.request(ctx, http.MethodGet, fmt.Sprintf("/store/%d", storeID), nil, &s); err != nil {
+		return "", err
+	}
+	return s.storeType(), nil
+}
+
+// GetStoreTypes returns the types of all stores, keyed by the store IDs.
+func (c *Client) GetStoreTypes(ctx context.Context) (map[uint64]StoreType, error) {
+	var stores struct {
+		Stores []*store `json:"stores"`
+	}
+	if err := c.request(ctx, http.MethodGet, "/stores", nil, &stores); err != nil {
+		return nil, err
+	}
+	types := make(map[uint64]StoreType, len(stores.Stores))
+	for _, s := range stores.Stores {
+		types[s.Store.ID] = s.storeType()
+	}
+	return types, nil
+}
+
+// SetStoreType sets the type of the store by its label. The other labels of
+// the store are kept.
+func (c *Client) SetStoreType(ctx context.Context, storeID uint64, typ StoreType) error {
+	if typ != StoreTypePerformance && typ != StoreTypeStorage {
+		return errors.Errorf("[pd] unknown store type %q", typ)
+	}
+	labels := map[string]string{StoreTypeLabelKey: string(typ)}
+	return c.request(ctx, http.MethodPost, fmt.Sprintf("/store/%d/label", storeID), labels, nil)
+}
+
+// GetLabelRules returns all label rules.
+func (c *Client) GetLabelRules(ctx context.Context) ([]*LabelRule, error) {
+	var rules []*labelRule
+	if err := c.request(ctx, http.MethodGet, "/config/label-rules", nil, &rules); err != nil {
+		return nil, err
+	}
+	res := make([]*LabelRule, 0, len(rules))
+	for _, r := range rules {
+		rule, err := r.decode()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, rule)
+	}
+	return res, nil
+}
+
+// GetLabelRule returns the label rule. It returns ErrNotFound if the rule
+// does not exist.
+func (c *Client) GetLabelRule(ctx context.Context, id string) (*LabelRule, error) {
+	var rule labelRule
+	if err := c.request(ctx, http.MethodGet, "/config/label-rule/"+url.PathEscape(id), nil, &rule); err != nil {
+		return nil, err
+	}
+	return rule.decode()
+}
+
+// SetLabelRule creates or updates the label rule.
+func (c *Client) SetLabelRule(ctx context.Context, rule *LabelRule) error {
+	r := &labelRule{
+		ID:       rule.ID,
+		Label:    rule.Label,
+		StartKey: hex.EncodeToString(rule.StartKey),
+		EndKey:   hex.EncodeToString(rule.EndKey),
+	}
+	return c.request(ctx, http.MethodPost, "/config/label-rule", r, nil)
+}
+
+// DeleteLabelRule deletes the label rule.
+func (c *Client) DeleteLabelRule(ctx context.Context, id string) error {
+	return c.request(ctx, http.MethodDelete, "/config/label-rule/"+url.PathEscape(id), nil, nil)
+}
+
+// GetStats returns the statistics of the store types and the range labels.
+func (c *Client) GetStats(ctx context.Context) (*Stats, error) {
+	stats := &Stats{}
+	if err := c.request(ctx, http.MethodGet, "/stats/tier", nil, stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// GetMigrationStatus returns the progress of moving the leaders of
+// hot-serving ranges off storage stores.
+func (c *Client) GetMigrationStatus(ctx context.Context) (*MigrationStatus, error) {
+	stats, err := c.GetStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if stats.Migration == nil {
+		return &MigrationStatus{}, nil
+	}
+	return stats.Migration, nil
+}
+
+// request sends the request with input encoded in JSON if it is not nil, and
+// decodes the response into output if it is not nil.
+func (c *Client) request(ctx context.Context, method, path string, input, output interface{}) error {
+	var body []byte
+	if input != nil {
+		var err error
+		if body, err = json.Marshal(input); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	req, err := http.NewRequest(method, c.addr+apiPrefix+path, bytes.NewReader(body))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req = req.WithContext(ctx)
+	if input != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.cli.Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return errors.WithStack(ErrNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("[pd] %s %s failed with status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if output == nil {
+		return nil
+	}
+	return errors.WithStack(json.Unmarshal(data, output))
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/pingcap/log"
 	"github.com/pingcap/pd/pkg/logutil"
 	"github.com/pingcap/pd/pkg/metricutil"
+	"github.com/pingcap/pd/pkg/tracing"
 	"github.com/pingcap/pd/server"
 	"github.com/pingcap/pd/server/api"
 	"github.com/pingcap/pd/server/config"
@@ -88,6 +89,11 @@ func main() {
 
 	metricutil.Push(&cfg.Metric)
 
+	tracerCloser, err := tracing.InitGlobalTracer(&cfg.Trace, "pd")
+	if err != nil {
+		log.Fatal("initialize tracer error", zap.Error(err))
+	}
+
 	err = join.PrepareJoinCluster(cfg)
 	if err != nil {
 		log.Fatal("join meet error", zap.Error(err))
@@ -123,6 +129,9 @@ func main() {
 	log.Info("Got signal to exit", zap.String("signal", sig.String()))
 
 	svr.Close()
+	if err := tracerCloser.Close(); err != nil {
+		log.Error("close tracer error", zap.Error(err))
+	}
 	switch sig {
 	case syscall.SIGTERM:
 		exit(0)
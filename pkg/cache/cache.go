@@ -31,6 +31,11 @@ type Cache interface {
 	Len() int
 }
 
+// EvictCallback is called with the item evicted from the cache because the
+// cache is full. It is called with the cache locked, so it must not access
+// the cache.
+type EvictCallback func(key uint64, value interface{})
+
 // Type is cache's type such as LRUCache and etc.
 type Type int
 
@@ -113,6 +118,23 @@ func NewCache(size int, cacheType Type) Cache {
 	}
 }
 
+// NewCacheWithEvict creates Cache instance by CacheType, which calls onEvict
+// with the evicted items.
+func NewCacheWithEvict(size int, cacheType Type, onEvict EvictCallback) Cache {
+	switch cacheType {
+	case LRUCache:
+		lru := newLRU(size)
+		lru.onEvict = onEvict
+		return newThreadSafeCache(lru)
+	case TwoQueueCache:
+		twoQueue := newTwoQueue(size)
+		twoQueue.onEvict = onEvict
+		return newThreadSafeCache(twoQueue)
+	default:
+		panic("Unknown cache type")
+	}
+}
+
 // NewDefaultCache create Cache instance by default cache type
 func NewDefaultCache(size int) Cache {
 	return NewCache(size, DefaultCacheType)
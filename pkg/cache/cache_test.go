@@ -76,6 +76,12 @@ func (s *testRegionCacheSuite) TestExpireRegionCache(c *C) {
 	c.Assert(value, Equals, 3.0)
 
 	c.Assert(cache.Len(), Equals, 1)
+
+	cache.Put(4, 4)
+	c.Assert(cache.RemoveIf(func(key uint64) bool { return key > 3 }), Equals, 1)
+	_, ok = cache.Get(4)
+	c.Assert(ok, IsFalse)
+	c.Assert(cache.Len(), Equals, 1)
 }
 
 func (s *testRegionCacheSuite) TestLRUCache(c *C) {
@@ -253,3 +259,33 @@ func (s *testRegionCacheSuite) TestTwoQueueCache(c *C) {
 	c.Assert(ok, IsFalse)
 	c.Assert(val, IsNil)
 }
+
+func (s *testRegionCacheSuite) TestEvictCallback(c *C) {
+	var evicted []uint64
+	onEvict := func(key uint64, value interface{}) {
+		c.Assert(value, Equals, key*10)
+		evicted = append(evicted, key)
+	}
+
+	lru := NewCacheWithEvict(2, LRUCache, onEvict)
+	lru.Put(1, uint64(10))
+	lru.Put(2, uint64(20))
+	lru.Get(1)
+	lru.Put(3, uint64(30))
+	c.Assert(evicted, DeepEquals, []uint64{2})
+	// Removed items are not evicted.
+	lru.Remove(1)
+	c.Assert(evicted, DeepEquals, []uint64{2})
+
+	evicted = nil
+	twoQueue := NewCacheWithEvict(4, TwoQueueCache, onEvict)
+	for i := uint64(1); i <= 4; i++ {
+		twoQueue.Put(i, i*10)
+	}
+	twoQueue.Get(3)
+	twoQueue.Get(4)
+	twoQueue.Put(5, uint64(50))
+	twoQueue.Put(6, uint64(60))
+	c.Assert(evicted, DeepEquals, []uint64{1, 2})
+	c.Assert(twoQueue.Len(), Equals, 4)
+}
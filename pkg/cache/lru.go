@@ -28,6 +28,7 @@ type LRU struct {
 	// maxCount is the maximum number of items.
 	// 0 means no limit.
 	maxCount int
+	onEvict  EvictCallback
 
 	ll    *list.List
 	cache map[uint64]*list.Element
@@ -55,7 +56,9 @@ func (c *LRU) Put(key uint64, value interface{}) {
 	ele := c.ll.PushFront(kv)
 	c.cache[key] = ele
 	if c.maxCount != 0 && c.ll.Len() > c.maxCount {
-		c.removeOldest()
+		if k, v, ok := c.getAndRemoveOldest(); ok && c.onEvict != nil {
+			c.onEvict(k, v)
+		}
 	}
 }
 
@@ -97,13 +100,6 @@ func (c *LRU) remove(key uint64) bool {
 	return false
 }
 
-func (c *LRU) removeOldest() {
-	ele := c.ll.Back()
-	if ele != nil {
-		c.removeElement(ele)
-	}
-}
-
 func (c *LRU) getAndRemoveOldest() (uint64, interface{}, bool) {
 	ele := c.ll.Back()
 	if ele != nil {
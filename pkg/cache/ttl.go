@@ -88,6 +88,22 @@ func (c *TTL) Remove(key uint64) {
 	delete(c.items, key)
 }
 
+// RemoveIf eliminates the items whose keys match the function, and returns
+// the number of the eliminated items.
+func (c *TTL) RemoveIf(fn func(key uint64) bool) int {
+	c.Lock()
+	defer c.Unlock()
+
+	var count int
+	for key := range c.items {
+		if fn(key) {
+			delete(c.items, key)
+			count++
+		}
+	}
+	return count
+}
+
 // Len returns current cache size.
 func (c *TTL) Len() int {
 	c.RLock()
@@ -41,6 +41,8 @@ type TwoQueue struct {
 	recent   *LRU
 	frequent *LRU
 	ghost    *LRU
+
+	onEvict EvictCallback
 }
 
 func newTwoQueue(size int) *TwoQueue {
@@ -101,13 +103,22 @@ func (c *TwoQueue) ensureSpace(ghost bool) {
 
 	// If recent list is larger than target, evict from there
 	if recentLen > 0 && (recentLen > c.recentSize || (recentLen == c.recentSize && !ghost)) {
-		k, _, _ := c.recent.getAndRemoveOldest()
+		k, v, _ := c.recent.getAndRemoveOldest()
 		c.ghost.Put(k, nil)
+		c.evict(k, v)
 		return
 	}
 
 	// Remove from frequent list
-	c.frequent.removeOldest()
+	if k, v, ok := c.frequent.getAndRemoveOldest(); ok {
+		c.evict(k, v)
+	}
+}
+
+func (c *TwoQueue) evict(key uint64, value interface{}) {
+	if c.onEvict != nil {
+		c.onEvict(key, value)
+	}
 }
 
 // Get retrives an item from cache.
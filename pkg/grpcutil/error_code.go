@@ -0,0 +1,54 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcutil
+
+import (
+	structpb "github.com/golang/protobuf/ptypes/struct"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/status"
+)
+
+// errorCodeField is the field of the status details holding the error code.
+const errorCodeField = "code"
+
+// WithErrorCode attaches the error code to the details of the status, in the
+// same form as the "code" field of the JSON bodies of the HTTP APIs.
+func WithErrorCode(st *status.Status, code string) *status.Status {
+	detail := &structpb.Struct{Fields: map[string]*structpb.Value{
+		errorCodeField: {Kind: &structpb.Value_StringValue{StringValue: code}},
+	}}
+	withDetail, err := st.WithDetails(detail)
+	if err != nil {
+		return st
+	}
+	return withDetail
+}
+
+// ErrorCode returns the error code in the details of the gRPC status error
+// returned by PD, such as "state.not_leader", or an empty string if there is
+// none.
+func ErrorCode(err error) string {
+	st, ok := status.FromError(errors.Cause(err))
+	if !ok || st == nil {
+		return ""
+	}
+	for _, detail := range st.Details() {
+		if s, ok := detail.(*structpb.Struct); ok {
+			if v, ok := s.GetFields()[errorCodeField]; ok {
+				return v.GetStringValue()
+			}
+		}
+	}
+	return ""
+}
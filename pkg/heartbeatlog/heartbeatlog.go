@@ -0,0 +1,308 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package heartbeatlog reads and writes the binary log of the store and
+// region heartbeats captured by PD.
+//
+// The log starts with a header, and each record is encoded as a kind byte,
+// the receiving time in unix nanoseconds and the length of the request as
+// uvarints, followed by the request in protobuf.
+package heartbeatlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pkg/errors"
+)
+
+var header = []byte("PDHB\x01")
+
+// The kinds of the records.
+const (
+	kindStore  byte = 1
+	kindRegion byte = 2
+)
+
+// maxRecordSize is the max size of a request, to detect corrupted logs.
+const maxRecordSize = 64 << 20
+
+// backupTimeFormat is the suffix of the rotated log files, which sorts them
+// by the rotation time.
+const backupTimeFormat = "20060102T150405.000000000"
+
+// Rotation limits the size of the log file created by Create. Once the file
+// reaches MaxSize bytes, it is renamed with the rotation time as the suffix
+// and a new file is created, so each file is a complete log. Only the latest
+// MaxBackups renamed files are kept. Zero MaxSize never rotates the file, and
+// zero MaxBackups keeps all renamed files.
+type Rotation struct {
+	MaxSize    int64
+	MaxBackups int
+}
+
+// Record is a heartbeat in the log. Only one of Store and Region is set.
+type Record struct {
+	Time   time.Time
+	Store  *pdpb.StoreHeartbeatRequest
+	Region *pdpb.RegionHeartbeatRequest
+}
+
+// Writer appends heartbeats to a log. It is safe for concurrent use.
+type Writer struct {
+	sync.Mutex
+	w      *bufio.Writer
+	closer io.Closer
+	buf    []byte
+
+	// The log file opened by Create.
+	path     string
+	rotation Rotation
+	size     int64
+}
+
+// NewWriter creates a Writer which writes a new log to w.
+func NewWriter(w io.Writer) (*Writer, error) {
+	writer := &Writer{w: bufio.NewWriter(w)}
+	if _, err := writer.w.Write(header); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return writer, nil
+}
+
+// Create opens the log file for appending, and creates it if it does not
+// exist. The file is rotated by the rotation.
+func Create(path string, rotation Rotation) (*Writer, error) {
+	writer := &Writer{path: path, rotation: rotation}
+	if err := writer.open(); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	w.w, w.closer, w.size = bufio.NewWriter(f), f, info.Size()
+	if w.size == 0 {
+		if _, err := w.w.Write(header); err != nil {
+			f.Close()
+			return errors.WithStack(err)
+		}
+		w.size = int64(len(header))
+	}
+	return nil
+}
+
+// rotate renames the log file and creates a new one.
+func (w *Writer) rotate() error {
+	if err := w.w.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := w.closer.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.Rename(w.path, w.path+"."+time.Now().Format(backupTimeFormat)); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := w.removeBackups(); err != nil {
+		return err
+	}
+	return w.open()
+}
+
+// removeBackups removes the renamed files except the latest MaxBackups ones.
+func (w *Writer) removeBackups() error {
+	if w.rotation.MaxBackups <= 0 {
+		return nil
+	}
+	dir, prefix := filepath.Dir(w.path), filepath.Base(w.path)+"."
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var backups []string
+	for _, file := range files {
+		if name := file.Name(); strings.HasPrefix(name, prefix) {
+			if _, err := time.Parse(backupTimeFormat, strings.TrimPrefix(name, prefix)); err == nil {
+				backups = append(backups, name)
+			}
+		}
+	}
+	sort.Strings(backups)
+	for len(backups) > w.rotation.MaxBackups {
+		if err := os.Remove(filepath.Join(dir, backups[0])); err != nil {
+			return errors.WithStack(err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// WriteStoreHeartbeat appends a store heartbeat received at t.
+func (w *Writer) WriteStoreHeartbeat(t time.Time, req *pdpb.StoreHeartbeatRequest) error {
+	data, err := req.Marshal()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return w.write(kindStore, t, data)
+}
+
+// WriteRegionHeartbeat appends a region heartbeat received at t.
+func (w *Writer) WriteRegionHeartbeat(t time.Time, req *pdpb.RegionHeartbeatRequest) error {
+	data, err := req.Marshal()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return w.write(kindRegion, t, data)
+}
+
+func (w *Writer) write(kind byte, t time.Time, data []byte) error {
+	w.Lock()
+	defer w.Unlock()
+	w.buf = append(w.buf[:0], kind)
+	w.buf = appendUvarint(w.buf, uint64(t.UnixNano()))
+	w.buf = appendUvarint(w.buf, uint64(len(data)))
+	if _, err := w.w.Write(w.buf); err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := w.w.Write(data); err != nil {
+		return errors.WithStack(err)
+	}
+	w.size += int64(len(w.buf) + len(data))
+	if w.path != "" && w.rotation.MaxSize > 0 && w.size >= w.rotation.MaxSize {
+		return w.rotate()
+	}
+	return nil
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	return append(buf, b[:n]...)
+}
+
+// Flush writes the buffered records to the underlying writer.
+func (w *Writer) Flush() error {
+	w.Lock()
+	defer w.Unlock()
+	return errors.WithStack(w.w.Flush())
+}
+
+// Close flushes the buffered records and closes the file opened by Create.
+func (w *Writer) Close() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if w.closer != nil {
+		return errors.WithStack(w.closer.Close())
+	}
+	return nil
+}
+
+// Reader reads heartbeats from a log.
+type Reader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+// NewReader creates a Reader which reads the log from r.
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{r: bufio.NewReader(r)}
+	h := make([]byte, len(header))
+	if _, err := io.ReadFull(reader.r, h); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if !bytes.Equal(h, header) {
+		return nil, errors.New("not a heartbeat log")
+	}
+	return reader, nil
+}
+
+// Next reads the next heartbeat. It returns io.EOF at the end of the log, and
+// io.ErrUnexpectedEOF if the last record is incomplete.
+func (r *Reader) Next() (*Record, error) {
+	kind, err := r.r.ReadByte()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ts, err := r.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	size, err := r.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if size > maxRecordSize {
+		return nil, errors.Errorf("record size %d exceeds the limit", size)
+	}
+	if uint64(cap(r.buf)) < size {
+		r.buf = make([]byte, size)
+	}
+	data := r.buf[:size]
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	record := &Record{Time: time.Unix(0, int64(ts))}
+	switch kind {
+	case kindStore:
+		record.Store = &pdpb.StoreHeartbeatRequest{}
+		err = record.Store.Unmarshal(data)
+	case kindRegion:
+		record.Region = &pdpb.RegionHeartbeatRequest{}
+		err = record.Region.Unmarshal(data)
+	default:
+		return nil, errors.Errorf("unknown record kind %d", kind)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return record, nil
+}
+
+func (r *Reader) readUvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	return v, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return errors.WithStack(err)
+}
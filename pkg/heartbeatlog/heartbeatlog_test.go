@@ -0,0 +1,144 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package heartbeatlog
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pkg/errors"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testHeartbeatLogSuite{})
+
+type testHeartbeatLogSuite struct{}
+
+func newRegionHeartbeat(id uint64) *pdpb.RegionHeartbeatRequest {
+	leader := &metapb.Peer{Id: id + 1, StoreId: 1}
+	return &pdpb.RegionHeartbeatRequest{
+		Region: &metapb.Region{Id: id, Peers: []*metapb.Peer{leader}},
+		Leader: leader,
+	}
+}
+
+func (s *testHeartbeatLogSuite) TestReadWrite(c *C) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	c.Assert(err, IsNil)
+	now := time.Now()
+	store := &pdpb.StoreHeartbeatRequest{Stats: &pdpb.StoreStats{StoreId: 1, RegionCount: 2}}
+	c.Assert(w.WriteStoreHeartbeat(now, store), IsNil)
+	c.Assert(w.WriteRegionHeartbeat(now.Add(time.Second), newRegionHeartbeat(2)), IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	c.Assert(err, IsNil)
+	record, err := r.Next()
+	c.Assert(err, IsNil)
+	c.Assert(record.Time.Equal(now), IsTrue)
+	c.Assert(record.Store, DeepEquals, store)
+	c.Assert(record.Region, IsNil)
+	record, err = r.Next()
+	c.Assert(err, IsNil)
+	c.Assert(record.Time.Equal(now.Add(time.Second)), IsTrue)
+	c.Assert(record.Region, DeepEquals, newRegionHeartbeat(2))
+	_, err = r.Next()
+	c.Assert(err, Equals, io.EOF)
+
+	// The incomplete record is reported.
+	r, err = NewReader(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	c.Assert(err, IsNil)
+	_, err = r.Next()
+	c.Assert(err, IsNil)
+	_, err = r.Next()
+	c.Assert(errors.Cause(err), Equals, io.ErrUnexpectedEOF)
+
+	_, err = NewReader(bytes.NewReader([]byte("not a log")))
+	c.Assert(err, NotNil)
+}
+
+func (s *testHeartbeatLogSuite) TestAppend(c *C) {
+	dir, err := ioutil.TempDir("", "heartbeat_log")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "heartbeats.log")
+
+	for i := uint64(1); i <= 2; i++ {
+		w, err := Create(path, Rotation{})
+		c.Assert(err, IsNil)
+		c.Assert(w.WriteRegionHeartbeat(time.Now(), newRegionHeartbeat(i)), IsNil)
+		c.Assert(w.Close(), IsNil)
+	}
+
+	f, err := os.Open(path)
+	c.Assert(err, IsNil)
+	defer f.Close()
+	r, err := NewReader(f)
+	c.Assert(err, IsNil)
+	for i := uint64(1); i <= 2; i++ {
+		record, err := r.Next()
+		c.Assert(err, IsNil)
+		c.Assert(record.Region.GetRegion().GetId(), Equals, i)
+	}
+	_, err = r.Next()
+	c.Assert(err, Equals, io.EOF)
+}
+
+func (s *testHeartbeatLogSuite) TestRotate(c *C) {
+	dir, err := ioutil.TempDir("", "heartbeat_log")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "heartbeats.log")
+
+	// Each record is rotated into a new file, and the latest 2 of them are
+	// kept.
+	w, err := Create(path, Rotation{MaxSize: 1, MaxBackups: 2})
+	c.Assert(err, IsNil)
+	for i := uint64(1); i <= 4; i++ {
+		c.Assert(w.WriteRegionHeartbeat(time.Now(), newRegionHeartbeat(i)), IsNil)
+	}
+	c.Assert(w.Close(), IsNil)
+
+	files, err := ioutil.ReadDir(dir)
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 3)
+	// The current file has only the header.
+	c.Assert(files[0].Name(), Equals, "heartbeats.log")
+	c.Assert(files[0].Size(), Equals, int64(len(header)))
+	// The rotated files are complete logs, and sorted by the rotation time.
+	for i, file := range files[1:] {
+		f, err := os.Open(filepath.Join(dir, file.Name()))
+		c.Assert(err, IsNil)
+		r, err := NewReader(f)
+		c.Assert(err, IsNil)
+		record, err := r.Next()
+		c.Assert(err, IsNil)
+		c.Assert(record.Region.GetRegion().GetId(), Equals, uint64(i+3))
+		_, err = r.Next()
+		c.Assert(err, Equals, io.EOF)
+		f.Close()
+	}
+}
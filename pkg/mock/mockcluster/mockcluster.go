@@ -14,6 +14,7 @@
 package mockcluster
 
 import (
+	"bytes"
 	"fmt"
 	"time"
 
@@ -36,17 +37,32 @@ type Cluster struct {
 	*mockoption.ScheduleOptions
 	*statistics.HotSpotCache
 	*statistics.StoresStats
-	ID uint64
+	ID              uint64
+	AccessSketch    *statistics.AccessSketch
+	EmptyRegions    *statistics.EmptyRegionTracker
+	evacuateTargets map[uint64][]uint64
+	// evacuationDenied records the stores out of their decommission windows.
+	evacuationDenied map[uint64]struct{}
+	regionPins       map[uint64]*core.RegionPin
+	intents          map[uint64]*core.PlacementIntent
+	// mergePauses are the key ranges whose merges are paused.
+	mergePauses [][2][]byte
 }
 
 // NewCluster creates a new Cluster
 func NewCluster(opt *mockoption.ScheduleOptions) *Cluster {
 	return &Cluster{
-		BasicCluster:    core.NewBasicCluster(),
-		IDAllocator:     mockid.NewIDAllocator(),
-		ScheduleOptions: opt,
-		HotSpotCache:    statistics.NewHotSpotCache(),
-		StoresStats:     statistics.NewStoresStats(),
+		BasicCluster:     core.NewBasicCluster(),
+		IDAllocator:      mockid.NewIDAllocator(),
+		ScheduleOptions:  opt,
+		HotSpotCache:     statistics.NewHotSpotCache(),
+		StoresStats:      statistics.NewStoresStats(opt),
+		AccessSketch:     statistics.NewAccessSketch(),
+		EmptyRegions:     statistics.NewEmptyRegionTracker(),
+		evacuateTargets:  make(map[uint64][]uint64),
+		evacuationDenied: make(map[uint64]struct{}),
+		regionPins:       make(map[uint64]*core.RegionPin),
+		intents:          make(map[uint64]*core.PlacementIntent),
 	}
 }
 
@@ -55,8 +71,8 @@ func (mc *Cluster) allocID() (uint64, error) {
 }
 
 // ScanRegions scans region with start key, until number greater than limit.
-func (mc *Cluster) ScanRegions(startKey, endKey []byte, limit int) []*core.RegionInfo {
-	return mc.Regions.ScanRange(startKey, endKey, limit)
+func (mc *Cluster) ScanRegions(startKey, endKey []byte, limit int, opts ...core.RegionOption) []*core.RegionInfo {
+	return mc.Regions.ScanRange(startKey, endKey, limit, opts...)
 }
 
 // LoadRegion puts region info without leader
@@ -81,6 +97,16 @@ func (mc *Cluster) IsRegionHot(region *core.RegionInfo) bool {
 	return mc.HotSpotCache.IsRegionHot(region, mc.GetHotRegionCacheHitsThreshold())
 }
 
+// IsRegionWarm checks if the region is accessed in enough recent heartbeats.
+func (mc *Cluster) IsRegionWarm(region *core.RegionInfo) bool {
+	return mc.AccessSketch.Estimate(region.GetID()) >= mc.WarmRegionAccessThreshold
+}
+
+// IsRegionLongEmpty checks if the region has been empty for long.
+func (mc *Cluster) IsRegionLongEmpty(region *core.RegionInfo) bool {
+	return mc.EmptyRegions.IsLongEmpty(region.GetID(), mc.GetLongEmptyRegionDuration())
+}
+
 // RegionReadStats returns hot region's read stats.
 func (mc *Cluster) RegionReadStats() map[uint64][]*statistics.HotSpotPeerStat {
 	return mc.HotSpotCache.RegionStats(statistics.ReadFlow)
@@ -91,13 +117,18 @@ func (mc *Cluster) RegionWriteStats() map[uint64][]*statistics.HotSpotPeerStat {
 	return mc.HotSpotCache.RegionStats(statistics.WriteFlow)
 }
 
-// RandHotRegionFromStore random picks a hot region in specify store.
-func (mc *Cluster) RandHotRegionFromStore(store uint64, kind statistics.FlowKind) *core.RegionInfo {
-	r := mc.HotSpotCache.RandHotRegionFromStore(store, kind, mc.GetHotRegionCacheHitsThreshold())
+// RandHotRegionFromStore random picks a hot region in specify store, which is
+// selected by all the options.
+func (mc *Cluster) RandHotRegionFromStore(store uint64, kind statistics.FlowKind, opts ...core.RegionOption) *core.RegionInfo {
+	var region *core.RegionInfo
+	r := mc.HotSpotCache.RandHotRegionFromStore(store, kind, mc.GetHotRegionCacheHitsThreshold(), func(stat *statistics.HotSpotPeerStat) bool {
+		region = mc.GetRegion(stat.RegionID)
+		return region != nil && core.SelectRegion(region, opts...)
+	})
 	if r == nil {
 		return nil
 	}
-	return mc.GetRegion(r.RegionID)
+	return region
 }
 
 // AllocPeer allocs a new peer on a store.
@@ -114,6 +145,75 @@ func (mc *Cluster) AllocPeer(storeID uint64) (*metapb.Peer, error) {
 	return peer, nil
 }
 
+// SetEvacuateTargets sets the preferred targets to evacuate the store.
+func (mc *Cluster) SetEvacuateTargets(storeID uint64, targets ...uint64) {
+	mc.evacuateTargets[storeID] = targets
+}
+
+// GetEvacuateTargets returns the preferred targets to evacuate the store.
+func (mc *Cluster) GetEvacuateTargets(storeID uint64) []uint64 {
+	return mc.evacuateTargets[storeID]
+}
+
+// SetEvacuationAllowed sets whether the replicas can be moved out of the
+// store.
+func (mc *Cluster) SetEvacuationAllowed(storeID uint64, allowed bool) {
+	if allowed {
+		delete(mc.evacuationDenied, storeID)
+	} else {
+		mc.evacuationDenied[storeID] = struct{}{}
+	}
+}
+
+// IsEvacuationAllowed returns whether the replicas can be moved out of the
+// store.
+func (mc *Cluster) IsEvacuationAllowed(storeID uint64) bool {
+	_, denied := mc.evacuationDenied[storeID]
+	return !denied
+}
+
+// GetRegionPin returns the pin of the region.
+func (mc *Cluster) GetRegionPin(regionID uint64) *core.RegionPin {
+	return mc.regionPins[regionID]
+}
+
+// GetPlacementIntent returns the placement intent of the region.
+func (mc *Cluster) GetPlacementIntent(regionID uint64) *core.PlacementIntent {
+	return mc.intents[regionID]
+}
+
+// IsMergePaused returns if merging the regions in [startKey, endKey) is
+// paused.
+func (mc *Cluster) IsMergePaused(startKey, endKey []byte) bool {
+	for _, r := range mc.mergePauses {
+		if (len(endKey) == 0 || bytes.Compare(r[0], endKey) < 0) && bytes.Compare(startKey, r[1]) < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// PauseMerge pauses merging the regions in [startKey, endKey).
+func (mc *Cluster) PauseMerge(startKey, endKey []byte) {
+	mc.mergePauses = append(mc.mergePauses, [2][]byte{startKey, endKey})
+}
+
+// PinRegion pins the region, or unpins it if the pin is nil.
+func (mc *Cluster) PinRegion(regionID uint64, pin *core.RegionPin) {
+	if pin == nil {
+		delete(mc.regionPins, regionID)
+	} else {
+		mc.regionPins[regionID] = pin
+	}
+}
+
+// AddPlacementIntent declares the intent for its regions.
+func (mc *Cluster) AddPlacementIntent(intent *core.PlacementIntent) {
+	for _, regionID := range intent.RegionIDs {
+		mc.intents[regionID] = intent
+	}
+}
+
 // SetStoreUp sets store state to be up.
 func (mc *Cluster) SetStoreUp(storeID uint64) {
 	store := mc.GetStore(storeID)
@@ -397,6 +497,11 @@ func (mc *Cluster) GetOpt() namespace.ScheduleOptions {
 	return mc.ScheduleOptions
 }
 
+// GetNamespace mocks method.
+func (mc *Cluster) GetNamespace() string {
+	return ""
+}
+
 // GetLeaderScheduleLimit mocks method.
 func (mc *Cluster) GetLeaderScheduleLimit() uint64 {
 	return mc.ScheduleOptions.GetLeaderScheduleLimit(namespace.DefaultNamespace)
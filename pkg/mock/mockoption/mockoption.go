@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/server/core"
 )
 
 const (
@@ -26,6 +27,7 @@ const (
 	defaultMaxMergeRegionSize          = 0
 	defaultMaxMergeRegionKeys          = 0
 	defaultSplitMergeInterval          = 0
+	defaultLongEmptyRegionDuration     = time.Hour
 	defaultMaxStoreDownTime            = 30 * time.Minute
 	defaultLeaderScheduleLimit         = 4
 	defaultRegionScheduleLimit         = 64
@@ -36,9 +38,12 @@ const (
 	defaultTolerantSizeRatio           = 2.5
 	defaultLowSpaceRatio               = 0.8
 	defaultHighSpaceRatio              = 0.6
+	defaultWarmRegionAccessThreshold   = 3
+	defaultMemoryPressureRatio         = 0.9
 	defaultSchedulerMaxWaitingOperator = 3
 	defaultHotRegionCacheHitsThreshold = 3
 	defaultStrictlyMatchLabel          = true
+	defaultStoreStatsWindowSize        = 3
 )
 
 // ScheduleOptions is a mock of ScheduleOptions
@@ -50,28 +55,49 @@ type ScheduleOptions struct {
 	MergeScheduleLimit           uint64
 	HotRegionScheduleLimit       uint64
 	StoreBalanceRate             float64
+	ScheduleSpeedScale           float64
 	MaxSnapshotCount             uint64
 	MaxPendingPeerCount          uint64
+	SnapshotBandwidthBudget      uint64
+	MaxStoreLearnerCount         uint64
+	MaxLearnerCount              uint64
+	MaxLearnerPromoteLag         uint64
 	MaxMergeRegionSize           uint64
 	MaxMergeRegionKeys           uint64
 	SchedulerMaxWaitingOperator  uint64
 	SplitMergeInterval           time.Duration
 	EnableOneWayMerge            bool
+	EnableCrossTierMerge         bool
+	MaxMergeChainLength          uint64
+	LongEmptyRegionDuration      time.Duration
+	EnableEmptyRegionMerge       bool
 	MaxStoreDownTime             time.Duration
 	MaxReplicas                  int
 	LocationLabels               []string
 	StrictlyMatchLabel           bool
 	HotRegionCacheHitsThreshold  int
+	StoreStatsWindowSize         int
+	StoreStatsEWMADecay          float64
+	StoreStatsLongWindows        []time.Duration
 	TolerantSizeRatio            float64
 	LowSpaceRatio                float64
 	HighSpaceRatio               float64
+	MaxLeaderFractionPerStore    float64
+	MaxAdjacentLeaderCount       uint64
+	MaxStoreRegionCount          uint64
+	MaxStoreRegionCountPerGB     uint64
+	WarmRegionAccessThreshold    uint64
+	MemoryPressureRatio          float64
+	NetworkDistances             map[[2]uint64]float64
 	DisableRemoveDownReplica     bool
 	DisableReplaceOfflineReplica bool
 	DisableMakeUpReplica         bool
 	DisableRemoveExtraReplica    bool
 	DisableLocationReplacement   bool
 	DisableNamespaceRelocation   bool
+	PreemptiveOperatorKinds      []string
 	LabelProperties              map[string][]*metapb.StoreLabel
+	SchedulerDeniedStores        map[string][]uint64
 }
 
 // NewScheduleOptions creates a mock schedule option.
@@ -83,19 +109,25 @@ func NewScheduleOptions() *ScheduleOptions {
 	mso.MergeScheduleLimit = defaultMergeScheduleLimit
 	mso.HotRegionScheduleLimit = defaultHotRegionScheduleLimit
 	mso.StoreBalanceRate = defaultStoreBalanceRate
+	mso.ScheduleSpeedScale = 1
 	mso.MaxSnapshotCount = defaultMaxSnapshotCount
 	mso.MaxMergeRegionSize = defaultMaxMergeRegionSize
 	mso.MaxMergeRegionKeys = defaultMaxMergeRegionKeys
 	mso.SchedulerMaxWaitingOperator = defaultSchedulerMaxWaitingOperator
 	mso.SplitMergeInterval = defaultSplitMergeInterval
+	mso.LongEmptyRegionDuration = defaultLongEmptyRegionDuration
 	mso.MaxStoreDownTime = defaultMaxStoreDownTime
 	mso.MaxReplicas = defaultMaxReplicas
 	mso.StrictlyMatchLabel = defaultStrictlyMatchLabel
 	mso.HotRegionCacheHitsThreshold = defaultHotRegionCacheHitsThreshold
+	mso.StoreStatsWindowSize = defaultStoreStatsWindowSize
+	mso.StoreStatsLongWindows = []time.Duration{time.Hour, 24 * time.Hour}
 	mso.MaxPendingPeerCount = defaultMaxPendingPeerCount
 	mso.TolerantSizeRatio = defaultTolerantSizeRatio
 	mso.LowSpaceRatio = defaultLowSpaceRatio
 	mso.HighSpaceRatio = defaultHighSpaceRatio
+	mso.WarmRegionAccessThreshold = defaultWarmRegionAccessThreshold
+	mso.MemoryPressureRatio = defaultMemoryPressureRatio
 	return mso
 }
 
@@ -129,6 +161,11 @@ func (mso *ScheduleOptions) GetStoreBalanceRate() float64 {
 	return mso.StoreBalanceRate
 }
 
+// GetScheduleSpeedScale mocks method
+func (mso *ScheduleOptions) GetScheduleSpeedScale() float64 {
+	return mso.ScheduleSpeedScale
+}
+
 // GetMaxSnapshotCount mocks method
 func (mso *ScheduleOptions) GetMaxSnapshotCount() uint64 {
 	return mso.MaxSnapshotCount
@@ -139,6 +176,26 @@ func (mso *ScheduleOptions) GetMaxPendingPeerCount() uint64 {
 	return mso.MaxPendingPeerCount
 }
 
+// GetSnapshotBandwidthBudget mocks method
+func (mso *ScheduleOptions) GetSnapshotBandwidthBudget() uint64 {
+	return mso.SnapshotBandwidthBudget
+}
+
+// GetMaxStoreLearnerCount mocks method
+func (mso *ScheduleOptions) GetMaxStoreLearnerCount() uint64 {
+	return mso.MaxStoreLearnerCount
+}
+
+// GetMaxLearnerCount mocks method
+func (mso *ScheduleOptions) GetMaxLearnerCount() uint64 {
+	return mso.MaxLearnerCount
+}
+
+// GetMaxLearnerPromoteLag mocks method
+func (mso *ScheduleOptions) GetMaxLearnerPromoteLag() uint64 {
+	return mso.MaxLearnerPromoteLag
+}
+
 // GetMaxMergeRegionSize mocks method
 func (mso *ScheduleOptions) GetMaxMergeRegionSize() uint64 {
 	return mso.MaxMergeRegionSize
@@ -159,6 +216,26 @@ func (mso *ScheduleOptions) IsOneWayMergeEnabled() bool {
 	return mso.EnableOneWayMerge
 }
 
+// IsCrossTierMergeEnabled mocks method
+func (mso *ScheduleOptions) IsCrossTierMergeEnabled() bool {
+	return mso.EnableCrossTierMerge
+}
+
+// GetMaxMergeChainLength mocks method
+func (mso *ScheduleOptions) GetMaxMergeChainLength() uint64 {
+	return mso.MaxMergeChainLength
+}
+
+// GetLongEmptyRegionDuration mocks method
+func (mso *ScheduleOptions) GetLongEmptyRegionDuration() time.Duration {
+	return mso.LongEmptyRegionDuration
+}
+
+// IsEmptyRegionMergeEnabled mocks method
+func (mso *ScheduleOptions) IsEmptyRegionMergeEnabled() bool {
+	return mso.EnableEmptyRegionMerge
+}
+
 // GetMaxStoreDownTime mocks method
 func (mso *ScheduleOptions) GetMaxStoreDownTime() time.Duration {
 	return mso.MaxStoreDownTime
@@ -184,6 +261,21 @@ func (mso *ScheduleOptions) GetHotRegionCacheHitsThreshold() int {
 	return mso.HotRegionCacheHitsThreshold
 }
 
+// GetStoreStatsWindowSize mocks method
+func (mso *ScheduleOptions) GetStoreStatsWindowSize() int {
+	return mso.StoreStatsWindowSize
+}
+
+// GetStoreStatsEWMADecay mocks method
+func (mso *ScheduleOptions) GetStoreStatsEWMADecay() float64 {
+	return mso.StoreStatsEWMADecay
+}
+
+// GetStoreStatsLongWindows mocks method
+func (mso *ScheduleOptions) GetStoreStatsLongWindows() []time.Duration {
+	return mso.StoreStatsLongWindows
+}
+
 // GetTolerantSizeRatio mocks method
 func (mso *ScheduleOptions) GetTolerantSizeRatio() float64 {
 	return mso.TolerantSizeRatio
@@ -199,11 +291,51 @@ func (mso *ScheduleOptions) GetHighSpaceRatio() float64 {
 	return mso.HighSpaceRatio
 }
 
+// GetMaxLeaderFractionPerStore mocks method
+func (mso *ScheduleOptions) GetMaxLeaderFractionPerStore() float64 {
+	return mso.MaxLeaderFractionPerStore
+}
+
+// GetMaxAdjacentLeaderCount mocks method
+func (mso *ScheduleOptions) GetMaxAdjacentLeaderCount() uint64 {
+	return mso.MaxAdjacentLeaderCount
+}
+
+// GetNetworkDistance mocks method. The distances are keyed by the IDs of the
+// stores in ascending order.
+func (mso *ScheduleOptions) GetNetworkDistance(from, to *core.StoreInfo) float64 {
+	a, b := from.GetID(), to.GetID()
+	if a > b {
+		a, b = b, a
+	}
+	return mso.NetworkDistances[[2]uint64{a, b}]
+}
+
+// GetMaxStoreRegionCount mocks method
+func (mso *ScheduleOptions) GetMaxStoreRegionCount() uint64 {
+	return mso.MaxStoreRegionCount
+}
+
+// GetMaxStoreRegionCountPerGB mocks method
+func (mso *ScheduleOptions) GetMaxStoreRegionCountPerGB() uint64 {
+	return mso.MaxStoreRegionCountPerGB
+}
+
+// GetMemoryPressureRatio mocks method
+func (mso *ScheduleOptions) GetMemoryPressureRatio() float64 {
+	return mso.MemoryPressureRatio
+}
+
 // GetSchedulerMaxWaitingOperator mocks method.
 func (mso *ScheduleOptions) GetSchedulerMaxWaitingOperator() uint64 {
 	return mso.SchedulerMaxWaitingOperator
 }
 
+// GetSchedulerDeniedStores mocks method.
+func (mso *ScheduleOptions) GetSchedulerDeniedStores(name string) []uint64 {
+	return mso.SchedulerDeniedStores[name]
+}
+
 // SetMaxReplicas mocks method
 func (mso *ScheduleOptions) SetMaxReplicas(replicas int) {
 	mso.MaxReplicas = replicas
@@ -238,3 +370,13 @@ func (mso *ScheduleOptions) IsLocationReplacementEnabled() bool {
 func (mso *ScheduleOptions) IsNamespaceRelocationEnabled() bool {
 	return !mso.DisableNamespaceRelocation
 }
+
+// IsOperatorPreemptionEnabled mocks method.
+func (mso *ScheduleOptions) IsOperatorPreemptionEnabled(kind string) bool {
+	for _, k := range mso.PreemptiveOperatorKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
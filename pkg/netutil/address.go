@@ -0,0 +1,162 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package netutil normalizes the network addresses, so that the same address
+// written in different forms, such as IPv6 literals with and without leading
+// zeros or host names in different cases, is treated as the same.
+package netutil
+
+import (
+	"crypto/x509"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// lookupHost resolves the host names, which is replaced in tests.
+var lookupHost = net.LookupHost
+
+// SplitHostPort splits the address into the host and the port. The address
+// is either "host:port" or a URL such as "http://host:port". IPv6 literals
+// must be in brackets if there is a port, and the port is empty if the
+// address has no port.
+func SplitHostPort(addr string) (host, port string, err error) {
+	addr = strings.TrimSpace(addr)
+	if strings.Contains(addr, "://") {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return "", "", errors.WithStack(err)
+		}
+		// IPv6 literals in URLs must be in brackets.
+		if strings.Count(u.Host, ":") > 1 && !strings.HasPrefix(u.Host, "[") {
+			return "", "", errors.Errorf("IPv6 address %s should be in brackets, such as http://[::1]:2379", u.Host)
+		}
+		addr = u.Host
+	}
+	if addr == "" {
+		return "", "", errors.New("empty address")
+	}
+	if ip := net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")); ip != nil {
+		return normalizeHost(addr), "", nil
+	}
+	if !strings.Contains(addr, ":") {
+		return normalizeHost(addr), "", nil
+	}
+	host, port, err = net.SplitHostPort(addr)
+	if err != nil {
+		if strings.Count(addr, ":") > 1 && !strings.HasPrefix(addr, "[") {
+			return "", "", errors.Errorf("IPv6 address %s should be in brackets, such as [::1]:2379", addr)
+		}
+		return "", "", errors.WithStack(err)
+	}
+	if host == "" {
+		return "", "", errors.Errorf("missing host in address %s", addr)
+	}
+	return normalizeHost(host), port, nil
+}
+
+// normalizeHost converts the IP literals to the canonical form, and the host
+// names to lower case without the trailing dot.
+func normalizeHost(host string) string {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// NormalizeAddress returns the address in the canonical "host:port" form,
+// with IPv6 literals in brackets.
+func NormalizeAddress(addr string) (string, error) {
+	host, port, err := SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if port == "" {
+		if strings.Contains(host, ":") {
+			return "[" + host + "]", nil
+		}
+		return host, nil
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// ResolveAddress returns the normalized addresses of all IPs of the host of
+// the address. The address is returned as is if it is an IP literal.
+func ResolveAddress(addr string) ([]string, error) {
+	host, port, err := SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips := []string{host}
+	if net.ParseIP(host) == nil {
+		if ips, err = lookupHost(host); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		a, err := NormalizeAddress(net.JoinHostPort(ip, port))
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, a)
+	}
+	return addrs, nil
+}
+
+// SameAddress checks if the addresses are the same after normalization. If
+// resolve is true, the host names are resolved, and the addresses are the same
+// if they share an IP and the port. The addresses failed to be parsed are
+// compared as is.
+func SameAddress(a, b string, resolve bool) bool {
+	if a == b {
+		return true
+	}
+	na, errA := NormalizeAddress(a)
+	nb, errB := NormalizeAddress(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	if na == nb {
+		return true
+	}
+	if !resolve {
+		return false
+	}
+	ra, errA := ResolveAddress(na)
+	rb, errB := ResolveAddress(nb)
+	if errA != nil || errB != nil {
+		return false
+	}
+	for _, x := range ra {
+		for _, y := range rb {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// VerifyCertHost checks if the certificate is valid for the host of the
+// address, by the IP or DNS subject alternative names.
+func VerifyCertHost(cert *x509.Certificate, addr string) error {
+	host, _, err := SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	return errors.WithStack(cert.VerifyHostname(host))
+}
@@ -0,0 +1,111 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pkg/errors"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testAddressSuite{})
+
+type testAddressSuite struct{}
+
+func (s *testAddressSuite) TestNormalizeAddress(c *C) {
+	testCases := []struct {
+		addr   string
+		expect string
+	}{
+		{"127.0.0.1:2379", "127.0.0.1:2379"},
+		{" http://127.0.0.1:2379 ", "127.0.0.1:2379"},
+		{"https://PD-1.Example.com.:2379/", "pd-1.example.com:2379"},
+		{"[::1]:20160", "[::1]:20160"},
+		{"http://[0:0:0:0:0:0:0:1]:2379", "[::1]:2379"},
+		{"[2001:DB8::0001]:20160", "[2001:db8::1]:20160"},
+		{"[::ffff:127.0.0.1]:20160", "127.0.0.1:20160"},
+		{"::1", "[::1]"},
+		{"[::1]", "[::1]"},
+		{"tikv1", "tikv1"},
+	}
+	for _, t := range testCases {
+		addr, err := NormalizeAddress(t.addr)
+		c.Assert(err, IsNil, Commentf("%s", t.addr))
+		c.Assert(addr, Equals, t.expect)
+	}
+
+	for _, addr := range []string{"", "::1:20160", "http://::1:2379", ":20160", "http://%zz"} {
+		_, err := NormalizeAddress(addr)
+		c.Assert(err, NotNil, Commentf("%s", addr))
+	}
+}
+
+func (s *testAddressSuite) TestSameAddress(c *C) {
+	defer func(f func(string) ([]string, error)) { lookupHost = f }(lookupHost)
+	lookupHost = func(host string) ([]string, error) {
+		switch host {
+		case "tikv1", "tikv1-alias":
+			return []string{"10.0.0.1", "fd00::1"}, nil
+		case "tikv2":
+			return []string{"10.0.0.2"}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	c.Assert(SameAddress("[::1]:20160", "[0::1]:20160", false), IsTrue)
+	c.Assert(SameAddress("TiKV1:20160", "tikv1:20160", false), IsTrue)
+	c.Assert(SameAddress("tikv1:20160", "tikv1-alias:20160", false), IsFalse)
+	c.Assert(SameAddress("::1:20160", "[::1]:20160", false), IsFalse)
+
+	c.Assert(SameAddress("tikv1:20160", "tikv1-alias:20160", true), IsTrue)
+	c.Assert(SameAddress("tikv1:20160", "[fd00::1]:20160", true), IsTrue)
+	c.Assert(SameAddress("tikv1:20160", "tikv1-alias:20161", true), IsFalse)
+	c.Assert(SameAddress("tikv1:20160", "tikv2:20160", true), IsFalse)
+	c.Assert(SameAddress("tikv1:20160", "unknown:20160", true), IsFalse)
+}
+
+func (s *testAddressSuite) TestVerifyCertHost(c *C) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"pd1.example.com"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	c.Assert(err, IsNil)
+	cert, err := x509.ParseCertificate(der)
+	c.Assert(err, IsNil)
+
+	for _, addr := range []string{"https://127.0.0.1:2379", "https://[::1]:2379", "https://[0:0::1]:2380", "https://PD1.example.com:2379"} {
+		c.Assert(VerifyCertHost(cert, addr), IsNil, Commentf("%s", addr))
+	}
+	for _, addr := range []string{"https://127.0.0.2:2379", "https://[::2]:2379", "https://pd2.example.com:2379", "https://::1:2379"} {
+		c.Assert(VerifyCertHost(cert, addr), NotNil, Commentf("%s", addr))
+	}
+}
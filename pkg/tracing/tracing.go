@@ -0,0 +1,64 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"io"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/pingcap/log"
+	"github.com/pkg/errors"
+	"github.com/uber/jaeger-client-go"
+	jaegercfg "github.com/uber/jaeger-client-go/config"
+	"go.uber.org/zap"
+)
+
+// TraceConfig is the tracing configuration.
+type TraceConfig struct {
+	// AgentAddress is the address of the jaeger agent to report spans to.
+	// Leaves it empty to disable tracing.
+	AgentAddress string `toml:"agent-address" json:"agent-address"`
+	// SamplingRatio is the ratio of traces to be sampled. 0 means none.
+	SamplingRatio float64 `toml:"sampling-ratio" json:"sampling-ratio"`
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// InitGlobalTracer sets a jaeger tracer reporting to the agent as the global
+// tracer. The returned closer flushes the pending spans and should be called
+// before exiting.
+func InitGlobalTracer(cfg *TraceConfig, serviceName string) (io.Closer, error) {
+	if cfg.AgentAddress == "" {
+		log.Info("disable tracing")
+		return nopCloser{}, nil
+	}
+	tracer, closer, err := jaegercfg.Configuration{
+		ServiceName: serviceName,
+		Sampler: &jaegercfg.SamplerConfig{
+			Type:  jaeger.SamplerTypeProbabilistic,
+			Param: cfg.SamplingRatio,
+		},
+		Reporter: &jaegercfg.ReporterConfig{
+			LocalAgentHostPort: cfg.AgentAddress,
+		},
+	}.NewTracer()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	opentracing.SetGlobalTracer(tracer)
+	log.Info("enable tracing", zap.String("agent-address", cfg.AgentAddress), zap.Float64("sampling-ratio", cfg.SamplingRatio))
+	return closer, nil
+}
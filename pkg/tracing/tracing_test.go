@@ -0,0 +1,44 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	. "github.com/pingcap/check"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testTracingSuite{})
+
+type testTracingSuite struct{}
+
+func (s *testTracingSuite) TestInitGlobalTracer(c *C) {
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	closer, err := InitGlobalTracer(&TraceConfig{}, "pd")
+	c.Assert(err, IsNil)
+	c.Assert(opentracing.GlobalTracer(), Equals, opentracing.Tracer(opentracing.NoopTracer{}))
+	c.Assert(closer.Close(), IsNil)
+
+	closer, err = InitGlobalTracer(&TraceConfig{AgentAddress: "127.0.0.1:6831", SamplingRatio: 1}, "pd")
+	c.Assert(err, IsNil)
+	c.Assert(opentracing.GlobalTracer(), Not(Equals), opentracing.Tracer(opentracing.NoopTracer{}))
+	opentracing.StartSpan("test").Finish()
+	c.Assert(closer.Close(), IsNil)
+}
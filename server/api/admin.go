@@ -18,6 +18,9 @@ import (
 	"strconv"
 
 	"github.com/gorilla/mux"
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/pd/pkg/apiutil"
+	"github.com/pingcap/pd/pkg/typeutil"
 	"github.com/pingcap/pd/server"
 	"github.com/unrolled/render"
 )
@@ -37,7 +40,7 @@ func newAdminHandler(svr *server.Server, rd *render.Render) *adminHandler {
 func (h *adminHandler) HandleDropCacheRegion(w http.ResponseWriter, r *http.Request) {
 	cluster := h.svr.GetRaftCluster()
 	if cluster == nil {
-		h.rd.JSON(w, http.StatusInternalServerError, server.ErrNotBootstrapped.Error())
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
 		return
 	}
 
@@ -51,3 +54,114 @@ func (h *adminHandler) HandleDropCacheRegion(w http.ResponseWriter, r *http.Requ
 	cluster.DropCacheRegion(regionID)
 	h.rd.JSON(w, http.StatusOK, nil)
 }
+
+// HandleIDAllocator returns the allocation window of the ID allocator of this
+// server, which is for debugging.
+func (h *adminHandler) HandleIDAllocator(w http.ResponseWriter, r *http.Request) {
+	h.rd.JSON(w, http.StatusOK, h.svr.GetAllocator().GetStatus())
+}
+
+// HandleStorageUsage returns the usage of etcd broken down by the families of
+// the keys saved by PD.
+func (h *adminHandler) HandleStorageUsage(w http.ResponseWriter, r *http.Request) {
+	usage, err := h.svr.GetStorageUsage()
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, usage)
+}
+
+// RegionMigration is the result of migrating regions out of etcd.
+type RegionMigration struct {
+	MigratedRegions int `json:"migrated_regions"`
+}
+
+// HandleMigrateRegions moves the regions left in etcd to the region storage.
+func (h *adminHandler) HandleMigrateRegions(w http.ResponseWriter, r *http.Request) {
+	count, err := h.svr.MigrateRegionsOutOfEtcd()
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, RegionMigration{MigratedRegions: count})
+}
+
+// AcceptStaleInput is the input to accept the stale regions from a store
+// restored from an old backup.
+type AcceptStaleInput struct {
+	Reason string `json:"reason"`
+	// TTL is how long the stale regions are accepted, such as "10m". The
+	// default TTL is used if it is empty.
+	TTL typeutil.Duration `json:"ttl"`
+	// Confirm must be true, since the newer regions in PD are overwritten.
+	Confirm bool `json:"confirm"`
+}
+
+// HandleAcceptStaleFromStore accepts the stale region heartbeats led by the
+// store.
+func (h *adminHandler) HandleAcceptStaleFromStore(w http.ResponseWriter, r *http.Request) {
+	cluster := h.svr.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+
+	storeID, errParse := apiutil.ParseUint64VarsField(mux.Vars(r), "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	var input AcceptStaleInput
+	if err := readJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	a, err := cluster.AcceptStaleFromStore(storeID, input.Reason, input.TTL.Duration, input.Confirm)
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, a)
+}
+
+// HandleGetStaleAcceptance returns the stale regions accepted from the store.
+func (h *adminHandler) HandleGetStaleAcceptance(w http.ResponseWriter, r *http.Request) {
+	cluster := h.svr.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+
+	storeID, errParse := apiutil.ParseUint64VarsField(mux.Vars(r), "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	a := cluster.GetStaleAcceptance(storeID)
+	if a == nil {
+		h.rd.JSON(w, http.StatusNotFound, "the store is not accepting stale regions")
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, a)
+}
+
+// HandleStopAcceptStaleFromStore stops accepting the stale regions from the
+// store.
+func (h *adminHandler) HandleStopAcceptStaleFromStore(w http.ResponseWriter, r *http.Request) {
+	cluster := h.svr.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+
+	storeID, errParse := apiutil.ParseUint64VarsField(mux.Vars(r), "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	if err := cluster.StopAcceptStaleFromStore(storeID); err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, nil)
+}
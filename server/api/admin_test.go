@@ -14,12 +14,15 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 
 	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/pd/server"
 	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/id"
 )
 
 var _ = Suite(&testAdminSuite{})
@@ -78,3 +81,114 @@ func (s *testAdminSuite) TestDropRegion(c *C) {
 	c.Assert(region.GetRegionEpoch().ConfVer, Equals, uint64(50))
 	c.Assert(region.GetRegionEpoch().Version, Equals, uint64(50))
 }
+
+func (s *testAdminSuite) TestAcceptStaleFromStore(c *C) {
+	cluster := s.svr.GetRaftCluster()
+	region := cluster.GetRegionInfoByKey([]byte("foo"))
+	region = region.Clone(
+		core.WithLeader(region.GetPeers()[0]),
+		core.SetRegionConfVer(10),
+		core.SetRegionVersion(10),
+	)
+	c.Assert(cluster.HandleRegionHeartbeat(region), IsNil)
+	stale := region.Clone(
+		core.SetRegionConfVer(5),
+		core.SetRegionVersion(5),
+	)
+	c.Assert(cluster.HandleRegionHeartbeat(stale), NotNil)
+
+	storeID := region.GetLeader().GetStoreId()
+	url := fmt.Sprintf("%s/admin/accept-stale-from-store/%d", s.urlPrefix, storeID)
+	// It must be confirmed.
+	c.Assert(postJSON(url, []byte(`{"reason":"restored"}`)), NotNil)
+	c.Assert(postJSON(fmt.Sprintf("%s/admin/accept-stale-from-store/100", s.urlPrefix), []byte(`{"confirm":true}`)), NotNil)
+	status, _ := requestStatusBody(c, newHTTPClient(), http.MethodGet, url)
+	c.Assert(status, Equals, http.StatusNotFound)
+
+	c.Assert(postJSON(url, []byte(`{"reason":"restored","ttl":"1h","confirm":true}`)), IsNil)
+	c.Assert(cluster.HandleRegionHeartbeat(stale), IsNil)
+	region = cluster.GetRegionInfoByKey([]byte("foo"))
+	c.Assert(region.GetRegionEpoch().GetVersion(), Equals, uint64(5))
+	var acceptance server.StaleAcceptance
+	c.Assert(readJSONWithURL(url, &acceptance), IsNil)
+	c.Assert(acceptance.Reason, Equals, "restored")
+	c.Assert(acceptance.AcceptedRegions, DeepEquals, []uint64{region.GetID()})
+
+	c.Assert(doDelete(url), IsNil)
+	status, _ = requestStatusBody(c, newHTTPClient(), http.MethodDelete, url)
+	c.Assert(status, Equals, http.StatusNotFound)
+	c.Assert(cluster.HandleRegionHeartbeat(stale.Clone(core.SetRegionVersion(4))), NotNil)
+}
+
+func (s *testAdminSuite) TestIDAllocator(c *C) {
+	newID, err := s.svr.GetAllocator().Alloc()
+	c.Assert(err, IsNil)
+	status := &id.AllocatorStatus{}
+	err = readJSONWithURL(s.urlPrefix+"/admin/id-allocator", status)
+	c.Assert(err, IsNil)
+	c.Assert(status.Step, Equals, id.DefaultAllocStep)
+	c.Assert(status.Base, Equals, newID)
+	c.Assert(status.Remaining, Equals, status.End-status.Base)
+}
+
+func (s *testAdminSuite) TestStorageUsage(c *C) {
+	// Leave a region in etcd, as if it is saved before the region storage is
+	// enabled.
+	region := &metapb.Region{Id: 1000, Peers: []*metapb.Peer{{Id: 1001, StoreId: 1}}}
+	value, err := region.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(s.svr.GetStorage().Base.Save("raft/r/00000000000000001000", string(value)), IsNil)
+
+	usage := &server.StorageUsage{}
+	err = readJSONWithURL(s.urlPrefix+"/admin/storage/usage", usage)
+	c.Assert(err, IsNil)
+	c.Assert(usage.DBSize > 0, IsTrue)
+	c.Assert(usage.QuotaBackendBytes > 0, IsTrue)
+	families := make(map[string]*core.KeyFamilyUsage)
+	for _, family := range usage.Families {
+		families[family.Family] = family
+	}
+	c.Assert(families[core.RegionKeyFamily].Keys > 0, IsTrue)
+	c.Assert(families[core.StoreKeyFamily].Keys > 0, IsTrue)
+
+	res, err := http.Post(s.urlPrefix+"/admin/storage/migrate-regions", "application/json", nil)
+	c.Assert(err, IsNil)
+	c.Assert(res.StatusCode, Equals, http.StatusOK)
+	migration := &RegionMigration{}
+	c.Assert(json.NewDecoder(res.Body).Decode(migration), IsNil)
+	res.Body.Close()
+	c.Assert(migration.MigratedRegions, Equals, families[core.RegionKeyFamily].Keys)
+
+	err = readJSONWithURL(s.urlPrefix+"/admin/storage/usage", usage)
+	c.Assert(err, IsNil)
+	c.Assert(usage.Families[0].Family, Equals, core.RegionKeyFamily)
+	c.Assert(usage.Families[0].Keys, Equals, 0)
+	loaded := &metapb.Region{}
+	ok, err := s.svr.GetStorage().LoadRegion(region.GetId(), loaded)
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsTrue)
+	c.Assert(loaded, DeepEquals, region)
+}
+
+func (s *testAdminSuite) TestEtcdStatus(c *C) {
+	status := &server.EtcdStatus{}
+	err := readJSONWithURL(s.urlPrefix+"/etcd/status", status)
+	c.Assert(err, IsNil)
+	c.Assert(status.DBSize > 0, IsTrue)
+	c.Assert(status.QuotaBackendBytes > 0, IsTrue)
+	c.Assert(status.Alarms, HasLen, 0)
+	c.Assert(status.Members, HasLen, 1)
+	c.Assert(status.Members[0].IsLeader, IsTrue)
+	c.Assert(status.Members[0].Error, Equals, "")
+	c.Assert(status.MaxRaftIndexGap, Equals, uint64(0))
+	c.Assert(status.Revision > status.CompactRevision, IsTrue)
+
+	res, err := http.Post(s.urlPrefix+"/admin/etcd/compact", "application/json", nil)
+	c.Assert(err, IsNil)
+	c.Assert(res.StatusCode, Equals, http.StatusOK)
+	compacted := &server.EtcdStatus{}
+	c.Assert(json.NewDecoder(res.Body).Decode(compacted), IsNil)
+	res.Body.Close()
+	c.Assert(compacted.CompactRevision >= status.Revision, IsTrue)
+	c.Assert(compacted.Members[0].DBSizeInUse <= compacted.Members[0].DBSize, IsTrue)
+}
@@ -0,0 +1,93 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pingcap/pd/server"
+	"github.com/unrolled/render"
+)
+
+type checkerHandler struct {
+	*server.Handler
+	rd *render.Render
+}
+
+func newCheckerHandler(handler *server.Handler, rd *render.Render) *checkerHandler {
+	return &checkerHandler{
+		Handler: handler,
+		rd:      rd,
+	}
+}
+
+func (h *checkerHandler) List(w http.ResponseWriter, r *http.Request) {
+	status, err := h.GetCheckersStatus()
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, status)
+}
+
+func (h *checkerHandler) Get(w http.ResponseWriter, r *http.Request) {
+	status, err := h.GetCheckerStatus(mux.Vars(r)["name"])
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	if status == nil {
+		h.rd.JSON(w, http.StatusNotFound, "checker not found")
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, status)
+}
+
+func (h *checkerHandler) Post(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	status, err := h.GetCheckerStatus(name)
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	if status == nil {
+		h.rd.JSON(w, http.StatusNotFound, "checker not found")
+		return
+	}
+	var input struct {
+		Paused *bool `json:"paused"`
+	}
+	if err := readJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	if input.Paused == nil {
+		h.rd.JSON(w, http.StatusBadRequest, "missing paused")
+		return
+	}
+	if err := h.PauseChecker(name, *input.Paused); err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, nil)
+}
+
+func (h *checkerHandler) GetLocationViolations(w http.ResponseWriter, r *http.Request) {
+	violations, err := h.Handler.GetLocationViolations()
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, violations)
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/server"
+	"github.com/pingcap/pd/server/checker"
+	"github.com/pingcap/pd/server/config"
+	"github.com/pingcap/pd/server/core"
+)
+
+var _ = Suite(&testCheckerSuite{})
+
+type testCheckerSuite struct {
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testCheckerSuite) SetUpSuite(c *C) {
+	s.svr, s.cleanup = mustNewServer(c)
+	mustWaitLeader(c, []*server.Server{s.svr})
+
+	addr := s.svr.GetAddr()
+	s.urlPrefix = fmt.Sprintf("%s%s/api/v1/checkers", addr, apiPrefix)
+
+	mustBootstrapCluster(c, s.svr)
+}
+
+func (s *testCheckerSuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func (s *testCheckerSuite) TestPause(c *C) {
+	var list []*server.CheckerStatus
+	c.Assert(readJSONWithURL(s.urlPrefix, &list), IsNil)
+	c.Assert(list, HasLen, 7)
+	for _, status := range list {
+		c.Assert(status.Paused, IsFalse)
+	}
+
+	status := &server.CheckerStatus{}
+	c.Assert(readJSONWithURL(s.urlPrefix+"/unknown", status), NotNil)
+	c.Assert(postJSON(s.urlPrefix+"/unknown", []byte(`{"paused":true}`)), NotNil)
+	c.Assert(postJSON(s.urlPrefix+"/merge", []byte(`{}`)), NotNil)
+
+	c.Assert(postJSON(s.urlPrefix+"/merge", []byte(`{"paused":true}`)), IsNil)
+	c.Assert(readJSONWithURL(s.urlPrefix+"/merge", status), IsNil)
+	c.Assert(status.Paused, IsTrue)
+
+	// The state is persisted.
+	paused := make(map[string]bool)
+	err := s.svr.GetStorage().LoadCheckerPaused(func(name string, p bool) { paused[name] = p })
+	c.Assert(err, IsNil)
+	c.Assert(paused["merge"], IsTrue)
+
+	c.Assert(postJSON(s.urlPrefix+"/merge", []byte(`{"paused":false}`)), IsNil)
+	c.Assert(readJSONWithURL(s.urlPrefix+"/merge", status), IsNil)
+	c.Assert(status.Paused, IsFalse)
+}
+
+func (s *testCheckerSuite) TestLocationViolations(c *C) {
+	c.Assert(s.svr.SetReplicationConfig(config.ReplicationConfig{MaxReplicas: 3, LocationLabels: []string{"host"}}), IsNil)
+	for id, host := range map[uint64]string{2: "h1", 3: "h1", 4: "h2", 5: "h3"} {
+		mustPutStore(c, s.svr, id, metapb.StoreState_Up, []*metapb.StoreLabel{{Key: "host", Value: host}})
+	}
+	region := newTestRegionInfo(2, 2, []byte("a"), []byte("b"),
+		core.WithAddPeer(&metapb.Peer{Id: 3, StoreId: 3}),
+		core.WithAddPeer(&metapb.Peer{Id: 4, StoreId: 4}))
+	mustRegionHeartbeat(c, s.svr, region)
+
+	var violations []*checker.LocationViolation
+	c.Assert(readJSONWithURL(s.urlPrefix+"/replica/location-violations", &violations), IsNil)
+	c.Assert(violations, HasLen, 1)
+	c.Assert(violations[0], DeepEquals, &checker.LocationViolation{RegionID: 2, Label: "host", Level: 1, StoreIDs: []uint64{2, 3}})
+}
@@ -44,7 +44,7 @@ func newClassifierHandler(svr *server.Server, rd *render.Render, prefix string)
 func (h *classifierHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	cluster := h.svr.GetRaftCluster()
 	if cluster == nil {
-		h.rd.JSON(w, http.StatusInternalServerError, server.ErrNotBootstrapped.Error())
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
 		return
 	}
 	classifier := cluster.GetNamespaceClassifier()
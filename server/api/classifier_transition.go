@@ -0,0 +1,90 @@
+// Copyright 2017 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/pd/server"
+	"github.com/pkg/errors"
+	"github.com/unrolled/render"
+)
+
+const defaultDivergentRegionLimit = 100
+
+type classifierTransitionHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+func newClassifierTransitionHandler(svr *server.Server, rd *render.Render) *classifierTransitionHandler {
+	return &classifierTransitionHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+func (h *classifierTransitionHandler) Get(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimit(r, defaultDivergentRegionLimit)
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	status := h.svr.GetClassifierTransition(limit)
+	if status == nil {
+		h.rd.JSON(w, http.StatusNotFound, "no classifier transition in progress")
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, status)
+}
+
+func (h *classifierTransitionHandler) Post(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Classifier  string            `json:"classifier"`
+		Args        map[string]string `json:"args"`
+		GracePeriod string            `json:"grace_period"`
+	}
+	if err := readJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	gracePeriod, err := time.ParseDuration(input.GracePeriod)
+	if err != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errors.Wrap(err, "invalid grace period")))
+		return
+	}
+	transition, err := h.svr.StartClassifierTransition(input.Classifier, input.Args, gracePeriod)
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, transition)
+}
+
+func (h *classifierTransitionHandler) CutOver(w http.ResponseWriter, r *http.Request) {
+	if err := h.svr.CutOverClassifier(); err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, nil)
+}
+
+func (h *classifierTransitionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.svr.AbortClassifierTransition(); err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, nil)
+}
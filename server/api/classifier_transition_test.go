@@ -0,0 +1,96 @@
+// Copyright 2017 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/pkg/testutil"
+	"github.com/pingcap/pd/server"
+	"github.com/pingcap/pd/server/config"
+	"github.com/pingcap/pd/server/namespace"
+)
+
+var _ = Suite(&testClassifierTransitionSuite{})
+
+type testClassifierTransitionSuite struct {
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testClassifierTransitionSuite) SetUpSuite(c *C) {
+	s.svr, s.cleanup = mustNewServer(c, func(cfg *config.Config) { cfg.NamespaceClassifier = "table" })
+	mustWaitLeader(c, []*server.Server{s.svr})
+
+	addr := s.svr.GetAddr()
+	s.urlPrefix = fmt.Sprintf("%s%s/api/v1", addr, apiPrefix)
+
+	mustBootstrapCluster(c, s.svr)
+	mustPutStore(c, s.svr, 1, metapb.StoreState_Up, nil)
+	mustPutStore(c, s.svr, 2, metapb.StoreState_Up, nil)
+}
+
+func (s *testClassifierTransitionSuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func (s *testClassifierTransitionSuite) TestTransition(c *C) {
+	c.Assert(postJSON(s.urlPrefix+"/classifier/table/namespaces", []byte(`{"namespace":"ns1"}`)), IsNil)
+	c.Assert(postJSON(s.urlPrefix+"/classifier/table/store_ns/2", []byte(`{"namespace":"ns1","action":"add"}`)), IsNil)
+	c.Assert(postJSON(s.urlPrefix+"/classifier/table/namespaces/table", []byte(`{"namespace":"ns1","table_id":"1","action":"add"}`)), IsNil)
+	mustRegionHeartbeat(c, s.svr, newTestRegionInfo(100, 2, tableStartKey(1), tableStartKey(2)))
+	mustRegionHeartbeat(c, s.svr, newTestRegionInfo(101, 1, tableStartKey(2), tableStartKey(3)))
+	store := s.svr.GetRaftCluster().GetStore(2)
+
+	url := s.urlPrefix + "/admin/classifier-transition"
+	status := &server.ClassifierTransitionStatus{}
+	c.Assert(readJSONWithURL(url, status), NotNil)
+	c.Assert(postJSON(url, []byte(`{"classifier":"unknown","grace_period":"1h"}`)), NotNil)
+	c.Assert(postJSON(url, []byte(`{"classifier":"default","grace_period":"-1h"}`)), NotNil)
+	c.Assert(postJSON(url+"/cut-over", nil), NotNil)
+
+	// Both classifiers are evaluated, while the current one is in effect.
+	c.Assert(postJSON(url, []byte(`{"classifier":"default","grace_period":"1h"}`)), IsNil)
+	c.Assert(postJSON(url, []byte(`{"classifier":"default","grace_period":"1h"}`)), NotNil)
+	c.Assert(readJSONWithURL(url, status), IsNil)
+	c.Assert(status.From, Equals, "table")
+	c.Assert(status.To, Equals, "default")
+	c.Assert(status.DivergentStores, DeepEquals, []*namespace.Divergence{{StoreID: 2, Current: "ns1", Next: "global"}})
+	c.Assert(status.DivergentRegionCount, Equals, 1)
+	c.Assert(status.DivergentRegions, DeepEquals, []*namespace.Divergence{{RegionID: 100, Current: "ns1", Next: "global"}})
+	c.Assert(s.svr.GetClassifier().GetStoreNamespace(store), Equals, "ns1")
+
+	// Abort the transition.
+	c.Assert(doDelete(url), IsNil)
+	c.Assert(readJSONWithURL(url, status), NotNil)
+	c.Assert(s.svr.AbortClassifierTransition(), NotNil)
+	c.Assert(s.svr.GetClassifierName(), Equals, "table")
+
+	// Cut over at once.
+	c.Assert(postJSON(url, []byte(`{"classifier":"default","grace_period":"1h"}`)), IsNil)
+	c.Assert(postJSON(url+"/cut-over", nil), IsNil)
+	c.Assert(readJSONWithURL(url, status), NotNil)
+	c.Assert(s.svr.GetClassifierName(), Equals, "default")
+	c.Assert(s.svr.GetClassifier().GetStoreNamespace(store), Equals, "global")
+
+	// Cut over after the grace period.
+	c.Assert(postJSON(url, []byte(`{"classifier":"table","grace_period":"0s"}`)), IsNil)
+	testutil.WaitUntil(c, func(c *C) bool {
+		return s.svr.GetClassifierName() == "table"
+	})
+	c.Assert(s.svr.GetClassifier().GetStoreNamespace(store), Equals, "ns1")
+}
@@ -39,8 +39,22 @@ func (h *clusterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func (h *clusterHandler) GetClusterStatus(w http.ResponseWriter, r *http.Request) {
 	status, err := h.svr.GetClusterStatus()
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 	h.rd.JSON(w, http.StatusOK, status)
 }
+
+// MinResolvedTS is the cluster-wide minimal resolved timestamp.
+type MinResolvedTS struct {
+	MinResolvedTS uint64 `json:"min_resolved_ts"`
+}
+
+func (h *clusterHandler) GetMinResolvedTS(w http.ResponseWriter, r *http.Request) {
+	cluster := h.svr.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, &MinResolvedTS{MinResolvedTS: cluster.GetMinResolvedTS()})
+}
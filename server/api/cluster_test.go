@@ -78,3 +78,20 @@ func (s *testCluster) TestGetClusterStatus(c *C) {
 	c.Assert(status.RaftBootstrapTime.After(now), IsTrue)
 	c.Assert(status.IsInitialized, IsTrue)
 }
+
+func (s *testCluster) TestMinResolvedTS(c *C) {
+	if s.svr.GetRaftCluster() == nil {
+		mustBootstrapCluster(c, s.svr)
+	}
+	cluster := s.svr.GetRaftCluster()
+	url := fmt.Sprintf("%s/cluster/min-resolved-ts", s.urlPrefix)
+	ts := &MinResolvedTS{}
+	c.Assert(readJSONWithURL(url, ts), IsNil)
+	c.Assert(ts.MinResolvedTS, Equals, uint64(0))
+
+	for _, store := range cluster.GetStores() {
+		c.Assert(cluster.SetStoreMinResolvedTS(store.GetID(), 100), IsNil)
+	}
+	c.Assert(readJSONWithURL(url, ts), IsNil)
+	c.Assert(ts.MinResolvedTS, Equals, uint64(100))
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/pingcap/errcode"
 	"github.com/pingcap/pd/server"
+	"github.com/pingcap/pd/server/config"
 	"github.com/pkg/errors"
 	"github.com/unrolled/render"
 )
@@ -42,36 +43,50 @@ func (h *confHandler) Get(w http.ResponseWriter, r *http.Request) {
 	h.rd.JSON(w, http.StatusOK, h.svr.GetConfig())
 }
 
+func (h *confHandler) GetEffective(w http.ResponseWriter, r *http.Request) {
+	items := h.svr.GetEffectiveConfig()
+	if r.URL.Query().Get("diff") == "true" {
+		changed := items[:0]
+		for _, item := range items {
+			if item.Source != config.SourceDefault {
+				changed = append(changed, item)
+			}
+		}
+		items = changed
+	}
+	h.rd.JSON(w, http.StatusOK, items)
+}
+
 func (h *confHandler) Post(w http.ResponseWriter, r *http.Request) {
 	config := h.svr.GetConfig()
 	data, err := ioutil.ReadAll(r.Body)
 	r.Body.Close()
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 	if err := json.Unmarshal(data, &config.Schedule); err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 	if err := json.Unmarshal(data, &config.Replication); err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 	if err := json.Unmarshal(data, &config.PDServerCfg); err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 	if err := h.svr.SetScheduleConfig(config.Schedule); err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 	if err := h.svr.SetReplicationConfig(config.Replication); err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 	if err := h.svr.SetPDServerConfig(config.PDServerCfg); err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 	h.rd.JSON(w, http.StatusOK, nil)
@@ -88,7 +103,7 @@ func (h *confHandler) SetSchedule(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.svr.SetScheduleConfig(*config); err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 	h.rd.JSON(w, http.StatusOK, nil)
@@ -105,7 +120,7 @@ func (h *confHandler) SetReplication(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.svr.SetReplicationConfig(*config); err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 	h.rd.JSON(w, http.StatusOK, nil)
@@ -140,7 +155,7 @@ func (h *confHandler) SetNamespace(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.svr.SetNamespaceConfig(name, *config); err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 	h.rd.JSON(w, http.StatusOK, nil)
@@ -156,7 +171,7 @@ func (h *confHandler) DeleteNamespace(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.svr.DeleteNamespaceConfig(name); err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 	h.rd.JSON(w, http.StatusOK, nil)
@@ -181,7 +196,7 @@ func (h *confHandler) SetLabelProperty(w http.ResponseWriter, r *http.Request) {
 		err = errors.Errorf("unknown action %v", input["action"])
 	}
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 	h.rd.JSON(w, http.StatusOK, nil)
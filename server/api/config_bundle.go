@@ -0,0 +1,77 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/pd/server"
+	"github.com/pkg/errors"
+	"github.com/unrolled/render"
+)
+
+type configBundleHandler struct {
+	*server.Handler
+	rd *render.Render
+}
+
+func newConfigBundleHandler(handler *server.Handler, rd *render.Render) *configBundleHandler {
+	return &configBundleHandler{
+		Handler: handler,
+		rd:      rd,
+	}
+}
+
+// ConfigBundleExportInput is the key to sign the exported bundle.
+type ConfigBundleExportInput struct {
+	Key string `json:"key"`
+}
+
+// ConfigBundleImportInput is the bundle to import and the key it is signed
+// with.
+type ConfigBundleImportInput struct {
+	Key    string               `json:"key"`
+	Bundle *server.ConfigBundle `json:"bundle"`
+}
+
+func (h *configBundleHandler) Export(w http.ResponseWriter, r *http.Request) {
+	var input ConfigBundleExportInput
+	if err := readJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	bundle, err := h.ExportConfigBundle(input.Key)
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, bundle)
+}
+
+func (h *configBundleHandler) Import(w http.ResponseWriter, r *http.Request) {
+	var input ConfigBundleImportInput
+	if err := readJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	if input.Bundle == nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errors.New("the config bundle is required")))
+		return
+	}
+	result, err := h.ImportConfigBundle(input.Bundle, input.Key)
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, result)
+}
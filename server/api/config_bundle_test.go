@@ -0,0 +1,149 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/server"
+	"github.com/pingcap/pd/server/config"
+)
+
+var _ = Suite(&testConfigBundleSuite{})
+
+type testConfigBundleSuite struct {
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testConfigBundleSuite) SetUpSuite(c *C) {
+	s.svr, s.cleanup = mustNewServer(c)
+	mustWaitLeader(c, []*server.Server{s.svr})
+
+	addr := s.svr.GetAddr()
+	s.urlPrefix = fmt.Sprintf("%s%s/api/v1/config/bundle", addr, apiPrefix)
+
+	mustBootstrapCluster(c, s.svr)
+	mustPutStore(c, s.svr, 1, metapb.StoreState_Up, nil)
+}
+
+func (s *testConfigBundleSuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func (s *testConfigBundleSuite) importBundle(key string, bundle *server.ConfigBundle) (*server.ConfigBundleImport, error) {
+	data, err := json.Marshal(&ConfigBundleImportInput{Key: key, Bundle: bundle})
+	if err != nil {
+		return nil, err
+	}
+	result := &server.ConfigBundleImport{}
+	err = postJSON(s.urlPrefix+"/import", data, func(res []byte) bool {
+		return json.Unmarshal(res, result) == nil
+	})
+	return result, err
+}
+
+func (s *testConfigBundleSuite) TestExportImport(c *C) {
+	// The schedulers scoped to stores are neither exported nor removed.
+	c.Assert(s.svr.GetHandler().AddScheduler("evict-leader", "1"), IsNil)
+	defer s.svr.GetHandler().RemoveScheduler("evict-leader-scheduler-1")
+
+	// The key is required.
+	err := postJSON(s.urlPrefix+"/export", []byte(`{"key":""}`))
+	c.Assert(err, NotNil)
+	bundle := &server.ConfigBundle{}
+	err = postJSON(s.urlPrefix+"/export", []byte(`{"key":"secret"}`), func(res []byte) bool {
+		return json.Unmarshal(res, bundle) == nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(bundle.ClusterID, Equals, s.svr.ClusterID())
+	c.Assert(bundle.Signature, Not(Equals), "")
+	for _, cfg := range bundle.Schedule.Schedulers {
+		c.Assert(cfg.Type, Not(Equals), "evict-leader")
+	}
+	limit := bundle.Schedule.RegionScheduleLimit
+	_, err = s.importBundle("", bundle)
+	c.Assert(err, NotNil)
+
+	// Change the cluster after exporting.
+	cfg := s.svr.GetScheduleConfig()
+	cfg.RegionScheduleLimit = limit + 10
+	c.Assert(s.svr.SetScheduleConfig(*cfg), IsNil)
+	c.Assert(s.svr.SetLabelProperty("reject-leader", "zone", "cn"), IsNil)
+	c.Assert(s.svr.GetHandler().AddScheduler("shuffle-leader"), IsNil)
+
+	// The bundle signed with another key or changed is rejected.
+	_, err = s.importBundle("another", bundle)
+	c.Assert(err, NotNil)
+	tampered := *bundle
+	tampered.Schedule.RegionScheduleLimit = 100
+	_, err = s.importBundle("secret", &tampered)
+	c.Assert(err, NotNil)
+	c.Assert(s.svr.GetScheduleConfig().RegionScheduleLimit, Equals, limit+10)
+
+	result, err := s.importBundle("secret", bundle)
+	c.Assert(err, IsNil)
+	c.Assert(result.AddedSchedulers, HasLen, 0)
+	c.Assert(result.RemovedSchedulers, DeepEquals, []string{"shuffle-leader-scheduler"})
+	c.Assert(s.svr.GetScheduleConfig().RegionScheduleLimit, Equals, limit)
+	c.Assert(s.svr.GetLabelProperty()["reject-leader"], HasLen, 0)
+	schedulers, err := s.svr.GetHandler().GetSchedulers()
+	c.Assert(err, IsNil)
+	c.Assert(schedulers, DeepEquals, []string{"evict-leader-scheduler-1"})
+	for _, cfg := range s.svr.GetScheduleConfig().Schedulers {
+		c.Assert(cfg.Type, Not(Equals), "shuffle-leader")
+	}
+
+	// The schedulers scoped to stores in the bundle are skipped.
+	withStores := *bundle
+	withStores.Schedule.Schedulers = append(withStores.Schedule.Schedulers[:len(withStores.Schedule.Schedulers):len(withStores.Schedule.Schedulers)],
+		config.SchedulerConfig{Type: "grant-leader", Args: []string{"1"}})
+	withStores.Signature = signBundle(c, "secret", &withStores)
+	result, err = s.importBundle("secret", &withStores)
+	c.Assert(err, IsNil)
+	c.Assert(result.SkippedSchedulers, DeepEquals, []string{"grant-leader 1"})
+	schedulers, err = s.svr.GetHandler().GetSchedulers()
+	c.Assert(err, IsNil)
+	c.Assert(schedulers, DeepEquals, []string{"evict-leader-scheduler-1"})
+
+	// The disabled scheduler is enabled again.
+	cfg = s.svr.GetScheduleConfig()
+	cfg.Schedulers[0].Disable = true
+	c.Assert(s.svr.SetScheduleConfig(*cfg), IsNil)
+	result, err = s.importBundle("secret", bundle)
+	c.Assert(err, IsNil)
+	c.Assert(result.AddedSchedulers, HasLen, 1)
+	c.Assert(result.RemovedSchedulers, HasLen, 0)
+	schedulerCfgs := s.svr.GetScheduleConfig().Schedulers
+	c.Assert(schedulerCfgs[:len(schedulerCfgs)-1], DeepEquals, bundle.Schedule.Schedulers)
+	c.Assert(schedulerCfgs[len(schedulerCfgs)-1].Type, Equals, "evict-leader")
+}
+
+// signBundle signs the bundle as the server does.
+func signBundle(c *C, key string, bundle *server.ConfigBundle) string {
+	unsigned := *bundle
+	unsigned.Signature = ""
+	data, err := json.Marshal(&unsigned)
+	c.Assert(err, IsNil)
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -94,6 +94,37 @@ func (s *testConfigSuite) TestConfigSchedule(c *C) {
 	c.Assert(*sc, DeepEquals, *sc1)
 }
 
+func (s *testConfigSuite) TestConfigEffective(c *C) {
+	addr := s.cfgs[rand.Intn(len(s.cfgs))].ClientUrls + apiPrefix + "/api/v1/config"
+	postData, err := json.Marshal(map[string]interface{}{"merge-schedule-limit": 13})
+	c.Assert(err, IsNil)
+	c.Assert(postJSON(addr, postData), IsNil)
+
+	var items []*config.EffectiveConfigItem
+	resp, err := doGet(addr + "/effective")
+	c.Assert(err, IsNil)
+	c.Assert(readJSON(resp.Body, &items), IsNil)
+	var found bool
+	for _, item := range items {
+		if item.Name == "schedule.merge-schedule-limit" {
+			found = true
+			c.Assert(item.Value, Equals, float64(13))
+			c.Assert(item.Source, Equals, config.SourceRuntime)
+			c.Assert(item.UpdateTime.IsZero(), IsFalse)
+		}
+	}
+	c.Assert(found, IsTrue)
+
+	var changed []*config.EffectiveConfigItem
+	resp, err = doGet(addr + "/effective?diff=true")
+	c.Assert(err, IsNil)
+	c.Assert(readJSON(resp.Body, &changed), IsNil)
+	c.Assert(len(changed), Less, len(items))
+	for _, item := range changed {
+		c.Assert(item.Source, Not(Equals), config.SourceDefault)
+	}
+}
+
 func (s *testConfigSuite) TestConfigReplication(c *C) {
 	addr := s.cfgs[rand.Intn(len(s.cfgs))].ClientUrls + apiPrefix + "/api/v1/config/replicate"
 	resp, err := doGet(addr)
@@ -0,0 +1,160 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/pkg/apiutil"
+	"github.com/pingcap/pd/server"
+	"github.com/pkg/errors"
+	"github.com/unrolled/render"
+)
+
+type deleteRangeHandler struct {
+	*server.Handler
+	rd *render.Render
+}
+
+func newDeleteRangeHandler(handler *server.Handler, rd *render.Render) *deleteRangeHandler {
+	return &deleteRangeHandler{
+		Handler: handler,
+		rd:      rd,
+	}
+}
+
+func (h *deleteRangeHandler) List(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetDeleteRangeTasks())
+}
+
+func (h *deleteRangeHandler) Get(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	id, errParse := apiutil.ParseUint64VarsField(mux.Vars(r), "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	task := cluster.GetDeleteRangeTask(id)
+	if task == nil {
+		h.rd.JSON(w, http.StatusNotFound, "delete range task not found")
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, task)
+}
+
+func (h *deleteRangeHandler) Post(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	var input struct {
+		StartKey string `json:"start_key"`
+		EndKey   string `json:"end_key"`
+	}
+	if err := readJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	startKey, err := hex.DecodeString(input.StartKey)
+	if err != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errors.Wrap(err, "start key should be in hex format")))
+		return
+	}
+	endKey, err := hex.DecodeString(input.EndKey)
+	if err != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errors.Wrap(err, "end key should be in hex format")))
+		return
+	}
+	task, err := cluster.CreateDeleteRangeTask(startKey, endKey)
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, task)
+}
+
+func (h *deleteRangeHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	id, errParse := apiutil.ParseUint64VarsField(mux.Vars(r), "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	if err := cluster.DeleteDeleteRangeTask(id); err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, nil)
+}
+
+func (h *deleteRangeHandler) GetStorePieces(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	storeID, errParse := apiutil.ParseUint64VarsField(mux.Vars(r), "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetStoreDeleteRangePieces(storeID))
+}
+
+func (h *deleteRangeHandler) FinishPiece(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	vars := mux.Vars(r)
+	id, errParse := apiutil.ParseUint64VarsField(vars, "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	pieceID, errParse := apiutil.ParseUint64VarsField(vars, "piece_id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	var input struct {
+		RegionEpoch *metapb.RegionEpoch `json:"region_epoch"`
+	}
+	if err := readJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	if err := cluster.FinishDeleteRangePiece(id, pieceID, input.RegionEpoch); err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, nil)
+}
@@ -0,0 +1,188 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gorilla/websocket"
+	"github.com/pingcap/log"
+	"github.com/ugorji/go/codec"
+	"github.com/unrolled/render"
+	"go.uber.org/zap"
+)
+
+const (
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeMsgpack  = "application/x-msgpack"
+)
+
+var msgpackHandle = &codec.MsgpackHandle{}
+
+// negotiate returns the content type requested by the Accept header of the
+// request. It returns an empty string for JSON.
+func negotiate(r *http.Request) string {
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, contentTypeProtobuf):
+		return contentTypeProtobuf
+	case strings.Contains(accept, contentTypeMsgpack), strings.Contains(accept, "application/msgpack"):
+		return contentTypeMsgpack
+	}
+	return ""
+}
+
+// respond writes data in the encoding requested by the client, which is JSON
+// by default. pbFn builds the protobuf form of data. It responds 406 if
+// protobuf is requested but pbFn is nil.
+func respond(rd *render.Render, w http.ResponseWriter, r *http.Request, status int, data interface{}, pbFn func() proto.Message) {
+	var (
+		body []byte
+		err  error
+	)
+	contentType := negotiate(r)
+	switch contentType {
+	case contentTypeProtobuf:
+		if pbFn == nil {
+			rd.JSON(w, http.StatusNotAcceptable, "protobuf is not supported by the api")
+			return
+		}
+		body, err = proto.Marshal(pbFn())
+	case contentTypeMsgpack:
+		body, err = encodeMsgpack(data)
+	default:
+		rd.JSON(w, status, data)
+		return
+	}
+	if err != nil {
+		rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeBody(w, contentType, status, body)
+}
+
+func encodeMsgpack(data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := codec.NewEncoder(&buf, msgpackHandle).Encode(data)
+	return buf.Bytes(), err
+}
+
+func writeBody(w http.ResponseWriter, contentType string, status int, body []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		log.Error("failed to write response", zap.Error(err))
+	}
+}
+
+// encodingNegotiator applies the content negotiation to the APIs responding
+// JSON by themselves. The JSON responses are converted to msgpack if it is
+// requested, and 406 is responded if protobuf is requested. The responses
+// encoded by respond are written as they are.
+type encodingNegotiator struct {
+	rd *render.Render
+}
+
+func newEncodingNegotiator(rd *render.Render) *encodingNegotiator {
+	return &encodingNegotiator{rd: rd}
+}
+
+func (n *encodingNegotiator) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	contentType := negotiate(r)
+	if contentType == "" || websocket.IsWebSocketUpgrade(r) {
+		next(w, r)
+		return
+	}
+	buf := &bufferedResponseWriter{header: make(http.Header), status: http.StatusOK}
+	next(buf, r)
+	for key, values := range buf.header {
+		if key != "Content-Type" && key != "Content-Length" {
+			w.Header()[key] = values
+		}
+	}
+	if !strings.HasPrefix(buf.header.Get("Content-Type"), render.ContentJSON) {
+		writeBody(w, buf.header.Get("Content-Type"), buf.status, buf.body.Bytes())
+		return
+	}
+	if contentType == contentTypeProtobuf {
+		n.rd.JSON(w, http.StatusNotAcceptable, "protobuf is not supported by the api")
+		return
+	}
+	var data interface{}
+	decoder := json.NewDecoder(&buf.body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		n.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	body, err := encodeMsgpack(convertJSONNumbers(data))
+	if err != nil {
+		n.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeBody(w, contentType, buf.status, body)
+}
+
+// convertJSONNumbers converts the numbers decoded from JSON to integers if
+// they are, or floats, so that they are encoded as numbers.
+func convertJSONNumbers(data interface{}) interface{} {
+	switch v := data.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return u
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = convertJSONNumbers(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = convertJSONNumbers(value)
+		}
+	}
+	return data
+}
+
+// bufferedResponseWriter keeps the response in memory to encode it again.
+type bufferedResponseWriter struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = status, true
+	}
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.body.Write(b)
+}
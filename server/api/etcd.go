@@ -0,0 +1,55 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/pingcap/pd/server"
+	"github.com/unrolled/render"
+)
+
+type etcdHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+func newEtcdHandler(svr *server.Server, rd *render.Render) *etcdHandler {
+	return &etcdHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+// GetStatus returns the backend size against the quota, the alarms, the raft
+// index gaps of the members and the compaction status of etcd.
+func (h *etcdHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := h.svr.GetEtcdStatus()
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, status)
+}
+
+// Compact compacts and defragments etcd on demand, and returns the status
+// afterwards.
+func (h *etcdHandler) Compact(w http.ResponseWriter, r *http.Request) {
+	status, err := h.svr.CompactAndDefragmentEtcd()
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, status)
+}
@@ -44,7 +44,7 @@ func (h *healthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	client := h.svr.GetClient()
 	members, err := server.GetMembers(client)
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 	unhealthMembers := h.svr.CheckHealth(members)
@@ -63,3 +63,34 @@ func (h *healthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	h.rd.JSON(w, http.StatusOK, healths)
 }
+
+type readyHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+// Readiness reflects whether the member is ready to serve the clients.
+type Readiness struct {
+	Ready  bool                    `json:"ready"`
+	Checks []server.ReadinessCheck `json:"checks"`
+}
+
+func newReadyHandler(svr *server.Server, rd *render.Render) *readyHandler {
+	return &readyHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+func (h *readyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.svr.IsClosed() {
+		h.rd.JSON(w, http.StatusServiceUnavailable, Readiness{Checks: []server.ReadinessCheck{}})
+		return
+	}
+	ready, checks := h.svr.CheckReadiness()
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	h.rd.JSON(w, status, Readiness{Ready: ready, Checks: checks})
+}
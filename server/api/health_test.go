@@ -20,6 +20,7 @@ import (
 	"strings"
 
 	. "github.com/pingcap/check"
+	"github.com/pingcap/pd/pkg/testutil"
 	"github.com/pingcap/pd/server"
 	"github.com/pingcap/pd/server/config"
 )
@@ -75,3 +76,45 @@ func (s *testHealthAPISuite) TestHealthSlice(c *C) {
 	c.Assert(err, IsNil)
 	checkSliceResponse(c, buf, cfgs, follow.GetConfig().Name)
 }
+
+func (s *testHealthAPISuite) TestReady(c *C) {
+	_, svrs, clean := mustNewCluster(c, 3)
+	defer clean()
+	leader := mustWaitLeader(c, svrs)
+
+	checkReady := func(svr *server.Server, ready bool) Readiness {
+		var readiness Readiness
+		testutil.WaitUntil(c, func(c *C) bool {
+			resp, err := s.hc.Get(svr.GetConfig().ClientUrls + apiPrefix + "/api/v1/ready")
+			c.Assert(err, IsNil)
+			defer resp.Body.Close()
+			buf, err := ioutil.ReadAll(resp.Body)
+			c.Assert(err, IsNil)
+			c.Assert(json.Unmarshal(buf, &readiness), IsNil)
+			if readiness.Ready != ready {
+				return false
+			}
+			if ready {
+				c.Assert(resp.StatusCode, Equals, http.StatusOK)
+			} else {
+				c.Assert(resp.StatusCode, Equals, http.StatusServiceUnavailable)
+			}
+			return true
+		})
+		c.Assert(readiness.Checks, HasLen, 4)
+		return readiness
+	}
+
+	// Not ready before the cluster is bootstrapped.
+	for _, svr := range svrs {
+		readiness := checkReady(svr, false)
+		for _, check := range readiness.Checks {
+			c.Assert(check.Ready, Equals, check.Name != server.BootstrapCheck)
+		}
+	}
+
+	mustBootstrapCluster(c, leader)
+	for _, svr := range svrs {
+		checkReady(svr, true)
+	}
+}
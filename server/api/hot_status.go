@@ -41,11 +41,11 @@ func newHotStatusHandler(handler *server.Handler, rd *render.Render) *hotStatusH
 }
 
 func (h *hotStatusHandler) GetHotWriteRegions(w http.ResponseWriter, r *http.Request) {
-	h.rd.JSON(w, http.StatusOK, h.Handler.GetHotWriteRegions())
+	respond(h.rd, w, r, http.StatusOK, h.Handler.GetHotWriteRegions(), nil)
 }
 
 func (h *hotStatusHandler) GetHotReadRegions(w http.ResponseWriter, r *http.Request) {
-	h.rd.JSON(w, http.StatusOK, h.Handler.GetHotReadRegions())
+	respond(h.rd, w, r, http.StatusOK, h.Handler.GetHotReadRegions(), nil)
 }
 
 func (h *hotStatusHandler) GetHotStores(w http.ResponseWriter, r *http.Request) {
@@ -60,5 +60,27 @@ func (h *hotStatusHandler) GetHotStores(w http.ResponseWriter, r *http.Request)
 		KeysWriteStats:  keysWriteStats,
 		KeysReadStats:   keysReadStats,
 	}
-	h.rd.JSON(w, http.StatusOK, stats)
+	respond(h.rd, w, r, http.StatusOK, stats, nil)
+}
+
+func (h *hotStatusHandler) InjectSyntheticLoad(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	var load server.SyntheticLoad
+	if err := readJSONRespondError(h.rd, w, r.Body, &load); err != nil {
+		return
+	}
+	err := cluster.InjectSyntheticLoad(&load)
+	if err == server.ErrSyntheticLoadDisabled {
+		h.rd.JSON(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, nil)
 }
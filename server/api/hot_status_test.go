@@ -14,10 +14,12 @@
 package api
 
 import (
+	"bytes"
 	"fmt"
 	"net/http"
 
 	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/pd/server"
 	_ "github.com/pingcap/pd/server/schedulers"
 )
@@ -51,3 +53,22 @@ func (s testHotStatusSuite) TestGetHotStore(c *C) {
 	err = readJSON(resp.Body, &stat)
 	c.Assert(err, IsNil)
 }
+
+func (s testHotStatusSuite) TestInjectSyntheticLoad(c *C) {
+	mustPutStore(c, s.svr, 1, metapb.StoreState_Up, nil)
+	mustRegionHeartbeat(c, s.svr, newTestRegionInfo(2, 1, []byte("a"), []byte("b")))
+	url := s.urlPrefix + "/synthetic-load"
+	load := []byte(`{"regions":[{"region_id":2,"bytes_written":1048576}],"stores":[{"store_id":1,"bytes_written":1048576}]}`)
+	resp, err := dialClient.Post(url, "application/json", bytes.NewBuffer(load))
+	c.Assert(err, IsNil)
+	c.Assert(resp.Body.Close(), IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusForbidden)
+
+	configURL := fmt.Sprintf("%s%s/api/v1/config", s.svr.GetAddr(), apiPrefix)
+	c.Assert(postJSON(configURL, []byte(`{"enable-synthetic-load":"true"}`)), IsNil)
+	defer func() {
+		c.Assert(postJSON(configURL, []byte(`{"enable-synthetic-load":"false"}`)), IsNil)
+	}()
+	c.Assert(postJSON(url, load), IsNil)
+	c.Assert(postJSON(url, []byte(`{"regions":[{"region_id":100}]}`)), NotNil)
+}
@@ -39,7 +39,7 @@ func newLabelsHandler(svr *server.Server, rd *render.Render) *labelsHandler {
 func (h *labelsHandler) Get(w http.ResponseWriter, r *http.Request) {
 	cluster := h.svr.GetRaftCluster()
 	if cluster == nil {
-		h.rd.JSON(w, http.StatusInternalServerError, server.ErrNotBootstrapped.Error())
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
 		return
 	}
 	var labels []*metapb.StoreLabel
@@ -60,7 +60,7 @@ func (h *labelsHandler) Get(w http.ResponseWriter, r *http.Request) {
 func (h *labelsHandler) GetStores(w http.ResponseWriter, r *http.Request) {
 	cluster := h.svr.GetRaftCluster()
 	if cluster == nil {
-		h.rd.JSON(w, http.StatusInternalServerError, server.ErrNotBootstrapped.Error())
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
 		return
 	}
 
@@ -68,7 +68,7 @@ func (h *labelsHandler) GetStores(w http.ResponseWriter, r *http.Request) {
 	value := r.URL.Query().Get("value")
 	filter, err := newStoresLabelFilter(name, value)
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 
@@ -82,7 +82,7 @@ func (h *labelsHandler) GetStores(w http.ResponseWriter, r *http.Request) {
 		storeID := s.GetId()
 		store := cluster.GetStore(storeID)
 		if store == nil {
-			h.rd.JSON(w, http.StatusInternalServerError, server.ErrStoreNotFound(storeID))
+			errorResp(h.rd, w, server.ErrStoreNotFound(storeID))
 			return
 		}
 
@@ -0,0 +1,88 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pingcap/pd/server"
+	"github.com/pingcap/pd/server/schedule/labeler"
+	"github.com/unrolled/render"
+)
+
+type labelRuleHandler struct {
+	*server.Handler
+	rd *render.Render
+}
+
+func newLabelRuleHandler(handler *server.Handler, rd *render.Render) *labelRuleHandler {
+	return &labelRuleHandler{
+		Handler: handler,
+		rd:      rd,
+	}
+}
+
+func (h *labelRuleHandler) List(w http.ResponseWriter, r *http.Request) {
+	l, err := h.GetRangeLabeler()
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, l.GetLabelRules())
+}
+
+func (h *labelRuleHandler) Get(w http.ResponseWriter, r *http.Request) {
+	l, err := h.GetRangeLabeler()
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	rule := l.GetLabelRule(mux.Vars(r)["id"])
+	if rule == nil {
+		h.rd.JSON(w, http.StatusNotFound, "label rule not found")
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, rule)
+}
+
+func (h *labelRuleHandler) Set(w http.ResponseWriter, r *http.Request) {
+	l, err := h.GetRangeLabeler()
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	rule := &labeler.LabelRule{}
+	if err := readJSONRespondError(h.rd, w, r.Body, rule); err != nil {
+		return
+	}
+	if err := l.SetLabelRule(rule); err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, nil)
+}
+
+func (h *labelRuleHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	l, err := h.GetRangeLabeler()
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	if err := l.DeleteLabelRule(mux.Vars(r)["id"]); err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, nil)
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/pd/server"
+	"github.com/pingcap/pd/server/schedule/labeler"
+)
+
+var _ = Suite(&testLabelRuleSuite{})
+
+type testLabelRuleSuite struct {
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testLabelRuleSuite) SetUpSuite(c *C) {
+	s.svr, s.cleanup = mustNewServer(c)
+	mustWaitLeader(c, []*server.Server{s.svr})
+
+	addr := s.svr.GetAddr()
+	s.urlPrefix = fmt.Sprintf("%s%s/api/v1/config", addr, apiPrefix)
+
+	mustBootstrapCluster(c, s.svr)
+}
+
+func (s *testLabelRuleSuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func (s *testLabelRuleSuite) TestLabelRule(c *C) {
+	err := postJSON(s.urlPrefix+"/label-rule", []byte(`{"id":"1","label":"hot-serving","start_key":"zz"}`))
+	c.Assert(err, NotNil)
+	err = postJSON(s.urlPrefix+"/label-rule", []byte(`{"id":"1","label":"hot-serving","start_key":"61","end_key":"62"}`))
+	c.Assert(err, IsNil)
+
+	var rules []*labeler.LabelRule
+	c.Assert(readJSONWithURL(s.urlPrefix+"/label-rules", &rules), IsNil)
+	c.Assert(rules, HasLen, 1)
+	c.Assert(rules[0].Label, Equals, labeler.HotServing)
+
+	rule := &labeler.LabelRule{}
+	c.Assert(readJSONWithURL(s.urlPrefix+"/label-rule/1", rule), IsNil)
+	c.Assert(rule.StartKey, Equals, "61")
+
+	c.Assert(doDelete(s.urlPrefix+"/label-rule/1"), IsNil)
+	c.Assert(readJSONWithURL(s.urlPrefix+"/label-rule/1", rule), NotNil)
+}
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/pingcap/kvproto/pkg/pdpb"
@@ -26,6 +27,7 @@ import (
 	"github.com/pingcap/pd/server"
 	"github.com/pkg/errors"
 	"github.com/unrolled/render"
+	"go.etcd.io/etcd/pkg/types"
 	"go.uber.org/zap"
 )
 
@@ -44,7 +46,7 @@ func newMemberHandler(svr *server.Server, rd *render.Render) *memberHandler {
 func (h *memberHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
 	members, err := h.getMembers()
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 	h.rd.JSON(w, http.StatusOK, members)
@@ -72,6 +74,87 @@ func (h *memberHandler) getMembers() (*pdpb.GetMembersResponse, error) {
 	return members, nil
 }
 
+// MemberAddInput is the PD server to add to the cluster.
+type MemberAddInput struct {
+	Name     string   `json:"name"`
+	PeerURLs []string `json:"peer_urls"`
+	// IsLearner is always rejected. The embedded etcd has no learners, so
+	// adding members as learners and promoting them are not supported, and a
+	// client asking for a learner should not get a voter instead.
+	IsLearner bool `json:"is_learner"`
+}
+
+// MemberAddOutput is the added PD server, with the initial-cluster to start
+// it with.
+type MemberAddOutput struct {
+	ID             uint64   `json:"id"`
+	Name           string   `json:"name"`
+	PeerURLs       []string `json:"peer_urls"`
+	InitialCluster string   `json:"initial_cluster"`
+}
+
+// AddMember adds a PD server to the cluster, which should be started with the
+// returned initial-cluster later, instead of joining the cluster.
+func (h *memberHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	var input MemberAddInput
+	if err := readJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	if input.IsLearner {
+		h.rd.JSON(w, http.StatusNotImplemented, "learner members are not supported by the embedded etcd")
+		return
+	}
+	if input.Name == "" {
+		h.rd.JSON(w, http.StatusBadRequest, "missing the name of the member")
+		return
+	}
+	if _, err := types.NewURLs(input.PeerURLs); err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, fmt.Sprintf("invalid peer urls: %v", err))
+		return
+	}
+
+	client := h.svr.GetClient()
+	listResp, err := etcdutil.ListEtcdMembers(client)
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	for _, m := range listResp.Members {
+		// A member without a name has been added but not started.
+		if m.Name == "" {
+			h.rd.JSON(w, http.StatusBadRequest, fmt.Sprintf("member %v has not started yet", m.ID))
+			return
+		}
+		if m.Name == input.Name {
+			h.rd.JSON(w, http.StatusBadRequest, fmt.Sprintf("duplicated pd: %s", input.Name))
+			return
+		}
+	}
+
+	addResp, err := etcdutil.AddEtcdMember(client, input.PeerURLs)
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	var pds []string
+	for _, m := range addResp.Members {
+		name := m.Name
+		if m.ID == addResp.Member.ID {
+			name = input.Name
+		}
+		for _, u := range m.PeerURLs {
+			pds = append(pds, fmt.Sprintf("%s=%s", name, u))
+		}
+	}
+	log.Info("pd member is added", zap.String("name", input.Name), zap.Uint64("id", addResp.Member.ID), zap.Strings("peer-urls", input.PeerURLs))
+	h.rd.JSON(w, http.StatusOK, &MemberAddOutput{
+		ID:             addResp.Member.ID,
+		Name:           input.Name,
+		PeerURLs:       addResp.Member.PeerURLs,
+		InitialCluster: strings.Join(pds, ","),
+	})
+}
+
 func (h *memberHandler) DeleteByName(w http.ResponseWriter, r *http.Request) {
 	client := h.svr.GetClient()
 
@@ -80,7 +163,7 @@ func (h *memberHandler) DeleteByName(w http.ResponseWriter, r *http.Request) {
 	name := mux.Vars(r)["name"]
 	listResp, err := etcdutil.ListEtcdMembers(client)
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 	for _, m := range listResp.Members {
@@ -97,14 +180,19 @@ func (h *memberHandler) DeleteByName(w http.ResponseWriter, r *http.Request) {
 	// Delete config.
 	err = h.svr.GetMember().DeleteMemberLeaderPriority(id)
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
+		return
+	}
+	err = h.svr.GetMember().UnregisterMember(id)
+	if err != nil {
+		errorResp(h.rd, w, err)
 		return
 	}
 
 	// Remove member by id
 	_, err = etcdutil.RemoveEtcdMember(client, id)
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 	h.rd.JSON(w, http.StatusOK, fmt.Sprintf("removed, pd: %s", name))
@@ -121,14 +209,19 @@ func (h *memberHandler) DeleteByID(w http.ResponseWriter, r *http.Request) {
 	// Delete config.
 	err = h.svr.GetMember().DeleteMemberLeaderPriority(id)
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
+		return
+	}
+	err = h.svr.GetMember().UnregisterMember(id)
+	if err != nil {
+		errorResp(h.rd, w, err)
 		return
 	}
 
 	client := h.svr.GetClient()
 	_, err = etcdutil.RemoveEtcdMember(client, id)
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 	h.rd.JSON(w, http.StatusOK, fmt.Sprintf("removed, pd: %v", id))
@@ -168,7 +261,7 @@ func (h *memberHandler) SetMemberPropertyByName(w http.ResponseWriter, r *http.R
 			}
 			err := h.svr.GetMember().SetMemberLeaderPriority(memberID, int(priority))
 			if err != nil {
-				h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+				errorResp(h.rd, w, err)
 				return
 			}
 		}
@@ -195,7 +288,7 @@ func (h *leaderHandler) Get(w http.ResponseWriter, r *http.Request) {
 func (h *leaderHandler) Resign(w http.ResponseWriter, r *http.Request) {
 	err := h.svr.GetMember().ResignLeader(h.svr.Context(), h.svr.Name(), "")
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 
@@ -203,9 +296,8 @@ func (h *leaderHandler) Resign(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *leaderHandler) Transfer(w http.ResponseWriter, r *http.Request) {
-	err := h.svr.GetMember().ResignLeader(h.svr.Context(), h.svr.Name(), mux.Vars(r)["next_leader"])
-	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+	if err := h.svr.TransferLeader(h.svr.Context(), mux.Vars(r)["next_leader"]); err != nil {
+		errorResp(h.rd, w, err)
 		return
 	}
 
@@ -14,7 +14,9 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
@@ -23,6 +25,7 @@ import (
 
 	. "github.com/pingcap/check"
 	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/pd/pkg/tempurl"
 	"github.com/pingcap/pd/server"
 	"github.com/pingcap/pd/server/config"
 )
@@ -98,3 +101,42 @@ func (s *testMemberAPISuite) TestMemberLeader(c *C) {
 	c.Assert(got.GetClientUrls(), DeepEquals, leader.GetClientUrls())
 	c.Assert(got.GetMemberId(), Equals, leader.GetMemberId())
 }
+
+func (s *testMemberAPISuite) TestMemberAdd(c *C) {
+	addr := s.cfgs[0].ClientUrls + apiPrefix + "/api/v1/members"
+	post := func(body string) (int, []byte) {
+		resp, err := s.hc.Post(addr, "application/json", bytes.NewBufferString(body))
+		c.Assert(err, IsNil)
+		defer resp.Body.Close()
+		buf, err := ioutil.ReadAll(resp.Body)
+		c.Assert(err, IsNil)
+		return resp.StatusCode, buf
+	}
+	peerURL := tempurl.Alloc()
+	code, _ := post(fmt.Sprintf(`{"name":"pd4","peer_urls":["%s"],"is_learner":true}`, peerURL))
+	c.Assert(code, Equals, http.StatusNotImplemented)
+	code, _ = post(fmt.Sprintf(`{"peer_urls":["%s"]}`, peerURL))
+	c.Assert(code, Equals, http.StatusBadRequest)
+	code, _ = post(`{"name":"pd4","peer_urls":["127.0.0.1:2380"]}`)
+	c.Assert(code, Equals, http.StatusBadRequest)
+	code, _ = post(fmt.Sprintf(`{"name":"%s","peer_urls":["%s"]}`, s.cfgs[1].Name, peerURL))
+	c.Assert(code, Equals, http.StatusBadRequest)
+
+	code, buf := post(fmt.Sprintf(`{"name":"pd4","peer_urls":["%s"]}`, peerURL))
+	c.Assert(code, Equals, http.StatusOK, Commentf("%s", buf))
+	var output MemberAddOutput
+	c.Assert(json.Unmarshal(buf, &output), IsNil)
+	defer func() {
+		c.Assert(doDelete(fmt.Sprintf("%s/id/%d", addr, output.ID)), IsNil)
+	}()
+	c.Assert(output.ID, Not(Equals), uint64(0))
+	c.Assert(output.PeerURLs, DeepEquals, []string{peerURL})
+	expected := []string{fmt.Sprintf("pd4=%s", peerURL)}
+	for _, cfg := range s.cfgs {
+		expected = append(expected, fmt.Sprintf("%s=%s", cfg.Name, cfg.AdvertisePeerUrls))
+	}
+	relaxEqualStings(c, strings.Split(output.InitialCluster, ","), expected)
+	// The added member has not started.
+	code, _ = post(fmt.Sprintf(`{"name":"pd5","peer_urls":["%s"]}`, tempurl.Alloc()))
+	c.Assert(code, Equals, http.StatusBadRequest)
+}
@@ -0,0 +1,126 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/pd/pkg/apiutil"
+	"github.com/pingcap/pd/pkg/typeutil"
+	"github.com/pingcap/pd/server"
+	"github.com/pkg/errors"
+	"github.com/unrolled/render"
+)
+
+type mergePauseHandler struct {
+	*server.Handler
+	rd *render.Render
+}
+
+func newMergePauseHandler(handler *server.Handler, rd *render.Render) *mergePauseHandler {
+	return &mergePauseHandler{
+		Handler: handler,
+		rd:      rd,
+	}
+}
+
+// MergePauseInput is the key range whose merges are paused, by whom, why and
+// for how long.
+type MergePauseInput struct {
+	Owner    string `json:"owner"`
+	Reason   string `json:"reason"`
+	StartKey string `json:"start_key"`
+	EndKey   string `json:"end_key"`
+	// PauseSplit also pauses splitting the regions in the range.
+	PauseSplit bool `json:"pause_split"`
+	// Lease is how long the pause lasts without being renewed, such as "30s".
+	Lease typeutil.Duration `json:"lease"`
+}
+
+func (h *mergePauseHandler) List(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetMergePauses())
+}
+
+func (h *mergePauseHandler) Pause(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	var input MergePauseInput
+	if err := readJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	startKey, err := hex.DecodeString(input.StartKey)
+	if err != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errors.Wrap(err, "start key should be in hex format")))
+		return
+	}
+	endKey, err := hex.DecodeString(input.EndKey)
+	if err != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errors.Wrap(err, "end key should be in hex format")))
+		return
+	}
+	pause, err := cluster.PauseMerge(input.Owner, input.Reason, startKey, endKey, input.PauseSplit, input.Lease.Duration)
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, pause)
+}
+
+func (h *mergePauseHandler) Renew(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	id, errParse := apiutil.ParseUint64VarsField(mux.Vars(r), "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	pause, err := cluster.RenewMergePause(id)
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, pause)
+}
+
+func (h *mergePauseHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	id, errParse := apiutil.ParseUint64VarsField(mux.Vars(r), "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	if err := cluster.ResumeMerge(id); err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, nil)
+}
@@ -0,0 +1,117 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/pd/pkg/apiutil"
+	"github.com/pingcap/pd/server"
+	"github.com/pkg/errors"
+	"github.com/unrolled/render"
+)
+
+type namespaceMigrationHandler struct {
+	*server.Handler
+	rd *render.Render
+}
+
+func newNamespaceMigrationHandler(handler *server.Handler, rd *render.Render) *namespaceMigrationHandler {
+	return &namespaceMigrationHandler{
+		Handler: handler,
+		rd:      rd,
+	}
+}
+
+func (h *namespaceMigrationHandler) List(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetNamespaceMigrations())
+}
+
+func (h *namespaceMigrationHandler) Get(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	id, errParse := apiutil.ParseUint64VarsField(mux.Vars(r), "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	status := cluster.GetNamespaceMigration(id)
+	if status == nil {
+		h.rd.JSON(w, http.StatusNotFound, "namespace migration not found")
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, status)
+}
+
+func (h *namespaceMigrationHandler) Post(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	var input struct {
+		From     string `json:"from"`
+		To       string `json:"to"`
+		StartKey string `json:"start_key"`
+		EndKey   string `json:"end_key"`
+	}
+	if err := readJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	startKey, err := hex.DecodeString(input.StartKey)
+	if err != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errors.Wrap(err, "start key should be in hex format")))
+		return
+	}
+	endKey, err := hex.DecodeString(input.EndKey)
+	if err != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errors.Wrap(err, "end key should be in hex format")))
+		return
+	}
+	migration, err := cluster.CreateNamespaceMigration(input.From, input.To, startKey, endKey)
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, migration)
+}
+
+func (h *namespaceMigrationHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	id, errParse := apiutil.ParseUint64VarsField(mux.Vars(r), "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	if err := cluster.DeleteNamespaceMigration(id); err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, nil)
+}
@@ -0,0 +1,99 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/server"
+	"github.com/pingcap/pd/server/config"
+	"github.com/pingcap/pd/table"
+)
+
+var _ = Suite(&testNamespaceMigrationSuite{})
+
+type testNamespaceMigrationSuite struct {
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testNamespaceMigrationSuite) SetUpSuite(c *C) {
+	s.svr, s.cleanup = mustNewServer(c, func(cfg *config.Config) { cfg.NamespaceClassifier = "table" })
+	mustWaitLeader(c, []*server.Server{s.svr})
+
+	addr := s.svr.GetAddr()
+	s.urlPrefix = fmt.Sprintf("%s%s/api/v1", addr, apiPrefix)
+
+	mustBootstrapCluster(c, s.svr)
+	mustPutStore(c, s.svr, 1, metapb.StoreState_Up, nil)
+	mustPutStore(c, s.svr, 2, metapb.StoreState_Up, nil)
+}
+
+func (s *testNamespaceMigrationSuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func tableStartKey(id int64) []byte {
+	return table.EncodeBytes(table.GenerateTableKey(id))
+}
+
+func (s *testNamespaceMigrationSuite) TestMigration(c *C) {
+	c.Assert(postJSON(s.urlPrefix+"/classifier/table/namespaces", []byte(`{"namespace":"ns1"}`)), IsNil)
+	c.Assert(postJSON(s.urlPrefix+"/classifier/table/store_ns/2", []byte(`{"namespace":"ns1","action":"add"}`)), IsNil)
+	mustRegionHeartbeat(c, s.svr, newTestRegionInfo(100, 1, tableStartKey(1), tableStartKey(2)))
+	mustRegionHeartbeat(c, s.svr, newTestRegionInfo(101, 2, tableStartKey(2), tableStartKey(3)))
+
+	url := s.urlPrefix + "/admin/namespace-migrations"
+	input := map[string]string{
+		"from":      "global",
+		"to":        "ns1",
+		"start_key": hex.EncodeToString(tableStartKey(1)),
+		"end_key":   hex.EncodeToString(tableStartKey(3)),
+	}
+	post := func(input map[string]string) error {
+		b, err := json.Marshal(input)
+		c.Assert(err, IsNil)
+		return postJSON(url, b)
+	}
+	c.Assert(post(map[string]string{"from": "global", "to": "ns2", "start_key": input["start_key"], "end_key": input["end_key"]}), NotNil)
+	c.Assert(post(map[string]string{"from": "global", "to": "ns1", "start_key": "zz", "end_key": input["end_key"]}), NotNil)
+	c.Assert(post(input), IsNil)
+	// Nothing is left in the source namespace.
+	c.Assert(post(input), NotNil)
+
+	var list []*server.NamespaceMigrationStatus
+	c.Assert(readJSONWithURL(url, &list), IsNil)
+	c.Assert(list, HasLen, 1)
+	c.Assert(list[0].From, Equals, "global")
+	c.Assert(list[0].To, Equals, "ns1")
+	c.Assert(list[0].TotalRegions, Equals, 2)
+	c.Assert(list[0].RelocatedRegions, Equals, 1)
+	c.Assert(list[0].Finished, IsFalse)
+
+	idURL := fmt.Sprintf("%s/%d", url, list[0].ID)
+	status := &server.NamespaceMigrationStatus{}
+	c.Assert(readJSONWithURL(idURL, status), IsNil)
+	c.Assert(status.TotalRegions, Equals, 2)
+
+	// Deleting the migration keeps the range in the target namespace.
+	c.Assert(doDelete(idURL), IsNil)
+	c.Assert(readJSONWithURL(idURL, status), NotNil)
+	c.Assert(s.svr.GetRaftCluster().GetNamespaceMigrations(), HasLen, 0)
+	c.Assert(s.svr.GetClassifier().IsTableIDExist(2), IsTrue)
+}
@@ -19,10 +19,15 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/pingcap/pd/server"
+	"github.com/pingcap/pd/server/core"
 	"github.com/pingcap/pd/server/schedule/operator"
 	"github.com/unrolled/render"
 )
 
+// defaultCancelReason is the reason recorded for the operators canceled by
+// the API without a reason.
+const defaultCancelReason = "canceled by admin"
+
 type operatorHandler struct {
 	*server.Handler
 	r *render.Render
@@ -46,7 +51,7 @@ func (h *operatorHandler) Get(w http.ResponseWriter, r *http.Request) {
 
 	op, err := h.GetOperatorStatus(regionID)
 	if err != nil {
-		h.r.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.r, w, err)
 		return
 	}
 
@@ -64,7 +69,7 @@ func (h *operatorHandler) List(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		results, err = h.GetOperators()
 		if err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			errorResp(h.r, w, err)
 			return
 		}
 	} else {
@@ -80,7 +85,7 @@ func (h *operatorHandler) List(w http.ResponseWriter, r *http.Request) {
 				ops, err = h.GetWaitingOperators()
 			}
 			if err != nil {
-				h.r.JSON(w, http.StatusInternalServerError, err.Error())
+				errorResp(h.r, w, err)
 				return
 			}
 			results = append(results, ops...)
@@ -115,7 +120,7 @@ func (h *operatorHandler) Post(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if err := h.AddTransferLeaderOperator(uint64(regionID), uint64(storeID)); err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			errorResp(h.r, w, err)
 			return
 		}
 	case "transfer-region":
@@ -134,7 +139,7 @@ func (h *operatorHandler) Post(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if err := h.AddTransferRegionOperator(uint64(regionID), storeIDs); err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			errorResp(h.r, w, err)
 			return
 		}
 	case "transfer-peer":
@@ -154,7 +159,7 @@ func (h *operatorHandler) Post(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if err := h.AddTransferPeerOperator(uint64(regionID), uint64(fromID), uint64(toID)); err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			errorResp(h.r, w, err)
 			return
 		}
 	case "add-peer":
@@ -169,7 +174,7 @@ func (h *operatorHandler) Post(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if err := h.AddAddPeerOperator(uint64(regionID), uint64(storeID)); err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			errorResp(h.r, w, err)
 			return
 		}
 	case "add-learner":
@@ -184,7 +189,7 @@ func (h *operatorHandler) Post(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if err := h.AddAddLearnerOperator(uint64(regionID), uint64(storeID)); err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			errorResp(h.r, w, err)
 			return
 		}
 	case "remove-peer":
@@ -199,7 +204,57 @@ func (h *operatorHandler) Post(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if err := h.AddRemovePeerOperator(uint64(regionID), uint64(storeID)); err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			errorResp(h.r, w, err)
+			return
+		}
+	case "promote-learner":
+		regionID, ok := input["region_id"].(float64)
+		if !ok {
+			h.r.JSON(w, http.StatusBadRequest, "missing region id")
+			return
+		}
+		storeID, ok := input["store_id"].(float64)
+		if !ok {
+			h.r.JSON(w, http.StatusBadRequest, "invalid store id of the learner")
+			return
+		}
+		if err := h.AddPromoteLearnerOperator(uint64(regionID), uint64(storeID)); err != nil {
+			errorResp(h.r, w, err)
+			return
+		}
+	case "demote-voter":
+		regionID, ok := input["region_id"].(float64)
+		if !ok {
+			h.r.JSON(w, http.StatusBadRequest, "missing region id")
+			return
+		}
+		storeID, ok := input["store_id"].(float64)
+		if !ok {
+			h.r.JSON(w, http.StatusBadRequest, "invalid store id of the voter")
+			return
+		}
+		if err := h.AddDemoteVoterOperator(uint64(regionID), uint64(storeID)); err != nil {
+			errorResp(h.r, w, err)
+			return
+		}
+	case "switch-role":
+		regionID, ok := input["region_id"].(float64)
+		if !ok {
+			h.r.JSON(w, http.StatusBadRequest, "missing region id")
+			return
+		}
+		learnerStoreID, ok := input["learner_store_id"].(float64)
+		if !ok {
+			h.r.JSON(w, http.StatusBadRequest, "invalid store id of the learner")
+			return
+		}
+		voterStoreID, ok := input["voter_store_id"].(float64)
+		if !ok {
+			h.r.JSON(w, http.StatusBadRequest, "invalid store id of the voter")
+			return
+		}
+		if err := h.AddSwitchRoleOperator(uint64(regionID), uint64(learnerStoreID), uint64(voterStoreID)); err != nil {
+			errorResp(h.r, w, err)
 			return
 		}
 	case "merge-region":
@@ -214,7 +269,7 @@ func (h *operatorHandler) Post(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if err := h.AddMergeRegionOperator(uint64(regionID), uint64(targetID)); err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			errorResp(h.r, w, err)
 			return
 		}
 	case "split-region":
@@ -240,7 +295,7 @@ func (h *operatorHandler) Post(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		if err := h.AddSplitRegionOperator(uint64(regionID), policy, keys); err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			errorResp(h.r, w, err)
 			return
 		}
 	case "scatter-region":
@@ -249,8 +304,17 @@ func (h *operatorHandler) Post(w http.ResponseWriter, r *http.Request) {
 			h.r.JSON(w, http.StatusBadRequest, "missing region id")
 			return
 		}
-		if err := h.AddScatterRegionOperator(uint64(regionID)); err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+		var priority *core.PriorityLevel
+		if v, ok := input["priority"].(string); ok {
+			level, err := core.ParsePriorityLevel(v)
+			if err != nil {
+				h.r.JSON(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			priority = &level
+		}
+		if err := h.AddScatterRegionOperator(uint64(regionID), priority); err != nil {
+			errorResp(h.r, w, err)
 			return
 		}
 	default:
@@ -261,6 +325,26 @@ func (h *operatorHandler) Post(w http.ResponseWriter, r *http.Request) {
 	h.r.JSON(w, http.StatusOK, nil)
 }
 
+func (h *operatorHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	var plans []server.OperatorPlan
+	if err := readJSONRespondError(h.r, w, r.Body, &plans); err != nil {
+		return
+	}
+	for _, plan := range plans {
+		if plan.Name == "" {
+			h.r.JSON(w, http.StatusBadRequest, "missing operator name")
+			return
+		}
+	}
+
+	previews, err := h.PreviewOperators(plans)
+	if err != nil {
+		errorResp(h.r, w, err)
+		return
+	}
+	h.r.JSON(w, http.StatusOK, previews)
+}
+
 func (h *operatorHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["region_id"]
 
@@ -270,8 +354,12 @@ func (h *operatorHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = h.RemoveOperator(regionID); err != nil {
-		h.r.JSON(w, http.StatusInternalServerError, err.Error())
+	reason := r.URL.Query().Get("reason")
+	if reason == "" {
+		reason = defaultCancelReason
+	}
+	if err = h.CancelOperator(regionID, reason); err != nil {
+		errorResp(h.r, w, err)
 		return
 	}
 
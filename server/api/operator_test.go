@@ -15,6 +15,7 @@ package api
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -68,18 +69,23 @@ func (s *testOperatorSuite) TestAddRemovePeer(c *C) {
 	mustRegionHeartbeat(c, s.svr, regionInfo)
 
 	regionURL := fmt.Sprintf("%s/operators/%d", s.urlPrefix, region.GetId())
-	operator := mustReadURL(c, regionURL)
-	c.Assert(strings.Contains(operator, "operator not found"), IsTrue)
+	status, body := requestStatusBody(c, newHTTPClient(), http.MethodGet, regionURL)
+	c.Assert(status, Equals, http.StatusNotFound)
+	c.Assert(strings.Contains(string(body), "operator not found"), IsTrue)
+	c.Assert(strings.Contains(string(body), "missing.operator"), IsTrue)
 
 	mustPutStore(c, s.svr, 3, metapb.StoreState_Up, nil)
 	err := postJSON(fmt.Sprintf("%s/operators", s.urlPrefix), []byte(`{"name":"add-peer", "region_id": 1, "store_id": 3}`))
 	c.Assert(err, IsNil)
-	operator = mustReadURL(c, regionURL)
+	operator := mustReadURL(c, regionURL)
 	c.Assert(strings.Contains(operator, "add learner peer 1 on store 3"), IsTrue)
 	c.Assert(strings.Contains(operator, "RUNNING"), IsTrue)
+	c.Assert(strings.Contains(operator, "progress: step 1/2: add learner peer 1 on store 3, waiting for the peer to be created"), IsTrue)
 
-	err = doDelete(regionURL)
+	err = doDelete(regionURL + "?reason=test")
 	c.Assert(err, IsNil)
+	operator = mustReadURL(c, regionURL)
+	c.Assert(strings.Contains(operator, "status: CANCEL, reason: test"), IsTrue)
 
 	err = postJSON(fmt.Sprintf("%s/operators", s.urlPrefix), []byte(`{"name":"remove-peer", "region_id": 1, "store_id": 2}`))
 	c.Assert(err, IsNil)
@@ -96,6 +102,13 @@ func (s *testOperatorSuite) TestAddRemovePeer(c *C) {
 	operator = mustReadURL(c, regionURL)
 	c.Assert(strings.Contains(operator, "add learner peer 2 on store 4"), IsTrue)
 
+	// The operator conflicts with the running one.
+	resp, err := newHTTPClient().Post(fmt.Sprintf("%s/operators", s.urlPrefix), "application/json", strings.NewReader(`{"name":"remove-peer", "region_id": 1, "store_id": 2}`))
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusConflict)
+	c.Assert(resp.Header.Get("TiDB-Error-Code"), Equals, "state.operator_conflict")
+
 	// Fail to add peer to tombstone store.
 	err = s.svr.GetRaftCluster().BuryStore(3, true)
 	c.Assert(err, IsNil)
@@ -133,6 +146,55 @@ func (s *testOperatorSuite) TestMergeRegionOperator(c *C) {
 	c.Assert(err, NotNil)
 }
 
+func (s *testOperatorSuite) TestPreviewOperators(c *C) {
+	mustPutStore(c, s.svr, 1, metapb.StoreState_Up, nil)
+	mustPutStore(c, s.svr, 2, metapb.StoreState_Up, nil)
+	peer1 := &metapb.Peer{Id: 51, StoreId: 1}
+	peer2 := &metapb.Peer{Id: 52, StoreId: 2}
+	region := &metapb.Region{
+		Id:          50,
+		StartKey:    []byte("x"),
+		EndKey:      []byte("y"),
+		Peers:       []*metapb.Peer{peer1, peer2},
+		RegionEpoch: &metapb.RegionEpoch{ConfVer: 10, Version: 10},
+	}
+	mustRegionHeartbeat(c, s.svr, core.NewRegionInfo(region, peer1, core.SetApproximateSize(30)))
+
+	url := fmt.Sprintf("%s/operators/preview", s.urlPrefix)
+	resp, err := newHTTPClient().Post(url, "application/json", strings.NewReader(`[{"name":"transfer-leader", "region_id": 50, "to_store_id": 2}]`))
+	c.Assert(err, IsNil)
+	var previews []*server.StoreInfluencePreview
+	c.Assert(json.NewDecoder(resp.Body).Decode(&previews), IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	c.Assert(previews, HasLen, 2)
+	c.Assert(previews[0].StoreID, Equals, uint64(1))
+	c.Assert(previews[0].LeaderCount, Equals, int64(-1))
+	c.Assert(previews[0].LeaderSize, Equals, int64(-30))
+	c.Assert(previews[0].LeaderScoreAfter, Equals, previews[0].LeaderScoreBefore-30)
+	c.Assert(previews[1].StoreID, Equals, uint64(2))
+	c.Assert(previews[1].LeaderCount, Equals, int64(1))
+	c.Assert(previews[1].LeaderScoreAfter, Equals, previews[1].LeaderScoreBefore+30)
+
+	// The operators are not added.
+	operator := mustReadURL(c, fmt.Sprintf("%s/operators/50", s.urlPrefix))
+	c.Assert(strings.Contains(operator, "operator not found"), IsTrue)
+
+	for _, body := range []string{
+		`[{"region_id": 50, "to_store_id": 2}]`,
+		`{"name":"transfer-leader"}`,
+	} {
+		resp, err = newHTTPClient().Post(url, "application/json", strings.NewReader(body))
+		c.Assert(err, IsNil)
+		resp.Body.Close()
+		c.Assert(resp.StatusCode, Equals, http.StatusBadRequest)
+	}
+	resp, err = newHTTPClient().Post(url, "application/json", strings.NewReader(`[{"name":"add-peer", "region_id": 50, "store_id": 100}]`))
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusNotFound)
+}
+
 func mustPutStore(c *C, svr *server.Server, id uint64, state metapb.StoreState, labels []*metapb.StoreLabel) {
 	_, err := svr.PutStore(context.Background(), &pdpb.PutStoreRequest{
 		Header: &pdpb.RequestHeader{ClusterId: svr.ClusterID()},
@@ -0,0 +1,109 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/pd/pkg/apiutil"
+	"github.com/pingcap/pd/server"
+	"github.com/unrolled/render"
+)
+
+type placementIntentHandler struct {
+	*server.Handler
+	rd *render.Render
+}
+
+func newPlacementIntentHandler(handler *server.Handler, rd *render.Render) *placementIntentHandler {
+	return &placementIntentHandler{
+		Handler: handler,
+		rd:      rd,
+	}
+}
+
+// PlacementIntentInput is the placement declared for the regions, which is
+// the store of the leaders, the stores of the peers, or both.
+type PlacementIntentInput struct {
+	RegionIDs     []uint64 `json:"region_ids"`
+	LeaderStoreID uint64   `json:"leader_store_id"`
+	StoreIDs      []uint64 `json:"store_ids"`
+	Reason        string   `json:"reason"`
+}
+
+func (h *placementIntentHandler) List(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetPlacementIntentStatuses())
+}
+
+func (h *placementIntentHandler) Post(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	var input PlacementIntentInput
+	if err := readJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	intent, err := cluster.AddPlacementIntent(input.RegionIDs, input.LeaderStoreID, input.StoreIDs, input.Reason)
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, intent)
+}
+
+func (h *placementIntentHandler) Get(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	id, errParse := apiutil.ParseUint64VarsField(mux.Vars(r), "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	status, err := cluster.GetPlacementIntentStatus(id)
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, status)
+}
+
+func (h *placementIntentHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	id, errParse := apiutil.ParseUint64VarsField(mux.Vars(r), "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	if err := cluster.DeletePlacementIntent(id); err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, nil)
+}
@@ -0,0 +1,75 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/pd/server"
+	"github.com/pingcap/pd/server/core"
+)
+
+var _ = Suite(&testPlacementIntentSuite{})
+
+type testPlacementIntentSuite struct {
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testPlacementIntentSuite) SetUpSuite(c *C) {
+	s.svr, s.cleanup = mustNewServer(c)
+	mustWaitLeader(c, []*server.Server{s.svr})
+
+	addr := s.svr.GetAddr()
+	s.urlPrefix = fmt.Sprintf("%s%s/api/v1/placement-intents", addr, apiPrefix)
+
+	mustBootstrapCluster(c, s.svr)
+}
+
+func (s *testPlacementIntentSuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func (s *testPlacementIntentSuite) TestPlacementIntent(c *C) {
+	mustRegionHeartbeat(c, s.svr, newTestRegionInfo(8, 1, nil, nil))
+
+	c.Assert(postJSON(s.urlPrefix, []byte(`{"region_ids":[8]}`)), NotNil)
+	c.Assert(postJSON(s.urlPrefix, []byte(`{"region_ids":[100],"leader_store_id":1}`)), NotNil)
+
+	var intent core.PlacementIntent
+	err := postJSON(s.urlPrefix, []byte(`{"region_ids":[8],"leader_store_id":1,"reason":"test"}`), func(res []byte) bool {
+		return json.Unmarshal(res, &intent) == nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(intent.RegionIDs, DeepEquals, []uint64{8})
+
+	var statuses []*server.PlacementIntentStatus
+	c.Assert(readJSONWithURL(s.urlPrefix, &statuses), IsNil)
+	c.Assert(statuses, HasLen, 1)
+	c.Assert(statuses[0].State, Equals, server.IntentConverged)
+	var status server.PlacementIntentStatus
+	c.Assert(readJSONWithURL(fmt.Sprintf("%s/%d", s.urlPrefix, intent.ID), &status), IsNil)
+	c.Assert(status.Intent.ID, Equals, intent.ID)
+	c.Assert(status.Converged, Equals, 1)
+
+	c.Assert(doDelete(fmt.Sprintf("%s/%d", s.urlPrefix, intent.ID)), IsNil)
+	resp, err := dialClient.Get(fmt.Sprintf("%s/%d", s.urlPrefix, intent.ID))
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusNotFound)
+}
@@ -14,15 +14,22 @@
 package api
 
 import (
+	"bytes"
 	"container/heap"
+	"encoding/hex"
 	"net/http"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 
+	"github.com/gogo/protobuf/proto"
 	"github.com/gorilla/mux"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/pingcap/pd/server"
 	"github.com/pingcap/pd/server/core"
+	"github.com/pkg/errors"
 	"github.com/unrolled/render"
 )
 
@@ -43,6 +50,8 @@ type RegionInfo struct {
 	ReadKeys        uint64            `json:"read_keys,omitempty"`
 	ApproximateSize int64             `json:"approximate_size,omitempty"`
 	ApproximateKeys int64             `json:"approximate_keys,omitempty"`
+	// LearnerLags is the applied index lags of the learners keyed by peer ID.
+	LearnerLags map[uint64]uint64 `json:"learner_lags,omitempty"`
 }
 
 // NewRegionInfo create a new api RegionInfo.
@@ -65,6 +74,7 @@ func NewRegionInfo(r *core.RegionInfo) *RegionInfo {
 		ReadKeys:        r.GetKeysRead(),
 		ApproximateSize: r.GetApproximateSize(),
 		ApproximateKeys: r.GetApproximateKeys(),
+		LearnerLags:     r.GetLearnerLags(),
 	}
 }
 
@@ -72,8 +82,15 @@ func NewRegionInfo(r *core.RegionInfo) *RegionInfo {
 type RegionsInfo struct {
 	Count   int           `json:"count"`
 	Regions []*RegionInfo `json:"regions"`
+	// NextPageToken is used to fetch the next page of a paginated listing.
+	// It is empty if there are no more regions.
+	NextPageToken string `json:"next_page_token,omitempty"`
 }
 
+// nextPageTokenHeader carries the next page token for encodings which have
+// no field for it, such as protobuf.
+const nextPageTokenHeader = "PD-Next-Page-Token"
+
 type regionHandler struct {
 	svr *server.Server
 	rd  *render.Render
@@ -89,7 +106,7 @@ func newRegionHandler(svr *server.Server, rd *render.Render) *regionHandler {
 func (h *regionHandler) GetRegionByID(w http.ResponseWriter, r *http.Request) {
 	cluster := h.svr.GetRaftCluster()
 	if cluster == nil {
-		h.rd.JSON(w, http.StatusInternalServerError, server.ErrNotBootstrapped.Error())
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
 		return
 	}
 
@@ -108,7 +125,7 @@ func (h *regionHandler) GetRegionByID(w http.ResponseWriter, r *http.Request) {
 func (h *regionHandler) GetRegionByKey(w http.ResponseWriter, r *http.Request) {
 	cluster := h.svr.GetRaftCluster()
 	if cluster == nil {
-		h.rd.JSON(w, http.StatusInternalServerError, server.ErrNotBootstrapped.Error())
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
 		return
 	}
 	vars := mux.Vars(r)
@@ -129,6 +146,186 @@ func newRegionsHandler(svr *server.Server, rd *render.Render) *regionsHandler {
 	}
 }
 
+func convertToProtoRegions(regions []*core.RegionInfo) *pdpb.ScanRegionsResponse {
+	resp := &pdpb.ScanRegionsResponse{}
+	for _, r := range regions {
+		if r == nil {
+			continue
+		}
+		resp.Regions = append(resp.Regions, r.GetMeta())
+		resp.Leaders = append(resp.Leaders, r.GetLeader())
+	}
+	return resp
+}
+
+// renderRegions responds the regions in the encoding requested by the client.
+// If the `fields` parameter is given, only the listed fields of each region
+// are responded, which does not apply to protobuf.
+func (h *regionsHandler) renderRegions(w http.ResponseWriter, r *http.Request, regions []*core.RegionInfo, nextPageToken string) {
+	fields, err := parseRegionFields(r)
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if nextPageToken != "" {
+		w.Header().Set(nextPageTokenHeader, nextPageToken)
+	}
+	regionsInfo := convertToAPIRegions(regions)
+	regionsInfo.NextPageToken = nextPageToken
+	var data interface{} = regionsInfo
+	if len(fields) > 0 {
+		data = projectRegions(regionsInfo, fields)
+	}
+	respond(h.rd, w, r, http.StatusOK, data, func() proto.Message { return convertToProtoRegions(regions) })
+}
+
+// renderRegionList paginates the regions by their keys if requested, then
+// responds them. It is used by the APIs which cannot scan regions by key from
+// the cluster. Like ScanRegions, a page starts from the region containing the
+// start key, and the next page token is the end key of the last region of the
+// page. The token is not tied to a snapshot of the regions, so a region merged
+// or split between the pages may be listed again, but no region is skipped.
+func (h *regionsHandler) renderRegionList(w http.ResponseWriter, r *http.Request, regions []*core.RegionInfo) {
+	startKey, limit, paged, err := parsePage(r)
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !paged {
+		h.renderRegions(w, r, regions, "")
+		return
+	}
+	regions = append(regions[:0:0], regions...)
+	sort.Slice(regions, func(i, j int) bool {
+		return bytes.Compare(regions[i].GetStartKey(), regions[j].GetStartKey()) < 0
+	})
+	i := sort.Search(len(regions), func(i int) bool {
+		endKey := regions[i].GetEndKey()
+		return len(endKey) == 0 || bytes.Compare(endKey, startKey) > 0
+	})
+	regions = regions[i:]
+	var nextPageToken string
+	if len(regions) > limit {
+		regions = regions[:limit]
+		nextPageToken = hex.EncodeToString(regions[limit-1].GetEndKey())
+	}
+	h.renderRegions(w, r, regions, nextPageToken)
+}
+
+// parsePage parses the pagination parameters. `start_key` is the hex encoded
+// key to start from, and `page_token` is the continuation returned by the
+// previous page, which takes precedence. The listing is paged if either of
+// them or `limit` is given.
+func parsePage(r *http.Request) (startKey []byte, limit int, paged bool, err error) {
+	query := r.URL.Query()
+	key, limitStr := query.Get("start_key"), query.Get("limit")
+	if token := query.Get("page_token"); token != "" {
+		key = token
+	}
+	if key == "" && limitStr == "" {
+		return nil, 0, false, nil
+	}
+	if startKey, err = hex.DecodeString(key); err != nil {
+		return nil, 0, false, errors.New("start key or page token should be in hex format")
+	}
+	limit = maxRegionLimit
+	if limitStr != "" {
+		if limit, err = strconv.Atoi(limitStr); err != nil {
+			return nil, 0, false, errors.WithStack(err)
+		}
+	}
+	if limit <= 0 || limit > maxRegionLimit {
+		limit = maxRegionLimit
+	}
+	return startKey, limit, true, nil
+}
+
+// parseRegionOptions parses the predicates of regions from the query, so that
+// clients do not have to download all regions to filter them.
+func parseRegionOptions(r *http.Request) ([]core.RegionOption, error) {
+	query := r.URL.Query()
+	var opts []core.RegionOption
+	if s := query.Get("leader_store"); s != "" {
+		storeID, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid leader store %s", s)
+		}
+		opts = append(opts, core.LeaderInStore(storeID))
+	}
+	if s := query.Get("peer_store"); s != "" {
+		storeID, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid peer store %s", s)
+		}
+		opts = append(opts, core.PeerInStore(storeID))
+	}
+	if s := query.Get("down_peer"); s != "" {
+		downPeer, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, errors.Errorf("invalid down peer %s", s)
+		}
+		if downPeer {
+			opts = append(opts, core.DownPeerRegion())
+		}
+	}
+	return opts, nil
+}
+
+// regionInfoFields maps the json names of RegionInfo fields to their indexes.
+var regionInfoFields = func() map[string]int {
+	fields := make(map[string]int)
+	t := reflect.TypeOf(RegionInfo{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		fields[name] = i
+	}
+	return fields
+}()
+
+// parseRegionFields parses the comma separated `fields` parameter.
+func parseRegionFields(r *http.Request) ([]string, error) {
+	value := r.URL.Query().Get("fields")
+	if value == "" {
+		return nil, nil
+	}
+	var fields []string
+	for _, f := range strings.Split(value, ",") {
+		f = strings.TrimSpace(f)
+		if _, ok := regionInfoFields[f]; !ok {
+			return nil, errors.Errorf("unknown region field %q", f)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// projectedRegionsInfo is RegionsInfo with only part of the region fields.
+type projectedRegionsInfo struct {
+	Count         int                      `json:"count"`
+	Regions       []map[string]interface{} `json:"regions"`
+	NextPageToken string                   `json:"next_page_token,omitempty"`
+}
+
+func projectRegions(regionsInfo *RegionsInfo, fields []string) *projectedRegionsInfo {
+	projected := &projectedRegionsInfo{
+		Count:         regionsInfo.Count,
+		Regions:       make([]map[string]interface{}, len(regionsInfo.Regions)),
+		NextPageToken: regionsInfo.NextPageToken,
+	}
+	for i, region := range regionsInfo.Regions {
+		if region == nil {
+			continue
+		}
+		v := reflect.ValueOf(region).Elem()
+		m := make(map[string]interface{}, len(fields))
+		for _, f := range fields {
+			m[f] = v.Field(regionInfoFields[f]).Interface()
+		}
+		projected.Regions[i] = m
+	}
+	return projected
+}
+
 func convertToAPIRegions(regions []*core.RegionInfo) *RegionsInfo {
 	regionInfos := make([]*RegionInfo, len(regions))
 	for i, r := range regions {
@@ -143,18 +340,33 @@ func convertToAPIRegions(regions []*core.RegionInfo) *RegionsInfo {
 func (h *regionsHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 	cluster := h.svr.GetRaftCluster()
 	if cluster == nil {
-		h.rd.JSON(w, http.StatusInternalServerError, server.ErrNotBootstrapped.Error())
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
 		return
 	}
-	regions := cluster.GetRegions()
-	regionsInfo := convertToAPIRegions(regions)
-	h.rd.JSON(w, http.StatusOK, regionsInfo)
+	startKey, limit, paged, err := parsePage(r)
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !paged {
+		h.renderRegions(w, r, cluster.GetRegions(), "")
+		return
+	}
+	regions := cluster.ScanRegions(startKey, nil, limit)
+	// The token is the end key of the last region, see renderRegionList.
+	var nextPageToken string
+	if len(regions) == limit {
+		if endKey := regions[len(regions)-1].GetEndKey(); len(endKey) > 0 {
+			nextPageToken = hex.EncodeToString(endKey)
+		}
+	}
+	h.renderRegions(w, r, regions, nextPageToken)
 }
 
 func (h *regionsHandler) ScanRegions(w http.ResponseWriter, r *http.Request) {
 	cluster := h.svr.GetRaftCluster()
 	if cluster == nil {
-		h.rd.JSON(w, http.StatusInternalServerError, server.ErrNotBootstrapped.Error())
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
 		return
 	}
 
@@ -172,15 +384,19 @@ func (h *regionsHandler) ScanRegions(w http.ResponseWriter, r *http.Request) {
 	if limit > maxRegionLimit {
 		limit = maxRegionLimit
 	}
-	regions := cluster.ScanRegions([]byte(startKey), nil, limit)
-	regionsInfo := convertToAPIRegions(regions)
-	h.rd.JSON(w, http.StatusOK, regionsInfo)
+	opts, err := parseRegionOptions(r)
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	regions := cluster.ScanRegions([]byte(startKey), nil, limit, opts...)
+	h.renderRegions(w, r, regions, "")
 }
 
 func (h *regionsHandler) GetStoreRegions(w http.ResponseWriter, r *http.Request) {
 	cluster := h.svr.GetRaftCluster()
 	if cluster == nil {
-		h.rd.JSON(w, http.StatusInternalServerError, server.ErrNotBootstrapped.Error())
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
 		return
 	}
 
@@ -191,91 +407,103 @@ func (h *regionsHandler) GetStoreRegions(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	regions := cluster.GetStoreRegions(uint64(id))
-	regionsInfo := convertToAPIRegions(regions)
-	h.rd.JSON(w, http.StatusOK, regionsInfo)
+	h.renderRegionList(w, r, regions)
 }
 
 func (h *regionsHandler) GetMissPeerRegions(w http.ResponseWriter, r *http.Request) {
 	handler := h.svr.GetHandler()
 	regions, err := handler.GetMissPeerRegions()
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
-	regionsInfo := convertToAPIRegions(regions)
-	h.rd.JSON(w, http.StatusOK, regionsInfo)
+	h.renderRegionList(w, r, regions)
 }
 
 func (h *regionsHandler) GetExtraPeerRegions(w http.ResponseWriter, r *http.Request) {
 	handler := h.svr.GetHandler()
 	regions, err := handler.GetExtraPeerRegions()
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
-	regionsInfo := convertToAPIRegions(regions)
-	h.rd.JSON(w, http.StatusOK, regionsInfo)
+	h.renderRegionList(w, r, regions)
 }
 
 func (h *regionsHandler) GetPendingPeerRegions(w http.ResponseWriter, r *http.Request) {
 	handler := h.svr.GetHandler()
 	regions, err := handler.GetPendingPeerRegions()
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
-	regionsInfo := convertToAPIRegions(regions)
-	h.rd.JSON(w, http.StatusOK, regionsInfo)
+	h.renderRegionList(w, r, regions)
 }
 
 func (h *regionsHandler) GetDownPeerRegions(w http.ResponseWriter, r *http.Request) {
 	handler := h.svr.GetHandler()
 	regions, err := handler.GetDownPeerRegions()
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
-	regionsInfo := convertToAPIRegions(regions)
-	h.rd.JSON(w, http.StatusOK, regionsInfo)
+	h.renderRegionList(w, r, regions)
 }
 
 func (h *regionsHandler) GetIncorrectNamespaceRegions(w http.ResponseWriter, r *http.Request) {
 	handler := h.svr.GetHandler()
 	regions, err := handler.GetIncorrectNamespaceRegions()
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
-	regionsInfo := convertToAPIRegions(regions)
-	h.rd.JSON(w, http.StatusOK, regionsInfo)
+	h.renderRegionList(w, r, regions)
 }
 
 func (h *regionsHandler) GetOfflinePeer(w http.ResponseWriter, r *http.Request) {
 	handler := h.svr.GetHandler()
 	regions, err := handler.GetOfflinePeer()
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
-	regionsInfo := convertToAPIRegions(regions)
-	h.rd.JSON(w, http.StatusOK, regionsInfo)
+	h.renderRegionList(w, r, regions)
 }
 
 func (h *regionsHandler) GetEmptyRegion(w http.ResponseWriter, r *http.Request) {
 	handler := h.svr.GetHandler()
 	regions, err := handler.GetEmptyRegion()
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
-	regionsInfo := convertToAPIRegions(regions)
-	h.rd.JSON(w, http.StatusOK, regionsInfo)
+	h.renderRegionList(w, r, regions)
+}
+
+func (h *regionsHandler) GetLongEmptyRegions(w http.ResponseWriter, r *http.Request) {
+	handler := h.svr.GetHandler()
+	regions, err := handler.GetLongEmptyRegions()
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.renderRegionList(w, r, regions)
+}
+
+func (h *regionsHandler) GetLaggingLearnerRegions(w http.ResponseWriter, r *http.Request) {
+	handler := h.svr.GetHandler()
+	regions, err := handler.GetLaggingLearnerRegions()
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.renderRegionList(w, r, regions)
 }
 
 func (h *regionsHandler) GetRegionSiblings(w http.ResponseWriter, r *http.Request) {
 	cluster := h.svr.GetRaftCluster()
 	if cluster == nil {
-		h.rd.JSON(w, http.StatusInternalServerError, server.ErrNotBootstrapped.Error())
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
 		return
 	}
 
@@ -287,13 +515,12 @@ func (h *regionsHandler) GetRegionSiblings(w http.ResponseWriter, r *http.Reques
 	}
 	region := cluster.GetRegion(uint64(id))
 	if region == nil {
-		h.rd.JSON(w, http.StatusNotFound, server.ErrRegionNotFound(uint64(id)).Error())
+		errorResp(h.rd, w, server.ErrRegionNotFound(uint64(id)))
 		return
 	}
 
 	left, right := cluster.GetAdjacentRegions(region)
-	regionsInfo := convertToAPIRegions([]*core.RegionInfo{left, right})
-	h.rd.JSON(w, http.StatusOK, regionsInfo)
+	h.renderRegions(w, r, []*core.RegionInfo{left, right}, "")
 }
 
 const (
@@ -330,7 +557,7 @@ func (h *regionsHandler) GetTopSize(w http.ResponseWriter, r *http.Request) {
 func (h *regionsHandler) GetTopNRegions(w http.ResponseWriter, r *http.Request, less func(a, b *core.RegionInfo) bool) {
 	cluster := h.svr.GetRaftCluster()
 	if cluster == nil {
-		h.rd.JSON(w, http.StatusInternalServerError, server.ErrNotBootstrapped.Error())
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
 		return
 	}
 	limit := defaultRegionLimit
@@ -346,8 +573,7 @@ func (h *regionsHandler) GetTopNRegions(w http.ResponseWriter, r *http.Request,
 		limit = maxRegionLimit
 	}
 	regions := TopNRegions(cluster.GetRegions(), less, limit)
-	regionsInfo := convertToAPIRegions(regions)
-	h.rd.JSON(w, http.StatusOK, regionsInfo)
+	h.renderRegions(w, r, regions, "")
 }
 
 // RegionHeap implements heap.Interface, used for selecting top n regions.
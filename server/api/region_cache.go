@@ -0,0 +1,49 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/pingcap/pd/server"
+	"github.com/unrolled/render"
+)
+
+// regionCacheHashHandler hashes the regions synchronized by the member
+// serving the request, which is requested by the leader to check the
+// consistency of the region caches.
+type regionCacheHashHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+func newRegionCacheHashHandler(svr *server.Server, rd *render.Render) *regionCacheHashHandler {
+	return &regionCacheHashHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+func (h *regionCacheHashHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var shards []*server.RegionCacheShard
+	if err := readJSONRespondError(h.rd, w, r.Body, &shards); err != nil {
+		return
+	}
+	hashes, err := h.svr.HashSyncedRegions(shards)
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, hashes)
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/pingcap/pd/pkg/typeutil"
+	"github.com/pingcap/pd/server"
+	"github.com/unrolled/render"
+)
+
+type regionPinHandler struct {
+	*server.Handler
+	rd *render.Render
+}
+
+func newRegionPinHandler(handler *server.Handler, rd *render.Render) *regionPinHandler {
+	return &regionPinHandler{
+		Handler: handler,
+		rd:      rd,
+	}
+}
+
+// RegionPinInput is where the leader or the peers of a region are pinned,
+// why and for how long.
+type RegionPinInput struct {
+	LeaderStoreID uint64   `json:"leader_store_id"`
+	StoreIDs      []uint64 `json:"store_ids"`
+	Reason        string   `json:"reason"`
+	// TTL is how long the region is pinned, such as "30m".
+	TTL typeutil.Duration `json:"ttl"`
+}
+
+func (h *regionPinHandler) List(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetRegionPins())
+}
+
+func (h *regionPinHandler) Pin(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	regionID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var input RegionPinInput
+	if err := readJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	pin, err := cluster.PinRegion(regionID, input.LeaderStoreID, input.StoreIDs, input.Reason, input.TTL.Duration)
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, pin)
+}
+
+func (h *regionPinHandler) Unpin(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	regionID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := cluster.UnpinRegion(regionID); err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, nil)
+}
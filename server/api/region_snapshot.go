@@ -0,0 +1,99 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/pingcap/pd/server"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pkg/errors"
+	"github.com/unrolled/render"
+)
+
+// RegionSnapshotInfo is the boundaries and the replica placements of the
+// regions at a point in time.
+type RegionSnapshotInfo struct {
+	server.RegionSequence
+	Time    time.Time     `json:"time"`
+	Count   int           `json:"count"`
+	Regions []*RegionInfo `json:"regions"`
+}
+
+type regionSnapshotHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+func newRegionSnapshotHandler(svr *server.Server, rd *render.Render) *regionSnapshotHandler {
+	return &regionSnapshotHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+func (h *regionSnapshotHandler) Get(w http.ResponseWriter, r *http.Request) {
+	cluster := h.svr.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	query := r.URL.Query()
+	startKey, err := hex.DecodeString(query.Get("start_key"))
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, errors.New("start key should be in hex format").Error())
+		return
+	}
+	endKey, err := hex.DecodeString(query.Get("end_key"))
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, errors.New("end key should be in hex format").Error())
+		return
+	}
+	snapshot := cluster.GetRegionSnapshot(startKey, endKey)
+	info := &RegionSnapshotInfo{
+		RegionSequence: snapshot.RegionSequence,
+		Time:           snapshot.Time,
+		Count:          len(snapshot.Regions),
+		Regions:        make([]*RegionInfo, len(snapshot.Regions)),
+	}
+	for i, region := range snapshot.Regions {
+		info.Regions[i] = newRegionPlacement(region)
+	}
+	h.rd.JSON(w, http.StatusOK, info)
+}
+
+func (h *regionSnapshotHandler) GetSequence(w http.ResponseWriter, r *http.Request) {
+	cluster := h.svr.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetRegionSequence())
+}
+
+// newRegionPlacement returns the RegionInfo with only the boundaries and the
+// replica placement of the region, which keeps the snapshot of large clusters
+// small.
+func newRegionPlacement(r *core.RegionInfo) *RegionInfo {
+	return &RegionInfo{
+		ID:          r.GetID(),
+		StartKey:    string(core.HexRegionKey(r.GetStartKey())),
+		EndKey:      string(core.HexRegionKey(r.GetEndKey())),
+		RegionEpoch: r.GetRegionEpoch(),
+		Peers:       r.GetPeers(),
+		Leader:      r.GetLeader(),
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/pd/server"
+	"github.com/pingcap/pd/server/core"
+)
+
+var _ = Suite(&testRegionSnapshotSuite{})
+
+type testRegionSnapshotSuite struct {
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testRegionSnapshotSuite) SetUpSuite(c *C) {
+	s.svr, s.cleanup = mustNewServer(c)
+	mustWaitLeader(c, []*server.Server{s.svr})
+
+	addr := s.svr.GetAddr()
+	s.urlPrefix = fmt.Sprintf("%s%s/api/v1", addr, apiPrefix)
+
+	mustBootstrapCluster(c, s.svr)
+}
+
+func (s *testRegionSnapshotSuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func (s *testRegionSnapshotSuite) TestRegionSnapshot(c *C) {
+	r1 := newTestRegionInfo(100, 1, []byte("a"), []byte("b"))
+	r2 := newTestRegionInfo(101, 1, []byte("b"), []byte("c"))
+	r3 := newTestRegionInfo(102, 1, []byte("c"), []byte("d"))
+	for _, r := range []*core.RegionInfo{r1, r2, r3} {
+		mustRegionHeartbeat(c, s.svr, r)
+	}
+
+	sequence := &server.RegionSequence{}
+	c.Assert(readJSONWithURL(s.urlPrefix+"/regions/snapshot/sequence", sequence), IsNil)
+
+	url := fmt.Sprintf("%s/regions/snapshot?start_key=%s&end_key=%s", s.urlPrefix,
+		hex.EncodeToString([]byte("b")), hex.EncodeToString([]byte("d")))
+	snapshot := &RegionSnapshotInfo{}
+	c.Assert(readJSONWithURL(url, snapshot), IsNil)
+	c.Assert(snapshot.RegionSequence, Equals, *sequence)
+	c.Assert(snapshot.Count, Equals, 2)
+	c.Assert(snapshot.Regions, DeepEquals, []*RegionInfo{newRegionPlacement(r2), newRegionPlacement(r3)})
+
+	// Heartbeats without meta changes do not invalidate the snapshot.
+	mustRegionHeartbeat(c, s.svr, r2.Clone(core.SetApproximateSize(20)))
+	c.Assert(readJSONWithURL(s.urlPrefix+"/regions/snapshot/sequence", sequence), IsNil)
+	c.Assert(sequence.Sequence, Equals, snapshot.Sequence)
+
+	// Splits do.
+	r4 := newTestRegionInfo(103, 1, []byte("b"), []byte("bb"))
+	r4 = r4.Clone(core.WithIncVersion())
+	mustRegionHeartbeat(c, s.svr, r4)
+	c.Assert(readJSONWithURL(s.urlPrefix+"/regions/snapshot/sequence", sequence), IsNil)
+	c.Assert(sequence.Generation, Equals, snapshot.Generation)
+	c.Assert(sequence.Sequence > snapshot.Sequence, IsTrue)
+
+	err := readJSONWithURL(s.urlPrefix+"/regions/snapshot?start_key=zz", snapshot)
+	c.Assert(err, NotNil)
+}
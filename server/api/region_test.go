@@ -14,16 +14,23 @@
 package api
 
 import (
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
+	"net/http"
 	"net/url"
 	"sort"
+	"time"
 
+	"github.com/gogo/protobuf/proto"
 	. "github.com/pingcap/check"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/pd/pkg/typeutil"
 	"github.com/pingcap/pd/server"
 	"github.com/pingcap/pd/server/core"
+	"github.com/ugorji/go/codec"
 )
 
 var _ = Suite(&testRegionSuite{})
@@ -132,6 +139,20 @@ func (s *testRegionSuite) TestRegionCheck(c *C) {
 	err = readJSONWithURL(url, r5)
 	c.Assert(err, IsNil)
 	c.Assert(r5, DeepEquals, &RegionsInfo{Count: 1, Regions: []*RegionInfo{NewRegionInfo(r)}})
+
+	url = fmt.Sprintf("%s/regions/check/%s", s.urlPrefix, "long-empty-region")
+	r6 := &RegionsInfo{}
+	c.Assert(readJSONWithURL(url, r6), IsNil)
+	c.Assert(r6, DeepEquals, &RegionsInfo{Count: 0, Regions: []*RegionInfo{}})
+	cfg := s.svr.GetScheduleConfig()
+	cfg.LongEmptyRegionDuration = typeutil.NewDuration(time.Millisecond)
+	c.Assert(s.svr.SetScheduleConfig(*cfg), IsNil)
+	r = r.Clone(core.SetApproximateKeys(0))
+	mustRegionHeartbeat(c, s.svr, r)
+	time.Sleep(10 * time.Millisecond)
+	r7 := &RegionsInfo{}
+	c.Assert(readJSONWithURL(url, r7), IsNil)
+	c.Assert(r7, DeepEquals, &RegionsInfo{Count: 1, Regions: []*RegionInfo{NewRegionInfo(r)}})
 }
 
 func (s *testRegionSuite) TestRegions(c *C) {
@@ -160,6 +181,143 @@ func (s *testRegionSuite) TestRegions(c *C) {
 	}
 }
 
+var _ = Suite(&testRegionEncodingSuite{})
+
+type testRegionEncodingSuite struct {
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testRegionEncodingSuite) SetUpSuite(c *C) {
+	s.svr, s.cleanup = mustNewServer(c)
+	mustWaitLeader(c, []*server.Server{s.svr})
+
+	addr := s.svr.GetAddr()
+	s.urlPrefix = fmt.Sprintf("%s%s/api/v1", addr, apiPrefix)
+
+	mustBootstrapCluster(c, s.svr)
+}
+
+func (s *testRegionEncodingSuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func (s *testRegionEncodingSuite) TestRegionsPageToken(c *C) {
+	for i, key := range []string{"p1", "p2", "p3"} {
+		r := newTestRegionInfo(uint64(20+i), 1, []byte(key), []byte(key+"x"))
+		mustRegionHeartbeat(c, s.svr, r)
+	}
+	var ids []uint64
+	token := hex.EncodeToString([]byte("p1"))
+	for token != "" {
+		url := fmt.Sprintf("%s/regions?limit=2&page_token=%s", s.urlPrefix, token)
+		regionsInfo := &RegionsInfo{}
+		c.Assert(readJSONWithURL(url, regionsInfo), IsNil)
+		c.Assert(regionsInfo.Count, LessEqual, 2)
+		for _, r := range regionsInfo.Regions {
+			if r.ID >= 20 && r.ID < 23 {
+				ids = append(ids, r.ID)
+			}
+		}
+		token = regionsInfo.NextPageToken
+	}
+	c.Assert(ids, DeepEquals, []uint64{20, 21, 22})
+
+	url := fmt.Sprintf("%s/regions?page_token=zz", s.urlPrefix)
+	resp, err := dialClient.Get(url)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusBadRequest)
+}
+
+func (s *testRegionEncodingSuite) TestRegionsProjection(c *C) {
+	for i, key := range []string{"r1", "r2", "r3"} {
+		r := newTestRegionInfo(uint64(40+i), 5, []byte(key), []byte(key+"x"))
+		mustRegionHeartbeat(c, s.svr, r)
+	}
+	url := fmt.Sprintf("%s/regions/store/5?limit=2&start_key=%s&fields=id,leader", s.urlPrefix, hex.EncodeToString([]byte("r2")))
+	var projected struct {
+		Count         int                      `json:"count"`
+		Regions       []map[string]interface{} `json:"regions"`
+		NextPageToken string                   `json:"next_page_token"`
+	}
+	c.Assert(readJSONWithURL(url, &projected), IsNil)
+	c.Assert(projected.Count, Equals, 2)
+	c.Assert(projected.NextPageToken, Equals, "")
+	for i, r := range projected.Regions {
+		c.Assert(r, HasLen, 2)
+		c.Assert(r["id"], Equals, float64(41+i))
+		c.Assert(r["leader"], NotNil)
+	}
+
+	url = fmt.Sprintf("%s/regions/store/5?limit=1", s.urlPrefix)
+	regionsInfo := &RegionsInfo{}
+	c.Assert(readJSONWithURL(url, regionsInfo), IsNil)
+	c.Assert(regionsInfo.Regions[0].ID, Equals, uint64(40))
+	c.Assert(regionsInfo.NextPageToken, Equals, hex.EncodeToString([]byte("r1x")))
+
+	resp, err := dialClient.Get(fmt.Sprintf("%s/regions?fields=id,unknown", s.urlPrefix))
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusBadRequest)
+}
+
+func (s *testRegionEncodingSuite) TestRegionsEncoding(c *C) {
+	r := newTestRegionInfo(30, 1, []byte("q1"), []byte("q2"))
+	mustRegionHeartbeat(c, s.svr, r)
+	url := fmt.Sprintf("%s/regions/key?key=q1&limit=1", s.urlPrefix)
+
+	body := s.getWithAccept(c, url, contentTypeProtobuf)
+	pbResp := &pdpb.ScanRegionsResponse{}
+	c.Assert(proto.Unmarshal(body, pbResp), IsNil)
+	c.Assert(pbResp.GetRegions(), HasLen, 1)
+	c.Assert(pbResp.GetRegions()[0].GetId(), Equals, uint64(30))
+	c.Assert(pbResp.GetLeaders()[0].GetStoreId(), Equals, uint64(1))
+
+	body = s.getWithAccept(c, url, contentTypeMsgpack)
+	regionsInfo := &RegionsInfo{}
+	c.Assert(codec.NewDecoderBytes(body, msgpackHandle).Decode(regionsInfo), IsNil)
+	c.Assert(regionsInfo.Count, Equals, 1)
+	c.Assert(regionsInfo.Regions[0].ID, Equals, uint64(30))
+
+	// Hot regions have no protobuf form.
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/hotspot/regions/write", s.urlPrefix), nil)
+	c.Assert(err, IsNil)
+	req.Header.Set("Accept", contentTypeProtobuf)
+	resp, err := dialClient.Do(req)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusNotAcceptable)
+
+	// The APIs responding JSON by themselves are negotiated as well.
+	body = s.getWithAccept(c, fmt.Sprintf("%s/config/replicate", s.urlPrefix), contentTypeMsgpack)
+	replication := make(map[string]interface{})
+	c.Assert(codec.NewDecoderBytes(body, msgpackHandle).Decode(&replication), IsNil)
+	c.Assert(replication["max-replicas"], Equals, int64(s.svr.GetReplicationConfig().MaxReplicas))
+	req, err = http.NewRequest("GET", fmt.Sprintf("%s/config/replicate", s.urlPrefix), nil)
+	c.Assert(err, IsNil)
+	req.Header.Set("Accept", contentTypeProtobuf)
+	resp, err = dialClient.Do(req)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusNotAcceptable)
+}
+
+func (s *testRegionEncodingSuite) getWithAccept(c *C, url, accept string) []byte {
+	req, err := http.NewRequest("GET", url, nil)
+	c.Assert(err, IsNil)
+	req.Header.Set("Accept", accept)
+	resp, err := dialClient.Do(req)
+	c.Assert(err, IsNil)
+	defer resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	c.Assert(resp.Header.Get("Content-Type"), Equals, accept)
+	body, err := ioutil.ReadAll(resp.Body)
+	c.Assert(err, IsNil)
+	return body
+}
+
 func (s *testRegionSuite) TestStoreRegions(c *C) {
 	r1 := newTestRegionInfo(2, 1, []byte("a"), []byte("b"))
 	r2 := newTestRegionInfo(3, 1, []byte("b"), []byte("c"))
@@ -330,4 +488,28 @@ func (s *testGetRegionSuite) TestScanRegionByKey(c *C) {
 	for i, v := range regionIds {
 		c.Assert(v, Equals, regions.Regions[i].ID)
 	}
+
+	// Filter regions by predicates.
+	r5 := newTestRegionInfo(6, 2, []byte("z"), []byte("zz"), core.WithDownPeers([]*pdpb.PeerStats{{Peer: &metapb.Peer{Id: 106, StoreId: 3}, DownSeconds: 600}}))
+	mustRegionHeartbeat(c, s.svr, r5)
+	for query, regionIds := range map[string][]uint64{
+		"leader_store=2":              {4, 5, 6},
+		"leader_store=2&limit=1":      {4},
+		"peer_store=1":                {3, 99},
+		"down_peer=true":              {6},
+		"down_peer=false":             {3, 4, 5, 6, 99},
+		"leader_store=1&peer_store=2": {},
+	} {
+		url = fmt.Sprintf("%s/regions/key?key=%s&%s", s.urlPrefix, "b", query)
+		regions = &RegionsInfo{}
+		c.Assert(readJSONWithURL(url, regions), IsNil)
+		c.Assert(regions.Count, Equals, len(regionIds), Commentf("query %s", query))
+		for i, v := range regionIds {
+			c.Assert(regions.Regions[i].ID, Equals, v, Commentf("query %s", query))
+		}
+	}
+	for _, query := range []string{"leader_store=x", "peer_store=-1", "down_peer=x"} {
+		url = fmt.Sprintf("%s/regions/key?key=%s&%s", s.urlPrefix, "b", query)
+		c.Assert(readJSONWithURL(url, regions), NotNil)
+	}
 }
@@ -35,6 +35,7 @@ func createRouter(prefix string, svr *server.Server) *mux.Router {
 	operatorHandler := newOperatorHandler(handler, rd)
 	router.HandleFunc("/api/v1/operators", operatorHandler.List).Methods("GET")
 	router.HandleFunc("/api/v1/operators", operatorHandler.Post).Methods("POST")
+	router.HandleFunc("/api/v1/operators/preview", operatorHandler.Preview).Methods("POST")
 	router.HandleFunc("/api/v1/operators/{region_id}", operatorHandler.Get).Methods("GET")
 	router.HandleFunc("/api/v1/operators/{region_id}", operatorHandler.Delete).Methods("DELETE")
 
@@ -42,14 +43,36 @@ func createRouter(prefix string, svr *server.Server) *mux.Router {
 	router.HandleFunc("/api/v1/schedulers", schedulerHandler.List).Methods("GET")
 	router.HandleFunc("/api/v1/schedulers", schedulerHandler.Post).Methods("POST")
 	router.HandleFunc("/api/v1/schedulers/{name}", schedulerHandler.Delete).Methods("DELETE")
+	router.HandleFunc("/api/v1/schedulers/{name}/denied-stores", schedulerHandler.GetDeniedStores).Methods("GET")
+	router.HandleFunc("/api/v1/schedulers/{name}/denied-stores", schedulerHandler.SetDeniedStores).Methods("POST")
+	router.HandleFunc("/api/v1/schedulers/{name}/config", schedulerHandler.GetConfig).Methods("GET")
+	router.HandleFunc("/api/v1/schedulers/{name}/hits", schedulerHandler.ResetHits).Methods("DELETE")
+
+	checkerHandler := newCheckerHandler(handler, rd)
+	router.HandleFunc("/api/v1/checkers", checkerHandler.List).Methods("GET")
+	router.HandleFunc("/api/v1/checkers/replica/location-violations", checkerHandler.GetLocationViolations).Methods("GET")
+	router.HandleFunc("/api/v1/checkers/{name}", checkerHandler.Get).Methods("GET")
+	router.HandleFunc("/api/v1/checkers/{name}", checkerHandler.Post).Methods("POST")
+
+	schedulingPauseHandler := newSchedulingPauseHandler(handler, rd)
+	router.HandleFunc("/api/v1/schedule/pause", schedulingPauseHandler.Get).Methods("GET")
+	router.HandleFunc("/api/v1/schedule/pause", schedulingPauseHandler.Pause).Methods("POST")
+	router.HandleFunc("/api/v1/schedule/pause", schedulingPauseHandler.Resume).Methods("DELETE")
+
+	scheduleSpeedHandler := newScheduleSpeedHandler(handler, rd)
+	router.HandleFunc("/api/v1/schedule/speed", scheduleSpeedHandler.Get).Methods("GET")
+	router.HandleFunc("/api/v1/schedule/speed", scheduleSpeedHandler.Set).Methods("POST")
+	router.HandleFunc("/api/v1/schedule/speed", scheduleSpeedHandler.Reset).Methods("DELETE")
 
 	clusterHandler := newClusterHandler(svr, rd)
 	router.Handle("/api/v1/cluster", clusterHandler).Methods("GET")
 	router.HandleFunc("/api/v1/cluster/status", clusterHandler.GetClusterStatus).Methods("GET")
+	router.HandleFunc("/api/v1/cluster/min-resolved-ts", clusterHandler.GetMinResolvedTS).Methods("GET")
 
 	confHandler := newConfHandler(svr, rd)
 	router.HandleFunc("/api/v1/config", confHandler.Get).Methods("GET")
 	router.HandleFunc("/api/v1/config", confHandler.Post).Methods("POST")
+	router.HandleFunc("/api/v1/config/effective", confHandler.GetEffective).Methods("GET")
 	router.HandleFunc("/api/v1/config/schedule", confHandler.SetSchedule).Methods("POST")
 	router.HandleFunc("/api/v1/config/schedule", confHandler.GetSchedule).Methods("GET")
 	router.HandleFunc("/api/v1/config/replicate", confHandler.SetReplication).Methods("POST")
@@ -62,6 +85,16 @@ func createRouter(prefix string, svr *server.Server) *mux.Router {
 	router.HandleFunc("/api/v1/config/cluster-version", confHandler.GetClusterVersion).Methods("GET")
 	router.HandleFunc("/api/v1/config/cluster-version", confHandler.SetClusterVersion).Methods("POST")
 
+	configBundleHandler := newConfigBundleHandler(handler, rd)
+	router.HandleFunc("/api/v1/config/bundle/export", configBundleHandler.Export).Methods("POST")
+	router.HandleFunc("/api/v1/config/bundle/import", configBundleHandler.Import).Methods("POST")
+
+	labelRuleHandler := newLabelRuleHandler(handler, rd)
+	router.HandleFunc("/api/v1/config/label-rules", labelRuleHandler.List).Methods("GET")
+	router.HandleFunc("/api/v1/config/label-rule", labelRuleHandler.Set).Methods("POST")
+	router.HandleFunc("/api/v1/config/label-rule/{id}", labelRuleHandler.Get).Methods("GET")
+	router.HandleFunc("/api/v1/config/label-rule/{id}", labelRuleHandler.Delete).Methods("DELETE")
+
 	storeHandler := newStoreHandler(handler, rd)
 	router.HandleFunc("/api/v1/store/{id}", storeHandler.Get).Methods("GET")
 	router.HandleFunc("/api/v1/store/{id}", storeHandler.Delete).Methods("DELETE")
@@ -69,11 +102,30 @@ func createRouter(prefix string, svr *server.Server) *mux.Router {
 	router.HandleFunc("/api/v1/store/{id}/label", storeHandler.SetLabels).Methods("POST")
 	router.HandleFunc("/api/v1/store/{id}/weight", storeHandler.SetWeight).Methods("POST")
 	router.HandleFunc("/api/v1/store/{id}/limit", storeHandler.SetLimit).Methods("POST")
+	router.HandleFunc("/api/v1/store/{id}/evacuate-to", storeHandler.GetEvacuateTargets).Methods("GET")
+	router.HandleFunc("/api/v1/store/{id}/evacuate-to", storeHandler.SetEvacuateTargets).Methods("POST")
+	router.HandleFunc("/api/v1/store/{id}/evacuate-to", storeHandler.DeleteEvacuateTargets).Methods("DELETE")
+	router.HandleFunc("/api/v1/store/{id}/decommission", storeHandler.GetDecommission).Methods("GET")
+	router.HandleFunc("/api/v1/store/{id}/startup", storeHandler.GetStartupState).Methods("GET")
+	router.HandleFunc("/api/v1/store/{id}/block", storeHandler.Block).Methods("POST")
+	router.HandleFunc("/api/v1/store/{id}/block", storeHandler.Unblock).Methods("DELETE")
+	router.HandleFunc("/api/v1/store/{id}/credential", storeHandler.RotateCredential).Methods("POST")
+	router.HandleFunc("/api/v1/store/{id}/credential", storeHandler.RevokeCredential).Methods("DELETE")
 	storesHandler := newStoresHandler(handler, rd)
 	router.Handle("/api/v1/stores", storesHandler).Methods("GET")
 	router.HandleFunc("/api/v1/stores/remove-tombstone", storesHandler.RemoveTombStone).Methods("DELETE")
 	router.HandleFunc("/api/v1/stores/limit", storesHandler.GetAllLimit).Methods("GET")
 	router.HandleFunc("/api/v1/stores/limit", storesHandler.SetAllLimit).Methods("POST")
+	router.HandleFunc("/api/v1/stores/label", storesHandler.SetLabels).Methods("POST")
+	router.HandleFunc("/api/v1/stores/evacuate-to", storesHandler.GetAllEvacuateTargets).Methods("GET")
+	router.HandleFunc("/api/v1/stores/blocked", storesHandler.GetBlockedStores).Methods("GET")
+	router.HandleFunc("/api/v1/stores/unknown", storesHandler.GetUnknownStores).Methods("GET")
+	router.HandleFunc("/api/v1/stores/unknown", storesHandler.ReleaseUnknownStores).Methods("DELETE")
+	router.HandleFunc("/api/v1/stores/credentials", storesHandler.GetCredentials).Methods("GET")
+	router.HandleFunc("/api/v1/stores/stats", storesHandler.GetStats).Methods("GET")
+	router.HandleFunc("/api/v1/stores/stats-stream", storesHandler.StreamStats).Methods("GET")
+	router.HandleFunc("/api/v1/stores/heartbeat-interval", storesHandler.GetHeartbeatIntervals).Methods("GET")
+	router.HandleFunc("/api/v1/stores/{id}/removable", storeHandler.GetRemovable).Methods("GET")
 
 	labelsHandler := newLabelsHandler(svr, rd)
 	router.HandleFunc("/api/v1/labels", labelsHandler.Get).Methods("GET")
@@ -83,11 +135,21 @@ func createRouter(prefix string, svr *server.Server) *mux.Router {
 	router.HandleFunc("/api/v1/hotspot/regions/write", hotStatusHandler.GetHotWriteRegions).Methods("GET")
 	router.HandleFunc("/api/v1/hotspot/regions/read", hotStatusHandler.GetHotReadRegions).Methods("GET")
 	router.HandleFunc("/api/v1/hotspot/stores", hotStatusHandler.GetHotStores).Methods("GET")
+	router.HandleFunc("/api/v1/hotspot/synthetic-load", hotStatusHandler.InjectSyntheticLoad).Methods("POST")
 
 	regionHandler := newRegionHandler(svr, rd)
 	router.HandleFunc("/api/v1/region/id/{id}", regionHandler.GetRegionByID).Methods("GET")
 	router.HandleFunc("/api/v1/region/key/{key}", regionHandler.GetRegionByKey).Methods("GET")
 
+	regionPinHandler := newRegionPinHandler(handler, rd)
+	router.HandleFunc("/api/v1/region/id/{id}/pin", regionPinHandler.Pin).Methods("POST")
+	router.HandleFunc("/api/v1/region/id/{id}/pin", regionPinHandler.Unpin).Methods("DELETE")
+	router.HandleFunc("/api/v1/regions/pinned", regionPinHandler.List).Methods("GET")
+
+	regionSnapshotHandler := newRegionSnapshotHandler(svr, rd)
+	router.HandleFunc("/api/v1/regions/snapshot", regionSnapshotHandler.Get).Methods("GET")
+	router.HandleFunc("/api/v1/regions/snapshot/sequence", regionSnapshotHandler.GetSequence).Methods("GET")
+
 	regionsHandler := newRegionsHandler(svr, rd)
 	router.HandleFunc("/api/v1/regions", regionsHandler.GetAll).Methods("GET")
 	router.HandleFunc("/api/v1/regions/key", regionsHandler.ScanRegions).Methods("GET")
@@ -103,6 +165,8 @@ func createRouter(prefix string, svr *server.Server) *mux.Router {
 	router.HandleFunc("/api/v1/regions/check/down-peer", regionsHandler.GetDownPeerRegions).Methods("GET")
 	router.HandleFunc("/api/v1/regions/check/offline-peer", regionsHandler.GetOfflinePeer).Methods("GET")
 	router.HandleFunc("/api/v1/regions/check/empty-region", regionsHandler.GetEmptyRegion).Methods("GET")
+	router.HandleFunc("/api/v1/regions/check/long-empty-region", regionsHandler.GetLongEmptyRegions).Methods("GET")
+	router.HandleFunc("/api/v1/regions/check/lagging-learner", regionsHandler.GetLaggingLearnerRegions).Methods("GET")
 	router.HandleFunc("/api/v1/regions/sibling/{id}", regionsHandler.GetRegionSiblings).Methods("GET")
 	router.HandleFunc("/api/v1/regions/check/incorrect-ns", regionsHandler.GetIncorrectNamespaceRegions).Methods("GET")
 
@@ -111,6 +175,7 @@ func createRouter(prefix string, svr *server.Server) *mux.Router {
 
 	memberHandler := newMemberHandler(svr, rd)
 	router.HandleFunc("/api/v1/members", memberHandler.ListMembers).Methods("GET")
+	router.HandleFunc("/api/v1/members", memberHandler.AddMember).Methods("POST")
 	router.HandleFunc("/api/v1/members/name/{name}", memberHandler.DeleteByName).Methods("DELETE")
 	router.HandleFunc("/api/v1/members/id/{id}", memberHandler.DeleteByID).Methods("DELETE")
 	router.HandleFunc("/api/v1/members/name/{name}", memberHandler.SetMemberPropertyByName).Methods("POST")
@@ -126,12 +191,61 @@ func createRouter(prefix string, svr *server.Server) *mux.Router {
 
 	statsHandler := newStatsHandler(svr, rd)
 	router.HandleFunc("/api/v1/stats/region", statsHandler.Region).Methods("GET")
+	router.HandleFunc("/api/v1/stats/namespace/{name}", statsHandler.NamespaceRegion).Methods("GET")
+	router.HandleFunc("/api/v1/stats/region-fragmentation", statsHandler.RegionFragmentation).Methods("GET")
+	router.HandleFunc("/api/v1/stats/defrag-plan", statsHandler.DefragPlan).Methods("GET")
+	router.HandleFunc("/api/v1/stats/tier", statsHandler.Tier).Methods("GET")
+	router.HandleFunc("/api/v1/stats/capacity", statsHandler.Capacity).Methods("GET")
+	router.HandleFunc("/api/v1/stats/balance-index", statsHandler.BalanceIndex).Methods("GET")
+	router.HandleFunc("/api/v1/stats/label-value", statsHandler.LabelValue).Methods("GET")
 
 	trendHandler := newTrendHandler(svr, rd)
 	router.HandleFunc("/api/v1/trend", trendHandler.Handle).Methods("GET")
 
 	adminHandler := newAdminHandler(svr, rd)
 	router.HandleFunc("/api/v1/admin/cache/region/{id}", adminHandler.HandleDropCacheRegion).Methods("DELETE")
+	router.HandleFunc("/api/v1/admin/accept-stale-from-store/{id}", adminHandler.HandleGetStaleAcceptance).Methods("GET")
+	router.HandleFunc("/api/v1/admin/accept-stale-from-store/{id}", adminHandler.HandleAcceptStaleFromStore).Methods("POST")
+	router.HandleFunc("/api/v1/admin/accept-stale-from-store/{id}", adminHandler.HandleStopAcceptStaleFromStore).Methods("DELETE")
+	router.HandleFunc("/api/v1/admin/id-allocator", adminHandler.HandleIDAllocator).Methods("GET")
+	router.HandleFunc("/api/v1/admin/storage/usage", adminHandler.HandleStorageUsage).Methods("GET")
+	router.HandleFunc("/api/v1/admin/storage/migrate-regions", adminHandler.HandleMigrateRegions).Methods("POST")
+
+	etcdHandler := newEtcdHandler(svr, rd)
+	router.HandleFunc("/api/v1/etcd/status", etcdHandler.GetStatus).Methods("GET")
+	router.HandleFunc("/api/v1/admin/etcd/compact", etcdHandler.Compact).Methods("POST")
+
+	deleteRangeHandler := newDeleteRangeHandler(handler, rd)
+	router.HandleFunc("/api/v1/delete-ranges", deleteRangeHandler.List).Methods("GET")
+	router.HandleFunc("/api/v1/delete-ranges", deleteRangeHandler.Post).Methods("POST")
+	router.HandleFunc("/api/v1/delete-ranges/{id}", deleteRangeHandler.Get).Methods("GET")
+	router.HandleFunc("/api/v1/delete-ranges/{id}", deleteRangeHandler.Delete).Methods("DELETE")
+	router.HandleFunc("/api/v1/delete-ranges/{id}/pieces/{piece_id}/finish", deleteRangeHandler.FinishPiece).Methods("POST")
+	router.HandleFunc("/api/v1/store/{id}/delete-ranges", deleteRangeHandler.GetStorePieces).Methods("GET")
+
+	mergePauseHandler := newMergePauseHandler(handler, rd)
+	router.HandleFunc("/api/v1/merge-pauses", mergePauseHandler.List).Methods("GET")
+	router.HandleFunc("/api/v1/merge-pauses", mergePauseHandler.Pause).Methods("POST")
+	router.HandleFunc("/api/v1/merge-pauses/{id}", mergePauseHandler.Resume).Methods("DELETE")
+	router.HandleFunc("/api/v1/merge-pauses/{id}/renew", mergePauseHandler.Renew).Methods("POST")
+
+	placementIntentHandler := newPlacementIntentHandler(handler, rd)
+	router.HandleFunc("/api/v1/placement-intents", placementIntentHandler.List).Methods("GET")
+	router.HandleFunc("/api/v1/placement-intents", placementIntentHandler.Post).Methods("POST")
+	router.HandleFunc("/api/v1/placement-intents/{id}", placementIntentHandler.Get).Methods("GET")
+	router.HandleFunc("/api/v1/placement-intents/{id}", placementIntentHandler.Delete).Methods("DELETE")
+
+	nsMigrationHandler := newNamespaceMigrationHandler(handler, rd)
+	router.HandleFunc("/api/v1/admin/namespace-migrations", nsMigrationHandler.List).Methods("GET")
+	router.HandleFunc("/api/v1/admin/namespace-migrations", nsMigrationHandler.Post).Methods("POST")
+	router.HandleFunc("/api/v1/admin/namespace-migrations/{id}", nsMigrationHandler.Get).Methods("GET")
+	router.HandleFunc("/api/v1/admin/namespace-migrations/{id}", nsMigrationHandler.Delete).Methods("DELETE")
+
+	classifierTransitionHandler := newClassifierTransitionHandler(svr, rd)
+	router.HandleFunc("/api/v1/admin/classifier-transition", classifierTransitionHandler.Get).Methods("GET")
+	router.HandleFunc("/api/v1/admin/classifier-transition", classifierTransitionHandler.Post).Methods("POST")
+	router.HandleFunc("/api/v1/admin/classifier-transition", classifierTransitionHandler.Delete).Methods("DELETE")
+	router.HandleFunc("/api/v1/admin/classifier-transition/cut-over", classifierTransitionHandler.CutOver).Methods("POST")
 
 	logHanler := newlogHandler(svr, rd)
 	router.HandleFunc("/api/v1/admin/log", logHanler.Handle).Methods("POST")
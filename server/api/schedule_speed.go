@@ -0,0 +1,79 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/pingcap/pd/server"
+	"github.com/unrolled/render"
+)
+
+type scheduleSpeedHandler struct {
+	*server.Handler
+	rd *render.Render
+}
+
+func newScheduleSpeedHandler(handler *server.Handler, rd *render.Render) *scheduleSpeedHandler {
+	return &scheduleSpeedHandler{
+		Handler: handler,
+		rd:      rd,
+	}
+}
+
+// ScheduleSpeedInput is the scale of the schedule limits overriding the one
+// adjusted by the governor.
+type ScheduleSpeedInput struct {
+	Scale float64 `json:"scale"`
+}
+
+func (h *scheduleSpeedHandler) Get(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetScheduleSpeed())
+}
+
+func (h *scheduleSpeedHandler) Set(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	var input ScheduleSpeedInput
+	if err := readJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	override, err := cluster.SetScheduleSpeed(input.Scale)
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, override)
+}
+
+func (h *scheduleSpeedHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	if err := cluster.ResetScheduleSpeed(); err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, nil)
+}
@@ -36,7 +36,7 @@ func newSchedulerHandler(handler *server.Handler, r *render.Render) *schedulerHa
 func (h *schedulerHandler) List(w http.ResponseWriter, r *http.Request) {
 	schedulers, err := h.GetSchedulers()
 	if err != nil {
-		h.r.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.r, w, err)
 		return
 	}
 	h.r.JSON(w, http.StatusOK, schedulers)
@@ -57,22 +57,35 @@ func (h *schedulerHandler) Post(w http.ResponseWriter, r *http.Request) {
 	switch name {
 	case "balance-leader-scheduler":
 		if err := h.AddBalanceLeaderScheduler(); err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			errorResp(h.r, w, err)
 			return
 		}
 	case "balance-hot-region-scheduler":
 		if err := h.AddBalanceHotRegionScheduler(); err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			errorResp(h.r, w, err)
 			return
 		}
 	case "balance-region-scheduler":
-		if err := h.AddBalanceRegionScheduler(); err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+		var args []string
+		if ranges, ok := input["ranges"].([]interface{}); ok {
+			for _, r := range ranges {
+				keys, ok := r.(map[string]interface{})
+				if !ok {
+					h.r.JSON(w, http.StatusBadRequest, "invalid key range")
+					return
+				}
+				startKey, _ := keys["start_key"].(string)
+				endKey, _ := keys["end_key"].(string)
+				args = append(args, startKey, endKey)
+			}
+		}
+		if err := h.AddBalanceRegionScheduler(args...); err != nil {
+			errorResp(h.r, w, err)
 			return
 		}
 	case "label-scheduler":
 		if err := h.AddLabelScheduler(); err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			errorResp(h.r, w, err)
 			return
 		}
 	case "scatter-range":
@@ -90,7 +103,7 @@ func (h *schedulerHandler) Post(w http.ResponseWriter, r *http.Request) {
 			args = append(args, name)
 		}
 		if err := h.AddScatterRangeScheduler(args...); err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			errorResp(h.r, w, err)
 			return
 		}
 
@@ -106,7 +119,7 @@ func (h *schedulerHandler) Post(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if err := h.AddAdjacentRegionScheduler(args...); err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			errorResp(h.r, w, err)
 			return
 		}
 	case "grant-leader-scheduler":
@@ -116,7 +129,7 @@ func (h *schedulerHandler) Post(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if err := h.AddGrantLeaderScheduler(uint64(storeID)); err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			errorResp(h.r, w, err)
 			return
 		}
 	case "evict-leader-scheduler":
@@ -126,22 +139,22 @@ func (h *schedulerHandler) Post(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if err := h.AddEvictLeaderScheduler(uint64(storeID)); err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			errorResp(h.r, w, err)
 			return
 		}
 	case "shuffle-leader-scheduler":
 		if err := h.AddShuffleLeaderScheduler(); err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			errorResp(h.r, w, err)
 			return
 		}
 	case "shuffle-region-scheduler":
 		if err := h.AddShuffleRegionScheduler(); err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			errorResp(h.r, w, err)
 			return
 		}
 	case "random-merge-scheduler":
 		if err := h.AddRandomMergeScheduler(); err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			errorResp(h.r, w, err)
 			return
 		}
 	case "shuffle-hot-region-scheduler":
@@ -151,7 +164,7 @@ func (h *schedulerHandler) Post(w http.ResponseWriter, r *http.Request) {
 			limit = uint64(l)
 		}
 		if err := h.AddShuffleHotRegionScheduler(limit); err != nil {
-			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			errorResp(h.r, w, err)
 			return
 		}
 	default:
@@ -162,11 +175,61 @@ func (h *schedulerHandler) Post(w http.ResponseWriter, r *http.Request) {
 	h.r.JSON(w, http.StatusOK, nil)
 }
 
+func (h *schedulerHandler) GetDeniedStores(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	storeIDs, err := h.GetSchedulerDeniedStores(name)
+	if err != nil {
+		errorResp(h.r, w, err)
+		return
+	}
+	if storeIDs == nil {
+		storeIDs = []uint64{}
+	}
+	h.r.JSON(w, http.StatusOK, storeIDs)
+}
+
+func (h *schedulerHandler) SetDeniedStores(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var storeIDs []uint64
+	if err := readJSONRespondError(h.r, w, r.Body, &storeIDs); err != nil {
+		return
+	}
+	if err := h.SetSchedulerDeniedStores(name, storeIDs); err != nil {
+		errorResp(h.r, w, err)
+		return
+	}
+
+	h.r.JSON(w, http.StatusOK, nil)
+}
+
+func (h *schedulerHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	cfg, err := h.GetSchedulerConfig(name)
+	if err != nil {
+		errorResp(h.r, w, err)
+		return
+	}
+	h.r.JSON(w, http.StatusOK, cfg)
+}
+
+func (h *schedulerHandler) ResetHits(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if err := h.ResetSchedulerHits(name); err != nil {
+		errorResp(h.r, w, err)
+		return
+	}
+	h.r.JSON(w, http.StatusOK, nil)
+}
+
 func (h *schedulerHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	name := mux.Vars(r)["name"]
 
 	if err := h.RemoveScheduler(name); err != nil {
-		h.r.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.r, w, err)
 		return
 	}
 
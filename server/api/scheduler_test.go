@@ -16,11 +16,12 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
 
 	. "github.com/pingcap/check"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/pd/server"
-	_ "github.com/pingcap/pd/server/schedulers"
+	"github.com/pingcap/pd/server/schedulers"
 )
 
 var _ = Suite(&testScheduleSuite{})
@@ -100,3 +101,88 @@ func (s *testScheduleSuite) testAddAndRemoveScheduler(name, createdName string,
 	err = doDelete(deleteURL)
 	c.Assert(err, IsNil)
 }
+
+func (s *testScheduleSuite) TestDeniedStores(c *C) {
+	body, err := json.Marshal(map[string]interface{}{"name": "shuffle-region-scheduler"})
+	c.Assert(err, IsNil)
+	c.Assert(postJSON(s.urlPrefix, body), IsNil)
+	defer doDelete(fmt.Sprintf("%s/%s", s.urlPrefix, "shuffle-region-scheduler"))
+
+	url := fmt.Sprintf("%s/%s/denied-stores", s.urlPrefix, "shuffle-region-scheduler")
+	var storeIDs []uint64
+	c.Assert(readJSONWithURL(url, &storeIDs), IsNil)
+	c.Assert(storeIDs, HasLen, 0)
+
+	c.Assert(postJSON(url, []byte("[1]")), IsNil)
+	c.Assert(readJSONWithURL(url, &storeIDs), IsNil)
+	c.Assert(storeIDs, DeepEquals, []uint64{1})
+	for _, cfg := range s.svr.GetScheduleConfig().Schedulers {
+		if cfg.Type == "shuffle-region" {
+			c.Assert(cfg.DeniedStores, DeepEquals, []uint64{1})
+		}
+	}
+
+	// The stores should exist.
+	c.Assert(postJSON(url, []byte("[1, 100]")), NotNil)
+	// The scheduler should exist.
+	c.Assert(postJSON(fmt.Sprintf("%s/%s/denied-stores", s.urlPrefix, "unknown-scheduler"), []byte("[1]")), NotNil)
+
+	c.Assert(postJSON(url, []byte("[]")), IsNil)
+	c.Assert(readJSONWithURL(url, &storeIDs), IsNil)
+	c.Assert(storeIDs, HasLen, 0)
+}
+
+func (s *testScheduleSuite) TestBalanceRegionRanges(c *C) {
+	body := []byte(`{"name":"balance-region-scheduler","ranges":[{"start_key":"61","end_key":"63"},{"start_key":"78","end_key":""}]}`)
+	c.Assert(postJSON(s.urlPrefix, body), IsNil)
+	defer doDelete(fmt.Sprintf("%s/%s", s.urlPrefix, "balance-region-scheduler"))
+	for _, cfg := range s.svr.GetScheduleConfig().Schedulers {
+		if cfg.Type == "balance-region" {
+			c.Assert(cfg.Args, DeepEquals, []string{"61", "63", "78", ""})
+		}
+	}
+
+	var cfg schedulers.BalanceRegionConfig
+	c.Assert(readJSONWithURL(fmt.Sprintf("%s/%s/config", s.urlPrefix, "balance-region-scheduler"), &cfg), IsNil)
+	c.Assert(cfg.Ranges, HasLen, 2)
+	c.Assert(cfg.Ranges[0].StartKey, Equals, "61")
+	c.Assert(cfg.Ranges[0].EndKey, Equals, "63")
+	c.Assert(cfg.Ranges[1].StartKey, Equals, "78")
+	c.Assert(cfg.Ranges[1].EndKey, Equals, "")
+	c.Assert(cfg.Hits, NotNil)
+	c.Assert(doDelete(fmt.Sprintf("%s/%s/hits", s.urlPrefix, "balance-region-scheduler")), IsNil)
+
+	// The scheduler should exist, and have a config.
+	c.Assert(readJSONWithURL(fmt.Sprintf("%s/%s/config", s.urlPrefix, "unknown-scheduler"), &cfg), NotNil)
+	c.Assert(postJSON(s.urlPrefix, []byte(`{"name":"shuffle-region-scheduler"}`)), IsNil)
+	defer doDelete(fmt.Sprintf("%s/%s", s.urlPrefix, "shuffle-region-scheduler"))
+	c.Assert(readJSONWithURL(fmt.Sprintf("%s/%s/config", s.urlPrefix, "shuffle-region-scheduler"), &cfg), NotNil)
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/%s/hits", s.urlPrefix, "shuffle-region-scheduler"), nil)
+	c.Assert(err, IsNil)
+	resp, err := dialClient.Do(req)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusBadRequest)
+}
+
+func (s *testScheduleSuite) TestSchedulingPause(c *C) {
+	url := fmt.Sprintf("%s%s/api/v1/schedule/pause", s.svr.GetAddr(), apiPrefix)
+	c.Assert(postJSON(url, []byte(`{"owner":"admin","ttl":"1h"}`)), NotNil)
+	c.Assert(postJSON(url, []byte(`{"owner":"admin","reason":"upgrade","ttl":"1h"}`)), IsNil)
+	var status server.SchedulingPauseStatus
+	c.Assert(readJSONWithURL(url, &status), IsNil)
+	c.Assert(status.Pause, NotNil)
+	c.Assert(status.Pause.Owner, Equals, "admin")
+	c.Assert(status.Pause.Reason, Equals, "upgrade")
+	c.Assert(status.Pause.ExpireTime, NotNil)
+	c.Assert(status.Events, HasLen, 1)
+
+	c.Assert(doDelete(url+"?owner=admin"), IsNil)
+	status = server.SchedulingPauseStatus{}
+	c.Assert(readJSONWithURL(url, &status), IsNil)
+	c.Assert(status.Pause, IsNil)
+	c.Assert(status.Events, HasLen, 2)
+	c.Assert(status.Events[1].Event, Equals, server.SchedulingPauseEventResume)
+	code, _ := requestStatusBody(c, newHTTPClient(), http.MethodDelete, url)
+	c.Assert(code, Equals, http.StatusNotFound)
+}
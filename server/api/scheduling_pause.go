@@ -0,0 +1,84 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/pingcap/pd/pkg/typeutil"
+	"github.com/pingcap/pd/server"
+	"github.com/unrolled/render"
+)
+
+type schedulingPauseHandler struct {
+	*server.Handler
+	rd *render.Render
+}
+
+func newSchedulingPauseHandler(handler *server.Handler, rd *render.Render) *schedulingPauseHandler {
+	return &schedulingPauseHandler{
+		Handler: handler,
+		rd:      rd,
+	}
+}
+
+// SchedulingPauseInput is who pauses scheduling, why and the optional
+// expiry.
+type SchedulingPauseInput struct {
+	Owner  string `json:"owner"`
+	Reason string `json:"reason"`
+	// TTL is how long scheduling is paused, such as "2h". Scheduling is paused
+	// until being resumed if it is empty.
+	TTL typeutil.Duration `json:"ttl"`
+}
+
+func (h *schedulingPauseHandler) Get(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetSchedulingPause())
+}
+
+func (h *schedulingPauseHandler) Pause(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	var input SchedulingPauseInput
+	if err := readJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	pause, err := cluster.PauseScheduling(input.Owner, input.Reason, input.TTL.Duration)
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, pause)
+}
+
+func (h *schedulingPauseHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	if err := cluster.ResumeScheduling(r.URL.Query().Get("owner")); err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, nil)
+}
@@ -18,6 +18,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/pingcap/pd/server"
+	"github.com/unrolled/render"
 	"github.com/urfave/negroni"
 )
 
@@ -31,11 +32,24 @@ func NewHandler(svr *server.Server) http.Handler {
 	engine.Use(recovery)
 
 	router := mux.NewRouter()
+	// The readiness is served by every member without being redirected to
+	// the leader, so that load balancers can probe each member.
+	rd := render.New(render.Options{
+		IndentJSON: true,
+	})
+	router.Handle(apiPrefix+"/api/v1/ready", newReadyHandler(svr, rd)).Methods("GET")
+	// The region cache is hashed by the follower serving the request.
+	router.Handle(apiPrefix+"/api/v1/region-cache/hash", newRegionCacheHashHandler(svr, rd)).Methods("POST")
+	// The UI is served by every member, and its data is redirected.
+	ui := newUIHandler(svr, rd)
+	router.Handle(apiPrefix+"/ui", ui).Methods("GET")
+	router.Handle(apiPrefix+"/ui/", ui).Methods("GET")
 	router.PathPrefix(apiPrefix).Handler(negroni.New(
 		newRedirector(svr),
 		negroni.Wrap(createRouter(apiPrefix, svr)),
 	))
 
+	engine.Use(newEncodingNegotiator(rd))
 	engine.UseHandler(router)
 
 	return engine
@@ -14,12 +14,20 @@
 package api
 
 import (
+	"encoding/hex"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/pingcap/pd/server"
+	"github.com/pingcap/pd/server/namespace"
 	"github.com/unrolled/render"
 )
 
+const defaultDefragStepLimit = 256
+
 type statsHandler struct {
 	svr *server.Server
 	rd  *render.Render
@@ -35,10 +43,126 @@ func newStatsHandler(svr *server.Server, rd *render.Render) *statsHandler {
 func (h *statsHandler) Region(w http.ResponseWriter, r *http.Request) {
 	cluster := h.svr.GetRaftCluster()
 	if cluster == nil {
-		h.rd.JSON(w, http.StatusInternalServerError, server.ErrNotBootstrapped.Error())
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
 		return
 	}
 	startKey, endKey := r.URL.Query().Get("start_key"), r.URL.Query().Get("end_key")
 	stats := cluster.GetRegionStats([]byte(startKey), []byte(endKey))
 	h.rd.JSON(w, http.StatusOK, stats)
 }
+
+func (h *statsHandler) NamespaceRegion(w http.ResponseWriter, r *http.Request) {
+	cluster := h.svr.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	name := mux.Vars(r)["name"]
+	// Regions not classified into any namespace belong to the default one.
+	if name != namespace.DefaultNamespace && !h.svr.IsNamespaceExist(name) {
+		h.rd.JSON(w, http.StatusNotFound, fmt.Sprintf("invalid namespace Name %s, not found", name))
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetNamespaceRegionStats(name))
+}
+
+func (h *statsHandler) RegionFragmentation(w http.ResponseWriter, r *http.Request) {
+	cluster := h.svr.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	limit, err := parseLimit(r, defaultRegionLimit)
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetRegionFragmentation(limit))
+}
+
+func (h *statsHandler) DefragPlan(w http.ResponseWriter, r *http.Request) {
+	cluster := h.svr.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	startKey, err := hex.DecodeString(r.URL.Query().Get("start_key"))
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	endKey, err := hex.DecodeString(r.URL.Query().Get("end_key"))
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err := parseLimit(r, defaultDefragStepLimit)
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetDefragPlan(startKey, endKey, limit))
+}
+
+func (h *statsHandler) Tier(w http.ResponseWriter, r *http.Request) {
+	cluster := h.svr.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetTierStats())
+}
+
+func (h *statsHandler) Capacity(w http.ResponseWriter, r *http.Request) {
+	cluster := h.svr.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetCapacityStats())
+}
+
+func (h *statsHandler) BalanceIndex(w http.ResponseWriter, r *http.Request) {
+	cluster := h.svr.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	var from time.Time
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		fromInt, err := strconv.ParseInt(fromStr, 10, 64)
+		if err != nil {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		from = time.Unix(fromInt, 0)
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetBalanceIndexes(from))
+}
+
+func (h *statsHandler) LabelValue(w http.ResponseWriter, r *http.Request) {
+	cluster := h.svr.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	stats := cluster.GetLabelValueStats()
+	if label := r.URL.Query().Get("label"); label != "" {
+		filtered := stats[:0]
+		for _, s := range stats {
+			if s.Label == label {
+				filtered = append(filtered, s)
+			}
+		}
+		stats = filtered
+	}
+	h.rd.JSON(w, http.StatusOK, stats)
+}
+
+func parseLimit(r *http.Request, defaultLimit int) (int, error) {
+	limitStr := r.URL.Query().Get("limit")
+	if limitStr == "" {
+		return defaultLimit, nil
+	}
+	return strconv.Atoi(limitStr)
+}
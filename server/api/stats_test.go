@@ -22,6 +22,7 @@ import (
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/pd/pkg/apiutil"
 	"github.com/pingcap/pd/server"
+	"github.com/pingcap/pd/server/config"
 	"github.com/pingcap/pd/server/core"
 	"github.com/pingcap/pd/server/statistics"
 )
@@ -175,4 +176,74 @@ func (s *testStatsSuite) TestRegionStats(c *C) {
 	err = apiutil.ReadJSON(res.Body, stats)
 	c.Assert(err, IsNil)
 	c.Assert(stats, DeepEquals, stats23)
+
+	res, err = http.Get(s.urlPrefix + "/stats/namespace/global")
+	c.Assert(err, IsNil)
+	nsStats := &statistics.NamespaceRegionStats{}
+	err = apiutil.ReadJSON(res.Body, nsStats)
+	c.Assert(err, IsNil)
+	c.Assert(nsStats, DeepEquals, &statistics.NamespaceRegionStats{
+		Namespace:     "global",
+		Count:         4,
+		StorageSize:   351,
+		StorageKeys:   221,
+		AbnormalCount: map[string]int{"miss-peer": 2, "empty-region": 1},
+	})
+
+	res, err = http.Get(s.urlPrefix + "/stats/namespace/unknown")
+	c.Assert(err, IsNil)
+	c.Assert(res.StatusCode, Equals, http.StatusNotFound)
+}
+
+var _ = Suite(&testRegionFragmentationSuite{})
+
+type testRegionFragmentationSuite struct {
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testRegionFragmentationSuite) SetUpSuite(c *C) {
+	s.svr, s.cleanup = mustNewServer(c, func(cfg *config.Config) { cfg.Replication.MaxReplicas = 1 })
+	mustWaitLeader(c, []*server.Server{s.svr})
+
+	addr := s.svr.GetAddr()
+	s.urlPrefix = fmt.Sprintf("%s%s/api/v1", addr, apiPrefix)
+
+	mustBootstrapCluster(c, s.svr)
+}
+
+func (s *testRegionFragmentationSuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func (s *testRegionFragmentationSuite) TestFragmentation(c *C) {
+	mustRegionHeartbeat(c, s.svr, newTestRegionInfo(100, 1, []byte("a"), []byte("b")))
+	mustRegionHeartbeat(c, s.svr, newTestRegionInfo(101, 1, []byte("b"), []byte("c")))
+
+	var stats []*server.RegionFragmentation
+	c.Assert(readJSONWithURL(s.urlPrefix+"/stats/region-fragmentation", &stats), IsNil)
+	c.Assert(stats, HasLen, 1)
+	c.Assert(stats[0].MergeableCount, Equals, 1)
+
+	plan := &server.DefragPlan{}
+	c.Assert(readJSONWithURL(s.urlPrefix+"/stats/defrag-plan?start_key=61&end_key=63", plan), IsNil)
+	c.Assert(plan.Steps, DeepEquals, []*server.DefragStep{{Round: 1, SourceID: 100, TargetID: 101}})
+	c.Assert(readJSONWithURL(s.urlPrefix+"/stats/defrag-plan?start_key=zz", plan), NotNil)
+	c.Assert(readJSONWithURL(s.urlPrefix+"/stats/defrag-plan?limit=x", plan), NotNil)
+}
+
+func (s *testStatsSuite) TestBalanceIndex(c *C) {
+	var indexes []*server.BalanceIndex
+	c.Assert(readJSONWithURL(s.urlPrefix+"/stats/balance-index", &indexes), IsNil)
+	c.Assert(readJSONWithURL(s.urlPrefix+"/stats/balance-index?from=0", &indexes), IsNil)
+	c.Assert(readJSONWithURL(s.urlPrefix+"/stats/balance-index?from=x", &indexes), NotNil)
+}
+
+func (s *testStatsSuite) TestCapacity(c *C) {
+	var stats []*server.CapacityStats
+	c.Assert(readJSONWithURL(s.urlPrefix+"/stats/capacity", &stats), IsNil)
+	c.Assert(stats, HasLen, 2)
+	c.Assert(stats[0].StoreType, Equals, core.StoreTypePerformance)
+	c.Assert(stats[1].StoreType, Equals, core.StoreTypeStorage)
 }
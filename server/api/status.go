@@ -26,6 +26,7 @@ type statusHandler struct {
 
 type status struct {
 	BuildTS string `json:"build_ts"`
+	Version string `json:"version"`
 	GitHash string `json:"git_hash"`
 }
 
@@ -38,6 +39,7 @@ func newStatusHandler(rd *render.Render) *statusHandler {
 func (h *statusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	version := status{
 		BuildTS: server.PDBuildTS,
+		Version: server.PDReleaseVersion,
 		GitHash: server.PDGitHash,
 	}
 
@@ -38,6 +38,7 @@ func checkStatusResponse(c *C, body []byte, cfgs []*config.Config) {
 	c.Assert(json.Unmarshal(body, &got), IsNil)
 
 	c.Assert(got.BuildTS, Equals, server.PDBuildTS)
+	c.Assert(got.Version, Equals, server.PDReleaseVersion)
 	c.Assert(got.GitHash, Equals, server.PDGitHash)
 }
 
@@ -14,8 +14,10 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"time"
 
@@ -28,6 +30,7 @@ import (
 	"github.com/pingcap/pd/server/config"
 	"github.com/pingcap/pd/server/core"
 	"github.com/pingcap/pd/server/schedule"
+	"github.com/pingcap/pd/server/statistics"
 	"github.com/pkg/errors"
 	"github.com/unrolled/render"
 )
@@ -40,23 +43,30 @@ type MetaStore struct {
 
 // StoreStatus contains status about a store.
 type StoreStatus struct {
-	Capacity           typeutil.ByteSize  `json:"capacity,omitempty"`
-	Available          typeutil.ByteSize  `json:"available,omitempty"`
-	LeaderCount        int                `json:"leader_count,omitempty"`
-	LeaderWeight       float64            `json:"leader_weight,omitempty"`
-	LeaderScore        float64            `json:"leader_score,omitempty"`
-	LeaderSize         int64              `json:"leader_size,omitempty"`
-	RegionCount        int                `json:"region_count,omitempty"`
-	RegionWeight       float64            `json:"region_weight,omitempty"`
-	RegionScore        float64            `json:"region_score,omitempty"`
-	RegionSize         int64              `json:"region_size,omitempty"`
-	SendingSnapCount   uint32             `json:"sending_snap_count,omitempty"`
-	ReceivingSnapCount uint32             `json:"receiving_snap_count,omitempty"`
-	ApplyingSnapCount  uint32             `json:"applying_snap_count,omitempty"`
-	IsBusy             bool               `json:"is_busy,omitempty"`
-	StartTS            *time.Time         `json:"start_ts,omitempty"`
-	LastHeartbeatTS    *time.Time         `json:"last_heartbeat_ts,omitempty"`
-	Uptime             *typeutil.Duration `json:"uptime,omitempty"`
+	Capacity           typeutil.ByteSize    `json:"capacity,omitempty"`
+	Available          typeutil.ByteSize    `json:"available,omitempty"`
+	LeaderCount        int                  `json:"leader_count,omitempty"`
+	LeaderWeight       float64              `json:"leader_weight,omitempty"`
+	LeaderScore        float64              `json:"leader_score,omitempty"`
+	LeaderSize         int64                `json:"leader_size,omitempty"`
+	RegionCount        int                  `json:"region_count,omitempty"`
+	RegionWeight       float64              `json:"region_weight,omitempty"`
+	RegionScore        float64              `json:"region_score,omitempty"`
+	RegionSize         int64                `json:"region_size,omitempty"`
+	SendingSnapCount   uint32               `json:"sending_snap_count,omitempty"`
+	ReceivingSnapCount uint32               `json:"receiving_snap_count,omitempty"`
+	ApplyingSnapCount  uint32               `json:"applying_snap_count,omitempty"`
+	IsBusy             bool                 `json:"is_busy,omitempty"`
+	MemoryTotal        typeutil.ByteSize    `json:"memory_total,omitempty"`
+	MemoryUsed         typeutil.ByteSize    `json:"memory_used,omitempty"`
+	MemoryPressured    bool                 `json:"memory_pressured,omitempty"`
+	MemoryPressureTS   *time.Time           `json:"memory_pressure_ts,omitempty"`
+	Capabilities       []string             `json:"capabilities,omitempty"`
+	Benchmark          *core.StoreBenchmark `json:"benchmark,omitempty"`
+	BenchmarkWeight    float64              `json:"benchmark_weight,omitempty"`
+	StartTS            *time.Time           `json:"start_ts,omitempty"`
+	LastHeartbeatTS    *time.Time           `json:"last_heartbeat_ts,omitempty"`
+	Uptime             *typeutil.Duration   `json:"uptime,omitempty"`
 }
 
 // StoreInfo contains information about a store.
@@ -68,6 +78,10 @@ type StoreInfo struct {
 const (
 	disconnectedName = "Disconnected"
 	downStateName    = "Down"
+
+	// maxRebalanceStoreLimit is the max number of operators to add at once
+	// to rebalance a store after its weight is changed.
+	maxRebalanceStoreLimit = 1024
 )
 
 func newStoreInfo(opt *config.ScheduleConfig, store *core.StoreInfo) *StoreInfo {
@@ -91,6 +105,12 @@ func newStoreInfo(opt *config.ScheduleConfig, store *core.StoreInfo) *StoreInfo
 			ReceivingSnapCount: store.GetReceivingSnapCount(),
 			ApplyingSnapCount:  store.GetApplyingSnapCount(),
 			IsBusy:             store.GetIsBusy(),
+			MemoryTotal:        typeutil.ByteSize(store.GetMemoryTotal()),
+			MemoryUsed:         typeutil.ByteSize(store.GetMemoryUsed()),
+			MemoryPressured:    store.IsMemoryPressured(opt.MemoryPressureRatio),
+			Capabilities:       store.GetCapabilities(),
+			Benchmark:          store.GetBenchmark(),
+			BenchmarkWeight:    store.GetBenchmarkWeight(),
 		},
 	}
 
@@ -101,6 +121,9 @@ func newStoreInfo(opt *config.ScheduleConfig, store *core.StoreInfo) *StoreInfo
 	if lastHeartbeat := store.GetLastHeartbeatTS(); !lastHeartbeat.IsZero() {
 		s.Status.LastHeartbeatTS = &lastHeartbeat
 	}
+	if since := store.GetMemoryPressureSince(); !since.IsZero() {
+		s.Status.MemoryPressureTS = &since
+	}
 	if upTime := store.GetUptime(); upTime > 0 {
 		duration := typeutil.NewDuration(upTime)
 		s.Status.Uptime = &duration
@@ -137,7 +160,7 @@ func newStoreHandler(handler *server.Handler, rd *render.Render) *storeHandler {
 func (h *storeHandler) Get(w http.ResponseWriter, r *http.Request) {
 	cluster := h.GetRaftCluster()
 	if cluster == nil {
-		h.rd.JSON(w, http.StatusInternalServerError, server.ErrNotBootstrapped.Error())
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
 		return
 	}
 
@@ -150,7 +173,7 @@ func (h *storeHandler) Get(w http.ResponseWriter, r *http.Request) {
 
 	store := cluster.GetStore(storeID)
 	if store == nil {
-		h.rd.JSON(w, http.StatusInternalServerError, server.ErrStoreNotFound(storeID))
+		errorResp(h.rd, w, server.ErrStoreNotFound(storeID))
 		return
 	}
 
@@ -161,7 +184,7 @@ func (h *storeHandler) Get(w http.ResponseWriter, r *http.Request) {
 func (h *storeHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	cluster := h.GetRaftCluster()
 	if cluster == nil {
-		errorResp(h.rd, w, errcode.NewInternalErr(server.ErrNotBootstrapped))
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
 		return
 	}
 
@@ -177,7 +200,16 @@ func (h *storeHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	if force {
 		err = cluster.BuryStore(storeID, force)
 	} else {
-		err = cluster.RemoveStore(storeID)
+		// The store is only evacuated in the window if it is given, such as
+		// "00:00-06:00".
+		var schedule *core.DecommissionSchedule
+		if window := r.URL.Query().Get("window"); window != "" {
+			if schedule, err = core.ParseDecommissionWindow(window); err != nil {
+				errorResp(h.rd, w, errcode.NewInvalidInputErr(err))
+				return
+			}
+		}
+		err = cluster.RemoveStore(storeID, schedule)
 	}
 
 	if err != nil {
@@ -191,7 +223,7 @@ func (h *storeHandler) Delete(w http.ResponseWriter, r *http.Request) {
 func (h *storeHandler) SetState(w http.ResponseWriter, r *http.Request) {
 	cluster := h.GetRaftCluster()
 	if cluster == nil {
-		h.rd.JSON(w, http.StatusInternalServerError, server.ErrNotBootstrapped.Error())
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
 		return
 	}
 
@@ -211,7 +243,7 @@ func (h *storeHandler) SetState(w http.ResponseWriter, r *http.Request) {
 
 	err := cluster.SetStoreState(storeID, metapb.StoreState(state))
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 
@@ -221,7 +253,7 @@ func (h *storeHandler) SetState(w http.ResponseWriter, r *http.Request) {
 func (h *storeHandler) SetLabels(w http.ResponseWriter, r *http.Request) {
 	cluster := h.GetRaftCluster()
 	if cluster == nil {
-		h.rd.JSON(w, http.StatusInternalServerError, server.ErrNotBootstrapped.Error())
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
 		return
 	}
 
@@ -251,7 +283,7 @@ func (h *storeHandler) SetLabels(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := cluster.UpdateStoreLabels(storeID, labels); err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 
@@ -261,7 +293,7 @@ func (h *storeHandler) SetLabels(w http.ResponseWriter, r *http.Request) {
 func (h *storeHandler) SetWeight(w http.ResponseWriter, r *http.Request) {
 	cluster := h.GetRaftCluster()
 	if cluster == nil {
-		h.rd.JSON(w, http.StatusInternalServerError, server.ErrNotBootstrapped.Error())
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
 		return
 	}
 
@@ -298,11 +330,305 @@ func (h *storeHandler) SetWeight(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var rebalance int
+	if rebalanceVal, ok := input["rebalance"]; ok {
+		limit, ok := rebalanceVal.(float64)
+		if !ok || limit < 0 || limit > maxRebalanceStoreLimit {
+			h.rd.JSON(w, http.StatusBadRequest, "badformat rebalance limit")
+			return
+		}
+		rebalance = int(limit)
+	}
+
 	if err := cluster.SetStoreWeight(storeID, leader, region); err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
+		return
+	}
+	if rebalance > 0 {
+		count, err := cluster.RebalanceStore(storeID, rebalance)
+		if err != nil {
+			errorResp(h.rd, w, err)
+			return
+		}
+		h.rd.JSON(w, http.StatusOK, map[string]int{"operators": count})
+		return
+	}
+
+	h.rd.JSON(w, http.StatusOK, nil)
+}
+
+// EvacuateTargets is the preferred targets to move the replicas of a store to
+// when it is evacuated.
+type EvacuateTargets struct {
+	Targets []uint64 `json:"targets"`
+}
+
+func (h *storeHandler) GetEvacuateTargets(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+
+	vars := mux.Vars(r)
+	storeID, errParse := apiutil.ParseUint64VarsField(vars, "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, &EvacuateTargets{Targets: cluster.GetEvacuateTargets(storeID)})
+}
+
+// GetStartupState returns the state that PD expects the store to have, which
+// the store fetches on boot.
+func (h *storeHandler) GetStartupState(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+
+	vars := mux.Vars(r)
+	storeID, errParse := apiutil.ParseUint64VarsField(vars, "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	state, err := cluster.GetStoreStartupState(storeID)
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, state)
+}
+
+// GetRemovable checks if the store can be removed safely.
+func (h *storeHandler) GetRemovable(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+
+	vars := mux.Vars(r)
+	storeID, errParse := apiutil.ParseUint64VarsField(vars, "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	check, err := cluster.CheckStoreRemoval(storeID)
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, check)
+}
+
+// StoreDecommission is the schedule and the progress to evacuate a removed
+// store.
+type StoreDecommission struct {
+	*core.DecommissionSchedule
+	RegionCount int `json:"region_count"`
+	// InWindow is whether the store is being evacuated now.
+	InWindow bool    `json:"in_window"`
+	Progress float64 `json:"progress"`
+}
+
+func (h *storeHandler) GetDecommission(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+
+	vars := mux.Vars(r)
+	storeID, errParse := apiutil.ParseUint64VarsField(vars, "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	store := cluster.GetStore(storeID)
+	if store == nil {
+		errorResp(h.rd, w, core.NewStoreNotFoundErr(storeID))
+		return
+	}
+	schedule := cluster.GetDecommissionSchedule(storeID)
+	if schedule == nil {
+		h.rd.JSON(w, http.StatusNotFound, "the store is not removed with a schedule")
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, &StoreDecommission{
+		DecommissionSchedule: schedule,
+		RegionCount:          store.GetRegionCount(),
+		InWindow:             cluster.IsEvacuationAllowed(storeID),
+		Progress:             schedule.Progress(store.GetRegionCount()),
+	})
+}
+
+func (h *storeHandler) SetEvacuateTargets(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+
+	vars := mux.Vars(r)
+	storeID, errParse := apiutil.ParseUint64VarsField(vars, "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+
+	var input EvacuateTargets
+	if err := readJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	if err := cluster.SetEvacuateTargets(storeID, input.Targets); err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, nil)
+}
+
+func (h *storeHandler) DeleteEvacuateTargets(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+
+	vars := mux.Vars(r)
+	storeID, errParse := apiutil.ParseUint64VarsField(vars, "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	if err := cluster.DeleteEvacuateTargets(storeID); err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, nil)
+}
+
+// BlockStoreInput is the reason and the optional expiry to block a store from
+// scheduling.
+type BlockStoreInput struct {
+	Reason string `json:"reason"`
+	// TTL is how long the store is blocked, such as "1h". The store is
+	// blocked until being unblocked if it is empty.
+	TTL typeutil.Duration `json:"ttl"`
+}
+
+func (h *storeHandler) Block(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+
+	vars := mux.Vars(r)
+	storeID, errParse := apiutil.ParseUint64VarsField(vars, "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+
+	var input BlockStoreInput
+	if err := readJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	blocked, err := cluster.AddBlockedStore(storeID, input.Reason, input.TTL.Duration)
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, blocked)
+}
+
+func (h *storeHandler) Unblock(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+
+	vars := mux.Vars(r)
+	storeID, errParse := apiutil.ParseUint64VarsField(vars, "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	if err := cluster.RemoveBlockedStore(storeID); err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, nil)
+}
+
+// StoreCredentialInput is the credential to authenticate a store.
+type StoreCredentialInput struct {
+	// SAN is a DNS name, an IP address or a URI in the certificate of the
+	// store.
+	SAN string `json:"san"`
+	// Token is whether to generate a new token for the store.
+	Token bool `json:"token"`
+}
+
+// StoreCredentialOutput is the rotated credential of a store. The token is
+// only returned once and PD keeps its hash only.
+type StoreCredentialOutput struct {
+	StoreID uint64 `json:"store_id"`
+	SAN     string `json:"san,omitempty"`
+	Token   string `json:"token,omitempty"`
+}
+
+func (h *storeHandler) RotateCredential(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+
+	vars := mux.Vars(r)
+	storeID, errParse := apiutil.ParseUint64VarsField(vars, "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+
+	var input StoreCredentialInput
+	if err := readJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	token, err := cluster.RotateStoreCredential(storeID, input.SAN, input.Token)
+	if err != nil {
+		errorResp(h.rd, w, err)
 		return
 	}
+	h.rd.JSON(w, http.StatusOK, &StoreCredentialOutput{
+		StoreID: storeID,
+		SAN:     input.SAN,
+		Token:   token,
+	})
+}
 
+func (h *storeHandler) RevokeCredential(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+
+	vars := mux.Vars(r)
+	storeID, errParse := apiutil.ParseUint64VarsField(vars, "id")
+	if errParse != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errParse))
+		return
+	}
+	if err := cluster.RevokeStoreCredential(storeID); err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
 	h.rd.JSON(w, http.StatusOK, nil)
 }
 
@@ -331,7 +657,7 @@ func (h *storeHandler) SetLimit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.SetStoreLimit(storeID, rate/schedule.StoreBalanceBaseTime); err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 
@@ -353,11 +679,16 @@ func newStoresHandler(handler *server.Handler, rd *render.Render) *storesHandler
 func (h *storesHandler) RemoveTombStone(w http.ResponseWriter, r *http.Request) {
 	cluster := h.GetRaftCluster()
 	if cluster == nil {
-		errorResp(h.rd, w, errcode.NewInternalErr(server.ErrNotBootstrapped))
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
 		return
 	}
 
-	err := cluster.RemoveTombStoneRecords()
+	var err error
+	if address := r.URL.Query().Get("address"); address != "" {
+		err = cluster.RemoveTombStoneRecordsByAddress(address)
+	} else {
+		err = cluster.RemoveTombStoneRecords()
+	}
 	if err != nil {
 		errorResp(h.rd, w, err)
 		return
@@ -384,17 +715,62 @@ func (h *storesHandler) SetAllLimit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.SetAllStoresLimit(rate / schedule.StoreBalanceBaseTime); err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 
 	h.rd.JSON(w, http.StatusOK, nil)
 }
 
+// StoresLabelsInput is the labels to merge into the labels of the stores
+// selected by the selector.
+type StoresLabelsInput struct {
+	Selector server.StoreSelector `json:"selector"`
+	Labels   map[string]string    `json:"labels"`
+	// DryRun returns the updates without applying them.
+	DryRun bool `json:"dry_run"`
+}
+
+func (h *storesHandler) SetLabels(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+
+	var input StoresLabelsInput
+	if err := readJSONRespondError(h.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	if t := input.Selector.StoreType; t != "" && t != core.StoreTypePerformance && t != core.StoreTypeStorage {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(errors.Errorf("unknown store type %s", t)))
+		return
+	}
+
+	labels := make([]*metapb.StoreLabel, 0, len(input.Labels))
+	for k, v := range input.Labels {
+		labels = append(labels, &metapb.StoreLabel{
+			Key:   k,
+			Value: v,
+		})
+	}
+	if err := config.ValidateLabels(labels); err != nil {
+		errorResp(h.rd, w, errcode.NewInvalidInputErr(err))
+		return
+	}
+
+	updates, err := cluster.UpdateStoresLabels(&input.Selector, labels, input.DryRun)
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, updates)
+}
+
 func (h *storesHandler) GetAllLimit(w http.ResponseWriter, r *http.Request) {
 	limit, err := h.GetAllStoresLimit()
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 	ret := make(map[uint64]interface{})
@@ -407,10 +783,108 @@ func (h *storesHandler) GetAllLimit(w http.ResponseWriter, r *http.Request) {
 	h.rd.JSON(w, http.StatusOK, ret)
 }
 
+func (h *storesHandler) GetAllEvacuateTargets(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	ret := make(map[uint64]*EvacuateTargets)
+	for storeID, targets := range cluster.GetAllEvacuateTargets() {
+		ret[storeID] = &EvacuateTargets{Targets: targets}
+	}
+	h.rd.JSON(w, http.StatusOK, ret)
+}
+
+func (h *storesHandler) GetBlockedStores(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetBlockedStores())
+}
+
+func (h *storesHandler) GetUnknownStores(w http.ResponseWriter, r *http.Request) {
+	h.rd.JSON(w, http.StatusOK, h.Handler.GetUnknownStores())
+}
+
+func (h *storesHandler) ReleaseUnknownStores(w http.ResponseWriter, r *http.Request) {
+	n := h.Handler.ReleaseUnknownStores(r.URL.Query().Get("address"))
+	h.rd.JSON(w, http.StatusOK, fmt.Sprintf("%d unknown stores are released.", n))
+}
+
+// StoreCredentialInfo is the credential of a store without the token hash.
+type StoreCredentialInfo struct {
+	StoreID    uint64    `json:"store_id"`
+	SAN        string    `json:"san,omitempty"`
+	HasToken   bool      `json:"has_token"`
+	UpdateTime time.Time `json:"update_time"`
+}
+
+func (h *storesHandler) GetCredentials(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	credentials := cluster.GetStoreCredentials()
+	infos := make([]*StoreCredentialInfo, 0, len(credentials))
+	for _, sc := range credentials {
+		infos = append(infos, &StoreCredentialInfo{
+			StoreID:    sc.StoreID,
+			SAN:        sc.SAN,
+			HasToken:   sc.TokenHash != "",
+			UpdateTime: sc.UpdateTime,
+		})
+	}
+	h.rd.JSON(w, http.StatusOK, infos)
+}
+
+// StoreWindowStats is the flow rates of a store in the recent heartbeats and
+// in the long windows.
+type StoreWindowStats struct {
+	StoreID uint64                        `json:"store_id"`
+	Current statistics.StoreRates         `json:"current"`
+	Windows []statistics.StoreWindowRates `json:"windows"`
+}
+
+func (h *storesHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+	storesStats := cluster.GetStoresStats()
+	stores := cluster.GetStores()
+	stats := make([]*StoreWindowStats, 0, len(stores))
+	for _, store := range stores {
+		if store.IsTombstone() {
+			continue
+		}
+		stats = append(stats, &StoreWindowStats{
+			StoreID: store.GetID(),
+			Current: storesStats.GetStoreRates(store.GetID()),
+			Windows: storesStats.GetStoreWindowRates(store.GetID()),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].StoreID < stats[j].StoreID })
+	h.rd.JSON(w, http.StatusOK, stats)
+}
+
+func (h *storesHandler) GetHeartbeatIntervals(w http.ResponseWriter, r *http.Request) {
+	intervals, err := h.Handler.GetHeartbeatIntervals()
+	if err != nil {
+		errorResp(h.rd, w, err)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, intervals)
+}
+
 func (h *storesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	cluster := h.GetRaftCluster()
 	if cluster == nil {
-		h.rd.JSON(w, http.StatusInternalServerError, server.ErrNotBootstrapped.Error())
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
 		return
 	}
 
@@ -421,7 +895,7 @@ func (h *storesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	urlFilter, err := newStoreStateFilter(r.URL)
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 
@@ -430,7 +904,7 @@ func (h *storesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		storeID := s.GetId()
 		store := cluster.GetStore(storeID)
 		if store == nil {
-			h.rd.JSON(w, http.StatusInternalServerError, server.ErrStoreNotFound(storeID))
+			errorResp(h.rd, w, server.ErrStoreNotFound(storeID))
 			return
 		}
 
@@ -0,0 +1,119 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/server"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultStatsStreamInterval = time.Second
+	minStatsStreamInterval     = 100 * time.Millisecond
+	statsStreamWriteTimeout    = 10 * time.Second
+)
+
+// StreamStats pushes the score and flow of stores to the websocket client
+// after store heartbeats. The updates are sent as JSON arrays at most once
+// per interval, and only the latest update of each store is kept in between.
+func (h *storesHandler) StreamStats(w http.ResponseWriter, r *http.Request) {
+	interval := defaultStatsStreamInterval
+	if v := r.URL.Query().Get("interval"); v != "" {
+		var err error
+		if interval, err = time.ParseDuration(v); err != nil {
+			errorResp(h.rd, w, errcode.NewInvalidInputErr(err))
+			return
+		}
+		if interval < minStatsStreamInterval {
+			interval = minStatsStreamInterval
+		}
+	}
+	cluster := h.GetRaftCluster()
+	if cluster == nil {
+		errorResp(h.rd, w, server.ErrNotBootstrapped)
+		return
+	}
+
+	upgrader := websocket.Upgrader{CheckOrigin: h.checkStatsStreamOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has replied to the client.
+		return
+	}
+	defer conn.Close()
+	sub := cluster.SubscribeStoreStats(interval)
+	defer sub.Close()
+
+	// Messages from the client are discarded, the read loop only detects
+	// that the connection is closed.
+	clientClosed := make(chan struct{})
+	go func() {
+		defer close(clientClosed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		updates, ok := sub.Receive(clientClosed)
+		if !ok {
+			break
+		}
+		if err := conn.SetWriteDeadline(time.Now().Add(statsStreamWriteTimeout)); err != nil {
+			return
+		}
+		if err := conn.WriteJSON(updates); err != nil {
+			log.Info("store stats stream is closed", zap.String("remote", r.RemoteAddr), zap.Error(err))
+			return
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "")
+	_ = conn.WriteControl(websocket.CloseMessage, msg, deadline)
+}
+
+// checkStatsStreamOrigin allows the websocket requests from the pages served
+// by PD, either at the requested host or at the advertised client urls, so
+// that other sites cannot read the stats with the cookies of the browsers.
+// The requests without origins are not sent by browsers and are allowed.
+func (h *storesHandler) checkStatsStreamOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, addr := range h.GetAdvertiseClientUrls() {
+		advertised, err := url.Parse(addr)
+		if err == nil && strings.EqualFold(advertised.Scheme, u.Scheme) && strings.EqualFold(advertised.Host, u.Host) {
+			return true
+		}
+	}
+	return false
+}
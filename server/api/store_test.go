@@ -24,11 +24,14 @@ import (
 	"time"
 
 	"github.com/docker/go-units"
+	"github.com/gorilla/websocket"
 	. "github.com/pingcap/check"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/pingcap/pd/server"
 	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/statistics"
+	"google.golang.org/grpc/metadata"
 )
 
 var _ = Suite(&testStoreSuite{})
@@ -157,6 +160,12 @@ func (s *testStoreSuite) TestStoreGet(c *C) {
 	c.Assert(int64(info.Status.Capacity), Equals, capacity)
 	c.Assert(int64(info.Status.Available), Equals, available)
 	checkStoresInfo(c, []*StoreInfo{info}, s.stores[:1])
+
+	status, body := requestStatusBody(c, newHTTPClient(), http.MethodGet, fmt.Sprintf("%s/store/100", s.urlPrefix))
+	c.Assert(status, Equals, http.StatusNotFound)
+	var resp map[string]interface{}
+	c.Assert(json.Unmarshal(body, &resp), IsNil)
+	c.Assert(resp["code"], Equals, "missing.store")
 }
 
 func (s *testStoreSuite) TestStoreLabel(c *C) {
@@ -216,6 +225,40 @@ func (s *testStoreSuite) TestStoreLabel(c *C) {
 	s.stores[0].Labels = info.Store.Labels
 }
 
+func (s *testStoreSuite) TestStoresLabel(c *C) {
+	url := fmt.Sprintf("%s/stores/label", s.urlPrefix)
+	input := map[string]interface{}{
+		"selector": map[string]string{"address_prefix": "tikv6"},
+		"labels":   map[string]string{"rack": "r1"},
+		"dry_run":  true,
+	}
+	b, err := json.Marshal(input)
+	c.Assert(err, IsNil)
+	var updates []*server.StoreLabelsUpdate
+	c.Assert(postJSON(url, b, func(res []byte) bool {
+		return json.Unmarshal(res, &updates) == nil
+	}), IsNil)
+	c.Assert(updates, HasLen, 1)
+	c.Assert(updates[0].StoreID, Equals, uint64(6))
+	c.Assert(updates[0].NewLabels, HasLen, 1)
+	var info StoreInfo
+	c.Assert(readJSONWithURL(fmt.Sprintf("%s/store/6", s.urlPrefix), &info), IsNil)
+	c.Assert(info.Store.Labels, HasLen, 0)
+
+	input["dry_run"] = false
+	b, err = json.Marshal(input)
+	c.Assert(err, IsNil)
+	c.Assert(postJSON(url, b), IsNil)
+	c.Assert(readJSONWithURL(fmt.Sprintf("%s/store/6", s.urlPrefix), &info), IsNil)
+	c.Assert(info.Store.Labels, HasLen, 1)
+	c.Assert(info.Store.Labels[0].Value, Equals, "r1")
+	s.stores[2].Labels = info.Store.Labels
+
+	// Invalid inputs.
+	c.Assert(postJSON(url, []byte(`{"labels":{"rack":"r1"}}`)), NotNil)
+	c.Assert(postJSON(url, []byte(`{"selector":{"store_type":"ssd"},"labels":{"rack":"r1"}}`)), NotNil)
+}
+
 func (s *testStoreSuite) TestStoreDelete(c *C) {
 	table := []struct {
 		id     int
@@ -238,6 +281,212 @@ func (s *testStoreSuite) TestStoreDelete(c *C) {
 	}
 }
 
+func (s *testStoreSuite) TestStoreDecommission(c *C) {
+	client := newHTTPClient()
+	url := fmt.Sprintf("%s/store/6", s.urlPrefix)
+	status, _ := requestStatusBody(c, client, http.MethodGet, url+"/decommission")
+	c.Assert(status, Equals, http.StatusNotFound)
+	status, _ = requestStatusBody(c, client, http.MethodDelete, url+"?window=06:00")
+	c.Assert(status, Equals, http.StatusBadRequest)
+
+	status, _ = requestStatusBody(c, client, http.MethodDelete, url+"?window=00:00-06:00")
+	c.Assert(status, Equals, http.StatusOK)
+	decommission := &StoreDecommission{}
+	c.Assert(readJSONWithURL(url+"/decommission", decommission), IsNil)
+	c.Assert(decommission.Start, Equals, "00:00")
+	c.Assert(decommission.End, Equals, "06:00")
+	c.Assert(decommission.Progress, Equals, 1.0)
+
+	// The schedule is deleted if the store is up again.
+	c.Assert(postJSON(url+"/state?state=Up", nil), IsNil)
+	status, _ = requestStatusBody(c, client, http.MethodGet, url+"/decommission")
+	c.Assert(status, Equals, http.StatusNotFound)
+	c.Assert(postJSON(url+"/state?state=Offline", nil), IsNil)
+}
+
+func (s *testStoreSuite) TestEvacuateTargets(c *C) {
+	url := fmt.Sprintf("%s/store/6/evacuate-to", s.urlPrefix)
+	c.Assert(postJSON(url, []byte(`{"targets":[1,4]}`)), IsNil)
+	targets := &EvacuateTargets{}
+	c.Assert(readJSONWithURL(url, targets), IsNil)
+	c.Assert(targets.Targets, DeepEquals, []uint64{1, 4})
+	all := make(map[uint64]*EvacuateTargets)
+	c.Assert(readJSONWithURL(fmt.Sprintf("%s/stores/evacuate-to", s.urlPrefix), &all), IsNil)
+	c.Assert(all[6].Targets, DeepEquals, []uint64{1, 4})
+
+	// Invalid targets.
+	c.Assert(postJSON(url, []byte(`{"targets":[]}`)), NotNil)
+	c.Assert(postJSON(url, []byte(`{"targets":[6]}`)), NotNil)
+	c.Assert(postJSON(url, []byte(`{"targets":[7]}`)), NotNil)
+	c.Assert(postJSON(url, []byte(`{"targets":[100]}`)), NotNil)
+	c.Assert(postJSON(fmt.Sprintf("%s/store/7/evacuate-to", s.urlPrefix), []byte(`{"targets":[1]}`)), NotNil)
+
+	c.Assert(doDelete(url), IsNil)
+	targets = &EvacuateTargets{}
+	c.Assert(readJSONWithURL(url, targets), IsNil)
+	c.Assert(targets.Targets, HasLen, 0)
+}
+
+func (s *testStoreSuite) TestBlockStore(c *C) {
+	url := fmt.Sprintf("%s/store/6/block", s.urlPrefix)
+	c.Assert(postJSON(url, []byte(`{"reason":"disk replacement","ttl":"1h"}`)), IsNil)
+	var blocked []*server.BlockedStore
+	c.Assert(readJSONWithURL(fmt.Sprintf("%s/stores/blocked", s.urlPrefix), &blocked), IsNil)
+	c.Assert(blocked, HasLen, 1)
+	c.Assert(blocked[0].StoreID, Equals, uint64(6))
+	c.Assert(blocked[0].Reason, Equals, "disk replacement")
+	c.Assert(blocked[0].ExpireTime, NotNil)
+
+	// Invalid inputs.
+	c.Assert(postJSON(url, []byte(`{"ttl":"abc"}`)), NotNil)
+	c.Assert(postJSON(fmt.Sprintf("%s/store/7/block", s.urlPrefix), []byte(`{}`)), NotNil)
+	c.Assert(postJSON(fmt.Sprintf("%s/store/100/block", s.urlPrefix), []byte(`{}`)), NotNil)
+
+	c.Assert(doDelete(url), IsNil)
+	blocked = nil
+	c.Assert(readJSONWithURL(fmt.Sprintf("%s/stores/blocked", s.urlPrefix), &blocked), IsNil)
+	c.Assert(blocked, HasLen, 0)
+	status, _ := requestStatusBody(c, newHTTPClient(), http.MethodDelete, url)
+	c.Assert(status, Equals, http.StatusNotFound)
+}
+
+func (s *testStoreSuite) TestStoreStartupState(c *C) {
+	c.Assert(postJSON(fmt.Sprintf("%s/store/1/block", s.urlPrefix), []byte(`{"reason":"disk replacement"}`)), IsNil)
+	defer doDelete(fmt.Sprintf("%s/store/1/block", s.urlPrefix))
+	c.Assert(postJSON(fmt.Sprintf("%s/store/1/limit", s.urlPrefix), []byte(`{"rate":3}`)), IsNil)
+
+	url := fmt.Sprintf("%s/store/1/startup", s.urlPrefix)
+	state := &server.StoreStartupState{}
+	c.Assert(readJSONWithURL(url, state), IsNil)
+	c.Assert(state.StoreID, Equals, uint64(1))
+	c.Assert(state.State, Equals, metapb.StoreState_Up.String())
+	c.Assert(state.Blocked, IsTrue)
+	c.Assert(state.BlockedStore, NotNil)
+	c.Assert(state.BlockedStore.Reason, Equals, "disk replacement")
+	c.Assert(state.AcceptingStale, IsFalse)
+	c.Assert(state.StoreLimit, Equals, 3.0)
+	c.Assert(state.ClusterVersion, Equals, s.svr.GetClusterVersion().String())
+
+	status, _ := requestStatusBody(c, newHTTPClient(), http.MethodGet, fmt.Sprintf("%s/store/100/startup", s.urlPrefix))
+	c.Assert(status, Equals, http.StatusNotFound)
+	status, _ = requestStatusBody(c, newHTTPClient(), http.MethodGet, fmt.Sprintf("%s/store/7/startup", s.urlPrefix))
+	c.Assert(status, Equals, http.StatusGone)
+}
+
+func (s *testStoreSuite) TestStoreRemovable(c *C) {
+	check := &server.StoreRemovalCheck{}
+	c.Assert(readJSONWithURL(fmt.Sprintf("%s/stores/1/removable", s.urlPrefix), check), IsNil)
+	c.Assert(check.StoreID, Equals, uint64(1))
+	// The bootstrapped region has its only replica on the store.
+	c.Assert(check.Removable, IsFalse)
+	c.Assert(check.Violations, Not(HasLen), 0)
+
+	status, _ := requestStatusBody(c, newHTTPClient(), http.MethodGet, fmt.Sprintf("%s/stores/100/removable", s.urlPrefix))
+	c.Assert(status, Equals, http.StatusNotFound)
+	status, _ = requestStatusBody(c, newHTTPClient(), http.MethodGet, fmt.Sprintf("%s/stores/7/removable", s.urlPrefix))
+	c.Assert(status, Equals, http.StatusGone)
+}
+
+func (s *testStoreSuite) TestStoreCredential(c *C) {
+	url := fmt.Sprintf("%s/store/4/credential", s.urlPrefix)
+	var output StoreCredentialOutput
+	c.Assert(postJSON(url, []byte(`{"san":"tikv4","token":true}`), func(res []byte) bool {
+		return json.Unmarshal(res, &output) == nil
+	}), IsNil)
+	c.Assert(output.StoreID, Equals, uint64(4))
+	c.Assert(output.Token, Not(Equals), "")
+	var infos []*StoreCredentialInfo
+	c.Assert(readJSONWithURL(fmt.Sprintf("%s/stores/credentials", s.urlPrefix), &infos), IsNil)
+	c.Assert(infos, HasLen, 1)
+	c.Assert(infos[0].StoreID, Equals, uint64(4))
+	c.Assert(infos[0].SAN, Equals, "tikv4")
+	c.Assert(infos[0].HasToken, IsTrue)
+
+	heartbeat := func(ctx context.Context) error {
+		_, err := s.svr.StoreHeartbeat(ctx, &pdpb.StoreHeartbeatRequest{
+			Header: &pdpb.RequestHeader{ClusterId: s.svr.ClusterID()},
+			Stats:  &pdpb.StoreStats{StoreId: 4, Capacity: 1024, Available: 512},
+		})
+		return err
+	}
+	c.Assert(heartbeat(context.Background()), NotNil)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("pd-store-token", output.Token))
+	c.Assert(heartbeat(ctx), IsNil)
+
+	// Invalid inputs.
+	c.Assert(postJSON(url, []byte(`{}`)), NotNil)
+	c.Assert(postJSON(fmt.Sprintf("%s/store/7/credential", s.urlPrefix), []byte(`{"token":true}`)), NotNil)
+
+	c.Assert(doDelete(url), IsNil)
+	c.Assert(heartbeat(context.Background()), IsNil)
+	infos = nil
+	c.Assert(readJSONWithURL(fmt.Sprintf("%s/stores/credentials", s.urlPrefix), &infos), IsNil)
+	c.Assert(infos, HasLen, 0)
+	status, _ := requestStatusBody(c, newHTTPClient(), http.MethodDelete, url)
+	c.Assert(status, Equals, http.StatusNotFound)
+}
+
+func (s *testStoreSuite) TestStatsStream(c *C) {
+	resp, err := http.Get(fmt.Sprintf("%s/stores/stats-stream?interval=abc", s.urlPrefix))
+	c.Assert(err, IsNil)
+	c.Assert(resp.Body.Close(), IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusBadRequest)
+	url := "ws" + strings.TrimPrefix(fmt.Sprintf("%s/stores/stats-stream?interval=100ms", s.urlPrefix), "http")
+	// The pages of other sites are rejected.
+	_, resp, err = websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"http://example.com"}})
+	c.Assert(err, NotNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusForbidden)
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {s.svr.GetAddr()}})
+	c.Assert(err, IsNil)
+	c.Assert(conn.Close(), IsNil)
+	conn, _, err = websocket.DefaultDialer.Dial(url, nil)
+	c.Assert(err, IsNil)
+	defer conn.Close()
+
+	s.svr.StoreHeartbeat(
+		context.Background(), &pdpb.StoreHeartbeatRequest{
+			Header: &pdpb.RequestHeader{ClusterId: s.svr.ClusterID()},
+			Stats: &pdpb.StoreStats{
+				StoreId:   4,
+				Capacity:  1024,
+				Available: 512,
+			},
+		},
+	)
+	c.Assert(conn.SetReadDeadline(time.Now().Add(5*time.Second)), IsNil)
+	var updates []*statistics.StoreStatsUpdate
+	c.Assert(conn.ReadJSON(&updates), IsNil)
+	c.Assert(updates, HasLen, 1)
+	c.Assert(updates[0].StoreID, Equals, uint64(4))
+	c.Assert(updates[0].LeaderScore, Equals, 0.0)
+}
+
+func (s *testStoreSuite) TestStoresWindowStats(c *C) {
+	now := uint64(time.Now().Unix())
+	_, err := s.svr.StoreHeartbeat(context.Background(), &pdpb.StoreHeartbeatRequest{
+		Header: &pdpb.RequestHeader{ClusterId: s.svr.ClusterID()},
+		Stats: &pdpb.StoreStats{
+			StoreId:      1,
+			Capacity:     1024,
+			Available:    512,
+			BytesWritten: 1000,
+			Interval:     &pdpb.TimeInterval{StartTimestamp: now - 10, EndTimestamp: now},
+		},
+	})
+	c.Assert(err, IsNil)
+
+	var stats []*StoreWindowStats
+	c.Assert(readJSONWithURL(fmt.Sprintf("%s/stores/stats", s.urlPrefix), &stats), IsNil)
+	// The tombstone store is not listed.
+	c.Assert(stats, HasLen, 3)
+	c.Assert(stats[0].StoreID, Equals, uint64(1))
+	c.Assert(stats[0].Current.BytesWriteRate, Equals, 100.0)
+	c.Assert(stats[0].Windows, HasLen, 2)
+	c.Assert(stats[0].Windows[0].Window.Duration, Equals, time.Hour)
+	c.Assert(stats[0].Windows[0].BytesWriteRate, Equals, 100.0)
+	c.Assert(stats[0].Windows[1].Window.Duration, Equals, 24*time.Hour)
+}
+
 func (s *testStoreSuite) TestStoreSetState(c *C) {
 	url := fmt.Sprintf("%s/store/1", s.urlPrefix)
 	info := StoreInfo{}
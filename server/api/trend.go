@@ -88,13 +88,13 @@ func (h *trendHandler) Handle(w http.ResponseWriter, r *http.Request) {
 
 	stores, err := h.getTrendStores()
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 
 	history, err := h.getTrendHistory(from)
 	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		errorResp(h.rd, w, err)
 		return
 	}
 
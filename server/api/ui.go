@@ -0,0 +1,205 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/server"
+	"github.com/unrolled/render"
+	"go.uber.org/zap"
+)
+
+// uiHandler serves the built-in UI, a single page showing the overview of the
+// cluster with the data of the APIs, which are redirected to the leader.
+type uiHandler struct {
+	svr *server.Server
+	rd  *render.Render
+}
+
+func newUIHandler(svr *server.Server, rd *render.Render) *uiHandler {
+	return &uiHandler{
+		svr: svr,
+		rd:  rd,
+	}
+}
+
+func (h *uiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.svr.GetPDServerConfig().EnableUI {
+		h.rd.JSON(w, http.StatusNotFound, "the UI is disabled")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.WriteString(w, uiPage); err != nil {
+		log.Error("failed to write the UI page", zap.Error(err))
+	}
+}
+
+// uiPage is the page of the UI. It refreshes itself periodically, and renders
+// the data as text only, since the labels and the reasons are set by users.
+const uiPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>PD Cluster Overview</title>
+<style>
+body { font-family: sans-serif; margin: 20px; color: #333; }
+h2 { border-bottom: 1px solid #ccc; padding-bottom: 4px; }
+table { border-collapse: collapse; margin-bottom: 16px; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: left; }
+th { background: #f4f4f4; }
+.bar { background: #4a90d9; height: 10px; }
+.error { color: #c00; }
+</style>
+</head>
+<body>
+<h1>PD Cluster Overview</h1>
+<div id="error" class="error"></div>
+<h2>Stores</h2>
+<div id="stores"></div>
+<h2>Region Distribution</h2>
+<div id="regions"></div>
+<h2>Operators</h2>
+<div id="operators"></div>
+<h2>Scheduling Pauses</h2>
+<div id="pauses"></div>
+<script>
+var api = "/pd/api/v1";
+
+function get(path) {
+  return fetch(api + path).then(function(resp) {
+    if (!resp.ok) {
+      return resp.text().then(function(text) { throw new Error(path + ": " + text); });
+    }
+    return resp.json();
+  });
+}
+
+function table(headers, rows) {
+  var t = document.createElement("table");
+  var tr = t.insertRow();
+  headers.forEach(function(h) {
+    var th = document.createElement("th");
+    th.textContent = h;
+    tr.appendChild(th);
+  });
+  rows.forEach(function(row) {
+    var tr = t.insertRow();
+    row.forEach(function(cell) {
+      var td = tr.insertCell();
+      if (cell instanceof Node) {
+        td.appendChild(cell);
+      } else {
+        td.textContent = cell === undefined || cell === null ? "" : cell;
+      }
+    });
+  });
+  return t;
+}
+
+function show(id, nodes) {
+  var div = document.getElementById(id);
+  while (div.firstChild) {
+    div.removeChild(div.firstChild);
+  }
+  nodes.forEach(function(n) { div.appendChild(n); });
+}
+
+function text(s) {
+  var p = document.createElement("p");
+  p.textContent = s;
+  return p;
+}
+
+function storeType(store) {
+  var labels = store.labels || [];
+  for (var i = 0; i < labels.length; i++) {
+    if (labels[i].key === "store-type") {
+      return labels[i].value;
+    }
+  }
+  return "performance";
+}
+
+function renderStores(data) {
+  var tiers = {};
+  (data.stores || []).forEach(function(s) {
+    var t = storeType(s.store);
+    (tiers[t] = tiers[t] || []).push(s);
+  });
+  var nodes = [];
+  Object.keys(tiers).sort().forEach(function(t) {
+    var h = document.createElement("h3");
+    h.textContent = t + " (" + tiers[t].length + ")";
+    nodes.push(h);
+    nodes.push(table(["ID", "Address", "State", "Capacity", "Available", "Leaders", "Regions", "Last Heartbeat"],
+      tiers[t].map(function(s) {
+        var st = s.status || {};
+        return [s.store.id, s.store.address, s.store.state_name, st.capacity, st.available,
+          st.leader_count || 0, st.region_count || 0, st.last_heartbeat_ts];
+      })));
+  });
+  show("stores", nodes.length ? nodes : [text("No stores.")]);
+
+  var stores = (data.stores || []).filter(function(s) { return s.store.state_name !== "Tombstone"; });
+  var max = 1;
+  stores.forEach(function(s) { max = Math.max(max, (s.status || {}).region_count || 0); });
+  show("regions", [table(["Store", "Tier", "Regions", "Leaders", ""], stores.map(function(s) {
+    var st = s.status || {};
+    var bar = document.createElement("div");
+    bar.className = "bar";
+    bar.style.width = Math.round(200 * (st.region_count || 0) / max) + "px";
+    return [s.store.id, storeType(s.store), st.region_count || 0, st.leader_count || 0, bar];
+  }))]);
+}
+
+function renderOperators(ops) {
+  ops = ops || [];
+  show("operators", ops.length ? [table(["Operator"], ops.map(function(op) { return [op]; }))] : [text("No operators.")]);
+}
+
+function renderPauses(schedule, merges) {
+  var rows = [];
+  if (schedule && schedule.pause) {
+    var p = schedule.pause;
+    rows.push(["scheduling", p.owner, p.reason, "", p.pause_time, p.expire_time || "never"]);
+  }
+  (merges || []).forEach(function(p) {
+    rows.push([p.pause_split ? "merge and split" : "merge", p.owner, p.reason,
+      "[" + p.start_key + ", " + p.end_key + ")", p.pause_time, p.expire_time]);
+  });
+  show("pauses", rows.length ? [table(["Paused", "Owner", "Reason", "Range", "Since", "Until"], rows)] : [text("Nothing is paused.")]);
+}
+
+function refresh() {
+  Promise.all([get("/stores"), get("/operators"), get("/schedule/pause"), get("/merge-pauses")]).then(function(r) {
+    document.getElementById("error").textContent = "";
+    renderStores(r[0]);
+    renderOperators(r[1]);
+    renderPauses(r[2], r[3]);
+  }).catch(function(err) {
+    document.getElementById("error").textContent = err.message;
+  });
+}
+
+refresh();
+setInterval(refresh, 10000);
+</script>
+</body>
+</html>
+`
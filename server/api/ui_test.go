@@ -0,0 +1,70 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/pd/server"
+)
+
+var _ = Suite(&testUISuite{})
+
+type testUISuite struct {
+	hc        *http.Client
+	svr       *server.Server
+	cleanup   cleanUpFunc
+	urlPrefix string
+}
+
+func (s *testUISuite) SetUpSuite(c *C) {
+	s.hc = newHTTPClient()
+	s.svr, s.cleanup = mustNewServer(c)
+	mustWaitLeader(c, []*server.Server{s.svr})
+	s.urlPrefix = s.svr.GetAddr() + apiPrefix + "/ui"
+}
+
+func (s *testUISuite) TearDownSuite(c *C) {
+	s.cleanup()
+}
+
+func (s *testUISuite) TestUI(c *C) {
+	c.Assert(s.svr.GetPDServerConfig().EnableUI, IsTrue)
+	for _, url := range []string{s.urlPrefix, s.urlPrefix + "/"} {
+		resp, err := s.hc.Get(url)
+		c.Assert(err, IsNil)
+		buf, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		c.Assert(err, IsNil)
+		c.Assert(resp.StatusCode, Equals, http.StatusOK)
+		c.Assert(strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html"), IsTrue)
+		c.Assert(strings.Contains(string(buf), "PD Cluster Overview"), IsTrue)
+	}
+
+	// The UI is not served once disabled.
+	cfg := s.svr.GetPDServerConfig()
+	cfg.EnableUI = false
+	c.Assert(s.svr.SetPDServerConfig(*cfg), IsNil)
+	defer func() {
+		cfg.EnableUI = true
+		c.Assert(s.svr.SetPDServerConfig(*cfg), IsNil)
+	}()
+	resp, err := s.hc.Get(s.urlPrefix)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusNotFound)
+}
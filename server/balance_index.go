@@ -0,0 +1,146 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"math"
+	"time"
+
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+)
+
+const (
+	// balanceIndexInterval is the interval to compute the balance index.
+	balanceIndexInterval = time.Minute
+	// balanceIndexHistorySize is the number of the balance indexes kept,
+	// which is an hour of the indexes.
+	balanceIndexHistorySize = 60
+)
+
+// BalanceIndex is the imbalance of the stores at a time. Each dimension is the
+// coefficient of variation of the stores, which is 0 if they are balanced, and
+// Score is the mean of the dimensions.
+type BalanceIndex struct {
+	Time        time.Time `json:"time"`
+	WriteFlow   float64   `json:"write_flow"`
+	ReadFlow    float64   `json:"read_flow"`
+	LeaderCount float64   `json:"leader_count"`
+	RegionSize  float64   `json:"region_size"`
+	Score       float64   `json:"score"`
+}
+
+// coefficientOfVariation returns the ratio of the standard deviation of the
+// values to their mean, or 0 if the mean is 0.
+func coefficientOfVariation(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if mean == 0 {
+		return 0
+	}
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(len(values))
+	return math.Sqrt(variance) / mean
+}
+
+// computeBalanceIndex computes the balance index of the stores which are up
+// and can hold replicas.
+func (c *RaftCluster) computeBalanceIndex() *BalanceIndex {
+	var writeFlows, readFlows, leaderCounts, regionSizes []float64
+	for _, store := range c.GetStores() {
+		if !store.IsUp() || store.IsLearnerOnly() {
+			continue
+		}
+		writeRate, readRate := c.storesStats.GetStoreBytesRate(store.GetID())
+		writeFlows = append(writeFlows, writeRate)
+		readFlows = append(readFlows, readRate)
+		leaderCounts = append(leaderCounts, float64(store.GetLeaderCount()))
+		regionSizes = append(regionSizes, float64(store.GetRegionSize()))
+	}
+	index := &BalanceIndex{
+		Time:        time.Now(),
+		WriteFlow:   coefficientOfVariation(writeFlows),
+		ReadFlow:    coefficientOfVariation(readFlows),
+		LeaderCount: coefficientOfVariation(leaderCounts),
+		RegionSize:  coefficientOfVariation(regionSizes),
+	}
+	index.Score = (index.WriteFlow + index.ReadFlow + index.LeaderCount + index.RegionSize) / 4
+	return index
+}
+
+// updateBalanceIndex computes the current balance index every minute, appends
+// it to the history, and persists the history so that it survives leader
+// changes.
+func (c *RaftCluster) updateBalanceIndex() {
+	c.RLock()
+	last := len(c.balanceIndexes) - 1
+	skip := last >= 0 && time.Since(c.balanceIndexes[last].Time) < balanceIndexInterval
+	c.RUnlock()
+	if skip {
+		return
+	}
+	index := c.computeBalanceIndex()
+	balanceIndexGauge.WithLabelValues("write-flow").Set(index.WriteFlow)
+	balanceIndexGauge.WithLabelValues("read-flow").Set(index.ReadFlow)
+	balanceIndexGauge.WithLabelValues("leader-count").Set(index.LeaderCount)
+	balanceIndexGauge.WithLabelValues("region-size").Set(index.RegionSize)
+	balanceIndexGauge.WithLabelValues("score").Set(index.Score)
+
+	c.Lock()
+	c.balanceIndexes = append(c.balanceIndexes, index)
+	if len(c.balanceIndexes) > balanceIndexHistorySize {
+		c.balanceIndexes = c.balanceIndexes[len(c.balanceIndexes)-balanceIndexHistorySize:]
+	}
+	history := append([]*BalanceIndex(nil), c.balanceIndexes...)
+	c.Unlock()
+
+	// Saves the copy of the history outside the lock, so that the heartbeats
+	// are not blocked by etcd.
+	if err := c.storage.SaveBalanceIndexHistory(history); err != nil {
+		log.Error("failed to save balance index history", zap.Error(err))
+	}
+}
+
+// loadBalanceIndexes loads the history of the balance indexes.
+func (c *RaftCluster) loadBalanceIndexes() error {
+	var indexes []*BalanceIndex
+	if _, err := c.storage.LoadBalanceIndexHistory(&indexes); err != nil {
+		return err
+	}
+	c.balanceIndexes = indexes
+	return nil
+}
+
+// GetBalanceIndexes returns the balance indexes since the time, in the order
+// of the time.
+func (c *RaftCluster) GetBalanceIndexes(since time.Time) []*BalanceIndex {
+	c.RLock()
+	defer c.RUnlock()
+	indexes := make([]*BalanceIndex, 0, len(c.balanceIndexes))
+	for _, index := range c.balanceIndexes {
+		if !index.Time.Before(since) {
+			indexes = append(indexes, index)
+		}
+	}
+	return indexes
+}
@@ -0,0 +1,74 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/pd/pkg/mock/mockid"
+)
+
+var _ = Suite(&testBalanceIndexSuite{})
+
+type testBalanceIndexSuite struct{}
+
+func (s *testBalanceIndexSuite) TestCoefficientOfVariation(c *C) {
+	c.Assert(coefficientOfVariation(nil), Equals, 0.0)
+	c.Assert(coefficientOfVariation([]float64{0, 0}), Equals, 0.0)
+	c.Assert(coefficientOfVariation([]float64{5, 5, 5}), Equals, 0.0)
+	c.Assert(coefficientOfVariation([]float64{10, 30}), Equals, 0.5)
+}
+
+func (s *testBalanceIndexSuite) TestBalanceIndex(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	tc := newTestCluster(opt)
+	c.Assert(tc.addRegionStore(1, 10), IsNil)
+	c.Assert(tc.addRegionStore(2, 30), IsNil)
+	c.Assert(tc.updateLeaderCount(1, 20), IsNil)
+	c.Assert(tc.updateLeaderCount(2, 20), IsNil)
+	c.Assert(tc.addRegionStore(3, 20), IsNil)
+	c.Assert(tc.setStoreOffline(3), IsNil)
+
+	// The offline store is not counted.
+	tc.updateBalanceIndex()
+	indexes := tc.GetBalanceIndexes(time.Time{})
+	c.Assert(indexes, HasLen, 1)
+	c.Assert(indexes[0].LeaderCount, Equals, 0.0)
+	c.Assert(indexes[0].RegionSize, Equals, 0.5)
+	c.Assert(indexes[0].Score, Equals, 0.125)
+
+	// The index is computed once a minute.
+	tc.updateBalanceIndex()
+	c.Assert(tc.GetBalanceIndexes(time.Time{}), HasLen, 1)
+	c.Assert(tc.GetBalanceIndexes(time.Now().Add(time.Minute)), HasLen, 0)
+
+	// The history is kept for an hour.
+	tc.Lock()
+	for i := 1; i < balanceIndexHistorySize; i++ {
+		tc.balanceIndexes = append(tc.balanceIndexes, &BalanceIndex{Time: time.Now().Add(-time.Hour)})
+	}
+	tc.Unlock()
+	tc.updateBalanceIndex()
+	indexes = tc.GetBalanceIndexes(time.Time{})
+	c.Assert(indexes, HasLen, balanceIndexHistorySize)
+	c.Assert(indexes[0].Time.Before(time.Now().Add(-time.Minute)), IsTrue)
+	c.Assert(indexes[len(indexes)-1].RegionSize, Equals, 0.5)
+
+	// The history is loaded by a new cluster.
+	cluster := createTestRaftCluster(mockid.NewIDAllocator(), opt, tc.storage)
+	c.Assert(cluster.loadBalanceIndexes(), IsNil)
+	c.Assert(cluster.GetBalanceIndexes(time.Time{}), HasLen, balanceIndexHistorySize)
+}
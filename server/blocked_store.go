@@ -0,0 +1,154 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// BlockedStore records a store blocked from scheduling by users. Unlike the
+// stores blocked by schedulers, which are blocked again when the schedulers
+// are created by the new leader, it is persisted so that the store stays
+// blocked after the leader changes.
+type BlockedStore struct {
+	StoreID   uint64    `json:"store_id"`
+	Reason    string    `json:"reason"`
+	BlockTime time.Time `json:"block_time"`
+	// ExpireTime is when the store is unblocked automatically. The store is
+	// blocked until being unblocked by users if it is nil.
+	ExpireTime *time.Time `json:"expire_time,omitempty"`
+}
+
+func (b *BlockedStore) isExpired(now time.Time) bool {
+	return b.ExpireTime != nil && !now.Before(*b.ExpireTime)
+}
+
+func (c *RaftCluster) loadBlockedStores() error {
+	return c.storage.LoadBlockedStores(func(value string) error {
+		b := &BlockedStore{}
+		if err := json.Unmarshal([]byte(value), b); err != nil {
+			return errors.WithStack(err)
+		}
+		if c.GetStore(b.StoreID) == nil {
+			log.Warn("blocked store is not found", zap.Uint64("store-id", b.StoreID))
+			return nil
+		}
+		if err := c.core.BlockStore(b.StoreID); err != nil {
+			return err
+		}
+		c.blockedStores[b.StoreID] = b
+		return nil
+	})
+}
+
+// AddBlockedStore blocks the store from scheduling until it is removed by
+// RemoveBlockedStore, or ttl passes if ttl is positive. Adding a blocked store
+// again updates the reason and the expiry.
+func (c *RaftCluster) AddBlockedStore(storeID uint64, reason string, ttl time.Duration) (*BlockedStore, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	store := c.GetStore(storeID)
+	if store == nil {
+		return nil, core.NewStoreNotFoundErr(storeID)
+	}
+	if store.IsTombstone() {
+		return nil, core.StoreTombstonedErr{StoreID: storeID}
+	}
+	now := time.Now()
+	b := &BlockedStore{
+		StoreID:   storeID,
+		Reason:    reason,
+		BlockTime: now,
+	}
+	if ttl > 0 {
+		expireTime := now.Add(ttl)
+		b.ExpireTime = &expireTime
+	}
+	old, ok := c.blockedStores[storeID]
+	if ok {
+		b.BlockTime = old.BlockTime
+	} else if err := c.core.BlockStore(storeID); err != nil {
+		// The store is blocked by a scheduler.
+		return nil, err
+	}
+	if err := c.storage.SaveBlockedStore(storeID, b); err != nil {
+		if !ok {
+			c.core.UnblockStore(storeID)
+		}
+		return nil, err
+	}
+	c.blockedStores[storeID] = b
+	log.Info("store is blocked from scheduling",
+		zap.Uint64("store-id", storeID),
+		zap.String("reason", reason),
+		zap.Duration("ttl", ttl))
+	return b, nil
+}
+
+// RemoveBlockedStore unblocks a store blocked by AddBlockedStore.
+func (c *RaftCluster) RemoveBlockedStore(storeID uint64) error {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.blockedStores[storeID]; !ok {
+		return errcode.NewNotFoundErr(errors.Errorf("store %d is not blocked", storeID))
+	}
+	return c.unblockStoreLocked(storeID)
+}
+
+func (c *RaftCluster) unblockStoreLocked(storeID uint64) error {
+	if err := c.storage.DeleteBlockedStore(storeID); err != nil {
+		return err
+	}
+	c.core.UnblockStore(storeID)
+	delete(c.blockedStores, storeID)
+	log.Info("store is unblocked from scheduling", zap.Uint64("store-id", storeID))
+	return nil
+}
+
+// GetBlockedStores returns the stores blocked by AddBlockedStore in the
+// order of store IDs.
+func (c *RaftCluster) GetBlockedStores() []*BlockedStore {
+	c.RLock()
+	defer c.RUnlock()
+	stores := make([]*BlockedStore, 0, len(c.blockedStores))
+	for _, b := range c.blockedStores {
+		stores = append(stores, b)
+	}
+	sort.Slice(stores, func(i, j int) bool { return stores[i].StoreID < stores[j].StoreID })
+	return stores
+}
+
+// checkBlockedStores unblocks the blocked stores which are expired.
+func (c *RaftCluster) checkBlockedStores() {
+	c.Lock()
+	defer c.Unlock()
+	now := time.Now()
+	for storeID, b := range c.blockedStores {
+		if !b.isExpired(now) {
+			continue
+		}
+		if err := c.unblockStoreLocked(storeID); err != nil {
+			log.Error("failed to unblock expired store", zap.Uint64("store-id", storeID), zap.Error(err))
+		}
+	}
+}
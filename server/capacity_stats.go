@@ -0,0 +1,91 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"math"
+
+	"github.com/pingcap/pd/server/core"
+)
+
+const secondsPerDay = 24 * 60 * 60
+
+// CapacityStats is the capacity of the stores of a type for planning hardware
+// additions. The stores are regarded full when they reach the low space ratio,
+// since no more regions are scheduled to them.
+type CapacityStats struct {
+	StoreType  core.StoreType `json:"store_type"`
+	StoreCount int            `json:"store_count"`
+	Capacity   uint64         `json:"capacity"`
+	Used       uint64         `json:"used"`
+	Available  uint64         `json:"available"`
+	// Headroom is the available size before the stores are full.
+	Headroom uint64 `json:"headroom"`
+	// GrowthRate is the growth of the used size in bytes per day, which is
+	// estimated from the samples of the last day.
+	GrowthRate float64 `json:"growth_rate"`
+	// DaysToFull is the projected days before the stores are full, or nil if
+	// the used size is not growing.
+	DaysToFull *float64 `json:"days_to_full,omitempty"`
+	// ReplicaCount is the number of the region replicas on the stores.
+	ReplicaCount int `json:"replica_count"`
+	// AvgReplicaSize is the average approximate size of the replicas in MB.
+	AvgReplicaSize float64 `json:"avg_replica_size"`
+	// ReplicaHeadroom is the number of the replicas of the average size which
+	// the stores can still hold.
+	ReplicaHeadroom uint64 `json:"replica_headroom"`
+}
+
+// GetCapacityStats returns the capacity statistics of the performance and the
+// storage stores. The offline and tombstone stores are not counted since they
+// are leaving the cluster.
+func (c *RaftCluster) GetCapacityStats() []*CapacityStats {
+	stats := map[core.StoreType]*CapacityStats{
+		core.StoreTypePerformance: {StoreType: core.StoreTypePerformance},
+		core.StoreTypeStorage:     {StoreType: core.StoreTypeStorage},
+	}
+	regionSizes := make(map[core.StoreType]int64)
+	lowSpaceRatio := c.GetLowSpaceRatio()
+	for _, store := range c.GetStores() {
+		if !store.IsUp() {
+			continue
+		}
+		s := stats[store.GetStoreType()]
+		s.StoreCount++
+		s.Capacity += store.GetCapacity()
+		s.Used += store.GetUsedSize()
+		s.Available += store.GetAvailable()
+		reserved := uint64(math.Ceil(float64(store.GetCapacity()) * (1 - lowSpaceRatio)))
+		if store.GetAvailable() > reserved {
+			s.Headroom += store.GetAvailable() - reserved
+		}
+		s.GrowthRate += c.storesStats.GetStoreUsedSizeGrowthRate(store.GetID()) * secondsPerDay
+		s.ReplicaCount += store.GetRegionCount()
+		regionSizes[store.GetStoreType()] += store.GetRegionSize()
+	}
+
+	for typ, s := range stats {
+		if s.GrowthRate > 0 {
+			days := float64(s.Headroom) / s.GrowthRate
+			s.DaysToFull = &days
+		}
+		if s.ReplicaCount > 0 {
+			s.AvgReplicaSize = float64(regionSizes[typ]) / float64(s.ReplicaCount)
+		}
+		if s.AvgReplicaSize > 0 {
+			s.ReplicaHeadroom = uint64(float64(s.Headroom) / (s.AvgReplicaSize * (1 << 20)))
+		}
+	}
+	return []*CapacityStats{stats[core.StoreTypePerformance], stats[core.StoreTypeStorage]}
+}
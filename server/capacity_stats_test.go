@@ -0,0 +1,89 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/pd/server/core"
+)
+
+var _ = Suite(&testCapacityStatsSuite{})
+
+type testCapacityStatsSuite struct{}
+
+const gb = 1 << 30
+
+func (s *testCapacityStatsSuite) TestCapacityStats(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	tc := newTestCluster(opt)
+
+	putStore := func(storeID uint64, state metapb.StoreState, storeType core.StoreType) {
+		store := core.NewStoreInfo(
+			&metapb.Store{
+				Id:     storeID,
+				State:  state,
+				Labels: []*metapb.StoreLabel{{Key: core.StoreTypeLabelKey, Value: string(storeType)}},
+			},
+			core.SetStoreStats(&pdpb.StoreStats{Capacity: 100 * gb, UsedSize: 50 * gb, Available: 50 * gb}),
+			core.SetRegionCount(1024),
+			core.SetRegionSize(100*1024),
+		)
+		tc.Lock()
+		defer tc.Unlock()
+		c.Assert(tc.putStoreLocked(store), IsNil)
+	}
+	putStore(1, metapb.StoreState_Up, core.StoreTypePerformance)
+	putStore(2, metapb.StoreState_Up, core.StoreTypeStorage)
+	putStore(3, metapb.StoreState_Offline, core.StoreTypeStorage)
+
+	// Store 1 grows by 1GB per day.
+	heartbeat := func(start, end uint64, used uint64) {
+		c.Assert(tc.handleStoreHeartbeat(&pdpb.StoreStats{
+			StoreId:   1,
+			Capacity:  100 * gb,
+			UsedSize:  used,
+			Available: 100*gb - used,
+			Interval:  &pdpb.TimeInterval{StartTimestamp: start, EndTimestamp: end},
+		}, storeMemoryUsage{}), IsNil)
+	}
+	heartbeat(1000, 1010, 50*gb)
+	heartbeat(1010, 1020, 60*gb)
+	heartbeat(44200, 44210, 50*gb+gb/2)
+
+	stats := tc.GetCapacityStats()
+	c.Assert(stats, HasLen, 2)
+	performance, storage := stats[0], stats[1]
+	c.Assert(performance.StoreType, Equals, core.StoreTypePerformance)
+	c.Assert(performance.StoreCount, Equals, 1)
+	c.Assert(performance.Used, Equals, uint64(50*gb+gb/2))
+	// 20% of the capacity is reserved by the low space ratio.
+	c.Assert(performance.Headroom, Equals, uint64(29*gb+gb/2))
+	c.Assert(performance.GrowthRate, Equals, float64(gb))
+	c.Assert(*performance.DaysToFull, Equals, 29.5)
+	c.Assert(performance.ReplicaCount, Equals, 1024)
+	c.Assert(performance.AvgReplicaSize, Equals, 100.0)
+	c.Assert(performance.ReplicaHeadroom, Equals, uint64(302))
+
+	// The offline store is not counted.
+	c.Assert(storage.StoreType, Equals, core.StoreTypeStorage)
+	c.Assert(storage.StoreCount, Equals, 1)
+	c.Assert(storage.Capacity, Equals, uint64(100*gb))
+	c.Assert(storage.Headroom, Equals, uint64(30*gb))
+	c.Assert(storage.GrowthRate, Equals, 0.0)
+	c.Assert(storage.DaysToFull, IsNil)
+	c.Assert(storage.ReplicaHeadroom, Equals, uint64(307))
+}
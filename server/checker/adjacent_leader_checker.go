@@ -0,0 +1,104 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"bytes"
+
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/schedule"
+	"github.com/pingcap/pd/server/schedule/filter"
+	"github.com/pingcap/pd/server/schedule/operator"
+)
+
+const adjacentLeaderCheckerName = "adjacent-leader-checker"
+
+// AdjacentLeaderChecker spreads the leaders of chains of adjacent regions led
+// by the same store. Sequential writes append to the last region of a range,
+// and the regions split from it usually keep their leaders in the same store,
+// which makes the store a write hot spot.
+type AdjacentLeaderChecker struct {
+	cluster schedule.Cluster
+	filters []filter.Filter
+}
+
+// NewAdjacentLeaderChecker creates an adjacent leader checker.
+func NewAdjacentLeaderChecker(cluster schedule.Cluster) *AdjacentLeaderChecker {
+	return &AdjacentLeaderChecker{
+		cluster: cluster,
+		filters: []filter.Filter{
+			filter.StoreStateFilter{ActionScope: adjacentLeaderCheckerName, TransferLeader: true},
+		},
+	}
+}
+
+// Check verifies the leaders of the regions before the region, creating an
+// Operator if need. A region transfers its leader out only if the leaders of
+// the max-adjacent-leader-count regions just before it are all in the same
+// store, so a long chain is cut into chains within the limit.
+func (a *AdjacentLeaderChecker) Check(region *core.RegionInfo) *operator.Operator {
+	limit := int(a.cluster.GetMaxAdjacentLeaderCount())
+	if limit == 0 {
+		return nil
+	}
+	leaderStore := a.cluster.GetLeaderStore(region)
+	if leaderStore == nil {
+		return nil
+	}
+	checkerCounter.WithLabelValues("adjacent_leader_checker", "check").Inc()
+	if a.adjacentLeaderCount(region, limit) < limit {
+		return nil
+	}
+
+	avoid := uint64(0)
+	if _, next := a.cluster.GetAdjacentRegions(region); next != nil && bytes.Equal(region.GetEndKey(), next.GetStartKey()) {
+		avoid = next.GetLeader().GetStoreId()
+	}
+	filters := append([]filter.Filter{filter.NewLeaderFractionFilter(adjacentLeaderCheckerName, a.cluster.GetStores())}, a.filters...)
+	var target *core.StoreInfo
+	for _, store := range a.cluster.GetFollowerStores(region) {
+		// Moving the leader to the store leading the next region only makes
+		// another chain.
+		if store.GetID() == avoid || filter.Target(a.cluster, store, filters) {
+			continue
+		}
+		if target == nil || store.LeaderScore(0) < target.LeaderScore(0) {
+			target = store
+		}
+	}
+	if target == nil {
+		checkerCounter.WithLabelValues("adjacent_leader_checker", "no-target-store").Inc()
+		return nil
+	}
+	checkerCounter.WithLabelValues("adjacent_leader_checker", "new-operator").Inc()
+	return operator.CreateTransferLeaderOperator("spread-adjacent-leader", region, leaderStore.GetID(), target.GetID(), operator.OpLeader)
+}
+
+// adjacentLeaderCount returns the number of contiguous regions just before
+// the region whose leaders are in the same store as the region. It counts at
+// most limit regions.
+func (a *AdjacentLeaderChecker) adjacentLeaderCount(region *core.RegionInfo, limit int) int {
+	storeID := region.GetLeader().GetStoreId()
+	var count int
+	for count < limit {
+		prev, _ := a.cluster.GetAdjacentRegions(region)
+		if prev == nil || !bytes.Equal(prev.GetEndKey(), region.GetStartKey()) ||
+			prev.GetLeader().GetStoreId() != storeID {
+			break
+		}
+		count++
+		region = prev
+	}
+	return count
+}
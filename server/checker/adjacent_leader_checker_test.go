@@ -0,0 +1,63 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/pd/pkg/mock/mockcluster"
+	"github.com/pingcap/pd/pkg/mock/mockoption"
+	"github.com/pingcap/pd/server/schedule/operator"
+)
+
+var _ = Suite(&testAdjacentLeaderCheckerSuite{})
+
+type testAdjacentLeaderCheckerSuite struct{}
+
+func (s *testAdjacentLeaderCheckerSuite) TestCheck(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	cluster := mockcluster.NewCluster(opt)
+	cluster.AddLeaderStore(1, 10)
+	cluster.AddLeaderStore(2, 5)
+	cluster.AddLeaderStore(3, 0)
+	checker := NewAdjacentLeaderChecker(cluster)
+
+	// Regions [a, e) are led by store 1, and [e, f) by store 3.
+	cluster.AddLeaderRegionWithRange(1, "a", "b", 1, 2, 3)
+	cluster.AddLeaderRegionWithRange(2, "b", "c", 1, 2, 3)
+	cluster.AddLeaderRegionWithRange(3, "c", "d", 1, 2, 3)
+	cluster.AddLeaderRegionWithRange(4, "d", "e", 1, 2, 3)
+	cluster.AddLeaderRegionWithRange(5, "e", "f", 3, 1, 2)
+	c.Assert(checker.Check(cluster.GetRegion(3)), IsNil)
+
+	opt.MaxAdjacentLeaderCount = 2
+	c.Assert(checker.Check(cluster.GetRegion(1)), IsNil)
+	c.Assert(checker.Check(cluster.GetRegion(2)), IsNil)
+	// The leaders of the 2 regions before are in store 1.
+	op := checker.Check(cluster.GetRegion(3))
+	c.Assert(op, NotNil)
+	c.Assert(op.Step(0), Equals, operator.TransferLeader{FromStore: 1, ToStore: 3})
+	// Store 3 leads the next region, so store 2 is picked even if store 3 has
+	// fewer leaders.
+	op = checker.Check(cluster.GetRegion(4))
+	c.Assert(op, NotNil)
+	c.Assert(op.Step(0), Equals, operator.TransferLeader{FromStore: 1, ToStore: 2})
+
+	// The chain is cut.
+	cluster.AddLeaderRegionWithRange(3, "c", "d", 2, 1, 3)
+	c.Assert(checker.Check(cluster.GetRegion(4)), IsNil)
+
+	// Regions with a gap between them are not adjacent.
+	cluster.AddLeaderRegionWithRange(3, "c", "cc", 1, 2, 3)
+	c.Assert(checker.Check(cluster.GetRegion(4)), IsNil)
+}
@@ -15,14 +15,19 @@ package checker
 
 import (
 	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/schedule"
 	"github.com/pingcap/pd/server/schedule/operator"
 )
 
 // LearnerChecker ensures region has a learner will be promoted.
-type LearnerChecker struct{}
+type LearnerChecker struct {
+	cluster schedule.Cluster
+}
 
 // NewLearnerChecker creates a learner checker.
-func NewLearnerChecker() *LearnerChecker { return &LearnerChecker{} }
+func NewLearnerChecker(cluster schedule.Cluster) *LearnerChecker {
+	return &LearnerChecker{cluster: cluster}
+}
 
 // Check verifies a region's namespace, creating an Operator if need.
 func (l *LearnerChecker) Check(region *core.RegionInfo) *operator.Operator {
@@ -30,6 +35,14 @@ func (l *LearnerChecker) Check(region *core.RegionInfo) *operator.Operator {
 		if region.GetPendingLearner(p.GetId()) != nil {
 			continue
 		}
+		// Promoting a learner far behind the leader makes the region lose
+		// availability until the new voter catches up.
+		if maxLag := l.cluster.GetMaxLearnerPromoteLag(); maxLag > 0 {
+			if lag, ok := region.GetLearnerLag(p.GetId()); ok && lag > maxLag {
+				checkerCounter.WithLabelValues("learner_checker", "lagging-learner").Inc()
+				continue
+			}
+		}
 		op := operator.CreatePromoteLearnerOperator("promote-learner", region, p)
 		return op
 	}
@@ -0,0 +1,57 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/pkg/mock/mockcluster"
+	"github.com/pingcap/pd/pkg/mock/mockoption"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/schedule/operator"
+)
+
+var _ = Suite(&testLearnerCheckerSuite{})
+
+type testLearnerCheckerSuite struct{}
+
+func (s *testLearnerCheckerSuite) TestPromoteLearner(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	cluster := mockcluster.NewCluster(opt)
+	checker := NewLearnerChecker(cluster)
+	peers := []*metapb.Peer{
+		{Id: 101, StoreId: 1},
+		{Id: 102, StoreId: 2},
+		{Id: 103, StoreId: 3, IsLearner: true},
+	}
+	region := core.NewRegionInfo(&metapb.Region{Id: 1, Peers: peers}, peers[0],
+		core.WithLearnerLags(map[uint64]uint64{103: 100}))
+
+	op := checker.Check(region)
+	c.Assert(op, NotNil)
+	c.Assert(op.Step(0), Equals, operator.PromoteLearner{ToStore: 3, PeerID: 103})
+
+	// The learner lagging behind is not promoted.
+	opt.MaxLearnerPromoteLag = 50
+	c.Assert(checker.Check(region), IsNil)
+	region = region.Clone(core.WithLearnerLags(map[uint64]uint64{103: 50}))
+	c.Assert(checker.Check(region), NotNil)
+
+	// The learner with unknown lag is promoted.
+	region = region.Clone(core.WithLearnerLags(nil))
+	c.Assert(checker.Check(region), NotNil)
+
+	region = region.Clone(core.WithPendingPeers(peers[2:]))
+	c.Assert(checker.Check(region), IsNil)
+}
@@ -0,0 +1,151 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pingcap/pd/server/core"
+)
+
+// locationRepairDescPrefix is the prefix of the descriptions of the operators
+// repairing location violations, which is followed by the location label of
+// the violation for metrics.
+const locationRepairDescPrefix = "move-to-better-location-"
+
+// LocationViolation is a region whose replicas share a location while the
+// cluster has enough locations to isolate them.
+type LocationViolation struct {
+	RegionID uint64 `json:"region_id"`
+	// Label is the deepest location label shared by the replicas, such as
+	// "host".
+	Label string `json:"label"`
+	// Level is the number of the leading location labels shared by the
+	// replicas. A higher level is more severe.
+	Level    int      `json:"level"`
+	StoreIDs []uint64 `json:"store_ids"`
+}
+
+// CheckLocation returns the location violation of the region, or nil if the
+// replicas are isolated as well as the cluster allows.
+func (r *ReplicaChecker) CheckLocation(region *core.RegionInfo) *LocationViolation {
+	labels := r.cluster.GetLocationLabels()
+	if len(labels) == 0 {
+		return nil
+	}
+	return r.checkLocation(region, labels, r.countLocations(labels))
+}
+
+// CheckLocations returns the location violations of the regions, ordered by
+// the severity from the worst.
+func (r *ReplicaChecker) CheckLocations(regions []*core.RegionInfo) []*LocationViolation {
+	violations := make([]*LocationViolation, 0)
+	labels := r.cluster.GetLocationLabels()
+	if len(labels) == 0 {
+		return violations
+	}
+	counts := r.countLocations(labels)
+	for _, region := range regions {
+		if v := r.checkLocation(region, labels, counts); v != nil {
+			violations = append(violations, v)
+		}
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Level != violations[j].Level {
+			return violations[i].Level > violations[j].Level
+		}
+		return violations[i].RegionID < violations[j].RegionID
+	})
+	return violations
+}
+
+// countLocations returns the number of the distinct locations of the stores
+// able to hold replicas at each level, where counts[i] is the number of the
+// distinct values of the first i+1 labels.
+func (r *ReplicaChecker) countLocations(labels []string) []int {
+	locations := make([]map[string]struct{}, len(labels))
+	for i := range locations {
+		locations[i] = make(map[string]struct{})
+	}
+	for _, store := range r.cluster.GetStores() {
+		if !store.IsUp() || store.IsLearnerOnly() {
+			continue
+		}
+		var location string
+		for i, label := range labels {
+			location += "/" + strings.ToLower(store.GetLabelValue(label))
+			locations[i][location] = struct{}{}
+		}
+	}
+	counts := make([]int, len(labels))
+	for i := range locations {
+		counts[i] = len(locations[i])
+	}
+	return counts
+}
+
+func (r *ReplicaChecker) checkLocation(region *core.RegionInfo, labels []string, counts []int) *LocationViolation {
+	stores := r.getReplicaStores(region)
+	var level int
+	shared := make(map[uint64]struct{})
+	for i := range stores {
+		for j := i + 1; j < len(stores); j++ {
+			l := stores[i].CompareLocation(stores[j], labels)
+			if l == -1 {
+				l = len(labels)
+			}
+			if l == 0 || l < level {
+				continue
+			}
+			if l > level {
+				level = l
+				shared = make(map[uint64]struct{})
+			}
+			shared[stores[i].GetID()] = struct{}{}
+			shared[stores[j].GetID()] = struct{}{}
+		}
+	}
+	// The replicas cannot be isolated at the level if there are not enough
+	// locations.
+	if level == 0 || counts[level-1] < len(stores) {
+		return nil
+	}
+	storeIDs := make([]uint64, 0, len(shared))
+	for id := range shared {
+		storeIDs = append(storeIDs, id)
+	}
+	sort.Slice(storeIDs, func(i, j int) bool { return storeIDs[i] < storeIDs[j] })
+	return &LocationViolation{
+		RegionID: region.GetID(),
+		Label:    labels[level-1],
+		Level:    level,
+		StoreIDs: storeIDs,
+	}
+}
+
+// locationRepairPriority returns the priority of the operator repairing the
+// violation. The replicas sharing all location labels, such as the ones on the
+// same host, are repaired first, and the ones sharing only the top label, such
+// as the ones in the same zone, are repaired last.
+func locationRepairPriority(v *LocationViolation, labels []string) core.PriorityLevel {
+	switch {
+	case v.Level == len(labels):
+		return core.HighPriority
+	case v.Level == 1:
+		return core.LowPriority
+	default:
+		return core.NormalPriority
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/pd/pkg/mock/mockcluster"
+	"github.com/pingcap/pd/pkg/mock/mockoption"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/namespace"
+	"github.com/pingcap/pd/server/schedule/operator"
+)
+
+var _ = Suite(&testLocationViolationSuite{})
+
+type testLocationViolationSuite struct{}
+
+func (s *testLocationViolationSuite) TestCheckLocations(c *C) {
+	cfg := mockoption.NewScheduleOptions()
+	cfg.LocationLabels = []string{"zone", "rack", "host"}
+	cluster := mockcluster.NewCluster(cfg)
+	rc := NewReplicaChecker(cluster, namespace.DefaultClassifier)
+	cluster.AddLabelsStore(1, 1, map[string]string{"zone": "z1", "rack": "r1", "host": "h1"})
+	cluster.AddLabelsStore(2, 1, map[string]string{"zone": "z1", "rack": "r1", "host": "h1"})
+	cluster.AddLabelsStore(3, 1, map[string]string{"zone": "z1", "rack": "r2", "host": "h2"})
+	cluster.AddLabelsStore(4, 1, map[string]string{"zone": "z2", "rack": "r1", "host": "h1"})
+	cluster.AddLabelsStore(5, 1, map[string]string{"zone": "z3", "rack": "r1", "host": "h1"})
+
+	cluster.AddLeaderRegion(1, 1, 3, 4)
+	cluster.AddLeaderRegion(2, 1, 2, 4)
+	cluster.AddLeaderRegion(3, 1, 4, 5)
+	regions := []*core.RegionInfo{cluster.GetRegion(1), cluster.GetRegion(2), cluster.GetRegion(3)}
+
+	// The replicas on the same host are the worst.
+	violations := rc.CheckLocations(regions)
+	c.Assert(violations, HasLen, 2)
+	c.Assert(violations[0], DeepEquals, &LocationViolation{RegionID: 2, Label: "host", Level: 3, StoreIDs: []uint64{1, 2}})
+	c.Assert(violations[1], DeepEquals, &LocationViolation{RegionID: 1, Label: "zone", Level: 1, StoreIDs: []uint64{1, 3}})
+	c.Assert(rc.CheckLocation(cluster.GetRegion(3)), IsNil)
+
+	// The repairs are described by the labels and prioritized by the levels.
+	op := rc.Check(cluster.GetRegion(2))
+	c.Assert(op, NotNil)
+	c.Assert(op.Desc(), Equals, "move-to-better-location-host")
+	c.Assert(op.Kind()&operator.OpLocation, Equals, operator.OpLocation)
+	c.Assert(op.LocationLabel(), Equals, "host")
+	c.Assert(op.GetPriorityLevel(), Equals, core.HighPriority)
+	op = rc.Check(cluster.GetRegion(1))
+	c.Assert(op, NotNil)
+	c.Assert(op.Desc(), Equals, "move-to-better-location-zone")
+	c.Assert(op.GetPriorityLevel(), Equals, core.LowPriority)
+	c.Assert(op.LocationLabel(), Equals, "zone")
+
+	// The replicas in the same zone are not violations if there are not
+	// enough zones.
+	cluster.SetStoreOffline(5)
+	c.Assert(rc.CheckLocation(cluster.GetRegion(1)), IsNil)
+	c.Assert(rc.CheckLocation(cluster.GetRegion(2)), NotNil)
+}
@@ -14,13 +14,16 @@
 package checker
 
 import (
+	"bytes"
 	"time"
 
 	"github.com/pingcap/log"
 	"github.com/pingcap/pd/pkg/cache"
 	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/keydecoder"
 	"github.com/pingcap/pd/server/namespace"
 	"github.com/pingcap/pd/server/schedule"
+	"github.com/pingcap/pd/server/schedule/labeler"
 	"github.com/pingcap/pd/server/schedule/operator"
 	"go.uber.org/zap"
 )
@@ -29,21 +32,41 @@ import (
 // splitCache to prevent merging any regions when server is recently started.
 const mergeBlockMarker = 0
 
+// mergePlanTTL is how long a merge plan is kept without making progress.
+const mergePlanTTL = 10 * time.Minute
+
+// mergePlan is a chain of adjacent small regions to be merged into the target
+// region one after another. Each merge depends on the previous one, since a
+// source is adjacent to the target only after the previous source is merged.
+type mergePlan struct {
+	// sources are the regions to be merged, in the order of merging.
+	sources []uint64
+}
+
 // MergeChecker ensures region to merge with adjacent region when size is small
 type MergeChecker struct {
 	cluster    schedule.Cluster
 	classifier namespace.Classifier
+	labeler    *labeler.RangeLabeler
+	decoder    keydecoder.KeyDecoder
 	splitCache *cache.TTLUint64
+	// plans are the merge plans keyed by the target regions.
+	plans *cache.TTL
 }
 
-// NewMergeChecker creates a merge checker.
-func NewMergeChecker(cluster schedule.Cluster, classifier namespace.Classifier) *MergeChecker {
+// NewMergeChecker creates a merge checker. The labeler decides the tier of
+// merged regions, and the decoder decides the units that regions should not
+// be merged across. Both of them can be nil.
+func NewMergeChecker(cluster schedule.Cluster, classifier namespace.Classifier, labeler *labeler.RangeLabeler, decoder keydecoder.KeyDecoder) *MergeChecker {
 	splitCache := cache.NewIDTTL(time.Minute, cluster.GetSplitMergeInterval())
 	splitCache.Put(mergeBlockMarker)
 	return &MergeChecker{
 		cluster:    cluster,
 		classifier: classifier,
+		labeler:    labeler,
+		decoder:    decoder,
 		splitCache: splitCache,
+		plans:      cache.NewTTL(time.Minute, mergePlanTTL),
 	}
 }
 
@@ -60,13 +83,21 @@ func (m *MergeChecker) Check(region *core.RegionInfo) []*operator.Operator {
 		return nil
 	}
 
-	if m.splitCache.Exists(region.GetID()) {
+	// The long empty regions, such as the ones of dropped tables, are merged
+	// aggressively if enabled, since they only cost the heartbeats.
+	emptyMerge := m.cluster.IsEmptyRegionMergeEnabled() && m.cluster.IsRegionLongEmpty(region)
+
+	if m.splitCache.Exists(region.GetID()) && !emptyMerge {
 		checkerCounter.WithLabelValues("merge_checker", "recently-split").Inc()
 		return nil
 	}
 
 	checkerCounter.WithLabelValues("merge_checker", "check").Inc()
 
+	if ops := m.checkMergePlan(region); ops != nil {
+		return ops
+	}
+
 	// when pd just started, it will load region meta from etcd
 	// but the size for these loaded region info is 0
 	// pd don't know the real size of one region until the first heartbeat of the region
@@ -77,8 +108,8 @@ func (m *MergeChecker) Check(region *core.RegionInfo) []*operator.Operator {
 	}
 
 	// region is not small enough
-	if region.GetApproximateSize() > int64(m.cluster.GetMaxMergeRegionSize()) ||
-		region.GetApproximateKeys() > int64(m.cluster.GetMaxMergeRegionKeys()) {
+	if !emptyMerge && (region.GetApproximateSize() > int64(m.cluster.GetMaxMergeRegionSize()) ||
+		region.GetApproximateKeys() > int64(m.cluster.GetMaxMergeRegionKeys())) {
 		checkerCounter.WithLabelValues("merge_checker", "no-need").Inc()
 		return nil
 	}
@@ -100,6 +131,17 @@ func (m *MergeChecker) Check(region *core.RegionInfo) []*operator.Operator {
 		return nil
 	}
 
+	if m.cluster.IsMergePaused(region.GetStartKey(), region.GetEndKey()) {
+		checkerCounter.WithLabelValues("merge_checker", "paused").Inc()
+		return nil
+	}
+
+	// the region would leave its placement intent once merged
+	if m.cluster.GetPlacementIntent(region.GetID()) != nil {
+		checkerCounter.WithLabelValues("merge_checker", "placement-intent").Inc()
+		return nil
+	}
+
 	prev, next := m.cluster.GetAdjacentRegions(region)
 
 	var target *core.RegionInfo
@@ -124,10 +166,113 @@ func (m *MergeChecker) Check(region *core.RegionInfo) []*operator.Operator {
 		return nil
 	}
 	checkerCounter.WithLabelValues("merge_checker", "new-operator").Inc()
+	if emptyMerge {
+		checkerCounter.WithLabelValues("merge_checker", "empty-region").Inc()
+	}
 	if region.GetApproximateSize() > target.GetApproximateSize() ||
 		region.GetApproximateKeys() > target.GetApproximateKeys() {
 		checkerCounter.WithLabelValues("merge_checker", "larger-source").Inc()
 	}
+	m.planMergeChain(region, target)
+	return ops
+}
+
+func (m *MergeChecker) checkSource(region *core.RegionInfo) bool {
+	return region.GetApproximateSize() != 0 &&
+		region.GetApproximateSize() <= int64(m.cluster.GetMaxMergeRegionSize()) &&
+		region.GetApproximateKeys() <= int64(m.cluster.GetMaxMergeRegionKeys()) &&
+		len(region.GetDownPeers()) == 0 && len(region.GetPendingPeers()) == 0 && len(region.GetLearners()) == 0 &&
+		len(region.GetPeers()) == m.cluster.GetMaxReplicas() &&
+		!m.cluster.IsRegionHot(region) &&
+		!m.splitCache.Exists(region.GetID()) &&
+		m.cluster.GetPlacementIntent(region.GetID()) == nil &&
+		!m.cluster.IsMergePaused(region.GetStartKey(), region.GetEndKey())
+}
+
+// planMergeChain plans merging the small regions beyond the source into the
+// target after the source is merged. The regions are on the other side of the
+// source, so that each of them is adjacent to the target after the previous
+// one is merged. The chain stops before the merged region grows beyond the
+// max merge region size or keys.
+func (m *MergeChecker) planMergeChain(source, target *core.RegionInfo) {
+	limit := int(m.cluster.GetMaxMergeChainLength())
+	if limit <= 2 {
+		return
+	}
+	forward := bytes.Equal(source.GetEndKey(), target.GetStartKey())
+	size := source.GetApproximateSize() + target.GetApproximateSize()
+	keys := source.GetApproximateKeys() + target.GetApproximateKeys()
+	plan := &mergePlan{}
+	for region := source; len(plan.sources)+2 < limit; {
+		prev, next := m.cluster.GetAdjacentRegions(region)
+		adjacent := next
+		if forward {
+			adjacent = prev
+		}
+		if adjacent == nil || !m.checkSource(adjacent) || !m.checkTarget(adjacent, target) {
+			break
+		}
+		size += adjacent.GetApproximateSize()
+		keys += adjacent.GetApproximateKeys()
+		if size > int64(m.cluster.GetMaxMergeRegionSize()) || keys > int64(m.cluster.GetMaxMergeRegionKeys()) {
+			break
+		}
+		plan.sources = append(plan.sources, adjacent.GetID())
+		region = adjacent
+	}
+	if len(plan.sources) == 0 {
+		return
+	}
+	checkerCounter.WithLabelValues("merge_checker", "new-plan").Inc()
+	m.plans.Put(target.GetID(), plan)
+}
+
+// HasMergePlan checks if the region is the target of a merge plan.
+func (m *MergeChecker) HasMergePlan(regionID uint64) bool {
+	_, ok := m.plans.Get(regionID)
+	return ok
+}
+
+// checkMergePlan creates the next merge of the plan targeting the region. The
+// sources that no longer exist are considered merged. The plan is dropped if
+// the next source cannot be merged into the region any more.
+func (m *MergeChecker) checkMergePlan(target *core.RegionInfo) []*operator.Operator {
+	v, ok := m.plans.Get(target.GetID())
+	if !ok {
+		return nil
+	}
+	// The plan is not modified in place since it is shared by the patrol and
+	// the heartbeats.
+	sources := v.(*mergePlan).sources
+	for len(sources) > 0 && m.cluster.GetRegion(sources[0]) == nil {
+		sources = sources[1:]
+	}
+	if len(sources) == 0 {
+		m.plans.Remove(target.GetID())
+		return nil
+	}
+	source := m.cluster.GetRegion(sources[0])
+	if !bytes.Equal(source.GetEndKey(), target.GetStartKey()) && !bytes.Equal(target.GetEndKey(), source.GetStartKey()) {
+		// The previous source is being merged.
+		return nil
+	}
+	if !m.checkSource(source) || !m.checkTarget(source, target) ||
+		source.GetApproximateSize()+target.GetApproximateSize() > int64(m.cluster.GetMaxMergeRegionSize()) ||
+		source.GetApproximateKeys()+target.GetApproximateKeys() > int64(m.cluster.GetMaxMergeRegionKeys()) {
+		checkerCounter.WithLabelValues("merge_checker", "drop-plan").Inc()
+		m.plans.Remove(target.GetID())
+		return nil
+	}
+	ops, err := operator.CreateMergeRegionOperator("merge-region-chain", m.cluster, source, target, operator.OpMerge)
+	if err != nil {
+		log.Warn("create merge region operator failed", zap.Error(err))
+		m.plans.Remove(target.GetID())
+		return nil
+	}
+	log.Debug("try to merge region by plan", zap.Stringer("from", core.RegionToHexMeta(source.GetMeta())), zap.Stringer("to", core.RegionToHexMeta(target.GetMeta())), zap.Int("remaining", len(sources)-1))
+	checkerCounter.WithLabelValues("merge_checker", "new-plan-operator").Inc()
+	// Refreshes the TTL since the plan makes progress.
+	m.plans.Put(target.GetID(), &mergePlan{sources: sources})
 	return ops
 }
 
@@ -135,5 +280,74 @@ func (m *MergeChecker) checkTarget(region, adjacent *core.RegionInfo) bool {
 	return adjacent != nil && !m.cluster.IsRegionHot(adjacent) &&
 		m.classifier.AllowMerge(region, adjacent) &&
 		len(adjacent.GetDownPeers()) == 0 && len(adjacent.GetPendingPeers()) == 0 && len(adjacent.GetLearners()) == 0 && // no special peer
-		len(adjacent.GetPeers()) == m.cluster.GetMaxReplicas() // peer count should equal
+		len(adjacent.GetPeers()) == m.cluster.GetMaxReplicas() && // peer count should equal
+		m.allowUnitMerge(region, adjacent) &&
+		m.allowTierMerge(region, adjacent) &&
+		m.cluster.GetPlacementIntent(adjacent.GetID()) == nil &&
+		!m.cluster.IsMergePaused(adjacent.GetStartKey(), adjacent.GetEndKey())
+}
+
+// allowUnitMerge checks if the region and the adjacent region are in the same
+// unit decoded from their keys, such as a table.
+func (m *MergeChecker) allowUnitMerge(region, adjacent *core.RegionInfo) bool {
+	if m.decoder == nil ||
+		bytes.Equal(m.decoder.UnitStartKey(region.GetStartKey()), m.decoder.UnitStartKey(adjacent.GetStartKey())) {
+		return true
+	}
+	checkerCounter.WithLabelValues("merge_checker", "cross-unit").Inc()
+	return false
+}
+
+// allowTierMerge checks if the region can be merged into the adjacent region
+// without moving peers across tiers. Regions of different tiers are merged
+// only if the merged range is labeled for the tier of the adjacent region,
+// since the peers of the region are moved to the stores of the adjacent one.
+func (m *MergeChecker) allowTierMerge(region, adjacent *core.RegionInfo) bool {
+	if m.cluster.IsCrossTierMergeEnabled() {
+		return true
+	}
+	targetTier := m.regionTier(adjacent)
+	if m.regionTier(region) == targetTier {
+		return true
+	}
+	startKey, endKey := region.GetStartKey(), adjacent.GetEndKey()
+	if bytes.Equal(adjacent.GetEndKey(), region.GetStartKey()) {
+		startKey, endKey = adjacent.GetStartKey(), region.GetEndKey()
+	}
+	if targetTier != "" && m.labeledTier(startKey, endKey) == targetTier {
+		return true
+	}
+	checkerCounter.WithLabelValues("merge_checker", "cross-tier").Inc()
+	return false
+}
+
+// regionTier returns the type of the stores that the peers of the region are
+// on. It returns an empty type if the peers are on stores of different types.
+func (m *MergeChecker) regionTier(region *core.RegionInfo) core.StoreType {
+	var tier core.StoreType
+	for _, store := range m.cluster.GetRegionStores(region) {
+		if tier != "" && store.GetStoreType() != tier {
+			return ""
+		}
+		tier = store.GetStoreType()
+	}
+	return tier
+}
+
+// labeledTier returns the tier that the range should be on according to its
+// label. It returns an empty type if the range has no tier label.
+func (m *MergeChecker) labeledTier(startKey, endKey []byte) core.StoreType {
+	if m.labeler == nil {
+		return ""
+	}
+	hot := m.labeler.HasRangeLabel(startKey, endKey, labeler.HotServing)
+	cold := m.labeler.HasRangeLabel(startKey, endKey, labeler.Cold)
+	switch {
+	case hot && !cold:
+		return core.StoreTypePerformance
+	case cold && !hot:
+		return core.StoreTypeStorage
+	default:
+		return ""
+	}
 }
@@ -23,10 +23,13 @@ import (
 	"github.com/pingcap/pd/pkg/mock/mockhbstream"
 	"github.com/pingcap/pd/pkg/mock/mockoption"
 	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/keydecoder"
 	"github.com/pingcap/pd/server/namespace"
 	"github.com/pingcap/pd/server/schedule"
+	"github.com/pingcap/pd/server/schedule/labeler"
 	"github.com/pingcap/pd/server/schedule/operator"
 	"github.com/pingcap/pd/server/schedule/opt"
+	"github.com/pingcap/pd/table"
 )
 
 func TestChecker(t *testing.T) {
@@ -121,7 +124,7 @@ func (s *testMergeCheckerSuite) SetUpTest(c *C) {
 		s.cluster.PutRegion(region)
 	}
 
-	s.mc = NewMergeChecker(s.cluster, namespace.DefaultClassifier)
+	s.mc = NewMergeChecker(s.cluster, namespace.DefaultClassifier, nil, nil)
 }
 
 func (s *testMergeCheckerSuite) TestBasic(c *C) {
@@ -169,6 +172,248 @@ func (s *testMergeCheckerSuite) TestBasic(c *C) {
 	c.Assert(ops, IsNil)
 }
 
+func (s *testMergeCheckerSuite) TestMergePaused(c *C) {
+	c.Assert(s.mc.Check(s.regions[2]), NotNil)
+
+	// The target region is in the paused range.
+	s.cluster.PauseMerge([]byte("b"), []byte("c"))
+	c.Assert(s.mc.Check(s.regions[2]), IsNil)
+
+	// The source region is in the paused range.
+	s.SetUpTest(c)
+	s.cluster.PauseMerge([]byte("u"), []byte("v"))
+	c.Assert(s.mc.Check(s.regions[2]), IsNil)
+}
+
+func (s *testMergeCheckerSuite) TestPlacementIntent(c *C) {
+	c.Assert(s.mc.Check(s.regions[2]), NotNil)
+
+	// The target region has an intent.
+	s.cluster.AddPlacementIntent(&core.PlacementIntent{RegionIDs: []uint64{s.regions[1].GetID(), s.regions[3].GetID()}, LeaderStoreID: 1})
+	c.Assert(s.mc.Check(s.regions[2]), IsNil)
+
+	// The source region has an intent.
+	s.SetUpTest(c)
+	s.cluster.AddPlacementIntent(&core.PlacementIntent{RegionIDs: []uint64{s.regions[2].GetID()}, LeaderStoreID: 1})
+	c.Assert(s.mc.Check(s.regions[2]), IsNil)
+}
+
+func (s *testMergeCheckerSuite) TestCrossTier(c *C) {
+	for _, id := range []uint64{9, 10, 11} {
+		s.cluster.PutStoreWithLabels(id, core.StoreTypeLabelKey, string(core.StoreTypeStorage))
+	}
+	// Move the region to storage stores, so it is in the different tier from
+	// the previous region.
+	s.regions[2] = core.NewRegionInfo(
+		&metapb.Region{
+			Id:       3,
+			StartKey: []byte("t"),
+			EndKey:   []byte("x"),
+			Peers: []*metapb.Peer{
+				{Id: 106, StoreId: 9},
+				{Id: 107, StoreId: 10},
+				{Id: 108, StoreId: 11},
+			},
+		},
+		&metapb.Peer{Id: 108, StoreId: 11},
+		core.SetApproximateSize(1),
+		core.SetApproximateKeys(1),
+	)
+	s.cluster.PutRegion(s.regions[2])
+	c.Assert(s.mc.Check(s.regions[2]), IsNil)
+
+	l, err := labeler.NewRangeLabeler(nil)
+	c.Assert(err, IsNil)
+	mc := NewMergeChecker(s.cluster, namespace.DefaultClassifier, l, nil)
+	c.Assert(mc.Check(s.regions[2]), IsNil)
+
+	// The merged range is cold, which should not be on performance stores.
+	c.Assert(l.SetLabelRule(&labeler.LabelRule{ID: "r", Label: labeler.Cold, StartKey: "61", EndKey: "78"}), IsNil)
+	c.Assert(mc.Check(s.regions[2]), IsNil)
+
+	// The merged range is hot, so the region is merged to performance stores.
+	c.Assert(l.SetLabelRule(&labeler.LabelRule{ID: "r", Label: labeler.HotServing, StartKey: "61", EndKey: "78"}), IsNil)
+	ops := mc.Check(s.regions[2])
+	c.Assert(ops, NotNil)
+	c.Assert(ops[1].RegionID(), Equals, s.regions[1].GetID())
+
+	// Only a part of the merged range is hot.
+	c.Assert(l.SetLabelRule(&labeler.LabelRule{ID: "r", Label: labeler.HotServing, StartKey: "74", EndKey: "78"}), IsNil)
+	c.Assert(mc.Check(s.regions[2]), IsNil)
+
+	s.cluster.ScheduleOptions.EnableCrossTierMerge = true
+	c.Assert(s.mc.Check(s.regions[2]), NotNil)
+}
+
+func (s *testMergeCheckerSuite) TestCrossUnit(c *C) {
+	decoder, err := keydecoder.CreateDecoder("tidb")
+	c.Assert(err, IsNil)
+	mc := NewMergeChecker(s.cluster, namespace.DefaultClassifier, nil, decoder)
+	setBoundaries := func(k1, k2 []byte) {
+		s.regions[0] = s.regions[0].Clone(core.WithEndKey(k1))
+		s.regions[1] = s.regions[1].Clone(core.WithStartKey(k1), core.WithEndKey(k2))
+		s.regions[2] = s.regions[2].Clone(core.WithStartKey(k2))
+		for _, region := range s.regions[:3] {
+			s.cluster.PutRegion(region)
+		}
+	}
+
+	// The regions are in the different tables.
+	setBoundaries(table.EncodeBytes(table.GenerateTableKey(1)), table.EncodeBytes(table.GenerateTableKey(2)))
+	c.Assert(mc.Check(s.regions[2]), IsNil)
+	c.Assert(s.mc.Check(s.regions[2]), NotNil)
+
+	// The regions are in the same table.
+	setBoundaries(table.EncodeBytes(table.GenerateTableKey(2)), table.EncodeBytes(table.GenerateRowKey(2, 100)))
+	ops := mc.Check(s.regions[2])
+	c.Assert(ops, NotNil)
+	c.Assert(ops[1].RegionID(), Equals, s.regions[1].GetID())
+}
+
+func (s *testMergeCheckerSuite) TestEmptyRegionMerge(c *C) {
+	s.cluster.ScheduleOptions.MaxMergeRegionSize = 0
+	s.cluster.ScheduleOptions.MaxMergeRegionKeys = 0
+	s.cluster.ScheduleOptions.LongEmptyRegionDuration = time.Millisecond
+	s.regions[2] = s.regions[2].Clone(core.SetApproximateKeys(0))
+	s.cluster.PutRegion(s.regions[2])
+	s.mc.RecordRegionSplit(s.regions[2].GetID())
+	c.Assert(s.mc.Check(s.regions[2]), IsNil)
+
+	// The region is not empty for long enough.
+	s.cluster.ScheduleOptions.EnableEmptyRegionMerge = true
+	s.cluster.EmptyRegions.Observe(s.regions[2])
+	c.Assert(s.cluster.IsRegionLongEmpty(s.regions[2]), IsFalse)
+	c.Assert(s.mc.Check(s.regions[2]), IsNil)
+
+	// The long empty region is merged even if it is split recently and
+	// merging is disabled.
+	time.Sleep(10 * time.Millisecond)
+	c.Assert(s.cluster.IsRegionLongEmpty(s.regions[2]), IsTrue)
+	ops := s.mc.Check(s.regions[2])
+	c.Assert(ops, NotNil)
+	c.Assert(ops[0].RegionID(), Equals, s.regions[2].GetID())
+	c.Assert(ops[1].RegionID(), Equals, s.regions[1].GetID())
+
+	s.cluster.ScheduleOptions.EnableEmptyRegionMerge = false
+	c.Assert(s.mc.Check(s.regions[2]), IsNil)
+
+	// The region is not empty any more.
+	s.cluster.ScheduleOptions.EnableEmptyRegionMerge = true
+	s.regions[2] = s.regions[2].Clone(core.SetApproximateKeys(1))
+	s.cluster.PutRegion(s.regions[2])
+	s.cluster.EmptyRegions.Observe(s.regions[2])
+	c.Assert(s.mc.Check(s.regions[2]), IsNil)
+}
+
+func (s *testMergeCheckerSuite) TestMergeChain(c *C) {
+	s.cluster.ScheduleOptions.MaxMergeRegionSize = 10
+	s.cluster.ScheduleOptions.MaxMergeRegionKeys = 10
+	s.cluster.ScheduleOptions.MaxMergeChainLength = 4
+	keys := []string{"", "a", "b", "c", "d", ""}
+	regions := make([]*core.RegionInfo, 0, len(keys)-1)
+	for i := 0; i < len(keys)-1; i++ {
+		id := uint64(11 + i)
+		region := core.NewRegionInfo(
+			&metapb.Region{
+				Id:          id,
+				StartKey:    []byte(keys[i]),
+				EndKey:      []byte(keys[i+1]),
+				RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: 1},
+				Peers: []*metapb.Peer{
+					{Id: id*10 + 1, StoreId: 1},
+					{Id: id*10 + 2, StoreId: 2},
+					{Id: id*10 + 3, StoreId: 3},
+				},
+			},
+			&metapb.Peer{Id: id*10 + 1, StoreId: 1},
+			core.SetApproximateSize(1),
+			core.SetApproximateKeys(1),
+		)
+		regions = append(regions, region)
+		s.cluster.PutRegion(region)
+	}
+
+	// Region 13 is merged into region 14, and then regions 12 and 11 are
+	// planned to be merged into region 14 in sequence.
+	ops := s.mc.Check(regions[2])
+	c.Assert(ops, NotNil)
+	c.Assert(ops[1].RegionID(), Equals, uint64(14))
+	c.Assert(s.mc.HasMergePlan(14), IsTrue)
+
+	merge := func(source, target *core.RegionInfo) *core.RegionInfo {
+		merged := target.Clone(core.WithStartKey(source.GetStartKey()), core.WithIncVersion(),
+			core.SetApproximateSize(source.GetApproximateSize()+target.GetApproximateSize()),
+			core.SetApproximateKeys(source.GetApproximateKeys()+target.GetApproximateKeys()))
+		s.cluster.PutRegion(merged)
+		return merged
+	}
+	target := merge(regions[2], regions[3])
+	ops = s.mc.Check(target)
+	c.Assert(ops, NotNil)
+	c.Assert(ops[0].Desc(), Equals, "merge-region-chain")
+	c.Assert(ops[0].RegionID(), Equals, uint64(12))
+	c.Assert(ops[1].RegionID(), Equals, uint64(14))
+
+	target = merge(regions[1], target)
+	ops = s.mc.Check(target)
+	c.Assert(ops, NotNil)
+	c.Assert(ops[0].Desc(), Equals, "merge-region-chain")
+	c.Assert(ops[0].RegionID(), Equals, uint64(11))
+
+	// The plan is finished after all sources are merged.
+	target = merge(regions[0], target)
+	ops = s.mc.Check(target)
+	c.Assert(s.mc.HasMergePlan(14), IsFalse)
+	c.Assert(ops, NotNil)
+	c.Assert(ops[0].Desc(), Equals, "merge-region")
+}
+
+func (s *testMergeCheckerSuite) TestMergeChainLimit(c *C) {
+	s.cluster.ScheduleOptions.MaxMergeRegionSize = 10
+	s.cluster.ScheduleOptions.MaxMergeRegionKeys = 10
+	keys := []string{"", "a", "b", "c", ""}
+	regions := make([]*core.RegionInfo, 0, len(keys)-1)
+	for i := 0; i < len(keys)-1; i++ {
+		id := uint64(11 + i)
+		region := core.NewRegionInfo(
+			&metapb.Region{
+				Id:       id,
+				StartKey: []byte(keys[i]),
+				EndKey:   []byte(keys[i+1]),
+				Peers: []*metapb.Peer{
+					{Id: id*10 + 1, StoreId: 1},
+					{Id: id*10 + 2, StoreId: 2},
+					{Id: id*10 + 3, StoreId: 3},
+				},
+			},
+			&metapb.Peer{Id: id*10 + 1, StoreId: 1},
+			core.SetApproximateSize(4),
+			core.SetApproximateKeys(1),
+		)
+		regions = append(regions, region)
+		s.cluster.PutRegion(region)
+	}
+
+	// Regions are merged in pairs by default.
+	c.Assert(s.mc.Check(regions[2]), NotNil)
+	c.Assert(s.mc.HasMergePlan(14), IsFalse)
+
+	// The chain stops before the merged region is too large.
+	s.cluster.ScheduleOptions.MaxMergeChainLength = 4
+	c.Assert(s.mc.Check(regions[2]), NotNil)
+	c.Assert(s.mc.HasMergePlan(14), IsFalse)
+	s.cluster.ScheduleOptions.MaxMergeRegionSize = 12
+	c.Assert(s.mc.Check(regions[2]), NotNil)
+	c.Assert(s.mc.HasMergePlan(14), IsTrue)
+
+	// The plan is dropped if the next source is no longer small.
+	target := regions[3].Clone(core.WithStartKey(regions[2].GetStartKey()), core.WithIncVersion(), core.SetApproximateSize(8))
+	s.cluster.PutRegion(target)
+	s.cluster.PutRegion(regions[1].Clone(core.SetApproximateSize(20)))
+	s.mc.Check(target)
+	c.Assert(s.mc.HasMergePlan(14), IsFalse)
+}
+
 func (s *testMergeCheckerSuite) checkSteps(c *C, op *operator.Operator, steps []operator.OpStep) {
 	c.Assert(op.Kind()&operator.OpMerge, Not(Equals), 0)
 	c.Assert(steps, NotNil)
@@ -80,7 +80,7 @@ func (n *NamespaceChecker) Check(region *core.RegionInfo) *operator.Operator {
 			checkerCounter.WithLabelValues("namespace_checker", "no-target-peer").Inc()
 			return nil
 		}
-		op, err := operator.CreateMovePeerOperator("make-namespace-relocation", n.cluster, region, operator.OpReplica, peer.GetStoreId(), newPeer.GetStoreId(), newPeer.GetId())
+		op, err := operator.CreateMovePeerOperator("make-namespace-relocation", n.cluster, region, operator.OpReplica|operator.OpNamespace, peer.GetStoreId(), newPeer.GetStoreId(), newPeer.GetId())
 		if err != nil {
 			checkerCounter.WithLabelValues("namespace_checker", "create-operator-fail").Inc()
 			return nil
@@ -67,16 +67,20 @@ func (r *ReplicaChecker) Check(region *core.RegionInfo) *operator.Operator {
 	checkerCounter.WithLabelValues("replica_checker", "check").Inc()
 	if op := r.checkDownPeer(region); op != nil {
 		checkerCounter.WithLabelValues("replica_checker", "new-operator").Inc()
-		op.SetPriorityLevel(core.HighPriority)
+		op.SetPriorityLevel(r.repairPriority(region, core.HighPriority))
 		return op
 	}
 	if op := r.checkOfflinePeer(region); op != nil {
 		checkerCounter.WithLabelValues("replica_checker", "new-operator").Inc()
-		op.SetPriorityLevel(core.HighPriority)
+		op.SetPriorityLevel(r.repairPriority(region, core.HighPriority))
+		return op
+	}
+	if op := r.checkLearnerOnlyVoter(region); op != nil {
+		checkerCounter.WithLabelValues("replica_checker", "new-operator").Inc()
 		return op
 	}
 
-	if len(region.GetPeers()) < r.cluster.GetMaxReplicas() && r.cluster.IsMakeUpReplicaEnabled() {
+	if r.countReplicas(region) < r.cluster.GetMaxReplicas() && r.cluster.IsMakeUpReplicaEnabled() {
 		log.Debug("region has fewer than max replicas", zap.Uint64("region-id", region.GetID()), zap.Int("peers", len(region.GetPeers())))
 		newPeer, _ := r.selectBestPeerToAddReplica(region, filter.NewStorageThresholdFilter(r.name))
 		if newPeer == nil {
@@ -84,7 +88,9 @@ func (r *ReplicaChecker) Check(region *core.RegionInfo) *operator.Operator {
 			return nil
 		}
 		checkerCounter.WithLabelValues("replica_checker", "new-operator").Inc()
-		return operator.CreateAddPeerOperator("make-up-replica", region, newPeer.GetId(), newPeer.GetStoreId(), operator.OpReplica)
+		op := operator.CreateAddPeerOperator("make-up-replica", region, newPeer.GetId(), newPeer.GetStoreId(), operator.OpReplica|operator.OpRepair)
+		op.SetPriorityLevel(r.repairPriority(region, core.NormalPriority))
+		return op
 	}
 
 	// when add learner peer, the number of peer will exceed max replicas for a while,
@@ -105,9 +111,72 @@ func (r *ReplicaChecker) Check(region *core.RegionInfo) *operator.Operator {
 		return op
 	}
 
+	// the peers of the region with placement intent are placed by the intent
+	// checker rather than by the location labels
+	if intent := r.cluster.GetPlacementIntent(region.GetID()); intent != nil && len(intent.StoreIDs) > 0 {
+		checkerCounter.WithLabelValues("replica_checker", "placement-intent").Inc()
+		return nil
+	}
 	return r.checkBestReplacement(region)
 }
 
+// isLearnerOnlyPeer checks if the peer is a learner on a learner-only store.
+// Such learners are not counted as replicas, and are never moved to hold
+// voters.
+func (r *ReplicaChecker) isLearnerOnlyPeer(peer *metapb.Peer) bool {
+	if !peer.GetIsLearner() {
+		return false
+	}
+	store := r.cluster.GetStore(peer.GetStoreId())
+	return store != nil && store.IsLearnerOnly()
+}
+
+// countReplicas returns the number of the peers of the region except the
+// learners on learner-only stores.
+func (r *ReplicaChecker) countReplicas(region *core.RegionInfo) int {
+	var count int
+	for _, peer := range region.GetPeers() {
+		if !r.isLearnerOnlyPeer(peer) {
+			count++
+		}
+	}
+	return count
+}
+
+// getReplicaStores returns the stores of the region except the learner-only
+// stores, which take no part in the isolation of replicas.
+func (r *ReplicaChecker) getReplicaStores(region *core.RegionInfo) []*core.StoreInfo {
+	stores := r.cluster.GetRegionStores(region)
+	replicaStores := stores[:0]
+	for _, store := range stores {
+		if !store.IsLearnerOnly() {
+			replicaStores = append(replicaStores, store)
+		}
+	}
+	return replicaStores
+}
+
+// repairPriority classifies the priority of the operator repairing the region.
+// It is UrgentPriority if the region is one failure away from losing quorum,
+// otherwise the given level.
+func (r *ReplicaChecker) repairPriority(region *core.RegionInfo, level core.PriorityLevel) core.PriorityLevel {
+	var healthy int
+	for _, peer := range region.GetVoters() {
+		if region.GetDownPeer(peer.GetId()) != nil {
+			continue
+		}
+		if store := r.cluster.GetStore(peer.GetStoreId()); store == nil || !store.IsUp() {
+			continue
+		}
+		healthy++
+	}
+	if healthy <= r.cluster.GetMaxReplicas()/2+1 {
+		checkerCounter.WithLabelValues("replica_checker", "urgent-operator").Inc()
+		return core.UrgentPriority
+	}
+	return level
+}
+
 // SelectBestReplacementStore returns a store id that to be used to replace the old peer and distinct score.
 func (r *ReplicaChecker) SelectBestReplacementStore(region *core.RegionInfo, oldPeer *metapb.Peer, filters ...filter.Filter) (uint64, float64) {
 	filters = append(filters, filter.NewExcludedFilter(r.name, nil, region.GetStoreIds()))
@@ -135,14 +204,18 @@ func (r *ReplicaChecker) selectBestStoreToAddReplica(region *core.RegionInfo, fi
 	newFilters := []filter.Filter{
 		filter.NewStateFilter(r.name),
 		filter.NewExcludedFilter(r.name, nil, region.GetStoreIds()),
+		filter.NewRegionCountFilter(r.name),
+		filter.NewMemoryPressureFilter(r.name),
+		filter.NewLearnerOnlyFilter(r.name),
 	}
 	filters = append(filters, r.filters...)
 	filters = append(filters, newFilters...)
 	if r.classifier != nil {
 		filters = append(filters, filter.NewNamespaceFilter(r.name, r.classifier, r.classifier.GetRegionNamespace(region)))
 	}
-	regionStores := r.cluster.GetRegionStores(region)
-	s := selector.NewReplicaSelector(regionStores, r.cluster.GetLocationLabels(), r.filters...)
+	regionStores := r.getReplicaStores(region)
+	// The snapshots are sent by the leader.
+	s := selector.NewSnapshotReplicaSelector(regionStores, r.cluster.GetLocationLabels(), r.cluster.GetStore(region.GetLeader().GetStoreId()), r.filters...)
 	target := s.SelectTarget(r.cluster, r.cluster.GetStores(), filters...)
 	if target == nil {
 		return 0, 0
@@ -152,7 +225,7 @@ func (r *ReplicaChecker) selectBestStoreToAddReplica(region *core.RegionInfo, fi
 
 // selectWorstPeer returns the worst peer in the region.
 func (r *ReplicaChecker) selectWorstPeer(region *core.RegionInfo) (*metapb.Peer, float64) {
-	regionStores := r.cluster.GetRegionStores(region)
+	regionStores := r.getReplicaStores(region)
 	s := selector.NewReplicaSelector(regionStores, r.cluster.GetLocationLabels(), r.filters...)
 	worstStore := s.SelectSource(r.cluster, regionStores)
 	if worstStore == nil {
@@ -184,8 +257,11 @@ func (r *ReplicaChecker) checkDownPeer(region *core.RegionInfo) *operator.Operat
 		if stats.GetDownSeconds() < uint64(r.cluster.GetMaxStoreDownTime().Seconds()) {
 			continue
 		}
+		if r.isLearnerOnlyPeer(peer) {
+			return r.removeLearner(region, peer, "down")
+		}
 
-		return r.fixPeer(region, peer, "down")
+		return r.fixPeer(region, peer, "down", operator.OpReplica|operator.OpRepair)
 	}
 	return nil
 }
@@ -195,9 +271,11 @@ func (r *ReplicaChecker) checkOfflinePeer(region *core.RegionInfo) *operator.Ope
 		return nil
 	}
 
-	// just skip learner
-	if len(region.GetLearners()) != 0 {
-		return nil
+	// just skip learner, except the ones on learner-only stores.
+	for _, peer := range region.GetLearners() {
+		if !r.isLearnerOnlyPeer(peer) {
+			return nil
+		}
 	}
 
 	for _, peer := range region.GetPeers() {
@@ -210,13 +288,39 @@ func (r *ReplicaChecker) checkOfflinePeer(region *core.RegionInfo) *operator.Ope
 		if store.IsUp() {
 			continue
 		}
+		if r.isLearnerOnlyPeer(peer) {
+			return r.removeLearner(region, peer, "offline")
+		}
 
-		return r.fixPeer(region, peer, "offline")
+		return r.fixPeer(region, peer, "offline", operator.OpReplica|operator.OpRepair)
 	}
 
 	return nil
 }
 
+// checkLearnerOnlyVoter moves the voters out of the learner-only stores.
+func (r *ReplicaChecker) checkLearnerOnlyVoter(region *core.RegionInfo) *operator.Operator {
+	for _, peer := range region.GetVoters() {
+		store := r.cluster.GetStore(peer.GetStoreId())
+		if store != nil && store.IsLearnerOnly() {
+			return r.fixPeer(region, peer, "learner-only", operator.OpReplica)
+		}
+	}
+	return nil
+}
+
+// removeLearner removes the down or offline learner on a learner-only store,
+// which is not replaced since the learners there are not managed as replicas.
+func (r *ReplicaChecker) removeLearner(region *core.RegionInfo, peer *metapb.Peer, status string) *operator.Operator {
+	desc := fmt.Sprintf("remove-%s-learner", status)
+	op, err := operator.CreateRemovePeerOperator(desc, r.cluster, operator.OpReplica|operator.OpRepair, region, peer.GetStoreId())
+	if err != nil {
+		checkerCounter.WithLabelValues("replica_checker", fmt.Sprintf("%s-fail", desc)).Inc()
+		return nil
+	}
+	return op
+}
+
 func (r *ReplicaChecker) checkBestReplacement(region *core.RegionInfo) *operator.Operator {
 	if !r.cluster.IsLocationReplacementEnabled() {
 		return nil
@@ -242,20 +346,47 @@ func (r *ReplicaChecker) checkBestReplacement(region *core.RegionInfo) *operator
 	if err != nil {
 		return nil
 	}
-	op, err := operator.CreateMovePeerOperator("move-to-better-location", r.cluster, region, operator.OpReplica, oldPeer.GetStoreId(), newPeer.GetStoreId(), newPeer.GetId())
+	// The repairs of the violations carry the location labels, so that they
+	// are limited separately by the coordinator.
+	desc, kind := "move-to-better-location", operator.OpReplica
+	violation := r.CheckLocation(region)
+	if violation != nil {
+		desc, kind = locationRepairDescPrefix+violation.Label, kind|operator.OpLocation
+	}
+	op, err := operator.CreateMovePeerOperator(desc, r.cluster, region, kind, oldPeer.GetStoreId(), newPeer.GetStoreId(), newPeer.GetId())
 	if err != nil {
 		checkerCounter.WithLabelValues("replica_checker", "create-operator-fail").Inc()
 		return nil
 	}
+	if violation != nil {
+		op.SetLocationLabel(violation.Label)
+		op.SetPriorityLevel(locationRepairPriority(violation, r.cluster.GetLocationLabels()))
+	}
 	checkerCounter.WithLabelValues("replica_checker", "new-operator").Inc()
 	return op
 }
 
-func (r *ReplicaChecker) fixPeer(region *core.RegionInfo, peer *metapb.Peer, status string) *operator.Operator {
+// selectReplacementStore selects the store to replace the peer. The preferred
+// evacuate targets of the peer's store are tried first.
+func (r *ReplicaChecker) selectReplacementStore(region *core.RegionInfo, peer *metapb.Peer) uint64 {
+	if targets := r.cluster.GetEvacuateTargets(peer.GetStoreId()); len(targets) > 0 {
+		storeID, _ := r.SelectBestReplacementStore(region, peer, filter.NewStorageThresholdFilter(r.name), filter.NewEvacuateTargetFilter(r.name, targets))
+		if storeID != 0 {
+			return storeID
+		}
+		checkerCounter.WithLabelValues("replica_checker", "no-evacuate-target").Inc()
+	}
+	storeID, _ := r.SelectBestReplacementStore(region, peer, filter.NewStorageThresholdFilter(r.name))
+	return storeID
+}
+
+// fixPeer removes or replaces the peer of the status with the operators of the
+// kind, which carries OpRepair for the down and offline peers.
+func (r *ReplicaChecker) fixPeer(region *core.RegionInfo, peer *metapb.Peer, status string, kind operator.OpKind) *operator.Operator {
 	removeExtra := fmt.Sprintf("remove-extra-%s-replica", status)
 	// Check the number of replicas first.
-	if len(region.GetPeers()) > r.cluster.GetMaxReplicas() {
-		op, err := operator.CreateRemovePeerOperator(removeExtra, r.cluster, operator.OpReplica, region, peer.GetStoreId())
+	if r.countReplicas(region) > r.cluster.GetMaxReplicas() {
+		op, err := operator.CreateRemovePeerOperator(removeExtra, r.cluster, kind, region, peer.GetStoreId())
 		if err != nil {
 			reason := fmt.Sprintf("%s-fail", removeExtra)
 			checkerCounter.WithLabelValues("replica_checker", reason).Inc()
@@ -264,7 +395,7 @@ func (r *ReplicaChecker) fixPeer(region *core.RegionInfo, peer *metapb.Peer, sta
 		return op
 	}
 
-	storeID, _ := r.SelectBestReplacementStore(region, peer, filter.NewStorageThresholdFilter(r.name))
+	storeID := r.selectReplacementStore(region, peer)
 	if storeID == 0 {
 		reason := fmt.Sprintf("no-store-%s", status)
 		checkerCounter.WithLabelValues("replica_checker", reason).Inc()
@@ -277,7 +408,7 @@ func (r *ReplicaChecker) fixPeer(region *core.RegionInfo, peer *metapb.Peer, sta
 	}
 
 	replace := fmt.Sprintf("replace-%s-replica", status)
-	op, err := operator.CreateMovePeerOperator(replace, r.cluster, region, operator.OpReplica, peer.GetStoreId(), newPeer.GetStoreId(), newPeer.GetId())
+	op, err := operator.CreateMovePeerOperator(replace, r.cluster, region, kind, peer.GetStoreId(), newPeer.GetStoreId(), newPeer.GetId())
 	if err != nil {
 		reason := fmt.Sprintf("%s-fail", replace)
 		checkerCounter.WithLabelValues("replica_checker", reason).Inc()
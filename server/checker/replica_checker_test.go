@@ -107,3 +107,96 @@ func (s *testReplicaCheckerSuite) TestReplacePendingPeer(c *C) {
 	c.Assert(op.Step(1).(operator.PromoteLearner).ToStore, Equals, uint64(4))
 	c.Assert(op.Step(2).(operator.RemovePeer).FromStore, Equals, uint64(1))
 }
+
+func (s *testReplicaCheckerSuite) TestRepairPriority(c *C) {
+	cfg := mockoption.NewScheduleOptions()
+	cfg.MaxReplicas = 5
+	s.cluster = mockcluster.NewCluster(cfg)
+	s.rc = NewReplicaChecker(s.cluster, namespace.DefaultClassifier)
+	for i := uint64(1); i <= 7; i++ {
+		s.cluster.AddRegionStore(i, 1)
+	}
+	s.cluster.AddLeaderRegion(1, 1, 2, 3, 4, 5)
+
+	// One offline replica of five still tolerates another failure.
+	s.cluster.SetStoreOffline(5)
+	op := s.rc.Check(s.cluster.GetRegion(1))
+	c.Assert(op, NotNil)
+	c.Assert(op.GetPriorityLevel(), Equals, core.HighPriority)
+
+	// Losing one more replica will lose the quorum.
+	s.cluster.SetStoreOffline(4)
+	op = s.rc.Check(s.cluster.GetRegion(1))
+	c.Assert(op, NotNil)
+	c.Assert(op.GetPriorityLevel(), Equals, core.UrgentPriority)
+
+	// Making up replicas of a region with three peers.
+	s.cluster.AddLeaderRegion(2, 1, 2, 3)
+	op = s.rc.Check(s.cluster.GetRegion(2))
+	c.Assert(op, NotNil)
+	c.Assert(op.Desc(), Equals, "make-up-replica")
+	c.Assert(op.GetPriorityLevel(), Equals, core.UrgentPriority)
+	s.cluster.AddLeaderRegion(3, 1, 2, 3, 6)
+	op = s.rc.Check(s.cluster.GetRegion(3))
+	c.Assert(op, NotNil)
+	c.Assert(op.GetPriorityLevel(), Equals, core.NormalPriority)
+}
+
+func (s *testReplicaCheckerSuite) TestEvacuateTargets(c *C) {
+	s.cluster.AddRegionStore(1, 10)
+	s.cluster.AddRegionStore(2, 10)
+	s.cluster.AddRegionStore(3, 10)
+	s.cluster.AddRegionStore(4, 20)
+	s.cluster.AddRegionStore(5, 10)
+	s.cluster.AddLeaderRegion(1, 1, 2, 3)
+	s.cluster.SetStoreOffline(1)
+
+	op := s.rc.Check(s.cluster.GetRegion(1))
+	c.Assert(op, NotNil)
+	c.Assert(op.Step(0).(operator.AddLearner).ToStore, Equals, uint64(5))
+
+	s.cluster.SetEvacuateTargets(1, 4)
+	op = s.rc.Check(s.cluster.GetRegion(1))
+	c.Assert(op, NotNil)
+	c.Assert(op.Step(0).(operator.AddLearner).ToStore, Equals, uint64(4))
+
+	// Falls back to other stores if the preferred targets are unavailable.
+	s.cluster.SetStoreOffline(4)
+	op = s.rc.Check(s.cluster.GetRegion(1))
+	c.Assert(op, NotNil)
+	c.Assert(op.Step(0).(operator.AddLearner).ToStore, Equals, uint64(5))
+}
+
+func (s *testReplicaCheckerSuite) TestLearnerOnlyStore(c *C) {
+	s.cluster.AddRegionStore(1, 10)
+	s.cluster.AddRegionStore(2, 10)
+	s.cluster.AddRegionStore(3, 10)
+	s.cluster.AddLabelsStore(4, 0, map[string]string{core.StoreRoleLabelKey: core.StoreRoleLearnerOnly})
+
+	// The learner-only store never receives voters even if it is the best.
+	s.cluster.AddLeaderRegion(1, 1, 2)
+	op := s.rc.Check(s.cluster.GetRegion(1))
+	c.Assert(op, NotNil)
+	c.Assert(op.Desc(), Equals, "make-up-replica")
+	c.Assert(op.Step(0).(operator.AddLearner).ToStore, Equals, uint64(3))
+
+	// The learners on the learner-only store are not counted as replicas.
+	s.cluster.AddLeaderRegion(2, 1, 2, 3)
+	learner := &metapb.Peer{Id: 100, StoreId: 4, IsLearner: true}
+	s.cluster.PutRegion(s.cluster.GetRegion(2).Clone(core.WithAddPeer(learner)))
+	c.Assert(s.rc.Check(s.cluster.GetRegion(2)), IsNil)
+
+	// The voters on the learner-only store are moved out.
+	s.cluster.AddLeaderRegion(3, 1, 2, 4)
+	op = s.rc.Check(s.cluster.GetRegion(3))
+	c.Assert(op, NotNil)
+	c.Assert(op.Desc(), Equals, "replace-learner-only-replica")
+	c.Assert(op.Step(0).(operator.AddLearner).ToStore, Equals, uint64(3))
+
+	// The learners on the offline learner-only store are removed.
+	s.cluster.SetStoreOffline(4)
+	op = s.rc.Check(s.cluster.GetRegion(2))
+	c.Assert(op, NotNil)
+	c.Assert(op.Desc(), Equals, "remove-offline-learner")
+	c.Assert(op.Step(0).(operator.RemovePeer).FromStore, Equals, uint64(4))
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/schedule"
+	"github.com/pingcap/pd/server/schedule/filter"
+	"github.com/pingcap/pd/server/schedule/labeler"
+	"github.com/pingcap/pd/server/schedule/operator"
+)
+
+const tierLeaderCheckerName = "tier-leader-checker"
+
+// TierLeaderChecker keeps leaders of hot-serving ranges off storage stores.
+// Leaders of cold ranges and unlabeled ranges are allowed on storage stores,
+// so they are left to the balance schedulers, unless the unlabeled regions are
+// warm. The voters of cold ranges on performance stores switch roles with the
+// learners on storage stores, so that the cold data is served by the storage
+// tier without moving it, unless the regions are still warm.
+type TierLeaderChecker struct {
+	cluster schedule.Cluster
+	labeler *labeler.RangeLabeler
+	filters []filter.Filter
+}
+
+// NewTierLeaderChecker creates a tier leader checker.
+func NewTierLeaderChecker(cluster schedule.Cluster, labeler *labeler.RangeLabeler) *TierLeaderChecker {
+	return &TierLeaderChecker{
+		cluster: cluster,
+		labeler: labeler,
+		filters: []filter.Filter{
+			filter.StoreStateFilter{ActionScope: tierLeaderCheckerName, TransferLeader: true},
+		},
+	}
+}
+
+// Check verifies the tier of a region's leader, creating an Operator if need.
+func (t *TierLeaderChecker) Check(region *core.RegionInfo) *operator.Operator {
+	if t.labeler == nil {
+		return nil
+	}
+	hotServing, cold := t.labeler.HasLabel(region, labeler.HotServing), t.labeler.HasLabel(region, labeler.Cold)
+	if cold && !hotServing {
+		if t.cluster.IsRegionWarm(region) {
+			checkerCounter.WithLabelValues("tier_leader_checker", "warm-cold-region").Inc()
+			return nil
+		}
+		return t.checkCold(region)
+	}
+	leaderStore := t.cluster.GetLeaderStore(region)
+	if leaderStore == nil || !leaderStore.IsStorageType() {
+		return nil
+	}
+	checkerCounter.WithLabelValues("tier_leader_checker", "check").Inc()
+	if !hotServing && (cold || !t.cluster.IsRegionWarm(region)) {
+		return nil
+	}
+
+	filters := append([]filter.Filter{filter.NewLeaderFractionFilter(tierLeaderCheckerName, t.cluster.GetStores())}, t.filters...)
+	var target *core.StoreInfo
+	for _, store := range t.cluster.GetFollowerStores(region) {
+		if store.IsStorageType() || filter.Target(t.cluster, store, filters) {
+			continue
+		}
+		if target == nil || store.LeaderScore(0) < target.LeaderScore(0) {
+			target = store
+		}
+	}
+	if target == nil {
+		checkerCounter.WithLabelValues("tier_leader_checker", "no-target-store").Inc()
+		return nil
+	}
+	checkerCounter.WithLabelValues("tier_leader_checker", "new-operator").Inc()
+	return operator.CreateTransferLeaderOperator("demote-storage-leader", region, leaderStore.GetID(), target.GetID(), operator.OpLeader)
+}
+
+// checkCold switches the roles of a learner on a storage store and a voter on
+// a performance store of the cold region. The followers are demoted before
+// the leader, which saves a leader transfer.
+func (t *TierLeaderChecker) checkCold(region *core.RegionInfo) *operator.Operator {
+	var learner, voter *metapb.Peer
+	for _, p := range region.GetLearners() {
+		store := t.cluster.GetStore(p.GetStoreId())
+		if store == nil || !store.IsStorageType() || store.IsLearnerOnly() || !store.IsUp() || store.IsDisconnected() {
+			continue
+		}
+		if region.GetPendingLearner(p.GetId()) == nil {
+			learner = p
+			break
+		}
+	}
+	if learner == nil {
+		return nil
+	}
+	checkerCounter.WithLabelValues("tier_leader_checker", "check-cold").Inc()
+	for _, p := range region.GetVoters() {
+		store := t.cluster.GetStore(p.GetStoreId())
+		if store == nil || store.IsStorageType() {
+			continue
+		}
+		if voter == nil || p.GetId() != region.GetLeader().GetId() {
+			voter = p
+		}
+	}
+	if voter == nil {
+		return nil
+	}
+	op, err := operator.CreateSwitchRoleOperator("cold-switch-role", t.cluster, region, learner, voter)
+	if err != nil {
+		checkerCounter.WithLabelValues("tier_leader_checker", "create-operator-fail").Inc()
+		return nil
+	}
+	checkerCounter.WithLabelValues("tier_leader_checker", "new-operator").Inc()
+	return op
+}
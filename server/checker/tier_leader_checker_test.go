@@ -0,0 +1,138 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/pkg/mock/mockcluster"
+	"github.com/pingcap/pd/pkg/mock/mockoption"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/schedule/labeler"
+	"github.com/pingcap/pd/server/schedule/operator"
+)
+
+var _ = Suite(&testTierLeaderCheckerSuite{})
+
+type testTierLeaderCheckerSuite struct{}
+
+func (s *testTierLeaderCheckerSuite) TestCheck(c *C) {
+	cluster := mockcluster.NewCluster(mockoption.NewScheduleOptions())
+	storage := map[string]string{core.StoreTypeLabelKey: string(core.StoreTypeStorage)}
+	cluster.AddLabelsStore(1, 0, storage)
+	cluster.AddLabelsStore(2, 0, storage)
+	cluster.AddLabelsStore(3, 0, nil)
+	cluster.AddLabelsStore(4, 0, nil)
+
+	l, err := labeler.NewRangeLabeler(nil)
+	c.Assert(err, IsNil)
+	c.Assert(l.SetLabelRule(&labeler.LabelRule{ID: "hot", Label: labeler.HotServing, StartKey: "61", EndKey: "63"}), IsNil)
+	c.Assert(l.SetLabelRule(&labeler.LabelRule{ID: "cold", Label: labeler.Cold, StartKey: "63", EndKey: "64"}), IsNil)
+	checker := NewTierLeaderChecker(cluster, l)
+
+	// The leader of a hot-serving range is moved to a performance store.
+	cluster.AddLeaderRegionWithRange(1, "a", "b", 1, 2, 3)
+	op := checker.Check(cluster.GetRegion(1))
+	c.Assert(op, NotNil)
+	c.Assert(op.Step(0), Equals, operator.TransferLeader{FromStore: 1, ToStore: 3})
+	c.Assert(op.Kind()&operator.OpLeader, Not(Equals), operator.OpKind(0))
+
+	// No performance follower.
+	cluster.AddLeaderRegionWithRange(2, "a", "b", 1, 2)
+	c.Assert(checker.Check(cluster.GetRegion(2)), IsNil)
+
+	// The leader is already on a performance store.
+	cluster.AddLeaderRegionWithRange(3, "a", "b", 3, 1, 2)
+	c.Assert(checker.Check(cluster.GetRegion(3)), IsNil)
+
+	// Leaders of cold and unlabeled ranges are allowed on storage stores.
+	cluster.AddLeaderRegionWithRange(4, "c", "d", 1, 2, 3)
+	c.Assert(checker.Check(cluster.GetRegion(4)), IsNil)
+	cluster.AddLeaderRegionWithRange(5, "x", "y", 1, 2, 3)
+	c.Assert(checker.Check(cluster.GetRegion(5)), IsNil)
+
+	// The region is not fully covered by the hot-serving range.
+	cluster.AddLeaderRegionWithRange(6, "b", "d", 1, 2, 3)
+	c.Assert(checker.Check(cluster.GetRegion(6)), IsNil)
+
+	// The leader of a warm unlabeled region is moved to a performance store,
+	// but the cold label wins over the warmth.
+	cluster.AddLeaderRegionWithRange(4, "c", "d", 1, 2, 3)
+	for i := uint64(0); i < cluster.WarmRegionAccessThreshold; i++ {
+		cluster.AccessSketch.Observe(4)
+		cluster.AccessSketch.Observe(5)
+	}
+	c.Assert(checker.Check(cluster.GetRegion(4)), IsNil)
+	op = checker.Check(cluster.GetRegion(5))
+	c.Assert(op, NotNil)
+	c.Assert(op.Step(0), Equals, operator.TransferLeader{FromStore: 1, ToStore: 3})
+}
+
+func (s *testTierLeaderCheckerSuite) TestCheckCold(c *C) {
+	cluster := mockcluster.NewCluster(mockoption.NewScheduleOptions())
+	storage := map[string]string{core.StoreTypeLabelKey: string(core.StoreTypeStorage)}
+	cluster.AddLabelsStore(1, 0, nil)
+	cluster.AddLabelsStore(2, 0, nil)
+	cluster.AddLabelsStore(3, 0, storage)
+	cluster.AddLabelsStore(4, 0, storage)
+
+	l, err := labeler.NewRangeLabeler(nil)
+	c.Assert(err, IsNil)
+	c.Assert(l.SetLabelRule(&labeler.LabelRule{ID: "cold", Label: labeler.Cold, StartKey: "63", EndKey: "64"}), IsNil)
+	checker := NewTierLeaderChecker(cluster, l)
+	addLearner := func(region *core.RegionInfo, storeID uint64) *core.RegionInfo {
+		region = region.Clone(core.WithAddPeer(&metapb.Peer{Id: 100 + storeID, StoreId: storeID, IsLearner: true}))
+		cluster.PutRegion(region)
+		return region
+	}
+
+	// The follower on the performance store switches roles with the learner
+	// on the storage store.
+	cluster.AddLeaderRegionWithRange(1, "c", "d", 1, 2, 4)
+	region := addLearner(cluster.GetRegion(1), 3)
+	op := checker.Check(region)
+	c.Assert(op, NotNil)
+	c.Assert(op.Len(), Equals, 2)
+	c.Assert(op.Step(0), Equals, operator.PromoteLearner{ToStore: 3, PeerID: 103})
+	c.Assert(op.Step(1), Equals, operator.DemoteVoter{ToStore: 2, PeerID: region.GetStorePeer(2).GetId()})
+
+	// The leader is demoted after the leadership is transferred.
+	cluster.AddLeaderRegionWithRange(2, "c", "d", 1, 4)
+	region = addLearner(cluster.GetRegion(2), 3)
+	op = checker.Check(region)
+	c.Assert(op, NotNil)
+	c.Assert(op.Step(0), Equals, operator.PromoteLearner{ToStore: 3, PeerID: 103})
+	c.Assert(op.Step(op.Len()-1), Equals, operator.DemoteVoter{ToStore: 1, PeerID: region.GetLeader().GetId()})
+
+	// The pending learner is not promoted.
+	region = region.Clone(core.WithPendingPeers([]*metapb.Peer{region.GetStorePeer(3)}))
+	c.Assert(checker.Check(region), IsNil)
+
+	// No voter on performance stores.
+	cluster.AddLeaderRegionWithRange(3, "c", "d", 4)
+	c.Assert(checker.Check(addLearner(cluster.GetRegion(3), 3)), IsNil)
+
+	// The learners of unlabeled ranges are left alone.
+	cluster.AddLeaderRegionWithRange(4, "x", "y", 1, 2)
+	c.Assert(checker.Check(addLearner(cluster.GetRegion(4), 3)), IsNil)
+
+	// The warm regions of cold ranges are kept on performance stores.
+	cluster.AddLeaderRegionWithRange(1, "c", "d", 1, 2, 4)
+	region = addLearner(cluster.GetRegion(1), 3)
+	c.Assert(checker.Check(region), NotNil)
+	for i := uint64(0); i < cluster.WarmRegionAccessThreshold; i++ {
+		cluster.AccessSketch.Observe(1)
+	}
+	c.Assert(checker.Check(region), IsNil)
+}
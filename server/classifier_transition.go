@@ -0,0 +1,238 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/pkg/typeutil"
+	"github.com/pingcap/pd/server/namespace"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// classifierTransitionCheckInterval is the interval to check if the grace
+// period of the classifier transition has passed.
+const classifierTransitionCheckInterval = time.Second
+
+// ClassifierTransition is a switch of the namespace classifier at runtime.
+// Both classifiers are evaluated during the grace period to report the
+// divergences, while only the classifier switched from is in effect. The
+// classifier switched to is cut over when the grace period passes.
+type ClassifierTransition struct {
+	From        string            `json:"from"`
+	To          string            `json:"to"`
+	Args        map[string]string `json:"args,omitempty"`
+	StartTime   time.Time         `json:"start_time"`
+	GracePeriod typeutil.Duration `json:"grace_period"`
+}
+
+// CutOverTime returns the time to cut over to the classifier switched to.
+func (t *ClassifierTransition) CutOverTime() time.Time {
+	return t.StartTime.Add(t.GracePeriod.Duration)
+}
+
+// ClassifierTransitionStatus is a transition with the stores and regions
+// classified differently by the two classifiers. At most the limited number
+// of divergent regions are listed.
+type ClassifierTransitionStatus struct {
+	*ClassifierTransition
+	CutOverTime          time.Time               `json:"cut_over_time"`
+	DivergentStores      []*namespace.Divergence `json:"divergent_stores"`
+	DivergentRegionCount int                     `json:"divergent_region_count"`
+	DivergentRegions     []*namespace.Divergence `json:"divergent_regions"`
+}
+
+// classifierState is the classifier switched at runtime, which is persisted
+// to override the classifier in the configuration.
+type classifierState struct {
+	Name       string                `json:"name"`
+	Args       map[string]string     `json:"args,omitempty"`
+	Transition *ClassifierTransition `json:"transition,omitempty"`
+}
+
+// classifierManager tracks the name and the transition of the classifier.
+type classifierManager struct {
+	sync.Mutex
+	state classifierState
+}
+
+func (s *Server) createClassifier(name string, args map[string]string) (namespace.Classifier, error) {
+	return namespace.CreateClassifier(name, s.storage, s.idAllocator, args)
+}
+
+// loadClassifierState switches to the classifier persisted by the leader, in
+// case the classifier was switched when the server was not the leader.
+func (s *Server) loadClassifierState() error {
+	s.classifierMgr.Lock()
+	defer s.classifierMgr.Unlock()
+	var state classifierState
+	ok, err := s.storage.LoadClassifierState(&state)
+	if err != nil || !ok {
+		return err
+	}
+	if state.Name != s.classifierMgr.state.Name || !reflect.DeepEqual(state.Args, s.classifierMgr.state.Args) {
+		current, err := s.createClassifier(state.Name, state.Args)
+		if err != nil {
+			return err
+		}
+		s.classifier.SetCurrent(current)
+	}
+	if state.Transition == nil {
+		s.classifier.SetNext(nil)
+	} else if t := s.classifierMgr.state.Transition; t == nil || t.To != state.Transition.To || !t.StartTime.Equal(state.Transition.StartTime) {
+		next, err := s.createClassifier(state.Transition.To, state.Transition.Args)
+		if err != nil {
+			return err
+		}
+		s.classifier.SetNext(next)
+	}
+	s.classifierMgr.state = state
+	return nil
+}
+
+// GetClassifierName returns the name of the classifier in effect.
+func (s *Server) GetClassifierName() string {
+	s.classifierMgr.Lock()
+	defer s.classifierMgr.Unlock()
+	return s.classifierMgr.state.Name
+}
+
+// StartClassifierTransition starts switching to the classifier created with
+// the args, which is cut over after the grace period.
+func (s *Server) StartClassifierTransition(name string, args map[string]string, gracePeriod time.Duration) (*ClassifierTransition, error) {
+	if gracePeriod < 0 {
+		return nil, errcode.NewInvalidInputErr(errors.New("grace period should not be negative"))
+	}
+	s.classifierMgr.Lock()
+	defer s.classifierMgr.Unlock()
+	state := s.classifierMgr.state
+	if state.Transition != nil {
+		return nil, errcode.NewInvalidInputErr(errors.Errorf("the transition to classifier %s is in progress", state.Transition.To))
+	}
+	next, err := s.createClassifier(name, args)
+	if err != nil {
+		return nil, errcode.NewInvalidInputErr(err)
+	}
+	if err = next.ReloadNamespaces(); err != nil {
+		return nil, err
+	}
+	state.Transition = &ClassifierTransition{
+		From:        state.Name,
+		To:          name,
+		Args:        args,
+		StartTime:   time.Now(),
+		GracePeriod: typeutil.NewDuration(gracePeriod),
+	}
+	if err = s.storage.SaveClassifierState(&state); err != nil {
+		return nil, err
+	}
+	s.classifierMgr.state = state
+	s.classifier.SetNext(next)
+	log.Info("classifier transition started",
+		zap.String("from", state.Transition.From),
+		zap.String("to", name),
+		zap.Duration("grace-period", gracePeriod))
+	return state.Transition, nil
+}
+
+// GetClassifierTransition returns the transition in progress with the
+// divergences, or nil if there is no transition.
+func (s *Server) GetClassifierTransition(regionLimit int) *ClassifierTransitionStatus {
+	s.classifierMgr.Lock()
+	transition := s.classifierMgr.state.Transition
+	s.classifierMgr.Unlock()
+	if transition == nil {
+		return nil
+	}
+	status := &ClassifierTransitionStatus{
+		ClassifierTransition: transition,
+		CutOverTime:          transition.CutOverTime(),
+	}
+	cluster := s.GetRaftCluster()
+	if cluster == nil {
+		return status
+	}
+	status.DivergentStores = s.classifier.DiffStores(cluster.GetStores())
+	regions := s.classifier.DiffRegions(cluster.GetRegions())
+	status.DivergentRegionCount = len(regions)
+	if regionLimit > 0 && len(regions) > regionLimit {
+		regions = regions[:regionLimit]
+	}
+	status.DivergentRegions = regions
+	return status
+}
+
+// CutOverClassifier makes the classifier switched to in effect at once,
+// without waiting for the grace period.
+func (s *Server) CutOverClassifier() error {
+	s.classifierMgr.Lock()
+	defer s.classifierMgr.Unlock()
+	return s.cutOverClassifierLocked()
+}
+
+func (s *Server) cutOverClassifierLocked() error {
+	transition := s.classifierMgr.state.Transition
+	if transition == nil {
+		return errcode.NewNotFoundErr(errors.New("no classifier transition in progress"))
+	}
+	state := classifierState{Name: transition.To, Args: transition.Args}
+	if err := s.storage.SaveClassifierState(&state); err != nil {
+		return err
+	}
+	if err := s.classifier.CutOver(); err != nil {
+		return err
+	}
+	s.classifierMgr.state = state
+	log.Info("classifier is cut over", zap.String("from", transition.From), zap.String("to", transition.To))
+	return nil
+}
+
+// AbortClassifierTransition stops the transition, keeping the classifier
+// switched from in effect.
+func (s *Server) AbortClassifierTransition() error {
+	s.classifierMgr.Lock()
+	defer s.classifierMgr.Unlock()
+	transition := s.classifierMgr.state.Transition
+	if transition == nil {
+		return errcode.NewNotFoundErr(errors.New("no classifier transition in progress"))
+	}
+	state := s.classifierMgr.state
+	state.Transition = nil
+	if err := s.storage.SaveClassifierState(&state); err != nil {
+		return err
+	}
+	s.classifier.SetNext(nil)
+	s.classifierMgr.state = state
+	log.Info("classifier transition aborted", zap.String("from", transition.From), zap.String("to", transition.To))
+	return nil
+}
+
+// checkClassifierTransition cuts over the classifier if the grace period of
+// the transition has passed.
+func (s *Server) checkClassifierTransition() {
+	s.classifierMgr.Lock()
+	defer s.classifierMgr.Unlock()
+	transition := s.classifierMgr.state.Transition
+	if transition == nil || time.Now().Before(transition.CutOverTime()) {
+		return
+	}
+	if err := s.cutOverClassifierLocked(); err != nil {
+		log.Error("failed to cut over the classifier", zap.String("to", transition.To), zap.Error(err))
+	}
+}
@@ -14,8 +14,10 @@
 package server
 
 import (
+	"bytes"
 	"fmt"
 	"path"
+	"sort"
 	"sync"
 	"time"
 
@@ -27,13 +29,17 @@ import (
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/pingcap/log"
 	"github.com/pingcap/pd/pkg/logutil"
+	"github.com/pingcap/pd/pkg/netutil"
 	"github.com/pingcap/pd/pkg/typeutil"
 	"github.com/pingcap/pd/server/config"
 	"github.com/pingcap/pd/server/core"
 	"github.com/pingcap/pd/server/id"
+	"github.com/pingcap/pd/server/keydecoder"
 	"github.com/pingcap/pd/server/namespace"
 	syncer "github.com/pingcap/pd/server/region_syncer"
 	"github.com/pingcap/pd/server/schedule"
+	"github.com/pingcap/pd/server/schedule/labeler"
+	"github.com/pingcap/pd/server/schedulers"
 	"github.com/pingcap/pd/server/statistics"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
@@ -42,6 +48,8 @@ import (
 var (
 	backgroundJobInterval      = time.Minute
 	defaultChangedRegionsLimit = 10000
+
+	errSchedulingHalted = errcode.NewCodedError(errors.New("scheduling is halted or paused"), core.OperatorConflictCode)
 )
 
 // RaftCluster is used for cluster config management.
@@ -69,12 +77,71 @@ type RaftCluster struct {
 	id      id.Allocator
 
 	prepareChecker *prepareChecker
+	haltChecker    *haltChecker
+	hbAdvisor      *heartbeatIntervalAdvisor
+	hbAdmission    *heartbeatAdmission
 	changedRegions chan *core.RegionInfo
 
 	labelLevelStats *statistics.LabelStatistics
 	regionStats     *statistics.RegionStatistics
 	storesStats     *statistics.StoresStats
 	hotSpotCache    *statistics.HotSpotCache
+	accessSketch    *statistics.AccessSketch
+	emptyRegions    *statistics.EmptyRegionTracker
+	storeStatsHub   *statistics.StoreStatsBroadcaster
+	labeler         *labeler.RangeLabeler
+	keyDecoder      keydecoder.KeyDecoder
+	// events publishes the changes of regions and stores to the checkers and
+	// schedulers.
+	events *schedule.EventBus
+	// evacuateTargets maps stores to the preferred targets to evacuate them.
+	evacuateTargets map[uint64][]uint64
+	// decommissionSchedules maps removed stores to the windows to evacuate
+	// them.
+	decommissionSchedules map[uint64]*core.DecommissionSchedule
+	// nsMigrations tracks the key ranges moved between namespaces.
+	nsMigrations map[uint64]*NamespaceMigration
+	// fragmentationCollectTime is the last time the fragmentation metrics
+	// are collected.
+	fragmentationCollectTime time.Time
+	// learnerLags records the learner lags reported by the stores.
+	learnerLags learnerLags
+	// storeRTTs records the RTTs between the stores measured by them.
+	storeRTTs storeRTTs
+	// deleteRanges tracks the tasks to delete key ranges.
+	deleteRanges map[uint64]*DeleteRangeTask
+	// blockedStores records the stores blocked from scheduling by users.
+	blockedStores map[uint64]*BlockedStore
+	// pauser records the scheduling of the whole cluster paused by users.
+	pauser schedulingPauser
+	// pinner records the regions pinned to stores by users.
+	pinner regionPinner
+	// mergePauser records the key ranges whose merges are paused.
+	mergePauser mergePauser
+	// intents records the placements of regions declared by users.
+	intents placementIntents
+	// governor scales the schedule limits by the recent operator failures.
+	governor *speedGovernor
+	// standby is kept warm by the region syncer while this member is a
+	// follower, and reused once the cluster starts.
+	standby *standbyCluster
+	// regionGeneration and regionSequence identify the state of the region
+	// metas in the cache, see RegionSequence.
+	regionGeneration int64
+	regionSequence   uint64
+	// storeCredentials records the credentials to authenticate the stores.
+	storeCredentials map[uint64]*StoreCredential
+	// staleAcceptances records the stores whose stale regions are accepted.
+	staleAcceptances map[uint64]*StaleAcceptance
+	// resolvedTS records the minimal resolved timestamps of the stores and
+	// the cluster-wide one.
+	resolvedTS resolvedTSTracker
+	// benchmarkMedians caches the median benchmark scores of the stores of
+	// each type, which are compared with by the store heartbeats.
+	benchmarkMedians map[core.StoreType]benchmarkMedians
+	// balanceIndexes is the recent history of the balance index of the
+	// stores.
+	balanceIndexes []*BalanceIndex
 
 	coordinator *coordinator
 
@@ -90,13 +157,15 @@ type ClusterStatus struct {
 }
 
 func newRaftCluster(s *Server, clusterID uint64) *RaftCluster {
-	return &RaftCluster{
+	c := &RaftCluster{
 		s:            s,
 		running:      false,
 		clusterID:    clusterID,
 		clusterRoot:  s.getClusterRootPath(),
 		regionSyncer: syncer.NewRegionSyncer(s),
 	}
+	c.regionSyncer.SetRegionObserver(c.observeSyncedRegion)
+	return c
 }
 
 func (c *RaftCluster) loadClusterStatus() (*ClusterStatus, error) {
@@ -145,10 +214,33 @@ func (c *RaftCluster) initCluster(id id.Allocator, opt *config.ScheduleOption, s
 	c.storage = storage
 	c.id = id
 	c.labelLevelStats = statistics.NewLabelStatistics()
-	c.storesStats = statistics.NewStoresStats()
+	c.storesStats = statistics.NewStoresStats(c.opt)
 	c.prepareChecker = newPrepareChecker()
+	c.haltChecker = newHaltChecker()
+	c.hbAdvisor = newHeartbeatIntervalAdvisor()
+	c.hbAdmission = newHeartbeatAdmission()
 	c.changedRegions = make(chan *core.RegionInfo, defaultChangedRegionsLimit)
 	c.hotSpotCache = statistics.NewHotSpotCache()
+	c.accessSketch = statistics.NewAccessSketch()
+	c.emptyRegions = statistics.NewEmptyRegionTracker()
+	c.storeStatsHub = statistics.NewStoreStatsBroadcaster()
+	c.events = schedule.NewEventBus()
+	c.evacuateTargets = make(map[uint64][]uint64)
+	c.decommissionSchedules = make(map[uint64]*core.DecommissionSchedule)
+	c.nsMigrations = make(map[uint64]*NamespaceMigration)
+	c.learnerLags.reset()
+	c.storeRTTs.reset()
+	c.deleteRanges = make(map[uint64]*DeleteRangeTask)
+	c.blockedStores = make(map[uint64]*BlockedStore)
+	c.resolvedTS.reset()
+	c.pinner.pins = make(map[uint64]*core.RegionPin)
+	c.mergePauser.pauses = make(map[uint64]*MergePause)
+	c.intents.reset(nil)
+	c.governor = newSpeedGovernor()
+	c.regionGeneration = time.Now().UnixNano()
+	c.regionSequence = 0
+	c.storeCredentials = make(map[uint64]*StoreCredential)
+	c.staleAcceptances = make(map[uint64]*StaleAcceptance)
 }
 
 func (c *RaftCluster) start() error {
@@ -160,7 +252,10 @@ func (c *RaftCluster) start() error {
 		return nil
 	}
 
+	standby := c.standby
+	c.standby = nil
 	c.initCluster(c.s.idAllocator, c.s.scheduleOpt, c.s.storage)
+	standby.restore(c)
 	cluster, err := c.loadClusterInfo()
 	if err != nil {
 		return err
@@ -169,22 +264,88 @@ func (c *RaftCluster) start() error {
 		return nil
 	}
 
+	if err = c.s.loadClassifierState(); err != nil {
+		return err
+	}
 	err = c.s.classifier.ReloadNamespaces()
 	if err != nil {
 		return err
 	}
 
+	c.labeler, err = labeler.NewRangeLabeler(c.storage)
+	if err != nil {
+		return err
+	}
+
+	err = c.storage.LoadEvacuateTargets(func(storeID uint64, targets []uint64) {
+		c.evacuateTargets[storeID] = targets
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.storage.LoadDecommissionSchedules(func(storeID uint64, schedule *core.DecommissionSchedule) {
+		c.decommissionSchedules[storeID] = schedule
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = c.loadNamespaceMigrations(); err != nil {
+		return err
+	}
+
+	if err = c.loadMinResolvedTS(); err != nil {
+		return err
+	}
+
+	if err = c.loadBalanceIndexes(); err != nil {
+		return err
+	}
+
+	c.keyDecoder = c.s.keyDecoder
 	c.coordinator = newCoordinator(cluster, c.s.hbStreams, c.s.classifier)
-	c.regionStats = statistics.NewRegionStatistics(c.s.scheduleOpt, c.s.classifier)
+	if err = c.coordinator.loadCheckerStatus(); err != nil {
+		return err
+	}
+	if standby == nil {
+		c.regionStats = statistics.NewRegionStatistics(c.s.scheduleOpt, c.s.classifier)
+	}
 	c.quit = make(chan struct{})
 
-	c.wg.Add(3)
-	go c.runCoordinator()
+	storesCheckInterval := func() time.Duration { return c.opt.LoadPDServerConfig().StoresCheckInterval.Duration }
+	metricsInterval := func() time.Duration { return c.opt.LoadPDServerConfig().MetricsInterval.Duration }
+	historyPruneInterval := func() time.Duration { return c.opt.LoadPDServerConfig().HistoryPruneInterval.Duration }
+	regionCacheCheckInterval := func() time.Duration { return c.opt.LoadPDServerConfig().RegionCacheCheckInterval.Duration }
 	failpoint.Inject("highFrequencyClusterJobs", func() {
 		backgroundJobInterval = 100 * time.Microsecond
+		storesCheckInterval = func() time.Duration { return backgroundJobInterval }
+		metricsInterval = storesCheckInterval
+		historyPruneInterval = storesCheckInterval
 	})
+
+	sub := c.events.Subscribe("speed-governor", speedGovernorEventBufferSize,
+		schedule.EventOperatorFinish, schedule.EventOperatorFail)
+	c.wg.Add(8)
+	go c.runCoordinator()
 	go c.runBackgroundJobs(backgroundJobInterval)
+	go c.runBackgroundJob("stores-check", storesCheckInterval, func() {
+		c.checkStores()
+		c.refreshBenchmarkMedians()
+		c.checkBlockedStores()
+		c.checkSchedulingPause()
+		c.checkRegionPins()
+		c.checkPlacementIntents()
+		c.checkMergePauses()
+		c.checkStaleAcceptances()
+		c.removeStaleStores()
+		c.boundStatsMemory()
+	})
+	go c.runBackgroundJob("metrics", metricsInterval, c.collectMetrics)
+	go c.runBackgroundJob("history-prune", historyPruneInterval, c.coordinator.opController.PruneHistory)
+	go c.runBackgroundJob("region-cache-check", regionCacheCheckInterval, c.checkRegionCaches)
 	go c.syncRegions()
+	go c.governScheduleSpeed(sub)
 	c.running = true
 
 	return nil
@@ -209,16 +370,45 @@ func (c *RaftCluster) loadClusterInfo() (*RaftCluster, error) {
 		zap.Int("count", c.getStoreCount()),
 		zap.Duration("cost", time.Since(start)),
 	)
+	c.refreshBenchmarkMediansLocked()
+
+	// The regions are kept warm by the region syncer if the cluster was a
+	// standby.
+	if c.core.GetRegionCount() == 0 {
+		start = time.Now()
+		if err := c.storage.LoadRegions(c.core.PutRegion); err != nil {
+			return nil, err
+		}
+		log.Info("load regions",
+			zap.Int("count", c.core.GetRegionCount()),
+			zap.Duration("cost", time.Since(start)),
+		)
+	}
 
-	start = time.Now()
-
-	if err := c.storage.LoadRegions(c.core.PutRegion); err != nil {
+	if err := c.loadStoreCredentials(); err != nil {
+		return nil, err
+	}
+	if err := c.loadBlockedStores(); err != nil {
+		return nil, err
+	}
+	if err := c.loadSchedulingPause(); err != nil {
+		return nil, err
+	}
+	if err := c.loadRegionPins(); err != nil {
+		return nil, err
+	}
+	if err := c.loadMergePauses(); err != nil {
+		return nil, err
+	}
+	if err := c.loadPlacementIntents(); err != nil {
+		return nil, err
+	}
+	if err := c.loadScheduleSpeedOverride(); err != nil {
+		return nil, err
+	}
+	if err := c.loadDeleteRangeTasks(); err != nil {
 		return nil, err
 	}
-	log.Info("load regions",
-		zap.Int("count", c.core.GetRegionCount()),
-		zap.Duration("cost", time.Since(start)),
-	)
 	for _, store := range c.GetStores() {
 		c.storesStats.CreateRollingStoreStats(store.GetID())
 	}
@@ -229,6 +419,8 @@ func (c *RaftCluster) runBackgroundJobs(interval time.Duration) {
 	defer logutil.LogPanic()
 	defer c.wg.Done()
 
+	c.checkLeaderChange()
+
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
@@ -238,9 +430,30 @@ func (c *RaftCluster) runBackgroundJobs(interval time.Duration) {
 			log.Info("background jobs has been stopped")
 			return
 		case <-ticker.C:
-			c.checkStores()
-			c.collectMetrics()
-			c.coordinator.opController.PruneHistory()
+			c.saveMinResolvedTS()
+			c.updateBalanceIndex()
+		}
+	}
+}
+
+// runBackgroundJob runs the job periodically until the cluster is stopped.
+// The interval is loaded before each wait, so that it can be changed online
+// without restarting the job.
+func (c *RaftCluster) runBackgroundJob(name string, interval func() time.Duration, job func()) {
+	defer logutil.LogPanic()
+	defer c.wg.Done()
+
+	timer := time.NewTimer(interval())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-c.quit:
+			log.Info("background job has been stopped", zap.String("job", name))
+			return
+		case <-timer.C:
+			job()
+			timer.Reset(interval())
 		}
 	}
 }
@@ -275,6 +488,7 @@ func (c *RaftCluster) stop() {
 
 	close(c.quit)
 	c.coordinator.stop()
+	c.storeStatsHub.Close()
 	c.Unlock()
 	c.wg.Wait()
 }
@@ -307,7 +521,7 @@ func (c *RaftCluster) GetCoordinator() *coordinator {
 }
 
 // handleStoreHeartbeat updates the store status.
-func (c *RaftCluster) handleStoreHeartbeat(stats *pdpb.StoreStats) error {
+func (c *RaftCluster) handleStoreHeartbeat(stats *pdpb.StoreStats, memory storeMemoryUsage) error {
 	c.Lock()
 	defer c.Unlock()
 
@@ -316,22 +530,76 @@ func (c *RaftCluster) handleStoreHeartbeat(stats *pdpb.StoreStats) error {
 	if store == nil {
 		return core.NewStoreNotFoundErr(storeID)
 	}
-	newStore := store.Clone(core.SetStoreStats(stats), core.SetLastHeartbeatTS(time.Now()))
+	now := time.Now()
+	newStore := store.Clone(
+		core.SetStoreStats(stats),
+		core.SetLastHeartbeatTS(now),
+		core.SetMemoryUsage(memory.total, memory.used),
+		core.SetBenchmarkWeight(c.benchmarkWeight(store)),
+	)
+	// The pressure starts from the first heartbeat exceeding the ratio.
+	if !newStore.IsMemoryPressured(c.opt.GetMemoryPressureRatio()) {
+		newStore = newStore.Clone(core.SetMemoryPressureSince(time.Time{}))
+	} else if store.GetMemoryPressureSince().IsZero() {
+		log.Warn("store is under memory pressure",
+			zap.Uint64("store-id", storeID),
+			zap.Uint64("memory-total", memory.total),
+			zap.Uint64("memory-used", memory.used))
+		newStore = newStore.Clone(core.SetMemoryPressureSince(now))
+	}
 	c.core.PutStore(newStore)
 	c.storesStats.Observe(newStore.GetID(), newStore.GetStoreStats())
 	c.storesStats.UpdateTotalBytesRate(c.core.GetStores)
+	c.publishStoreStats(newStore)
 	return nil
 }
 
+func (c *RaftCluster) publishStoreStats(store *core.StoreInfo) {
+	update := &statistics.StoreStatsUpdate{
+		StoreID:     store.GetID(),
+		LeaderScore: store.LeaderScore(0),
+		RegionScore: store.RegionScore(c.GetHighSpaceRatio(), c.GetLowSpaceRatio(), 0),
+		Time:        store.GetLastHeartbeatTS(),
+	}
+	if stats := c.storesStats.GetRollingStoreStats(store.GetID()); stats != nil {
+		update.BytesWriteRate, update.BytesReadRate = stats.GetBytesRate()
+		update.KeysWriteRate = stats.GetKeysWriteRate()
+		update.KeysReadRate = stats.GetKeysReadRate()
+	}
+	c.storeStatsHub.Publish(update)
+}
+
+// SubscribeStoreStats subscribes the stats updates of stores, which are
+// published after each store heartbeat. The subscriber receives updates at
+// most once per interval, and it is closed when the cluster is stopped.
+func (c *RaftCluster) SubscribeStoreStats(interval time.Duration) *statistics.StoreStatsSubscriber {
+	c.RLock()
+	defer c.RUnlock()
+	return c.storeStatsHub.Subscribe(interval)
+}
+
 // processRegionHeartbeat updates the region information.
-func (c *RaftCluster) processRegionHeartbeat(region *core.RegionInfo) error {
+func (c *RaftCluster) processRegionHeartbeat(region *core.RegionInfo) (err error) {
+	c.hbAdvisor.observeRegionHeartbeat()
+	// Skip the heartbeats of the idle regions before taking the lock.
+	if c.isDuplicatedHeartbeat(c.GetRegion(region.GetID()), region) {
+		regionEventCounter.WithLabelValues("dedup").Inc()
+		return nil
+	}
 	c.RLock()
 	origin := c.GetRegion(region.GetID())
+	acceptStale := c.acceptsStaleLocked(region)
+	// newer is the newer region meta replaced by the accepted stale region.
+	var newer *metapb.Region
 	if origin == nil {
 		for _, item := range c.core.GetOverlaps(region) {
 			if region.GetRegionEpoch().GetVersion() < item.GetRegionEpoch().GetVersion() {
-				c.RUnlock()
-				return ErrRegionIsStale(region.GetMeta(), item)
+				if !acceptStale {
+					c.RUnlock()
+					return ErrRegionIsStale(region.GetMeta(), item)
+				}
+				newer = item
+				break
 			}
 		}
 	}
@@ -354,7 +622,11 @@ func (c *RaftCluster) processRegionHeartbeat(region *core.RegionInfo) error {
 		o := origin.GetRegionEpoch()
 		// Region meta is stale, return an error.
 		if r.GetVersion() < o.GetVersion() || r.GetConfVer() < o.GetConfVer() {
-			return ErrRegionIsStale(region.GetMeta(), origin.GetMeta())
+			if !acceptStale {
+				return ErrRegionIsStale(region.GetMeta(), origin.GetMeta())
+			}
+			newer = origin.GetMeta()
+			saveKV, saveCache = true, true
 		}
 		if r.GetVersion() > o.GetVersion() {
 			log.Info("region Version changed",
@@ -424,17 +696,43 @@ func (c *RaftCluster) processRegionHeartbeat(region *core.RegionInfo) error {
 		default:
 		}
 	}
-	if len(writeItems) == 0 && len(readItems) == 0 && !saveCache && !isNew {
+	if region.GetKeysRead()+region.GetKeysWritten() >= c.opt.GetRegionAccessMinKeys() {
+		c.accessSketch.Observe(region.GetID())
+	}
+	c.emptyRegions.Observe(region)
+	if !saveCache && !isNew {
+		c.updateHotCache(writeItems, readItems)
 		return nil
 	}
 
+	// The hot cache is updated after the lock is released, and only if the
+	// heartbeat is accepted.
+	defer func() {
+		if err == nil {
+			c.updateHotCache(writeItems, readItems)
+		}
+	}()
 	c.Lock()
 	defer c.Unlock()
+	// The cached region may be updated by another heartbeat after the check
+	// above.
+	if cur := c.core.GetRegion(region.GetID()); cur != nil && cur != origin && !acceptStale {
+		r, o := region.GetRegionEpoch(), cur.GetRegionEpoch()
+		if r.GetVersion() < o.GetVersion() || r.GetConfVer() < o.GetConfVer() {
+			return ErrRegionIsStale(region.GetMeta(), cur.GetMeta())
+		}
+	}
 	if isNew {
 		c.prepareChecker.collect(region)
 	}
+	if newer != nil {
+		c.recordStaleLocked(region, newer)
+	}
 
 	if saveCache {
+		if saveKV {
+			c.regionSequence++
+		}
 		overlaps := c.core.PutRegion(region)
 		if c.storage != nil {
 			for _, item := range overlaps {
@@ -446,11 +744,15 @@ func (c *RaftCluster) processRegionHeartbeat(region *core.RegionInfo) error {
 				}
 			}
 		}
+		if e := regionVersionEvent(origin, region); e != nil && e.Type == schedule.EventRegionMerge {
+			c.intents.markMerged(overlaps)
+		}
 		for _, item := range overlaps {
 			if c.regionStats != nil {
 				c.regionStats.ClearDefunctRegion(item.GetId())
 			}
 			c.labelLevelStats.ClearDefunctRegion(item.GetId(), c.GetLocationLabels())
+			c.emptyRegions.Remove(item.GetId())
 		}
 
 		// Update related stores.
@@ -464,18 +766,88 @@ func (c *RaftCluster) processRegionHeartbeat(region *core.RegionInfo) error {
 		}
 		regionEventCounter.WithLabelValues("update_cache").Inc()
 	}
+	if e := regionVersionEvent(origin, region); e != nil {
+		c.events.Publish(e)
+	}
 
 	if c.regionStats != nil {
 		c.regionStats.Observe(region, c.takeRegionStoresLocked(region))
 	}
+	return nil
+}
 
+// updateHotCache updates the hot cache with the flow items of an accepted
+// region heartbeat. The hot cache is sharded by itself, so it is updated
+// without the lock.
+func (c *RaftCluster) updateHotCache(writeItems, readItems []*statistics.HotSpotPeerStat) {
 	for _, writeItem := range writeItems {
 		c.hotSpotCache.Update(writeItem)
 	}
 	for _, readItem := range readItems {
 		c.hotSpotCache.Update(readItem)
 	}
-	return nil
+}
+
+// isDuplicatedHeartbeat checks if the region heartbeat only differs from the
+// cached region by the flow stats within RegionHeartbeatFlowDelta, and the
+// region is not in the hot cache, so that neither the cache nor the statistics
+// need to be updated by it.
+func (c *RaftCluster) isDuplicatedHeartbeat(origin, region *core.RegionInfo) bool {
+	delta := c.opt.GetRegionHeartbeatFlowDelta()
+	if delta == 0 || origin == nil {
+		return false
+	}
+	r, o := region.GetRegionEpoch(), origin.GetRegionEpoch()
+	if r.GetVersion() != o.GetVersion() || r.GetConfVer() != o.GetConfVer() ||
+		region.GetLeader().GetId() != origin.GetLeader().GetId() ||
+		len(region.GetPeers()) != len(origin.GetPeers()) ||
+		len(region.GetDownPeers()) > 0 || len(region.GetPendingPeers()) > 0 ||
+		len(origin.GetDownPeers()) > 0 || len(origin.GetPendingPeers()) > 0 ||
+		region.GetApproximateSize() != origin.GetApproximateSize() ||
+		region.GetApproximateKeys() != origin.GetApproximateKeys() {
+		return false
+	}
+	diff := func(a, b uint64) uint64 {
+		if a > b {
+			return a - b
+		}
+		return b - a
+	}
+	if diff(region.GetBytesWritten(), origin.GetBytesWritten()) > delta ||
+		diff(region.GetBytesRead(), origin.GetBytesRead()) > delta ||
+		diff(region.GetKeysWritten(), origin.GetKeysWritten()) > delta ||
+		diff(region.GetKeysRead(), origin.GetKeysRead()) > delta {
+		return false
+	}
+	// The hot cache needs the heartbeats to cool the region down.
+	return !c.hotSpotCache.HasRegion(region.GetID())
+}
+
+// regionVersionEvent returns the event of the split or the merge if the
+// version of the region changes, or nil otherwise. A region split shrinks to
+// a part of its origin range, while a merge grows to cover it.
+func regionVersionEvent(origin, region *core.RegionInfo) *schedule.Event {
+	if origin == nil || region.GetRegionEpoch().GetVersion() <= origin.GetRegionEpoch().GetVersion() {
+		return nil
+	}
+	e := &schedule.Event{Region: region, Origin: origin}
+	switch {
+	case rangeCovers(origin, region) && !rangeCovers(region, origin):
+		e.Type = schedule.EventRegionSplit
+	case rangeCovers(region, origin) && !rangeCovers(origin, region):
+		e.Type = schedule.EventRegionMerge
+	default:
+		return nil
+	}
+	return e
+}
+
+// rangeCovers returns if the range of a covers the range of b.
+func rangeCovers(a, b *core.RegionInfo) bool {
+	if bytes.Compare(a.GetStartKey(), b.GetStartKey()) > 0 {
+		return false
+	}
+	return len(a.GetEndKey()) == 0 || (len(b.GetEndKey()) > 0 && bytes.Compare(a.GetEndKey(), b.GetEndKey()) >= 0)
 }
 
 func (c *RaftCluster) updateStoreStatusLocked(id uint64) {
@@ -579,9 +951,10 @@ func (c *RaftCluster) GetRegionInfoByKey(regionKey []byte) *core.RegionInfo {
 }
 
 // ScanRegions scans region with start key, until the region contains endKey, or
-// total number greater than limit.
-func (c *RaftCluster) ScanRegions(startKey, endKey []byte, limit int) []*core.RegionInfo {
-	return c.core.ScanRange(startKey, endKey, limit)
+// total number greater than limit. Only the regions matching all the options
+// are returned.
+func (c *RaftCluster) ScanRegions(startKey, endKey []byte, limit int, opts ...core.RegionOption) []*core.RegionInfo {
+	return c.core.ScanRange(startKey, endKey, limit, opts...)
 }
 
 // GetRegionByID gets region and leader peer by regionID from cluster.
@@ -628,15 +1001,20 @@ func (c *RaftCluster) RandPendingRegion(storeID uint64, opts ...core.RegionOptio
 	return c.core.RandPendingRegion(storeID, opts...)
 }
 
-// RandHotRegionFromStore randomly picks a hot region in specified store.
-func (c *RaftCluster) RandHotRegionFromStore(store uint64, kind statistics.FlowKind) *core.RegionInfo {
+// RandHotRegionFromStore randomly picks a hot region in specified store, which
+// is selected by all the options.
+func (c *RaftCluster) RandHotRegionFromStore(store uint64, kind statistics.FlowKind, opts ...core.RegionOption) *core.RegionInfo {
 	c.RLock()
 	defer c.RUnlock()
-	r := c.hotSpotCache.RandHotRegionFromStore(store, kind, c.GetHotRegionCacheHitsThreshold())
+	var region *core.RegionInfo
+	r := c.hotSpotCache.RandHotRegionFromStore(store, kind, c.GetHotRegionCacheHitsThreshold(), func(stat *statistics.HotSpotPeerStat) bool {
+		region = c.GetRegion(stat.RegionID)
+		return region != nil && core.SelectRegion(region, opts...)
+	})
 	if r == nil {
 		return nil
 	}
-	return c.GetRegion(r.RegionID)
+	return region
 }
 
 // GetLeaderStore returns all stores that contains the region's leader peer.
@@ -701,15 +1079,44 @@ func (c *RaftCluster) GetStores() []*core.StoreInfo {
 	return c.core.GetStores()
 }
 
+// IsMixedTier checks if the stores in the cluster are of different types.
+func (c *RaftCluster) IsMixedTier() bool {
+	return c.core.IsMixedTier()
+}
+
 // GetStore gets store from cluster.
 func (c *RaftCluster) GetStore(storeID uint64) *core.StoreInfo {
 	return c.core.GetStore(storeID)
 }
 
+// IsRegionWarm checks if a region is accessed in enough recent heartbeats to be
+// regarded warm.
+func (c *RaftCluster) IsRegionWarm(region *core.RegionInfo) bool {
+	return c.accessSketch.Estimate(region.GetID()) >= c.opt.GetWarmRegionAccessThreshold()
+}
+
+// IsRegionLongEmpty checks if a region has been empty for
+// long-empty-region-duration.
+func (c *RaftCluster) IsRegionLongEmpty(region *core.RegionInfo) bool {
+	return c.emptyRegions.IsLongEmpty(region.GetID(), c.opt.GetLongEmptyRegionDuration())
+}
+
+// GetLongEmptyRegions returns the regions which have been empty for
+// long-empty-region-duration.
+func (c *RaftCluster) GetLongEmptyRegions() []*core.RegionInfo {
+	ids := c.emptyRegions.GetEmptyRegions(c.opt.GetLongEmptyRegionDuration())
+	regions := make([]*core.RegionInfo, 0, len(ids))
+	for _, id := range ids {
+		if region := c.GetRegion(id); region != nil {
+			regions = append(regions, region)
+		}
+	}
+	sort.Slice(regions, func(i, j int) bool { return regions[i].GetID() < regions[j].GetID() })
+	return regions
+}
+
 // IsRegionHot checks if a region is in hot state.
 func (c *RaftCluster) IsRegionHot(region *core.RegionInfo) bool {
-	c.RLock()
-	defer c.RUnlock()
 	return c.hotSpotCache.IsRegionHot(region, c.GetHotRegionCacheHitsThreshold())
 }
 
@@ -731,7 +1138,27 @@ func (c *RaftCluster) UpdateStoreLabels(storeID uint64, labels []*metapb.StoreLa
 	return err
 }
 
+// resolveSameAddressStores returns the IDs of the other stores which share an
+// IP and the port with the store after resolving the host names. It is called
+// without the cluster lock.
+func (c *RaftCluster) resolveSameAddressStores(store *metapb.Store) map[uint64]struct{} {
+	stores := make(map[uint64]struct{})
+	for _, s := range c.GetStores() {
+		if s.GetID() != store.GetId() && netutil.SameAddress(s.GetAddress(), store.GetAddress(), true) {
+			stores[s.GetID()] = struct{}{}
+		}
+	}
+	return stores
+}
+
 func (c *RaftCluster) putStore(store *metapb.Store) error {
+	// Resolves the addresses before taking the lock, since the DNS lookups
+	// may be slow.
+	var resolved map[uint64]struct{}
+	if c.opt.LoadPDServerConfig().ResolveStoreAddress {
+		resolved = c.resolveSameAddressStores(store)
+	}
+
 	c.Lock()
 	defer c.Unlock()
 
@@ -749,18 +1176,39 @@ func (c *RaftCluster) putStore(store *metapb.Store) error {
 	}
 
 	// Store address can not be the same as other stores.
+	var tombstoneID uint64
 	for _, s := range c.GetStores() {
+		if s.GetID() == store.GetId() {
+			continue
+		}
+		if _, ok := resolved[s.GetID()]; !ok && !netutil.SameAddress(s.GetAddress(), store.GetAddress(), false) {
+			continue
+		}
 		// It's OK to start a new store on the same address if the old store has been removed.
 		if s.IsTombstone() {
+			if s.GetID() > tombstoneID {
+				tombstoneID = s.GetID()
+			}
 			continue
 		}
-		if s.GetID() != store.GetId() && s.GetAddress() == store.GetAddress() {
-			return errors.Errorf("duplicated store address: %v, already registered by %v", store, s.GetMeta())
-		}
+		return errors.Errorf("duplicated store address: %v, already registered by %v", store, s.GetMeta())
 	}
 
 	s := c.GetStore(store.GetId())
 	if s == nil {
+		// IDs are allocated in ascending order, so a store with a smaller ID
+		// than the removed one on the address is not a newly deployed store,
+		// and may still have the data of the removed one.
+		if store.GetId() < tombstoneID {
+			return errors.Errorf("store %d is older than the tombstone store %d on address %s, please deploy a new store on the address",
+				store.GetId(), tombstoneID, store.GetAddress())
+		}
+		if tombstoneID != 0 {
+			log.Info("add a new store on the address of a tombstone store",
+				zap.Uint64("store-id", store.GetId()),
+				zap.Uint64("tombstone-store-id", tombstoneID),
+				zap.String("address", store.GetAddress()))
+		}
 		// Add a new store.
 		s = core.NewStoreInfo(store)
 	} else {
@@ -773,7 +1221,14 @@ func (c *RaftCluster) putStore(store *metapb.Store) error {
 			core.SetStoreLabels(labels),
 		)
 	}
-	// Check location labels.
+	if err := c.checkStoreLabels(s); err != nil {
+		return err
+	}
+	return c.putStoreLocked(s)
+}
+
+// checkStoreLabels checks the labels of the store against the location labels.
+func (c *RaftCluster) checkStoreLabels(s *core.StoreInfo) error {
 	keysSet := make(map[string]struct{})
 	for _, k := range c.GetLocationLabels() {
 		keysSet[k] = struct{}{}
@@ -797,12 +1252,13 @@ func (c *RaftCluster) putStore(store *metapb.Store) error {
 			}
 		}
 	}
-	return c.putStoreLocked(s)
+	return nil
 }
 
-// RemoveStore marks a store as offline in cluster.
+// RemoveStore marks a store as offline in cluster. If the schedule is not nil,
+// the store is only evacuated in the window of the schedule.
 // State transition: Up -> Offline.
-func (c *RaftCluster) RemoveStore(storeID uint64) error {
+func (c *RaftCluster) RemoveStore(storeID uint64, schedule *core.DecommissionSchedule) error {
 	op := errcode.Op("store.remove")
 	c.Lock()
 	defer c.Unlock()
@@ -812,20 +1268,68 @@ func (c *RaftCluster) RemoveStore(storeID uint64) error {
 		return op.AddTo(core.NewStoreNotFoundErr(storeID))
 	}
 
+	if store.IsTombstone() {
+		return op.AddTo(core.StoreTombstonedErr{StoreID: storeID})
+	}
+
+	if schedule != nil {
+		if err := schedule.Validate(); err != nil {
+			return op.AddTo(errcode.NewInvalidInputErr(err))
+		}
+		schedule = &core.DecommissionSchedule{Start: schedule.Start, End: schedule.End, InitialRegionCount: store.GetRegionCount()}
+		// The progress of an offline store is counted from the start of the
+		// evacuation.
+		if old, ok := c.decommissionSchedules[storeID]; ok {
+			schedule.InitialRegionCount = old.InitialRegionCount
+		}
+		if err := c.storage.SaveDecommissionSchedule(storeID, schedule); err != nil {
+			return err
+		}
+		c.decommissionSchedules[storeID] = schedule
+	}
+
 	// Remove an offline store should be OK, nothing to do.
 	if store.IsOffline() {
 		return nil
 	}
 
-	if store.IsTombstone() {
-		return op.AddTo(core.StoreTombstonedErr{StoreID: storeID})
-	}
-
+	oldState := store.GetState()
 	newStore := store.Clone(core.SetStoreState(metapb.StoreState_Offline))
 	log.Warn("store has been offline",
 		zap.Uint64("store-id", newStore.GetID()),
-		zap.String("store-address", newStore.GetAddress()))
-	return c.putStoreLocked(newStore)
+		zap.String("store-address", newStore.GetAddress()),
+		zap.Reflect("schedule", schedule))
+	if err := c.putStoreLocked(newStore); err != nil {
+		return err
+	}
+	c.publishStoreStateChange(storeID, oldState, metapb.StoreState_Offline)
+	return nil
+}
+
+// GetDecommissionSchedule returns the schedule to evacuate the store, or nil
+// if the store is evacuated at any time.
+func (c *RaftCluster) GetDecommissionSchedule(storeID uint64) *core.DecommissionSchedule {
+	c.RLock()
+	defer c.RUnlock()
+	return c.decommissionSchedules[storeID]
+}
+
+// IsEvacuationAllowed returns whether the replicas can be moved out of the
+// store now, which is false if the store is removed with a schedule and the
+// time is out of its window.
+func (c *RaftCluster) IsEvacuationAllowed(storeID uint64) bool {
+	schedule := c.GetDecommissionSchedule(storeID)
+	return schedule == nil || schedule.Contains(time.Now())
+}
+
+func (c *RaftCluster) deleteDecommissionScheduleLocked(storeID uint64) {
+	if _, ok := c.decommissionSchedules[storeID]; !ok {
+		return
+	}
+	if err := c.storage.DeleteDecommissionSchedule(storeID); err != nil {
+		log.Error("failed to delete decommission schedule", zap.Uint64("store-id", storeID), zap.Error(err))
+	}
+	delete(c.decommissionSchedules, storeID)
 }
 
 // BuryStore marks a store as tombstone in cluster.
@@ -853,11 +1357,93 @@ func (c *RaftCluster) BuryStore(storeID uint64, force bool) error { // revive:di
 		log.Warn("forcedly bury store", zap.Stringer("store", store.GetMeta()))
 	}
 
+	oldState := store.GetState()
 	newStore := store.Clone(core.SetStoreState(metapb.StoreState_Tombstone))
 	log.Warn("store has been Tombstone",
 		zap.Uint64("store-id", newStore.GetID()),
 		zap.String("store-address", newStore.GetAddress()))
-	return c.putStoreLocked(newStore)
+	if err := c.putStoreLocked(newStore); err != nil {
+		return err
+	}
+	c.publishStoreStateChange(storeID, oldState, metapb.StoreState_Tombstone)
+	// The store has been evacuated.
+	if _, ok := c.evacuateTargets[storeID]; ok {
+		if err := c.storage.DeleteEvacuateTargets(storeID); err != nil {
+			log.Error("failed to delete evacuate targets", zap.Uint64("store-id", storeID), zap.Error(err))
+		}
+		delete(c.evacuateTargets, storeID)
+	}
+	c.deleteDecommissionScheduleLocked(storeID)
+	if _, ok := c.blockedStores[storeID]; ok {
+		if err := c.unblockStoreLocked(storeID); err != nil {
+			log.Error("failed to unblock store", zap.Uint64("store-id", storeID), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// SetEvacuateTargets sets the preferred targets to move the replicas of the
+// store to when it is evacuated.
+func (c *RaftCluster) SetEvacuateTargets(storeID uint64, targets []uint64) error {
+	c.Lock()
+	defer c.Unlock()
+
+	store := c.GetStore(storeID)
+	if store == nil {
+		return core.NewStoreNotFoundErr(storeID)
+	}
+	if store.IsTombstone() {
+		return core.StoreTombstonedErr{StoreID: storeID}
+	}
+	if len(targets) == 0 {
+		return errcode.NewInvalidInputErr(errors.New("evacuate targets should not be empty"))
+	}
+	for _, id := range targets {
+		if id == storeID {
+			return errcode.NewInvalidInputErr(errors.Errorf("store %d cannot be evacuated to itself", storeID))
+		}
+		target := c.GetStore(id)
+		if target == nil {
+			return core.NewStoreNotFoundErr(id)
+		}
+		if target.IsTombstone() {
+			return core.StoreTombstonedErr{StoreID: id}
+		}
+	}
+	if err := c.storage.SaveEvacuateTargets(storeID, targets); err != nil {
+		return err
+	}
+	c.evacuateTargets[storeID] = append(targets[:0:0], targets...)
+	return nil
+}
+
+// DeleteEvacuateTargets deletes the preferred targets to evacuate the store.
+func (c *RaftCluster) DeleteEvacuateTargets(storeID uint64) error {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.storage.DeleteEvacuateTargets(storeID); err != nil {
+		return err
+	}
+	delete(c.evacuateTargets, storeID)
+	return nil
+}
+
+// GetEvacuateTargets returns the preferred targets to evacuate the store.
+func (c *RaftCluster) GetEvacuateTargets(storeID uint64) []uint64 {
+	c.RLock()
+	defer c.RUnlock()
+	return c.evacuateTargets[storeID]
+}
+
+// GetAllEvacuateTargets returns the preferred targets to evacuate all stores.
+func (c *RaftCluster) GetAllEvacuateTargets() map[uint64][]uint64 {
+	c.RLock()
+	defer c.RUnlock()
+	targets := make(map[uint64][]uint64, len(c.evacuateTargets))
+	for storeID, ids := range c.evacuateTargets {
+		targets[storeID] = ids
+	}
+	return targets
 }
 
 // BlockStore stops balancer from selecting the store.
@@ -885,11 +1471,19 @@ func (c *RaftCluster) SetStoreState(storeID uint64, state metapb.StoreState) err
 		return core.NewStoreNotFoundErr(storeID)
 	}
 
+	oldState := store.GetState()
 	newStore := store.Clone(core.SetStoreState(state))
 	log.Warn("store update state",
 		zap.Uint64("store-id", storeID),
 		zap.Stringer("new-state", state))
-	return c.putStoreLocked(newStore)
+	if err := c.putStoreLocked(newStore); err != nil {
+		return err
+	}
+	c.publishStoreStateChange(storeID, oldState, state)
+	if state != metapb.StoreState_Offline {
+		c.deleteDecommissionScheduleLocked(storeID)
+	}
+	return nil
 }
 
 // SetStoreWeight sets up a store's leader/region balance weight.
@@ -914,6 +1508,22 @@ func (c *RaftCluster) SetStoreWeight(storeID uint64, leaderWeight, regionWeight
 	return c.putStoreLocked(newStore)
 }
 
+// RebalanceStore adds at most limit operators at once to move leaders and
+// regions to or from the store according to its weights. It returns the
+// number of the added operators. It is rejected if scheduling is halted or
+// paused, and the operators are under the schedule limits.
+func (c *RaftCluster) RebalanceStore(storeID uint64, limit int) (int, error) {
+	if c.GetStore(storeID) == nil {
+		return 0, core.NewStoreNotFoundErr(storeID)
+	}
+	if c.isSchedulingHalted() {
+		return 0, errSchedulingHalted
+	}
+	ops := schedulers.RebalanceStore(c, c.coordinator.opController, storeID, limit)
+	log.Info("rebalance store", zap.Uint64("store-id", storeID), zap.Int("operator-count", len(ops)))
+	return len(ops), nil
+}
+
 func (c *RaftCluster) putStoreLocked(store *core.StoreInfo) error {
 	if c.storage != nil {
 		if err := c.storage.SaveStore(store.GetMeta()); err != nil {
@@ -925,6 +1535,21 @@ func (c *RaftCluster) putStoreLocked(store *core.StoreInfo) error {
 	return nil
 }
 
+// publishStoreStateChange publishes the state change of the store. It is
+// called by the state transitions, since the state is updated in the meta
+// shared by the clones of the store.
+func (c *RaftCluster) publishStoreStateChange(storeID uint64, oldState, newState metapb.StoreState) {
+	if oldState == newState {
+		return
+	}
+	c.events.Publish(&schedule.Event{
+		Type:     schedule.EventStoreStateChange,
+		StoreID:  storeID,
+		OldState: oldState,
+		NewState: newState,
+	})
+}
+
 func (c *RaftCluster) checkStores() {
 	var offlineStores []*metapb.Store
 	var upStoreCount int
@@ -970,23 +1595,54 @@ func (c *RaftCluster) checkStores() {
 // RemoveTombStoneRecords removes the tombStone Records.
 func (c *RaftCluster) RemoveTombStoneRecords() error {
 	c.Lock()
-	defer c.Unlock()
+	count, err := c.removeTombStoneRecordsLocked("")
+	c.Unlock()
+	if count > 0 {
+		c.removeStaleStores()
+	}
+	return err
+}
 
+// RemoveTombStoneRecordsByAddress removes the tombstone records on the
+// address, so that the address is not taken by any removed store. It returns
+// an error if there is no tombstone store on the address.
+func (c *RaftCluster) RemoveTombStoneRecordsByAddress(address string) error {
+	c.Lock()
+	count, err := c.removeTombStoneRecordsLocked(address)
+	c.Unlock()
+	if count > 0 {
+		c.removeStaleStores()
+	}
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return errcode.NewNotFoundErr(errors.Errorf("no tombstone store on address %s", address))
+	}
+	return nil
+}
+
+// removeTombStoneRecordsLocked removes the tombstone records on the address,
+// or all tombstone records if address is empty. It returns the number of the
+// removed records.
+func (c *RaftCluster) removeTombStoneRecordsLocked(address string) (int, error) {
+	var count int
 	for _, store := range c.GetStores() {
-		if store.IsTombstone() {
+		if store.IsTombstone() && (address == "" || netutil.SameAddress(store.GetAddress(), address, false)) {
 			// the store has already been tombstone
 			err := c.deleteStoreLocked(store)
 			if err != nil {
 				log.Error("delete store failed",
 					zap.Stringer("store", store.GetMeta()),
 					zap.Error(err))
-				return err
+				return count, err
 			}
 			log.Info("delete store successed",
 				zap.Stringer("store", store.GetMeta()))
+			count++
 		}
 	}
-	return nil
+	return count, nil
 }
 
 func (c *RaftCluster) deleteStoreLocked(store *core.StoreInfo) error {
@@ -1000,6 +1656,21 @@ func (c *RaftCluster) deleteStoreLocked(store *core.StoreInfo) error {
 	return nil
 }
 
+// removeStaleStores removes the entries of the stores which do not exist any
+// more from the statistics, the hot cache and the schedulers. It runs after
+// the tombstone records are removed, and periodically to clean up the entries
+// left behind by the stores removed before, such as by an earlier leader.
+func (c *RaftCluster) removeStaleStores() {
+	exists := func(storeID uint64) bool {
+		return c.GetStore(storeID) != nil
+	}
+	staleStoreEntriesCounter.WithLabelValues("store-stats").Add(float64(c.storesStats.RemoveStaleStores(exists)))
+	staleStoreEntriesCounter.WithLabelValues("hot-cache").Add(float64(c.hotSpotCache.RemoveStaleStores(exists)))
+	if c.coordinator != nil {
+		staleStoreEntriesCounter.WithLabelValues("scheduler").Add(float64(c.coordinator.removeStaleStores(exists)))
+	}
+}
+
 func (c *RaftCluster) collectMetrics() {
 	statsMap := statistics.NewStoreStatisticsMap(c.opt, c.GetNamespaceClassifier())
 	stores := c.GetStores()
@@ -1010,8 +1681,21 @@ func (c *RaftCluster) collectMetrics() {
 
 	c.coordinator.collectSchedulerMetrics()
 	c.coordinator.collectHotSpotMetrics()
+	c.coordinator.opController.CollectSnapshotBandwidthMetrics()
 	c.collectClusterMetrics()
 	c.collectHealthStatus()
+	c.collectHeartbeatIntervals()
+	c.collectFragmentationMetrics()
+}
+
+// GetLabelValueStats returns the totals of the stores with each value of the
+// location labels, such as the capacity and the flow of each zone.
+func (c *RaftCluster) GetLabelValueStats() []*statistics.LabelValueStats {
+	statsMap := statistics.NewStoreStatisticsMap(c.opt, c.GetNamespaceClassifier())
+	for _, s := range c.GetStores() {
+		statsMap.ObserveLabelValues(s, c.storesStats)
+	}
+	return statsMap.GetLabelValueStats()
 }
 
 func (c *RaftCluster) collectClusterMetrics() {
@@ -1040,6 +1724,68 @@ func (c *RaftCluster) collectHealthStatus() {
 		}
 		healthStatusGauge.WithLabelValues(member.GetName()).Set(1)
 	}
+	// The quorum is at low majority if losing one more member makes it unavailable.
+	if len(unhealth) > 0 && len(members)-len(unhealth) <= len(members)/2+1 {
+		c.haltChecker.markUnstable("etcd quorum is at low majority")
+	}
+	if etcd := c.s.member.Etcd(); etcd != nil {
+		c.haltChecker.observeEtcdTerm(etcd.Server.Term())
+	}
+	c.collectHaltStatus()
+}
+
+// checkLeaderChange checks whether the previous leader stepped down cleanly.
+// If the previous leader is unhealthy, the leader is changed because of
+// failure and the cached regions may be stale.
+func (c *RaftCluster) checkLeaderChange() {
+	prevLeader := c.s.member.GetPrevLeader()
+	if prevLeader == nil || prevLeader.GetMemberId() == c.s.member.ID() {
+		return
+	}
+	if _, ok := c.s.CheckHealth([]*pdpb.Member{prevLeader})[prevLeader.GetMemberId()]; ok {
+		c.haltChecker.markUnstable("unclean leader change from " + prevLeader.GetName())
+	}
+	c.collectHaltStatus()
+}
+
+func (c *RaftCluster) collectHaltStatus() {
+	var halted float64
+	if c.isSchedulingHalted() {
+		halted = 1
+	}
+	schedulingHaltedGauge.Set(halted)
+}
+
+// isSchedulingHalted returns if scheduling should be paused because the
+// cluster became unstable recently, or it is paused by users.
+func (c *RaftCluster) isSchedulingHalted() bool {
+	return c.isSchedulingPaused() || c.haltChecker.isHalted(c.opt.GetSchedulingHaltWindow())
+}
+
+func (c *RaftCluster) collectHeartbeatIntervals() {
+	c.hbAdvisor.collect()
+	intervals := c.GetHeartbeatIntervals()
+	heartbeatIntervalGauge.WithLabelValues("store").Set(intervals.StoreHeartbeatInterval.Seconds())
+	heartbeatIntervalGauge.WithLabelValues("region").Set(intervals.RegionHeartbeatInterval.Seconds())
+}
+
+// GetHeartbeatIntervals returns the heartbeat intervals recommended to stores.
+func (c *RaftCluster) GetHeartbeatIntervals() *HeartbeatIntervals {
+	cfg := c.opt.LoadPDServerConfig()
+	intervals := c.hbAdvisor.getIntervals(cfg, c.core.GetRegionCount())
+	if c.hbAdmission.isOverloaded(cfg) {
+		// Slows down the stores as much as allowed until PD recovers.
+		intervals.StoreHeartbeatInterval = cfg.MaxStoreHeartbeatInterval
+		intervals.RegionHeartbeatInterval = cfg.MaxRegionHeartbeatInterval
+	}
+	return intervals
+}
+
+// GetRangeLabeler returns the labeler of key ranges.
+func (c *RaftCluster) GetRangeLabeler() *labeler.RangeLabeler {
+	c.RLock()
+	defer c.RUnlock()
+	return c.labeler
 }
 
 // GetRegionStatsByType gets the status of the region by types.
@@ -1052,6 +1798,16 @@ func (c *RaftCluster) GetRegionStatsByType(typ statistics.RegionStatisticType) [
 	return c.regionStats.GetRegionStatsByType(typ)
 }
 
+// GetNamespaceRegionStats gets the statistics of the regions in the namespace.
+func (c *RaftCluster) GetNamespaceRegionStats(name string) *statistics.NamespaceRegionStats {
+	c.RLock()
+	defer c.RUnlock()
+	if c.regionStats == nil {
+		return nil
+	}
+	return c.regionStats.GetNamespaceRegionStats(name)
+}
+
 func (c *RaftCluster) updateRegionsLabelLevelStats(regions []*core.RegionInfo) {
 	c.Lock()
 	defer c.Unlock()
@@ -1161,37 +1917,43 @@ func (c *RaftCluster) putConfig(meta *metapb.Cluster) error {
 
 // GetNamespaceClassifier returns current namespace classifier.
 func (c *RaftCluster) GetNamespaceClassifier() namespace.Classifier {
-	return c.s.classifier
+	return c.s.classifier.Current()
 }
 
-// GetOpt returns the scheduling options.
+// GetOpt returns the scheduling options, whose schedule limits are scaled by
+// the speed governor.
 func (c *RaftCluster) GetOpt() namespace.ScheduleOptions {
-	return c.opt
+	return governedScheduleOptions{ScheduleOptions: c.opt, governor: c.governor}
+}
+
+// GetNamespace returns empty since the cluster is not limited to a namespace.
+func (c *RaftCluster) GetNamespace() string {
+	return ""
 }
 
 // GetLeaderScheduleLimit returns the limit for leader schedule.
 func (c *RaftCluster) GetLeaderScheduleLimit() uint64 {
-	return c.opt.GetLeaderScheduleLimit(namespace.DefaultNamespace)
+	return c.GetOpt().GetLeaderScheduleLimit(namespace.DefaultNamespace)
 }
 
 // GetRegionScheduleLimit returns the limit for region schedule.
 func (c *RaftCluster) GetRegionScheduleLimit() uint64 {
-	return c.opt.GetRegionScheduleLimit(namespace.DefaultNamespace)
+	return c.GetOpt().GetRegionScheduleLimit(namespace.DefaultNamespace)
 }
 
 // GetReplicaScheduleLimit returns the limit for replica schedule.
 func (c *RaftCluster) GetReplicaScheduleLimit() uint64 {
-	return c.opt.GetReplicaScheduleLimit(namespace.DefaultNamespace)
+	return c.GetOpt().GetReplicaScheduleLimit(namespace.DefaultNamespace)
 }
 
 // GetMergeScheduleLimit returns the limit for merge schedule.
 func (c *RaftCluster) GetMergeScheduleLimit() uint64 {
-	return c.opt.GetMergeScheduleLimit(namespace.DefaultNamespace)
+	return c.GetOpt().GetMergeScheduleLimit(namespace.DefaultNamespace)
 }
 
 // GetHotRegionScheduleLimit returns the limit for hot region schedule.
 func (c *RaftCluster) GetHotRegionScheduleLimit() uint64 {
-	return c.opt.GetHotRegionScheduleLimit(namespace.DefaultNamespace)
+	return c.GetOpt().GetHotRegionScheduleLimit(namespace.DefaultNamespace)
 }
 
 // GetStoreBalanceRate returns the balance rate of a store.
@@ -1199,6 +1961,12 @@ func (c *RaftCluster) GetStoreBalanceRate() float64 {
 	return c.opt.GetStoreBalanceRate()
 }
 
+// GetScheduleSpeedScale returns the factor the schedule limits and the store
+// limits are multiplied by.
+func (c *RaftCluster) GetScheduleSpeedScale() float64 {
+	return c.governor.getScale()
+}
+
 // GetTolerantSizeRatio gets the tolerant size ratio.
 func (c *RaftCluster) GetTolerantSizeRatio() float64 {
 	return c.opt.GetTolerantSizeRatio()
@@ -1214,11 +1982,56 @@ func (c *RaftCluster) GetHighSpaceRatio() float64 {
 	return c.opt.GetHighSpaceRatio()
 }
 
+// GetMaxLeaderFractionPerStore returns the max fraction of leaders that a
+// store can hold.
+func (c *RaftCluster) GetMaxLeaderFractionPerStore() float64 {
+	return c.opt.GetMaxLeaderFractionPerStore()
+}
+
+// GetMaxAdjacentLeaderCount returns the max number of adjacent regions whose
+// leaders are in the same store.
+func (c *RaftCluster) GetMaxAdjacentLeaderCount() uint64 {
+	return c.opt.GetMaxAdjacentLeaderCount()
+}
+
+// GetNetworkDistance returns the network distance between the stores. The
+// RTT measured by the stores is used if no distance is configured.
+func (c *RaftCluster) GetNetworkDistance(from, to *core.StoreInfo) float64 {
+	if distance := c.opt.GetNetworkDistance(from, to); distance > 0 {
+		return distance
+	}
+	rtt, _ := c.storeRTTs.get(from.GetID(), to.GetID())
+	return rtt
+}
+
+// GetMaxStoreRegionCount returns the max number of regions that a store can
+// hold.
+func (c *RaftCluster) GetMaxStoreRegionCount() uint64 {
+	return c.opt.GetMaxStoreRegionCount()
+}
+
+// GetMaxStoreRegionCountPerGB returns the max number of regions that a store
+// can hold for each GB of its capacity.
+func (c *RaftCluster) GetMaxStoreRegionCountPerGB() uint64 {
+	return c.opt.GetMaxStoreRegionCountPerGB()
+}
+
+// GetMemoryPressureRatio returns the memory usage ratio above which a store is
+// under memory pressure.
+func (c *RaftCluster) GetMemoryPressureRatio() float64 {
+	return c.opt.GetMemoryPressureRatio()
+}
+
 // GetSchedulerMaxWaitingOperator returns the number of the max waiting operators.
 func (c *RaftCluster) GetSchedulerMaxWaitingOperator() uint64 {
 	return c.opt.GetSchedulerMaxWaitingOperator()
 }
 
+// GetSchedulerDeniedStores returns the stores denied for the scheduler.
+func (c *RaftCluster) GetSchedulerDeniedStores(name string) []uint64 {
+	return c.opt.GetSchedulerDeniedStores(name)
+}
+
 // GetMaxSnapshotCount returns the number of the max snapshot which is allowed to send.
 func (c *RaftCluster) GetMaxSnapshotCount() uint64 {
 	return c.opt.GetMaxSnapshotCount()
@@ -1229,6 +2042,28 @@ func (c *RaftCluster) GetMaxPendingPeerCount() uint64 {
 	return c.opt.GetMaxPendingPeerCount()
 }
 
+// GetSnapshotBandwidthBudget returns the total bandwidth per second of the
+// snapshots sent for the operators.
+func (c *RaftCluster) GetSnapshotBandwidthBudget() uint64 {
+	return c.opt.GetSnapshotBandwidthBudget()
+}
+
+// GetMaxStoreLearnerCount returns the max number of learners being added to a store.
+func (c *RaftCluster) GetMaxStoreLearnerCount() uint64 {
+	return c.opt.GetMaxStoreLearnerCount()
+}
+
+// GetMaxLearnerCount returns the max number of learners being added in the cluster.
+func (c *RaftCluster) GetMaxLearnerCount() uint64 {
+	return c.opt.GetMaxLearnerCount()
+}
+
+// GetMaxLearnerPromoteLag returns the max applied index lag of a learner to
+// promote it.
+func (c *RaftCluster) GetMaxLearnerPromoteLag() uint64 {
+	return c.opt.GetMaxLearnerPromoteLag()
+}
+
 // GetMaxMergeRegionSize returns the max region size.
 func (c *RaftCluster) GetMaxMergeRegionSize() uint64 {
 	return c.opt.GetMaxMergeRegionSize()
@@ -1249,11 +2084,39 @@ func (c *RaftCluster) IsOneWayMergeEnabled() bool {
 	return c.opt.IsOneWayMergeEnabled()
 }
 
+// IsCrossTierMergeEnabled returns if regions on stores of different types can be merged.
+func (c *RaftCluster) IsCrossTierMergeEnabled() bool {
+	return c.opt.IsCrossTierMergeEnabled()
+}
+
+// GetMaxMergeChainLength returns the max number of adjacent regions merged
+// into one region in sequence.
+func (c *RaftCluster) GetMaxMergeChainLength() uint64 {
+	return c.opt.GetMaxMergeChainLength()
+}
+
+// GetLongEmptyRegionDuration returns how long a region stays empty to be a
+// long empty region.
+func (c *RaftCluster) GetLongEmptyRegionDuration() time.Duration {
+	return c.opt.GetLongEmptyRegionDuration()
+}
+
+// IsEmptyRegionMergeEnabled returns if the long empty regions are merged
+// regardless of the split merge interval and the merge size limits.
+func (c *RaftCluster) IsEmptyRegionMergeEnabled() bool {
+	return c.opt.IsEmptyRegionMergeEnabled()
+}
+
 // GetPatrolRegionInterval returns the interval of patroling region.
 func (c *RaftCluster) GetPatrolRegionInterval() time.Duration {
 	return c.opt.GetPatrolRegionInterval()
 }
 
+// GetPatrolRegionWorkerCount returns the number of workers to patrol regions.
+func (c *RaftCluster) GetPatrolRegionWorkerCount() uint64 {
+	return c.opt.GetPatrolRegionWorkerCount()
+}
+
 // GetMaxStoreDownTime returns the max down time of a store.
 func (c *RaftCluster) GetMaxStoreDownTime() time.Duration {
 	return c.opt.GetMaxStoreDownTime()
@@ -1304,11 +2167,23 @@ func (c *RaftCluster) IsLocationReplacementEnabled() bool {
 	return c.opt.IsLocationReplacementEnabled()
 }
 
+// GetLocationRepairLimit returns the limit for the operators repairing the
+// violations of the location label.
+func (c *RaftCluster) GetLocationRepairLimit(label string) uint64 {
+	return c.opt.GetLocationRepairLimit(label)
+}
+
 // IsNamespaceRelocationEnabled returns if namespace relocation is enabled.
 func (c *RaftCluster) IsNamespaceRelocationEnabled() bool {
 	return c.opt.IsNamespaceRelocationEnabled()
 }
 
+// IsOperatorPreemptionEnabled returns if the operators of the kind may preempt
+// the in-flight operators of lower schedule priorities.
+func (c *RaftCluster) IsOperatorPreemptionEnabled(kind string) bool {
+	return c.opt.IsOperatorPreemptionEnabled(kind)
+}
+
 // CheckLabelProperty is used to check label property.
 func (c *RaftCluster) CheckLabelProperty(typ string, labels []*metapb.StoreLabel) bool {
 	return c.opt.CheckLabelProperty(typ, labels)
@@ -1422,3 +2297,41 @@ func (checker *prepareChecker) collect(region *core.RegionInfo) {
 	}
 	checker.sum++
 }
+
+// haltChecker records when the cluster became unstable last time. Scheduling
+// will be halted for a window after that.
+type haltChecker struct {
+	sync.RWMutex
+	unstableTime time.Time
+	reason       string
+	etcdTerm     uint64
+}
+
+func newHaltChecker() *haltChecker {
+	return &haltChecker{}
+}
+
+func (checker *haltChecker) markUnstable(reason string) {
+	checker.Lock()
+	defer checker.Unlock()
+	log.Warn("cluster is unstable, scheduling will be halted", zap.String("reason", reason))
+	checker.unstableTime = time.Now()
+	checker.reason = reason
+}
+
+// observeEtcdTerm marks the cluster unstable if etcd elected a new leader.
+func (checker *haltChecker) observeEtcdTerm(term uint64) {
+	checker.Lock()
+	lastTerm := checker.etcdTerm
+	checker.etcdTerm = term
+	checker.Unlock()
+	if lastTerm != 0 && lastTerm != term {
+		checker.markUnstable(fmt.Sprintf("etcd term changed from %d to %d", lastTerm, term))
+	}
+}
+
+func (checker *haltChecker) isHalted(window time.Duration) bool {
+	checker.RLock()
+	defer checker.RUnlock()
+	return !checker.unstableTime.IsZero() && time.Since(checker.unstableTime) < window
+}
@@ -15,9 +15,13 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/coreos/go-semver/semver"
@@ -30,7 +34,11 @@ import (
 	"github.com/pingcap/pd/server/config"
 	"github.com/pingcap/pd/server/core"
 	"github.com/pingcap/pd/server/kv"
+	"github.com/pingcap/pd/server/namespace"
+	"github.com/pingcap/pd/server/schedule"
+	"github.com/pingcap/pd/server/statistics"
 	"github.com/pkg/errors"
+	"google.golang.org/grpc/metadata"
 )
 
 const (
@@ -347,7 +355,7 @@ func (s *baseCluster) testRemoveStore(c *C, clusterID uint64, store *metapb.Stor
 	{
 		// Case 1: RemoveStore should be OK;
 		s.resetStoreState(c, store.GetId(), metapb.StoreState_Up)
-		err := cluster.RemoveStore(store.GetId())
+		err := cluster.RemoveStore(store.GetId(), nil)
 		c.Assert(err, IsNil)
 		removedStore := s.getStore(c, clusterID, store.GetId())
 		c.Assert(removedStore.GetState(), Equals, metapb.StoreState_Offline)
@@ -367,7 +375,7 @@ func (s *baseCluster) testRemoveStore(c *C, clusterID uint64, store *metapb.Stor
 	{
 		// Case 1: RemoveStore should be OK;
 		s.resetStoreState(c, store.GetId(), metapb.StoreState_Offline)
-		err := cluster.RemoveStore(store.GetId())
+		err := cluster.RemoveStore(store.GetId(), nil)
 		c.Assert(err, IsNil)
 		removedStore := s.getStore(c, clusterID, store.GetId())
 		c.Assert(removedStore.GetState(), Equals, metapb.StoreState_Offline)
@@ -383,7 +391,7 @@ func (s *baseCluster) testRemoveStore(c *C, clusterID uint64, store *metapb.Stor
 	{
 		// Case 1: RemoveStore should should fail;
 		s.resetStoreState(c, store.GetId(), metapb.StoreState_Tombstone)
-		err := cluster.RemoveStore(store.GetId())
+		err := cluster.RemoveStore(store.GetId(), nil)
 		c.Assert(err, NotNil)
 		// Case 2: BuryStore w/ or w/o force should be OK.
 		s.resetStoreState(c, store.GetId(), metapb.StoreState_Tombstone)
@@ -974,6 +982,737 @@ func (s *testClusterInfoSuite) TestLoadClusterInfo(c *C) {
 	}
 }
 
+func (s *testClusterInfoSuite) TestStoreLearnerLags(c *C) {
+	ctx := context.Background()
+	newContext := func(kv ...string) context.Context {
+		return metadata.NewIncomingContext(ctx, metadata.Pairs(kv...))
+	}
+	_, ok := storeLearnerLagsFromContext(ctx)
+	c.Assert(ok, IsFalse)
+	lags, ok := storeLearnerLagsFromContext(newContext(storeLearnerLagsKey, "1:11:100, x, 1:12, 2:21:5"))
+	c.Assert(ok, IsTrue)
+	c.Assert(lags, DeepEquals, map[uint64]map[uint64]uint64{1: {11: 100}, 2: {21: 5}})
+
+	var l learnerLags
+	l.reset()
+	l.update(1, lags)
+	c.Assert(l.get(1, 1), DeepEquals, map[uint64]uint64{11: 100})
+	// Only the lags reported by the store of the leader are taken.
+	c.Assert(l.get(1, 2), IsNil)
+	c.Assert(l.get(3, 1), IsNil)
+	// The regions not reported any longer are dropped.
+	l.update(1, map[uint64]map[uint64]uint64{2: {21: 0}})
+	c.Assert(l.get(1, 1), IsNil)
+	c.Assert(l.get(2, 1), DeepEquals, map[uint64]uint64{21: 0})
+	// The leader of region 2 is transferred to store 2.
+	l.update(2, map[uint64]map[uint64]uint64{2: {21: 7}})
+	l.update(1, nil)
+	c.Assert(l.get(2, 2), DeepEquals, map[uint64]uint64{21: 7})
+}
+
+func (s *testClusterInfoSuite) TestBlockedStores(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	c.Assert(storage.SaveMeta(&metapb.Cluster{Id: 123}), IsNil)
+	mustSaveStores(c, storage, 3)
+	cluster := createTestRaftCluster(mockid.NewIDAllocator(), opt, storage)
+	_, err = cluster.loadClusterInfo()
+	c.Assert(err, IsNil)
+
+	_, err = cluster.AddBlockedStore(1, "maintenance", 0)
+	c.Assert(err, IsNil)
+	_, err = cluster.AddBlockedStore(2, "maintenance", time.Hour)
+	c.Assert(err, IsNil)
+	_, err = cluster.AddBlockedStore(3, "maintenance", 0)
+	c.Assert(err, NotNil)
+	// A store blocked by a scheduler cannot be blocked by users.
+	c.Assert(cluster.BlockStore(0), IsNil)
+	_, err = cluster.AddBlockedStore(0, "maintenance", 0)
+	c.Assert(err, NotNil)
+	c.Assert(cluster.GetStore(1).IsBlocked(), IsTrue)
+	c.Assert(cluster.GetStore(2).IsBlocked(), IsTrue)
+
+	// The blocked stores are reloaded.
+	cluster = createTestRaftCluster(mockid.NewIDAllocator(), opt, storage)
+	_, err = cluster.loadClusterInfo()
+	c.Assert(err, IsNil)
+	blocked := cluster.GetBlockedStores()
+	c.Assert(blocked, HasLen, 2)
+	c.Assert(blocked[0].StoreID, Equals, uint64(1))
+	c.Assert(blocked[0].Reason, Equals, "maintenance")
+	c.Assert(blocked[0].ExpireTime, IsNil)
+	c.Assert(blocked[1].StoreID, Equals, uint64(2))
+	c.Assert(blocked[1].ExpireTime, NotNil)
+	c.Assert(cluster.GetStore(1).IsBlocked(), IsTrue)
+	c.Assert(cluster.GetStore(2).IsBlocked(), IsTrue)
+
+	c.Assert(cluster.RemoveBlockedStore(1), IsNil)
+	c.Assert(cluster.RemoveBlockedStore(1), NotNil)
+	c.Assert(cluster.GetStore(1).IsBlocked(), IsFalse)
+
+	// The expired store is unblocked.
+	_, err = cluster.AddBlockedStore(2, "maintenance", time.Nanosecond)
+	c.Assert(err, IsNil)
+	time.Sleep(time.Millisecond)
+	cluster.checkBlockedStores()
+	c.Assert(cluster.GetBlockedStores(), HasLen, 0)
+	c.Assert(cluster.GetStore(2).IsBlocked(), IsFalse)
+}
+
+func (s *testClusterInfoSuite) TestSchedulingPause(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	c.Assert(storage.SaveMeta(&metapb.Cluster{Id: 123}), IsNil)
+	cluster := createTestRaftCluster(mockid.NewIDAllocator(), opt, storage)
+	_, err = cluster.loadClusterInfo()
+	c.Assert(err, IsNil)
+	c.Assert(cluster.GetSchedulingPause().Pause, IsNil)
+	c.Assert(cluster.ResumeScheduling("admin"), NotNil)
+
+	_, err = cluster.PauseScheduling("admin", "", 0)
+	c.Assert(err, NotNil)
+	pause, err := cluster.PauseScheduling("admin", "upgrade", 0)
+	c.Assert(err, IsNil)
+	c.Assert(pause.ExpireTime, IsNil)
+	c.Assert(cluster.isSchedulingHalted(), IsTrue)
+	// Rebalancing a store is rejected while scheduling is paused.
+	c.Assert(cluster.putStoreLocked(newTestStores(1)[0]), IsNil)
+	_, err = cluster.RebalanceStore(1, 10)
+	c.Assert(err, Equals, errSchedulingHalted)
+	// Pausing again keeps the pause time.
+	again, err := cluster.PauseScheduling("dba", "upgrade tikv", time.Hour)
+	c.Assert(err, IsNil)
+	c.Assert(again.PauseTime, Equals, pause.PauseTime)
+	c.Assert(again.ExpireTime, NotNil)
+
+	// The pause is reloaded.
+	cluster = createTestRaftCluster(mockid.NewIDAllocator(), opt, storage)
+	_, err = cluster.loadClusterInfo()
+	c.Assert(err, IsNil)
+	status := cluster.GetSchedulingPause()
+	c.Assert(status.Pause, NotNil)
+	c.Assert(status.Pause.Owner, Equals, "dba")
+	c.Assert(status.Pause.Reason, Equals, "upgrade tikv")
+	c.Assert(status.Events, HasLen, 2)
+	c.Assert(cluster.isSchedulingHalted(), IsTrue)
+
+	c.Assert(cluster.ResumeScheduling("admin"), IsNil)
+	c.Assert(cluster.ResumeScheduling("admin"), NotNil)
+	c.Assert(cluster.isSchedulingHalted(), IsFalse)
+
+	// The expired pause is removed with an event.
+	_, err = cluster.PauseScheduling("admin", "upgrade", time.Nanosecond)
+	c.Assert(err, IsNil)
+	time.Sleep(time.Millisecond)
+	c.Assert(cluster.isSchedulingHalted(), IsFalse)
+	cluster.checkSchedulingPause()
+	status = cluster.GetSchedulingPause()
+	c.Assert(status.Pause, IsNil)
+	c.Assert(status.Events, HasLen, 5)
+	c.Assert(status.Events[2].Event, Equals, SchedulingPauseEventResume)
+	c.Assert(status.Events[4].Event, Equals, SchedulingPauseEventExpire)
+	c.Assert(status.Events[4].Owner, Equals, "admin")
+}
+
+func (s *testClusterInfoSuite) TestScheduleSpeedGovernor(c *C) {
+	cfg, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	c.Assert(storage.SaveMeta(&metapb.Cluster{Id: 123}), IsNil)
+	cluster := createTestRaftCluster(mockid.NewIDAllocator(), opt, storage)
+	_, err = cluster.loadClusterInfo()
+	c.Assert(err, IsNil)
+	cfg.RegionScheduleLimit = 10
+	c.Assert(cluster.GetRegionScheduleLimit(), Equals, uint64(10))
+
+	observe := func(finished, failed int) {
+		for i := 0; i < finished; i++ {
+			cluster.governor.observe(&schedule.Event{Type: schedule.EventOperatorFinish})
+		}
+		for i := 0; i < failed; i++ {
+			cluster.governor.observe(&schedule.Event{Type: schedule.EventOperatorFail})
+		}
+	}
+	// A few failures do not slow down scheduling.
+	observe(2, 3)
+	old, scale := cluster.governor.adjust(time.Now())
+	c.Assert(old, Equals, 1.0)
+	c.Assert(scale, Equals, 1.0)
+	// Scheduling is slowed down when failures spike, down to the minimum.
+	for _, expect := range []float64{0.5, 0.25, 0.125, 0.125} {
+		observe(6, 4)
+		_, scale = cluster.governor.adjust(time.Now())
+		c.Assert(scale, Equals, expect)
+	}
+	c.Assert(cluster.GetRegionScheduleLimit(), Equals, uint64(2))
+	c.Assert(cluster.GetOpt().GetRegionScheduleLimit(namespace.DefaultNamespace), Equals, uint64(2))
+	cfg.HotRegionScheduleLimit = 8
+	c.Assert(cluster.GetHotRegionScheduleLimit(), Equals, uint64(1))
+	c.Assert(cluster.GetScheduleSpeedScale(), Equals, 0.125)
+	cfg.RegionScheduleLimit = 0
+	c.Assert(cluster.GetRegionScheduleLimit(), Equals, uint64(0))
+	cfg.RegionScheduleLimit = 10
+	// Some failures keep the scale, and being healthy speeds up scheduling.
+	observe(9, 1)
+	_, scale = cluster.governor.adjust(time.Now())
+	c.Assert(scale, Equals, 0.125)
+	observe(20, 0)
+	_, scale = cluster.governor.adjust(time.Now())
+	c.Assert(scale, Equals, 0.25)
+	c.Assert(cluster.GetRegionScheduleLimit(), Equals, uint64(3))
+
+	// The override replaces the auto scale and is reloaded.
+	c.Assert(cluster.ResetScheduleSpeed(), NotNil)
+	_, err = cluster.SetScheduleSpeed(0)
+	c.Assert(err, NotNil)
+	_, err = cluster.SetScheduleSpeed(1.5)
+	c.Assert(err, NotNil)
+	_, err = cluster.SetScheduleSpeed(1)
+	c.Assert(err, IsNil)
+	c.Assert(cluster.GetRegionScheduleLimit(), Equals, uint64(10))
+	cluster = createTestRaftCluster(mockid.NewIDAllocator(), opt, storage)
+	_, err = cluster.loadClusterInfo()
+	c.Assert(err, IsNil)
+	status := cluster.GetScheduleSpeed()
+	c.Assert(status.Scale, Equals, 1.0)
+	c.Assert(status.Override, NotNil)
+	c.Assert(cluster.ResetScheduleSpeed(), IsNil)
+	c.Assert(cluster.GetScheduleSpeed().Override, IsNil)
+}
+
+func (s *testClusterInfoSuite) TestStandbyCluster(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	cluster := createTestRaftCluster(mockid.NewIDAllocator(), opt, storage)
+	// The standby is not started if the cluster is not bootstrapped.
+	c.Assert(cluster.startStandby(opt, storage, namespace.DefaultClassifier), IsNil)
+	c.Assert(cluster.standby, IsNil)
+
+	c.Assert(storage.SaveMeta(&metapb.Cluster{Id: 123}), IsNil)
+	for _, store := range newTestStores(10) {
+		c.Assert(storage.SaveStore(store.GetMeta()), IsNil)
+	}
+	regions := newTestRegions(10, 3)
+	for _, region := range regions[:5] {
+		c.Assert(storage.SaveRegion(region.GetMeta()), IsNil)
+	}
+	c.Assert(cluster.startStandby(opt, storage, namespace.DefaultClassifier), IsNil)
+	c.Assert(cluster.standby, NotNil)
+	c.Assert(cluster.standby.core.GetRegionCount(), Equals, 5)
+
+	// The regions are synchronized without leaders, as the syncer does.
+	for i := 0; i < 2; i++ {
+		for _, region := range regions {
+			cluster.observeSyncedRegion(core.NewRegionInfo(region.GetMeta(), nil))
+		}
+	}
+	c.Assert(cluster.standby.core.GetRegionCount(), Equals, 10)
+
+	// The hot regions are taken from the leader.
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stat := &statistics.HotRegionsStat{RegionsStat: statistics.RegionsStat{{RegionID: 1, FlowBytes: 1024, HotDegree: 5}}}
+		infos := &statistics.StoreHotRegionInfos{AsLeader: statistics.StoreHotRegionsStat{1: stat}}
+		if r.URL.Path == hotWriteRegionsURL {
+			infos.AsPeer = statistics.StoreHotRegionsStat{1: stat, 2: stat}
+		}
+		json.NewEncoder(w).Encode(infos)
+	}))
+	defer leader.Close()
+	c.Assert(cluster.syncStandbyHotRegions(leader.URL), IsNil)
+	c.Assert(cluster.standby.hotSpotCache.RegionStats(statistics.WriteFlow), HasLen, 2)
+	c.Assert(cluster.standby.hotSpotCache.RegionStats(statistics.ReadFlow), HasLen, 1)
+	c.Assert(cluster.standby.hotSpotCache.IsRegionHot(regions[1], 3), IsTrue)
+
+	// The cold cluster waits for the regions to be reported.
+	cold := createTestRaftCluster(mockid.NewIDAllocator(), opt, storage)
+	_, err = cold.loadClusterInfo()
+	c.Assert(err, IsNil)
+	c.Assert(cold.isPrepared(), IsFalse)
+
+	// The promoted standby knows the regions, but waits for them to be
+	// reported before scheduling, even if the standby has run for long.
+	standby := cluster.standby
+	standby.since = time.Now().Add(-2 * collectTimeout)
+	cluster.standby = nil
+	cluster.initCluster(mockid.NewIDAllocator(), opt, storage)
+	standby.restore(cluster)
+	_, err = cluster.loadClusterInfo()
+	c.Assert(err, IsNil)
+	c.Assert(cluster.core.GetRegionCount(), Equals, 10)
+	c.Assert(cluster.getStoreCount(), Equals, 10)
+	c.Assert(cluster.IsRegionHot(regions[1]), IsTrue)
+	c.Assert(cluster.isPrepared(), IsFalse)
+	for _, region := range regions {
+		// The first peer of region 0 has ID 0, which is taken as no leader.
+		heartbeat := core.NewRegionInfo(region.GetMeta(), region.GetPeers()[1])
+		c.Assert(cluster.processRegionHeartbeat(heartbeat), IsNil)
+	}
+	c.Assert(cluster.isPrepared(), IsTrue)
+	// The promoted cluster does not feed the standby any more.
+	cluster.observeSyncedRegion(regions[0])
+	c.Assert(cluster.standby, IsNil)
+}
+
+func (s *testClusterInfoSuite) TestRegionPins(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	c.Assert(storage.SaveMeta(&metapb.Cluster{Id: 123}), IsNil)
+	cluster := createTestRaftCluster(mockid.NewIDAllocator(), opt, storage)
+	_, err = cluster.loadClusterInfo()
+	c.Assert(err, IsNil)
+	for _, store := range newTestStores(4) {
+		c.Assert(cluster.putStoreLocked(store), IsNil)
+	}
+	// Region 1 has peers in stores 1, 2 and 3 with the leader in store 1.
+	peers := []*metapb.Peer{{Id: 11, StoreId: 1}, {Id: 12, StoreId: 2}, {Id: 13, StoreId: 3}}
+	region := core.NewRegionInfo(&metapb.Region{Id: 1, Peers: peers}, peers[0])
+	c.Assert(cluster.putRegion(region), IsNil)
+
+	for _, t := range []struct {
+		regionID uint64
+		leader   uint64
+		stores   []uint64
+		ttl      time.Duration
+	}{
+		{1, 1, nil, 0},
+		{1, 0, nil, time.Hour},
+		{2, 1, nil, time.Hour},
+		{1, 5, nil, time.Hour},
+		{1, 2, nil, time.Hour},
+		{1, 0, []uint64{1, 2}, time.Hour},
+		{1, 0, []uint64{1, 2, 4}, time.Hour},
+		{1, 0, []uint64{1, 2, 2}, time.Hour},
+	} {
+		_, err = cluster.PinRegion(t.regionID, t.leader, t.stores, "test", t.ttl)
+		c.Assert(err, NotNil)
+	}
+	pin, err := cluster.PinRegion(1, 1, []uint64{3, 1, 2}, "benchmark", time.Hour)
+	c.Assert(err, IsNil)
+	c.Assert(pin.StoreIDs, DeepEquals, []uint64{1, 2, 3})
+	c.Assert(cluster.GetRegionPin(1), DeepEquals, pin)
+	c.Assert(cluster.GetRegionPin(2), IsNil)
+
+	// The pins are reloaded.
+	cluster = createTestRaftCluster(mockid.NewIDAllocator(), opt, storage)
+	_, err = cluster.loadClusterInfo()
+	c.Assert(err, IsNil)
+	pins := cluster.GetRegionPins()
+	c.Assert(pins, HasLen, 1)
+	c.Assert(pins[0].LeaderStoreID, Equals, uint64(1))
+	c.Assert(pins[0].StoreIDs, DeepEquals, []uint64{1, 2, 3})
+	c.Assert(pins[0].Reason, Equals, "benchmark")
+
+	c.Assert(cluster.UnpinRegion(1), IsNil)
+	c.Assert(cluster.UnpinRegion(1), NotNil)
+	c.Assert(cluster.GetRegionPin(1), IsNil)
+
+	// The expired pin takes no effect and is removed.
+	c.Assert(cluster.putRegion(region), IsNil)
+	_, err = cluster.PinRegion(1, 1, nil, "debug", time.Nanosecond)
+	c.Assert(err, IsNil)
+	time.Sleep(time.Millisecond)
+	c.Assert(cluster.GetRegionPin(1), IsNil)
+	c.Assert(cluster.GetRegionPins(), HasLen, 0)
+	cluster.checkRegionPins()
+	c.Assert(cluster.UnpinRegion(1), NotNil)
+	n := 0
+	c.Assert(storage.LoadRegionPins(func(*core.RegionPin) { n++ }), IsNil)
+	c.Assert(n, Equals, 0)
+}
+
+func (s *testClusterInfoSuite) TestMergePauses(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	c.Assert(storage.SaveMeta(&metapb.Cluster{Id: 123}), IsNil)
+	cluster := createTestRaftCluster(mockid.NewIDAllocator(), opt, storage)
+	_, err = cluster.loadClusterInfo()
+	c.Assert(err, IsNil)
+
+	_, err = cluster.PauseMerge("tidb", "add index", []byte("b"), []byte("d"), false, 0)
+	c.Assert(err, NotNil)
+	_, err = cluster.PauseMerge("tidb", "add index", []byte("d"), []byte("b"), false, time.Hour)
+	c.Assert(err, NotNil)
+	_, err = cluster.PauseMerge("tidb", "add index", []byte("b"), nil, false, time.Hour)
+	c.Assert(err, NotNil)
+	pause, err := cluster.PauseMerge("tidb", "add index", []byte("b"), []byte("d"), true, time.Hour)
+	c.Assert(err, IsNil)
+	c.Assert(pause.StartKey, Equals, "62")
+	c.Assert(pause.EndKey, Equals, "64")
+
+	for _, t := range []struct {
+		startKey, endKey string
+		paused           bool
+	}{
+		{"", "b", false},
+		{"", "c", true},
+		{"c", "", true},
+		{"a", "e", true},
+		{"d", "", false},
+	} {
+		c.Assert(cluster.IsMergePaused([]byte(t.startKey), []byte(t.endKey)), Equals, t.paused)
+		c.Assert(cluster.isSplitPaused([]byte(t.startKey), []byte(t.endKey)), Equals, t.paused)
+	}
+
+	// The pauses are reloaded.
+	cluster = createTestRaftCluster(mockid.NewIDAllocator(), opt, storage)
+	_, err = cluster.loadClusterInfo()
+	c.Assert(err, IsNil)
+	pauses := cluster.GetMergePauses()
+	c.Assert(pauses, HasLen, 1)
+	c.Assert(pauses[0].ID, Equals, pause.ID)
+	c.Assert(pauses[0].Lease.Duration, Equals, time.Hour)
+	c.Assert(cluster.IsMergePaused([]byte("c"), []byte("e")), IsTrue)
+
+	renewed, err := cluster.RenewMergePause(pause.ID)
+	c.Assert(err, IsNil)
+	c.Assert(renewed.ExpireTime.Before(pause.ExpireTime), IsFalse)
+	_, err = cluster.RenewMergePause(pause.ID + 1)
+	c.Assert(err, NotNil)
+	c.Assert(cluster.ResumeMerge(pause.ID), IsNil)
+	c.Assert(cluster.ResumeMerge(pause.ID), NotNil)
+	c.Assert(cluster.IsMergePaused([]byte("c"), []byte("e")), IsFalse)
+
+	// Only the splits of the pauses with pause split are paused.
+	pause, err = cluster.PauseMerge("tidb", "ingest", []byte("b"), []byte("d"), false, time.Hour)
+	c.Assert(err, IsNil)
+	c.Assert(cluster.IsMergePaused([]byte("c"), []byte("e")), IsTrue)
+	c.Assert(cluster.isSplitPaused([]byte("c"), []byte("e")), IsFalse)
+	c.Assert(cluster.ResumeMerge(pause.ID), IsNil)
+
+	// The pause whose lease lapses takes no effect, cannot be renewed, and is
+	// removed.
+	pause, err = cluster.PauseMerge("tidb", "crashed", []byte("b"), []byte("d"), true, time.Nanosecond)
+	c.Assert(err, IsNil)
+	time.Sleep(time.Millisecond)
+	c.Assert(cluster.IsMergePaused([]byte("c"), []byte("e")), IsFalse)
+	c.Assert(cluster.GetMergePauses(), HasLen, 0)
+	_, err = cluster.RenewMergePause(pause.ID)
+	c.Assert(err, NotNil)
+	cluster.checkMergePauses()
+	c.Assert(cluster.ResumeMerge(pause.ID), NotNil)
+	n := 0
+	c.Assert(storage.LoadMergePauses(func(string) error { n++; return nil }), IsNil)
+	c.Assert(n, Equals, 0)
+}
+
+func (s *testClusterInfoSuite) TestDeleteRange(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	c.Assert(storage.SaveMeta(&metapb.Cluster{Id: 123}), IsNil)
+	cluster := createTestRaftCluster(mockid.NewIDAllocator(), opt, storage)
+	_, err = cluster.loadClusterInfo()
+	c.Assert(err, IsNil)
+	newRegion := func(id uint64, start, end string, version uint64, leader uint64) *core.RegionInfo {
+		peer := &metapb.Peer{Id: id * 10, StoreId: leader}
+		return core.NewRegionInfo(&metapb.Region{
+			Id:          id,
+			StartKey:    []byte(start),
+			EndKey:      []byte(end),
+			Peers:       []*metapb.Peer{peer},
+			RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: version},
+		}, peer)
+	}
+	// No region covers the keys from "d" yet.
+	c.Assert(cluster.putRegion(newRegion(1, "", "b", 1, 1)), IsNil)
+	c.Assert(cluster.putRegion(newRegion(2, "b", "d", 1, 2)), IsNil)
+
+	_, err = cluster.CreateDeleteRangeTask([]byte("e"), []byte("a"))
+	c.Assert(err, NotNil)
+	_, err = cluster.CreateDeleteRangeTask([]byte("a"), nil)
+	c.Assert(err, NotNil)
+	task, err := cluster.CreateDeleteRangeTask([]byte("a"), []byte("e"))
+	c.Assert(err, IsNil)
+	c.Assert(task.Pending, HasLen, 3)
+	pieceKeys := func(p *DeleteRangePiece) []string {
+		startKey, endKey := p.keys()
+		return []string{string(startKey), string(endKey)}
+	}
+	c.Assert(pieceKeys(task.Pending[0]), DeepEquals, []string{"a", "b"})
+	c.Assert(pieceKeys(task.Pending[1]), DeepEquals, []string{"b", "d"})
+	c.Assert(pieceKeys(task.Pending[2]), DeepEquals, []string{"d", "e"})
+	c.Assert(task.Pending[2].RegionID, Equals, uint64(0))
+
+	pieces := cluster.GetStoreDeleteRangePieces(1)
+	c.Assert(pieces, HasLen, 1)
+	c.Assert(pieces[0].RegionID, Equals, uint64(1))
+	c.Assert(cluster.FinishDeleteRangePiece(task.ID, pieces[0].ID, &metapb.RegionEpoch{ConfVer: 1, Version: 2}), NotNil)
+	c.Assert(cluster.FinishDeleteRangePiece(task.ID, pieces[0].ID, pieces[0].RegionEpoch), IsNil)
+	c.Assert(cluster.FinishDeleteRangePiece(task.ID, pieces[0].ID, pieces[0].RegionEpoch), NotNil)
+
+	// The piece of region 2 is split with the region, and the gap is covered
+	// by a new region.
+	stale := cluster.GetStoreDeleteRangePieces(2)[0]
+	c.Assert(cluster.putRegion(newRegion(2, "b", "c", 2, 2)), IsNil)
+	c.Assert(cluster.putRegion(newRegion(3, "c", "d", 2, 3)), IsNil)
+	c.Assert(cluster.putRegion(newRegion(4, "d", "", 1, 3)), IsNil)
+	task = cluster.GetDeleteRangeTask(task.ID)
+	c.Assert(cluster.FinishDeleteRangePiece(task.ID, stale.ID, stale.RegionEpoch), NotNil)
+	c.Assert(task.Pending, HasLen, 3)
+	c.Assert(task.Deleted, Equals, 1)
+	c.Assert(pieceKeys(task.Pending[0]), DeepEquals, []string{"b", "c"})
+	c.Assert(pieceKeys(task.Pending[1]), DeepEquals, []string{"c", "d"})
+	c.Assert(pieceKeys(task.Pending[2]), DeepEquals, []string{"d", "e"})
+	c.Assert(cluster.GetStoreDeleteRangePieces(2), HasLen, 1)
+	c.Assert(cluster.GetStoreDeleteRangePieces(3), HasLen, 2)
+	// Reading the task does not save it.
+	var saved []*DeleteRangeTask
+	c.Assert(storage.LoadDeleteRangeTasks(func(value string) error {
+		t := &DeleteRangeTask{}
+		saved = append(saved, t)
+		return json.Unmarshal([]byte(value), t)
+	}), IsNil)
+	c.Assert(saved, HasLen, 1)
+	c.Assert(saved[0].Pending, HasLen, 2)
+	c.Assert(saved[0].Pending[0].RegionID, Equals, uint64(2))
+
+	// The tasks are reloaded.
+	cluster = createTestRaftCluster(mockid.NewIDAllocator(), opt, storage)
+	_, err = cluster.loadClusterInfo()
+	c.Assert(err, IsNil)
+	c.Assert(cluster.putRegion(newRegion(2, "b", "c", 2, 2)), IsNil)
+	c.Assert(cluster.putRegion(newRegion(3, "c", "d", 2, 3)), IsNil)
+	c.Assert(cluster.putRegion(newRegion(4, "d", "", 1, 3)), IsNil)
+	tasks := cluster.GetDeleteRangeTasks()
+	c.Assert(tasks, HasLen, 1)
+	c.Assert(tasks[0].Pending, DeepEquals, task.Pending)
+	c.Assert(tasks[0].Deleted, Equals, 1)
+	for _, storeID := range []uint64{2, 3} {
+		for _, p := range cluster.GetStoreDeleteRangePieces(storeID) {
+			c.Assert(cluster.FinishDeleteRangePiece(task.ID, p.ID, p.RegionEpoch), IsNil)
+		}
+	}
+	task = cluster.GetDeleteRangeTask(task.ID)
+	c.Assert(task.IsFinished(), IsTrue)
+	c.Assert(task.Deleted, Equals, 4)
+
+	c.Assert(cluster.DeleteDeleteRangeTask(task.ID), IsNil)
+	c.Assert(cluster.DeleteDeleteRangeTask(task.ID), NotNil)
+	c.Assert(cluster.GetDeleteRangeTasks(), HasLen, 0)
+}
+
+func (s *testClusterInfoSuite) TestMinResolvedTS(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	cluster := createTestRaftCluster(mockid.NewIDAllocator(), opt, storage)
+	for _, store := range newTestStores(3) {
+		c.Assert(cluster.putStoreLocked(store), IsNil)
+	}
+
+	// Stores which have not reported hold the timestamp back.
+	c.Assert(cluster.SetStoreMinResolvedTS(1, 10), IsNil)
+	c.Assert(cluster.SetStoreMinResolvedTS(2, 20), IsNil)
+	c.Assert(cluster.GetMinResolvedTS(), Equals, uint64(0))
+	c.Assert(cluster.SetStoreMinResolvedTS(3, 30), IsNil)
+	c.Assert(cluster.GetMinResolvedTS(), Equals, uint64(10))
+	c.Assert(cluster.SetStoreMinResolvedTS(4, 40), NotNil)
+
+	// The timestamp never goes backward.
+	c.Assert(cluster.SetStoreMinResolvedTS(1, 5), IsNil)
+	c.Assert(cluster.resolvedTS.stores[1], Equals, uint64(10))
+	c.Assert(cluster.SetStoreMinResolvedTS(1, 25), IsNil)
+	c.Assert(cluster.GetMinResolvedTS(), Equals, uint64(20))
+
+	// Tombstone stores are ignored.
+	c.Assert(cluster.BuryStore(2, true), IsNil)
+	c.Assert(cluster.SetStoreMinResolvedTS(3, 35), IsNil)
+	c.Assert(cluster.GetMinResolvedTS(), Equals, uint64(25))
+
+	c.Assert(cluster.minResolvedTSPairs(), DeepEquals, []string{minResolvedTSHeaderKey, "25"})
+
+	cluster.saveMinResolvedTS()
+	ts, err := storage.LoadMinResolvedTS()
+	c.Assert(err, IsNil)
+	c.Assert(ts, Equals, uint64(25))
+}
+
+func (s *testClusterInfoSuite) TestStoreMinResolvedTSFromContext(c *C) {
+	ctx := context.Background()
+	newContext := func(kv ...string) context.Context {
+		return metadata.NewIncomingContext(ctx, metadata.Pairs(kv...))
+	}
+	_, ok := storeMinResolvedTSFromContext(ctx)
+	c.Assert(ok, IsFalse)
+	_, ok = storeMinResolvedTSFromContext(newContext(storeMinResolvedTSKey, "x"))
+	c.Assert(ok, IsFalse)
+	_, ok = storeMinResolvedTSFromContext(newContext(storeMinResolvedTSKey, "0"))
+	c.Assert(ok, IsFalse)
+	ts, ok := storeMinResolvedTSFromContext(newContext(storeMinResolvedTSKey, "100"))
+	c.Assert(ok, IsTrue)
+	c.Assert(ts, Equals, uint64(100))
+}
+
+func (s *testClusterInfoSuite) TestStoreRTTs(c *C) {
+	ctx := context.Background()
+	newContext := func(kv ...string) context.Context {
+		return metadata.NewIncomingContext(ctx, metadata.Pairs(kv...))
+	}
+	_, ok := storeRTTsFromContext(ctx)
+	c.Assert(ok, IsFalse)
+	rtts, ok := storeRTTsFromContext(newContext(storeRTTsKey, "2:1.5, x, 3:-1, 4:NaN, 3:8"))
+	c.Assert(ok, IsTrue)
+	c.Assert(rtts, DeepEquals, map[uint64]float64{2: 1.5, 3: 8})
+
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := createTestRaftCluster(mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()))
+	store := func(id uint64, zone string) *core.StoreInfo {
+		return core.NewStoreInfo(&metapb.Store{Id: id, Labels: []*metapb.StoreLabel{{Key: "zone", Value: zone}}})
+	}
+	s1, s2, s3, s4 := store(1, "z1"), store(2, "z1"), store(3, "z2"), store(4, "z2")
+	cluster.storeRTTs.update(1, rtts)
+	cluster.storeRTTs.update(3, map[uint64]float64{1: 10})
+	// The larger one of the RTTs measured by both stores is used.
+	c.Assert(cluster.GetNetworkDistance(s1, s2), Equals, 1.5)
+	c.Assert(cluster.GetNetworkDistance(s2, s1), Equals, 1.5)
+	c.Assert(cluster.GetNetworkDistance(s1, s3), Equals, 10.0)
+	c.Assert(cluster.GetNetworkDistance(s1, s4), Equals, 0.0)
+
+	// The configured distances take precedence.
+	cfg := opt.Load().Clone()
+	cfg.NetworkDistances = []config.NetworkDistance{{Key: "zone", From: "z1", To: "z2", Distance: 2}}
+	opt.Store(cfg)
+	c.Assert(cluster.GetNetworkDistance(s1, s3), Equals, 2.0)
+	c.Assert(cluster.GetNetworkDistance(s1, s2), Equals, 1.5)
+}
+
+func (s *testClusterInfoSuite) TestBackgroundJob(c *C) {
+	var interval atomic.Value
+	interval.Store(time.Hour)
+	loaded, runs := make(chan struct{}, 1), make(chan struct{}, 1)
+	start := func() *RaftCluster {
+		cluster := &RaftCluster{quit: make(chan struct{})}
+		cluster.wg.Add(1)
+		go cluster.runBackgroundJob("test", func() time.Duration {
+			select {
+			case loaded <- struct{}{}:
+			default:
+			}
+			return interval.Load().(time.Duration)
+		}, func() {
+			select {
+			case runs <- struct{}{}:
+			default:
+			}
+		})
+		return cluster
+	}
+
+	// The job waits for the interval loaded before the wait.
+	cluster := start()
+	<-loaded
+	interval.Store(time.Millisecond)
+	select {
+	case <-runs:
+		c.Fatal("the job should wait for an hour")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(cluster.quit)
+	cluster.wg.Wait()
+
+	cluster = start()
+	for i := 0; i < 3; i++ {
+		select {
+		case <-runs:
+		case <-time.After(5 * time.Second):
+			c.Fatal("the job should run every millisecond")
+		}
+	}
+	close(cluster.quit)
+	cluster.wg.Wait()
+}
+
+func (s *testClusterInfoSuite) TestPutStoreOnTombstoneAddress(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := createTestRaftCluster(mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()))
+	newStore := func(storeID uint64, addr string) *metapb.Store {
+		return &metapb.Store{Id: storeID, Address: addr, Version: "2.1.0"}
+	}
+	c.Assert(cluster.putStore(newStore(5, "tikv5")), IsNil)
+	c.Assert(cluster.putStore(newStore(6, "tikv6")), IsNil)
+	c.Assert(cluster.BuryStore(5, true), IsNil)
+
+	// A store older than the tombstone store can not take the address.
+	c.Assert(cluster.putStore(newStore(3, "tikv5")), NotNil)
+	c.Assert(cluster.GetStore(3), IsNil)
+	c.Assert(cluster.putStore(newStore(7, "tikv5")), IsNil)
+
+	// Purge the tombstone records by address.
+	c.Assert(cluster.BuryStore(6, true), IsNil)
+	c.Assert(cluster.RemoveTombStoneRecordsByAddress("tikv5"), IsNil)
+	c.Assert(cluster.GetStore(5), IsNil)
+	c.Assert(cluster.GetStore(6), NotNil)
+	c.Assert(cluster.RemoveTombStoneRecordsByAddress("tikv5"), NotNil)
+	c.Assert(cluster.RemoveTombStoneRecords(), IsNil)
+	c.Assert(cluster.GetStore(6), IsNil)
+}
+
+func (s *testClusterInfoSuite) TestPutStoreOnSameAddress(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := createTestRaftCluster(mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()))
+	newStore := func(storeID uint64, addr string) *metapb.Store {
+		return &metapb.Store{Id: storeID, Address: addr, Version: "2.1.0"}
+	}
+	c.Assert(cluster.putStore(newStore(1, "[::1]:20160")), IsNil)
+	c.Assert(cluster.putStore(newStore(2, "TiKV2:20160")), IsNil)
+
+	c.Assert(cluster.putStore(newStore(3, "[0:0:0:0:0:0:0:1]:20160")), NotNil)
+	c.Assert(cluster.putStore(newStore(3, "tikv2.:20160")), NotNil)
+	c.Assert(cluster.putStore(newStore(3, "[::1]:20161")), IsNil)
+	// The store can update its address to an equivalent one.
+	c.Assert(cluster.putStore(newStore(1, "[::0001]:20160")), IsNil)
+
+	// The host names are resolved if enabled.
+	c.Assert(cluster.putStore(newStore(4, "127.0.0.1:20170")), IsNil)
+	c.Assert(cluster.putStore(newStore(5, "localhost:20170")), IsNil)
+	c.Assert(cluster.BuryStore(5, true), IsNil)
+	pdServerCfg := *opt.LoadPDServerConfig()
+	pdServerCfg.ResolveStoreAddress = true
+	opt.SetPDServerConfig(&pdServerCfg)
+	c.Assert(cluster.putStore(newStore(6, "localhost:20170")), NotNil)
+
+	c.Assert(cluster.BuryStore(2, true), IsNil)
+	c.Assert(cluster.RemoveTombStoneRecordsByAddress("tikv2:20160"), IsNil)
+	c.Assert(cluster.GetStore(2), IsNil)
+}
+
+func (s *testClusterInfoSuite) TestRemoveStaleStores(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := createTestRaftCluster(mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()))
+	for _, storeID := range []uint64{1, 2} {
+		c.Assert(cluster.putStore(&metapb.Store{Id: storeID, Address: fmt.Sprintf("tikv%d", storeID), Version: "2.1.0"}), IsNil)
+	}
+	// The entries of store 9 are left behind by a store removed before.
+	cluster.storesStats.CreateRollingStoreStats(9)
+	for _, storeID := range []uint64{1, 2, 9} {
+		cluster.hotSpotCache.Update(&statistics.HotSpotPeerStat{RegionID: 1, StoreID: storeID, Kind: statistics.WriteFlow})
+		cluster.hotSpotCache.Update(&statistics.HotSpotPeerStat{RegionID: 2, StoreID: storeID, Kind: statistics.ReadFlow})
+	}
+	cluster.hotSpotCache.Update(&statistics.HotSpotPeerStat{RegionID: 3, StoreID: 9, Kind: statistics.WriteFlow})
+
+	c.Assert(cluster.BuryStore(2, true), IsNil)
+	c.Assert(cluster.RemoveTombStoneRecords(), IsNil)
+	c.Assert(cluster.GetStore(2), IsNil)
+	c.Assert(cluster.storesStats.GetRollingStoreStats(1), NotNil)
+	c.Assert(cluster.storesStats.GetRollingStoreStats(2), IsNil)
+	c.Assert(cluster.storesStats.GetRollingStoreStats(9), IsNil)
+	writeStats := cluster.hotSpotCache.RegionStats(statistics.WriteFlow)
+	c.Assert(writeStats, HasLen, 1)
+	c.Assert(writeStats[1], HasLen, 1)
+	c.Assert(cluster.hotSpotCache.RegionStats(statistics.ReadFlow), HasLen, 1)
+	c.Assert(cluster.hotSpotCache.HasRegion(1), IsTrue)
+	c.Assert(cluster.hotSpotCache.HasRegion(3), IsFalse)
+}
+
 func (s *testClusterInfoSuite) TestStoreHeartbeat(c *C) {
 	_, opt, err := newTestScheduleConfig()
 	c.Assert(err, IsNil)
@@ -995,14 +1734,14 @@ func (s *testClusterInfoSuite) TestStoreHeartbeat(c *C) {
 			Available:   50,
 			RegionCount: 1,
 		}
-		c.Assert(cluster.handleStoreHeartbeat(storeStats), NotNil)
+		c.Assert(cluster.handleStoreHeartbeat(storeStats, storeMemoryUsage{}), NotNil)
 
 		c.Assert(cluster.putStoreLocked(store), IsNil)
 		c.Assert(cluster.getStoreCount(), Equals, i+1)
 
 		c.Assert(store.GetLastHeartbeatTS().IsZero(), IsTrue)
 
-		c.Assert(cluster.handleStoreHeartbeat(storeStats), IsNil)
+		c.Assert(cluster.handleStoreHeartbeat(storeStats, storeMemoryUsage{}), IsNil)
 
 		s := cluster.GetStore(store.GetID())
 		c.Assert(s.GetLastHeartbeatTS().IsZero(), IsFalse)
@@ -1020,6 +1759,127 @@ func (s *testClusterInfoSuite) TestStoreHeartbeat(c *C) {
 	}
 }
 
+func (s *testClusterInfoSuite) TestStoreMemoryPressure(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := createTestRaftCluster(mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()))
+	store := newTestStores(1)[0]
+	c.Assert(cluster.putStoreLocked(store), IsNil)
+	stats := &pdpb.StoreStats{StoreId: store.GetID()}
+
+	c.Assert(cluster.handleStoreHeartbeat(stats, storeMemoryUsage{total: 100, used: 50}), IsNil)
+	c.Assert(cluster.GetStore(1).GetMemoryUsed(), Equals, uint64(50))
+	c.Assert(cluster.GetStore(1).GetMemoryPressureSince().IsZero(), IsTrue)
+
+	c.Assert(cluster.handleStoreHeartbeat(stats, storeMemoryUsage{total: 100, used: 95}), IsNil)
+	since := cluster.GetStore(1).GetMemoryPressureSince()
+	c.Assert(since.IsZero(), IsFalse)
+	// The pressure lasts from the first heartbeat exceeding the ratio.
+	c.Assert(cluster.handleStoreHeartbeat(stats, storeMemoryUsage{total: 100, used: 99}), IsNil)
+	c.Assert(cluster.GetStore(1).GetMemoryPressureSince(), Equals, since)
+
+	// The pressure ends once the usage drops or it is not reported.
+	c.Assert(cluster.handleStoreHeartbeat(stats, storeMemoryUsage{}), IsNil)
+	c.Assert(cluster.GetStore(1).GetMemoryTotal(), Equals, uint64(0))
+	c.Assert(cluster.GetStore(1).GetMemoryPressureSince().IsZero(), IsTrue)
+}
+
+func (s *testClusterInfoSuite) TestStoreMemoryUsageFromContext(c *C) {
+	ctx := context.Background()
+	c.Assert(storeMemoryUsageFromContext(ctx), Equals, storeMemoryUsage{})
+	newContext := func(kv ...string) context.Context {
+		return metadata.NewIncomingContext(ctx, metadata.Pairs(kv...))
+	}
+	c.Assert(storeMemoryUsageFromContext(newContext(storeMemoryTotalKey, "100", storeMemoryUsedKey, "60")),
+		Equals, storeMemoryUsage{total: 100, used: 60})
+	c.Assert(storeMemoryUsageFromContext(newContext(storeMemoryTotalKey, "100")), Equals, storeMemoryUsage{})
+	c.Assert(storeMemoryUsageFromContext(newContext(storeMemoryTotalKey, "100", storeMemoryUsedKey, "x")), Equals, storeMemoryUsage{})
+	c.Assert(storeMemoryUsageFromContext(newContext(storeMemoryTotalKey, "100", storeMemoryUsedKey, "200")), Equals, storeMemoryUsage{})
+}
+
+func (s *testClusterInfoSuite) TestStoreCapabilities(c *C) {
+	ctx := context.Background()
+	c.Assert(storeCapabilitiesFromContext(ctx), IsNil)
+	md := metadata.Pairs(storeCapabilitiesKey, " Witness,demote-voter,,", storeCapabilitiesKey, "demote-voter")
+	capabilities := storeCapabilitiesFromContext(metadata.NewIncomingContext(ctx, md))
+	c.Assert(capabilities, DeepEquals, []string{"demote-voter", "witness"})
+
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := createTestRaftCluster(mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()))
+	for _, store := range newTestStores(2) {
+		c.Assert(cluster.putStoreLocked(store), IsNil)
+	}
+	cluster.updateStoreCapabilities(1, capabilities)
+	c.Assert(cluster.GetStore(1).HasCapability("demote-voter"), IsTrue)
+	c.Assert(cluster.GetStore(2).HasCapability("demote-voter"), IsFalse)
+	// The capabilities are kept by the store heartbeats.
+	c.Assert(cluster.handleStoreHeartbeat(&pdpb.StoreStats{StoreId: 1}, storeMemoryUsage{}), IsNil)
+	c.Assert(cluster.GetStore(1).GetCapabilities(), DeepEquals, capabilities)
+	// The store is downgraded.
+	cluster.updateStoreCapabilities(1, nil)
+	c.Assert(cluster.GetStore(1).GetCapabilities(), HasLen, 0)
+}
+
+func (s *testClusterInfoSuite) TestStoreBenchmark(c *C) {
+	ctx := context.Background()
+	c.Assert(storeBenchmarkFromContext(ctx), IsNil)
+	newContext := func(kv ...string) context.Context {
+		return metadata.NewIncomingContext(ctx, metadata.Pairs(kv...))
+	}
+	c.Assert(storeBenchmarkFromContext(newContext(storeBenchmarkRandReadIOPSKey, "x")), IsNil)
+	c.Assert(*storeBenchmarkFromContext(newContext(storeBenchmarkRandReadIOPSKey, "1000", storeBenchmarkSeqThroughputKey, "x")),
+		Equals, core.StoreBenchmark{RandReadIOPS: 1000})
+
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	storage := core.NewStorage(kv.NewMemoryKV())
+	c.Assert(storage.SaveMeta(&metapb.Cluster{Id: 123}), IsNil)
+	cluster := createTestRaftCluster(mockid.NewIDAllocator(), opt, storage)
+	for _, store := range newTestStores(3) {
+		c.Assert(cluster.putStoreLocked(store), IsNil)
+	}
+	cluster.updateStoreBenchmark(1, &core.StoreBenchmark{RandReadIOPS: 3000, SeqThroughput: 300})
+	cluster.updateStoreBenchmark(2, &core.StoreBenchmark{RandReadIOPS: 1000, SeqThroughput: 100})
+	heartbeat := func(storeID uint64) *core.StoreInfo {
+		c.Assert(cluster.handleStoreHeartbeat(&pdpb.StoreStats{StoreId: storeID}, storeMemoryUsage{}), IsNil)
+		return cluster.GetStore(storeID)
+	}
+	// The benchmarks are ignored in the capacity mode.
+	c.Assert(heartbeat(1).GetBenchmarkWeight(), Equals, 1.0)
+	c.Assert(heartbeat(1).GetBenchmark().RandReadIOPS, Equals, uint64(3000))
+
+	opt.Load().PlacementScoreMode = config.PlacementScoreBenchmark
+	c.Assert(heartbeat(1).GetBenchmarkWeight(), Equals, 1.5)
+	c.Assert(heartbeat(2).GetBenchmarkWeight(), Equals, 0.5)
+	// The stores reporting no benchmark are weighted as usual.
+	c.Assert(heartbeat(3).GetBenchmarkWeight(), Equals, 1.0)
+	// The faster store takes more data for the same score.
+	c.Assert(cluster.GetStore(1).LeaderScore(300), Equals, cluster.GetStore(2).LeaderScore(100))
+
+	// The store far faster than the others does not drag their weights down.
+	cluster.updateStoreBenchmark(3, &core.StoreBenchmark{RandReadIOPS: 100000, SeqThroughput: 10000})
+	c.Assert(heartbeat(2).GetBenchmarkWeight(), Equals, 1.0/3)
+	c.Assert(heartbeat(3).GetBenchmarkWeight(), Equals, float64(maxBenchmarkWeight))
+
+	// The benchmarks are loaded by the new leader.
+	cluster = createTestRaftCluster(mockid.NewIDAllocator(), opt, storage)
+	_, err = cluster.loadClusterInfo()
+	c.Assert(err, IsNil)
+	c.Assert(*cluster.GetStore(1).GetBenchmark(), Equals, core.StoreBenchmark{RandReadIOPS: 3000, SeqThroughput: 300})
+	c.Assert(heartbeat(2).GetBenchmarkWeight(), Equals, 1.0/3)
+
+	// The benchmark is cleared if the store reports none when it registers.
+	cluster.updateStoreBenchmark(3, nil)
+	c.Assert(cluster.GetStore(3).GetBenchmark(), IsNil)
+	c.Assert(heartbeat(3).GetBenchmarkWeight(), Equals, 1.0)
+	c.Assert(heartbeat(1).GetBenchmarkWeight(), Equals, 1.5)
+	cluster = createTestRaftCluster(mockid.NewIDAllocator(), opt, storage)
+	_, err = cluster.loadClusterInfo()
+	c.Assert(err, IsNil)
+	c.Assert(cluster.GetStore(3).GetBenchmark(), IsNil)
+}
+
 func (s *testClusterInfoSuite) TestRegionHeartbeat(c *C) {
 	_, opt, err := newTestScheduleConfig()
 	c.Assert(err, IsNil)
@@ -1316,6 +2176,101 @@ func (s *testClusterInfoSuite) TestHeartbeatSplit(c *C) {
 	checkRegion(c, cluster.GetRegionInfoByKey([]byte("n")), region3)
 }
 
+func (s *testClusterInfoSuite) TestEvents(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := createTestRaftCluster(mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()))
+	sub := cluster.events.Subscribe("test", 10, schedule.EventRegionSplit, schedule.EventRegionMerge, schedule.EventStoreStateChange)
+
+	// 1: [nil, nil)
+	region1 := core.NewRegionInfo(&metapb.Region{Id: 1, RegionEpoch: &metapb.RegionEpoch{Version: 1, ConfVer: 1}}, nil)
+	c.Assert(cluster.processRegionHeartbeat(region1), IsNil)
+	// The leader change is not an event.
+	c.Assert(cluster.processRegionHeartbeat(region1.Clone(core.WithLeader(&metapb.Peer{Id: 1, StoreId: 1}))), IsNil)
+	c.Assert(sub.C, HasLen, 0)
+
+	// split 1 to 2: [nil, m) 1: [m, nil)
+	split := region1.Clone(core.WithStartKey([]byte("m")), core.WithIncVersion())
+	c.Assert(cluster.processRegionHeartbeat(split), IsNil)
+	e := <-sub.C
+	c.Assert(e.Type, Equals, schedule.EventRegionSplit)
+	c.Assert(e.Origin.GetStartKey(), HasLen, 0)
+	c.Assert(e.Region.GetStartKey(), DeepEquals, []byte("m"))
+
+	// merge 2 into 1: [nil, nil)
+	merged := split.Clone(core.WithStartKey(nil), core.WithIncVersion())
+	c.Assert(cluster.processRegionHeartbeat(merged), IsNil)
+	e = <-sub.C
+	c.Assert(e.Type, Equals, schedule.EventRegionMerge)
+	c.Assert(e.Origin.GetStartKey(), DeepEquals, []byte("m"))
+
+	store := newTestStores(1)[0]
+	c.Assert(cluster.putStoreLocked(store), IsNil)
+	c.Assert(cluster.RemoveStore(store.GetID(), nil), IsNil)
+	e = <-sub.C
+	c.Assert(e.Type, Equals, schedule.EventStoreStateChange)
+	c.Assert(e.StoreID, Equals, store.GetID())
+	c.Assert(e.OldState, Equals, metapb.StoreState_Up)
+	c.Assert(e.NewState, Equals, metapb.StoreState_Offline)
+	c.Assert(sub.C, HasLen, 0)
+}
+
+func (s *testClusterInfoSuite) TestWarmRegion(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := createTestRaftCluster(mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()))
+
+	region := core.NewRegionInfo(&metapb.Region{Id: 1, RegionEpoch: &metapb.RegionEpoch{Version: 1, ConfVer: 1}}, nil)
+	c.Assert(cluster.processRegionHeartbeat(region), IsNil)
+	c.Assert(cluster.IsRegionWarm(region), IsFalse)
+
+	// A single scan does not make the region warm.
+	scanned := region.Clone(core.SetReadKeys(100000))
+	c.Assert(cluster.processRegionHeartbeat(scanned), IsNil)
+	c.Assert(cluster.IsRegionWarm(region), IsFalse)
+
+	for i := 0; i < 2; i++ {
+		c.Assert(cluster.processRegionHeartbeat(region.Clone(core.SetWrittenKeys(10))), IsNil)
+	}
+	c.Assert(cluster.IsRegionWarm(region), IsTrue)
+}
+
+func (s *testClusterInfoSuite) TestDuplicatedRegionHeartbeat(c *C) {
+	cfg, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cfg.RegionHeartbeatFlowDelta = 1024
+	opt.Store(cfg)
+	cluster := createTestRaftCluster(mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()))
+
+	peers := []*metapb.Peer{{Id: 1, StoreId: 1}, {Id: 2, StoreId: 2}}
+	region := core.NewRegionInfo(&metapb.Region{Id: 1, Peers: peers, RegionEpoch: &metapb.RegionEpoch{Version: 1, ConfVer: 1}}, peers[0])
+	c.Assert(cluster.processRegionHeartbeat(region), IsNil)
+
+	// The flow stats within the delta are skipped.
+	c.Assert(cluster.processRegionHeartbeat(region.Clone(core.SetWrittenBytes(1000), core.SetReadKeys(10))), IsNil)
+	c.Assert(cluster.GetRegion(1).GetBytesWritten(), Equals, uint64(0))
+	c.Assert(cluster.GetRegion(1).GetKeysRead(), Equals, uint64(0))
+	c.Assert(cluster.processRegionHeartbeat(region.Clone(core.SetWrittenBytes(2000))), IsNil)
+	c.Assert(cluster.GetRegion(1).GetBytesWritten(), Equals, uint64(2000))
+
+	// Other changes are not skipped.
+	c.Assert(cluster.processRegionHeartbeat(region.Clone(core.WithLeader(peers[1]))), IsNil)
+	c.Assert(cluster.GetRegion(1).GetLeader().GetId(), Equals, uint64(2))
+	c.Assert(cluster.processRegionHeartbeat(region.Clone(core.SetApproximateSize(10))), IsNil)
+	c.Assert(cluster.GetRegion(1).GetApproximateSize(), Equals, int64(10))
+
+	// Neither are the regions in the hot cache.
+	region = cluster.GetRegion(1)
+	cluster.hotSpotCache.Update(&statistics.HotSpotPeerStat{RegionID: 1, StoreID: 1, Kind: statistics.ReadFlow})
+	c.Assert(cluster.processRegionHeartbeat(region.Clone(core.SetReadBytes(100))), IsNil)
+	c.Assert(cluster.GetRegion(1).GetBytesRead(), Equals, uint64(100))
+
+	cfg.RegionHeartbeatFlowDelta = 0
+	opt.Store(cfg)
+	c.Assert(cluster.processRegionHeartbeat(region.Clone(core.SetWrittenBytes(2001))), IsNil)
+	c.Assert(cluster.GetRegion(1).GetBytesWritten(), Equals, uint64(2001))
+}
+
 func (s *testClusterInfoSuite) TestRegionSplitAndMerge(c *C) {
 	_, opt, err := newTestScheduleConfig()
 	c.Assert(err, IsNil)
@@ -1424,6 +2379,23 @@ func (s *testClusterUtilSuite) TestCheckStaleRegion(c *C) {
 	c.Assert(checkStaleRegion(region, origin), NotNil)
 }
 
+func (s *testClusterUtilSuite) TestHaltChecker(c *C) {
+	checker := newHaltChecker()
+	c.Assert(checker.isHalted(time.Minute), IsFalse)
+
+	checker.markUnstable("test")
+	c.Assert(checker.isHalted(time.Minute), IsTrue)
+	c.Assert(checker.isHalted(0), IsFalse)
+
+	checker = newHaltChecker()
+	checker.observeEtcdTerm(2)
+	c.Assert(checker.isHalted(time.Minute), IsFalse)
+	checker.observeEtcdTerm(2)
+	c.Assert(checker.isHalted(time.Minute), IsFalse)
+	checker.observeEtcdTerm(3)
+	c.Assert(checker.isHalted(time.Minute), IsTrue)
+}
+
 func mustSaveStores(c *C, s *core.Storage, n int) []*metapb.Store {
 	stores := make([]*metapb.Store, 0, n)
 	for i := 0; i < n; i++ {
@@ -1452,3 +2424,28 @@ func mustSaveRegions(c *C, s *core.Storage, n int) []*metapb.Region {
 
 	return regions
 }
+
+func (s *testClusterInfoSuite) TestStaleHeartbeatHotCache(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := createTestRaftCluster(mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()))
+
+	peers := []*metapb.Peer{{Id: 1, StoreId: 1}, {Id: 2, StoreId: 2}}
+	region := core.NewRegionInfo(&metapb.Region{Id: 1, Peers: peers, RegionEpoch: &metapb.RegionEpoch{Version: 2, ConfVer: 2}}, peers[0])
+	c.Assert(cluster.processRegionHeartbeat(region), IsNil)
+
+	// The rejected heartbeats leave the hot cache unchanged.
+	stale := region.Clone(
+		core.WithDecVersion(),
+		core.SetWrittenBytes(512*1024*1024),
+		core.SetReportInterval(statistics.RegionHeartBeatReportInterval),
+	)
+	c.Assert(cluster.processRegionHeartbeat(stale), NotNil)
+	c.Assert(cluster.hotSpotCache.RegionStats(statistics.WriteFlow), HasLen, 0)
+
+	c.Assert(cluster.processRegionHeartbeat(region.Clone(
+		core.SetWrittenBytes(512*1024*1024),
+		core.SetReportInterval(statistics.RegionHeartBeatReportInterval),
+	)), IsNil)
+	c.Assert(cluster.hotSpotCache.RegionStats(statistics.WriteFlow), HasLen, 2)
+}
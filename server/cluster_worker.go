@@ -15,8 +15,10 @@ package server
 
 import (
 	"bytes"
+	"context"
 
 	"github.com/gogo/protobuf/proto"
+	"github.com/opentracing/opentracing-go"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/pingcap/log"
@@ -28,7 +30,24 @@ import (
 
 // HandleRegionHeartbeat processes RegionInfo reports from client.
 func (c *RaftCluster) HandleRegionHeartbeat(region *core.RegionInfo) error {
-	if err := c.processRegionHeartbeat(region); err != nil {
+	return c.handleRegionHeartbeat(context.Background(), region)
+}
+
+// handleRegionHeartbeat processes the region heartbeat, tracing the steps as
+// children of the span in ctx.
+func (c *RaftCluster) handleRegionHeartbeat(ctx context.Context, region *core.RegionInfo) error {
+	c.hbAdmission.enter()
+	defer c.hbAdmission.leave()
+	if c.shouldShedRegionHeartbeat(region) {
+		c.hbAdvisor.observeRegionHeartbeat()
+		regionHeartbeatShedCounter.Inc()
+		return nil
+	}
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "processRegionHeartbeat")
+	err := c.processRegionHeartbeat(region)
+	span.Finish()
+	if err != nil {
 		return err
 	}
 
@@ -41,7 +60,19 @@ func (c *RaftCluster) HandleRegionHeartbeat(region *core.RegionInfo) error {
 	c.RLock()
 	co := c.coordinator
 	c.RUnlock()
+	// The dispatch is a child of the heartbeat, and follows from the creation
+	// of the operator.
+	var opts []opentracing.StartSpanOption
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		opts = append(opts, opentracing.ChildOf(parent.Context()))
+	}
+	if op := co.opController.GetOperator(region.GetID()); op != nil && op.SpanContext() != nil {
+		opts = append(opts, opentracing.FollowsFrom(op.SpanContext()))
+	}
+	span = opentracing.StartSpan("dispatchOperator", opts...)
 	co.opController.Dispatch(region, schedule.DispatchFromHeartBeat)
+	co.checkMergePlan(region)
+	span.Finish()
 	return nil
 }
 
@@ -51,6 +82,9 @@ func (c *RaftCluster) handleAskSplit(request *pdpb.AskSplitRequest) (*pdpb.AskSp
 	if err != nil {
 		return nil, err
 	}
+	if c.isSplitPaused(reqRegion.GetStartKey(), reqRegion.GetEndKey()) {
+		return nil, errors.Errorf("split is paused, request region: %v", core.RegionToHexMeta(reqRegion))
+	}
 
 	newRegionID, err := c.s.idAllocator.Alloc()
 	if err != nil {
@@ -101,6 +135,9 @@ func (c *RaftCluster) handleAskBatchSplit(request *pdpb.AskBatchSplitRequest) (*
 	if err != nil {
 		return nil, err
 	}
+	if c.isSplitPaused(reqRegion.GetStartKey(), reqRegion.GetEndKey()) {
+		return nil, errors.Errorf("split is paused, request region: %v", core.RegionToHexMeta(reqRegion))
+	}
 	splitIDs := make([]*pdpb.SplitID, 0, splitCount)
 
 	c.RLock()
@@ -186,6 +223,7 @@ func (c *RaftCluster) handleReportSplit(request *pdpb.ReportSplitRequest) (*pdpb
 	log.Info("region split, generate new region",
 		zap.Uint64("region-id", originRegion.GetId()),
 		zap.Stringer("region-meta", core.RegionToHexMeta(left)))
+	c.splitPlacementIntent(originRegion.GetId(), []uint64{left.GetId()})
 	return &pdpb.ReportSplitResponse{}, nil
 }
 
@@ -207,5 +245,10 @@ func (c *RaftCluster) handleBatchReportSplit(request *pdpb.ReportBatchSplitReque
 		zap.Uint64("region-id", originRegion.GetId()),
 		zap.Stringer("origin", hrm),
 		zap.Int("total", last))
+	splitIDs := make([]uint64, 0, last)
+	for _, region := range regions[:last] {
+		splitIDs = append(splitIDs, region.GetId())
+	}
+	c.splitPlacementIntent(originRegion.GetId(), splitIDs)
 	return &pdpb.ReportBatchSplitResponse{}, nil
 }
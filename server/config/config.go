@@ -15,11 +15,15 @@ package config
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -28,7 +32,11 @@ import (
 	"github.com/coreos/go-semver/semver"
 	"github.com/pingcap/log"
 	"github.com/pingcap/pd/pkg/metricutil"
+	"github.com/pingcap/pd/pkg/netutil"
+	"github.com/pingcap/pd/pkg/tracing"
 	"github.com/pingcap/pd/pkg/typeutil"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/kv"
 	"github.com/pingcap/pd/server/namespace"
 	"github.com/pingcap/pd/server/schedule"
 	"github.com/pkg/errors"
@@ -62,6 +70,10 @@ type Config struct {
 	// Join to an existing pd cluster, a string of endpoints.
 	Join string `toml:"join" json:"join"`
 
+	// RootPrefix is the etcd key prefix of the data of the PD cluster. PD
+	// clusters sharing one etcd must use different prefixes.
+	RootPrefix string `toml:"root-prefix" json:"root-prefix"`
+
 	// LeaderLease time, if leader doesn't update its TTL
 	// in etcd after lease time, etcd will expire the leader key
 	// and other servers can campaign the leader again.
@@ -70,6 +82,9 @@ type Config struct {
 
 	// Log related config.
 	Log log.Config `toml:"log" json:"log"`
+	// SlowLogFile is the file of the slow log, which records the slow gRPC
+	// requests. The slow requests are logged into the main log if it is empty.
+	SlowLogFile string `toml:"slow-log-file" json:"slow-log-file"`
 
 	// Backward compatibility.
 	LogFileDeprecated  string `toml:"log-file" json:"log-file"`
@@ -77,9 +92,15 @@ type Config struct {
 
 	// TsoSaveInterval is the interval to save timestamp.
 	TsoSaveInterval typeutil.Duration `toml:"tso-save-interval" json:"tso-save-interval"`
+	// MaxResetTSGap is the largest gap the timestamp jumps forward at once.
+	// If the system time of the leader jumps forward further, the timestamp
+	// catches up with it gradually instead. 0 means no limit.
+	MaxResetTSGap typeutil.Duration `toml:"max-reset-ts-gap" json:"max-reset-ts-gap"`
 
 	Metric metricutil.MetricConfig `toml:"metric" json:"metric"`
 
+	Trace tracing.TraceConfig `toml:"trace" json:"trace"`
+
 	Schedule ScheduleConfig `toml:"schedule" json:"schedule"`
 
 	Replication ReplicationConfig `toml:"replication" json:"replication"`
@@ -118,6 +139,9 @@ type Config struct {
 	LabelProperty LabelPropertyConfig `toml:"label-property" json:"label-property"`
 
 	configFile string
+	// fileItems is the items of the schedule, the replication and the PD
+	// server configs set in the config file.
+	fileItems map[string]struct{}
 
 	// For all warnings during parsing.
 	WarningMsgs []string
@@ -125,6 +149,21 @@ type Config struct {
 	// NamespaceClassifier is for classifying stores/regions into different
 	// namespaces.
 	NamespaceClassifier string `toml:"namespace-classifier" json:"namespace-classifier"`
+	// ClassifierCfg is the configuration for the namespace classifier.
+	ClassifierCfg ClassifierConfig `toml:"classifier" json:"classifier"`
+	// KeyDecoder is for decoding the keys of regions into logical units such
+	// as tables, so that regions are split and merged along their boundaries.
+	KeyDecoder string `toml:"key-decoder" json:"key-decoder"`
+	// HeartbeatCaptureFile is the file to append the store and region
+	// heartbeats received by the server, which can be replayed offline by
+	// pd-heartbeat-replay. Empty means the heartbeats are not captured.
+	HeartbeatCaptureFile string `toml:"heartbeat-capture-file" json:"heartbeat-capture-file"`
+	// HeartbeatCaptureMaxSize is the size of the capture file to rotate it.
+	// 0 means the file is never rotated.
+	HeartbeatCaptureMaxSize typeutil.ByteSize `toml:"heartbeat-capture-max-size" json:"heartbeat-capture-max-size"`
+	// HeartbeatCaptureMaxBackups is the number of the rotated capture files
+	// to keep. 0 means all of them are kept.
+	HeartbeatCaptureMaxBackups int `toml:"heartbeat-capture-max-backups" json:"heartbeat-capture-max-backups"`
 
 	// Only test can change them.
 	nextRetryDelay             time.Duration
@@ -178,11 +217,13 @@ func NewConfig() *Config {
 
 const (
 	defaultLeaderLease             = int64(3)
+	defaultMaxResetTSGap           = 24 * time.Hour
 	defaultNextRetryDelay          = time.Second
 	defaultCompactionMode          = "periodic"
 	defaultAutoCompactionRetention = "1h"
 
 	defaultName                = "pd"
+	defaultRootPrefix          = "/pd"
 	defaultClientUrls          = "http://127.0.0.1:2379"
 	defaultPeerUrls            = "http://127.0.0.1:2380"
 	defaultInitialClusterState = embed.ClusterStateFlagNew
@@ -197,11 +238,33 @@ const (
 
 	defaultMetricsPushInterval = 15 * time.Second
 
+	defaultTraceSamplingRatio = 0.001
+
 	defaultHeartbeatStreamRebindInterval = time.Minute
 
 	defaultLeaderPriorityCheckInterval = time.Minute
 
+	// The same as the default heartbeat intervals of TiKV.
+	defaultMinStoreHeartbeatInterval  = 10 * time.Second
+	defaultMaxStoreHeartbeatInterval  = time.Minute
+	defaultMinRegionHeartbeatInterval = time.Minute
+	defaultMaxRegionHeartbeatInterval = 5 * time.Minute
+	defaultMaxRegionHeartbeatRate     = 20000
+	defaultIDAllocStep                = 1000
+
+	defaultStoresCheckInterval  = time.Minute
+	defaultMetricsInterval      = time.Minute
+	defaultHistoryPruneInterval = time.Minute
+	defaultSlowRPCThreshold     = 500 * time.Millisecond
+
+	defaultRegionCacheCheckInterval = 10 * time.Minute
+
+	defaultHeartbeatCaptureMaxSize = 300 << 20
+
+	defaultHeartbeatAdmissionMaxPending = 128
+
 	defaultUseRegionStorage    = true
+	defaultEnableUI            = true
 	defaultStrictlyMatchLabel  = false
 	defaultEnableGRPCGateway   = true
 	defaultDisableErrorVerbose = true
@@ -310,6 +373,12 @@ func (c *Config) Validate() error {
 	if !strings.HasPrefix(rel, "..") {
 		return errors.New("log directory shouldn't be the subdirectory of data directory")
 	}
+	if c.Trace.SamplingRatio < 0 || c.Trace.SamplingRatio > 1 {
+		return errors.New("trace sampling-ratio should between 0 and 1")
+	}
+	if c.RootPrefix != "" && (!path.IsAbs(c.RootPrefix) || path.Clean(c.RootPrefix) != c.RootPrefix || c.RootPrefix == "/") {
+		return errors.Errorf("root-prefix %q should be a clean absolute path other than /", c.RootPrefix)
+	}
 
 	return nil
 }
@@ -372,6 +441,7 @@ func (c *Config) Adjust(meta *toml.MetaData) error {
 		adjustString(&c.Name, fmt.Sprintf("%s-%s", defaultName, hostname))
 	}
 	adjustString(&c.DataDir, fmt.Sprintf("default.%s", c.Name))
+	adjustString(&c.RootPrefix, defaultRootPrefix)
 
 	if err := c.Validate(); err != nil {
 		return err
@@ -382,6 +452,9 @@ func (c *Config) Adjust(meta *toml.MetaData) error {
 	adjustString(&c.PeerUrls, defaultPeerUrls)
 	adjustString(&c.AdvertisePeerUrls, c.PeerUrls)
 	adjustDuration(&c.Metric.PushInterval, defaultMetricsPushInterval)
+	if !configMetaData.Child("trace").IsDefined("sampling-ratio") {
+		c.Trace.SamplingRatio = defaultTraceSamplingRatio
+	}
 
 	if len(c.InitialCluster) == 0 {
 		// The advertise peer urls may be http://127.0.0.1:2380,http://127.0.0.1:2381
@@ -406,6 +479,9 @@ func (c *Config) Adjust(meta *toml.MetaData) error {
 	adjustInt64(&c.LeaderLease, defaultLeaderLease)
 
 	adjustDuration(&c.TsoSaveInterval, time.Duration(defaultLeaderLease)*time.Second)
+	if !configMetaData.IsDefined("max-reset-ts-gap") {
+		c.MaxResetTSGap.Duration = defaultMaxResetTSGap
+	}
 
 	if c.nextRetryDelay == 0 {
 		c.nextRetryDelay = defaultNextRetryDelay
@@ -417,6 +493,10 @@ func (c *Config) Adjust(meta *toml.MetaData) error {
 	adjustDuration(&c.ElectionInterval, defaultElectionInterval)
 
 	adjustString(&c.NamespaceClassifier, "table")
+	adjustString(&c.KeyDecoder, "raw")
+	if !configMetaData.IsDefined("heartbeat-capture-max-size") {
+		c.HeartbeatCaptureMaxSize = defaultHeartbeatCaptureMaxSize
+	}
 
 	adjustString(&c.Metric.PushJob, c.Name)
 
@@ -431,6 +511,8 @@ func (c *Config) Adjust(meta *toml.MetaData) error {
 		return err
 	}
 
+	c.fileItems = fileItems(c, configMetaData)
+
 	c.adjustLog(configMetaData.Child("log"))
 	adjustDuration(&c.HeartbeatStreamBindInterval, defaultHeartbeatStreamRebindInterval)
 
@@ -478,6 +560,12 @@ type ScheduleConfig struct {
 	// it will never be used as a source or target store.
 	MaxSnapshotCount    uint64 `toml:"max-snapshot-count,omitempty" json:"max-snapshot-count"`
 	MaxPendingPeerCount uint64 `toml:"max-pending-peer-count,omitempty" json:"max-pending-peer-count"`
+	// SnapshotBandwidthBudget is the total bandwidth per second of the
+	// snapshots sent for the operators adding peers, which is shared by the
+	// operators. The steps adding peers are not dispatched until the budget
+	// is available, assuming a snapshot is as large as the region. 0 means no
+	// limit.
+	SnapshotBandwidthBudget typeutil.ByteSize `toml:"snapshot-bandwidth-budget,omitempty" json:"snapshot-bandwidth-budget"`
 	// If both the size of region is smaller than MaxMergeRegionSize
 	// and the number of rows in region is smaller than MaxMergeRegionKeys,
 	// it will try to merge with adjacent regions.
@@ -487,8 +575,27 @@ type ScheduleConfig struct {
 	SplitMergeInterval typeutil.Duration `toml:"split-merge-interval,omitempty" json:"split-merge-interval"`
 	// EnableOneWayMerge is the option to enable one way merge. This means a Region can only be merged into the next region of it.
 	EnableOneWayMerge bool `toml:"enable-one-way-merge,omitempty" json:"enable-one-way-merge,string"`
+	// EnableCrossTierMerge is the option to allow merging regions on stores of
+	// different types. By default, such regions are merged only if the range
+	// of the merged region is labeled for the tier of the target region.
+	EnableCrossTierMerge bool `toml:"enable-cross-tier-merge,omitempty" json:"enable-cross-tier-merge,string"`
+	// MaxMergeChainLength is the max number of adjacent small regions merged
+	// into one region in sequence, without waiting for the patrol to check
+	// them again. 0 means regions are merged in pairs.
+	MaxMergeChainLength uint64 `toml:"max-merge-chain-length,omitempty" json:"max-merge-chain-length"`
+	// LongEmptyRegionDuration is how long a region has to stay empty to be
+	// reported as a long empty region, such as the ones of dropped tables.
+	LongEmptyRegionDuration typeutil.Duration `toml:"long-empty-region-duration,omitempty" json:"long-empty-region-duration"`
+	// EnableEmptyRegionMerge is the option to merge the long empty regions
+	// even if they are split recently, or merging regions is disabled by
+	// MaxMergeRegionSize or MaxMergeRegionKeys.
+	EnableEmptyRegionMerge bool `toml:"enable-empty-region-merge,omitempty" json:"enable-empty-region-merge,string"`
 	// PatrolRegionInterval is the interval for scanning region during patrol.
 	PatrolRegionInterval typeutil.Duration `toml:"patrol-region-interval,omitempty" json:"patrol-region-interval"`
+	// PatrolRegionWorkerCount is the number of workers to patrol regions. The
+	// key space is split into shards with about the same number of regions,
+	// and each worker patrols a shard.
+	PatrolRegionWorkerCount uint64 `toml:"patrol-region-worker-count,omitempty" json:"patrol-region-worker-count"`
 	// MaxStoreDownTime is the max duration after which
 	// a store will be considered to be down if it hasn't reported heartbeats.
 	MaxStoreDownTime typeutil.Duration `toml:"max-store-down-time,omitempty" json:"max-store-down-time"`
@@ -506,6 +613,29 @@ type ScheduleConfig struct {
 	// If the number of times a region hits the hot cache is greater than this
 	// threshold, it is considered a hot region.
 	HotRegionCacheHitsThreshold uint64 `toml:"hot-region-cache-hits-threshold,omitempty" json:"hot-region-cache-hits-threshold"`
+	// WarmRegionAccessThreshold is the number of the recent region heartbeats
+	// reporting accesses for a region to be regarded warm, so that the regions
+	// touched by a single scan are still cold.
+	WarmRegionAccessThreshold uint64 `toml:"warm-region-access-threshold,omitempty" json:"warm-region-access-threshold"`
+	// RegionAccessMinKeys is the min number of the keys read and written in a
+	// region heartbeat to count it as an access.
+	RegionAccessMinKeys uint64 `toml:"region-access-min-keys,omitempty" json:"region-access-min-keys"`
+	// RegionHeartbeatFlowDelta is the max difference of the bytes and the keys
+	// read and written between a region heartbeat and the cached region to
+	// skip the heartbeat, if nothing else changes and the region is not in the
+	// hot cache. 0 means no heartbeat is skipped.
+	RegionHeartbeatFlowDelta uint64 `toml:"region-heartbeat-flow-delta,omitempty" json:"region-heartbeat-flow-delta"`
+	// StoreStatsWindowSize is the number of the recent store heartbeats whose
+	// median is the flow rate of a store, which filters the noise.
+	StoreStatsWindowSize uint64 `toml:"store-stats-window-size,omitempty" json:"store-stats-window-size"`
+	// StoreStatsEWMADecay is the weight of the history in the exponentially
+	// weighted moving average of the medians, which smooths the flow rates of
+	// a store further. 0 means the medians are used directly.
+	StoreStatsEWMADecay float64 `toml:"store-stats-ewma-decay,omitempty" json:"store-stats-ewma-decay"`
+	// StoreStatsLongWindows are the windows that the flow rates of a store are
+	// averaged in, for the schedulers needing stability over long horizons.
+	// Each window is downsampled into 60 buckets.
+	StoreStatsLongWindows []typeutil.Duration `toml:"store-stats-long-windows,omitempty" json:"store-stats-long-windows"`
 	// StoreBalanceRate is the maximum of balance rate for each store.
 	StoreBalanceRate float64 `toml:"store-balance-rate,omitempty" json:"store-balance-rate"`
 	// TolerantSizeRatio is the ratio of buffer size for balance scheduler.
@@ -524,6 +654,49 @@ type ScheduleConfig struct {
 	HighSpaceRatio float64 `toml:"high-space-ratio,omitempty" json:"high-space-ratio"`
 	// SchedulerMaxWaitingOperator is the max coexist operators for each scheduler.
 	SchedulerMaxWaitingOperator uint64 `toml:"scheduler-max-waiting-operator,omitempty" json:"scheduler-max-waiting-operator"`
+	// MaxStoreLearnerCount is the max number of learners being added to a store
+	// concurrently by operators. 0 means no limit.
+	MaxStoreLearnerCount uint64 `toml:"max-store-learner-count,omitempty" json:"max-store-learner-count"`
+	// MaxLearnerCount is the max number of learners being added concurrently
+	// by operators in the cluster. 0 means no limit.
+	MaxLearnerCount uint64 `toml:"max-learner-count,omitempty" json:"max-learner-count"`
+	// MaxLearnerPromoteLag is the max applied index lag of a learner to the
+	// leader to promote it to a voter. 0 means no limit.
+	MaxLearnerPromoteLag uint64 `toml:"max-learner-promote-lag,omitempty" json:"max-learner-promote-lag"`
+	// MaxLeaderFractionPerStore is the max fraction of all leaders that a
+	// store can hold, regardless of the leader weight. 0 means no limit.
+	MaxLeaderFractionPerStore float64 `toml:"max-leader-fraction-per-store,omitempty" json:"max-leader-fraction-per-store"`
+	// MaxAdjacentLeaderCount is the max number of adjacent regions whose
+	// leaders are in the same store. Leaders of longer chains are spread to
+	// avoid write hot spots of sequential writes. 0 means no limit.
+	MaxAdjacentLeaderCount uint64 `toml:"max-adjacent-leader-count,omitempty" json:"max-adjacent-leader-count"`
+	// MaxStoreRegionCount is the max number of regions that a store can hold.
+	// Stores reaching it are not selected to add peers to. 0 means no limit.
+	MaxStoreRegionCount uint64 `toml:"max-store-region-count,omitempty" json:"max-store-region-count"`
+	// MaxStoreRegionCountPerGB limits the regions of a store relative to its
+	// capacity. The smaller one of the two limits takes effect. 0 means no limit.
+	MaxStoreRegionCountPerGB uint64 `toml:"max-store-region-count-per-gb,omitempty" json:"max-store-region-count-per-gb"`
+	// MemoryPressureRatio is the memory usage ratio reported by a store above
+	// which the store is under memory pressure. Leaders are not transferred to
+	// such stores, and peers are not added to them once the pressure is
+	// sustained. 0 means the memory usage is ignored.
+	MemoryPressureRatio float64 `toml:"memory-pressure-ratio,omitempty" json:"memory-pressure-ratio"`
+	// PlacementScoreMode is how the stores are weighted in the balance scores.
+	// "capacity" weights them by the weights set by users only, and
+	// "benchmark" also by the benchmark scores the stores report relative to
+	// the other stores of the same type, for fleets of heterogeneous disks.
+	PlacementScoreMode string `toml:"placement-score-mode,omitempty" json:"placement-score-mode"`
+	// NetworkDistances are the network distances between the stores, such as
+	// the RTT between zones. The targets closer to the leader are preferred to
+	// receive snapshots when their isolation levels are the same. The RTTs
+	// measured by the stores are used between the stores without distances.
+	NetworkDistances []NetworkDistance `toml:"network-distances,omitempty" json:"network-distances"`
+	// LocationRepairLimits are the max coexist operators moving replicas to
+	// better locations for the violations of each location label, such as
+	// "host", so that the worse violations are not starved by the others. The
+	// violations of a label without a limit are only limited by
+	// ReplicaScheduleLimit.
+	LocationRepairLimits map[string]uint64 `toml:"location-repair-limits,omitempty" json:"location-repair-limits"`
 	// WARN: DisableLearner is deprecated.
 	// DisableLearner is the option to disable using AddLearnerNode instead of AddNode.
 	DisableLearner bool `toml:"disable-raft-learner" json:"disable-raft-learner,string"`
@@ -545,6 +718,17 @@ type ScheduleConfig struct {
 	// DisableNamespaceRelocation is the option to prevent namespace checker
 	// from moving replica to the target namespace.
 	DisableNamespaceRelocation bool `toml:"disable-namespace-relocation" json:"disable-namespace-relocation,string"`
+	// SchedulingHaltWindow is the duration to pause scheduling after PD detects
+	// an unclean leader change or an unstable etcd quorum, so that operators are
+	// not created based on a stale region cache. 0 means never.
+	SchedulingHaltWindow typeutil.Duration `toml:"scheduling-halt-window,omitempty" json:"scheduling-halt-window"`
+	// PreemptiveOperatorKinds are the kinds of operators which may preempt the
+	// in-flight operator of a lower schedule priority on the same region,
+	// including "replica", "namespace" and "balance". Replica repair takes
+	// precedence over namespace relocation, which takes precedence over balance
+	// and then merge. Only the repairs of down, offline or missing replicas
+	// count as replica repair.
+	PreemptiveOperatorKinds []string `toml:"preemptive-operator-kinds,omitempty" json:"preemptive-operator-kinds"`
 
 	// Schedulers support for loading customized schedulers
 	Schedulers SchedulerConfigs `toml:"schedulers,omitempty" json:"schedulers-v2"` // json v2 is for the sake of compatible upgrade
@@ -554,26 +738,61 @@ type ScheduleConfig struct {
 func (c *ScheduleConfig) Clone() *ScheduleConfig {
 	schedulers := make(SchedulerConfigs, len(c.Schedulers))
 	copy(schedulers, c.Schedulers)
+	var distances []NetworkDistance
+	if c.NetworkDistances != nil {
+		distances = make([]NetworkDistance, len(c.NetworkDistances))
+		copy(distances, c.NetworkDistances)
+	}
+	var repairLimits map[string]uint64
+	if c.LocationRepairLimits != nil {
+		repairLimits = make(map[string]uint64, len(c.LocationRepairLimits))
+		for label, limit := range c.LocationRepairLimits {
+			repairLimits[label] = limit
+		}
+	}
 	return &ScheduleConfig{
 		MaxSnapshotCount:             c.MaxSnapshotCount,
 		MaxPendingPeerCount:          c.MaxPendingPeerCount,
+		SnapshotBandwidthBudget:      c.SnapshotBandwidthBudget,
 		MaxMergeRegionSize:           c.MaxMergeRegionSize,
 		MaxMergeRegionKeys:           c.MaxMergeRegionKeys,
 		SplitMergeInterval:           c.SplitMergeInterval,
 		PatrolRegionInterval:         c.PatrolRegionInterval,
+		PatrolRegionWorkerCount:      c.PatrolRegionWorkerCount,
 		MaxStoreDownTime:             c.MaxStoreDownTime,
 		LeaderScheduleLimit:          c.LeaderScheduleLimit,
 		RegionScheduleLimit:          c.RegionScheduleLimit,
 		ReplicaScheduleLimit:         c.ReplicaScheduleLimit,
 		MergeScheduleLimit:           c.MergeScheduleLimit,
 		EnableOneWayMerge:            c.EnableOneWayMerge,
+		EnableCrossTierMerge:         c.EnableCrossTierMerge,
+		MaxMergeChainLength:          c.MaxMergeChainLength,
+		LongEmptyRegionDuration:      c.LongEmptyRegionDuration,
+		EnableEmptyRegionMerge:       c.EnableEmptyRegionMerge,
 		HotRegionScheduleLimit:       c.HotRegionScheduleLimit,
 		HotRegionCacheHitsThreshold:  c.HotRegionCacheHitsThreshold,
+		WarmRegionAccessThreshold:    c.WarmRegionAccessThreshold,
+		RegionAccessMinKeys:          c.RegionAccessMinKeys,
+		RegionHeartbeatFlowDelta:     c.RegionHeartbeatFlowDelta,
+		StoreStatsWindowSize:         c.StoreStatsWindowSize,
+		StoreStatsEWMADecay:          c.StoreStatsEWMADecay,
+		StoreStatsLongWindows:        append([]typeutil.Duration(nil), c.StoreStatsLongWindows...),
 		StoreBalanceRate:             c.StoreBalanceRate,
 		TolerantSizeRatio:            c.TolerantSizeRatio,
 		LowSpaceRatio:                c.LowSpaceRatio,
 		HighSpaceRatio:               c.HighSpaceRatio,
 		SchedulerMaxWaitingOperator:  c.SchedulerMaxWaitingOperator,
+		MaxStoreLearnerCount:         c.MaxStoreLearnerCount,
+		MaxLearnerCount:              c.MaxLearnerCount,
+		MaxLearnerPromoteLag:         c.MaxLearnerPromoteLag,
+		MaxLeaderFractionPerStore:    c.MaxLeaderFractionPerStore,
+		MaxAdjacentLeaderCount:       c.MaxAdjacentLeaderCount,
+		MaxStoreRegionCount:          c.MaxStoreRegionCount,
+		MaxStoreRegionCountPerGB:     c.MaxStoreRegionCountPerGB,
+		MemoryPressureRatio:          c.MemoryPressureRatio,
+		PlacementScoreMode:           c.PlacementScoreMode,
+		NetworkDistances:             distances,
+		LocationRepairLimits:         repairLimits,
 		DisableLearner:               c.DisableLearner,
 		DisableRemoveDownReplica:     c.DisableRemoveDownReplica,
 		DisableReplaceOfflineReplica: c.DisableReplaceOfflineReplica,
@@ -581,6 +800,8 @@ func (c *ScheduleConfig) Clone() *ScheduleConfig {
 		DisableRemoveExtraReplica:    c.DisableRemoveExtraReplica,
 		DisableLocationReplacement:   c.DisableLocationReplacement,
 		DisableNamespaceRelocation:   c.DisableNamespaceRelocation,
+		SchedulingHaltWindow:         c.SchedulingHaltWindow,
+		PreemptiveOperatorKinds:      append([]string(nil), c.PreemptiveOperatorKinds...),
 		Schedulers:                   schedulers,
 	}
 }
@@ -592,7 +813,9 @@ const (
 	defaultMaxMergeRegionSize     = 20
 	defaultMaxMergeRegionKeys     = 200000
 	defaultSplitMergeInterval     = 1 * time.Hour
+	defaultEmptyRegionDuration    = 1 * time.Hour
 	defaultPatrolRegionInterval   = 100 * time.Millisecond
+	defaultPatrolRegionWorkers    = 1
 	defaultMaxStoreDownTime       = 30 * time.Minute
 	defaultLeaderScheduleLimit    = 4
 	defaultRegionScheduleLimit    = 64
@@ -603,10 +826,28 @@ const (
 	defaultTolerantSizeRatio      = 0
 	defaultLowSpaceRatio          = 0.8
 	defaultHighSpaceRatio         = 0.6
+	defaultMemoryPressureRatio    = 0.9
 	// defaultHotRegionCacheHitsThreshold is the low hit number threshold of the
 	// hot region.
 	defaultHotRegionCacheHitsThreshold = 3
+	defaultWarmRegionAccessThreshold   = 3
+	defaultRegionAccessMinKeys         = 1
+	defaultStoreStatsWindowSize        = 3
 	defaultSchedulerMaxWaitingOperator = 3
+	defaultSchedulingHaltWindow        = 3 * time.Minute
+)
+
+// The kinds of operators which may preempt the others.
+const (
+	PreemptiveReplica   = "replica"
+	PreemptiveNamespace = "namespace"
+	PreemptiveBalance   = "balance"
+)
+
+// The modes of weighting the stores in the balance scores.
+const (
+	PlacementScoreCapacity  = "capacity"
+	PlacementScoreBenchmark = "benchmark"
 )
 
 func (c *ScheduleConfig) adjust(meta *configMetaData) error {
@@ -623,8 +864,13 @@ func (c *ScheduleConfig) adjust(meta *configMetaData) error {
 		adjustUint64(&c.MaxMergeRegionKeys, defaultMaxMergeRegionKeys)
 	}
 	adjustDuration(&c.SplitMergeInterval, defaultSplitMergeInterval)
+	adjustDuration(&c.LongEmptyRegionDuration, defaultEmptyRegionDuration)
 	adjustDuration(&c.PatrolRegionInterval, defaultPatrolRegionInterval)
+	adjustUint64(&c.PatrolRegionWorkerCount, defaultPatrolRegionWorkers)
 	adjustDuration(&c.MaxStoreDownTime, defaultMaxStoreDownTime)
+	if !meta.IsDefined("scheduling-halt-window") {
+		adjustDuration(&c.SchedulingHaltWindow, defaultSchedulingHaltWindow)
+	}
 	if !meta.IsDefined("leader-schedule-limit") {
 		adjustUint64(&c.LeaderScheduleLimit, defaultLeaderScheduleLimit)
 	}
@@ -643,6 +889,12 @@ func (c *ScheduleConfig) adjust(meta *configMetaData) error {
 	if !meta.IsDefined("hot-region-cache-hits-threshold") {
 		adjustUint64(&c.HotRegionCacheHitsThreshold, defaultHotRegionCacheHitsThreshold)
 	}
+	adjustUint64(&c.WarmRegionAccessThreshold, defaultWarmRegionAccessThreshold)
+	adjustUint64(&c.RegionAccessMinKeys, defaultRegionAccessMinKeys)
+	adjustUint64(&c.StoreStatsWindowSize, defaultStoreStatsWindowSize)
+	if !meta.IsDefined("store-stats-long-windows") && len(c.StoreStatsLongWindows) == 0 {
+		c.StoreStatsLongWindows = []typeutil.Duration{typeutil.NewDuration(time.Hour), typeutil.NewDuration(24 * time.Hour)}
+	}
 	if !meta.IsDefined("tolerant-size-ratio") {
 		adjustFloat64(&c.TolerantSizeRatio, defaultTolerantSizeRatio)
 	}
@@ -652,6 +904,10 @@ func (c *ScheduleConfig) adjust(meta *configMetaData) error {
 	adjustFloat64(&c.StoreBalanceRate, defaultStoreBalanceRate)
 	adjustFloat64(&c.LowSpaceRatio, defaultLowSpaceRatio)
 	adjustFloat64(&c.HighSpaceRatio, defaultHighSpaceRatio)
+	if !meta.IsDefined("memory-pressure-ratio") {
+		adjustFloat64(&c.MemoryPressureRatio, defaultMemoryPressureRatio)
+	}
+	adjustString(&c.PlacementScoreMode, PlacementScoreCapacity)
 	adjustSchedulers(&c.Schedulers, defaultSchedulers)
 
 	return c.Validate()
@@ -671,11 +927,44 @@ func (c *ScheduleConfig) Validate() error {
 	if c.LowSpaceRatio <= c.HighSpaceRatio {
 		return errors.New("low-space-ratio should be larger than high-space-ratio")
 	}
+	if c.MaxLeaderFractionPerStore < 0 || c.MaxLeaderFractionPerStore > 1 {
+		return errors.New("max-leader-fraction-per-store should between 0 and 1")
+	}
+	if c.MemoryPressureRatio < 0 || c.MemoryPressureRatio > 1 {
+		return errors.New("memory-pressure-ratio should between 0 and 1")
+	}
+	if c.PlacementScoreMode != "" && c.PlacementScoreMode != PlacementScoreCapacity && c.PlacementScoreMode != PlacementScoreBenchmark {
+		return errors.Errorf("placement-score-mode should be %q or %q", PlacementScoreCapacity, PlacementScoreBenchmark)
+	}
+	for _, kind := range c.PreemptiveOperatorKinds {
+		if kind != PreemptiveReplica && kind != PreemptiveNamespace && kind != PreemptiveBalance {
+			return errors.Errorf("preemptive-operator-kinds should be in %q, %q and %q", PreemptiveReplica, PreemptiveNamespace, PreemptiveBalance)
+		}
+	}
 	for _, scheduleConfig := range c.Schedulers {
 		if !schedule.IsSchedulerRegistered(scheduleConfig.Type) {
 			return errors.Errorf("create func of %v is not registered, maybe misspelled", scheduleConfig.Type)
 		}
 	}
+	for _, d := range c.NetworkDistances {
+		if d.Key == "" {
+			return errors.New("key of network-distances should not be empty")
+		}
+		if d.Distance < 0 {
+			return errors.New("distance of network-distances should be nonnegative")
+		}
+	}
+	if c.StoreStatsWindowSize == 0 {
+		return errors.New("store-stats-window-size should be positive")
+	}
+	if c.StoreStatsEWMADecay < 0 || c.StoreStatsEWMADecay >= 1 {
+		return errors.New("store-stats-ewma-decay should be at least 0 and less than 1")
+	}
+	for _, w := range c.StoreStatsLongWindows {
+		if w.Duration < time.Minute {
+			return errors.New("store-stats-long-windows should be at least 1m")
+		}
+	}
 	return nil
 }
 
@@ -695,6 +984,28 @@ type SchedulerConfig struct {
 	Type    string   `toml:"type" json:"type"`
 	Args    []string `toml:"args,omitempty" json:"args"`
 	Disable bool     `toml:"disable" json:"disable"`
+	// DeniedStores are the stores the scheduler never schedules regions or
+	// leaders from or to, while other schedulers still work on them.
+	DeniedStores []uint64 `toml:"denied-stores,omitempty" json:"denied-stores,omitempty"`
+}
+
+// NetworkDistance is the network distance between the stores labeled with the
+// two values of the label key. The distance is symmetric.
+type NetworkDistance struct {
+	Key      string  `toml:"key" json:"key"`
+	From     string  `toml:"from" json:"from"`
+	To       string  `toml:"to" json:"to"`
+	Distance float64 `toml:"distance" json:"distance"`
+}
+
+// Match checks if the distance is between the two stores.
+func (d NetworkDistance) Match(from, to *core.StoreInfo) bool {
+	v1, v2 := from.GetLabelValue(d.Key), to.GetLabelValue(d.Key)
+	if v1 == "" || v2 == "" {
+		return false
+	}
+	return (strings.EqualFold(v1, d.From) && strings.EqualFold(v2, d.To)) ||
+		(strings.EqualFold(v1, d.To) && strings.EqualFold(v2, d.From))
 }
 
 var defaultSchedulers = SchedulerConfigs{
@@ -810,19 +1121,171 @@ func (s SecurityConfig) ToTLSConfig() (*tls.Config, error) {
 	return tlsConfig, nil
 }
 
+// VerifyCertHosts checks if the certificate is valid for the hosts of the
+// urls, so that the peers verifying the host names can connect to PD by them.
+func (s SecurityConfig) VerifyCertHosts(urls ...string) error {
+	if len(s.CertPath) == 0 {
+		return nil
+	}
+	data, err := ioutil.ReadFile(s.CertPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return errors.Errorf("no certificate found in %s", s.CertPath)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, u := range urls {
+		if err := netutil.VerifyCertHost(cert, u); err != nil {
+			return errors.WithMessage(err, u)
+		}
+	}
+	return nil
+}
+
 // PDServerConfig is the configuration for pd server.
 type PDServerConfig struct {
 	// UseRegionStorage enables the independent region storage.
 	UseRegionStorage bool `toml:"use-region-storage" json:"use-region-storage,string"`
+	// RegionStorageBackend is the embedded kv of the region storage, which is
+	// either "leveldb" or "bbolt". It takes effect after restarting PD.
+	RegionStorageBackend string `toml:"region-storage-backend" json:"region-storage-backend"`
+	// MinStoreHeartbeatInterval and MaxStoreHeartbeatInterval are the bounds of
+	// the store heartbeat interval recommended to stores.
+	MinStoreHeartbeatInterval typeutil.Duration `toml:"min-store-heartbeat-interval" json:"min-store-heartbeat-interval"`
+	MaxStoreHeartbeatInterval typeutil.Duration `toml:"max-store-heartbeat-interval" json:"max-store-heartbeat-interval"`
+	// MinRegionHeartbeatInterval and MaxRegionHeartbeatInterval are the bounds of
+	// the region heartbeat interval recommended to stores.
+	MinRegionHeartbeatInterval typeutil.Duration `toml:"min-region-heartbeat-interval" json:"min-region-heartbeat-interval"`
+	MaxRegionHeartbeatInterval typeutil.Duration `toml:"max-region-heartbeat-interval" json:"max-region-heartbeat-interval"`
+	// MaxRegionHeartbeatRate is the number of region heartbeats per second PD
+	// can handle comfortably. Recommended intervals are enlarged when the
+	// expected or observed rate exceeds it.
+	MaxRegionHeartbeatRate uint64 `toml:"max-region-heartbeat-rate" json:"max-region-heartbeat-rate"`
+	// IDAllocStep is the number of IDs reserved from etcd at a time. Larger
+	// steps hit etcd less often, but more IDs are skipped when the leader
+	// changes. It takes effect after restarting PD.
+	IDAllocStep uint64 `toml:"id-alloc-step" json:"id-alloc-step"`
+	// HeartbeatAdmissionCPUThreshold is the CPU usage of PD over all cores,
+	// above which PD is regarded as overloaded. 0 means never, which is the
+	// default since the CPU usage may be taken by other processes.
+	HeartbeatAdmissionCPUThreshold float64 `toml:"heartbeat-admission-cpu-threshold" json:"heartbeat-admission-cpu-threshold"`
+	// HeartbeatAdmissionMaxPending is the number of region heartbeats being
+	// processed concurrently, above which PD is regarded as overloaded. 0
+	// means never. When overloaded, region heartbeats which change nothing but
+	// statistics are shed, and stores are asked to use the max intervals.
+	HeartbeatAdmissionMaxPending uint64 `toml:"heartbeat-admission-max-pending" json:"heartbeat-admission-max-pending"`
+	// ResolveStoreAddress enables resolving the host names of the stores when
+	// checking duplicated store addresses, so that the aliases of a host are
+	// detected, at the cost of DNS lookups when stores are put.
+	ResolveStoreAddress bool `toml:"resolve-store-address" json:"resolve-store-address,string"`
+	// EnableSyntheticLoad enables the API to inject synthetic flows of regions
+	// and stores, which is only for testing the scheduling without real
+	// traffic. It should never be enabled in production.
+	EnableSyntheticLoad bool `toml:"enable-synthetic-load" json:"enable-synthetic-load,string"`
+	// RequireStoreCredential rejects the store heartbeats and the puts of the
+	// stores without credentials. The stores with credentials are always
+	// authenticated.
+	RequireStoreCredential bool `toml:"require-store-credential" json:"require-store-credential,string"`
+	// StoresCheckInterval is the interval to check the states of the stores
+	// and the expiry of the blocked stores.
+	StoresCheckInterval typeutil.Duration `toml:"stores-check-interval" json:"stores-check-interval"`
+	// MetricsInterval is the interval to collect the metrics of the cluster,
+	// which may be slowed down for large clusters since it walks through all
+	// the stores and the region statistics.
+	MetricsInterval typeutil.Duration `toml:"metrics-interval" json:"metrics-interval"`
+	// HistoryPruneInterval is the interval to prune the expired operator
+	// histories.
+	HistoryPruneInterval typeutil.Duration `toml:"history-prune-interval" json:"history-prune-interval"`
+	// SlowRPCThreshold is the duration above which the gRPC requests are
+	// logged into the slow log. 0 means never.
+	SlowRPCThreshold typeutil.Duration `toml:"slow-rpc-threshold" json:"slow-rpc-threshold"`
+	// UnknownStoreBlockThreshold is the number of heartbeats from a store not
+	// registered in the cluster, after which the heartbeats of the store from
+	// the same host are rejected at the gRPC level. 0 means never.
+	UnknownStoreBlockThreshold uint64 `toml:"unknown-store-block-threshold" json:"unknown-store-block-threshold"`
+	// RegionCacheCheckInterval is the interval for the leader to compare the
+	// hashes of its region cache with the ones synchronized by the followers.
+	RegionCacheCheckInterval typeutil.Duration `toml:"region-cache-check-interval" json:"region-cache-check-interval"`
+	// EnableUI serves the built-in UI of the cluster overview at /pd/ui/. It
+	// can be disabled for the deployments exposing the HTTP port to untrusted
+	// networks.
+	EnableUI bool `toml:"enable-ui" json:"enable-ui,string"`
+	// StatsMemoryBudget bounds the estimated memory of the statistics
+	// histories. The hot peers kept for each store are reduced to what is
+	// left by the store statistics, evicting the peers of the lowest flows.
+	// 0 means unbounded.
+	StatsMemoryBudget typeutil.ByteSize `toml:"stats-memory-budget" json:"stats-memory-budget"`
 }
 
 func (c *PDServerConfig) adjust(meta *configMetaData) error {
 	if !meta.IsDefined("use-region-storage") {
 		c.UseRegionStorage = defaultUseRegionStorage
 	}
+	if !meta.IsDefined("enable-ui") {
+		c.EnableUI = defaultEnableUI
+	}
+	adjustString(&c.RegionStorageBackend, kv.LeveldbBackend)
+	adjustDuration(&c.MinStoreHeartbeatInterval, defaultMinStoreHeartbeatInterval)
+	adjustDuration(&c.MaxStoreHeartbeatInterval, defaultMaxStoreHeartbeatInterval)
+	adjustDuration(&c.MinRegionHeartbeatInterval, defaultMinRegionHeartbeatInterval)
+	adjustDuration(&c.MaxRegionHeartbeatInterval, defaultMaxRegionHeartbeatInterval)
+	adjustUint64(&c.MaxRegionHeartbeatRate, defaultMaxRegionHeartbeatRate)
+	adjustUint64(&c.IDAllocStep, defaultIDAllocStep)
+	adjustDuration(&c.StoresCheckInterval, defaultStoresCheckInterval)
+	adjustDuration(&c.MetricsInterval, defaultMetricsInterval)
+	adjustDuration(&c.HistoryPruneInterval, defaultHistoryPruneInterval)
+	adjustDuration(&c.RegionCacheCheckInterval, defaultRegionCacheCheckInterval)
+	if !meta.IsDefined("slow-rpc-threshold") {
+		adjustDuration(&c.SlowRPCThreshold, defaultSlowRPCThreshold)
+	}
+	if !meta.IsDefined("heartbeat-admission-max-pending") {
+		c.HeartbeatAdmissionMaxPending = defaultHeartbeatAdmissionMaxPending
+	}
+	return c.Validate()
+}
+
+// Validate is used to validate if some pd-server configurations are right.
+func (c *PDServerConfig) Validate() error {
+	if c.RegionStorageBackend != kv.LeveldbBackend && c.RegionStorageBackend != kv.BoltBackend {
+		return errors.Errorf("unknown region-storage-backend %v", c.RegionStorageBackend)
+	}
+	if c.MinStoreHeartbeatInterval.Duration > c.MaxStoreHeartbeatInterval.Duration {
+		return errors.Errorf("min-store-heartbeat-interval %v should not be larger than max-store-heartbeat-interval %v", c.MinStoreHeartbeatInterval.Duration, c.MaxStoreHeartbeatInterval.Duration)
+	}
+	if c.MinRegionHeartbeatInterval.Duration > c.MaxRegionHeartbeatInterval.Duration {
+		return errors.Errorf("min-region-heartbeat-interval %v should not be larger than max-region-heartbeat-interval %v", c.MinRegionHeartbeatInterval.Duration, c.MaxRegionHeartbeatInterval.Duration)
+	}
+	if c.HeartbeatAdmissionCPUThreshold < 0 || c.HeartbeatAdmissionCPUThreshold > 1 {
+		return errors.New("heartbeat-admission-cpu-threshold should between 0 and 1")
+	}
+	if c.SlowRPCThreshold.Duration < 0 {
+		return errors.New("slow-rpc-threshold should not be negative")
+	}
+	if c.StoresCheckInterval.Duration <= 0 || c.MetricsInterval.Duration <= 0 || c.HistoryPruneInterval.Duration <= 0 {
+		return errors.New("stores-check-interval, metrics-interval and history-prune-interval should be positive")
+	}
+	if c.RegionCacheCheckInterval.Duration <= 0 {
+		return errors.New("region-cache-check-interval should be positive")
+	}
 	return nil
 }
 
+// ClassifierConfig is the configuration for the namespace classifier.
+type ClassifierConfig struct {
+	// ReloadInterval is the interval to reload namespaces from the backing
+	// data of the classifier. 0 means never reload.
+	ReloadInterval typeutil.Duration `toml:"reload-interval" json:"reload-interval"`
+	// Args are the arguments passed to the classifier, such as the key
+	// prefixes of the "key-prefix" classifier and the URL of the "http"
+	// classifier.
+	Args map[string]string `toml:"args" json:"args"`
+}
+
 // StoreLabel is the config item of LabelPropertyConfig.
 type StoreLabel struct {
 	Key   string `toml:"key" json:"key"`
@@ -843,16 +1306,36 @@ func (c LabelPropertyConfig) Clone() LabelPropertyConfig {
 	return m
 }
 
+// SplitUrls splits a comma-separated list of urls, ignoring the spaces and
+// the empty items.
+func SplitUrls(s string) []string {
+	var urls []string
+	for _, u := range strings.Split(s, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
 // ParseUrls parse a string into multiple urls.
 // Export for api.
 func ParseUrls(s string) ([]url.URL, error) {
 	items := strings.Split(s, ",")
 	urls := make([]url.URL, 0, len(items))
 	for _, item := range items {
+		item = strings.TrimSpace(item)
 		u, err := url.Parse(item)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
+		// url.Parse accepts IPv6 literals without brackets, which are
+		// ambiguous with the port.
+		if u.Host != "" {
+			if _, _, err := netutil.SplitHostPort(item); err != nil {
+				return nil, errors.WithMessage(err, "invalid url")
+			}
+		}
 
 		urls = append(urls, *u)
 	}
@@ -897,6 +1380,7 @@ func (c *Config) GenEmbedEtcdConfig() (*embed.Config, error) {
 	cfg.AutoCompactionMode = c.AutoCompactionMode
 	cfg.AutoCompactionRetention = c.AutoCompactionRetention
 	cfg.QuotaBackendBytes = int64(c.QuotaBackendBytes)
+	cfg.MaxTxnOps = kv.MaxTxnOps
 
 	cfg.ClientTLSInfo.ClientCertAuth = len(c.Security.CAPath) != 0
 	cfg.ClientTLSInfo.TrustedCAFile = c.Security.CAPath
@@ -23,6 +23,8 @@ import (
 
 	"github.com/BurntSushi/toml"
 	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/pkg/typeutil"
 	"github.com/pingcap/pd/server/core"
 	"github.com/pingcap/pd/server/kv"
 
@@ -51,6 +53,19 @@ func (s *testConfigSuite) TestBadFormatJoinAddr(c *C) {
 	c.Assert(cfg.Adjust(nil), NotNil)
 }
 
+func (s *testConfigSuite) TestParseUrls(c *C) {
+	urls, err := ParseUrls("http://127.0.0.1:2379, http://[::1]:2379")
+	c.Assert(err, IsNil)
+	c.Assert(urls, HasLen, 2)
+	c.Assert(urls[0].Host, Equals, "127.0.0.1:2379")
+	c.Assert(urls[1].Host, Equals, "[::1]:2379")
+	_, err = ParseUrls("http://127.0.0.1:2379,http://::1:2379")
+	c.Assert(err, NotNil)
+
+	c.Assert(SplitUrls(" http://a:2379,,http://b:2379 "), DeepEquals, []string{"http://a:2379", "http://b:2379"})
+	c.Assert(SplitUrls(""), HasLen, 0)
+}
+
 func (s *testConfigSuite) TestReloadConfig(c *C) {
 	opt, err := newTestScheduleOption()
 	c.Assert(err, IsNil)
@@ -76,6 +91,97 @@ func (s *testConfigSuite) TestReloadConfig(c *C) {
 	}
 	c.Assert(newOpt.GetMaxReplicas("default"), Equals, 5)
 	c.Assert(newOpt.GetMaxSnapshotCount(), Equals, uint64(10))
+
+	// The args of a default scheduler are kept.
+	newOpt.AddSchedulerCfg("balance-region", []string{"61", "63"})
+	c.Assert(newOpt.Persist(storage), IsNil)
+	newOpt, err = newTestScheduleOption()
+	c.Assert(err, IsNil)
+	c.Assert(newOpt.Reload(storage), IsNil)
+	schedulers = newOpt.GetSchedulers()
+	c.Assert(schedulers, HasLen, 5)
+	c.Assert(schedulers[0].Type, Equals, "balance-region")
+	c.Assert(schedulers[0].Args, DeepEquals, []string{"61", "63"})
+}
+
+func (s *testConfigSuite) TestSchedulerDeniedStores(c *C) {
+	opt, err := newTestScheduleOption()
+	c.Assert(err, IsNil)
+	c.Assert(opt.GetSchedulerDeniedStores("balance-region-scheduler"), HasLen, 0)
+	c.Assert(opt.SetSchedulerDeniedStores("balance-region-scheduler", []uint64{1, 2}), IsNil)
+	c.Assert(opt.GetSchedulerDeniedStores("balance-region-scheduler"), DeepEquals, []uint64{1, 2})
+	c.Assert(opt.GetSchedulerDeniedStores("balance-leader-scheduler"), HasLen, 0)
+	c.Assert(opt.SetSchedulerDeniedStores("not-found-scheduler", []uint64{1}), NotNil)
+
+	// The deny lists follow the configurations stored in place.
+	cfg := opt.Load()
+	for i := range cfg.Schedulers {
+		if cfg.Schedulers[i].Type == "balance-region" {
+			cfg.Schedulers[i].DeniedStores = []uint64{3}
+		}
+	}
+	opt.Store(cfg)
+	c.Assert(opt.GetSchedulerDeniedStores("balance-region-scheduler"), DeepEquals, []uint64{3})
+	c.Assert(opt.RemoveSchedulerCfg("balance-region-scheduler"), IsNil)
+	c.Assert(opt.GetSchedulerDeniedStores("balance-region-scheduler"), HasLen, 0)
+}
+
+func (s *testConfigSuite) TestEffectiveConfig(c *C) {
+	cfgData := `
+[schedule]
+leader-schedule-limit = 8
+`
+	newOption := func() *ScheduleOption {
+		cfg := NewConfig()
+		meta, err := toml.Decode(cfgData, &cfg)
+		c.Assert(err, IsNil)
+		c.Assert(cfg.Adjust(&meta), IsNil)
+		return NewScheduleOption(cfg)
+	}
+	find := func(opt *ScheduleOption, name string) *EffectiveConfigItem {
+		for _, item := range opt.GetEffectiveConfig() {
+			if item.Name == name {
+				return item
+			}
+		}
+		return nil
+	}
+
+	opt := newOption()
+	item := find(opt, "schedule.leader-schedule-limit")
+	c.Assert(item.Value, Equals, uint64(8))
+	c.Assert(item.Source, Equals, SourceFile)
+	item = find(opt, "schedule.max-snapshot-count")
+	c.Assert(item.Value, Equals, uint64(defaultMaxSnapshotCount))
+	c.Assert(item.Source, Equals, SourceDefault)
+	c.Assert(item.UpdateTime.IsZero(), IsTrue)
+	c.Assert(find(opt, "replication.max-replicas").Source, Equals, SourceDefault)
+	c.Assert(find(opt, "cluster-version"), NotNil)
+
+	// The items changed at runtime are recorded once persisted.
+	storage := core.NewStorage(kv.NewMemoryKV())
+	scheduleCfg := opt.Load().Clone()
+	scheduleCfg.MaxSnapshotCount = 10
+	opt.Store(scheduleCfg)
+	opt.SetMaxReplicas(5)
+	c.Assert(opt.Persist(storage), IsNil)
+	item = find(opt, "schedule.max-snapshot-count")
+	c.Assert(item.Value, Equals, uint64(10))
+	c.Assert(item.Source, Equals, SourceRuntime)
+	c.Assert(item.UpdateTime.IsZero(), IsFalse)
+	updateTime := item.UpdateTime
+	c.Assert(find(opt, "replication.max-replicas").Source, Equals, SourceRuntime)
+	c.Assert(find(opt, "schedule.leader-schedule-limit").Source, Equals, SourceFile)
+
+	// The provenance is kept after reloading.
+	newOpt := newOption()
+	c.Assert(newOpt.Reload(storage), IsNil)
+	item = find(newOpt, "schedule.max-snapshot-count")
+	c.Assert(item.Value, Equals, uint64(10))
+	c.Assert(item.Source, Equals, SourceRuntime)
+	c.Assert(item.UpdateTime.Equal(updateTime), IsTrue)
+	c.Assert(find(newOpt, "schedule.leader-schedule-limit").Source, Equals, SourceFile)
+	c.Assert(find(newOpt, "schedule.max-merge-region-size").Source, Equals, SourceDefault)
 }
 
 func (s *testConfigSuite) TestValidation(c *C) {
@@ -84,6 +190,15 @@ func (s *testConfigSuite) TestValidation(c *C) {
 
 	cfg.Log.File.Filename = path.Join(cfg.DataDir, "test")
 	c.Assert(cfg.Validate(), NotNil)
+	cfg.Log.File.Filename = ""
+	c.Assert(cfg.RootPrefix, Equals, "/pd")
+	c.Assert(cfg.Validate(), IsNil)
+	for _, prefix := range []string{"pd", "/", "/pd/", "/pd//a"} {
+		cfg.RootPrefix = prefix
+		c.Assert(cfg.Validate(), NotNil)
+	}
+	cfg.RootPrefix = "/pd-test"
+	c.Assert(cfg.Validate(), IsNil)
 
 	// check schedule config
 	cfg.Schedule.HighSpaceRatio = -0.1
@@ -96,19 +211,80 @@ func (s *testConfigSuite) TestValidation(c *C) {
 	c.Assert(cfg.Schedule.Validate(), NotNil)
 	cfg.Schedule.LowSpaceRatio = 0.8
 	c.Assert(cfg.Schedule.Validate(), IsNil)
+	cfg.Schedule.MaxLeaderFractionPerStore = 1.5
+	c.Assert(cfg.Schedule.Validate(), NotNil)
+	cfg.Schedule.MaxLeaderFractionPerStore = 0.5
+	c.Assert(cfg.Schedule.Validate(), IsNil)
 	cfg.Schedule.TolerantSizeRatio = -0.6
 	c.Assert(cfg.Schedule.Validate(), NotNil)
+	cfg.Schedule.TolerantSizeRatio = 0
+	cfg.Schedule.NetworkDistances = []NetworkDistance{{Key: "zone", From: "z1", To: "z2", Distance: -1}}
+	c.Assert(cfg.Schedule.Validate(), NotNil)
+	cfg.Schedule.NetworkDistances = []NetworkDistance{{From: "z1", To: "z2", Distance: 1}}
+	c.Assert(cfg.Schedule.Validate(), NotNil)
+	cfg.Schedule.NetworkDistances = nil
+	c.Assert(cfg.Schedule.Validate(), IsNil)
+	cfg.Schedule.StoreStatsEWMADecay = 1
+	c.Assert(cfg.Schedule.Validate(), NotNil)
+	cfg.Schedule.StoreStatsEWMADecay = 0.5
+	cfg.Schedule.StoreStatsLongWindows = []typeutil.Duration{typeutil.NewDuration(time.Second)}
+	c.Assert(cfg.Schedule.Validate(), NotNil)
+	cfg.Schedule.StoreStatsLongWindows = []typeutil.Duration{typeutil.NewDuration(time.Hour)}
+	c.Assert(cfg.Schedule.Validate(), IsNil)
+
+	// check pd-server config
+	c.Assert(cfg.PDServerCfg.MetricsInterval.Duration, Equals, time.Minute)
+	cfg.PDServerCfg.MetricsInterval.Duration = 0
+	c.Assert(cfg.PDServerCfg.Validate(), NotNil)
+	cfg.PDServerCfg.MetricsInterval.Duration = 5 * time.Minute
+	c.Assert(cfg.PDServerCfg.Validate(), IsNil)
+}
+
+func (s *testConfigSuite) TestNetworkDistance(c *C) {
+	opt, err := newTestScheduleOption()
+	c.Assert(err, IsNil)
+	store := func(id uint64, labels ...string) *core.StoreInfo {
+		meta := &metapb.Store{Id: id}
+		for i := 0; i < len(labels); i += 2 {
+			meta.Labels = append(meta.Labels, &metapb.StoreLabel{Key: labels[i], Value: labels[i+1]})
+		}
+		return core.NewStoreInfo(meta)
+	}
+	s1 := store(1, "zone", "z1", "host", "h1")
+	s2 := store(2, "zone", "z2", "host", "h2")
+	s3 := store(3, "zone", "z1", "host", "h3")
+	s4 := store(4)
+	c.Assert(opt.GetNetworkDistance(s1, s2), Equals, 0.0)
+
+	cfg := opt.Load().Clone()
+	cfg.NetworkDistances = []NetworkDistance{
+		{Key: "zone", From: "z1", To: "z2", Distance: 10},
+		{Key: "zone", From: "z1", To: "z1", Distance: 1},
+		{Key: "host", From: "h1", To: "h2", Distance: 20},
+	}
+	opt.Store(cfg)
+	// The largest distance is used.
+	c.Assert(opt.GetNetworkDistance(s1, s2), Equals, 20.0)
+	c.Assert(opt.GetNetworkDistance(s2, s3), Equals, 10.0)
+	c.Assert(opt.GetNetworkDistance(s3, s1), Equals, 1.0)
+	c.Assert(opt.GetNetworkDistance(s1, s4), Equals, 0.0)
 }
 
 func (s *testConfigSuite) TestAdjust(c *C) {
 	cfgData := `
 name = ""
 lease = 0
+max-reset-ts-gap = "0s"
+heartbeat-capture-max-size = "0B"
+
+[trace]
+sampling-ratio = 0.0
 
 [schedule]
 max-merge-region-size = 0
 enable-one-way-merge = true
 leader-schedule-limit = 0
+scheduling-halt-window = "0s"
 `
 	cfg := NewConfig()
 	meta, err := toml.Decode(cfgData, &cfg)
@@ -122,12 +298,23 @@ leader-schedule-limit = 0
 	c.Assert(cfg.Name, Equals, fmt.Sprintf("%s-%s", defaultName, host))
 	c.Assert(cfg.LeaderLease, Equals, defaultLeaderLease)
 	// When defined, use values from config file.
+	c.Assert(cfg.MaxResetTSGap.Duration, Equals, time.Duration(0))
+	c.Assert(cfg.HeartbeatCaptureMaxSize, Equals, typeutil.ByteSize(0))
+	c.Assert(cfg.Trace.SamplingRatio, Equals, 0.0)
 	c.Assert(cfg.Schedule.MaxMergeRegionSize, Equals, uint64(0))
 	c.Assert(cfg.Schedule.EnableOneWayMerge, Equals, true)
 	c.Assert(cfg.Schedule.LeaderScheduleLimit, Equals, uint64(0))
+	c.Assert(cfg.Schedule.SchedulingHaltWindow.Duration, Equals, time.Duration(0))
 	// When undefined, use default values.
 	c.Assert(cfg.PreVote, IsTrue)
 	c.Assert(cfg.Schedule.MaxMergeRegionKeys, Equals, uint64(defaultMaxMergeRegionKeys))
+	cfg = NewConfig()
+	c.Assert(cfg.Adjust(nil), IsNil)
+	c.Assert(cfg.Schedule.SchedulingHaltWindow.Duration, Equals, defaultSchedulingHaltWindow)
+	c.Assert(cfg.MaxResetTSGap.Duration, Equals, defaultMaxResetTSGap)
+	c.Assert(cfg.HeartbeatCaptureMaxSize, Equals, typeutil.ByteSize(defaultHeartbeatCaptureMaxSize))
+	c.Assert(cfg.PDServerCfg.HeartbeatAdmissionCPUThreshold, Equals, 0.0)
+	c.Assert(cfg.Trace.SamplingRatio, Equals, defaultTraceSamplingRatio)
 
 	// Check undefined config fields
 	cfgData = `
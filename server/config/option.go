@@ -14,6 +14,7 @@
 package config
 
 import (
+	"fmt"
 	"reflect"
 	"sync"
 	"sync/atomic"
@@ -22,8 +23,11 @@ import (
 
 	"github.com/coreos/go-semver/semver"
 	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
 	"github.com/pingcap/pd/server/core"
 	"github.com/pingcap/pd/server/schedule"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
 )
 
 // ScheduleOption is a wrapper to access the configuration safely.
@@ -34,6 +38,9 @@ type ScheduleOption struct {
 	labelProperty  atomic.Value
 	clusterVersion unsafe.Pointer
 	pdServerConfig atomic.Value
+	schedulerNames sync.Map     // concurrent map[string]string, from the type and args of a scheduler to its name
+	deniedStores   atomic.Value // *schedulerDeniedStores
+	provenance     *configProvenance
 }
 
 // NewScheduleOption creates a new ScheduleOption.
@@ -49,6 +56,7 @@ func NewScheduleOption(cfg *Config) *ScheduleOption {
 	o.pdServerConfig.Store(&cfg.PDServerCfg)
 	o.labelProperty.Store(cfg.LabelProperty)
 	o.SetClusterVersion(&cfg.ClusterVersion)
+	o.provenance = newConfigProvenance(cfg)
 	return o
 }
 
@@ -60,6 +68,7 @@ func (o *ScheduleOption) Load() *ScheduleConfig {
 // Store sets scheduling configurations.
 func (o *ScheduleOption) Store(cfg *ScheduleConfig) {
 	o.v.Store(cfg)
+	o.deniedStores.Store((*schedulerDeniedStores)(nil))
 }
 
 // GetReplication returns replication configurations.
@@ -140,6 +149,28 @@ func (o *ScheduleOption) GetMaxPendingPeerCount() uint64 {
 	return o.Load().MaxPendingPeerCount
 }
 
+// GetSnapshotBandwidthBudget returns the total bandwidth per second of the
+// snapshots sent for the operators.
+func (o *ScheduleOption) GetSnapshotBandwidthBudget() uint64 {
+	return uint64(o.Load().SnapshotBandwidthBudget)
+}
+
+// GetMaxStoreLearnerCount returns the max number of learners being added to a store.
+func (o *ScheduleOption) GetMaxStoreLearnerCount() uint64 {
+	return o.Load().MaxStoreLearnerCount
+}
+
+// GetMaxLearnerCount returns the max number of learners being added in the cluster.
+func (o *ScheduleOption) GetMaxLearnerCount() uint64 {
+	return o.Load().MaxLearnerCount
+}
+
+// GetMaxLearnerPromoteLag returns the max applied index lag of a learner to
+// promote it.
+func (o *ScheduleOption) GetMaxLearnerPromoteLag() uint64 {
+	return o.Load().MaxLearnerPromoteLag
+}
+
 // GetMaxMergeRegionSize returns the max region size.
 func (o *ScheduleOption) GetMaxMergeRegionSize() uint64 {
 	return o.Load().MaxMergeRegionSize
@@ -160,16 +191,50 @@ func (o *ScheduleOption) IsOneWayMergeEnabled() bool {
 	return o.Load().EnableOneWayMerge
 }
 
+// IsCrossTierMergeEnabled returns if regions on stores of different types can be merged.
+func (o *ScheduleOption) IsCrossTierMergeEnabled() bool {
+	return o.Load().EnableCrossTierMerge
+}
+
+// GetMaxMergeChainLength returns the max number of adjacent regions merged
+// into one region in sequence.
+func (o *ScheduleOption) GetMaxMergeChainLength() uint64 {
+	return o.Load().MaxMergeChainLength
+}
+
+// GetLongEmptyRegionDuration returns how long a region stays empty to be a
+// long empty region.
+func (o *ScheduleOption) GetLongEmptyRegionDuration() time.Duration {
+	return o.Load().LongEmptyRegionDuration.Duration
+}
+
+// IsEmptyRegionMergeEnabled returns if the long empty regions are merged
+// regardless of the split merge interval and the merge size limits.
+func (o *ScheduleOption) IsEmptyRegionMergeEnabled() bool {
+	return o.Load().EnableEmptyRegionMerge
+}
+
 // GetPatrolRegionInterval returns the interval of patroling region.
 func (o *ScheduleOption) GetPatrolRegionInterval() time.Duration {
 	return o.Load().PatrolRegionInterval.Duration
 }
 
+// GetPatrolRegionWorkerCount returns the number of workers to patrol regions.
+func (o *ScheduleOption) GetPatrolRegionWorkerCount() uint64 {
+	return o.Load().PatrolRegionWorkerCount
+}
+
 // GetMaxStoreDownTime returns the max down time of a store.
 func (o *ScheduleOption) GetMaxStoreDownTime() time.Duration {
 	return o.Load().MaxStoreDownTime.Duration
 }
 
+// GetSchedulingHaltWindow returns the duration to pause scheduling after the
+// cluster becomes unstable.
+func (o *ScheduleOption) GetSchedulingHaltWindow() time.Duration {
+	return o.Load().SchedulingHaltWindow.Duration
+}
+
 // GetLeaderScheduleLimit returns the limit for leader schedule.
 func (o *ScheduleOption) GetLeaderScheduleLimit(name string) uint64 {
 	if n, ok := o.GetNS(name); ok {
@@ -230,6 +295,55 @@ func (o *ScheduleOption) GetHighSpaceRatio() float64 {
 	return o.Load().HighSpaceRatio
 }
 
+// GetMaxLeaderFractionPerStore returns the max fraction of leaders that a
+// store can hold.
+func (o *ScheduleOption) GetMaxLeaderFractionPerStore() float64 {
+	return o.Load().MaxLeaderFractionPerStore
+}
+
+// GetMaxAdjacentLeaderCount returns the max number of adjacent regions whose
+// leaders are in the same store.
+func (o *ScheduleOption) GetMaxAdjacentLeaderCount() uint64 {
+	return o.Load().MaxAdjacentLeaderCount
+}
+
+// GetNetworkDistance returns the network distance between the stores, which
+// is the largest one of the configured distances between them. It is 0 if no
+// distance is configured.
+func (o *ScheduleOption) GetNetworkDistance(from, to *core.StoreInfo) float64 {
+	var distance float64
+	for _, d := range o.Load().NetworkDistances {
+		if d.Distance > distance && d.Match(from, to) {
+			distance = d.Distance
+		}
+	}
+	return distance
+}
+
+// GetMaxStoreRegionCount returns the max number of regions that a store can
+// hold.
+func (o *ScheduleOption) GetMaxStoreRegionCount() uint64 {
+	return o.Load().MaxStoreRegionCount
+}
+
+// GetMaxStoreRegionCountPerGB returns the max number of regions that a store
+// can hold for each GB of its capacity.
+func (o *ScheduleOption) GetMaxStoreRegionCountPerGB() uint64 {
+	return o.Load().MaxStoreRegionCountPerGB
+}
+
+// GetPlacementScoreMode returns how the stores are weighted in the balance
+// scores.
+func (o *ScheduleOption) GetPlacementScoreMode() string {
+	return o.Load().PlacementScoreMode
+}
+
+// GetMemoryPressureRatio returns the memory usage ratio above which a store is
+// under memory pressure.
+func (o *ScheduleOption) GetMemoryPressureRatio() float64 {
+	return o.Load().MemoryPressureRatio
+}
+
 // GetSchedulerMaxWaitingOperator returns the number of the max waiting operators.
 func (o *ScheduleOption) GetSchedulerMaxWaitingOperator() uint64 {
 	return o.Load().SchedulerMaxWaitingOperator
@@ -260,11 +374,28 @@ func (o *ScheduleOption) IsLocationReplacementEnabled() bool {
 	return !o.Load().DisableLocationReplacement
 }
 
+// GetLocationRepairLimit returns the limit for the operators repairing the
+// violations of the location label. 0 means no separate limit.
+func (o *ScheduleOption) GetLocationRepairLimit(label string) uint64 {
+	return o.Load().LocationRepairLimits[label]
+}
+
 // IsNamespaceRelocationEnabled returns if namespace relocation is enabled.
 func (o *ScheduleOption) IsNamespaceRelocationEnabled() bool {
 	return !o.Load().DisableNamespaceRelocation
 }
 
+// IsOperatorPreemptionEnabled returns if the operators of the kind may preempt
+// the in-flight operators of lower schedule priorities.
+func (o *ScheduleOption) IsOperatorPreemptionEnabled(kind string) bool {
+	for _, k := range o.Load().PreemptiveOperatorKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
 // GetSchedulers gets the scheduler configurations.
 func (o *ScheduleOption) GetSchedulers() SchedulerConfigs {
 	return o.Load().Schedulers
@@ -278,12 +409,18 @@ func (o *ScheduleOption) AddSchedulerCfg(tp string, args []string) {
 		// comparing args is to cover the case that there are schedulers in same type but not with same name
 		// such as two schedulers of type "evict-leader",
 		// one name is "evict-leader-scheduler-1" and the other is "evict-leader-scheduler-2"
-		if reflect.DeepEqual(schedulerCfg, SchedulerConfig{Type: tp, Args: args, Disable: false}) {
+		if schedulerCfg.Type == tp && reflect.DeepEqual(schedulerCfg.Args, args) {
+			if schedulerCfg.Disable {
+				schedulerCfg.Disable = false
+				v.Schedulers[i] = schedulerCfg
+				o.Store(v)
+			}
 			return
 		}
-
-		if reflect.DeepEqual(schedulerCfg, SchedulerConfig{Type: tp, Args: args, Disable: true}) {
+		// A default scheduler has only one config, whose args are replaced.
+		if schedulerCfg.Type == tp && IsDefaultScheduler(tp) {
 			schedulerCfg.Disable = false
+			schedulerCfg.Args = args
 			v.Schedulers[i] = schedulerCfg
 			o.Store(v)
 			return
@@ -298,14 +435,14 @@ func (o *ScheduleOption) RemoveSchedulerCfg(name string) error {
 	c := o.Load()
 	v := c.Clone()
 	for i, schedulerCfg := range v.Schedulers {
-		// To create a temporary scheduler is just used to get scheduler's name
-		tmp, err := schedule.CreateScheduler(schedulerCfg.Type, schedule.NewOperatorController(nil, nil), schedulerCfg.Args...)
+		schedulerName, err := o.schedulerName(schedulerCfg)
 		if err != nil {
 			return err
 		}
-		if tmp.GetName() == name {
-			if IsDefaultScheduler(tmp.GetType()) {
+		if schedulerName == name {
+			if IsDefaultScheduler(schedulerCfg.Type) {
 				schedulerCfg.Disable = true
+				schedulerCfg.DeniedStores = nil
 				v.Schedulers[i] = schedulerCfg
 			} else {
 				v.Schedulers = append(v.Schedulers[:i], v.Schedulers[i+1:]...)
@@ -317,6 +454,73 @@ func (o *ScheduleOption) RemoveSchedulerCfg(name string) error {
 	return nil
 }
 
+// SetSchedulerDeniedStores sets the stores denied for the scheduler. The
+// scheduler neither moves regions or leaders out of nor into the stores.
+func (o *ScheduleOption) SetSchedulerDeniedStores(name string, storeIDs []uint64) error {
+	c := o.Load()
+	v := c.Clone()
+	for i, schedulerCfg := range v.Schedulers {
+		schedulerName, err := o.schedulerName(schedulerCfg)
+		if err != nil {
+			return err
+		}
+		if schedulerName == name {
+			if len(storeIDs) == 0 {
+				storeIDs = nil
+			}
+			schedulerCfg.DeniedStores = storeIDs
+			v.Schedulers[i] = schedulerCfg
+			o.Store(v)
+			return nil
+		}
+	}
+	return errors.Errorf("scheduler %s not found", name)
+}
+
+// schedulerDeniedStores is the stores denied for the schedulers keyed by
+// their names, which is built from the scheduling configurations once they
+// are stored.
+type schedulerDeniedStores struct {
+	cfg    *ScheduleConfig
+	stores map[string][]uint64
+}
+
+// GetSchedulerDeniedStores returns the stores denied for the scheduler.
+func (o *ScheduleOption) GetSchedulerDeniedStores(name string) []uint64 {
+	cfg := o.Load()
+	if denied := o.deniedStores.Load().(*schedulerDeniedStores); denied != nil && denied.cfg == cfg {
+		return denied.stores[name]
+	}
+	denied := &schedulerDeniedStores{cfg: cfg, stores: make(map[string][]uint64)}
+	for _, schedulerCfg := range cfg.Schedulers {
+		if schedulerCfg.Disable || len(schedulerCfg.DeniedStores) == 0 {
+			continue
+		}
+		if schedulerName, err := o.schedulerName(schedulerCfg); err == nil {
+			denied.stores[schedulerName] = schedulerCfg.DeniedStores
+		}
+	}
+	o.deniedStores.Store(denied)
+	return denied.stores[name]
+}
+
+// schedulerName returns the name of the scheduler created by the
+// configuration. The names are cached since they are used by the filters of
+// the schedulers.
+func (o *ScheduleOption) schedulerName(cfg SchedulerConfig) (string, error) {
+	key := fmt.Sprintf("%s%q", cfg.Type, cfg.Args)
+	if name, ok := o.schedulerNames.Load(key); ok {
+		return name.(string), nil
+	}
+	// To create a temporary scheduler is just used to get scheduler's name
+	tmp, err := schedule.CreateScheduler(cfg.Type, schedule.NewOperatorController(nil, nil), cfg.Args...)
+	if err != nil {
+		return "", err
+	}
+	o.schedulerNames.Store(key, tmp.GetName())
+	return tmp.GetName(), nil
+}
+
 // SetLabelProperty sets the label property.
 func (o *ScheduleOption) SetLabelProperty(typ, labelKey, labelValue string) {
 	cfg := o.LoadLabelPropertyConfig().Clone()
@@ -383,8 +587,15 @@ func (o *ScheduleOption) Persist(storage *core.Storage) error {
 		ClusterVersion: *o.LoadClusterVersion(),
 		PDServerCfg:    *o.LoadPDServerConfig(),
 	}
-	err := storage.SaveConfig(cfg)
-	return err
+	if err := storage.SaveConfig(cfg); err != nil {
+		return err
+	}
+	o.provenance.observe(cfg, time.Now())
+	// The provenance is only for display, which should not fail the change.
+	if err := storage.SaveConfigProvenance(o.provenance.runtimeItems()); err != nil {
+		log.Error("failed to save config provenance", zap.Error(err))
+	}
+	return nil
 }
 
 // Reload reloads the configuration from the storage.
@@ -414,6 +625,11 @@ func (o *ScheduleOption) Reload(storage *core.Storage) error {
 		o.labelProperty.Store(cfg.LabelProperty)
 		o.SetClusterVersion(&cfg.ClusterVersion)
 		o.pdServerConfig.Store(&cfg.PDServerCfg)
+		provenance, err := loadProvenance(storage)
+		if err != nil {
+			return err
+		}
+		o.provenance.reload(cfg, provenance)
 	}
 	return nil
 }
@@ -422,8 +638,10 @@ func (o *ScheduleOption) adjustScheduleCfg(persistentCfg *Config) {
 	scheduleCfg := o.Load().Clone()
 	for i, s := range scheduleCfg.Schedulers {
 		for _, ps := range persistentCfg.Schedule.Schedulers {
-			if s.Type == ps.Type && reflect.DeepEqual(s.Args, ps.Args) {
+			if sameSchedulerCfg(s, ps) {
+				scheduleCfg.Schedulers[i].Args = ps.Args
 				scheduleCfg.Schedulers[i].Disable = ps.Disable
+				scheduleCfg.Schedulers[i].DeniedStores = ps.DeniedStores
 				break
 			}
 		}
@@ -432,7 +650,7 @@ func (o *ScheduleOption) adjustScheduleCfg(persistentCfg *Config) {
 	for _, ps := range persistentCfg.Schedule.Schedulers {
 		needRestore := true
 		for _, s := range scheduleCfg.Schedulers {
-			if s.Type == ps.Type && reflect.DeepEqual(s.Args, ps.Args) {
+			if sameSchedulerCfg(s, ps) {
 				needRestore = false
 				break
 			}
@@ -446,6 +664,58 @@ func (o *ScheduleOption) adjustScheduleCfg(persistentCfg *Config) {
 	o.Store(scheduleCfg)
 }
 
+// sameSchedulerCfg checks if the configs are of the same scheduler. A default
+// scheduler has only one config, whose args may be changed by users, such as
+// the key ranges of balance-region, so the persisted args are kept instead of
+// restoring another config which fails to be added.
+func sameSchedulerCfg(a, b SchedulerConfig) bool {
+	if a.Type != b.Type {
+		return false
+	}
+	return IsDefaultScheduler(a.Type) || reflect.DeepEqual(a.Args, b.Args)
+}
+
+// GetWarmRegionAccessThreshold returns the number of the recent accesses for a
+// region to be regarded warm.
+func (o *ScheduleOption) GetWarmRegionAccessThreshold() uint64 {
+	return o.Load().WarmRegionAccessThreshold
+}
+
+// GetRegionAccessMinKeys returns the min number of the keys read and written in
+// a region heartbeat to count it as an access.
+func (o *ScheduleOption) GetRegionAccessMinKeys() uint64 {
+	return o.Load().RegionAccessMinKeys
+}
+
+// GetRegionHeartbeatFlowDelta returns the max difference of the flow stats to
+// skip a region heartbeat.
+func (o *ScheduleOption) GetRegionHeartbeatFlowDelta() uint64 {
+	return o.Load().RegionHeartbeatFlowDelta
+}
+
+// GetStoreStatsWindowSize returns the number of the recent store heartbeats
+// whose median is the flow rate of a store.
+func (o *ScheduleOption) GetStoreStatsWindowSize() int {
+	return int(o.Load().StoreStatsWindowSize)
+}
+
+// GetStoreStatsEWMADecay returns the weight of the history in the moving
+// average of the flow rates of a store.
+func (o *ScheduleOption) GetStoreStatsEWMADecay() float64 {
+	return o.Load().StoreStatsEWMADecay
+}
+
+// GetStoreStatsLongWindows returns the long windows of the flow rates of a
+// store.
+func (o *ScheduleOption) GetStoreStatsLongWindows() []time.Duration {
+	windows := o.Load().StoreStatsLongWindows
+	result := make([]time.Duration, 0, len(windows))
+	for _, w := range windows {
+		result = append(result, w.Duration)
+	}
+	return result
+}
+
 // GetHotRegionCacheHitsThreshold is a threshold to decide if a region is hot.
 func (o *ScheduleOption) GetHotRegionCacheHitsThreshold() int {
 	return int(o.Load().HotRegionCacheHitsThreshold)
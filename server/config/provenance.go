@@ -0,0 +1,206 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/pd/server/core"
+)
+
+// Sources of the values of the config items.
+const (
+	// SourceDefault means the item takes the default value.
+	SourceDefault = "default"
+	// SourceFile means the item is set in the config file.
+	SourceFile = "file"
+	// SourceRuntime means the item is changed at runtime, such as by the API
+	// or by PD itself, which is persisted and overrides the config file.
+	SourceRuntime = "runtime"
+)
+
+// ItemProvenance is where the value of a config item comes from, and when it
+// was changed last time. UpdateTime is zero if it is unknown, such as for
+// the values changed by the PD of former versions.
+type ItemProvenance struct {
+	Source     string    `json:"source"`
+	UpdateTime time.Time `json:"update_time"`
+}
+
+// EffectiveConfigItem is a config item in effect with its provenance.
+type EffectiveConfigItem struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+	ItemProvenance
+}
+
+// configProvenance tracks the provenance of the items of the schedule, the
+// replication and the PD server configs. The items not tracked take the
+// default values.
+type configProvenance struct {
+	sync.RWMutex
+	items map[string]*ItemProvenance
+	// persisted is the items last persisted or loaded, against which the
+	// changes are found.
+	persisted map[string]interface{}
+}
+
+// flattenConfig returns the items of the config sections keyed by the section
+// and the toml names, such as schedule.max-snapshot-count.
+func flattenConfig(cfg *Config) map[string]interface{} {
+	items := make(map[string]interface{})
+	for _, section := range []struct {
+		name string
+		v    interface{}
+	}{
+		{"schedule", cfg.Schedule},
+		{"replication", cfg.Replication},
+		{"pd-server", cfg.PDServerCfg},
+	} {
+		v := reflect.ValueOf(section.v)
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name := itemName(t.Field(i))
+			if name == "" {
+				continue
+			}
+			items[section.name+"."+name] = v.Field(i).Interface()
+		}
+	}
+	items["cluster-version"] = cfg.ClusterVersion.String()
+	return items
+}
+
+func itemName(field reflect.StructField) string {
+	tag := field.Tag.Get("toml")
+	if tag == "" {
+		tag = field.Tag.Get("json")
+	}
+	name := strings.Split(tag, ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// fileItems returns the items of the config sections set in the config file.
+func fileItems(cfg *Config, meta *configMetaData) map[string]struct{} {
+	items := make(map[string]struct{})
+	for name := range flattenConfig(cfg) {
+		keys := strings.SplitN(name, ".", 2)
+		if len(keys) == 2 && meta.Child(keys[0]).IsDefined(keys[1]) {
+			items[name] = struct{}{}
+		}
+	}
+	return items
+}
+
+func newConfigProvenance(cfg *Config) *configProvenance {
+	p := &configProvenance{
+		items:     make(map[string]*ItemProvenance),
+		persisted: flattenConfig(cfg),
+	}
+	now := time.Now()
+	for name := range cfg.fileItems {
+		p.items[name] = &ItemProvenance{Source: SourceFile, UpdateTime: now}
+	}
+	return p
+}
+
+// observe records the items changed since the last time as changed at
+// runtime.
+func (p *configProvenance) observe(cfg *Config, now time.Time) {
+	p.Lock()
+	defer p.Unlock()
+	items := flattenConfig(cfg)
+	for name, value := range items {
+		if old, ok := p.persisted[name]; ok && reflect.DeepEqual(old, value) {
+			continue
+		}
+		p.items[name] = &ItemProvenance{Source: SourceRuntime, UpdateTime: now}
+	}
+	p.persisted = items
+}
+
+// reload records the items loaded from the storage. The loaded provenance
+// takes precedence, and the loaded items different from the current ones
+// without provenance are regarded as changed at runtime at an unknown time.
+func (p *configProvenance) reload(cfg *Config, loaded map[string]*ItemProvenance) {
+	p.Lock()
+	defer p.Unlock()
+	items := flattenConfig(cfg)
+	for name, value := range items {
+		if old, ok := p.persisted[name]; ok && reflect.DeepEqual(old, value) {
+			continue
+		}
+		p.items[name] = &ItemProvenance{Source: SourceRuntime}
+	}
+	for name, item := range loaded {
+		if item.Source == SourceRuntime {
+			p.items[name] = item
+		}
+	}
+	p.persisted = items
+}
+
+func (p *configProvenance) runtimeItems() map[string]*ItemProvenance {
+	p.RLock()
+	defer p.RUnlock()
+	items := make(map[string]*ItemProvenance)
+	for name, item := range p.items {
+		if item.Source == SourceRuntime {
+			items[name] = item
+		}
+	}
+	return items
+}
+
+func (p *configProvenance) effective() []*EffectiveConfigItem {
+	p.RLock()
+	defer p.RUnlock()
+	res := make([]*EffectiveConfigItem, 0, len(p.persisted))
+	for name, value := range p.persisted {
+		item := &EffectiveConfigItem{
+			Name:           name,
+			Value:          value,
+			ItemProvenance: ItemProvenance{Source: SourceDefault},
+		}
+		if provenance, ok := p.items[name]; ok {
+			item.ItemProvenance = *provenance
+		}
+		res = append(res, item)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// GetEffectiveConfig returns the items of the schedule, the replication and
+// the PD server configs in effect with their provenance, sorted by the names.
+// The changes not persisted yet are not included.
+func (o *ScheduleOption) GetEffectiveConfig() []*EffectiveConfigItem {
+	return o.provenance.effective()
+}
+
+// loadProvenance loads the provenance of the items changed at runtime.
+func loadProvenance(storage *core.Storage) (map[string]*ItemProvenance, error) {
+	items := make(map[string]*ItemProvenance)
+	if _, err := storage.LoadConfigProvenance(&items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
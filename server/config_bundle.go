@@ -0,0 +1,300 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/server/config"
+	"github.com/pingcap/pd/server/schedule"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ConfigBundle is the schedule config with the schedulers, the label
+// properties and the namespace configs of a cluster, which are exported
+// together to be imported into another cluster, such as propagating the
+// settings tuned in staging to production.
+type ConfigBundle struct {
+	// ClusterID is the cluster the bundle is exported from.
+	ClusterID     uint64                            `json:"cluster-id"`
+	ExportTime    time.Time                         `json:"export-time"`
+	Schedule      config.ScheduleConfig             `json:"schedule"`
+	LabelProperty config.LabelPropertyConfig        `json:"label-property"`
+	Namespace     map[string]config.NamespaceConfig `json:"namespace"`
+	// Signature is the hex encoded HMAC-SHA256 of the other fields with the
+	// key given when exporting.
+	Signature string `json:"signature"`
+}
+
+// ConfigBundleImport is the schedulers changed by importing a bundle, and the
+// schedulers in the bundle skipped since they are scoped to stores.
+type ConfigBundleImport struct {
+	AddedSchedulers   []string `json:"added-schedulers"`
+	RemovedSchedulers []string `json:"removed-schedulers"`
+	SkippedSchedulers []string `json:"skipped-schedulers,omitempty"`
+}
+
+// storeScopedSchedulers are the types of the schedulers whose args are store
+// IDs. They are neither exported nor imported, since the store IDs of
+// different clusters are unrelated, and the ones of the importing cluster,
+// such as evicting the leaders of a store under maintenance, are kept.
+var storeScopedSchedulers = map[string]struct{}{
+	"evict-leader": {},
+	"grant-leader": {},
+}
+
+func isStoreScopedScheduler(typ string) bool {
+	_, ok := storeScopedSchedulers[typ]
+	return ok
+}
+
+// splitStoreScopedSchedulers splits the configs into the ones portable
+// between clusters and the ones scoped to stores.
+func splitStoreScopedSchedulers(cfgs config.SchedulerConfigs) (portable, storeScoped config.SchedulerConfigs) {
+	for _, cfg := range cfgs {
+		if isStoreScopedScheduler(cfg.Type) {
+			storeScoped = append(storeScoped, cfg)
+		} else {
+			portable = append(portable, cfg)
+		}
+	}
+	return
+}
+
+func (b *ConfigBundle) sign(key string) (string, error) {
+	if key == "" {
+		return "", errcode.NewInvalidInputErr(errors.New("the key to sign the config bundle is required"))
+	}
+	unsigned := *b
+	unsigned.Signature = ""
+	data, err := json.Marshal(&unsigned)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ExportConfigBundle exports the config bundle signed with the key, which is
+// required. The schedulers scoped to stores are left out.
+func (h *Handler) ExportConfigBundle(key string) (*ConfigBundle, error) {
+	bundle := &ConfigBundle{
+		ClusterID:     h.s.ClusterID(),
+		ExportTime:    time.Now(),
+		Schedule:      *h.opt.Load().Clone(),
+		LabelProperty: h.s.GetLabelProperty(),
+		Namespace:     h.opt.LoadNSConfig(),
+	}
+	bundle.Schedule.Schedulers, _ = splitStoreScopedSchedulers(bundle.Schedule.Schedulers)
+	signature, err := bundle.sign(key)
+	if err != nil {
+		return nil, err
+	}
+	bundle.Signature = signature
+	return bundle, nil
+}
+
+// ImportConfigBundle verifies the signature of the bundle with the key, and
+// applies it. The label properties are replaced by the ones in the bundle,
+// and the namespace configs in the bundle are set. The schedulers not
+// enabled in the bundle are removed and the missing ones are added. Neither
+// the denied stores of the schedulers nor the schedulers scoped to stores are
+// imported, since the store IDs of different clusters are unrelated, and the
+// schedulers scoped to stores of this cluster are kept.
+func (h *Handler) ImportConfigBundle(bundle *ConfigBundle, key string) (*ConfigBundleImport, error) {
+	signature, err := bundle.sign(key)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(signature), []byte(bundle.Signature)) {
+		return nil, errcode.NewInvalidInputErr(errors.New("the signature of the config bundle does not match"))
+	}
+	if err = bundle.Schedule.Validate(); err != nil {
+		return nil, errcode.NewInvalidInputErr(err)
+	}
+	c, err := h.getCoordinator()
+	if err != nil {
+		return nil, err
+	}
+	// The schedulers are checked before changing anything.
+	portable, skipped := splitStoreScopedSchedulers(bundle.Schedule.Schedulers)
+	names, wanted, err := enabledSchedulers(c, portable)
+	if err != nil {
+		return nil, errcode.NewInvalidInputErr(err)
+	}
+	result := &ConfigBundleImport{}
+	c.Lock()
+	started := c.schedulersStarted
+	cfg := bundle.Schedule.Clone()
+	if started {
+		// The running schedulers are updated below.
+		cfg.Schedulers = h.opt.Load().Schedulers
+	} else {
+		// The schedulers in the config are created once the coordinator runs.
+		current, kept := splitStoreScopedSchedulers(h.opt.Load().Schedulers)
+		result, err = diffSchedulers(c, current, names, wanted)
+		cfg.Schedulers = make(config.SchedulerConfigs, 0, len(portable)+len(kept))
+		for _, schedulerCfg := range portable {
+			schedulerCfg.DeniedStores = nil
+			cfg.Schedulers = append(cfg.Schedulers, schedulerCfg)
+		}
+		cfg.Schedulers = append(cfg.Schedulers, kept...)
+	}
+	if result != nil {
+		for _, schedulerCfg := range skipped {
+			result.SkippedSchedulers = append(result.SkippedSchedulers,
+				strings.Join(append([]string{schedulerCfg.Type}, schedulerCfg.Args...), " "))
+		}
+	}
+	if err == nil {
+		err = h.s.SetScheduleConfig(*cfg)
+	}
+	c.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if err = h.importLabelProperty(bundle.LabelProperty); err != nil {
+		return nil, err
+	}
+	for name, ns := range bundle.Namespace {
+		if err = h.s.SetNamespaceConfig(name, ns); err != nil {
+			return nil, err
+		}
+	}
+
+	// The running schedulers not wanted are removed, including the ones added
+	// before the coordinator runs, which are in the diff of the config.
+	running := make(map[string]struct{})
+	for _, name := range c.getSchedulers() {
+		running[name] = struct{}{}
+		if _, ok := wanted[name]; ok || c.isStoreScopedScheduler(name) {
+			continue
+		}
+		if err = h.RemoveScheduler(name); err != nil {
+			return result, err
+		}
+		if started {
+			result.RemovedSchedulers = append(result.RemovedSchedulers, name)
+		}
+	}
+	if started {
+		for _, name := range names {
+			if _, ok := running[name]; ok {
+				continue
+			}
+			schedulerCfg := wanted[name]
+			if err = h.AddScheduler(schedulerCfg.Type, schedulerCfg.Args...); err != nil {
+				return result, err
+			}
+			result.AddedSchedulers = append(result.AddedSchedulers, name)
+		}
+	}
+	log.Info("config bundle is imported",
+		zap.Uint64("from-cluster-id", bundle.ClusterID),
+		zap.Time("export-time", bundle.ExportTime),
+		zap.Strings("added-schedulers", result.AddedSchedulers),
+		zap.Strings("removed-schedulers", result.RemovedSchedulers))
+	return result, nil
+}
+
+// importLabelProperty replaces the label properties with the given ones.
+func (h *Handler) importLabelProperty(labelProperty config.LabelPropertyConfig) error {
+	has := func(cfg config.LabelPropertyConfig, typ string, label config.StoreLabel) bool {
+		for _, l := range cfg[typ] {
+			if l == label {
+				return true
+			}
+		}
+		return false
+	}
+	old := h.s.GetLabelProperty()
+	for typ, labels := range old {
+		for _, l := range labels {
+			if has(labelProperty, typ, l) {
+				continue
+			}
+			if err := h.s.DeleteLabelProperty(typ, l.Key, l.Value); err != nil {
+				return err
+			}
+		}
+	}
+	for typ, labels := range labelProperty {
+		for _, l := range labels {
+			if has(old, typ, l) {
+				continue
+			}
+			if err := h.s.SetLabelProperty(typ, l.Key, l.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isStoreScopedScheduler returns if the running scheduler is scoped to stores.
+func (c *coordinator) isStoreScopedScheduler(name string) bool {
+	c.RLock()
+	defer c.RUnlock()
+	s, ok := c.schedulers[name]
+	return ok && isStoreScopedScheduler(s.GetType())
+}
+
+// enabledSchedulers returns the names of the enabled schedulers in the configs
+// in order, and their configs keyed by the names.
+func enabledSchedulers(c *coordinator, cfgs config.SchedulerConfigs) ([]string, map[string]config.SchedulerConfig, error) {
+	var names []string
+	enabled := make(map[string]config.SchedulerConfig)
+	for _, cfg := range cfgs {
+		if cfg.Disable {
+			continue
+		}
+		s, err := schedule.CreateScheduler(cfg.Type, c.opController, cfg.Args...)
+		if err != nil {
+			return nil, nil, err
+		}
+		names = append(names, s.GetName())
+		enabled[s.GetName()] = cfg
+	}
+	return names, enabled, nil
+}
+
+// diffSchedulers returns the schedulers added and removed by replacing the
+// enabled schedulers in the old configs with the wanted ones.
+func diffSchedulers(c *coordinator, old config.SchedulerConfigs, names []string, wanted map[string]config.SchedulerConfig) (*ConfigBundleImport, error) {
+	oldNames, enabled, err := enabledSchedulers(c, old)
+	if err != nil {
+		return nil, err
+	}
+	result := &ConfigBundleImport{}
+	for _, name := range oldNames {
+		if _, ok := wanted[name]; !ok {
+			result.RemovedSchedulers = append(result.RemovedSchedulers, name)
+		}
+	}
+	for _, name := range names {
+		if _, ok := enabled[name]; !ok {
+			result.AddedSchedulers = append(result.AddedSchedulers, name)
+		}
+	}
+	return result, nil
+}
@@ -14,12 +14,17 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/opentracing/opentracing-go"
+	"github.com/pingcap/errcode"
 	"github.com/pingcap/log"
+	"github.com/pingcap/pd/pkg/cache"
 	"github.com/pingcap/pd/pkg/logutil"
 	"github.com/pingcap/pd/server/checker"
 	"github.com/pingcap/pd/server/core"
@@ -41,13 +46,34 @@ const (
 	hotRegionScheduleName      = "balance-hot-region-scheduler"
 
 	patrolScanRegionLimit = 128 // It takes about 14 minutes to iterate 1 million regions.
+	eventBufferSize       = 1024
 )
 
+// Names of the checkers, which are used to pause checkers and label metrics.
+const (
+	learnerCheckerName         = "learner"
+	placementIntentCheckerName = "placement-intent"
+	tierLeaderCheckerName      = "tier-leader"
+	adjacentLeaderCheckerName  = "adjacent-leader"
+	namespaceCheckerName       = "namespace"
+	replicaCheckerName         = "replica"
+	mergeCheckerName           = "merge"
+)
+
+var checkerNames = []string{learnerCheckerName, placementIntentCheckerName, tierLeaderCheckerName, adjacentLeaderCheckerName, namespaceCheckerName, replicaCheckerName, mergeCheckerName}
+
 var (
 	errSchedulerExisted  = errors.New("scheduler existed")
 	errSchedulerNotFound = errors.New("scheduler not found")
+	errCheckerNotFound   = errors.New("checker not found")
 )
 
+// CheckerStatus is the runtime status of a checker.
+type CheckerStatus struct {
+	Name   string `json:"name"`
+	Paused bool   `json:"paused"`
+}
+
 // coordinator is used to manage all schedulers and checkers to decide if the region needs to be scheduled.
 type coordinator struct {
 	sync.RWMutex
@@ -56,84 +82,166 @@ type coordinator struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 
-	cluster          *RaftCluster
-	learnerChecker   *checker.LearnerChecker
-	replicaChecker   *checker.ReplicaChecker
-	namespaceChecker *checker.NamespaceChecker
-	mergeChecker     *checker.MergeChecker
-	regionScatterer  *schedule.RegionScatterer
-	schedulers       map[string]*scheduleController
-	opController     *schedule.OperatorController
-	classifier       namespace.Classifier
-	hbStreams        *heartbeatStreams
+	cluster               *RaftCluster
+	learnerChecker        *checker.LearnerChecker
+	tierLeaderChecker     *checker.TierLeaderChecker
+	adjacentLeaderChecker *checker.AdjacentLeaderChecker
+	replicaChecker        *checker.ReplicaChecker
+	namespaceChecker      *checker.NamespaceChecker
+	mergeChecker          *checker.MergeChecker
+	pausedCheckers        map[string]bool
+	regionScatterer       *schedule.RegionScatterer
+	schedulers            map[string]*scheduleController
+	// schedulersStarted is set once run creates the schedulers in the config.
+	// Before that, the schedulers to run are changed by updating the config.
+	schedulersStarted bool
+	opController      *schedule.OperatorController
+	// limitMu makes checking the schedule limits and adding the operators of
+	// the checkers atomic, since the patrol workers check regions concurrently.
+	limitMu      sync.Mutex
+	classifier   namespace.Classifier
+	hbStreams    *heartbeatStreams
+	scatterQueue *scatterQueue
+	// splitPriorities are the priorities of the recent splits keyed by the
+	// regions created by them.
+	splitPriorities *cache.TTL
 }
 
 // newCoordinator creates a new coordinator.
 func newCoordinator(cluster *RaftCluster, hbStreams *heartbeatStreams, classifier namespace.Classifier) *coordinator {
 	ctx, cancel := context.WithCancel(context.Background())
+	opController := schedule.NewOperatorController(cluster, hbStreams)
+	opController.SetEventBus(cluster.events)
 	return &coordinator{
-		ctx:              ctx,
-		cancel:           cancel,
-		cluster:          cluster,
-		learnerChecker:   checker.NewLearnerChecker(),
-		replicaChecker:   checker.NewReplicaChecker(cluster, classifier),
-		namespaceChecker: checker.NewNamespaceChecker(cluster, classifier),
-		mergeChecker:     checker.NewMergeChecker(cluster, classifier),
-		regionScatterer:  schedule.NewRegionScatterer(cluster, classifier),
-		schedulers:       make(map[string]*scheduleController),
-		opController:     schedule.NewOperatorController(cluster, hbStreams),
-		classifier:       classifier,
-		hbStreams:        hbStreams,
-	}
-}
-
-// patrolRegions is used to scan regions.
-// The checkers will check these regions to decide if they need to do some operations.
+		ctx:                   ctx,
+		cancel:                cancel,
+		cluster:               cluster,
+		learnerChecker:        checker.NewLearnerChecker(cluster),
+		tierLeaderChecker:     checker.NewTierLeaderChecker(cluster, cluster.labeler),
+		adjacentLeaderChecker: checker.NewAdjacentLeaderChecker(cluster),
+		replicaChecker:        checker.NewReplicaChecker(cluster, classifier),
+		namespaceChecker:      checker.NewNamespaceChecker(cluster, classifier),
+		mergeChecker:          checker.NewMergeChecker(cluster, classifier, cluster.labeler, cluster.keyDecoder),
+		pausedCheckers:        make(map[string]bool),
+		regionScatterer:       schedule.NewRegionScatterer(cluster, classifier),
+		schedulers:            make(map[string]*scheduleController),
+		opController:          opController,
+		classifier:            classifier,
+		hbStreams:             hbStreams,
+		scatterQueue:          &scatterQueue{},
+		splitPriorities:       cache.NewTTL(time.Minute, splitPriorityTTL),
+	}
+}
+
+// patrolRegions is used to scan regions. The key space is split into shards
+// with about the same number of regions, and each shard is patrolled by a
+// worker. The shards are split again after all workers finish a round, so
+// that the shards follow the changes of regions and the worker count.
 func (c *coordinator) patrolRegions() {
 	defer logutil.LogPanic()
 
 	defer c.wg.Done()
+	log.Info("coordinator starts patrol regions")
+	for {
+		start := time.Now()
+		keys := c.splitPatrolShards(int(c.cluster.GetPatrolRegionWorkerCount()))
+		var wg sync.WaitGroup
+		for i := 0; i <= len(keys); i++ {
+			var startKey, endKey []byte
+			if i > 0 {
+				startKey = keys[i-1]
+			}
+			if i < len(keys) {
+				endKey = keys[i]
+			}
+			wg.Add(1)
+			go func(shard int) {
+				defer logutil.LogPanic()
+				defer wg.Done()
+				c.patrolShard(shard, startKey, endKey)
+			}(i)
+		}
+		wg.Wait()
+		if c.ctx.Err() != nil {
+			log.Info("patrol regions has been stopped")
+			return
+		}
+		patrolCheckRegionsHistogram.Observe(time.Since(start).Seconds())
+	}
+}
+
+// splitPatrolShards splits the key space into at most n shards with about the
+// same number of regions, and returns the keys between the shards.
+func (c *coordinator) splitPatrolShards(n int) [][]byte {
+	if n <= 1 {
+		return nil
+	}
+	step := c.cluster.core.GetRegionCount() / n
+	if step == 0 {
+		return nil
+	}
+	var keys [][]byte
+	var key []byte
+	for i := 1; i < n; i++ {
+		regions := c.cluster.ScanRegions(key, nil, step)
+		if len(regions) < step {
+			break
+		}
+		key = regions[len(regions)-1].GetEndKey()
+		if len(key) == 0 {
+			break
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// patrolShard checks the regions in [startKey, endKey) once. An empty endKey
+// means the end of the key space.
+func (c *coordinator) patrolShard(shard int, startKey, endKey []byte) {
 	timer := time.NewTimer(c.cluster.GetPatrolRegionInterval())
 	defer timer.Stop()
 
-	log.Info("coordinator starts patrol regions")
 	start := time.Now()
-	var key []byte
+	key := startKey
 	for {
 		select {
 		case <-timer.C:
 			timer.Reset(c.cluster.GetPatrolRegionInterval())
 		case <-c.ctx.Done():
-			log.Info("patrol regions has been stopped")
 			return
 		}
+		if c.cluster.isSchedulingHalted() {
+			continue
+		}
 
-		regions := c.cluster.ScanRegions(key, nil, patrolScanRegionLimit)
+		regions := c.cluster.ScanRegions(key, endKey, patrolScanRegionLimit)
 		if len(regions) == 0 {
-			// Resets the scan key.
-			key = nil
-			continue
+			break
 		}
 
+		span := opentracing.StartSpan("patrolRegions", opentracing.Tag{Key: "shard", Value: shard})
 		for _, region := range regions {
-			// Skips the region if there is already a pending operator.
-			if c.opController.GetOperator(region.GetID()) != nil {
+			// Skips the region if there is already a pending operator, unless
+			// it may be preempted by the checkers.
+			if op := c.opController.GetOperator(region.GetID()); op != nil && !c.opController.IsPreemptible(op) {
 				continue
 			}
 
 			key = region.GetEndKey()
 
-			if c.checkRegion(region) {
+			if c.checkRegionInSpan(span, region) {
 				break
 			}
 		}
+		span.Finish()
 		// Updates the label level isolation statistics.
 		c.cluster.updateRegionsLabelLevelStats(regions)
-		if len(key) == 0 {
-			patrolCheckRegionsHistogram.Observe(time.Since(start).Seconds())
-			start = time.Now()
+		if len(key) == 0 || (len(endKey) > 0 && bytes.Compare(key, endKey) >= 0) {
+			break
 		}
 	}
+	patrolShardCheckRegionsHistogram.WithLabelValues(strconv.Itoa(shard)).Observe(time.Since(start).Seconds())
 }
 
 // drivePushOperator is used to push the unfinished operator to the excutor.
@@ -155,36 +263,147 @@ func (c *coordinator) drivePushOperator() {
 	}
 }
 
+// driveEvents checks the regions changed by splits, merges and operators once
+// they are reported, instead of waiting for the patrol to reach them.
+func (c *coordinator) driveEvents(sub *schedule.Subscription) {
+	defer logutil.LogPanic()
+
+	defer c.wg.Done()
+	defer c.cluster.events.Unsubscribe(sub)
+	for {
+		select {
+		case <-c.ctx.Done():
+			log.Info("drive events has been stopped")
+			return
+		case e := <-sub.C:
+			if c.cluster.isSchedulingHalted() {
+				continue
+			}
+			switch e.Type {
+			case schedule.EventRegionSplit:
+				// The range of the origin covers the split regions.
+				for _, region := range c.cluster.ScanRegions(e.Origin.GetStartKey(), e.Origin.GetEndKey(), 0) {
+					c.checkChangedRegion(region.GetID())
+				}
+			case schedule.EventRegionMerge, schedule.EventOperatorFinish:
+				c.checkChangedRegion(e.Region.GetID())
+			}
+		}
+	}
+}
+
+// checkChangedRegion checks the latest region if it has no operator, or the
+// operator may be preempted.
+func (c *coordinator) checkChangedRegion(regionID uint64) {
+	region := c.cluster.GetRegion(regionID)
+	if region == nil {
+		return
+	}
+	if op := c.opController.GetOperator(regionID); op != nil && !c.opController.IsPreemptible(op) {
+		return
+	}
+	c.checkRegion(region)
+}
+
 func (c *coordinator) checkRegion(region *core.RegionInfo) bool {
+	return c.checkRegionInSpan(nil, region)
+}
+
+// checkRegionInSpan checks the region with its span as a child of the parent
+// span, such as the span of the patrol. A nil parent starts a new trace.
+func (c *coordinator) checkRegionInSpan(parent opentracing.Span, region *core.RegionInfo) bool {
 	opController := c.opController
+	opts := []opentracing.StartSpanOption{opentracing.Tag{Key: "region-id", Value: region.GetID()}}
+	if parent != nil {
+		opts = append(opts, opentracing.ChildOf(parent.Context()))
+	}
+	span := opentracing.StartSpan("checkRegion", opts...)
+	defer span.Finish()
+
+	if c.shouldCheck(learnerCheckerName) {
+		checkSpan := startCheckerSpan(span, learnerCheckerName)
+		op := c.learnerChecker.Check(region)
+		finishCheckerSpan(checkSpan, op)
+		if op != nil {
+			checkerOperatorCounter.WithLabelValues(learnerCheckerName).Inc()
+			if opController.AddOperator(op) {
+				return true
+			}
+		}
+	}
 
-	if op := c.learnerChecker.Check(region); op != nil {
-		if opController.AddOperator(op) {
-			return true
+	if c.withinScheduleLimits(operator.OpLeader, operator.OpRegion) && c.shouldCheck(placementIntentCheckerName) {
+		checkSpan := startCheckerSpan(span, placementIntentCheckerName)
+		op := c.cluster.checkPlacementIntent(region)
+		finishCheckerSpan(checkSpan, op)
+		if op != nil {
+			checkerOperatorCounter.WithLabelValues(placementIntentCheckerName).Inc()
+			if c.addWaitingOperatorWithinLimits([]operator.OpKind{operator.OpLeader, operator.OpRegion}, op) {
+				return true
+			}
+			c.cluster.setPlacementIntentBlocked(region.GetID(), "the operator is rejected")
 		}
 	}
 
-	if opController.OperatorCount(operator.OpLeader) < c.cluster.GetLeaderScheduleLimit() &&
-		opController.OperatorCount(operator.OpRegion) < c.cluster.GetRegionScheduleLimit() &&
-		opController.OperatorCount(operator.OpReplica) < c.cluster.GetReplicaScheduleLimit() {
-		if op := c.namespaceChecker.Check(region); op != nil {
-			if opController.AddWaitingOperator(op) {
+	if c.withinScheduleLimits(operator.OpLeader) && c.shouldCheck(tierLeaderCheckerName) {
+		checkSpan := startCheckerSpan(span, tierLeaderCheckerName)
+		op := c.tierLeaderChecker.Check(region)
+		finishCheckerSpan(checkSpan, op)
+		if op != nil {
+			checkerOperatorCounter.WithLabelValues(tierLeaderCheckerName).Inc()
+			if c.addWaitingOperatorWithinLimits([]operator.OpKind{operator.OpLeader}, op) {
 				return true
 			}
 		}
 	}
 
-	if opController.OperatorCount(operator.OpReplica) < c.cluster.GetReplicaScheduleLimit() {
-		if op := c.replicaChecker.Check(region); op != nil {
-			if opController.AddWaitingOperator(op) {
+	if c.withinScheduleLimits(operator.OpLeader) && c.shouldCheck(adjacentLeaderCheckerName) {
+		checkSpan := startCheckerSpan(span, adjacentLeaderCheckerName)
+		op := c.adjacentLeaderChecker.Check(region)
+		finishCheckerSpan(checkSpan, op)
+		if op != nil {
+			checkerOperatorCounter.WithLabelValues(adjacentLeaderCheckerName).Inc()
+			if c.addWaitingOperatorWithinLimits([]operator.OpKind{operator.OpLeader}, op) {
 				return true
 			}
 		}
 	}
-	if c.cluster.IsFeatureSupported(RegionMerge) && opController.OperatorCount(operator.OpMerge) < c.cluster.GetMergeScheduleLimit() {
-		if ops := c.mergeChecker.Check(region); ops != nil {
+
+	if c.withinScheduleLimits(operator.OpLeader, operator.OpRegion, operator.OpReplica) && c.shouldCheck(namespaceCheckerName) {
+		checkSpan := startCheckerSpan(span, namespaceCheckerName)
+		op := c.namespaceChecker.Check(region)
+		finishCheckerSpan(checkSpan, op)
+		if op != nil {
+			checkerOperatorCounter.WithLabelValues(namespaceCheckerName).Inc()
+			if c.addWaitingOperatorWithinLimits([]operator.OpKind{operator.OpLeader, operator.OpRegion, operator.OpReplica}, op) {
+				return true
+			}
+		}
+	}
+
+	if c.withinScheduleLimits(operator.OpReplica) && c.shouldCheck(replicaCheckerName) {
+		checkSpan := startCheckerSpan(span, replicaCheckerName)
+		op := c.replicaChecker.Check(region)
+		finishCheckerSpan(checkSpan, op)
+		if op != nil && c.isLocationRepairLimited(op) {
+			log.Debug("location repair limit is reached", zap.Uint64("region-id", region.GetID()), zap.String("desc", op.Desc()))
+			op = nil
+		}
+		if op != nil {
+			checkerOperatorCounter.WithLabelValues(replicaCheckerName).Inc()
+			if c.addWaitingOperatorWithinLimits([]operator.OpKind{operator.OpReplica}, op) {
+				return true
+			}
+		}
+	}
+	if c.cluster.IsFeatureSupported(RegionMerge) && c.withinScheduleLimits(operator.OpMerge) && c.shouldCheck(mergeCheckerName) {
+		checkSpan := startCheckerSpan(span, mergeCheckerName)
+		ops := c.mergeChecker.Check(region)
+		finishCheckerSpan(checkSpan, ops...)
+		if ops != nil {
+			checkerOperatorCounter.WithLabelValues(mergeCheckerName).Inc()
 			// It makes sure that two operators can be added successfully altogether.
-			if opController.AddWaitingOperator(ops...) {
+			if c.addWaitingOperatorWithinLimits([]operator.OpKind{operator.OpMerge}, ops...) {
 				return true
 			}
 		}
@@ -192,6 +411,190 @@ func (c *coordinator) checkRegion(region *core.RegionInfo) bool {
 	return false
 }
 
+// withinScheduleLimits checks if the operators of each kind are fewer than its
+// schedule limit.
+func (c *coordinator) withinScheduleLimits(kinds ...operator.OpKind) bool {
+	for _, kind := range kinds {
+		var limit uint64
+		switch kind {
+		case operator.OpLeader:
+			limit = c.cluster.GetLeaderScheduleLimit()
+		case operator.OpRegion:
+			limit = c.cluster.GetRegionScheduleLimit()
+		case operator.OpReplica:
+			limit = c.cluster.GetReplicaScheduleLimit()
+		case operator.OpMerge:
+			limit = c.cluster.GetMergeScheduleLimit()
+		default:
+			continue
+		}
+		if c.opController.OperatorCount(kind) >= limit {
+			return false
+		}
+	}
+	return true
+}
+
+// addWaitingOperatorWithinLimits adds the operators of a checker if the
+// schedule limits of the kinds and the location repair limits are still not
+// reached. The limits are checked again with the operators added under the
+// same lock, so that the concurrent patrol workers can not go past them.
+func (c *coordinator) addWaitingOperatorWithinLimits(kinds []operator.OpKind, ops ...*operator.Operator) bool {
+	c.limitMu.Lock()
+	defer c.limitMu.Unlock()
+	if !c.withinScheduleLimits(kinds...) {
+		return false
+	}
+	for _, op := range ops {
+		if c.isLocationRepairLimited(op) {
+			return false
+		}
+	}
+	return c.opController.AddWaitingOperator(ops...)
+}
+
+// isLocationRepairLimited checks if the operator repairs a location violation
+// whose label has reached its separate limit.
+func (c *coordinator) isLocationRepairLimited(op *operator.Operator) bool {
+	if op.Kind()&operator.OpLocation == 0 {
+		return false
+	}
+	label := op.LocationLabel()
+	limit := c.cluster.GetLocationRepairLimit(label)
+	return limit > 0 && c.opController.OperatorCountByLocation(label) >= limit
+}
+
+// getLocationViolations returns the location violations of all regions,
+// ordered by the severity from the worst.
+func (c *coordinator) getLocationViolations() []*checker.LocationViolation {
+	return c.replicaChecker.CheckLocations(c.cluster.GetRegions())
+}
+
+// checkMergePlan continues the merge plan targeting the region once the
+// previous merge finishes, instead of waiting for the patrol to reach it.
+func (c *coordinator) checkMergePlan(region *core.RegionInfo) {
+	opController := c.opController
+	if !c.mergeChecker.HasMergePlan(region.GetID()) || opController.GetOperator(region.GetID()) != nil ||
+		c.cluster.isSchedulingHalted() || !c.cluster.IsFeatureSupported(RegionMerge) ||
+		!c.withinScheduleLimits(operator.OpMerge) || !c.shouldCheck(mergeCheckerName) {
+		return
+	}
+	if ops := c.mergeChecker.Check(region); ops != nil {
+		checkerOperatorCounter.WithLabelValues(mergeCheckerName).Inc()
+		c.addWaitingOperatorWithinLimits([]operator.OpKind{operator.OpMerge}, ops...)
+	}
+}
+
+// checkNamespaceRange runs the namespace checker on the regions in the range
+// instead of waiting for the patrol to reach them. It stops when the schedule
+// limits are reached and leaves the rest to the patrol. It returns the number
+// of operators added.
+func (c *coordinator) checkNamespaceRange(startKey, endKey []byte) int {
+	opController := c.opController
+	var count int
+	for _, region := range c.cluster.ScanRegions(startKey, endKey, 0) {
+		if !c.withinScheduleLimits(operator.OpLeader, operator.OpRegion, operator.OpReplica) || !c.shouldCheck(namespaceCheckerName) {
+			break
+		}
+		if op := opController.GetOperator(region.GetID()); op != nil && !opController.IsPreemptible(op) {
+			continue
+		}
+		if op := c.namespaceChecker.Check(region); op != nil {
+			checkerOperatorCounter.WithLabelValues(namespaceCheckerName).Inc()
+			if c.addWaitingOperatorWithinLimits([]operator.OpKind{operator.OpLeader, operator.OpRegion, operator.OpReplica}, op) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func startCheckerSpan(parent opentracing.Span, name string) opentracing.Span {
+	return opentracing.StartSpan(name+"-checker", opentracing.ChildOf(parent.Context()))
+}
+
+// finishCheckerSpan finishes the span of a checker, and binds the created
+// operators to the span so that their dispatch can be traced.
+func finishCheckerSpan(span opentracing.Span, ops ...*operator.Operator) {
+	for _, op := range ops {
+		if op == nil {
+			continue
+		}
+		op.SetSpanContext(span.Context())
+		span.SetTag("operator", op.Desc())
+	}
+	span.Finish()
+}
+
+// shouldCheck returns if the checker is not paused, and counts the invocation
+// of the checker if so.
+func (c *coordinator) shouldCheck(name string) bool {
+	c.RLock()
+	paused := c.pausedCheckers[name]
+	c.RUnlock()
+	if paused {
+		return false
+	}
+	checkerInvocationCounter.WithLabelValues(name).Inc()
+	return true
+}
+
+// loadCheckerStatus loads the persisted paused states of checkers.
+func (c *coordinator) loadCheckerStatus() error {
+	c.Lock()
+	defer c.Unlock()
+	return c.cluster.storage.LoadCheckerPaused(func(name string, paused bool) {
+		c.pausedCheckers[name] = paused
+	})
+}
+
+func isValidChecker(name string) bool {
+	for _, n := range checkerNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// getCheckerStatus returns the status of the checker, or nil if the checker
+// does not exist.
+func (c *coordinator) getCheckerStatus(name string) *CheckerStatus {
+	if !isValidChecker(name) {
+		return nil
+	}
+	c.RLock()
+	defer c.RUnlock()
+	return &CheckerStatus{Name: name, Paused: c.pausedCheckers[name]}
+}
+
+// getCheckersStatus returns the status of all checkers.
+func (c *coordinator) getCheckersStatus() []*CheckerStatus {
+	c.RLock()
+	defer c.RUnlock()
+	status := make([]*CheckerStatus, 0, len(checkerNames))
+	for _, name := range checkerNames {
+		status = append(status, &CheckerStatus{Name: name, Paused: c.pausedCheckers[name]})
+	}
+	return status
+}
+
+// pauseChecker pauses or resumes the checker, and persists the state so that
+// it survives leader changes.
+func (c *coordinator) pauseChecker(name string, paused bool) error {
+	if !isValidChecker(name) {
+		return errCheckerNotFound
+	}
+	c.Lock()
+	defer c.Unlock()
+	if err := c.cluster.storage.SaveCheckerPaused(name, paused); err != nil {
+		return err
+	}
+	c.pausedCheckers[name] = paused
+	log.Info("update checker status", zap.String("checker", name), zap.Bool("paused", paused))
+	return nil
+}
+
 func (c *coordinator) run() {
 	ticker := time.NewTicker(runSchedulerCheckInterval)
 	defer ticker.Stop()
@@ -211,7 +614,10 @@ func (c *coordinator) run() {
 	log.Info("coordinator starts to run schedulers")
 
 	k := 0
+	c.Lock()
+	c.schedulersStarted = true
 	scheduleCfg := c.cluster.opt.Load().Clone()
+	c.Unlock()
 	for _, schedulerCfg := range scheduleCfg.Schedulers {
 		if schedulerCfg.Disable {
 			scheduleCfg.Schedulers[k] = schedulerCfg
@@ -243,10 +649,14 @@ func (c *coordinator) run() {
 		log.Error("cannot persist schedule config", zap.Error(err))
 	}
 
-	c.wg.Add(2)
+	sub := c.cluster.events.Subscribe("coordinator", eventBufferSize,
+		schedule.EventRegionSplit, schedule.EventRegionMerge, schedule.EventOperatorFinish)
+	c.wg.Add(4)
 	// Starts to patrol regions.
 	go c.patrolRegions()
 	go c.drivePushOperator()
+	go c.driveScatterQueue()
+	go c.driveEvents(sub)
 }
 
 func (c *coordinator) stop() {
@@ -412,6 +822,95 @@ func (c *coordinator) removeScheduler(name string) error {
 	return c.cluster.opt.RemoveSchedulerCfg(name)
 }
 
+func (c *coordinator) getSchedulerDeniedStores(name string) ([]uint64, error) {
+	c.RLock()
+	defer c.RUnlock()
+
+	if _, ok := c.schedulers[name]; !ok {
+		return nil, errSchedulerNotFound
+	}
+	return c.cluster.opt.GetSchedulerDeniedStores(name), nil
+}
+
+func (c *coordinator) setSchedulerDeniedStores(name string, storeIDs []uint64) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := c.schedulers[name]; !ok {
+		return errSchedulerNotFound
+	}
+	for _, id := range storeIDs {
+		if c.cluster.GetStore(id) == nil {
+			return core.NewStoreNotFoundErr(id)
+		}
+	}
+	return c.cluster.opt.SetSchedulerDeniedStores(name, storeIDs)
+}
+
+// hasConfig is implemented by the schedulers exposing their config and
+// progress.
+type hasConfig interface {
+	GetConfig() interface{}
+}
+
+// hasHits is implemented by the schedulers skipping the stores which fail to
+// be picked too many times.
+type hasHits interface {
+	ResetHits()
+}
+
+func (c *coordinator) resetSchedulerHits(name string) error {
+	c.RLock()
+	defer c.RUnlock()
+
+	s, ok := c.schedulers[name]
+	if !ok {
+		return errSchedulerNotFound
+	}
+	h, ok := s.Scheduler.(hasHits)
+	if !ok {
+		return errcode.NewInvalidInputErr(errors.Errorf("scheduler %s has no hit records", name))
+	}
+	h.ResetHits()
+	return nil
+}
+
+// hasStoreStates is implemented by the schedulers keeping the states of the
+// stores, such as the taint caches and the hit records.
+type hasStoreStates interface {
+	RemoveStaleStores(exists func(storeID uint64) bool) int
+}
+
+// removeStaleStores removes the states of the stores which do not exist from
+// the schedulers, and returns the number of the removed entries.
+func (c *coordinator) removeStaleStores(exists func(storeID uint64) bool) int {
+	c.RLock()
+	defer c.RUnlock()
+
+	var count int
+	for _, s := range c.schedulers {
+		if h, ok := s.Scheduler.(hasStoreStates); ok {
+			count += h.RemoveStaleStores(exists)
+		}
+	}
+	return count
+}
+
+func (c *coordinator) getSchedulerConfig(name string) (interface{}, error) {
+	c.RLock()
+	defer c.RUnlock()
+
+	s, ok := c.schedulers[name]
+	if !ok {
+		return nil, errSchedulerNotFound
+	}
+	h, ok := s.Scheduler.(hasConfig)
+	if !ok {
+		return nil, errors.Errorf("scheduler %s has no config", name)
+	}
+	return h.GetConfig(), nil
+}
+
 func (c *coordinator) runScheduler(s *scheduleController) {
 	defer logutil.LogPanic()
 	defer c.wg.Done()
@@ -474,11 +973,18 @@ func (s *scheduleController) Stop() {
 }
 
 func (s *scheduleController) Schedule() []*operator.Operator {
+	span := opentracing.StartSpan("schedule", opentracing.Tag{Key: "scheduler", Value: s.GetName()})
+	defer span.Finish()
 	for i := 0; i < maxScheduleRetries; i++ {
 		// If we have schedule, reset interval to the minimal interval.
-		if op := scheduleByNamespace(s.cluster, s.classifier, s.Scheduler); op != nil {
+		if ops := scheduleByNamespace(s.cluster, s.classifier, s.Scheduler, span); ops != nil {
 			s.nextInterval = s.Scheduler.GetMinInterval()
-			return op
+			for _, op := range ops {
+				op.SetSpanContext(span.Context())
+			}
+			span.SetTag("operator", ops[0].Desc())
+			span.SetTag("retries", i)
+			return ops
 		}
 	}
 	s.nextInterval = s.Scheduler.GetNextInterval(s.nextInterval)
@@ -492,5 +998,8 @@ func (s *scheduleController) GetInterval() time.Duration {
 
 // AllowSchedule returns if a scheduler is allowed to schedule.
 func (s *scheduleController) AllowSchedule() bool {
+	if s.cluster.isSchedulingHalted() {
+		return false
+	}
 	return s.Scheduler.IsScheduleAllowed(s.cluster)
 }
@@ -14,11 +14,16 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"path/filepath"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
 	. "github.com/pingcap/check"
 	"github.com/pingcap/kvproto/pkg/eraftpb"
 	"github.com/pingcap/kvproto/pkg/metapb"
@@ -26,6 +31,7 @@ import (
 	"github.com/pingcap/pd/pkg/mock/mockhbstream"
 	"github.com/pingcap/pd/pkg/mock/mockid"
 	"github.com/pingcap/pd/pkg/testutil"
+	"github.com/pingcap/pd/pkg/typeutil"
 	"github.com/pingcap/pd/server/config"
 	"github.com/pingcap/pd/server/core"
 	"github.com/pingcap/pd/server/id"
@@ -86,6 +92,20 @@ func (c *testCluster) addRegionStore(storeID uint64, regionCount int) error {
 	return c.putStoreLocked(newStore)
 }
 
+func (c *testCluster) addLabelsStore(storeID uint64, regionCount int, labels map[string]string) error {
+	if err := c.addRegionStore(storeID, regionCount); err != nil {
+		return err
+	}
+	var storeLabels []*metapb.StoreLabel
+	for k, v := range labels {
+		storeLabels = append(storeLabels, &metapb.StoreLabel{Key: k, Value: v})
+	}
+	newStore := c.GetStore(storeID).Clone(core.SetStoreLabels(storeLabels))
+	c.Lock()
+	defer c.Unlock()
+	return c.putStoreLocked(newStore)
+}
+
 func (c *testCluster) addLeaderRegion(regionID uint64, leaderID uint64, followerIds ...uint64) error {
 	region := newTestRegionMeta(regionID)
 	leader, _ := c.AllocPeer(leaderID)
@@ -277,6 +297,68 @@ func (s *testCoordinatorSuite) TestCollectMetrics(c *C) {
 	}
 }
 
+func (s *testCoordinatorSuite) TestPatrolShards(c *C) {
+	cfg, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cfg.PatrolRegionWorkerCount = 3
+	tc := newTestCluster(opt)
+	hbStreams, cleanup := getHeartBeatStreams(c, tc)
+	defer cleanup()
+	defer hbStreams.Close()
+
+	co := newCoordinator(tc.RaftCluster, hbStreams, namespace.DefaultClassifier)
+	co.run()
+	defer co.wg.Wait()
+	defer co.stop()
+
+	for i := uint64(1); i <= 4; i++ {
+		c.Assert(tc.addRegionStore(i, 0), IsNil)
+	}
+	for i := uint64(1); i <= 9; i++ {
+		c.Assert(tc.addLeaderRegion(i, 1), IsNil)
+	}
+	c.Assert(co.splitPatrolShards(1), HasLen, 0)
+	c.Assert(co.splitPatrolShards(10), HasLen, 0)
+	c.Assert(co.splitPatrolShards(3), DeepEquals, [][]byte{newTestRegionMeta(4).GetStartKey(), newTestRegionMeta(7).GetStartKey()})
+
+	// Each shard is patrolled.
+	for i := uint64(1); i <= 9; i++ {
+		waitOperator(c, co, i)
+		c.Assert(co.opController.GetOperator(i).Kind()&operator.OpReplica, Equals, operator.OpReplica)
+	}
+}
+
+func (s *testCoordinatorSuite) TestCheckRegionConcurrently(c *C) {
+	cfg, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cfg.ReplicaScheduleLimit = 2
+	opt.Store(cfg)
+	tc := newTestCluster(opt)
+	hbStreams, cleanup := getHeartBeatStreams(c, tc)
+	defer cleanup()
+	defer hbStreams.Close()
+
+	co := newCoordinator(tc.RaftCluster, hbStreams, namespace.DefaultClassifier)
+	for i := uint64(1); i <= 4; i++ {
+		c.Assert(tc.addRegionStore(i, 0), IsNil)
+	}
+	for i := uint64(1); i <= 20; i++ {
+		c.Assert(tc.addLeaderRegion(i, 1, 2), IsNil)
+	}
+
+	// The patrol workers can not go past the schedule limit together.
+	var wg sync.WaitGroup
+	for i := uint64(1); i <= 20; i++ {
+		wg.Add(1)
+		go func(regionID uint64) {
+			defer wg.Done()
+			co.checkRegion(tc.GetRegion(regionID))
+		}(i)
+	}
+	wg.Wait()
+	c.Assert(co.opController.OperatorCount(operator.OpReplica), Equals, uint64(2))
+}
+
 func (s *testCoordinatorSuite) TestCheckRegion(c *C) {
 	_, opt, err := newTestScheduleConfig()
 	c.Assert(err, IsNil)
@@ -331,6 +413,258 @@ func (s *testCoordinatorSuite) TestCheckRegion(c *C) {
 	c.Assert(co.checkRegion(tc.GetRegion(1)), IsFalse)
 }
 
+func (s *testCoordinatorSuite) TestCheckMergePlan(c *C) {
+	cfg, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cfg.SplitMergeInterval = typeutil.NewDuration(0)
+	cfg.MaxMergeRegionSize = 10
+	cfg.MaxMergeRegionKeys = 10
+	cfg.MaxMergeChainLength = 4
+	cfg.MergeScheduleLimit = 1
+	tc := newTestCluster(opt)
+	hbStreams, cleanup := getHeartBeatStreams(c, tc)
+	defer cleanup()
+	defer hbStreams.Close()
+
+	co := newCoordinator(tc.RaftCluster, hbStreams, namespace.DefaultClassifier)
+	for i := uint64(1); i <= 3; i++ {
+		c.Assert(tc.addRegionStore(i, 0), IsNil)
+	}
+	keys := []string{"", "a", "b", "c", ""}
+	regions := make([]*core.RegionInfo, 0, len(keys)-1)
+	for i := 0; i < len(keys)-1; i++ {
+		id := uint64(11 + i)
+		region := core.NewRegionInfo(
+			&metapb.Region{
+				Id:          id,
+				StartKey:    []byte(keys[i]),
+				EndKey:      []byte(keys[i+1]),
+				RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: 1},
+				Peers: []*metapb.Peer{
+					{Id: id*10 + 1, StoreId: 1},
+					{Id: id*10 + 2, StoreId: 2},
+					{Id: id*10 + 3, StoreId: 3},
+				},
+			},
+			&metapb.Peer{Id: id*10 + 1, StoreId: 1},
+			core.SetApproximateSize(1),
+			core.SetApproximateKeys(1),
+		)
+		regions = append(regions, region)
+		c.Assert(tc.putRegion(region), IsNil)
+	}
+
+	// Region 13 is merged into region 14, which plans to merge region 12.
+	c.Assert(co.checkRegion(regions[2]), IsTrue)
+	c.Assert(co.mergeChecker.HasMergePlan(14), IsTrue)
+	for _, id := range []uint64{13, 14} {
+		c.Assert(co.opController.RemoveOperator(co.opController.GetOperator(id)), IsTrue)
+	}
+	target := regions[3].Clone(core.WithStartKey(regions[2].GetStartKey()), core.WithIncVersion(), core.SetApproximateSize(2), core.SetApproximateKeys(2))
+	c.Assert(tc.putRegion(target), IsNil)
+
+	// The next merge of the plan is gated by the merge schedule limit.
+	blocker := newTestOperator(11, regions[0].GetRegionEpoch(), operator.OpMerge, operator.MergeRegion{})
+	c.Assert(co.opController.AddOperator(blocker), IsTrue)
+	co.checkMergePlan(target)
+	c.Assert(co.opController.GetOperator(14), IsNil)
+	c.Assert(co.opController.RemoveOperator(blocker), IsTrue)
+	co.checkMergePlan(target)
+	op := co.opController.GetOperator(14)
+	c.Assert(op, NotNil)
+	c.Assert(op.Desc(), Equals, "merge-region-chain")
+	c.Assert(co.opController.GetOperator(12), NotNil)
+}
+
+func (s *testCoordinatorSuite) TestPatrolPreempt(c *C) {
+	cfg, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	tc := newTestCluster(opt)
+	hbStreams, cleanup := getHeartBeatStreams(c, tc)
+	defer cleanup()
+	defer hbStreams.Close()
+
+	co := newCoordinator(tc.RaftCluster, hbStreams, namespace.DefaultClassifier)
+	for i := uint64(1); i <= 4; i++ {
+		c.Assert(tc.addRegionStore(i, 0), IsNil)
+	}
+	// The region misses a replica while it is being balanced.
+	c.Assert(tc.addLeaderRegion(1, 1, 2), IsNil)
+	region := tc.GetRegion(1)
+	balance := newTestOperator(1, region.GetRegionEpoch(), operator.OpLeader|operator.OpBalance, operator.TransferLeader{FromStore: 1, ToStore: 2})
+	c.Assert(co.opController.AddOperator(balance), IsTrue)
+
+	// The patrol skips the region without the preemption enabled.
+	co.patrolShard(0, nil, nil)
+	c.Assert(co.opController.GetOperator(1), Equals, balance)
+
+	// The replica checker preempts the balance operator.
+	cfg.PreemptiveOperatorKinds = []string{config.PreemptiveReplica}
+	co.patrolShard(0, nil, nil)
+	op := co.opController.GetOperator(1)
+	c.Assert(op, NotNil)
+	c.Assert(op.Kind()&operator.OpReplica, Equals, operator.OpReplica)
+
+	// The operator of the highest schedule priority is never preempted.
+	c.Assert(co.opController.IsPreemptible(op), IsFalse)
+}
+
+func (s *testCoordinatorSuite) TestPauseChecker(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	tc := newTestCluster(opt)
+	hbStreams, cleanup := getHeartBeatStreams(c, tc)
+	defer cleanup()
+	defer hbStreams.Close()
+
+	co := newCoordinator(tc.RaftCluster, hbStreams, namespace.DefaultClassifier)
+	c.Assert(co.pauseChecker("unknown", true), Equals, errCheckerNotFound)
+	c.Assert(co.getCheckerStatus("unknown"), IsNil)
+
+	c.Assert(tc.addRegionStore(3, 3), IsNil)
+	c.Assert(tc.addRegionStore(2, 2), IsNil)
+	c.Assert(tc.addRegionStore(1, 1), IsNil)
+	c.Assert(tc.addLeaderRegion(1, 2, 3), IsNil)
+	c.Assert(co.pauseChecker(replicaCheckerName, true), IsNil)
+	c.Assert(co.getCheckerStatus(replicaCheckerName).Paused, IsTrue)
+	c.Assert(co.checkRegion(tc.GetRegion(1)), IsFalse)
+	c.Assert(co.opController.GetOperator(1), IsNil)
+
+	// The paused state is loaded by a new coordinator.
+	co = newCoordinator(tc.RaftCluster, hbStreams, namespace.DefaultClassifier)
+	c.Assert(co.loadCheckerStatus(), IsNil)
+	c.Assert(co.getCheckerStatus(replicaCheckerName).Paused, IsTrue)
+	c.Assert(co.pauseChecker(replicaCheckerName, false), IsNil)
+	c.Assert(co.checkRegion(tc.GetRegion(1)), IsTrue)
+	testutil.CheckAddPeer(c, co.opController.GetOperator(1), operator.OpReplica, 1)
+}
+
+func (s *testCoordinatorSuite) TestSnapshotBandwidthCap(c *C) {
+	cfg, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	tc := newTestCluster(opt)
+	hbStreams, cleanup := getHeartBeatStreams(c, tc)
+	defer cleanup()
+	defer hbStreams.Close()
+
+	co := newCoordinator(tc.RaftCluster, hbStreams, namespace.DefaultClassifier)
+	tc.RaftCluster.coordinator = co
+	c.Assert(tc.addRegionStore(1, 1), IsNil)
+	c.Assert(tc.addRegionStore(2, 1), IsNil)
+	c.Assert(tc.addLeaderRegion(1, 1), IsNil)
+	c.Assert(tc.addLeaderRegion(2, 1), IsNil)
+	for _, regionID := range []uint64{1, 2} {
+		op := newTestOperator(regionID, tc.GetRegion(regionID).GetRegionEpoch(), operator.OpRegion,
+			operator.AddPeer{ToStore: 2, PeerID: 10 + regionID})
+		c.Assert(co.opController.AddOperator(op), IsTrue)
+	}
+
+	// No budget.
+	c.Assert(tc.snapshotBandwidthCapPairs(1), IsNil)
+	cfg.SnapshotBandwidthBudget = 100 << 20
+	opt.Store(cfg)
+	// The cap is only sent to the stores advertising the capability.
+	c.Assert(tc.snapshotBandwidthCapPairs(1), IsNil)
+	for _, storeID := range []uint64{1, 2} {
+		tc.updateStoreCapabilities(storeID, []string{operator.CapabilitySnapshotBandwidthCap})
+	}
+	c.Assert(tc.snapshotBandwidthCapPairs(1), DeepEquals, []string{snapshotBandwidthCapKey, strconv.Itoa(100 << 20)})
+	c.Assert(tc.snapshotBandwidthCapPairs(2), DeepEquals, []string{snapshotBandwidthCapKey, strconv.Itoa(50 << 20)})
+}
+
+func (s *testCoordinatorSuite) TestLocationRepairLimit(c *C) {
+	cfg, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	opt.GetReplication().Store(&config.ReplicationConfig{MaxReplicas: 3, LocationLabels: []string{"host"}})
+	cfg.LocationRepairLimits = map[string]uint64{"host": 1}
+	tc := newTestCluster(opt)
+	hbStreams, cleanup := getHeartBeatStreams(c, tc)
+	defer cleanup()
+	defer hbStreams.Close()
+
+	co := newCoordinator(tc.RaftCluster, hbStreams, namespace.DefaultClassifier)
+	c.Assert(tc.addLabelsStore(1, 1, map[string]string{"host": "h1"}), IsNil)
+	c.Assert(tc.addLabelsStore(2, 1, map[string]string{"host": "h1"}), IsNil)
+	c.Assert(tc.addLabelsStore(3, 1, map[string]string{"host": "h2"}), IsNil)
+	c.Assert(tc.addLabelsStore(4, 1, map[string]string{"host": "h3"}), IsNil)
+	c.Assert(tc.addLabelsStore(5, 1, map[string]string{"host": "h4"}), IsNil)
+	c.Assert(tc.addLeaderRegion(1, 1, 2, 3), IsNil)
+	c.Assert(tc.addLeaderRegion(2, 1, 2, 4), IsNil)
+
+	violations := co.getLocationViolations()
+	c.Assert(violations, HasLen, 2)
+	c.Assert(violations[0].Label, Equals, "host")
+
+	c.Assert(co.checkRegion(tc.GetRegion(1)), IsTrue)
+	op := co.opController.GetOperator(1)
+	c.Assert(op.Kind()&operator.OpLocation, Equals, operator.OpLocation)
+	c.Assert(op.LocationLabel(), Equals, "host")
+	c.Assert(co.opController.OperatorCountByLocation("host"), Equals, uint64(1))
+	// The limit of the label is reached.
+	c.Assert(co.checkRegion(tc.GetRegion(2)), IsFalse)
+	cfg.LocationRepairLimits["host"] = 2
+	c.Assert(co.checkRegion(tc.GetRegion(2)), IsTrue)
+}
+
+func (s *testCoordinatorSuite) TestTrace(c *C) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	tc := newTestCluster(opt)
+	hbStreams, cleanup := getHeartBeatStreams(c, tc)
+	defer cleanup()
+	defer hbStreams.Close()
+
+	co := newCoordinator(tc.RaftCluster, hbStreams, namespace.DefaultClassifier)
+	tc.RaftCluster.coordinator = co
+	c.Assert(tc.addRegionStore(3, 3), IsNil)
+	c.Assert(tc.addRegionStore(2, 2), IsNil)
+	c.Assert(tc.addRegionStore(1, 1), IsNil)
+	c.Assert(tc.addLeaderRegion(1, 2, 3), IsNil)
+	c.Assert(co.checkRegion(tc.GetRegion(1)), IsTrue)
+
+	spans := make(map[string]*mocktracer.MockSpan)
+	for _, span := range tracer.FinishedSpans() {
+		spans[span.OperationName] = span
+	}
+	checkSpan, replicaSpan := spans["checkRegion"], spans["replica-checker"]
+	c.Assert(checkSpan, NotNil)
+	c.Assert(replicaSpan, NotNil)
+	c.Assert(replicaSpan.ParentID, Equals, checkSpan.SpanContext.SpanID)
+	op := co.opController.GetOperator(1)
+	c.Assert(op.SpanContext().(mocktracer.MockSpanContext).SpanID, Equals, replicaSpan.SpanContext.SpanID)
+
+	// The dispatch of the operator follows from the checker.
+	tracer.Reset()
+	span := tracer.StartSpan("RegionHeartbeat")
+	c.Assert(tc.handleRegionHeartbeat(opentracing.ContextWithSpan(context.Background(), span), tc.GetRegion(1)), IsNil)
+	span.Finish()
+	spans = make(map[string]*mocktracer.MockSpan)
+	for _, span := range tracer.FinishedSpans() {
+		spans[span.OperationName] = span
+	}
+	c.Assert(spans["processRegionHeartbeat"], NotNil)
+	dispatchSpan := spans["dispatchOperator"]
+	c.Assert(dispatchSpan, NotNil)
+	c.Assert(dispatchSpan.SpanContext.TraceID, Equals, spans["RegionHeartbeat"].SpanContext.TraceID)
+
+	// The checks in the patrol are children of the patrol span.
+	tracer.Reset()
+	c.Assert(tc.addLeaderRegion(2, 2, 3), IsNil)
+	patrolSpan := tracer.StartSpan("patrolRegions")
+	co.checkRegionInSpan(patrolSpan, tc.GetRegion(2))
+	patrolSpan.Finish()
+	spans = make(map[string]*mocktracer.MockSpan)
+	for _, span := range tracer.FinishedSpans() {
+		spans[span.OperationName] = span
+	}
+	c.Assert(spans["checkRegion"], NotNil)
+	c.Assert(spans["checkRegion"].ParentID, Equals, spans["patrolRegions"].SpanContext.SpanID)
+}
+
 func (s *testCoordinatorSuite) TestReplica(c *C) {
 	// Turn off balance.
 	cfg, opt, err := newTestScheduleConfig()
@@ -609,6 +943,36 @@ func (s *testCoordinatorSuite) TestAddScheduler(c *C) {
 	waitNoResponse(c, stream)
 }
 
+func (s *testCoordinatorSuite) TestHaltScheduling(c *C) {
+	cfg, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	tc := newTestCluster(opt)
+	hbStreams, cleanup := getHeartBeatStreams(c, tc)
+	defer cleanup()
+	defer hbStreams.Close()
+	co := newCoordinator(tc.RaftCluster, hbStreams, namespace.DefaultClassifier)
+	co.run()
+	defer co.wg.Wait()
+	defer co.stop()
+
+	sc, ok := co.schedulers["balance-leader-scheduler"]
+	c.Assert(ok, IsTrue)
+	c.Assert(sc.AllowSchedule(), IsTrue)
+	tc.haltChecker.markUnstable("test")
+	c.Assert(tc.isSchedulingHalted(), IsTrue)
+	c.Assert(sc.AllowSchedule(), IsFalse)
+	// Rebalancing a store is rejected while scheduling is halted.
+	c.Assert(tc.addRegionStore(1, 1), IsNil)
+	_, err = tc.RebalanceStore(1, 10)
+	c.Assert(err, Equals, errSchedulingHalted)
+
+	// 0 disables the halt window.
+	cfg.SchedulingHaltWindow.Duration = 0
+	opt.Store(cfg)
+	c.Assert(tc.isSchedulingHalted(), IsFalse)
+	c.Assert(sc.AllowSchedule(), IsTrue)
+}
+
 func (s *testCoordinatorSuite) TestPersistScheduler(c *C) {
 	cfg, opt, err := newTestScheduleConfig()
 	c.Assert(err, IsNil)
@@ -186,6 +186,13 @@ func (bc *BasicCluster) GetStoreCount() int {
 	return bc.Stores.GetStoreCount()
 }
 
+// IsMixedTier checks if the stores in the cluster are of different types.
+func (bc *BasicCluster) IsMixedTier() bool {
+	bc.RLock()
+	defer bc.RUnlock()
+	return bc.Stores.IsMixedTier()
+}
+
 // GetStoreRegionCount gets the total count of a store's leader and follower RegionInfo by storeID.
 func (bc *BasicCluster) GetStoreRegionCount(storeID uint64) int {
 	bc.RLock()
@@ -285,11 +292,11 @@ func (bc *BasicCluster) SearchPrevRegion(regionKey []byte) *RegionInfo {
 }
 
 // ScanRange scans regions intersecting [start key, end key), returns at most
-// `limit` regions. limit <= 0 means no limit.
-func (bc *BasicCluster) ScanRange(startKey, endKey []byte, limit int) []*RegionInfo {
+// `limit` regions which match all the options. limit <= 0 means no limit.
+func (bc *BasicCluster) ScanRange(startKey, endKey []byte, limit int, opts ...RegionOption) []*RegionInfo {
 	bc.RLock()
 	defer bc.RUnlock()
-	return bc.Regions.ScanRange(startKey, endKey, limit)
+	return bc.Regions.ScanRange(startKey, endKey, limit, opts...)
 }
 
 // GetOverlaps returns the regions which are overlapped with the specified region range.
@@ -315,13 +322,15 @@ type RegionSetInformer interface {
 	GetStoreRegionCount(storeID uint64) int
 	GetRegion(id uint64) *RegionInfo
 	GetAdjacentRegions(region *RegionInfo) (*RegionInfo, *RegionInfo)
-	ScanRegions(startKey, endKey []byte, limit int) []*RegionInfo
+	ScanRegions(startKey, endKey []byte, limit int, opts ...RegionOption) []*RegionInfo
 }
 
 // StoreSetInformer provides access to a shared informer of stores.
 type StoreSetInformer interface {
 	GetStores() []*StoreInfo
 	GetStore(id uint64) *StoreInfo
+	// IsMixedTier checks if the stores are of different types.
+	IsMixedTier() bool
 
 	GetRegionStores(region *RegionInfo) []*StoreInfo
 	GetFollowerStores(region *RegionInfo) []*StoreInfo
@@ -0,0 +1,95 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// decommissionTimeLayout is the layout of the time of the day of the
+// decommission windows.
+const decommissionTimeLayout = "15:04"
+
+// DecommissionSchedule is the daily window to evacuate a removed store, such
+// as from 00:00 to 06:00. The times are in the local time of PD, and the
+// window wraps around midnight if Start is after End.
+type DecommissionSchedule struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// InitialRegionCount is the region count of the store when it is removed,
+	// which is used to report the progress.
+	InitialRegionCount int `json:"initial_region_count"`
+}
+
+// ParseDecommissionWindow parses a window in the format of "00:00-06:00".
+func ParseDecommissionWindow(window string) (*DecommissionSchedule, error) {
+	parts := strings.Split(window, "-")
+	if len(parts) != 2 {
+		return nil, errors.Errorf("invalid decommission window %q, should be like 00:00-06:00", window)
+	}
+	s := &DecommissionSchedule{Start: strings.TrimSpace(parts[0]), End: strings.TrimSpace(parts[1])}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Validate checks the window of the schedule.
+func (s *DecommissionSchedule) Validate() error {
+	start, err := time.Parse(decommissionTimeLayout, s.Start)
+	if err != nil {
+		return errors.Errorf("invalid decommission window start %q", s.Start)
+	}
+	end, err := time.Parse(decommissionTimeLayout, s.End)
+	if err != nil {
+		return errors.Errorf("invalid decommission window end %q", s.End)
+	}
+	if start.Equal(end) {
+		return errors.New("decommission window should not be empty")
+	}
+	return nil
+}
+
+// Contains returns whether the time is in the window.
+func (s *DecommissionSchedule) Contains(t time.Time) bool {
+	start, err := time.Parse(decommissionTimeLayout, s.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(decommissionTimeLayout, s.End)
+	if err != nil {
+		return false
+	}
+	minutes := t.Hour()*60 + t.Minute()
+	startMinutes, endMinutes := start.Hour()*60+start.Minute(), end.Hour()*60+end.Minute()
+	if startMinutes < endMinutes {
+		return minutes >= startMinutes && minutes < endMinutes
+	}
+	return minutes >= startMinutes || minutes < endMinutes
+}
+
+// Progress returns the fraction of the initial regions moved out of the store
+// with the region count.
+func (s *DecommissionSchedule) Progress(regionCount int) float64 {
+	if s.InitialRegionCount <= 0 || regionCount <= 0 {
+		return 1
+	}
+	if regionCount >= s.InitialRegionCount {
+		return 0
+	}
+	return float64(s.InitialRegionCount-regionCount) / float64(s.InitialRegionCount)
+}
@@ -0,0 +1,60 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"time"
+
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&testDecommissionSuite{})
+
+type testDecommissionSuite struct{}
+
+func (s *testDecommissionSuite) TestWindow(c *C) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2019, 6, 1, hour, minute, 0, 0, time.Local)
+	}
+
+	schedule, err := ParseDecommissionWindow("00:00-06:00")
+	c.Assert(err, IsNil)
+	c.Assert(schedule.Contains(at(0, 0)), IsTrue)
+	c.Assert(schedule.Contains(at(5, 59)), IsTrue)
+	c.Assert(schedule.Contains(at(6, 0)), IsFalse)
+	c.Assert(schedule.Contains(at(23, 59)), IsFalse)
+
+	// The window wraps around midnight.
+	schedule, err = ParseDecommissionWindow("22:30 - 02:00")
+	c.Assert(err, IsNil)
+	c.Assert(schedule.Contains(at(22, 29)), IsFalse)
+	c.Assert(schedule.Contains(at(23, 0)), IsTrue)
+	c.Assert(schedule.Contains(at(1, 59)), IsTrue)
+	c.Assert(schedule.Contains(at(2, 0)), IsFalse)
+
+	for _, window := range []string{"", "00:00", "00:00-06:00-07:00", "24:00-06:00", "00:00-abc", "06:00-06:00"} {
+		_, err = ParseDecommissionWindow(window)
+		c.Assert(err, NotNil)
+	}
+}
+
+func (s *testDecommissionSuite) TestProgress(c *C) {
+	schedule := &DecommissionSchedule{Start: "00:00", End: "06:00", InitialRegionCount: 10}
+	c.Assert(schedule.Progress(10), Equals, 0.0)
+	c.Assert(schedule.Progress(12), Equals, 0.0)
+	c.Assert(schedule.Progress(4), Equals, 0.6)
+	c.Assert(schedule.Progress(0), Equals, 1.0)
+	schedule.InitialRegionCount = 0
+	c.Assert(schedule.Progress(0), Equals, 1.0)
+}
@@ -34,6 +34,34 @@ var (
 	StoreTombstonedCode = storeStateCode.Child("state.store.tombstoned").SetHTTP(http.StatusGone)
 )
 
+// The codes below are returned by both the HTTP APIs and the gRPC APIs, in
+// the "code" field of the JSON bodies and in the details of the gRPC statuses,
+// so that the clients can branch on them without parsing the messages. They
+// must not be renamed once released.
+var (
+	// NotLeaderCode is an error due to requesting a PD which is not the leader.
+	NotLeaderCode = errcode.StateCode.Child("state.not_leader").SetHTTP(http.StatusServiceUnavailable)
+
+	// NotBootstrappedCode is an error due to requesting a cluster which is not bootstrapped yet.
+	// It keeps the HTTP status 500 returned before the code is introduced.
+	NotBootstrappedCode = errcode.StateCode.Child("state.not_bootstrapped").SetHTTP(http.StatusInternalServerError)
+
+	// RegionStaleCode is an error due to a region whose epoch is older than the one known by PD.
+	RegionStaleCode = errcode.StateCode.Child("state.region_stale").SetHTTP(http.StatusConflict)
+
+	// OperatorConflictCode is an error due to adding an operator to a region which already has one.
+	OperatorConflictCode = errcode.StateCode.Child("state.operator_conflict").SetHTTP(http.StatusConflict)
+
+	// StoreNotFoundCode is an error due to requesting a store which does not exist.
+	StoreNotFoundCode = errcode.NotFoundCode.Child("missing.store")
+
+	// RegionNotFoundCode is an error due to requesting a region which does not exist.
+	RegionNotFoundCode = errcode.NotFoundCode.Child("missing.region")
+
+	// OperatorNotFoundCode is an error due to requesting an operator which does not exist.
+	OperatorNotFoundCode = errcode.NotFoundCode.Child("missing.operator")
+)
+
 var _ errcode.ErrorCode = (*StoreTombstonedErr)(nil) // assert implements interface
 var _ errcode.ErrorCode = (*StoreBlockedErr)(nil)    // assert implements interface
 
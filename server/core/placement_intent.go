@@ -0,0 +1,64 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import "time"
+
+// PlacementIntent is the placement that users declare for a set of regions,
+// which is the store of the leader, the stores of the peers, or both. It is
+// experimental. The checker compares the regions with the intent when
+// patrolling them, and adds operators to converge them. The intent is kept
+// until it is deleted, so the regions are moved back if they are moved away.
+type PlacementIntent struct {
+	ID        uint64   `json:"id"`
+	RegionIDs []uint64 `json:"region_ids"`
+	// LeaderStoreID is 0 if the leaders are placed anywhere.
+	LeaderStoreID uint64 `json:"leader_store_id,omitempty"`
+	// StoreIDs is empty if the peers are placed anywhere. Otherwise there are
+	// as many stores as the max replicas.
+	StoreIDs   []uint64  `json:"store_ids,omitempty"`
+	Reason     string    `json:"reason"`
+	CreateTime time.Time `json:"create_time"`
+}
+
+// IsConverged checks if the region is placed as declared.
+func (p *PlacementIntent) IsConverged(region *RegionInfo) bool {
+	if p.LeaderStoreID != 0 && region.GetLeader().GetStoreId() != p.LeaderStoreID {
+		return false
+	}
+	return len(p.StoreIDs) == 0 || p.HasPeers(region)
+}
+
+// HasPeers checks if the region has peers exactly in the stores.
+func (p *PlacementIntent) HasPeers(region *RegionInfo) bool {
+	if len(region.GetPeers()) != len(p.StoreIDs) {
+		return false
+	}
+	for _, storeID := range p.StoreIDs {
+		if region.GetStorePeer(storeID) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// IsPeerPlaced returns if the peers are declared to be placed in the store.
+func (p *PlacementIntent) IsPeerPlaced(storeID uint64) bool {
+	for _, id := range p.StoreIDs {
+		if id == storeID {
+			return true
+		}
+	}
+	return false
+}
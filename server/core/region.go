@@ -42,6 +42,9 @@ type RegionInfo struct {
 	approximateSize int64
 	approximateKeys int64
 	interval        *pdpb.TimeInterval
+	// learnerLags is the applied index lag of the learners to the leader,
+	// keyed by the peer ID. It is reported by the store of the leader.
+	learnerLags map[uint64]uint64
 }
 
 // NewRegionInfo creates RegionInfo with region's meta and leader peer.
@@ -77,8 +80,9 @@ func classifyVoterAndLearner(region *RegionInfo) {
 // (heartbeat size <= 1MB).
 const EmptyRegionApproximateSize = 1
 
-// RegionFromHeartbeat constructs a Region from region heartbeat.
-func RegionFromHeartbeat(heartbeat *pdpb.RegionHeartbeatRequest) *RegionInfo {
+// RegionFromHeartbeat constructs a Region from region heartbeat, with the
+// options setting the info not carried by the heartbeat.
+func RegionFromHeartbeat(heartbeat *pdpb.RegionHeartbeatRequest, opts ...RegionCreateOption) *RegionInfo {
 	// Convert unit to MB.
 	// If region is empty or less than 1MB, use 1MB instead.
 	regionSize := heartbeat.GetApproximateSize() / (1 << 20)
@@ -100,6 +104,9 @@ func RegionFromHeartbeat(heartbeat *pdpb.RegionHeartbeatRequest) *RegionInfo {
 		interval:        heartbeat.GetInterval(),
 	}
 
+	for _, opt := range opts {
+		opt(region)
+	}
 	classifyVoterAndLearner(region)
 	return region
 }
@@ -127,6 +134,7 @@ func (r *RegionInfo) Clone(opts ...RegionCreateOption) *RegionInfo {
 		approximateSize: r.approximateSize,
 		approximateKeys: r.approximateKeys,
 		interval:        proto.Clone(r.interval).(*pdpb.TimeInterval),
+		learnerLags:     r.learnerLags,
 	}
 
 	for _, opt := range opts {
@@ -216,6 +224,18 @@ func (r *RegionInfo) GetPendingLearner(peerID uint64) *metapb.Peer {
 	return nil
 }
 
+// GetLearnerLag returns the applied index lag of the learner to the leader,
+// and whether the lag is known.
+func (r *RegionInfo) GetLearnerLag(peerID uint64) (uint64, bool) {
+	lag, ok := r.learnerLags[peerID]
+	return lag, ok
+}
+
+// GetLearnerLags returns the known applied index lags of the learners.
+func (r *RegionInfo) GetLearnerLags() map[uint64]uint64 {
+	return r.learnerLags
+}
+
 // GetStorePeer returns the peer in specified store.
 func (r *RegionInfo) GetStorePeer(storeID uint64) *metapb.Peer {
 	for _, peer := range r.meta.GetPeers() {
@@ -342,6 +362,28 @@ func (r *RegionInfo) GetBytesWritten() uint64 {
 	return r.writtenBytes
 }
 
+// flowRate returns the flow per second during the interval. It returns 0 if
+// the interval is unknown.
+func flowRate(flow uint64, interval *pdpb.TimeInterval) float64 {
+	start, end := interval.GetStartTimestamp(), interval.GetEndTimestamp()
+	if end <= start {
+		return 0
+	}
+	return float64(flow) / float64(end-start)
+}
+
+// GetBytesReadRate returns the read bytes per second of the region during
+// the last report interval.
+func (r *RegionInfo) GetBytesReadRate() float64 {
+	return flowRate(r.readBytes, r.interval)
+}
+
+// GetBytesWriteRate returns the written bytes per second of the region during
+// the last report interval.
+func (r *RegionInfo) GetBytesWriteRate() float64 {
+	return flowRate(r.writtenBytes, r.interval)
+}
+
 // GetKeysWritten returns the written keys of the region.
 func (r *RegionInfo) GetKeysWritten() uint64 {
 	return r.writtenKeys
@@ -718,8 +760,8 @@ func (r *RegionsInfo) GetFollower(storeID uint64, regionID uint64) *RegionInfo {
 }
 
 // ScanRange scans regions intersecting [start key, end key), returns at most
-// `limit` regions. limit <= 0 means no limit.
-func (r *RegionsInfo) ScanRange(startKey, endKey []byte, limit int) []*RegionInfo {
+// `limit` regions which match all the options. limit <= 0 means no limit.
+func (r *RegionsInfo) ScanRange(startKey, endKey []byte, limit int, opts ...RegionOption) []*RegionInfo {
 	var res []*RegionInfo
 	r.tree.scanRange(startKey, func(meta *metapb.Region) bool {
 		if len(endKey) > 0 && bytes.Compare(meta.StartKey, endKey) >= 0 {
@@ -728,12 +770,24 @@ func (r *RegionsInfo) ScanRange(startKey, endKey []byte, limit int) []*RegionInf
 		if limit > 0 && len(res) >= limit {
 			return false
 		}
-		res = append(res, r.GetRegion(meta.GetId()))
+		region := r.GetRegion(meta.GetId())
+		if isSelectedRegion(region, opts) {
+			res = append(res, region)
+		}
 		return true
 	})
 	return res
 }
 
+func isSelectedRegion(region *RegionInfo, opts []RegionOption) bool {
+	for _, opt := range opts {
+		if !opt(region) {
+			return false
+		}
+	}
+	return true
+}
+
 // ScanRangeWithIterator scans from the first region containing or behind start key,
 // until iterator returns false.
 func (r *RegionsInfo) ScanRangeWithIterator(startKey []byte, iterator func(metaRegion *metapb.Region) bool) {
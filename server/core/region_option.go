@@ -35,6 +35,61 @@ func HealthRegionAllowPending() RegionOption {
 	}
 }
 
+// LeaderInStore checks if the leader of the region is in the store.
+func LeaderInStore(storeID uint64) RegionOption {
+	return func(region *RegionInfo) bool {
+		return region.leader.GetStoreId() == storeID
+	}
+}
+
+// PeerInStore checks if the region has a peer in the store.
+func PeerInStore(storeID uint64) RegionOption {
+	return func(region *RegionInfo) bool {
+		return region.GetStorePeer(storeID) != nil
+	}
+}
+
+// DownPeerRegion checks if the region has down peers.
+func DownPeerRegion() RegionOption {
+	return func(region *RegionInfo) bool {
+		return len(region.downPeers) > 0
+	}
+}
+
+// NoPendingPeerRegion checks if the region has no pending peers.
+func NoPendingPeerRegion() RegionOption {
+	return func(region *RegionInfo) bool {
+		return len(region.pendingPeers) == 0
+	}
+}
+
+// RegionSizeAtLeast checks if the approximate size of the region is at least
+// the size in MB.
+func RegionSizeAtLeast(size int64) RegionOption {
+	return func(region *RegionInfo) bool {
+		return region.approximateSize >= size
+	}
+}
+
+// RegionInTier checks if all peers of the region are on the stores of the
+// tier. getStore returns the store by its ID.
+func RegionInTier(getStore func(storeID uint64) *StoreInfo, tier StoreType) RegionOption {
+	return func(region *RegionInfo) bool {
+		for _, peer := range region.meta.GetPeers() {
+			store := getStore(peer.GetStoreId())
+			if store == nil || store.GetStoreType() != tier {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// SelectRegion checks if the region is selected by all the options.
+func SelectRegion(region *RegionInfo, opts ...RegionOption) bool {
+	return isSelectedRegion(region, opts)
+}
+
 // RegionCreateOption used to create region.
 type RegionCreateOption func(region *RegionInfo)
 
@@ -194,6 +249,14 @@ func SetApproximateKeys(v int64) RegionCreateOption {
 	}
 }
 
+// WithLearnerLags sets the applied index lags of the learners, keyed by the
+// peer ID.
+func WithLearnerLags(lags map[uint64]uint64) RegionCreateOption {
+	return func(region *RegionInfo) {
+		region.learnerLags = lags
+	}
+}
+
 // SetReportInterval sets the report interval for the region.
 func SetReportInterval(v uint64) RegionCreateOption {
 	return func(region *RegionInfo) {
@@ -252,3 +315,14 @@ func WithPromoteLearner(peerID uint64) RegionCreateOption {
 		}
 	}
 }
+
+// WithDemoteVoter demotes the voter.
+func WithDemoteVoter(peerID uint64) RegionCreateOption {
+	return func(region *RegionInfo) {
+		for _, p := range region.GetPeers() {
+			if p.GetId() == peerID {
+				p.IsLearner = true
+			}
+		}
+	}
+}
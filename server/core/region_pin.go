@@ -0,0 +1,47 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import "time"
+
+// RegionPin pins the leader or the peers of a region to stores for a while,
+// such as for debugging or isolating benchmarks. The region is neither moved
+// out of the pinned stores nor onto other stores until the pin expires.
+type RegionPin struct {
+	RegionID uint64 `json:"region_id"`
+	// LeaderStoreID is the store that the leader is pinned to. The leader is
+	// not pinned if it is 0.
+	LeaderStoreID uint64 `json:"leader_store_id,omitempty"`
+	// StoreIDs are the stores that the peers are pinned to. The peers are not
+	// pinned if it is empty.
+	StoreIDs   []uint64  `json:"store_ids,omitempty"`
+	Reason     string    `json:"reason"`
+	PinTime    time.Time `json:"pin_time"`
+	ExpireTime time.Time `json:"expire_time"`
+}
+
+// IsExpired returns if the pin takes no effect at the time.
+func (p *RegionPin) IsExpired(now time.Time) bool {
+	return !now.Before(p.ExpireTime)
+}
+
+// IsPeerPinned returns if a peer of the region is pinned to the store.
+func (p *RegionPin) IsPeerPinned(storeID uint64) bool {
+	for _, id := range p.StoreIDs {
+		if id == storeID {
+			return true
+		}
+	}
+	return false
+}
@@ -30,7 +30,7 @@ var dirtyFlushTick = time.Second
 
 // RegionStorage is used to save regions.
 type RegionStorage struct {
-	*kv.LeveldbKV
+	kv.RegionBase
 	mu           sync.RWMutex
 	batchRegions map[string]*metapb.Region
 	batchSize    int
@@ -50,13 +50,19 @@ const (
 
 // NewRegionStorage returns a region storage that is used to save regions.
 func NewRegionStorage(path string) (*RegionStorage, error) {
-	levelDB, err := kv.NewLeveldbKV(path)
+	return NewRegionStorageWithBackend(kv.LeveldbBackend, path)
+}
+
+// NewRegionStorageWithBackend returns a region storage which saves regions
+// to the embedded kv of the backend.
+func NewRegionStorageWithBackend(backend, path string) (*RegionStorage, error) {
+	base, err := kv.NewRegionBase(backend, path)
 	if err != nil {
 		return nil, err
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	s := &RegionStorage{
-		LeveldbKV:    levelDB,
+		RegionBase:   base,
 		batchSize:    defaultBatchSize,
 		flushRate:    defaultFlushRegionRate,
 		batchRegions: make(map[string]*metapb.Region, defaultBatchSize),
@@ -181,5 +187,5 @@ func (s *RegionStorage) Close() error {
 		log.Error("meet error before close the region storage", zap.Error(err))
 	}
 	s.cancel()
-	return errors.WithStack(s.LeveldbKV.Close())
+	return errors.WithStack(s.RegionBase.Close())
 }
@@ -136,3 +136,30 @@ func (*testRegionKey) TestRegionKey(c *C) {
 		c.Assert(strings.Contains(s, t.expect), IsTrue)
 	}
 }
+
+var _ = Suite(&testRegionOptionSuite{})
+
+type testRegionOptionSuite struct{}
+
+func (s *testRegionOptionSuite) TestRegionOptions(c *C) {
+	peers := []*metapb.Peer{{Id: 11, StoreId: 1}, {Id: 12, StoreId: 2}}
+	region := NewRegionInfo(&metapb.Region{Id: 1, Peers: peers}, peers[0], SetApproximateSize(96))
+	c.Assert(SelectRegion(region), IsTrue)
+	c.Assert(SelectRegion(region, NoPendingPeerRegion(), RegionSizeAtLeast(96)), IsTrue)
+	c.Assert(SelectRegion(region, RegionSizeAtLeast(97)), IsFalse)
+	pending := region.Clone(WithPendingPeers(peers[1:]))
+	c.Assert(SelectRegion(pending, NoPendingPeerRegion()), IsFalse)
+
+	stores := map[uint64]*StoreInfo{
+		1: newTierStore(1, StoreTypeStorage, 100, 10, 0),
+		2: newTierStore(2, StoreTypeStorage, 100, 10, 0),
+		3: newTierStore(3, StoreTypePerformance, 100, 10, 0),
+	}
+	getStore := func(storeID uint64) *StoreInfo { return stores[storeID] }
+	c.Assert(SelectRegion(region, RegionInTier(getStore, StoreTypeStorage)), IsTrue)
+	c.Assert(SelectRegion(region, RegionInTier(getStore, StoreTypePerformance)), IsFalse)
+	mixed := region.Clone(WithAddPeer(&metapb.Peer{Id: 13, StoreId: 3}))
+	c.Assert(SelectRegion(mixed, RegionInTier(getStore, StoreTypeStorage)), IsFalse)
+	missing := region.Clone(WithAddPeer(&metapb.Peer{Id: 14, StoreId: 4}))
+	c.Assert(SelectRegion(missing, RegionInTier(getStore, StoreTypeStorage)), IsFalse)
+}
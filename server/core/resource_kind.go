@@ -13,6 +13,12 @@
 
 package core
 
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
 // PriorityLevel lower level means higher priority
 type PriorityLevel int
 
@@ -21,8 +27,26 @@ const (
 	LowPriority PriorityLevel = iota
 	NormalPriority
 	HighPriority
+	// UrgentPriority is used by the operators which repair regions one
+	// failure away from losing quorum. They are promoted before any others.
+	UrgentPriority
 )
 
+// ParsePriorityLevel parses the priority set by applications, which is one of
+// "low", "normal" and "high".
+func ParsePriorityLevel(s string) (PriorityLevel, error) {
+	switch strings.ToLower(s) {
+	case "low":
+		return LowPriority, nil
+	case "normal":
+		return NormalPriority, nil
+	case "high":
+		return HighPriority, nil
+	default:
+		return NormalPriority, errors.Errorf("unknown priority %q", s)
+	}
+}
+
 // ResourceKind distinguishes different kinds of resources.
 type ResourceKind int
 
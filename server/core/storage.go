@@ -14,8 +14,10 @@
 package core
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"math"
 	"path"
 	"strconv"
@@ -23,6 +25,7 @@ import (
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/pkg/cache"
 	"github.com/pingcap/pd/server/kv"
 	"github.com/pkg/errors"
 )
@@ -32,11 +35,59 @@ const (
 	configPath   = "config"
 	schedulePath = "schedule"
 	gcPath       = "gc"
+	// rangeLabelPath is the path of the label rules of key ranges.
+	rangeLabelPath = "range_label"
+	// evacuatePath is the path of the preferred targets to evacuate stores.
+	evacuatePath = "evacuate_to"
+	// checkerPath is the path of the paused states of checkers.
+	checkerPath = "checker"
+	// nsMigrationPath is the path of the migrations of key ranges between
+	// namespaces.
+	nsMigrationPath = "namespace_migration"
+	// classifierPath is the path of the namespace classifier switched at
+	// runtime, which overrides the configuration.
+	classifierPath = "classifier"
+	// blockedStorePath is the path of the stores blocked from scheduling by
+	// users.
+	blockedStorePath = "blocked_store"
+	// minResolvedTSPath is the path of the cluster-wide minimal resolved
+	// timestamp.
+	minResolvedTSPath = "min_resolved_ts"
+	// balanceIndexPath is the path of the recent history of the balance index
+	// of the stores.
+	balanceIndexPath = "balance_index"
+	// decommissionPath is the path of the schedules to evacuate removed
+	// stores.
+	decommissionPath = "decommission"
+	// storeCredentialPath is the path of the credentials to authenticate
+	// stores.
+	storeCredentialPath = "store_credential"
+	// schedulingPausePath is the path of the scheduling of the whole cluster
+	// paused by users and the recent events of pausing and resuming it.
+	schedulingPausePath = "scheduling_pause"
+	// regionPinPath is the path of the regions pinned to stores by users.
+	regionPinPath = "region_pin"
+	// scheduleSpeedOverridePath is the path of the scale of the schedule
+	// limits overridden by users.
+	scheduleSpeedOverridePath = "schedule_speed_override"
+	// configProvenancePath is the path of the provenance of the config
+	// items changed at runtime.
+	configProvenancePath = "config_provenance"
+	// deleteRangePath is the path of the tasks to delete key ranges.
+	deleteRangePath = "delete_range"
+	// mergePausePath is the path of the key ranges whose merges are paused.
+	mergePausePath = "merge_pause"
+	// placementIntentPath is the path of the placements of regions declared
+	// by users.
+	placementIntentPath = "placement_intent"
 )
 
 const (
 	maxKVRangeLimit = 10000
 	minKVRangeLimit = 100
+	// regionHashCacheSize is the number of the regions whose hashes of the
+	// saved metas are kept.
+	regionHashCacheSize = 1 << 16
 )
 
 // Storage wraps all kv operations, keep it stateless.
@@ -44,12 +95,16 @@ type Storage struct {
 	kv.Base
 	regionStorage    *RegionStorage
 	useRegionStorage int32
+	// regionHashes caches the hashes of the region metas saved recently, so
+	// that saving an unchanged region is skipped.
+	regionHashes cache.Cache
 }
 
 // NewStorage creates Storage instance with Base.
 func NewStorage(base kv.Base) *Storage {
 	return &Storage{
-		Base: base,
+		Base:         base,
+		regionHashes: cache.NewCache(regionHashCacheSize, cache.LRUCache),
 	}
 }
 
@@ -67,11 +122,22 @@ func (s *Storage) GetRegionStorage() *RegionStorage {
 // SwitchToRegionStorage switches to the region storage.
 func (s *Storage) SwitchToRegionStorage() {
 	atomic.StoreInt32(&s.useRegionStorage, 1)
+	s.ResetRegionHashes()
 }
 
 // SwitchToDefaultStorage switches to the to default storage.
 func (s *Storage) SwitchToDefaultStorage() {
 	atomic.StoreInt32(&s.useRegionStorage, 0)
+	s.ResetRegionHashes()
+}
+
+// ResetRegionHashes forgets the saved regions, since they may not be in the
+// storage any more, such as after the storage is switched, or after the
+// regions are saved by another leader.
+func (s *Storage) ResetRegionHashes() {
+	for _, item := range s.regionHashes.Elems() {
+		s.regionHashes.Remove(item.Key)
+	}
 }
 
 func (s *Storage) storePath(storeID uint64) string {
@@ -82,6 +148,14 @@ func regionPath(regionID uint64) string {
 	return path.Join(clusterPath, "r", fmt.Sprintf("%020d", regionID))
 }
 
+func evacuateTargetsPath(storeID uint64) string {
+	return path.Join(evacuatePath, fmt.Sprintf("%020d", storeID))
+}
+
+func decommissionSchedulePath(storeID uint64) string {
+	return path.Join(decommissionPath, fmt.Sprintf("%020d", storeID))
+}
+
 // ClusterStatePath returns the path to save an option.
 func (s *Storage) ClusterStatePath(option string) string {
 	return path.Join(clusterPath, "status", option)
@@ -95,6 +169,10 @@ func (s *Storage) storeRegionWeightPath(storeID uint64) string {
 	return path.Join(schedulePath, "store_weight", fmt.Sprintf("%020d", storeID), "region")
 }
 
+func (s *Storage) storeBenchmarkPath(storeID uint64) string {
+	return path.Join(schedulePath, "store_benchmark", fmt.Sprintf("%020d", storeID))
+}
+
 // LoadMeta loads cluster meta from storage.
 func (s *Storage) LoadMeta(meta *metapb.Cluster) (bool, error) {
 	return loadProto(s.Base, clusterPath, meta)
@@ -115,6 +193,19 @@ func (s *Storage) SaveStore(store *metapb.Store) error {
 	return saveProto(s.Base, s.storePath(store.GetId()), store)
 }
 
+// SaveStores saves the stores atomically if the underlying kv supports it.
+func (s *Storage) SaveStores(stores []*metapb.Store) error {
+	kvs := make(map[string]string, len(stores))
+	for _, store := range stores {
+		value, err := proto.Marshal(store)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		kvs[s.storePath(store.GetId())] = string(value)
+	}
+	return s.SaveBatch(kvs)
+}
+
 // DeleteStore deletes one store from storage.
 func (s *Storage) DeleteStore(store *metapb.Store) error {
 	return s.Remove(s.storePath(store.GetId()))
@@ -136,16 +227,37 @@ func (s *Storage) LoadRegions(f func(region *RegionInfo) []*metapb.Region) error
 	return loadRegions(s.Base, f)
 }
 
-// SaveRegion saves one region to storage.
+// SaveRegion saves one region to storage. It is skipped if the region is the
+// same as the one saved last time, such as after the balance moves a peer
+// back and forth.
 func (s *Storage) SaveRegion(region *metapb.Region) error {
+	value, err := proto.Marshal(region)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	h := fnv.New64a()
+	_, _ = h.Write(value)
+	hash := h.Sum64()
+	if saved, ok := s.regionHashes.Peek(region.GetId()); ok && saved.(uint64) == hash {
+		return nil
+	}
+
 	if atomic.LoadInt32(&s.useRegionStorage) > 0 {
-		return s.regionStorage.SaveRegion(region)
+		err = s.regionStorage.SaveRegion(region)
+	} else {
+		err = s.Save(regionPath(region.GetId()), string(value))
+	}
+	if err != nil {
+		s.regionHashes.Remove(region.GetId())
+		return err
 	}
-	return saveProto(s.Base, regionPath(region.GetId()), region)
+	s.regionHashes.Put(region.GetId(), hash)
+	return nil
 }
 
 // DeleteRegion deletes one region from storage.
 func (s *Storage) DeleteRegion(region *metapb.Region) error {
+	s.regionHashes.Remove(region.GetId())
 	if atomic.LoadInt32(&s.useRegionStorage) > 0 {
 		return deleteRegion(s.regionStorage, region)
 	}
@@ -177,6 +289,312 @@ func (s *Storage) LoadConfig(cfg interface{}) (bool, error) {
 	return true, nil
 }
 
+// SaveClassifierState stores the state of the namespace classifier switched
+// at runtime.
+func (s *Storage) SaveClassifierState(state interface{}) error {
+	value, err := json.Marshal(state)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return s.Save(classifierPath, string(value))
+}
+
+// LoadClassifierState loads the state of the namespace classifier switched at
+// runtime. It returns false if the classifier has never been switched.
+func (s *Storage) LoadClassifierState(state interface{}) (bool, error) {
+	value, err := s.Load(classifierPath)
+	if err != nil {
+		return false, err
+	}
+	if value == "" {
+		return false, nil
+	}
+	if err = json.Unmarshal([]byte(value), state); err != nil {
+		return false, errors.WithStack(err)
+	}
+	return true, nil
+}
+
+// SaveRangeLabelRule stores a label rule of key ranges.
+func (s *Storage) SaveRangeLabelRule(id string, rule interface{}) error {
+	value, err := json.Marshal(rule)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return s.Save(rangeLabelRulePath(id), string(value))
+}
+
+// DeleteRangeLabelRule deletes a label rule of key ranges.
+func (s *Storage) DeleteRangeLabelRule(id string) error {
+	return s.Remove(rangeLabelRulePath(id))
+}
+
+// rangeLabelRulePath returns the path of the label rule. The ID is given by
+// users, so it is hex encoded to keep "/" and ".." from escaping the path.
+func rangeLabelRulePath(id string) string {
+	return path.Join(rangeLabelPath, hex.EncodeToString([]byte(id)))
+}
+
+// LoadRangeLabelRules loads all label rules of key ranges.
+func (s *Storage) LoadRangeLabelRules(f func(value string) error) error {
+	return s.loadDir(rangeLabelPath, func(_, value string) error { return f(value) })
+}
+
+// SaveNamespaceMigration stores a migration of a key range between namespaces.
+func (s *Storage) SaveNamespaceMigration(id uint64, migration interface{}) error {
+	value, err := json.Marshal(migration)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return s.Save(path.Join(nsMigrationPath, fmt.Sprintf("%020d", id)), string(value))
+}
+
+// DeleteNamespaceMigration deletes a migration of a key range between
+// namespaces.
+func (s *Storage) DeleteNamespaceMigration(id uint64) error {
+	return s.Remove(path.Join(nsMigrationPath, fmt.Sprintf("%020d", id)))
+}
+
+// LoadNamespaceMigrations loads all migrations of key ranges between
+// namespaces.
+func (s *Storage) LoadNamespaceMigrations(f func(value string) error) error {
+	return s.loadDir(nsMigrationPath, func(_, value string) error { return f(value) })
+}
+
+// SaveDeleteRangeTask stores a task to delete a key range.
+func (s *Storage) SaveDeleteRangeTask(id uint64, task interface{}) error {
+	value, err := json.Marshal(task)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return s.Save(path.Join(deleteRangePath, fmt.Sprintf("%020d", id)), string(value))
+}
+
+// DeleteDeleteRangeTask deletes a task to delete a key range.
+func (s *Storage) DeleteDeleteRangeTask(id uint64) error {
+	return s.Remove(path.Join(deleteRangePath, fmt.Sprintf("%020d", id)))
+}
+
+// LoadDeleteRangeTasks loads all tasks to delete key ranges.
+func (s *Storage) LoadDeleteRangeTasks(f func(value string) error) error {
+	return s.loadDir(deleteRangePath, func(_, value string) error { return f(value) })
+}
+
+// SaveMergePause stores a pause of merges in a key range.
+func (s *Storage) SaveMergePause(id uint64, pause interface{}) error {
+	value, err := json.Marshal(pause)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return s.Save(path.Join(mergePausePath, fmt.Sprintf("%020d", id)), string(value))
+}
+
+// DeleteMergePause deletes a pause of merges in a key range.
+func (s *Storage) DeleteMergePause(id uint64) error {
+	return s.Remove(path.Join(mergePausePath, fmt.Sprintf("%020d", id)))
+}
+
+// LoadMergePauses loads all pauses of merges in key ranges.
+func (s *Storage) LoadMergePauses(f func(value string) error) error {
+	return s.loadDir(mergePausePath, func(_, value string) error { return f(value) })
+}
+
+// SavePlacementIntent stores a placement of regions declared by users.
+func (s *Storage) SavePlacementIntent(id uint64, intent interface{}) error {
+	value, err := json.Marshal(intent)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return s.Save(path.Join(placementIntentPath, fmt.Sprintf("%020d", id)), string(value))
+}
+
+// DeletePlacementIntent deletes a placement of regions declared by users.
+func (s *Storage) DeletePlacementIntent(id uint64) error {
+	return s.Remove(path.Join(placementIntentPath, fmt.Sprintf("%020d", id)))
+}
+
+// LoadPlacementIntents loads all placements of regions declared by users.
+func (s *Storage) LoadPlacementIntents(f func(value string) error) error {
+	return s.loadDir(placementIntentPath, func(_, value string) error { return f(value) })
+}
+
+// SaveBlockedStore stores a store blocked from scheduling.
+func (s *Storage) SaveBlockedStore(storeID uint64, record interface{}) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return s.Save(path.Join(blockedStorePath, fmt.Sprintf("%020d", storeID)), string(value))
+}
+
+// DeleteBlockedStore deletes a store blocked from scheduling.
+func (s *Storage) DeleteBlockedStore(storeID uint64) error {
+	return s.Remove(path.Join(blockedStorePath, fmt.Sprintf("%020d", storeID)))
+}
+
+// LoadBlockedStores loads all stores blocked from scheduling.
+func (s *Storage) LoadBlockedStores(f func(value string) error) error {
+	return s.loadDir(blockedStorePath, func(_, value string) error { return f(value) })
+}
+
+// SaveRegionPin stores a region pinned to stores.
+func (s *Storage) SaveRegionPin(regionID uint64, pin *RegionPin) error {
+	value, err := json.Marshal(pin)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return s.Save(path.Join(regionPinPath, fmt.Sprintf("%020d", regionID)), string(value))
+}
+
+// DeleteRegionPin deletes a region pinned to stores.
+func (s *Storage) DeleteRegionPin(regionID uint64) error {
+	return s.Remove(path.Join(regionPinPath, fmt.Sprintf("%020d", regionID)))
+}
+
+// LoadRegionPins loads all regions pinned to stores.
+func (s *Storage) LoadRegionPins(f func(pin *RegionPin)) error {
+	return s.loadDir(regionPinPath, func(_, value string) error {
+		pin := &RegionPin{}
+		if err := json.Unmarshal([]byte(value), pin); err != nil {
+			return errors.WithStack(err)
+		}
+		f(pin)
+		return nil
+	})
+}
+
+// SaveStoreCredential stores the credential of a store.
+func (s *Storage) SaveStoreCredential(storeID uint64, record interface{}) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return s.Save(path.Join(storeCredentialPath, fmt.Sprintf("%020d", storeID)), string(value))
+}
+
+// DeleteStoreCredential deletes the credential of a store.
+func (s *Storage) DeleteStoreCredential(storeID uint64) error {
+	return s.Remove(path.Join(storeCredentialPath, fmt.Sprintf("%020d", storeID)))
+}
+
+// LoadStoreCredentials loads the credentials of all stores.
+func (s *Storage) LoadStoreCredentials(f func(value string) error) error {
+	return s.loadDir(storeCredentialPath, func(_, value string) error { return f(value) })
+}
+
+// SaveEvacuateTargets stores the preferred targets to evacuate the store.
+func (s *Storage) SaveEvacuateTargets(storeID uint64, targets []uint64) error {
+	value, err := json.Marshal(targets)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return s.Save(evacuateTargetsPath(storeID), string(value))
+}
+
+// DeleteEvacuateTargets deletes the preferred targets to evacuate the store.
+func (s *Storage) DeleteEvacuateTargets(storeID uint64) error {
+	return s.Remove(evacuateTargetsPath(storeID))
+}
+
+// LoadEvacuateTargets loads the preferred targets to evacuate all stores.
+func (s *Storage) LoadEvacuateTargets(f func(storeID uint64, targets []uint64)) error {
+	return s.loadDir(evacuatePath, func(key, value string) error {
+		storeID, err := strconv.ParseUint(path.Base(key), 10, 64)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		var targets []uint64
+		if err := json.Unmarshal([]byte(value), &targets); err != nil {
+			return errors.WithStack(err)
+		}
+		f(storeID, targets)
+		return nil
+	})
+}
+
+// SaveDecommissionSchedule stores the schedule to evacuate the store.
+func (s *Storage) SaveDecommissionSchedule(storeID uint64, schedule *DecommissionSchedule) error {
+	value, err := json.Marshal(schedule)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return s.Save(decommissionSchedulePath(storeID), string(value))
+}
+
+// DeleteDecommissionSchedule deletes the schedule to evacuate the store.
+func (s *Storage) DeleteDecommissionSchedule(storeID uint64) error {
+	return s.Remove(decommissionSchedulePath(storeID))
+}
+
+// LoadDecommissionSchedules loads the schedules to evacuate all stores.
+func (s *Storage) LoadDecommissionSchedules(f func(storeID uint64, schedule *DecommissionSchedule)) error {
+	return s.loadDir(decommissionPath, func(key, value string) error {
+		storeID, err := strconv.ParseUint(path.Base(key), 10, 64)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		schedule := &DecommissionSchedule{}
+		if err := json.Unmarshal([]byte(value), schedule); err != nil {
+			return errors.WithStack(err)
+		}
+		f(storeID, schedule)
+		return nil
+	})
+}
+
+// SaveCheckerPaused stores whether the checker is paused.
+func (s *Storage) SaveCheckerPaused(name string, paused bool) error {
+	return s.Save(path.Join(checkerPath, name), strconv.FormatBool(paused))
+}
+
+// LoadCheckerPaused loads the paused states of all checkers.
+func (s *Storage) LoadCheckerPaused(f func(name string, paused bool)) error {
+	return s.loadDir(checkerPath, func(key, value string) error {
+		paused, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		f(path.Base(key), paused)
+		return nil
+	})
+}
+
+// SaveBatch saves the key-values atomically if the underlying kv supports it,
+// or one by one otherwise.
+func (s *Storage) SaveBatch(kvs map[string]string) error {
+	if b, ok := s.Base.(kv.BatchBase); ok {
+		return b.SaveBatch(kvs)
+	}
+	for key, value := range kvs {
+		if err := s.Save(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadDir loads all keys under the directory.
+func (s *Storage) loadDir(dir string, f func(key, value string) error) error {
+	nextKey := dir + "/"
+	// '0' is the next byte of '/'.
+	endKey := dir + "0"
+	for {
+		keys, values, err := s.LoadRange(nextKey, endKey, minKVRangeLimit)
+		if err != nil {
+			return err
+		}
+		for i := range keys {
+			if err := f(keys[i], values[i]); err != nil {
+				return err
+			}
+		}
+		if len(keys) < minKVRangeLimit {
+			return nil
+		}
+		nextKey = keys[len(keys)-1] + "\x00"
+	}
+}
+
 // LoadStores loads all stores from storage to StoresInfo.
 func (s *Storage) LoadStores(f func(store *StoreInfo)) error {
 	nextID := uint64(0)
@@ -200,7 +618,11 @@ func (s *Storage) LoadStores(f func(store *StoreInfo)) error {
 			if err != nil {
 				return err
 			}
-			newStoreInfo := NewStoreInfo(store, SetLeaderWeight(leaderWeight), SetRegionWeight(regionWeight))
+			benchmark, err := s.loadStoreBenchmark(store.GetId())
+			if err != nil {
+				return err
+			}
+			newStoreInfo := NewStoreInfo(store, SetLeaderWeight(leaderWeight), SetRegionWeight(regionWeight), SetStoreBenchmark(benchmark))
 
 			nextID = store.GetId() + 1
 			f(newStoreInfo)
@@ -221,6 +643,31 @@ func (s *Storage) SaveStoreWeight(storeID uint64, leader, region float64) error
 	return s.Save(s.storeRegionWeightPath(storeID), regionValue)
 }
 
+// SaveStoreBenchmark saves the benchmark scores a store reports, or deletes
+// them if benchmark is nil.
+func (s *Storage) SaveStoreBenchmark(storeID uint64, benchmark *StoreBenchmark) error {
+	if benchmark == nil {
+		return s.Remove(s.storeBenchmarkPath(storeID))
+	}
+	value, err := json.Marshal(benchmark)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return s.Save(s.storeBenchmarkPath(storeID), string(value))
+}
+
+func (s *Storage) loadStoreBenchmark(storeID uint64) (*StoreBenchmark, error) {
+	value, err := s.Load(s.storeBenchmarkPath(storeID))
+	if err != nil || value == "" {
+		return nil, err
+	}
+	benchmark := &StoreBenchmark{}
+	if err := json.Unmarshal([]byte(value), benchmark); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return benchmark, nil
+}
+
 func (s *Storage) loadFloatWithDefaultValue(path string, def float64) (float64, error) {
 	res, err := s.Load(path)
 	if err != nil {
@@ -276,6 +723,135 @@ func (s *Storage) LoadGCSafePoint() (uint64, error) {
 	return safePoint, nil
 }
 
+// SaveMinResolvedTS saves the cluster-wide minimal resolved timestamp.
+func (s *Storage) SaveMinResolvedTS(ts uint64) error {
+	return s.Save(minResolvedTSPath, strconv.FormatUint(ts, 16))
+}
+
+// LoadMinResolvedTS loads the cluster-wide minimal resolved timestamp.
+func (s *Storage) LoadMinResolvedTS() (uint64, error) {
+	value, err := s.Load(minResolvedTSPath)
+	if err != nil {
+		return 0, err
+	}
+	if value == "" {
+		return 0, nil
+	}
+	ts, err := strconv.ParseUint(value, 16, 64)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return ts, nil
+}
+
+// SaveBalanceIndexHistory saves the recent history of the balance index.
+func (s *Storage) SaveBalanceIndexHistory(history interface{}) error {
+	value, err := json.Marshal(history)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return s.Save(balanceIndexPath, string(value))
+}
+
+// LoadBalanceIndexHistory loads the recent history of the balance index. It
+// returns false if there is no history.
+func (s *Storage) LoadBalanceIndexHistory(history interface{}) (bool, error) {
+	value, err := s.Load(balanceIndexPath)
+	if err != nil {
+		return false, err
+	}
+	if value == "" {
+		return false, nil
+	}
+	if err = json.Unmarshal([]byte(value), history); err != nil {
+		return false, errors.WithStack(err)
+	}
+	return true, nil
+}
+
+// SaveSchedulingPause saves the scheduling pause and its recent events.
+func (s *Storage) SaveSchedulingPause(status interface{}) error {
+	value, err := json.Marshal(status)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return s.Save(schedulingPausePath, string(value))
+}
+
+// LoadSchedulingPause loads the scheduling pause and its recent events. It
+// returns false if scheduling has never been paused.
+func (s *Storage) LoadSchedulingPause(status interface{}) (bool, error) {
+	value, err := s.Load(schedulingPausePath)
+	if err != nil {
+		return false, err
+	}
+	if value == "" {
+		return false, nil
+	}
+	if err = json.Unmarshal([]byte(value), status); err != nil {
+		return false, errors.WithStack(err)
+	}
+	return true, nil
+}
+
+// SaveConfigProvenance saves the provenance of the config items changed at
+// runtime.
+func (s *Storage) SaveConfigProvenance(provenance interface{}) error {
+	value, err := json.Marshal(provenance)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return s.Save(configProvenancePath, string(value))
+}
+
+// LoadConfigProvenance loads the provenance of the config items changed at
+// runtime. It returns false if it is not saved.
+func (s *Storage) LoadConfigProvenance(provenance interface{}) (bool, error) {
+	value, err := s.Load(configProvenancePath)
+	if err != nil {
+		return false, err
+	}
+	if value == "" {
+		return false, nil
+	}
+	if err = json.Unmarshal([]byte(value), provenance); err != nil {
+		return false, errors.WithStack(err)
+	}
+	return true, nil
+}
+
+// SaveScheduleSpeedOverride saves the scale of the schedule limits overridden
+// by users.
+func (s *Storage) SaveScheduleSpeedOverride(override interface{}) error {
+	value, err := json.Marshal(override)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return s.Save(scheduleSpeedOverridePath, string(value))
+}
+
+// DeleteScheduleSpeedOverride deletes the override of the scale of the
+// schedule limits.
+func (s *Storage) DeleteScheduleSpeedOverride() error {
+	return s.Remove(scheduleSpeedOverridePath)
+}
+
+// LoadScheduleSpeedOverride loads the scale of the schedule limits overridden
+// by users. It returns false if it is not overridden.
+func (s *Storage) LoadScheduleSpeedOverride(override interface{}) (bool, error) {
+	value, err := s.Load(scheduleSpeedOverridePath)
+	if err != nil {
+		return false, err
+	}
+	if value == "" {
+		return false, nil
+	}
+	if err = json.Unmarshal([]byte(value), override); err != nil {
+		return false, errors.WithStack(err)
+	}
+	return true, nil
+}
+
 func loadProto(s kv.Base, key string, msg proto.Message) (bool, error) {
 	value, err := s.Load(key)
 	if err != nil {
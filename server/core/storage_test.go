@@ -15,8 +15,12 @@ package core
 
 import (
 	"fmt"
+	"io/ioutil"
 	"math"
+	"os"
+	"path/filepath"
 
+	"github.com/gogo/protobuf/proto"
 	. "github.com/pingcap/check"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/pd/server/kv"
@@ -146,6 +150,40 @@ func (s *testKVSuite) TestLoadRegions(c *C) {
 	}
 }
 
+func (s *testKVSuite) TestSkipUnchangedRegion(c *C) {
+	storage := NewStorage(kv.NewMemoryKV())
+	region := newTestRegionMeta(1)
+	c.Assert(storage.SaveRegion(region), IsNil)
+
+	// The unchanged region is not saved again.
+	c.Assert(storage.Remove(regionPath(1)), IsNil)
+	c.Assert(storage.SaveRegion(proto.Clone(region).(*metapb.Region)), IsNil)
+	ok, err := storage.LoadRegion(1, &metapb.Region{})
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsFalse)
+
+	region.RegionEpoch = &metapb.RegionEpoch{ConfVer: 1}
+	c.Assert(storage.SaveRegion(region), IsNil)
+	ok, err = storage.LoadRegion(1, &metapb.Region{})
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsTrue)
+
+	// The deleted region is saved again.
+	c.Assert(storage.DeleteRegion(region), IsNil)
+	c.Assert(storage.SaveRegion(region), IsNil)
+	ok, err = storage.LoadRegion(1, &metapb.Region{})
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsTrue)
+
+	// The region is saved again after the hashes are reset.
+	c.Assert(storage.Remove(regionPath(1)), IsNil)
+	storage.ResetRegionHashes()
+	c.Assert(storage.SaveRegion(region), IsNil)
+	ok, err = storage.LoadRegion(1, &metapb.Region{})
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsTrue)
+}
+
 func (s *testKVSuite) TestLoadRegionsExceedRangeLimit(c *C) {
 	storage := NewStorage(&KVWithMaxRangeLimit{Base: kv.NewMemoryKV(), rangeLimit: 500})
 	cache := NewRegionsInfo()
@@ -159,6 +197,96 @@ func (s *testKVSuite) TestLoadRegionsExceedRangeLimit(c *C) {
 	}
 }
 
+func (s *testKVSuite) TestRangeLabelRules(c *C) {
+	storage := NewStorage(kv.NewMemoryKV())
+	c.Assert(storage.SaveConfig(&struct{ Name string }{"pd"}), IsNil)
+	// The IDs do not escape the path of the rules.
+	for _, id := range []string{"a/b", "..", "../config"} {
+		c.Assert(storage.SaveRangeLabelRule(id, id), IsNil)
+	}
+	c.Assert(storage.DeleteRangeLabelRule(".."), IsNil)
+	var rules []string
+	c.Assert(storage.LoadRangeLabelRules(func(value string) error {
+		rules = append(rules, value)
+		return nil
+	}), IsNil)
+	c.Assert(rules, HasLen, 2)
+	cfg := &struct{ Name string }{}
+	ok, err := storage.LoadConfig(cfg)
+	c.Assert(ok, IsTrue)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Name, Equals, "pd")
+}
+
+func (s *testKVSuite) TestRegionStorageBackends(c *C) {
+	for _, backend := range []string{kv.LeveldbBackend, kv.BoltBackend} {
+		dir, err := ioutil.TempDir("", "region_storage")
+		c.Assert(err, IsNil)
+		regionStorage, err := NewRegionStorageWithBackend(backend, filepath.Join(dir, backend))
+		c.Assert(err, IsNil)
+		storage := NewStorage(kv.NewMemoryKV()).SetRegionStorage(regionStorage)
+		storage.SwitchToRegionStorage()
+
+		n := 250
+		regions := mustSaveRegions(c, storage, n)
+		c.Assert(storage.Flush(), IsNil)
+		c.Assert(storage.DeleteRegion(regions[n-1]), IsNil)
+		region := &metapb.Region{}
+		ok, err := storage.LoadRegion(1, region)
+		c.Assert(ok, IsTrue)
+		c.Assert(err, IsNil)
+		c.Assert(region, DeepEquals, regions[1])
+
+		cache := NewRegionsInfo()
+		c.Assert(storage.LoadRegions(cache.SetRegion), IsNil)
+		c.Assert(cache.GetRegionCount(), Equals, n-1)
+		for _, region := range cache.GetMetaRegions() {
+			c.Assert(region, DeepEquals, regions[region.GetId()])
+		}
+		c.Assert(storage.Close(), IsNil)
+		os.RemoveAll(dir)
+	}
+	_, err := NewRegionStorageWithBackend("unknown", "")
+	c.Assert(err, NotNil)
+}
+
+func (s *testKVSuite) TestEvacuateTargets(c *C) {
+	storage := NewStorage(kv.NewMemoryKV())
+	c.Assert(storage.SaveEvacuateTargets(1, []uint64{2, 3}), IsNil)
+	c.Assert(storage.SaveEvacuateTargets(4, []uint64{5}), IsNil)
+	c.Assert(storage.DeleteEvacuateTargets(4), IsNil)
+	targets := make(map[uint64][]uint64)
+	c.Assert(storage.LoadEvacuateTargets(func(storeID uint64, ids []uint64) { targets[storeID] = ids }), IsNil)
+	c.Assert(targets, DeepEquals, map[uint64][]uint64{1: {2, 3}})
+}
+
+func (s *testKVSuite) TestDecommissionSchedules(c *C) {
+	storage := NewStorage(kv.NewMemoryKV())
+	c.Assert(storage.SaveDecommissionSchedule(1, &DecommissionSchedule{Start: "00:00", End: "06:00", InitialRegionCount: 10}), IsNil)
+	c.Assert(storage.SaveDecommissionSchedule(4, &DecommissionSchedule{Start: "22:00", End: "02:00"}), IsNil)
+	c.Assert(storage.DeleteDecommissionSchedule(4), IsNil)
+	schedules := make(map[uint64]*DecommissionSchedule)
+	c.Assert(storage.LoadDecommissionSchedules(func(storeID uint64, schedule *DecommissionSchedule) { schedules[storeID] = schedule }), IsNil)
+	c.Assert(schedules, DeepEquals, map[uint64]*DecommissionSchedule{1: {Start: "00:00", End: "06:00", InitialRegionCount: 10}})
+}
+
+func (s *testKVSuite) TestSaveBatch(c *C) {
+	storage := NewStorage(kv.NewMemoryKV())
+	c.Assert(storage.SaveBatch(map[string]string{"a": "1", "b": "2"}), IsNil)
+	v, err := storage.Load("b")
+	c.Assert(err, IsNil)
+	c.Assert(v, Equals, "2")
+}
+
+func (s *testKVSuite) TestCheckerPaused(c *C) {
+	storage := NewStorage(kv.NewMemoryKV())
+	c.Assert(storage.SaveCheckerPaused("merge", true), IsNil)
+	c.Assert(storage.SaveCheckerPaused("replica", false), IsNil)
+	paused := make(map[string]bool)
+	c.Assert(storage.LoadCheckerPaused(func(name string, p bool) { paused[name] = p }), IsNil)
+	c.Assert(paused, DeepEquals, map[string]bool{"merge": true, "replica": false})
+}
+
 func (s *testKVSuite) TestLoadGCSafePoint(c *C) {
 	storage := NewStorage(kv.NewMemoryKV())
 	testData := []uint64{0, 1, 2, 233, 2333, 23333333333, math.MaxUint64}
@@ -194,3 +322,71 @@ func newTestRegionMeta(regionID uint64) *metapb.Region {
 		EndKey:   []byte(fmt.Sprintf("%20d", regionID+1)),
 	}
 }
+
+func (s *testKVSuite) TestAnalyzeUsage(c *C) {
+	storage := NewStorage(kv.NewMemoryKV())
+	mustSaveStores(c, storage, 3)
+	mustSaveRegions(c, storage, 150)
+	c.Assert(storage.SaveConfig(map[string]string{"k": "v"}), IsNil)
+	c.Assert(storage.SaveGCSafePoint(1), IsNil)
+	c.Assert(storage.Save("custom/key", "value"), IsNil)
+
+	usages, err := storage.AnalyzeUsage()
+	c.Assert(err, IsNil)
+	keys := make(map[string]int)
+	for _, usage := range usages {
+		keys[usage.Family] = usage.Keys
+		if usage.Keys > 0 {
+			c.Assert(usage.Bytes > 0, IsTrue)
+		}
+	}
+	c.Assert(keys, DeepEquals, map[string]int{
+		RegionKeyFamily: 150,
+		StoreKeyFamily:  3,
+		ConfigKeyFamily: 1,
+		GCKeyFamily:     1,
+		CustomKeyFamily: 1,
+	})
+}
+
+func (s *testKVSuite) TestMigrateRegionsToRegionStorage(c *C) {
+	storage := NewStorage(kv.NewMemoryKV())
+	_, err := storage.MigrateRegionsToRegionStorage()
+	c.Assert(err, NotNil)
+
+	dir, err := ioutil.TempDir("", "region_storage")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+	regionStorage, err := NewRegionStorage(dir)
+	c.Assert(err, IsNil)
+	storage.SetRegionStorage(regionStorage)
+
+	n := 250
+	regions := mustSaveRegions(c, storage, n)
+	// The region in the region storage is newer than the one in etcd.
+	newer := newTestRegionMeta(1)
+	newer.RegionEpoch = &metapb.RegionEpoch{Version: 10}
+	c.Assert(regionStorage.SaveRegion(newer), IsNil)
+	c.Assert(regionStorage.FlushRegion(), IsNil)
+
+	count, err := storage.MigrateRegionsToRegionStorage()
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, n)
+	usages, err := storage.AnalyzeUsage()
+	c.Assert(err, IsNil)
+	c.Assert(usages[0].Family, Equals, RegionKeyFamily)
+	c.Assert(usages[0].Keys, Equals, 0)
+
+	storage.SwitchToRegionStorage()
+	cache := NewRegionsInfo()
+	c.Assert(storage.LoadRegions(cache.SetRegion), IsNil)
+	c.Assert(cache.GetRegionCount(), Equals, n)
+	for _, region := range cache.GetMetaRegions() {
+		if region.GetId() == newer.GetId() {
+			c.Assert(region, DeepEquals, newer)
+		} else {
+			c.Assert(region, DeepEquals, regions[region.GetId()])
+		}
+	}
+	c.Assert(storage.Close(), IsNil)
+}
@@ -0,0 +1,168 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"math"
+	"path"
+	"strings"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pkg/errors"
+)
+
+// The families of the keys saved by PD.
+const (
+	RegionKeyFamily = "regions"
+	StoreKeyFamily  = "stores"
+	ConfigKeyFamily = "config"
+	GCKeyFamily     = "gc"
+	CustomKeyFamily = "custom"
+)
+
+var keyFamilyPrefixes = []struct {
+	family string
+	prefix string
+}{
+	{RegionKeyFamily, path.Join(clusterPath, "r") + "/"},
+	{StoreKeyFamily, path.Join(clusterPath, "s") + "/"},
+	{StoreKeyFamily, path.Join(schedulePath, "store_weight") + "/"},
+	{StoreKeyFamily, evacuatePath + "/"},
+	{StoreKeyFamily, blockedStorePath + "/"},
+	{StoreKeyFamily, decommissionPath + "/"},
+	{StoreKeyFamily, storeCredentialPath + "/"},
+	{ConfigKeyFamily, configPath},
+	{ConfigKeyFamily, checkerPath + "/"},
+	{ConfigKeyFamily, rangeLabelPath + "/"},
+	{ConfigKeyFamily, nsMigrationPath + "/"},
+	{ConfigKeyFamily, classifierPath},
+	{GCKeyFamily, gcPath + "/"},
+}
+
+// KeyFamilyUsage is the usage of the keys of a family in the storage.
+type KeyFamilyUsage struct {
+	Family string `json:"family"`
+	Keys   int    `json:"keys"`
+	Bytes  int64  `json:"bytes"`
+}
+
+func keyFamily(key string) string {
+	for _, p := range keyFamilyPrefixes {
+		if key == p.prefix || (strings.HasSuffix(p.prefix, "/") && strings.HasPrefix(key, p.prefix)) {
+			return p.family
+		}
+	}
+	return CustomKeyFamily
+}
+
+// AnalyzeUsage breaks down the usage of the default storage by the key
+// families. The regions saved in the region storage are not counted.
+func (s *Storage) AnalyzeUsage() ([]*KeyFamilyUsage, error) {
+	usages := make(map[string]*KeyFamilyUsage)
+	for _, family := range []string{RegionKeyFamily, StoreKeyFamily, ConfigKeyFamily, GCKeyFamily, CustomKeyFamily} {
+		usages[family] = &KeyFamilyUsage{Family: family}
+	}
+
+	err := s.scanDefaultStorage(func(key, value string) error {
+		usage := usages[keyFamily(key)]
+		usage.Keys++
+		usage.Bytes += int64(len(key) + len(value))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []*KeyFamilyUsage{
+		usages[RegionKeyFamily],
+		usages[StoreKeyFamily],
+		usages[ConfigKeyFamily],
+		usages[GCKeyFamily],
+		usages[CustomKeyFamily],
+	}, nil
+}
+
+// scanDefaultStorage calls f for all keys in the default storage. The keys
+// are loaded in small batches since the values of regions may be large.
+func (s *Storage) scanDefaultStorage(f func(key, value string) error) error {
+	nextKey := ""
+	// All keys are printable, so they are smaller than "\xff".
+	endKey := "\xff"
+	for {
+		keys, values, err := s.Base.LoadRange(nextKey, endKey, minKVRangeLimit)
+		if err != nil {
+			return err
+		}
+		for i := range keys {
+			if err := f(keys[i], values[i]); err != nil {
+				return err
+			}
+		}
+		if len(keys) < minKVRangeLimit {
+			return nil
+		}
+		nextKey = keys[len(keys)-1] + "\x00"
+	}
+}
+
+// MigrateRegionsToRegionStorage moves the regions saved in the default
+// storage to the region storage, to reduce the usage of the default storage.
+// The region in the region storage is kept if it exists, since it is newer
+// than the one left in the default storage. It returns the number of the
+// regions removed from the default storage.
+func (s *Storage) MigrateRegionsToRegionStorage() (int, error) {
+	if s.regionStorage == nil {
+		return 0, errors.New("region storage is not enabled")
+	}
+	var count int
+	nextID := uint64(0)
+	endKey := regionPath(math.MaxUint64)
+	for {
+		_, values, err := s.Base.LoadRange(regionPath(nextID), endKey, minKVRangeLimit)
+		if err != nil {
+			return count, err
+		}
+		regions := make(map[string]*metapb.Region, len(values))
+		ids := make([]uint64, 0, len(values))
+		for _, value := range values {
+			region := &metapb.Region{}
+			if err := region.Unmarshal([]byte(value)); err != nil {
+				return count, errors.WithStack(err)
+			}
+			nextID = region.GetId() + 1
+			ids = append(ids, region.GetId())
+			key := regionPath(region.GetId())
+			// Load of the leveldb backend fails on missing keys, so the
+			// existence is checked by a range.
+			if keys, _, err := s.regionStorage.LoadRange(key, key+"\x00", 1); err != nil {
+				return count, err
+			} else if len(keys) == 0 {
+				regions[key] = region
+			}
+		}
+		if len(regions) > 0 {
+			if err := s.regionStorage.SaveRegions(regions); err != nil {
+				return count, err
+			}
+		}
+		for _, id := range ids {
+			if err := s.Base.Remove(regionPath(id)); err != nil {
+				return count, err
+			}
+			count++
+		}
+		if len(values) < minKVRangeLimit {
+			return count, nil
+		}
+	}
+}
@@ -41,15 +41,39 @@ type StoreInfo struct {
 	leaderWeight     float64
 	regionWeight     float64
 	overloaded       func() bool
+	// memoryTotal and memoryUsed are the memory of the store reported in the
+	// gRPC metadata of store heartbeats, since the stats have no field for it.
+	memoryTotal uint64
+	memoryUsed  uint64
+	// memoryPressureSince is when the memory usage of the store exceeds the
+	// pressure ratio, or zero if it does not.
+	memoryPressureSince time.Time
+	// capabilities is the operator steps the store advertises to support in
+	// the gRPC metadata of its region heartbeat stream, in order.
+	capabilities []string
+	// benchmark is the benchmark scores the store reports when it registers,
+	// or nil if it reports none. benchmarkWeight scales the leader and the
+	// region weights by the scores relative to the other stores, which is 1
+	// unless the benchmark scoring mode is enabled.
+	benchmark       *StoreBenchmark
+	benchmarkWeight float64
+}
+
+// StoreBenchmark is the synthetic benchmark scores of the disk of a store.
+type StoreBenchmark struct {
+	RandReadIOPS uint64 `json:"rand_read_iops,omitempty"`
+	// SeqThroughput is the sequential throughput in bytes per second.
+	SeqThroughput uint64 `json:"seq_throughput,omitempty"`
 }
 
 // NewStoreInfo creates StoreInfo with meta data.
 func NewStoreInfo(store *metapb.Store, opts ...StoreCreateOption) *StoreInfo {
 	storeInfo := &StoreInfo{
-		meta:         store,
-		stats:        &pdpb.StoreStats{},
-		leaderWeight: 1.0,
-		regionWeight: 1.0,
+		meta:            store,
+		stats:           &pdpb.StoreStats{},
+		leaderWeight:    1.0,
+		regionWeight:    1.0,
+		benchmarkWeight: 1.0,
 	}
 	for _, opt := range opts {
 		opt(storeInfo)
@@ -60,18 +84,24 @@ func NewStoreInfo(store *metapb.Store, opts ...StoreCreateOption) *StoreInfo {
 // Clone creates a copy of current StoreInfo.
 func (s *StoreInfo) Clone(opts ...StoreCreateOption) *StoreInfo {
 	store := &StoreInfo{
-		meta:             s.meta,
-		stats:            s.stats,
-		blocked:          s.blocked,
-		leaderCount:      s.leaderCount,
-		regionCount:      s.regionCount,
-		leaderSize:       s.leaderSize,
-		regionSize:       s.regionSize,
-		pendingPeerCount: s.pendingPeerCount,
-		lastHeartbeatTS:  s.lastHeartbeatTS,
-		leaderWeight:     s.leaderWeight,
-		regionWeight:     s.regionWeight,
-		overloaded:       s.overloaded,
+		meta:                s.meta,
+		stats:               s.stats,
+		blocked:             s.blocked,
+		leaderCount:         s.leaderCount,
+		regionCount:         s.regionCount,
+		leaderSize:          s.leaderSize,
+		regionSize:          s.regionSize,
+		pendingPeerCount:    s.pendingPeerCount,
+		lastHeartbeatTS:     s.lastHeartbeatTS,
+		leaderWeight:        s.leaderWeight,
+		regionWeight:        s.regionWeight,
+		overloaded:          s.overloaded,
+		memoryTotal:         s.memoryTotal,
+		memoryUsed:          s.memoryUsed,
+		memoryPressureSince: s.memoryPressureSince,
+		capabilities:        s.capabilities,
+		benchmark:           s.benchmark,
+		benchmarkWeight:     s.benchmarkWeight,
 	}
 
 	for _, opt := range opts {
@@ -85,6 +115,32 @@ func (s *StoreInfo) IsBlocked() bool {
 	return s.blocked
 }
 
+// GetCapabilities returns the capabilities the store advertises.
+func (s *StoreInfo) GetCapabilities() []string {
+	return s.capabilities
+}
+
+// HasCapability checks if the store advertises the capability.
+func (s *StoreInfo) HasCapability(capability string) bool {
+	for _, c := range s.capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// GetBenchmark returns the benchmark scores the store reports, or nil if it
+// reports none.
+func (s *StoreInfo) GetBenchmark() *StoreBenchmark {
+	return s.benchmark
+}
+
+// GetBenchmarkWeight returns the weight of the store by its benchmark scores.
+func (s *StoreInfo) GetBenchmarkWeight() float64 {
+	return s.benchmarkWeight
+}
+
 // IsOverloaded returns if the store is overloaded.
 func (s *StoreInfo) IsOverloaded() bool {
 	if s.overloaded == nil {
@@ -173,6 +229,18 @@ func (s *StoreInfo) GetBytesRead() uint64 {
 	return s.stats.GetBytesRead()
 }
 
+// GetBytesReadRate returns the bytes read per second for the store during
+// this period.
+func (s *StoreInfo) GetBytesReadRate() float64 {
+	return flowRate(s.stats.GetBytesRead(), s.stats.GetInterval())
+}
+
+// GetBytesWriteRate returns the bytes written per second for the store during
+// this period.
+func (s *StoreInfo) GetBytesWriteRate() float64 {
+	return flowRate(s.stats.GetBytesWritten(), s.stats.GetInterval())
+}
+
 // GetKeysWritten returns the keys written for the store during this period.
 func (s *StoreInfo) GetKeysWritten() uint64 {
 	return s.stats.GetKeysWritten()
@@ -251,9 +319,20 @@ func (s *StoreInfo) GetLastHeartbeatTS() time.Time {
 const minWeight = 1e-6
 const maxScore = 1024 * 1024 * 1024
 
+// scoreLeaderWeight and scoreRegionWeight are the weights the scores are
+// divided by, which are the weights set by users scaled by the benchmark
+// weight.
+func (s *StoreInfo) scoreLeaderWeight() float64 {
+	return math.Max(s.GetLeaderWeight()*s.GetBenchmarkWeight(), minWeight)
+}
+
+func (s *StoreInfo) scoreRegionWeight() float64 {
+	return math.Max(s.GetRegionWeight()*s.GetBenchmarkWeight(), minWeight)
+}
+
 // LeaderScore returns the store's leader score: leaderSize / leaderWeight.
 func (s *StoreInfo) LeaderScore(delta int64) float64 {
-	return float64(s.GetLeaderSize()+delta) / math.Max(s.GetLeaderWeight(), minWeight)
+	return float64(s.GetLeaderSize()+delta) / s.scoreLeaderWeight()
 }
 
 // RegionScore returns the store's region score.
@@ -299,7 +378,7 @@ func (s *StoreInfo) RegionScore(highSpaceRatio, lowSpaceRatio float64, delta int
 		score = k*float64(s.GetRegionSize()+delta) + b
 	}
 
-	return score / math.Max(s.GetRegionWeight(), minWeight)
+	return score / s.scoreRegionWeight()
 }
 
 // StorageSize returns store's used storage size reported from tikv.
@@ -320,6 +399,48 @@ func (s *StoreInfo) IsLowSpace(lowSpaceRatio float64) bool {
 	return s.GetStoreStats() != nil && s.AvailableRatio() < 1-lowSpaceRatio
 }
 
+// MemoryPressureSustainTime is how long the memory pressure of a store lasts
+// before no more peers are added to it.
+const MemoryPressureSustainTime = 3 * time.Minute
+
+// GetMemoryTotal returns the total memory of the store, or 0 if it is not
+// reported.
+func (s *StoreInfo) GetMemoryTotal() uint64 {
+	return s.memoryTotal
+}
+
+// GetMemoryUsed returns the used memory of the store.
+func (s *StoreInfo) GetMemoryUsed() uint64 {
+	return s.memoryUsed
+}
+
+// MemoryUsageRatio is store's usedMemory/totalMemory.
+func (s *StoreInfo) MemoryUsageRatio() float64 {
+	if s.memoryTotal == 0 {
+		return 0
+	}
+	return float64(s.memoryUsed) / float64(s.memoryTotal)
+}
+
+// GetMemoryPressureSince returns when the memory pressure of the store starts,
+// or zero if the store is not under memory pressure.
+func (s *StoreInfo) GetMemoryPressureSince() time.Time {
+	return s.memoryPressureSince
+}
+
+// IsMemoryPressured checks if the memory usage of the store exceeds the ratio.
+// The store is never pressured if the ratio is 0.
+func (s *StoreInfo) IsMemoryPressured(pressureRatio float64) bool {
+	return pressureRatio > 0 && s.memoryTotal > 0 && s.MemoryUsageRatio() >= pressureRatio
+}
+
+// IsMemoryPressureSustained checks if the store has been under memory pressure
+// for MemoryPressureSustainTime.
+func (s *StoreInfo) IsMemoryPressureSustained(pressureRatio float64) bool {
+	return s.IsMemoryPressured(pressureRatio) && !s.memoryPressureSince.IsZero() &&
+		time.Since(s.memoryPressureSince) >= MemoryPressureSustainTime
+}
+
 // ResourceCount returns count of leader/region in the store.
 func (s *StoreInfo) ResourceCount(kind ResourceKind) uint64 {
 	switch kind {
@@ -360,17 +481,9 @@ func (s *StoreInfo) ResourceScore(kind ResourceKind, highSpaceRatio, lowSpaceRat
 func (s *StoreInfo) ResourceWeight(kind ResourceKind) float64 {
 	switch kind {
 	case LeaderKind:
-		leaderWeight := s.GetLeaderWeight()
-		if leaderWeight <= 0 {
-			return minWeight
-		}
-		return leaderWeight
+		return s.scoreLeaderWeight()
 	case RegionKind:
-		regionWeight := s.GetRegionWeight()
-		if regionWeight <= 0 {
-			return minWeight
-		}
-		return regionWeight
+		return s.scoreRegionWeight()
 	default:
 		return 0
 	}
@@ -420,6 +533,112 @@ func (s *StoreInfo) GetLabelValue(key string) string {
 	return ""
 }
 
+// StoreRoleLabelKey and StoreRoleLearnerOnly are the reserved label to mark
+// the stores which only hold learners, such as the stores of analytical
+// replicas. They never receive voters or leaders.
+const (
+	StoreRoleLabelKey    = "role"
+	StoreRoleLearnerOnly = "learner-only"
+)
+
+// IsLearnerOnly checks if the store only holds learners.
+func (s *StoreInfo) IsLearnerOnly() bool {
+	return strings.EqualFold(s.GetLabelValue(StoreRoleLabelKey), StoreRoleLearnerOnly)
+}
+
+// StoreType is the type of a store, which decides the tier that the store
+// belongs to.
+type StoreType string
+
+const (
+	// StoreTypePerformance is the type of stores for latency-sensitive data.
+	StoreTypePerformance StoreType = "performance"
+	// StoreTypeStorage is the type of stores with large capacity and slow
+	// disks, which are used for cold data.
+	StoreTypeStorage StoreType = "storage"
+)
+
+// StoreTypeLabelKey is the label key to specify the type of a store, since
+// metapb.Store has no field for it.
+const StoreTypeLabelKey = "store-type"
+
+// GetStoreType returns the type of the store. Stores without the label are
+// performance stores.
+func (s *StoreInfo) GetStoreType() StoreType {
+	if strings.EqualFold(s.GetLabelValue(StoreTypeLabelKey), string(StoreTypeStorage)) {
+		return StoreTypeStorage
+	}
+	return StoreTypePerformance
+}
+
+// IsStorageType checks if the store is a storage store.
+func (s *StoreInfo) IsStorageType() bool {
+	return s.GetStoreType() == StoreTypeStorage
+}
+
+// IsMixedTier checks if the stores are of different types.
+func IsMixedTier(stores []*StoreInfo) bool {
+	for _, store := range stores {
+		if store.GetStoreType() != stores[0].GetStoreType() {
+			return true
+		}
+	}
+	return false
+}
+
+// flowScoreDuration is the duration of the flow of a performance store which
+// is counted as region size in its score.
+const flowScoreDuration = 10 * time.Minute
+
+// PerformanceScore returns the region score of the store in the performance
+// tier. Besides the region size, the flow served by the store is counted,
+// since the performance stores are bounded by the flow rather than the space.
+func (s *StoreInfo) PerformanceScore(highSpaceRatio, lowSpaceRatio float64, delta int64) float64 {
+	flow := (s.GetBytesReadRate() + s.GetBytesWriteRate()) / (1 << 20) * flowScoreDuration.Seconds()
+	return s.RegionScore(highSpaceRatio, lowSpaceRatio, delta) + flow/s.scoreRegionWeight()
+}
+
+// StorageScore returns the region score of the store in the storage tier,
+// which is the region size per GiB of the capacity, so that the stores hold
+// data in proportion to their capacities. The stores running out of space
+// have the highest scores like RegionScore.
+func (s *StoreInfo) StorageScore(highSpaceRatio, lowSpaceRatio float64, delta int64) float64 {
+	weight := s.scoreRegionWeight()
+	if s.GetCapacity() == 0 {
+		return float64(s.GetRegionSize()+delta) / weight
+	}
+	available := float64(s.GetAvailable()) / (1 << 20)
+	used := float64(s.GetUsedSize()) / (1 << 20)
+	amplification := float64(1)
+	if s.GetRegionSize() != 0 && used > 0 {
+		amplification = float64(s.GetRegionSize()) / used
+	}
+	if lowSpaceBound := (1 - lowSpaceRatio) * float64(s.GetCapacity()) / (1 << 20); available-float64(delta)/amplification <= lowSpaceBound {
+		return (maxScore - (available - float64(delta)/amplification)) / weight
+	}
+	capacity := math.Max(float64(s.GetCapacity())/(1<<30), 1)
+	return float64(s.GetRegionSize()+delta) / capacity / weight
+}
+
+// TierRegionScore returns the region score of the store by the scoring
+// function of its tier. The scores are only comparable between the stores of
+// the same type.
+func (s *StoreInfo) TierRegionScore(highSpaceRatio, lowSpaceRatio float64, delta int64) float64 {
+	if s.IsStorageType() {
+		return s.StorageScore(highSpaceRatio, lowSpaceRatio, delta)
+	}
+	return s.PerformanceScore(highSpaceRatio, lowSpaceRatio, delta)
+}
+
+// TierResourceScore returns score of leader/region in the store by the
+// scoring function of its tier. The leader score is the same for all tiers.
+func (s *StoreInfo) TierResourceScore(kind ResourceKind, highSpaceRatio, lowSpaceRatio float64, delta int64) float64 {
+	if kind == RegionKind {
+		return s.TierRegionScore(highSpaceRatio, lowSpaceRatio, delta)
+	}
+	return s.ResourceScore(kind, highSpaceRatio, lowSpaceRatio, delta)
+}
+
 // CompareLocation compares 2 stores' labels and returns at which level their
 // locations are different. It returns -1 if they are at the same location.
 func (s *StoreInfo) CompareLocation(other *StoreInfo, labels []string) int {
@@ -468,28 +687,30 @@ L:
 	return storeLabels
 }
 
-type storeNotFoundErr struct {
-	storeID uint64
-}
+type storeNotFoundErr StoreErr
 
 func (e storeNotFoundErr) Error() string {
-	return fmt.Sprintf("store %v not found", e.storeID)
+	return fmt.Sprintf("store %v not found", e.StoreID)
 }
 
 // NewStoreNotFoundErr is for log of store not found
 func NewStoreNotFoundErr(storeID uint64) errcode.ErrorCode {
-	return errcode.NewNotFoundErr(storeNotFoundErr{storeID})
+	return errcode.NewCodedError(storeNotFoundErr{StoreID: storeID}, StoreNotFoundCode)
 }
 
 // StoresInfo contains information about all stores.
 type StoresInfo struct {
 	stores map[uint64]*StoreInfo
+	// storeTypes counts the stores of each type, so that checking if the
+	// stores are of different types does not scan all stores.
+	storeTypes map[StoreType]int
 }
 
 // NewStoresInfo create a StoresInfo with map of storeID to StoreInfo
 func NewStoresInfo() *StoresInfo {
 	return &StoresInfo{
-		stores: make(map[uint64]*StoreInfo),
+		stores:     make(map[uint64]*StoreInfo),
+		storeTypes: make(map[StoreType]int),
 	}
 }
 
@@ -513,7 +734,23 @@ func (s *StoresInfo) TakeStore(storeID uint64) *StoreInfo {
 
 // SetStore sets a StoreInfo with storeID.
 func (s *StoresInfo) SetStore(store *StoreInfo) {
+	if old, ok := s.stores[store.GetID()]; ok {
+		s.removeStoreType(old)
+	}
 	s.stores[store.GetID()] = store
+	s.storeTypes[store.GetStoreType()]++
+}
+
+func (s *StoresInfo) removeStoreType(store *StoreInfo) {
+	typ := store.GetStoreType()
+	if s.storeTypes[typ]--; s.storeTypes[typ] <= 0 {
+		delete(s.storeTypes, typ)
+	}
+}
+
+// IsMixedTier checks if the stores are of different types.
+func (s *StoresInfo) IsMixedTier() bool {
+	return len(s.storeTypes) > 1
 }
 
 // BlockStore blocks a StoreInfo with storeID.
@@ -567,7 +804,10 @@ func (s *StoresInfo) GetMetaStores() []*metapb.Store {
 
 // DeleteStore deletes tombstone record form store
 func (s *StoresInfo) DeleteStore(store *StoreInfo) {
-	delete(s.stores, store.GetID())
+	if old, ok := s.stores[store.GetID()]; ok {
+		s.removeStoreType(old)
+		delete(s.stores, store.GetID())
+	}
 }
 
 // GetStoreCount returns the total count of storeInfo.
@@ -135,3 +135,39 @@ func SetOverloadStatus(f func() bool) StoreCreateOption {
 		store.overloaded = f
 	}
 }
+
+// SetMemoryUsage sets the total and the used memory of the store.
+func SetMemoryUsage(total, used uint64) StoreCreateOption {
+	return func(store *StoreInfo) {
+		store.memoryTotal = total
+		store.memoryUsed = used
+	}
+}
+
+// SetStoreCapabilities sets the capabilities the store advertises.
+func SetStoreCapabilities(capabilities []string) StoreCreateOption {
+	return func(store *StoreInfo) {
+		store.capabilities = capabilities
+	}
+}
+
+// SetStoreBenchmark sets the benchmark scores the store reports.
+func SetStoreBenchmark(benchmark *StoreBenchmark) StoreCreateOption {
+	return func(store *StoreInfo) {
+		store.benchmark = benchmark
+	}
+}
+
+// SetBenchmarkWeight sets the weight of the store by its benchmark scores.
+func SetBenchmarkWeight(weight float64) StoreCreateOption {
+	return func(store *StoreInfo) {
+		store.benchmarkWeight = weight
+	}
+}
+
+// SetMemoryPressureSince sets when the memory pressure of the store starts.
+func SetMemoryPressureSince(t time.Time) StoreCreateOption {
+	return func(store *StoreInfo) {
+		store.memoryPressureSince = t
+	}
+}
@@ -15,6 +15,8 @@ package core
 
 import (
 	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
 )
 
 var _ = Suite(&testDistinctScoreSuite{})
@@ -54,3 +56,71 @@ func (s *testDistinctScoreSuite) TestDistinctScore(c *C) {
 	store := NewStoreInfoWithLabel(100, 1, nil)
 	c.Assert(DistinctScore(labels, stores, store), Equals, float64(0))
 }
+
+var _ = Suite(&testTierScoreSuite{})
+
+type testTierScoreSuite struct{}
+
+func newTierStore(id uint64, typ StoreType, capacityGB, regionSizeGB uint64, bytesWritten uint64) *StoreInfo {
+	stats := &pdpb.StoreStats{
+		Capacity:     capacityGB << 30,
+		UsedSize:     regionSizeGB << 30,
+		Available:    (capacityGB - regionSizeGB) << 30,
+		BytesWritten: bytesWritten,
+		Interval:     &pdpb.TimeInterval{StartTimestamp: 0, EndTimestamp: 10},
+	}
+	return NewStoreInfo(
+		&metapb.Store{
+			Id:     id,
+			Labels: []*metapb.StoreLabel{{Key: StoreTypeLabelKey, Value: string(typ)}},
+		},
+		SetStoreStats(stats),
+		SetRegionSize(int64(regionSizeGB<<10)),
+	)
+}
+
+func (s *testTierScoreSuite) TestStorageScore(c *C) {
+	small := newTierStore(1, StoreTypeStorage, 1000, 100, 0)
+	large := newTierStore(2, StoreTypeStorage, 2000, 150, 0)
+	// The region score prefers the store holding less data, while the storage
+	// score prefers the store with more free capacity in proportion.
+	c.Assert(small.RegionScore(0.6, 0.8, 0) < large.RegionScore(0.6, 0.8, 0), IsTrue)
+	c.Assert(small.StorageScore(0.6, 0.8, 0) > large.StorageScore(0.6, 0.8, 0), IsTrue)
+	c.Assert(small.TierRegionScore(0.6, 0.8, 0), Equals, small.StorageScore(0.6, 0.8, 0))
+
+	// The store running out of space has the highest score.
+	full := newTierStore(3, StoreTypeStorage, 1000, 900, 0)
+	c.Assert(full.StorageScore(0.6, 0.8, 0) > small.StorageScore(0.6, 0.8, 0)*1000, IsTrue)
+}
+
+func (s *testTierScoreSuite) TestPerformanceScore(c *C) {
+	idle := newTierStore(1, StoreTypePerformance, 1000, 100, 0)
+	busy := newTierStore(2, StoreTypePerformance, 1000, 100, 100<<20*10)
+	c.Assert(idle.PerformanceScore(0.6, 0.8, 0), Equals, idle.RegionScore(0.6, 0.8, 0))
+	c.Assert(busy.PerformanceScore(0.6, 0.8, 0) > idle.PerformanceScore(0.6, 0.8, 0), IsTrue)
+	c.Assert(busy.TierResourceScore(RegionKind, 0.6, 0.8, 0), Equals, busy.PerformanceScore(0.6, 0.8, 0))
+	c.Assert(busy.TierResourceScore(LeaderKind, 0.6, 0.8, 0), Equals, busy.LeaderScore(0))
+}
+
+func (s *testTierScoreSuite) TestIsMixedTier(c *C) {
+	performance := newTierStore(1, StoreTypePerformance, 1000, 100, 0)
+	storage := newTierStore(2, StoreTypeStorage, 1000, 100, 0)
+	c.Assert(IsMixedTier(nil), IsFalse)
+	c.Assert(IsMixedTier([]*StoreInfo{performance, performance}), IsFalse)
+	c.Assert(IsMixedTier([]*StoreInfo{storage, performance}), IsTrue)
+
+	stores := NewStoresInfo()
+	c.Assert(stores.IsMixedTier(), IsFalse)
+	stores.SetStore(performance)
+	c.Assert(stores.IsMixedTier(), IsFalse)
+	stores.SetStore(storage)
+	c.Assert(stores.IsMixedTier(), IsTrue)
+	// The type of a store changes with its labels.
+	stores.SetStore(newTierStore(2, StoreTypePerformance, 1000, 100, 0))
+	c.Assert(stores.IsMixedTier(), IsFalse)
+	storage = newTierStore(3, StoreTypeStorage, 1000, 100, 0)
+	stores.SetStore(storage)
+	c.Assert(stores.IsMixedTier(), IsTrue)
+	stores.DeleteStore(storage)
+	c.Assert(stores.IsMixedTier(), IsFalse)
+}
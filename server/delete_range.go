@@ -0,0 +1,305 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// DeleteRangeTask is a job to delete the keys in a range. It is split into the
+// pieces in the regions, which are deleted by the executors, such as the
+// agents on the stores, and reported back. The region heartbeat responses
+// have no command to delete a range, so the executors pull the pieces led by
+// their stores instead of being pushed. The keys are hex encoded.
+type DeleteRangeTask struct {
+	ID         uint64     `json:"id"`
+	StartKey   string     `json:"start_key"`
+	EndKey     string     `json:"end_key"`
+	CreateTime time.Time  `json:"create_time"`
+	FinishTime *time.Time `json:"finish_time,omitempty"`
+	// Pending is the pieces not deleted yet in the order of keys.
+	Pending []*DeleteRangePiece `json:"pending"`
+	// Deleted counts the pieces reported to be deleted.
+	Deleted     int    `json:"deleted"`
+	NextPieceID uint64 `json:"next_piece_id"`
+}
+
+// DeleteRangePiece is the part of a task in a region, which should be deleted
+// by the leader of the region with the epoch. The piece is replaced by the
+// ones in the new regions if the region splits or merges before it is
+// deleted. RegionID is 0 if no region covers the range yet.
+type DeleteRangePiece struct {
+	ID          uint64              `json:"id"`
+	TaskID      uint64              `json:"task_id"`
+	RegionID    uint64              `json:"region_id"`
+	RegionEpoch *metapb.RegionEpoch `json:"region_epoch,omitempty"`
+	StoreID     uint64              `json:"store_id"`
+	StartKey    string              `json:"start_key"`
+	EndKey      string              `json:"end_key"`
+}
+
+// IsFinished returns if all pieces of the task are deleted.
+func (t *DeleteRangeTask) IsFinished() bool {
+	return t.FinishTime != nil
+}
+
+func (p *DeleteRangePiece) keys() (startKey, endKey []byte) {
+	startKey, _ = hex.DecodeString(p.StartKey)
+	endKey, _ = hex.DecodeString(p.EndKey)
+	return
+}
+
+func (t *DeleteRangeTask) clone() *DeleteRangeTask {
+	task := *t
+	task.Pending = make([]*DeleteRangePiece, len(t.Pending))
+	for i, p := range t.Pending {
+		piece := *p
+		task.Pending[i] = &piece
+	}
+	return &task
+}
+
+func (c *RaftCluster) loadDeleteRangeTasks() error {
+	return c.storage.LoadDeleteRangeTasks(func(value string) error {
+		t := &DeleteRangeTask{}
+		if err := json.Unmarshal([]byte(value), t); err != nil {
+			return errors.WithStack(err)
+		}
+		c.deleteRanges[t.ID] = t
+		return nil
+	})
+}
+
+// CreateDeleteRangeTask creates a task to delete the keys in [startKey,
+// endKey), split into the pieces in the regions.
+func (c *RaftCluster) CreateDeleteRangeTask(startKey, endKey []byte) (*DeleteRangeTask, error) {
+	if len(endKey) == 0 || bytes.Compare(startKey, endKey) >= 0 {
+		return nil, errcode.NewInvalidInputErr(errors.New("start key should be less than end key"))
+	}
+	// Allocates the ID and saves the task outside the lock, so that the
+	// heartbeats are not blocked by etcd. The task is not visible to the
+	// others until it is added to deleteRanges.
+	id, err := c.id.Alloc()
+	if err != nil {
+		return nil, err
+	}
+	t := &DeleteRangeTask{
+		ID:         id,
+		StartKey:   hex.EncodeToString(startKey),
+		EndKey:     hex.EncodeToString(endKey),
+		CreateTime: time.Now(),
+	}
+	c.RLock()
+	t.Pending = c.splitDeleteRangeLocked(t, startKey, endKey)
+	c.RUnlock()
+	if err = c.storage.SaveDeleteRangeTask(id, t); err != nil {
+		return nil, err
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.deleteRanges[id] = t
+	log.Info("delete range task created",
+		zap.Uint64("task-id", id),
+		zap.String("start-key", t.StartKey),
+		zap.String("end-key", t.EndKey),
+		zap.Int("pieces", len(t.Pending)))
+	return t.clone(), nil
+}
+
+// splitDeleteRangeLocked splits [startKey, endKey) into the pieces in the
+// regions, and the pieces of the gaps not covered by any region.
+func (c *RaftCluster) splitDeleteRangeLocked(t *DeleteRangeTask, startKey, endKey []byte) []*DeleteRangePiece {
+	var pieces []*DeleteRangePiece
+	newPiece := func(start, end []byte) *DeleteRangePiece {
+		t.NextPieceID++
+		return &DeleteRangePiece{
+			ID:       t.NextPieceID,
+			TaskID:   t.ID,
+			StartKey: hex.EncodeToString(start),
+			EndKey:   hex.EncodeToString(end),
+		}
+	}
+	next := startKey
+	for _, region := range c.core.ScanRange(startKey, endKey, 0) {
+		start, end := region.GetStartKey(), region.GetEndKey()
+		if bytes.Compare(start, next) > 0 {
+			pieces = append(pieces, newPiece(next, start))
+		} else {
+			start = next
+		}
+		if len(end) == 0 || bytes.Compare(end, endKey) > 0 {
+			end = endKey
+		}
+		piece := newPiece(start, end)
+		piece.RegionID = region.GetID()
+		piece.RegionEpoch = proto.Clone(region.GetRegionEpoch()).(*metapb.RegionEpoch)
+		piece.StoreID = region.GetLeader().GetStoreId()
+		pieces = append(pieces, piece)
+		next = end
+	}
+	if bytes.Compare(next, endKey) < 0 {
+		pieces = append(pieces, newPiece(next, endKey))
+	}
+	return pieces
+}
+
+// refreshDeleteRangeLocked returns a copy of the task whose pending pieces
+// follow the current regions. The pieces of the regions which split or merge,
+// or of the gaps, are split again, and the others are led by the current
+// leaders. The task itself is not changed, so reading it only needs the read
+// lock, and the pieces split again are numbered the same way by every read
+// until the task is saved when a piece is finished.
+func (c *RaftCluster) refreshDeleteRangeLocked(t *DeleteRangeTask) *DeleteRangeTask {
+	task := t.clone()
+	if task.IsFinished() {
+		return task
+	}
+	pending := make([]*DeleteRangePiece, 0, len(task.Pending))
+	for _, p := range task.Pending {
+		region := c.core.GetRegion(p.RegionID)
+		if region != nil && region.GetRegionEpoch().GetVersion() == p.RegionEpoch.GetVersion() {
+			p.StoreID = region.GetLeader().GetStoreId()
+			if region.GetRegionEpoch().GetConfVer() != p.RegionEpoch.GetConfVer() {
+				p.RegionEpoch = proto.Clone(region.GetRegionEpoch()).(*metapb.RegionEpoch)
+			}
+			pending = append(pending, p)
+			continue
+		}
+		startKey, endKey := p.keys()
+		if p.RegionID == 0 && len(c.core.ScanRange(startKey, endKey, 1)) == 0 {
+			// Still not covered by any region.
+			pending = append(pending, p)
+			continue
+		}
+		pending = append(pending, c.splitDeleteRangeLocked(task, startKey, endKey)...)
+	}
+	task.Pending = pending
+	return task
+}
+
+// GetDeleteRangeTasks returns the tasks sorted by id.
+func (c *RaftCluster) GetDeleteRangeTasks() []*DeleteRangeTask {
+	c.RLock()
+	defer c.RUnlock()
+	tasks := make([]*DeleteRangeTask, 0, len(c.deleteRanges))
+	for _, t := range c.deleteRanges {
+		tasks = append(tasks, c.refreshDeleteRangeLocked(t))
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	return tasks
+}
+
+// GetDeleteRangeTask returns the task, or nil if it does not exist.
+func (c *RaftCluster) GetDeleteRangeTask(id uint64) *DeleteRangeTask {
+	c.RLock()
+	defer c.RUnlock()
+	t, ok := c.deleteRanges[id]
+	if !ok {
+		return nil
+	}
+	return c.refreshDeleteRangeLocked(t)
+}
+
+// GetStoreDeleteRangePieces returns the pending pieces led by the store in the
+// order of tasks and keys.
+func (c *RaftCluster) GetStoreDeleteRangePieces(storeID uint64) []*DeleteRangePiece {
+	c.RLock()
+	defer c.RUnlock()
+	pieces := make([]*DeleteRangePiece, 0)
+	for _, t := range c.deleteRanges {
+		if t.IsFinished() {
+			continue
+		}
+		for _, p := range c.refreshDeleteRangeLocked(t).Pending {
+			if p.RegionID != 0 && p.StoreID == storeID {
+				pieces = append(pieces, p)
+			}
+		}
+	}
+	sort.SliceStable(pieces, func(i, j int) bool { return pieces[i].TaskID < pieces[j].TaskID })
+	return pieces
+}
+
+// FinishDeleteRangePiece records that the piece is deleted in the region with
+// the epoch, which should be the one the piece is assigned with. It fails if
+// the piece has been split again since the region split or merged, and the
+// executor should pull the new pieces instead.
+func (c *RaftCluster) FinishDeleteRangePiece(taskID, pieceID uint64, epoch *metapb.RegionEpoch) error {
+	c.Lock()
+	defer c.Unlock()
+	t, ok := c.deleteRanges[taskID]
+	if !ok {
+		return errcode.NewNotFoundErr(errors.Errorf("delete range task %d not found", taskID))
+	}
+	task := c.refreshDeleteRangeLocked(t)
+	index := -1
+	for i, p := range task.Pending {
+		if p.ID == pieceID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return errcode.NewNotFoundErr(errors.Errorf("piece %d of delete range task %d not found", pieceID, taskID))
+	}
+	piece := task.Pending[index]
+	if piece.RegionID == 0 || epoch.GetVersion() != piece.RegionEpoch.GetVersion() {
+		return errcode.NewInvalidInputErr(errors.Errorf("piece %d is not assigned with the region epoch %v", pieceID, epoch))
+	}
+
+	task.Pending = append(task.Pending[:index], task.Pending[index+1:]...)
+	task.Deleted++
+	if len(task.Pending) == 0 {
+		now := time.Now()
+		task.FinishTime = &now
+	}
+	if err := c.storage.SaveDeleteRangeTask(taskID, task); err != nil {
+		return err
+	}
+	c.deleteRanges[taskID] = task
+	if task.IsFinished() {
+		log.Info("delete range task finished",
+			zap.Uint64("task-id", taskID),
+			zap.Int("pieces", task.Deleted),
+			zap.Duration("cost", task.FinishTime.Sub(task.CreateTime)))
+	}
+	return nil
+}
+
+// DeleteDeleteRangeTask removes the task. The pieces of an unfinished task
+// stop being assigned, while the deleted ones are not restored.
+func (c *RaftCluster) DeleteDeleteRangeTask(id uint64) error {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.deleteRanges[id]; !ok {
+		return errcode.NewNotFoundErr(errors.Errorf("delete range task %d not found", id))
+	}
+	if err := c.storage.DeleteDeleteRangeTask(id); err != nil {
+		return err
+	}
+	delete(c.deleteRanges, id)
+	log.Info("delete range task removed", zap.Uint64("task-id", id))
+	return nil
+}
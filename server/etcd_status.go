@@ -0,0 +1,230 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/pkg/etcdutil"
+	"github.com/pkg/errors"
+	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/etcdserver"
+	"go.etcd.io/etcd/etcdserver/api/v3rpc/rpctypes"
+	"go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"go.uber.org/zap"
+)
+
+// etcdDefragmentTimeout is the timeout to defragment an etcd member, which
+// blocks the member from serving until the backend is rewritten.
+const etcdDefragmentTimeout = time.Minute
+
+// EtcdMemberStatus is the status of an etcd member.
+type EtcdMemberStatus struct {
+	Name       string   `json:"name"`
+	MemberID   uint64   `json:"member_id"`
+	ClientUrls []string `json:"client_urls"`
+	IsLeader   bool     `json:"is_leader"`
+	// DBSize is the size of the backend, and DBSizeInUse is the size in use,
+	// whose difference can be reclaimed by defragmentation.
+	DBSize      int64  `json:"db_size"`
+	DBSizeInUse int64  `json:"db_size_in_use"`
+	RaftIndex   uint64 `json:"raft_index"`
+	// RaftIndexGap is how far the raft index of the member falls behind the
+	// member with the largest one.
+	RaftIndexGap uint64 `json:"raft_index_gap"`
+	// Error is set if the status of the member is not available.
+	Error string `json:"error,omitempty"`
+}
+
+// EtcdAlarm is an alarm raised by an etcd member, such as NOSPACE once the
+// backend size exceeds the quota.
+type EtcdAlarm struct {
+	MemberID uint64 `json:"member_id"`
+	Alarm    string `json:"alarm"`
+}
+
+// EtcdStatus is the health and the backend usage of the etcd cluster embedded
+// in PD.
+type EtcdStatus struct {
+	// DBSize is the largest backend size of the members, which is checked
+	// against the quota.
+	DBSize            int64               `json:"db_size"`
+	QuotaBackendBytes int64               `json:"quota_backend_bytes"`
+	Alarms            []*EtcdAlarm        `json:"alarms"`
+	Members           []*EtcdMemberStatus `json:"members"`
+	MaxRaftIndexGap   uint64              `json:"max_raft_index_gap"`
+	// Revision is the current revision, and CompactRevision is the revision
+	// before which the history is compacted.
+	Revision                int64  `json:"revision"`
+	CompactRevision         int64  `json:"compact_revision"`
+	AutoCompactionMode      string `json:"auto_compaction_mode"`
+	AutoCompactionRetention string `json:"auto_compaction_retention"`
+}
+
+// GetEtcdStatus returns the backend sizes, the alarms, the raft index gaps of
+// the members and the compaction status of etcd.
+func (s *Server) GetEtcdStatus() (*EtcdStatus, error) {
+	client := s.GetClient()
+	members, err := GetMembers(client)
+	if err != nil {
+		return nil, err
+	}
+	status := &EtcdStatus{
+		QuotaBackendBytes:       s.etcdQuotaBackendBytes(),
+		Alarms:                  []*EtcdAlarm{},
+		AutoCompactionMode:      s.cfg.AutoCompactionMode,
+		AutoCompactionRetention: s.cfg.AutoCompactionRetention,
+	}
+	var maxRaftIndex uint64
+	for _, member := range members {
+		ms := &EtcdMemberStatus{
+			Name:       member.GetName(),
+			MemberID:   member.GetMemberId(),
+			ClientUrls: member.GetClientUrls(),
+		}
+		status.Members = append(status.Members, ms)
+		resp, err := etcdMemberStatus(client, member.GetClientUrls())
+		if err != nil {
+			ms.Error = err.Error()
+			continue
+		}
+		ms.IsLeader = resp.Leader == resp.Header.GetMemberId()
+		ms.DBSize, ms.DBSizeInUse, ms.RaftIndex = resp.DbSize, resp.DbSizeInUse, resp.RaftIndex
+		if ms.RaftIndex > maxRaftIndex {
+			maxRaftIndex = ms.RaftIndex
+		}
+		if ms.DBSize > status.DBSize {
+			status.DBSize = ms.DBSize
+		}
+		if resp.Header.GetRevision() > status.Revision {
+			status.Revision = resp.Header.GetRevision()
+		}
+	}
+	for _, ms := range status.Members {
+		if ms.Error != "" {
+			continue
+		}
+		ms.RaftIndexGap = maxRaftIndex - ms.RaftIndex
+		if ms.RaftIndexGap > status.MaxRaftIndexGap {
+			status.MaxRaftIndexGap = ms.RaftIndexGap
+		}
+	}
+	alarms, err := etcdAlarms(client)
+	if err != nil {
+		return nil, err
+	}
+	for _, alarm := range alarms {
+		status.Alarms = append(status.Alarms, &EtcdAlarm{MemberID: alarm.GetMemberID(), Alarm: alarm.GetAlarm().String()})
+	}
+	status.CompactRevision = s.member.Etcd().Server.KV().FirstRev()
+	return status, nil
+}
+
+// CompactAndDefragmentEtcd compacts the history of etcd before the current
+// revision and defragments the members one by one, the leader last, to reclaim
+// the space. The NOSPACE alarms are disarmed if the backends fit in the quota
+// afterwards, so that etcd accepts writes again. It returns the status after
+// the maintenance.
+func (s *Server) CompactAndDefragmentEtcd() (*EtcdStatus, error) {
+	client := s.GetClient()
+	rev := s.member.Etcd().Server.KV().Rev()
+	ctx, cancel := context.WithTimeout(client.Ctx(), etcdutil.DefaultRequestTimeout)
+	_, err := client.Compact(ctx, rev, clientv3.WithCompactPhysical())
+	cancel()
+	if err != nil && err != rpctypes.ErrCompacted {
+		return nil, errors.WithStack(err)
+	}
+	log.Info("etcd is compacted", zap.Int64("revision", rev))
+
+	members, err := GetMembers(client)
+	if err != nil {
+		return nil, err
+	}
+	// Defragments the followers first and the leader last, since a member
+	// stops serving while it is defragmented.
+	leaderID := s.member.GetEtcdLeader()
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].GetMemberId() != leaderID && members[j].GetMemberId() == leaderID
+	})
+	for _, member := range members {
+		if len(member.GetClientUrls()) == 0 {
+			continue
+		}
+		ctx, cancel = context.WithTimeout(client.Ctx(), etcdDefragmentTimeout)
+		_, err = client.Defragment(ctx, member.GetClientUrls()[0])
+		cancel()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to defragment etcd member %s", member.GetName())
+		}
+		log.Info("etcd member is defragmented", zap.String("name", member.GetName()), zap.Uint64("member-id", member.GetMemberId()))
+	}
+
+	status, err := s.GetEtcdStatus()
+	if err != nil || status.DBSize >= status.QuotaBackendBytes {
+		return status, err
+	}
+	alarms, err := etcdAlarms(client)
+	if err != nil {
+		return nil, err
+	}
+	for _, alarm := range alarms {
+		if alarm.GetAlarm() != etcdserverpb.AlarmType_NOSPACE {
+			continue
+		}
+		ctx, cancel = context.WithTimeout(client.Ctx(), etcdutil.DefaultRequestTimeout)
+		_, err = client.AlarmDisarm(ctx, (*clientv3.AlarmMember)(alarm))
+		cancel()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		log.Info("etcd alarm is disarmed", zap.Uint64("member-id", alarm.GetMemberID()), zap.Stringer("alarm", alarm.GetAlarm()))
+	}
+	return s.GetEtcdStatus()
+}
+
+func (s *Server) etcdQuotaBackendBytes() int64 {
+	if quota := s.etcdCfg.QuotaBackendBytes; quota > 0 {
+		return quota
+	}
+	return etcdserver.DefaultQuotaBytes
+}
+
+// etcdMemberStatus gets the status of an etcd member by the first available
+// client URL.
+func etcdMemberStatus(client *clientv3.Client, urls []string) (*clientv3.StatusResponse, error) {
+	err := errors.New("no client url")
+	for _, url := range urls {
+		ctx, cancel := context.WithTimeout(client.Ctx(), etcdutil.DefaultRequestTimeout)
+		var resp *clientv3.StatusResponse
+		resp, err = client.Status(ctx, url)
+		cancel()
+		if err == nil {
+			return resp, nil
+		}
+	}
+	return nil, errors.WithStack(err)
+}
+
+func etcdAlarms(client *clientv3.Client) ([]*etcdserverpb.AlarmMember, error) {
+	ctx, cancel := context.WithTimeout(client.Ctx(), etcdutil.DefaultRequestTimeout)
+	resp, err := client.AlarmList(ctx)
+	cancel()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return resp.Alarms, nil
+}
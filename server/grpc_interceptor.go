@@ -0,0 +1,469 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"path"
+	"time"
+
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/pkg/grpcutil"
+	"github.com/pingcap/pd/server/core"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// callerComponentKey is the gRPC metadata key for the callers to set the
+	// names of their components, such as "tikv" and "tidb", which tag the
+	// metrics of the requests.
+	callerComponentKey = "pd-caller-component"
+	// unknownCaller is the component of the callers setting no names or
+	// invalid ones.
+	unknownCaller = "unknown"
+	// maxCallerLength is the max length of the component names, which keeps
+	// the cardinality of the metrics under control with the allowed
+	// characters.
+	maxCallerLength = 32
+)
+
+// callerComponent returns the component name in the metadata of the request.
+func callerComponent(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return unknownCaller
+	}
+	values := md.Get(callerComponentKey)
+	if len(values) == 0 || len(values[0]) == 0 || len(values[0]) > maxCallerLength {
+		return unknownCaller
+	}
+	for _, c := range values[0] {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return unknownCaller
+		}
+	}
+	return values[0]
+}
+
+func methodName(fullMethod string) string {
+	return path.Base(fullMethod)
+}
+
+// recoverUnaryInterceptor turns the panics of the requests into errors, so
+// that a bug in one RPC does not kill the whole PD.
+func recoverUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recoverPanic(info.FullMethod, r)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// recoverStreamInterceptor is the same as recoverUnaryInterceptor for the
+// streams. The panics in the goroutines started by the handlers are not
+// recovered.
+func recoverStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recoverPanic(info.FullMethod, r)
+		}
+	}()
+	return handler(srv, ss)
+}
+
+func recoverPanic(fullMethod string, r interface{}) error {
+	method := methodName(fullMethod)
+	log.Error("panic in grpc request", zap.String("method", method), zap.Reflect("panic", r), zap.Stack("stack"))
+	grpcPanicCounter.WithLabelValues(method).Inc()
+	return status.Errorf(codes.Internal, "panic in %s: %v", method, r)
+}
+
+// grpcCode returns the gRPC code of the error code.
+func grpcCode(code errcode.Code) codes.Code {
+	switch {
+	case code.IsAncestor(core.NotLeaderCode):
+		return codes.Unavailable
+	case code.IsAncestor(errcode.NotFoundCode):
+		return codes.NotFound
+	case code.IsAncestor(errcode.InvalidInputCode):
+		return codes.InvalidArgument
+	case code.IsAncestor(errcode.StateCode):
+		return codes.FailedPrecondition
+	default:
+		return codes.Internal
+	}
+}
+
+// errorCodeStatus turns the error with an error code into a gRPC status, whose
+// details carry the code in the same form as the "code" field of the JSON
+// bodies of the HTTP APIs. The other errors are returned as is.
+func errorCodeStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	errCode := errcode.CodeChain(err)
+	if errCode == nil {
+		return err
+	}
+	st := status.New(grpcCode(errCode.Code()), errCode.Error())
+	return grpcutil.WithErrorCode(st, errCode.Code().CodeStr().String()).Err()
+}
+
+// errorCodeUnaryInterceptor returns the errors with error codes as gRPC
+// statuses carrying the codes.
+func errorCodeUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	return resp, errorCodeStatus(err)
+}
+
+// errorCodeStreamInterceptor is the same as errorCodeUnaryInterceptor for the
+// streams.
+func errorCodeStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return errorCodeStatus(handler(srv, ss))
+}
+
+// callerUnaryInterceptor counts the requests by the caller components.
+func callerUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	grpcRequestCounter.WithLabelValues(methodName(info.FullMethod), callerComponent(ctx)).Inc()
+	return handler(ctx, req)
+}
+
+// callerStreamInterceptor counts the streams by the caller components.
+func callerStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	grpcRequestCounter.WithLabelValues(methodName(info.FullMethod), callerComponent(ss.Context())).Inc()
+	return handler(srv, ss)
+}
+
+// newSlowLogUnaryInterceptor logs the requests taking longer than the
+// threshold into the slow log, or the main log if slowLog is nil. A threshold
+// of 0 logs nothing. Streams are not logged since they live long.
+func newSlowLogUnaryInterceptor(threshold func() time.Duration, slowLog *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		cost := time.Since(start)
+		if t := threshold(); t > 0 && cost > t {
+			method, caller := methodName(info.FullMethod), callerComponent(ctx)
+			fields := []zap.Field{zap.String("method", method), zap.String("caller", caller), zap.Duration("cost", cost)}
+			if p, ok := peer.FromContext(ctx); ok {
+				fields = append(fields, zap.Stringer("peer", p.Addr))
+			}
+			if err != nil {
+				fields = append(fields, zap.Error(err))
+			}
+			lg := slowLog
+			if lg == nil {
+				lg = log.L()
+			}
+			lg.Warn("slow grpc request", fields...)
+			grpcSlowRequestCounter.WithLabelValues(method, caller).Inc()
+		}
+		return resp, err
+	}
+}
+
+// chainUnaryInterceptors chains the interceptors, the first of which is the
+// outermost.
+func chainUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		h := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], h
+			h = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, next)
+			}
+		}
+		return h(ctx, req)
+	}
+}
+
+// chainStreamInterceptors chains the interceptors, the first of which is the
+// outermost.
+func chainStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		h := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], h
+			h = func(srv interface{}, ss grpc.ServerStream) error {
+				return interceptor(srv, ss, info, next)
+			}
+		}
+		return h(srv, ss)
+	}
+}
+
+// interceptedServer runs the requests of the PD service through the
+// interceptors. The gRPC server is created by the embedded etcd with its own
+// interceptors, so the ones of PD are applied by wrapping the service.
+type interceptedServer struct {
+	*Server
+	unary  grpc.UnaryServerInterceptor
+	stream grpc.StreamServerInterceptor
+}
+
+func newInterceptedServer(s *Server, slowLog *zap.Logger) *interceptedServer {
+	threshold := func() time.Duration {
+		return s.scheduleOpt.LoadPDServerConfig().SlowRPCThreshold.Duration
+	}
+	return &interceptedServer{
+		Server: s,
+		unary: chainUnaryInterceptors(
+			recoverUnaryInterceptor,
+			errorCodeUnaryInterceptor,
+			callerUnaryInterceptor,
+			s.quarantine.unaryInterceptor,
+			newSlowLogUnaryInterceptor(threshold, slowLog),
+		),
+		stream: chainStreamInterceptors(
+			recoverStreamInterceptor,
+			errorCodeStreamInterceptor,
+			callerStreamInterceptor,
+		),
+	}
+}
+
+func (s *interceptedServer) intercept(ctx context.Context, method string, req interface{}, handler grpc.UnaryHandler) (interface{}, error) {
+	return s.unary(ctx, req, &grpc.UnaryServerInfo{Server: s.Server, FullMethod: "/pdpb.PD/" + method}, handler)
+}
+
+func (s *interceptedServer) interceptStream(method string, ss grpc.ServerStream, handler func() error) error {
+	info := &grpc.StreamServerInfo{FullMethod: "/pdpb.PD/" + method, IsClientStream: true, IsServerStream: true}
+	return s.stream(s.Server, ss, info, func(interface{}, grpc.ServerStream) error { return handler() })
+}
+
+// GetMembers implements gRPC PDServer.
+func (s *interceptedServer) GetMembers(ctx context.Context, request *pdpb.GetMembersRequest) (*pdpb.GetMembersResponse, error) {
+	resp, err := s.intercept(ctx, "GetMembers", request, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.Server.GetMembers(ctx, request)
+	})
+	r, _ := resp.(*pdpb.GetMembersResponse)
+	return r, err
+}
+
+// Tso implements gRPC PDServer.
+func (s *interceptedServer) Tso(stream pdpb.PD_TsoServer) error {
+	return s.interceptStream("Tso", stream, func() error { return s.Server.Tso(stream) })
+}
+
+// Bootstrap implements gRPC PDServer.
+func (s *interceptedServer) Bootstrap(ctx context.Context, request *pdpb.BootstrapRequest) (*pdpb.BootstrapResponse, error) {
+	resp, err := s.intercept(ctx, "Bootstrap", request, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.Server.Bootstrap(ctx, request)
+	})
+	r, _ := resp.(*pdpb.BootstrapResponse)
+	return r, err
+}
+
+// IsBootstrapped implements gRPC PDServer.
+func (s *interceptedServer) IsBootstrapped(ctx context.Context, request *pdpb.IsBootstrappedRequest) (*pdpb.IsBootstrappedResponse, error) {
+	resp, err := s.intercept(ctx, "IsBootstrapped", request, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.Server.IsBootstrapped(ctx, request)
+	})
+	r, _ := resp.(*pdpb.IsBootstrappedResponse)
+	return r, err
+}
+
+// AllocID implements gRPC PDServer.
+func (s *interceptedServer) AllocID(ctx context.Context, request *pdpb.AllocIDRequest) (*pdpb.AllocIDResponse, error) {
+	resp, err := s.intercept(ctx, "AllocID", request, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.Server.AllocID(ctx, request)
+	})
+	r, _ := resp.(*pdpb.AllocIDResponse)
+	return r, err
+}
+
+// GetStore implements gRPC PDServer.
+func (s *interceptedServer) GetStore(ctx context.Context, request *pdpb.GetStoreRequest) (*pdpb.GetStoreResponse, error) {
+	resp, err := s.intercept(ctx, "GetStore", request, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.Server.GetStore(ctx, request)
+	})
+	r, _ := resp.(*pdpb.GetStoreResponse)
+	return r, err
+}
+
+// PutStore implements gRPC PDServer.
+func (s *interceptedServer) PutStore(ctx context.Context, request *pdpb.PutStoreRequest) (*pdpb.PutStoreResponse, error) {
+	resp, err := s.intercept(ctx, "PutStore", request, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.Server.PutStore(ctx, request)
+	})
+	r, _ := resp.(*pdpb.PutStoreResponse)
+	return r, err
+}
+
+// GetAllStores implements gRPC PDServer.
+func (s *interceptedServer) GetAllStores(ctx context.Context, request *pdpb.GetAllStoresRequest) (*pdpb.GetAllStoresResponse, error) {
+	resp, err := s.intercept(ctx, "GetAllStores", request, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.Server.GetAllStores(ctx, request)
+	})
+	r, _ := resp.(*pdpb.GetAllStoresResponse)
+	return r, err
+}
+
+// StoreHeartbeat implements gRPC PDServer.
+func (s *interceptedServer) StoreHeartbeat(ctx context.Context, request *pdpb.StoreHeartbeatRequest) (*pdpb.StoreHeartbeatResponse, error) {
+	resp, err := s.intercept(ctx, "StoreHeartbeat", request, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.Server.StoreHeartbeat(ctx, request)
+	})
+	r, _ := resp.(*pdpb.StoreHeartbeatResponse)
+	return r, err
+}
+
+// RegionHeartbeat implements gRPC PDServer.
+func (s *interceptedServer) RegionHeartbeat(stream pdpb.PD_RegionHeartbeatServer) error {
+	return s.interceptStream("RegionHeartbeat", stream, func() error {
+		return s.Server.RegionHeartbeat(quarantinedHeartbeatStream{PD_RegionHeartbeatServer: stream, q: s.quarantine})
+	})
+}
+
+// GetRegion implements gRPC PDServer.
+func (s *interceptedServer) GetRegion(ctx context.Context, request *pdpb.GetRegionRequest) (*pdpb.GetRegionResponse, error) {
+	resp, err := s.intercept(ctx, "GetRegion", request, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.Server.GetRegion(ctx, request)
+	})
+	r, _ := resp.(*pdpb.GetRegionResponse)
+	return r, err
+}
+
+// GetPrevRegion implements gRPC PDServer.
+func (s *interceptedServer) GetPrevRegion(ctx context.Context, request *pdpb.GetRegionRequest) (*pdpb.GetRegionResponse, error) {
+	resp, err := s.intercept(ctx, "GetPrevRegion", request, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.Server.GetPrevRegion(ctx, request)
+	})
+	r, _ := resp.(*pdpb.GetRegionResponse)
+	return r, err
+}
+
+// GetRegionByID implements gRPC PDServer.
+func (s *interceptedServer) GetRegionByID(ctx context.Context, request *pdpb.GetRegionByIDRequest) (*pdpb.GetRegionResponse, error) {
+	resp, err := s.intercept(ctx, "GetRegionByID", request, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.Server.GetRegionByID(ctx, request)
+	})
+	r, _ := resp.(*pdpb.GetRegionResponse)
+	return r, err
+}
+
+// ScanRegions implements gRPC PDServer.
+func (s *interceptedServer) ScanRegions(ctx context.Context, request *pdpb.ScanRegionsRequest) (*pdpb.ScanRegionsResponse, error) {
+	resp, err := s.intercept(ctx, "ScanRegions", request, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.Server.ScanRegions(ctx, request)
+	})
+	r, _ := resp.(*pdpb.ScanRegionsResponse)
+	return r, err
+}
+
+// AskSplit implements gRPC PDServer.
+func (s *interceptedServer) AskSplit(ctx context.Context, request *pdpb.AskSplitRequest) (*pdpb.AskSplitResponse, error) {
+	resp, err := s.intercept(ctx, "AskSplit", request, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.Server.AskSplit(ctx, request)
+	})
+	r, _ := resp.(*pdpb.AskSplitResponse)
+	return r, err
+}
+
+// ReportSplit implements gRPC PDServer.
+func (s *interceptedServer) ReportSplit(ctx context.Context, request *pdpb.ReportSplitRequest) (*pdpb.ReportSplitResponse, error) {
+	resp, err := s.intercept(ctx, "ReportSplit", request, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.Server.ReportSplit(ctx, request)
+	})
+	r, _ := resp.(*pdpb.ReportSplitResponse)
+	return r, err
+}
+
+// AskBatchSplit implements gRPC PDServer.
+func (s *interceptedServer) AskBatchSplit(ctx context.Context, request *pdpb.AskBatchSplitRequest) (*pdpb.AskBatchSplitResponse, error) {
+	resp, err := s.intercept(ctx, "AskBatchSplit", request, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.Server.AskBatchSplit(ctx, request)
+	})
+	r, _ := resp.(*pdpb.AskBatchSplitResponse)
+	return r, err
+}
+
+// ReportBatchSplit implements gRPC PDServer.
+func (s *interceptedServer) ReportBatchSplit(ctx context.Context, request *pdpb.ReportBatchSplitRequest) (*pdpb.ReportBatchSplitResponse, error) {
+	resp, err := s.intercept(ctx, "ReportBatchSplit", request, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.Server.ReportBatchSplit(ctx, request)
+	})
+	r, _ := resp.(*pdpb.ReportBatchSplitResponse)
+	return r, err
+}
+
+// GetClusterConfig implements gRPC PDServer.
+func (s *interceptedServer) GetClusterConfig(ctx context.Context, request *pdpb.GetClusterConfigRequest) (*pdpb.GetClusterConfigResponse, error) {
+	resp, err := s.intercept(ctx, "GetClusterConfig", request, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.Server.GetClusterConfig(ctx, request)
+	})
+	r, _ := resp.(*pdpb.GetClusterConfigResponse)
+	return r, err
+}
+
+// PutClusterConfig implements gRPC PDServer.
+func (s *interceptedServer) PutClusterConfig(ctx context.Context, request *pdpb.PutClusterConfigRequest) (*pdpb.PutClusterConfigResponse, error) {
+	resp, err := s.intercept(ctx, "PutClusterConfig", request, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.Server.PutClusterConfig(ctx, request)
+	})
+	r, _ := resp.(*pdpb.PutClusterConfigResponse)
+	return r, err
+}
+
+// ScatterRegion implements gRPC PDServer.
+func (s *interceptedServer) ScatterRegion(ctx context.Context, request *pdpb.ScatterRegionRequest) (*pdpb.ScatterRegionResponse, error) {
+	resp, err := s.intercept(ctx, "ScatterRegion", request, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.Server.ScatterRegion(ctx, request)
+	})
+	r, _ := resp.(*pdpb.ScatterRegionResponse)
+	return r, err
+}
+
+// GetGCSafePoint implements gRPC PDServer.
+func (s *interceptedServer) GetGCSafePoint(ctx context.Context, request *pdpb.GetGCSafePointRequest) (*pdpb.GetGCSafePointResponse, error) {
+	resp, err := s.intercept(ctx, "GetGCSafePoint", request, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.Server.GetGCSafePoint(ctx, request)
+	})
+	r, _ := resp.(*pdpb.GetGCSafePointResponse)
+	return r, err
+}
+
+// UpdateGCSafePoint implements gRPC PDServer.
+func (s *interceptedServer) UpdateGCSafePoint(ctx context.Context, request *pdpb.UpdateGCSafePointRequest) (*pdpb.UpdateGCSafePointResponse, error) {
+	resp, err := s.intercept(ctx, "UpdateGCSafePoint", request, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.Server.UpdateGCSafePoint(ctx, request)
+	})
+	r, _ := resp.(*pdpb.UpdateGCSafePointResponse)
+	return r, err
+}
+
+// SyncRegions implements gRPC PDServer.
+func (s *interceptedServer) SyncRegions(stream pdpb.PD_SyncRegionsServer) error {
+	return s.interceptStream("SyncRegions", stream, func() error { return s.Server.SyncRegions(stream) })
+}
+
+// GetOperator implements gRPC PDServer.
+func (s *interceptedServer) GetOperator(ctx context.Context, request *pdpb.GetOperatorRequest) (*pdpb.GetOperatorResponse, error) {
+	resp, err := s.intercept(ctx, "GetOperator", request, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.Server.GetOperator(ctx, request)
+	})
+	r, _ := resp.(*pdpb.GetOperatorResponse)
+	return r, err
+}
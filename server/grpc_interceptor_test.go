@@ -0,0 +1,150 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/pd/pkg/grpcutil"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Suite(&testGRPCInterceptorSuite{})
+
+type testGRPCInterceptorSuite struct{}
+
+func (s *testGRPCInterceptorSuite) TestCallerComponent(c *C) {
+	c.Assert(callerComponent(context.Background()), Equals, unknownCaller)
+	for caller, expect := range map[string]string{
+		"tikv":                              "tikv",
+		"pd-ctl":                            "pd-ctl",
+		"TiDB":                              unknownCaller,
+		"tidb server":                       unknownCaller,
+		"":                                  unknownCaller,
+		"a-very-long-component-name-0123":   "a-very-long-component-name-0123",
+		"a-very-long-component-name-01234":  "a-very-long-component-name-01234",
+		"a-very-long-component-name-012345": unknownCaller,
+	} {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(callerComponentKey, caller))
+		c.Assert(callerComponent(ctx), Equals, expect)
+	}
+}
+
+func (s *testGRPCInterceptorSuite) TestChain(c *C) {
+	var order []int
+	record := func(i int) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			order = append(order, i)
+			return handler(ctx, req)
+		}
+	}
+	chain := chainUnaryInterceptors(record(1), record(2), record(3))
+	resp, err := chain(context.Background(), "req", &grpc.UnaryServerInfo{FullMethod: "/pdpb.PD/GetMembers"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		order = append(order, 0)
+		return req, nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(resp, Equals, "req")
+	c.Assert(order, DeepEquals, []int{1, 2, 3, 0})
+}
+
+func (s *testGRPCInterceptorSuite) TestRecover(c *C) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/pdpb.PD/GetRegion"}
+	_, err := recoverUnaryInterceptor(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+		panic("boom")
+	})
+	c.Assert(status.Code(err), Equals, codes.Internal)
+
+	streamInfo := &grpc.StreamServerInfo{FullMethod: "/pdpb.PD/Tso"}
+	err = recoverStreamInterceptor(nil, nil, streamInfo, func(interface{}, grpc.ServerStream) error {
+		var m map[string]int
+		m["a"]++
+		return nil
+	})
+	c.Assert(status.Code(err), Equals, codes.Internal)
+}
+
+func (s *testGRPCInterceptorSuite) TestSlowLog(c *C) {
+	obs, logs := observer.New(zapcore.InfoLevel)
+	threshold := time.Duration(0)
+	interceptor := newSlowLogUnaryInterceptor(func() time.Duration { return threshold }, zap.New(obs))
+	info := &grpc.UnaryServerInfo{FullMethod: "/pdpb.PD/ScanRegions"}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(callerComponentKey, "tidb"))
+	slow := func(context.Context, interface{}) (interface{}, error) {
+		time.Sleep(10 * time.Millisecond)
+		return nil, nil
+	}
+
+	// A threshold of 0 logs nothing.
+	_, err := interceptor(ctx, nil, info, slow)
+	c.Assert(err, IsNil)
+	c.Assert(logs.Len(), Equals, 0)
+
+	threshold = time.Millisecond
+	_, err = interceptor(ctx, nil, info, slow)
+	c.Assert(err, IsNil)
+	c.Assert(logs.Len(), Equals, 1)
+	fields := logs.All()[0].ContextMap()
+	c.Assert(fields["method"], Equals, "ScanRegions")
+	c.Assert(fields["caller"], Equals, "tidb")
+
+	threshold = time.Minute
+	_, err = interceptor(ctx, nil, info, slow)
+	c.Assert(err, IsNil)
+	c.Assert(logs.Len(), Equals, 1)
+}
+
+func (s *testGRPCInterceptorSuite) TestErrorCode(c *C) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/pdpb.PD/GetStore"}
+	for _, t := range []struct {
+		err      error
+		grpcCode codes.Code
+		code     string
+	}{
+		{errors.WithStack(notLeaderError), codes.Unavailable, "state.not_leader"},
+		{ErrNotBootstrapped, codes.FailedPrecondition, "state.not_bootstrapped"},
+		{errors.WithStack(ErrAddOperator), codes.FailedPrecondition, "state.operator_conflict"},
+		{ErrStoreNotFound(1), codes.NotFound, "missing.store"},
+		{ErrRegionNotFound(1), codes.NotFound, "missing.region"},
+		{status.Errorf(codes.PermissionDenied, "blocked"), codes.PermissionDenied, ""},
+		{errors.New("unknown"), codes.Unknown, ""},
+	} {
+		_, err := errorCodeUnaryInterceptor(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+			return nil, t.err
+		})
+		c.Assert(status.Code(err), Equals, t.grpcCode)
+		c.Assert(grpcutil.ErrorCode(err), Equals, t.code)
+	}
+	_, err := errorCodeUnaryInterceptor(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	c.Assert(err, IsNil)
+
+	streamInfo := &grpc.StreamServerInfo{FullMethod: "/pdpb.PD/Tso"}
+	err = errorCodeStreamInterceptor(nil, nil, streamInfo, func(interface{}, grpc.ServerStream) error {
+		return errors.WithStack(notLeaderError)
+	})
+	c.Assert(status.Code(err), Equals, codes.Unavailable)
+	c.Assert(status.Convert(err).Message(), Equals, "not leader")
+	c.Assert(grpcutil.ErrorCode(err), Equals, "state.not_leader")
+}
@@ -21,13 +21,17 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/opentracing/opentracing-go"
+	"github.com/pingcap/errcode"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/pingcap/log"
 	"github.com/pingcap/pd/server/core"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
@@ -35,17 +39,28 @@ import (
 
 // notLeaderError is returned when current server is not the leader and not possible to process request.
 // TODO: work as proxy.
-var notLeaderError = status.Errorf(codes.Unavailable, "not leader")
+var notLeaderError = errcode.NewCodedError(errors.New("not leader"), core.NotLeaderCode)
 
 // GetMembers implements gRPC PDServer.
 func (s *Server) GetMembers(context.Context, *pdpb.GetMembersRequest) (*pdpb.GetMembersResponse, error) {
 	if s.IsClosed() {
 		return nil, status.Errorf(codes.Unknown, "server not started")
 	}
-	members, err := GetMembers(s.GetClient())
+	etcdMembers, err := GetMembers(s.GetClient())
 	if err != nil {
 		return nil, status.Errorf(codes.Unknown, err.Error())
 	}
+	// Members of other PD clusters sharing the etcd are not listed.
+	members := make([]*pdpb.Member, 0, len(etcdMembers))
+	for _, m := range etcdMembers {
+		isMember, err := s.member.IsClusterMember(m.GetMemberId())
+		if err != nil {
+			return nil, status.Errorf(codes.Unknown, err.Error())
+		}
+		if isMember {
+			members = append(members, m)
+		}
+	}
 
 	var etcdLeader *pdpb.Member
 	leadID := s.member.GetEtcdLeader()
@@ -206,6 +221,9 @@ func (s *Server) PutStore(ctx context.Context, request *pdpb.PutStoreRequest) (*
 	}
 
 	store := request.GetStore()
+	if err := cluster.authenticateStore(ctx, store.GetId()); err != nil {
+		return nil, status.Errorf(codes.PermissionDenied, err.Error())
+	}
 	if pberr := checkStore2(cluster, store.GetId()); pberr != nil {
 		return &pdpb.PutStoreResponse{
 			Header: s.errorHeader(pberr),
@@ -216,6 +234,8 @@ func (s *Server) PutStore(ctx context.Context, request *pdpb.PutStoreRequest) (*
 		return nil, status.Errorf(codes.Unknown, err.Error())
 	}
 
+	cluster.updateStoreBenchmark(store.GetId(), storeBenchmarkFromContext(ctx))
+
 	log.Info("put store ok", zap.Stringer("store", store))
 	cluster.OnStoreVersionChange()
 
@@ -262,21 +282,47 @@ func (s *Server) StoreHeartbeat(ctx context.Context, request *pdpb.StoreHeartbea
 	if request.GetStats() == nil {
 		return nil, errors.Errorf("invalid store heartbeat command, but %v", request)
 	}
+	s.hbCapture.captureStoreHeartbeat(request)
 	cluster := s.GetRaftCluster()
 	if cluster == nil {
 		return &pdpb.StoreHeartbeatResponse{Header: s.notBootstrappedHeader()}, nil
 	}
 
+	if cluster.GetStore(request.GetStats().GetStoreId()) == nil {
+		s.quarantine.observe(ctx, request.GetStats().GetStoreId(), "store")
+	}
+	if err := cluster.authenticateStore(ctx, request.GetStats().GetStoreId()); err != nil {
+		return nil, status.Errorf(codes.PermissionDenied, err.Error())
+	}
 	if pberr := checkStore2(cluster, request.GetStats().GetStoreId()); pberr != nil {
 		return &pdpb.StoreHeartbeatResponse{
 			Header: s.errorHeader(pberr),
 		}, nil
 	}
 
-	err := cluster.handleStoreHeartbeat(request.Stats)
+	err := cluster.handleStoreHeartbeat(request.Stats, storeMemoryUsageFromContext(ctx))
 	if err != nil {
 		return nil, status.Errorf(codes.Unknown, err.Error())
 	}
+	if lags, ok := storeLearnerLagsFromContext(ctx); ok {
+		cluster.learnerLags.update(request.GetStats().GetStoreId(), lags)
+	}
+	if ts, ok := storeMinResolvedTSFromContext(ctx); ok {
+		if err := cluster.SetStoreMinResolvedTS(request.GetStats().GetStoreId(), ts); err != nil {
+			log.Warn("failed to update store min resolved ts", zap.Uint64("store-id", request.GetStats().GetStoreId()), zap.Error(err))
+		}
+	}
+	if rtts, ok := storeRTTsFromContext(ctx); ok {
+		cluster.storeRTTs.update(request.GetStats().GetStoreId(), rtts)
+	}
+
+	// Stores that understand the header can adjust their heartbeat intervals.
+	pairs := cluster.GetHeartbeatIntervals().headerPairs()
+	pairs = append(pairs, cluster.snapshotBandwidthCapPairs(request.GetStats().GetStoreId())...)
+	pairs = append(pairs, cluster.minResolvedTSPairs()...)
+	if err := grpc.SetHeader(ctx, metadata.Pairs(pairs...)); err != nil {
+		log.Debug("failed to set store heartbeat header", zap.Error(err))
+	}
 
 	return &pdpb.StoreHeartbeatResponse{
 		Header: s.header(),
@@ -336,6 +382,7 @@ func (s *Server) RegionHeartbeat(stream pdpb.PD_RegionHeartbeatServer) error {
 		return errors.WithStack(err)
 	}
 
+	capabilities := storeCapabilitiesFromContext(stream.Context())
 	var lastBind time.Time
 	for {
 		request, err := server.Recv()
@@ -349,11 +396,13 @@ func (s *Server) RegionHeartbeat(stream pdpb.PD_RegionHeartbeatServer) error {
 		if err = s.validateRequest(request.GetHeader()); err != nil {
 			return err
 		}
+		s.hbCapture.captureRegionHeartbeat(request)
 
 		storeID := request.GetLeader().GetStoreId()
 		storeLabel := strconv.FormatUint(storeID, 10)
 		store := cluster.GetStore(storeID)
 		if store == nil {
+			s.quarantine.observe(stream.Context(), storeID, "region")
 			return errors.Errorf("invalid store ID %d, not found", storeID)
 		}
 		storeAddress := store.GetAddress()
@@ -367,9 +416,12 @@ func (s *Server) RegionHeartbeat(stream pdpb.PD_RegionHeartbeatServer) error {
 			regionHeartbeatCounter.WithLabelValues(storeAddress, storeLabel, "report", "bind").Inc()
 			hbStreams.bindStream(storeID, server)
 			lastBind = time.Now()
+			if !equalStrings(store.GetCapabilities(), capabilities) {
+				cluster.updateStoreCapabilities(storeID, capabilities)
+			}
 		}
 
-		region := core.RegionFromHeartbeat(request)
+		region := core.RegionFromHeartbeat(request, core.WithLearnerLags(cluster.learnerLags.get(request.GetRegion().GetId(), storeID)))
 		if region.GetLeader() == nil {
 			log.Error("invalid request, the leader is nil", zap.Reflect("reqeust", request))
 			continue
@@ -380,7 +432,9 @@ func (s *Server) RegionHeartbeat(stream pdpb.PD_RegionHeartbeatServer) error {
 			continue
 		}
 
-		err = cluster.HandleRegionHeartbeat(region)
+		span := opentracing.StartSpan("RegionHeartbeat", opentracing.Tag{Key: "region-id", Value: region.GetID()})
+		err = cluster.handleRegionHeartbeat(opentracing.ContextWithSpan(stream.Context(), span), region)
+		span.Finish()
 		if err != nil {
 			msg := err.Error()
 			hbStreams.sendErr(pdpb.ErrorType_UNKNOWN, msg, request.GetLeader(), storeAddress, storeLabel)
@@ -401,6 +455,7 @@ func (s *Server) GetRegion(ctx context.Context, request *pdpb.GetRegionRequest)
 		return &pdpb.GetRegionResponse{Header: s.notBootstrappedHeader()}, nil
 	}
 	region, leader := cluster.GetRegionByKey(request.GetRegionKey())
+	setRegionRoutingHeader(ctx, cluster, region)
 	return &pdpb.GetRegionResponse{
 		Header: s.header(),
 		Region: region,
@@ -420,6 +475,7 @@ func (s *Server) GetPrevRegion(ctx context.Context, request *pdpb.GetRegionReque
 	}
 
 	region, leader := cluster.GetPrevRegionByKey(request.GetRegionKey())
+	setRegionRoutingHeader(ctx, cluster, region)
 	return &pdpb.GetRegionResponse{
 		Header: s.header(),
 		Region: region,
@@ -439,6 +495,7 @@ func (s *Server) GetRegionByID(ctx context.Context, request *pdpb.GetRegionByIDR
 	}
 	id := request.GetRegionId()
 	region, leader := cluster.GetRegionByID(id)
+	setRegionRoutingHeader(ctx, cluster, region)
 	return &pdpb.GetRegionResponse{
 		Header: s.header(),
 		Region: region,
@@ -456,7 +513,11 @@ func (s *Server) ScanRegions(ctx context.Context, request *pdpb.ScanRegionsReque
 	if cluster == nil {
 		return &pdpb.ScanRegionsResponse{Header: s.notBootstrappedHeader()}, nil
 	}
-	regions := cluster.ScanRegions(request.GetStartKey(), request.GetEndKey(), int(request.GetLimit()))
+	opts, err := scanRegionOptionsFromContext(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	regions := cluster.ScanRegions(request.GetStartKey(), request.GetEndKey(), int(request.GetLimit()), opts...)
 	resp := &pdpb.ScanRegionsResponse{Header: s.header()}
 	for _, r := range regions {
 		leader := r.GetLeader()
@@ -482,6 +543,10 @@ func (s *Server) AskSplit(ctx context.Context, request *pdpb.AskSplitRequest) (*
 	if request.GetRegion() == nil {
 		return nil, errors.New("missing region for split")
 	}
+	priority, err := requestPriority(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
 	req := &pdpb.AskSplitRequest{
 		Region: request.Region,
 	}
@@ -489,6 +554,7 @@ func (s *Server) AskSplit(ctx context.Context, request *pdpb.AskSplitRequest) (*
 	if err != nil {
 		return nil, status.Errorf(codes.Unknown, err.Error())
 	}
+	cluster.GetCoordinator().recordSplitPriority(priority, request.GetRegion().GetId(), split.NewRegionId)
 
 	return &pdpb.AskSplitResponse{
 		Header:      s.header(),
@@ -514,6 +580,10 @@ func (s *Server) AskBatchSplit(ctx context.Context, request *pdpb.AskBatchSplitR
 	if request.GetRegion() == nil {
 		return nil, errors.New("missing region for split")
 	}
+	priority, err := requestPriority(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
 	req := &pdpb.AskBatchSplitRequest{
 		Region:     request.Region,
 		SplitCount: request.SplitCount,
@@ -522,6 +592,11 @@ func (s *Server) AskBatchSplit(ctx context.Context, request *pdpb.AskBatchSplitR
 	if err != nil {
 		return nil, status.Errorf(codes.Unknown, err.Error())
 	}
+	co := cluster.GetCoordinator()
+	co.recordSplitPriority(priority, request.GetRegion().GetId())
+	for _, id := range split.Ids {
+		co.recordSplitPriority(priority, id.NewRegionId)
+	}
 
 	return &pdpb.AskBatchSplitResponse{
 		Header: s.header(),
@@ -631,13 +706,12 @@ func (s *Server) ScatterRegion(ctx context.Context, request *pdpb.ScatterRegionR
 		return nil, errors.Errorf("region %d is a hot region", region.GetID())
 	}
 
-	co := cluster.GetCoordinator()
-	op, err := co.regionScatterer.Scatter(region)
+	priority, err := requestPriority(ctx)
 	if err != nil {
-		return nil, err
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
 	}
-	if op != nil {
-		co.opController.AddOperator(op)
+	if _, err := cluster.GetCoordinator().scatterRegion(region, priority); err != nil {
+		return nil, err
 	}
 
 	return &pdpb.ScatterRegionResponse{
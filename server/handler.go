@@ -24,9 +24,11 @@ import (
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/pingcap/log"
+	"github.com/pingcap/pd/server/checker"
 	"github.com/pingcap/pd/server/config"
 	"github.com/pingcap/pd/server/core"
 	"github.com/pingcap/pd/server/schedule"
+	"github.com/pingcap/pd/server/schedule/labeler"
 	"github.com/pingcap/pd/server/schedule/operator"
 	"github.com/pingcap/pd/server/statistics"
 	"github.com/pkg/errors"
@@ -35,16 +37,16 @@ import (
 
 var (
 	// ErrNotBootstrapped is error info for cluster not bootstrapped.
-	ErrNotBootstrapped = errors.New("TiKV cluster not bootstrapped, please start TiKV first")
+	ErrNotBootstrapped = errcode.NewCodedError(errors.New("TiKV cluster not bootstrapped, please start TiKV first"), core.NotBootstrappedCode)
 	// ErrOperatorNotFound is error info for operator not found.
-	ErrOperatorNotFound = errors.New("operator not found")
+	ErrOperatorNotFound = errcode.NewCodedError(errors.New("operator not found"), core.OperatorNotFoundCode)
 	// ErrAddOperator is error info for already have an operator when adding operator.
-	ErrAddOperator = errors.New("failed to add operator, maybe already have one")
+	ErrAddOperator = errcode.NewCodedError(errors.New("failed to add operator, maybe already have one"), core.OperatorConflictCode)
 	// ErrRegionNotAdjacent is error info for region not adjacent.
 	ErrRegionNotAdjacent = errors.New("two regions are not adjacent")
 	// ErrRegionNotFound is error info for region not found.
 	ErrRegionNotFound = func(regionID uint64) error {
-		return errors.Errorf("region %v not found", regionID)
+		return errcode.NewCodedError(errors.Errorf("region %v not found", regionID), core.RegionNotFoundCode)
 	}
 	// ErrRegionAbnormalPeer is error info for region has abonormal peer.
 	ErrRegionAbnormalPeer = func(regionID uint64) error {
@@ -52,14 +54,21 @@ var (
 	}
 	// ErrRegionIsStale is error info for region is stale.
 	ErrRegionIsStale = func(region *metapb.Region, origin *metapb.Region) error {
-		return errors.Errorf("region is stale: region %v origin %v", region, origin)
+		return errcode.NewCodedError(errors.Errorf("region is stale: region %v origin %v", region, origin), core.RegionStaleCode)
 	}
 	// ErrStoreNotFound is error info for store not found.
 	ErrStoreNotFound = func(storeID uint64) error {
-		return errors.Errorf("store %v not found", storeID)
+		return core.NewStoreNotFoundErr(storeID)
 	}
 )
 
+const (
+	// splitPolicyBoundary is the policy to split regions at unit boundaries.
+	splitPolicyBoundary = "boundary"
+	// maxBoundarySplitKeys limits the split keys of a region in one operator.
+	maxBoundarySplitKeys = 128
+)
+
 // Handler is a helper to export methods to handle API/RPC requests.
 type Handler struct {
 	s   *Server
@@ -75,6 +84,11 @@ func (h *Handler) GetRaftCluster() *RaftCluster {
 	return h.s.GetRaftCluster()
 }
 
+// GetAdvertiseClientUrls returns the urls of the server advertised to clients.
+func (h *Handler) GetAdvertiseClientUrls() []string {
+	return config.SplitUrls(h.s.GetAddr())
+}
+
 // GetScheduleConfig returns ScheduleConfig.
 func (h *Handler) GetScheduleConfig() *config.ScheduleConfig {
 	return h.s.GetScheduleConfig()
@@ -99,6 +113,44 @@ func (h *Handler) GetSchedulers() ([]string, error) {
 	return c.getSchedulers(), nil
 }
 
+// GetCheckersStatus returns the status of all checkers.
+func (h *Handler) GetCheckersStatus() ([]*CheckerStatus, error) {
+	c, err := h.getCoordinator()
+	if err != nil {
+		return nil, err
+	}
+	return c.getCheckersStatus(), nil
+}
+
+// GetCheckerStatus returns the status of the checker, or nil if the checker
+// does not exist.
+func (h *Handler) GetCheckerStatus(name string) (*CheckerStatus, error) {
+	c, err := h.getCoordinator()
+	if err != nil {
+		return nil, err
+	}
+	return c.getCheckerStatus(name), nil
+}
+
+// GetLocationViolations returns the regions whose replicas are not isolated by
+// the location labels as well as the cluster allows, ordered by the severity.
+func (h *Handler) GetLocationViolations() ([]*checker.LocationViolation, error) {
+	c, err := h.getCoordinator()
+	if err != nil {
+		return nil, err
+	}
+	return c.getLocationViolations(), nil
+}
+
+// PauseChecker pauses or resumes the checker.
+func (h *Handler) PauseChecker(name string, paused bool) error {
+	c, err := h.getCoordinator()
+	if err != nil {
+		return err
+	}
+	return c.pauseChecker(name, paused)
+}
+
 // GetStores returns all stores in the cluster.
 func (h *Handler) GetStores() ([]*core.StoreInfo, error) {
 	cluster := h.s.GetRaftCluster()
@@ -205,14 +257,64 @@ func (h *Handler) RemoveScheduler(name string) error {
 	return err
 }
 
+// GetSchedulerDeniedStores returns the stores denied for the scheduler.
+func (h *Handler) GetSchedulerDeniedStores(name string) ([]uint64, error) {
+	c, err := h.getCoordinator()
+	if err != nil {
+		return nil, err
+	}
+	return c.getSchedulerDeniedStores(name)
+}
+
+// SetSchedulerDeniedStores sets the stores denied for the scheduler, so that
+// the scheduler stops scheduling on the stores while other schedulers still
+// work on them.
+func (h *Handler) SetSchedulerDeniedStores(name string, storeIDs []uint64) error {
+	c, err := h.getCoordinator()
+	if err != nil {
+		return err
+	}
+	if err = c.setSchedulerDeniedStores(name, storeIDs); err != nil {
+		log.Error("can not set denied stores of scheduler", zap.String("scheduler-name", name), zap.Error(err))
+	} else if err = h.opt.Persist(c.cluster.storage); err != nil {
+		log.Error("can not persist scheduler config", zap.Error(err))
+	}
+	return err
+}
+
+// GetSchedulerConfig returns the config and the progress of the scheduler.
+func (h *Handler) GetSchedulerConfig(name string) (interface{}, error) {
+	c, err := h.getCoordinator()
+	if err != nil {
+		return nil, err
+	}
+	return c.getSchedulerConfig(name)
+}
+
+// ResetSchedulerHits clears the records of the stores failing to be picked by
+// the scheduler, so that the stores skipped for them are picked again.
+func (h *Handler) ResetSchedulerHits(name string) error {
+	c, err := h.getCoordinator()
+	if err != nil {
+		return err
+	}
+	if err = c.resetSchedulerHits(name); err != nil {
+		return err
+	}
+	log.Info("reset hit records of scheduler", zap.String("scheduler-name", name))
+	return nil
+}
+
 // AddBalanceLeaderScheduler adds a balance-leader-scheduler.
 func (h *Handler) AddBalanceLeaderScheduler() error {
 	return h.AddScheduler("balance-leader")
 }
 
-// AddBalanceRegionScheduler adds a balance-region-scheduler.
-func (h *Handler) AddBalanceRegionScheduler() error {
-	return h.AddScheduler("balance-region")
+// AddBalanceRegionScheduler adds a balance-region-scheduler. The args are the
+// escaped start and end keys of the ranges that the scheduler is restricted
+// to.
+func (h *Handler) AddBalanceRegionScheduler(args ...string) error {
+	return h.AddScheduler("balance-region", args...)
 }
 
 // AddBalanceHotRegionScheduler adds a balance-hot-region-scheduler.
@@ -311,6 +413,23 @@ func (h *Handler) RemoveOperator(regionID uint64) error {
 	return nil
 }
 
+// CancelOperator cancels the region operator with the reason, which is kept
+// in the operator status.
+func (h *Handler) CancelOperator(regionID uint64, reason string) error {
+	c, err := h.getCoordinator()
+	if err != nil {
+		return err
+	}
+
+	op := c.opController.GetOperator(regionID)
+	if op == nil {
+		return ErrOperatorNotFound
+	}
+
+	_ = c.opController.CancelOperator(op, reason)
+	return nil
+}
+
 // GetOperators returns the running operators.
 func (h *Handler) GetOperators() ([]*operator.Operator, error) {
 	c, err := h.getCoordinator()
@@ -387,6 +506,24 @@ func (h *Handler) GetAllStoresLimit() (map[uint64]float64, error) {
 	return c.opController.GetAllStoresLimit(), nil
 }
 
+// GetRangeLabeler returns the labeler of key ranges.
+func (h *Handler) GetRangeLabeler() (*labeler.RangeLabeler, error) {
+	cluster := h.s.GetRaftCluster()
+	if cluster == nil {
+		return nil, ErrNotBootstrapped
+	}
+	return cluster.GetRangeLabeler(), nil
+}
+
+// GetHeartbeatIntervals returns the heartbeat intervals recommended to stores.
+func (h *Handler) GetHeartbeatIntervals() (*HeartbeatIntervals, error) {
+	cluster := h.s.GetRaftCluster()
+	if cluster == nil {
+		return nil, ErrNotBootstrapped
+	}
+	return cluster.GetHeartbeatIntervals(), nil
+}
+
 // SetStoreLimit is used to set the limit of a store.
 func (h *Handler) SetStoreLimit(storeID uint64, rate float64) error {
 	c, err := h.getCoordinator()
@@ -413,6 +550,9 @@ func (h *Handler) AddTransferLeaderOperator(regionID uint64, storeID uint64) err
 	if newLeader == nil {
 		return errors.Errorf("region has no voter in store %v", storeID)
 	}
+	if store := c.cluster.GetStore(storeID); store != nil && store.IsLearnerOnly() {
+		return errors.Errorf("store %v only holds learners", storeID)
+	}
 
 	op := operator.CreateTransferLeaderOperator("admin-transfer-leader", region, region.GetLeader().GetStoreId(), newLeader.GetStoreId(), operator.OpAdmin)
 	if ok := c.opController.AddOperator(op); !ok {
@@ -446,6 +586,9 @@ func (h *Handler) AddTransferRegionOperator(regionID uint64, storeIDs map[uint64
 		if store.IsTombstone() {
 			return errcode.Op("operator.add").AddTo(core.StoreTombstonedErr{StoreID: id})
 		}
+		if store.IsLearnerOnly() {
+			return errors.Errorf("store %v only holds learners", id)
+		}
 	}
 
 	op, err := operator.CreateMoveRegionOperator("admin-move-region", c.cluster, region, operator.OpAdmin, storeIDs)
@@ -482,6 +625,9 @@ func (h *Handler) AddTransferPeerOperator(regionID uint64, fromStoreID, toStoreI
 	if toStore.IsTombstone() {
 		return errcode.Op("operator.add").AddTo(core.StoreTombstonedErr{StoreID: toStoreID})
 	}
+	if toStore.IsLearnerOnly() {
+		return errors.Errorf("store %v only holds learners", toStoreID)
+	}
 
 	newPeer, err := c.cluster.AllocPeer(toStoreID)
 	if err != nil {
@@ -531,6 +677,9 @@ func (h *Handler) AddAddPeerOperator(regionID uint64, toStoreID uint64) error {
 	if err != nil {
 		return err
 	}
+	if c.cluster.GetStore(toStoreID).IsLearnerOnly() {
+		return errors.Errorf("store %v only holds learners", toStoreID)
+	}
 
 	newPeer, err := c.cluster.AllocPeer(toStoreID)
 	if err != nil {
@@ -589,6 +738,111 @@ func (h *Handler) AddRemovePeerOperator(regionID uint64, fromStoreID uint64) err
 	return nil
 }
 
+// AddPromoteLearnerOperator adds an operator to promote a learner to voter in
+// place.
+func (h *Handler) AddPromoteLearnerOperator(regionID uint64, storeID uint64) error {
+	c, err := h.getCoordinator()
+	if err != nil {
+		return err
+	}
+
+	region := c.cluster.GetRegion(regionID)
+	if region == nil {
+		return ErrRegionNotFound(regionID)
+	}
+	learner, err := h.checkLearnerToPromote(c, region, storeID)
+	if err != nil {
+		return err
+	}
+
+	op := operator.CreatePromoteLearnerOperator("admin-promote-learner", region, learner)
+	op.AttachKind(operator.OpAdmin)
+	if ok := c.opController.AddOperator(op); !ok {
+		return errors.WithStack(ErrAddOperator)
+	}
+	return nil
+}
+
+// AddDemoteVoterOperator adds an operator to demote a voter to learner in
+// place.
+func (h *Handler) AddDemoteVoterOperator(regionID uint64, storeID uint64) error {
+	c, err := h.getCoordinator()
+	if err != nil {
+		return err
+	}
+
+	region := c.cluster.GetRegion(regionID)
+	if region == nil {
+		return ErrRegionNotFound(regionID)
+	}
+	voter := region.GetStoreVoter(storeID)
+	if voter == nil {
+		return errors.Errorf("region has no voter in store %v", storeID)
+	}
+
+	op, err := operator.CreateDemoteVoterOperator("admin-demote-voter", c.cluster, region, voter)
+	if err != nil {
+		return err
+	}
+	op.AttachKind(operator.OpAdmin)
+	if ok := c.opController.AddOperator(op); !ok {
+		return errors.WithStack(ErrAddOperator)
+	}
+	return nil
+}
+
+// AddSwitchRoleOperator adds an operator to promote the learner in a store and
+// demote the voter in another store in place.
+func (h *Handler) AddSwitchRoleOperator(regionID uint64, learnerStoreID, voterStoreID uint64) error {
+	c, err := h.getCoordinator()
+	if err != nil {
+		return err
+	}
+
+	region := c.cluster.GetRegion(regionID)
+	if region == nil {
+		return ErrRegionNotFound(regionID)
+	}
+	learner, err := h.checkLearnerToPromote(c, region, learnerStoreID)
+	if err != nil {
+		return err
+	}
+	voter := region.GetStoreVoter(voterStoreID)
+	if voter == nil {
+		return errors.Errorf("region has no voter in store %v", voterStoreID)
+	}
+
+	op, err := operator.CreateSwitchRoleOperator("admin-switch-role", c.cluster, region, learner, voter)
+	if err != nil {
+		return err
+	}
+	op.AttachKind(operator.OpAdmin)
+	if ok := c.opController.AddOperator(op); !ok {
+		return errors.WithStack(ErrAddOperator)
+	}
+	return nil
+}
+
+// checkLearnerToPromote returns the learner of the region in the store, which
+// must be able to hold a voter.
+func (h *Handler) checkLearnerToPromote(c *coordinator, region *core.RegionInfo, storeID uint64) (*metapb.Peer, error) {
+	learner := region.GetStoreLearner(storeID)
+	if learner == nil {
+		return nil, errors.Errorf("region has no learner in store %v", storeID)
+	}
+	if region.GetPendingLearner(learner.GetId()) != nil {
+		return nil, errors.Errorf("learner in store %v is pending", storeID)
+	}
+	store := c.cluster.GetStore(storeID)
+	if store == nil {
+		return nil, core.NewStoreNotFoundErr(storeID)
+	}
+	if store.IsLearnerOnly() {
+		return nil, errors.Errorf("store %v only holds learners", storeID)
+	}
+	return learner, nil
+}
+
 // AddMergeRegionOperator adds an operator to merge region.
 func (h *Handler) AddMergeRegionOperator(regionID uint64, targetID uint64) error {
 	c, err := h.getCoordinator()
@@ -632,7 +886,9 @@ func (h *Handler) AddMergeRegionOperator(regionID uint64, targetID uint64) error
 	return nil
 }
 
-// AddSplitRegionOperator adds an operator to split a region.
+// AddSplitRegionOperator adds an operator to split a region. The boundary
+// policy splits the region at the boundaries of the units decoded by the key
+// decoder, such as tables.
 func (h *Handler) AddSplitRegionOperator(regionID uint64, policyStr string, keys []string) error {
 	c, err := h.getCoordinator()
 	if err != nil {
@@ -644,13 +900,18 @@ func (h *Handler) AddSplitRegionOperator(regionID uint64, policyStr string, keys
 		return ErrRegionNotFound(regionID)
 	}
 
+	var splitKeys [][]byte
 	policy, ok := pdpb.CheckPolicy_value[strings.ToUpper(policyStr)]
-	if !ok {
+	switch {
+	case strings.EqualFold(policyStr, splitPolicyBoundary):
+		policy = int32(pdpb.CheckPolicy_USEKEY)
+		splitKeys = h.s.keyDecoder.UnitBoundaries(region.GetStartKey(), region.GetEndKey(), maxBoundarySplitKeys)
+		if len(splitKeys) == 0 {
+			return errors.Errorf("region %v does not cross unit boundaries", regionID)
+		}
+	case !ok:
 		return errors.Errorf("check policy %s is not supported", policyStr)
-	}
-
-	var splitKeys [][]byte
-	if pdpb.CheckPolicy(policy) == pdpb.CheckPolicy_USEKEY {
+	case pdpb.CheckPolicy(policy) == pdpb.CheckPolicy_USEKEY:
 		for i := range keys {
 			k, err := hex.DecodeString(keys[i])
 			if err != nil {
@@ -667,8 +928,10 @@ func (h *Handler) AddSplitRegionOperator(regionID uint64, policyStr string, keys
 	return nil
 }
 
-// AddScatterRegionOperator adds an operator to scatter a region.
-func (h *Handler) AddScatterRegionOperator(regionID uint64) error {
+// AddScatterRegionOperator adds an operator to scatter a region. The
+// operator waits for the operator limits in the order of the priority, or the
+// priority of the split creating the region if priority is nil.
+func (h *Handler) AddScatterRegionOperator(regionID uint64, priority *core.PriorityLevel) error {
 	c, err := h.getCoordinator()
 	if err != nil {
 		return err
@@ -683,15 +946,11 @@ func (h *Handler) AddScatterRegionOperator(regionID uint64) error {
 		return errors.Errorf("region %d is a hot region", regionID)
 	}
 
-	op, err := c.regionScatterer.Scatter(region)
+	ok, err := c.scatterRegion(region, priority)
 	if err != nil {
 		return err
 	}
-
-	if op == nil {
-		return nil
-	}
-	if ok := c.opController.AddOperator(op); !ok {
+	if !ok {
 		return errors.WithStack(ErrAddOperator)
 	}
 	return nil
@@ -759,3 +1018,23 @@ func (h *Handler) GetEmptyRegion() ([]*core.RegionInfo, error) {
 	}
 	return c.GetRegionStatsByType(statistics.EmptyRegion), nil
 }
+
+// GetLongEmptyRegions gets the regions which have no keys and have been empty
+// for long-empty-region-duration.
+func (h *Handler) GetLongEmptyRegions() ([]*core.RegionInfo, error) {
+	c := h.s.GetRaftCluster()
+	if c == nil {
+		return nil, ErrNotBootstrapped
+	}
+	return c.GetLongEmptyRegions(), nil
+}
+
+// GetLaggingLearnerRegions gets the region with learners lagging behind the
+// leader more than max-learner-promote-lag.
+func (h *Handler) GetLaggingLearnerRegions() ([]*core.RegionInfo, error) {
+	c := h.s.GetRaftCluster()
+	if c == nil {
+		return nil, ErrNotBootstrapped
+	}
+	return c.GetRegionStatsByType(statistics.LaggingLearner), nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/pd/server/config"
+	"github.com/pingcap/pd/server/core"
+)
+
+// cpuSampleInterval is the min interval to sample the CPU usage of PD.
+const cpuSampleInterval = time.Second
+
+// heartbeatAdmission detects whether PD is overloaded by the CPU usage of the
+// process and the number of region heartbeats being processed.
+type heartbeatAdmission struct {
+	pending int64 // atomic
+
+	sync.Mutex
+	cpuTime    func() (time.Duration, bool)
+	lastSample time.Time
+	lastCPU    time.Duration
+	cpuUsage   float64
+}
+
+func newHeartbeatAdmission() *heartbeatAdmission {
+	a := &heartbeatAdmission{cpuTime: processCPUTime, lastSample: time.Now()}
+	a.lastCPU, _ = a.cpuTime()
+	return a
+}
+
+func (a *heartbeatAdmission) enter() {
+	heartbeatAdmissionGauge.WithLabelValues("pending").Set(float64(atomic.AddInt64(&a.pending, 1)))
+}
+
+func (a *heartbeatAdmission) leave() {
+	atomic.AddInt64(&a.pending, -1)
+}
+
+// getCPUUsage returns the CPU usage of the process over all cores. It is
+// sampled at most once per cpuSampleInterval.
+func (a *heartbeatAdmission) getCPUUsage() float64 {
+	a.Lock()
+	defer a.Unlock()
+	now := time.Now()
+	elapsed := now.Sub(a.lastSample)
+	if elapsed < cpuSampleInterval {
+		return a.cpuUsage
+	}
+	cpu, ok := a.cpuTime()
+	if !ok {
+		return 0
+	}
+	a.cpuUsage = float64(cpu-a.lastCPU) / float64(elapsed) / float64(runtime.NumCPU())
+	a.lastSample, a.lastCPU = now, cpu
+	heartbeatAdmissionGauge.WithLabelValues("cpu-usage").Set(a.cpuUsage)
+	return a.cpuUsage
+}
+
+// isOverloaded checks if PD is too busy to process all region heartbeats.
+func (a *heartbeatAdmission) isOverloaded(cfg *config.PDServerConfig) bool {
+	overloaded := (cfg.HeartbeatAdmissionMaxPending > 0 && uint64(atomic.LoadInt64(&a.pending)) > cfg.HeartbeatAdmissionMaxPending) ||
+		(cfg.HeartbeatAdmissionCPUThreshold > 0 && a.getCPUUsage() >= cfg.HeartbeatAdmissionCPUThreshold)
+	if overloaded {
+		heartbeatAdmissionGauge.WithLabelValues("overloaded").Set(1)
+	} else {
+		heartbeatAdmissionGauge.WithLabelValues("overloaded").Set(0)
+	}
+	return overloaded
+}
+
+// isLowValueHeartbeat checks if the heartbeat changes nothing but the
+// statistics of the region. Changes of epoch, leader and peer states are
+// never regarded as low value.
+func isLowValueHeartbeat(origin, region *core.RegionInfo) bool {
+	if origin == nil {
+		return false
+	}
+	r, o := region.GetRegionEpoch(), origin.GetRegionEpoch()
+	return r.GetVersion() == o.GetVersion() &&
+		r.GetConfVer() == o.GetConfVer() &&
+		region.GetLeader().GetId() == origin.GetLeader().GetId() &&
+		len(region.GetPeers()) == len(origin.GetPeers()) &&
+		len(region.GetDownPeers()) == 0 && len(region.GetPendingPeers()) == 0 &&
+		len(origin.GetDownPeers()) == 0 && len(origin.GetPendingPeers()) == 0
+}
+
+// shouldShedRegionHeartbeat checks if the region heartbeat can be dropped to
+// protect PD when it is overloaded. Heartbeats of the regions with operators
+// are always processed to push the operators forward.
+func (c *RaftCluster) shouldShedRegionHeartbeat(region *core.RegionInfo) bool {
+	if !c.hbAdmission.isOverloaded(c.opt.LoadPDServerConfig()) {
+		return false
+	}
+	if !isLowValueHeartbeat(c.GetRegion(region.GetID()), region) {
+		return false
+	}
+	c.RLock()
+	co := c.coordinator
+	c.RUnlock()
+	return co == nil || co.opController.GetOperator(region.GetID()) == nil
+}
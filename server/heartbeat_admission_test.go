@@ -0,0 +1,102 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"runtime"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/server/config"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/namespace"
+)
+
+var _ = Suite(&testHeartbeatAdmissionSuite{})
+
+type testHeartbeatAdmissionSuite struct{}
+
+func (s *testHeartbeatAdmissionSuite) TestOverloaded(c *C) {
+	cfg := &config.PDServerConfig{HeartbeatAdmissionMaxPending: 1}
+	a := newHeartbeatAdmission()
+	a.enter()
+	c.Assert(a.isOverloaded(cfg), IsFalse)
+	a.enter()
+	c.Assert(a.isOverloaded(cfg), IsTrue)
+	a.leave()
+	c.Assert(a.isOverloaded(cfg), IsFalse)
+
+	// Uses all cores in the last second.
+	cfg = &config.PDServerConfig{HeartbeatAdmissionCPUThreshold: 0.8}
+	a.cpuTime = func() (time.Duration, bool) {
+		return a.lastCPU + time.Duration(runtime.NumCPU())*time.Second, true
+	}
+	a.lastSample = time.Now().Add(-time.Second)
+	c.Assert(a.isOverloaded(cfg), IsTrue)
+	// The usage is not sampled again within the interval.
+	a.cpuTime = func() (time.Duration, bool) { return a.lastCPU, true }
+	c.Assert(a.isOverloaded(cfg), IsTrue)
+	a.lastSample = time.Now().Add(-time.Second)
+	c.Assert(a.isOverloaded(cfg), IsFalse)
+}
+
+func (s *testHeartbeatAdmissionSuite) TestLowValueHeartbeat(c *C) {
+	peers := []*metapb.Peer{{Id: 1, StoreId: 1}, {Id: 2, StoreId: 2}}
+	origin := core.NewRegionInfo(&metapb.Region{
+		Id:          1,
+		Peers:       peers,
+		RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: 1},
+	}, peers[0], core.SetApproximateSize(10))
+
+	c.Assert(isLowValueHeartbeat(nil, origin), IsFalse)
+	c.Assert(isLowValueHeartbeat(origin, origin.Clone(core.SetApproximateSize(20))), IsTrue)
+	c.Assert(isLowValueHeartbeat(origin, origin.Clone(core.WithIncVersion())), IsFalse)
+	c.Assert(isLowValueHeartbeat(origin, origin.Clone(core.WithIncConfVer())), IsFalse)
+	c.Assert(isLowValueHeartbeat(origin, origin.Clone(core.WithLeader(peers[1]))), IsFalse)
+	c.Assert(isLowValueHeartbeat(origin, origin.Clone(core.WithPendingPeers(peers[1:]))), IsFalse)
+}
+
+func (s *testHeartbeatAdmissionSuite) TestShed(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	opt.LoadPDServerConfig().HeartbeatAdmissionMaxPending = 1
+	tc := newTestCluster(opt)
+	hbStreams, cleanup := getHeartBeatStreams(c, tc)
+	defer cleanup()
+	defer hbStreams.Close()
+	tc.RaftCluster.coordinator = newCoordinator(tc.RaftCluster, hbStreams, namespace.DefaultClassifier)
+
+	c.Assert(tc.addRegionStore(1, 1), IsNil)
+	c.Assert(tc.addRegionStore(2, 1), IsNil)
+	c.Assert(tc.addLeaderRegion(1, 1, 2), IsNil)
+	region := tc.GetRegion(1)
+
+	// Not overloaded.
+	c.Assert(tc.HandleRegionHeartbeat(region.Clone(core.SetApproximateSize(20))), IsNil)
+	c.Assert(tc.GetRegion(1).GetApproximateSize(), Equals, int64(20))
+
+	// Overloaded by other heartbeats being processed.
+	for i := 0; i < 2; i++ {
+		tc.hbAdmission.enter()
+		defer tc.hbAdmission.leave()
+	}
+	c.Assert(tc.GetHeartbeatIntervals().RegionHeartbeatInterval, Equals, opt.LoadPDServerConfig().MaxRegionHeartbeatInterval)
+	c.Assert(tc.HandleRegionHeartbeat(region.Clone(core.SetApproximateSize(30))), IsNil)
+	c.Assert(tc.GetRegion(1).GetApproximateSize(), Equals, int64(20))
+	// Changes of leader are always processed.
+	c.Assert(tc.HandleRegionHeartbeat(region.Clone(core.WithLeader(region.GetStorePeer(2)), core.SetApproximateSize(30))), IsNil)
+	c.Assert(tc.GetRegion(1).GetLeader().GetStoreId(), Equals, uint64(2))
+	c.Assert(tc.GetRegion(1).GetApproximateSize(), Equals, int64(30))
+}
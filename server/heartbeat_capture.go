@@ -0,0 +1,71 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/pkg/heartbeatlog"
+	"go.uber.org/zap"
+)
+
+// heartbeatCapture appends the heartbeats received by the server to a log
+// file. The capture stops at the first error, so that a full disk does not
+// slow down the heartbeats. A nil heartbeatCapture captures nothing.
+type heartbeatCapture struct {
+	path    string
+	writer  *heartbeatlog.Writer
+	stopped int32
+}
+
+func newHeartbeatCapture(path string, rotation heartbeatlog.Rotation) (*heartbeatCapture, error) {
+	writer, err := heartbeatlog.Create(path, rotation)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("capture heartbeats", zap.String("file", path), zap.Int64("max-size", rotation.MaxSize), zap.Int("max-backups", rotation.MaxBackups))
+	return &heartbeatCapture{path: path, writer: writer}, nil
+}
+
+func (c *heartbeatCapture) captureStoreHeartbeat(req *pdpb.StoreHeartbeatRequest) {
+	if c == nil || atomic.LoadInt32(&c.stopped) == 1 {
+		return
+	}
+	c.checkErr(c.writer.WriteStoreHeartbeat(time.Now(), req))
+}
+
+func (c *heartbeatCapture) captureRegionHeartbeat(req *pdpb.RegionHeartbeatRequest) {
+	if c == nil || atomic.LoadInt32(&c.stopped) == 1 {
+		return
+	}
+	c.checkErr(c.writer.WriteRegionHeartbeat(time.Now(), req))
+}
+
+func (c *heartbeatCapture) checkErr(err error) {
+	if err != nil && atomic.CompareAndSwapInt32(&c.stopped, 0, 1) {
+		log.Error("failed to capture heartbeats, stop capturing", zap.String("file", c.path), zap.Error(err))
+	}
+}
+
+func (c *heartbeatCapture) close() {
+	if c == nil {
+		return
+	}
+	if err := c.writer.Close(); err != nil {
+		log.Error("failed to close the heartbeat capture file", zap.String("file", c.path), zap.Error(err))
+	}
+}
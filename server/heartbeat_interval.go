@@ -0,0 +1,114 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/pd/pkg/typeutil"
+	"github.com/pingcap/pd/server/config"
+)
+
+const (
+	// storeHeartbeatIntervalKey and regionHeartbeatIntervalKey are the gRPC
+	// header keys to carry the recommended heartbeat intervals in seconds,
+	// since StoreHeartbeatResponse has no field for them.
+	storeHeartbeatIntervalKey  = "pd-store-heartbeat-interval"
+	regionHeartbeatIntervalKey = "pd-region-heartbeat-interval"
+)
+
+// HeartbeatIntervals is the heartbeat intervals recommended to stores.
+type HeartbeatIntervals struct {
+	StoreHeartbeatInterval  typeutil.Duration `json:"store-heartbeat-interval"`
+	RegionHeartbeatInterval typeutil.Duration `json:"region-heartbeat-interval"`
+	// RegionHeartbeatRate is the observed region heartbeats per second.
+	RegionHeartbeatRate float64 `json:"region-heartbeat-rate"`
+}
+
+func (hi *HeartbeatIntervals) headerPairs() []string {
+	return []string{
+		storeHeartbeatIntervalKey, strconv.FormatInt(int64(hi.StoreHeartbeatInterval.Seconds()), 10),
+		regionHeartbeatIntervalKey, strconv.FormatInt(int64(hi.RegionHeartbeatInterval.Seconds()), 10),
+	}
+}
+
+// heartbeatIntervalAdvisor computes heartbeat intervals for stores according
+// to the cluster size and the load of PD. The intervals are enlarged from the
+// lower bounds proportionally when the region heartbeat rate is higher than
+// PD can handle.
+type heartbeatIntervalAdvisor struct {
+	regionHeartbeats uint64 // atomic
+
+	sync.RWMutex
+	lastCollect time.Time
+	rate        float64
+}
+
+func newHeartbeatIntervalAdvisor() *heartbeatIntervalAdvisor {
+	return &heartbeatIntervalAdvisor{lastCollect: time.Now()}
+}
+
+func (a *heartbeatIntervalAdvisor) observeRegionHeartbeat() {
+	atomic.AddUint64(&a.regionHeartbeats, 1)
+}
+
+// collect updates the observed region heartbeat rate since last collection.
+func (a *heartbeatIntervalAdvisor) collect() {
+	a.Lock()
+	defer a.Unlock()
+	now := time.Now()
+	elapsed := now.Sub(a.lastCollect).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	a.rate = float64(atomic.SwapUint64(&a.regionHeartbeats, 0)) / elapsed
+	a.lastCollect = now
+}
+
+func (a *heartbeatIntervalAdvisor) getRate() float64 {
+	a.RLock()
+	defer a.RUnlock()
+	return a.rate
+}
+
+func (a *heartbeatIntervalAdvisor) getIntervals(cfg *config.PDServerConfig, regionCount int) *HeartbeatIntervals {
+	rate := a.getRate()
+	factor := 1.0
+	if cfg.MaxRegionHeartbeatRate > 0 && cfg.MinRegionHeartbeatInterval.Duration > 0 {
+		// The expected rate if every region reports at the lower bound.
+		expected := float64(regionCount) / cfg.MinRegionHeartbeatInterval.Seconds()
+		factor = math.Max(factor, expected/float64(cfg.MaxRegionHeartbeatRate))
+		factor = math.Max(factor, rate/float64(cfg.MaxRegionHeartbeatRate))
+	}
+	return &HeartbeatIntervals{
+		StoreHeartbeatInterval:  typeutil.NewDuration(scaleInterval(cfg.MinStoreHeartbeatInterval.Duration, cfg.MaxStoreHeartbeatInterval.Duration, factor)),
+		RegionHeartbeatInterval: typeutil.NewDuration(scaleInterval(cfg.MinRegionHeartbeatInterval.Duration, cfg.MaxRegionHeartbeatInterval.Duration, factor)),
+		RegionHeartbeatRate:     rate,
+	}
+}
+
+func scaleInterval(min, max time.Duration, factor float64) time.Duration {
+	interval := time.Duration(float64(min) * factor).Round(time.Second)
+	if interval > max {
+		return max
+	}
+	if interval < min {
+		return min
+	}
+	return interval
+}
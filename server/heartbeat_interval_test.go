@@ -0,0 +1,64 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/pd/pkg/typeutil"
+	"github.com/pingcap/pd/server/config"
+)
+
+var _ = Suite(&testHeartbeatIntervalSuite{})
+
+type testHeartbeatIntervalSuite struct{}
+
+func (s *testHeartbeatIntervalSuite) TestGetIntervals(c *C) {
+	cfg := &config.PDServerConfig{
+		MinStoreHeartbeatInterval:  typeutil.NewDuration(10 * time.Second),
+		MaxStoreHeartbeatInterval:  typeutil.NewDuration(time.Minute),
+		MinRegionHeartbeatInterval: typeutil.NewDuration(time.Minute),
+		MaxRegionHeartbeatInterval: typeutil.NewDuration(5 * time.Minute),
+		MaxRegionHeartbeatRate:     100,
+	}
+	a := newHeartbeatIntervalAdvisor()
+
+	// Small cluster uses the lower bounds.
+	intervals := a.getIntervals(cfg, 600)
+	c.Assert(intervals.StoreHeartbeatInterval.Duration, Equals, 10*time.Second)
+	c.Assert(intervals.RegionHeartbeatInterval.Duration, Equals, time.Minute)
+
+	// 12000 regions report 200 times per second at the lower bound.
+	intervals = a.getIntervals(cfg, 12000)
+	c.Assert(intervals.StoreHeartbeatInterval.Duration, Equals, 20*time.Second)
+	c.Assert(intervals.RegionHeartbeatInterval.Duration, Equals, 2*time.Minute)
+
+	// Huge cluster is limited by the upper bounds.
+	intervals = a.getIntervals(cfg, 1000000)
+	c.Assert(intervals.StoreHeartbeatInterval.Duration, Equals, time.Minute)
+	c.Assert(intervals.RegionHeartbeatInterval.Duration, Equals, 5*time.Minute)
+
+	// The observed rate is taken into account.
+	a.lastCollect = time.Now().Add(-time.Second)
+	for i := 0; i < 300; i++ {
+		a.observeRegionHeartbeat()
+	}
+	a.collect()
+	c.Assert(a.getRate(), LessEqual, 300.0)
+	c.Assert(a.getRate(), Greater, 200.0)
+	intervals = a.getIntervals(cfg, 600)
+	c.Assert(intervals.StoreHeartbeatInterval.Duration, Greater, 20*time.Second)
+	c.Assert(intervals.RegionHeartbeatInterval.Duration, Greater, 2*time.Minute)
+}
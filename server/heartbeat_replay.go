@@ -0,0 +1,139 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/pkg/heartbeatlog"
+	"github.com/pingcap/pd/server/config"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/kv"
+	"github.com/pingcap/pd/server/namespace"
+	"github.com/pingcap/pd/server/statistics"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ReplayStats is the result of replaying heartbeats.
+type ReplayStats struct {
+	StoreHeartbeats  int
+	RegionHeartbeats int
+	// Errors is the number of the heartbeats failed to be handled, such as
+	// the stale region heartbeats.
+	Errors int
+	// Elapsed is the wall time of the replay, including the time waiting for
+	// the pace of the log.
+	Elapsed time.Duration
+	// StoreHeartbeatTime and RegionHeartbeatTime are the total time spent on
+	// handling the heartbeats.
+	StoreHeartbeatTime  time.Duration
+	RegionHeartbeatTime time.Duration
+}
+
+// ReplayHeartbeats feeds the heartbeats in the log into a fresh RaftCluster
+// with the configuration, for testing the performance of heartbeat
+// processing offline. The speed is the multiple of the pace at which the
+// heartbeats were captured, and the heartbeats are fed as fast as possible if
+// it is not positive. The stores are created on their first heartbeats, and
+// the schedulers are not running, so no operator is created.
+func ReplayHeartbeats(ctx context.Context, r *heartbeatlog.Reader, cfg *config.Config, speed float64) (*ReplayStats, error) {
+	opt := config.NewScheduleOption(cfg)
+	cluster := &RaftCluster{}
+	cluster.initCluster(&replayIDAllocator{}, opt, core.NewStorage(kv.NewMemoryKV()))
+	hbStreams := newHeartbeatStreams(0, cluster)
+	defer hbStreams.Close()
+	cluster.coordinator = newCoordinator(cluster, hbStreams, namespace.DefaultClassifier)
+	cluster.regionStats = statistics.NewRegionStatistics(opt, namespace.DefaultClassifier)
+
+	stats := &ReplayStats{}
+	start := time.Now()
+	var firstTime time.Time
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+		if firstTime.IsZero() {
+			firstTime = record.Time
+		}
+		if speed > 0 {
+			wait := time.Duration(float64(record.Time.Sub(firstTime))/speed) - time.Since(start)
+			if wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return stats, errors.WithStack(ctx.Err())
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return stats, errors.WithStack(ctx.Err())
+		default:
+		}
+
+		if record.Store != nil {
+			stats.StoreHeartbeats++
+			storeStats := record.Store.GetStats()
+			cluster.ensureReplayStore(storeStats.GetStoreId())
+			handleStart := time.Now()
+			err = cluster.handleStoreHeartbeat(storeStats, storeMemoryUsage{})
+			stats.StoreHeartbeatTime += time.Since(handleStart)
+		} else {
+			stats.RegionHeartbeats++
+			region := core.RegionFromHeartbeat(record.Region)
+			cluster.ensureReplayStore(region.GetLeader().GetStoreId())
+			handleStart := time.Now()
+			err = cluster.HandleRegionHeartbeat(region)
+			stats.RegionHeartbeatTime += time.Since(handleStart)
+		}
+		if err != nil {
+			stats.Errors++
+		}
+	}
+	stats.Elapsed = time.Since(start)
+	return stats, nil
+}
+
+// ensureReplayStore creates the store if it does not exist, since the log
+// only has the heartbeats.
+func (c *RaftCluster) ensureReplayStore(storeID uint64) {
+	if storeID == 0 || c.GetStore(storeID) != nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	store := core.NewStoreInfo(&metapb.Store{Id: storeID, State: metapb.StoreState_Up}, core.SetLastHeartbeatTS(time.Now()))
+	if err := c.putStoreLocked(store); err != nil {
+		log.Warn("failed to create the store for replaying", zap.Uint64("store-id", storeID), zap.Error(err))
+	}
+}
+
+// replayIDAllocator allocates IDs in memory for the replaying cluster.
+type replayIDAllocator struct {
+	base uint64
+}
+
+func (alloc *replayIDAllocator) Alloc() (uint64, error) {
+	return atomic.AddUint64(&alloc.base, 1), nil
+}
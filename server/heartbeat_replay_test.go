@@ -0,0 +1,83 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/pd/pkg/heartbeatlog"
+	"github.com/pingcap/pd/server/config"
+)
+
+var _ = Suite(&testHeartbeatReplaySuite{})
+
+type testHeartbeatReplaySuite struct{}
+
+func newReplayRegionHeartbeat(id uint64, version uint64, startKey, endKey string) *pdpb.RegionHeartbeatRequest {
+	leader := &metapb.Peer{Id: id * 10, StoreId: 1}
+	return &pdpb.RegionHeartbeatRequest{
+		Region: &metapb.Region{
+			Id:          id,
+			StartKey:    []byte(startKey),
+			EndKey:      []byte(endKey),
+			RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: version},
+			Peers:       []*metapb.Peer{leader, {Id: id*10 + 1, StoreId: 2}},
+		},
+		Leader: leader,
+	}
+}
+
+func (s *testHeartbeatReplaySuite) TestReplayHeartbeats(c *C) {
+	var buf bytes.Buffer
+	w, err := heartbeatlog.NewWriter(&buf)
+	c.Assert(err, IsNil)
+	start := time.Now()
+	for _, id := range []uint64{1, 2} {
+		c.Assert(w.WriteStoreHeartbeat(start, &pdpb.StoreHeartbeatRequest{Stats: &pdpb.StoreStats{StoreId: id}}), IsNil)
+	}
+	c.Assert(w.WriteRegionHeartbeat(start, newReplayRegionHeartbeat(1, 2, "", "b")), IsNil)
+	c.Assert(w.WriteRegionHeartbeat(start, newReplayRegionHeartbeat(2, 2, "b", "")), IsNil)
+	// The region overlaps with the newer regions, so it is stale.
+	c.Assert(w.WriteRegionHeartbeat(start.Add(time.Second), newReplayRegionHeartbeat(3, 1, "a", "c")), IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	cfg := config.NewConfig()
+	c.Assert(cfg.Adjust(nil), IsNil)
+	for _, speed := range []float64{0, 10} {
+		r, err := heartbeatlog.NewReader(bytes.NewReader(buf.Bytes()))
+		c.Assert(err, IsNil)
+		stats, err := ReplayHeartbeats(context.Background(), r, cfg, speed)
+		c.Assert(err, IsNil)
+		c.Assert(stats.StoreHeartbeats, Equals, 2)
+		c.Assert(stats.RegionHeartbeats, Equals, 3)
+		c.Assert(stats.Errors, Equals, 1)
+		if speed > 0 {
+			c.Assert(stats.Elapsed >= 100*time.Millisecond, IsTrue)
+		}
+	}
+
+	// The replay stops when the context is canceled.
+	r, err := heartbeatlog.NewReader(bytes.NewReader(buf.Bytes()))
+	c.Assert(err, IsNil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	stats, err := ReplayHeartbeats(ctx, r, cfg, 0.001)
+	c.Assert(err, NotNil)
+	c.Assert(stats.RegionHeartbeats, Equals, 2)
+}
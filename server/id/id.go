@@ -31,22 +31,52 @@ type Allocator interface {
 	Alloc() (uint64, error)
 }
 
-const allocStep = uint64(1000)
+// DefaultAllocStep is the default number of IDs reserved from etcd at a time.
+const DefaultAllocStep = uint64(1000)
 
-// AllocatorImpl is used to allocate ID.
+// AllocatorImpl is used to allocate ID. IDs are allocated from a window that
+// is reserved from etcd. The next window is reserved in the background when
+// less than half of the current window remains, so most allocations do not
+// wait for etcd.
 type AllocatorImpl struct {
 	mu   sync.Mutex
 	base uint64
 	end  uint64
+	step uint64
+	// next is the end of the window that is reserved in advance, 0 if none.
+	next uint64
+	// prefetch receives the result of the background reservation. It is nil
+	// if no reservation is in flight.
+	prefetch chan prefetchResult
 
 	client   *clientv3.Client
 	rootPath string
 	member   string
 }
 
-// NewAllocatorImpl creates a new IDAllocator.
-func NewAllocatorImpl(client *clientv3.Client, rootPath string, member string) *AllocatorImpl {
-	return &AllocatorImpl{client: client, rootPath: rootPath, member: member}
+type prefetchResult struct {
+	end uint64
+	err error
+}
+
+// AllocatorStatus is the state of the allocation window.
+type AllocatorStatus struct {
+	Step      uint64 `json:"step"`
+	Base      uint64 `json:"base"`
+	End       uint64 `json:"end"`
+	Remaining uint64 `json:"remaining"`
+	// NextEnd is the end of the next window if it is reserved, or 0.
+	NextEnd     uint64 `json:"next_end"`
+	Prefetching bool   `json:"prefetching"`
+}
+
+// NewAllocatorImpl creates a new IDAllocator which reserves step IDs from etcd
+// at a time.
+func NewAllocatorImpl(client *clientv3.Client, rootPath string, member string, step uint64) *AllocatorImpl {
+	if step == 0 {
+		step = DefaultAllocStep
+	}
+	return &AllocatorImpl{client: client, rootPath: rootPath, member: member, step: step}
 }
 
 // Alloc returns a new id.
@@ -55,20 +85,82 @@ func (alloc *AllocatorImpl) Alloc() (uint64, error) {
 	defer alloc.mu.Unlock()
 
 	if alloc.base == alloc.end {
-		end, err := alloc.generate()
-		if err != nil {
-			return 0, err
+		// Wait for the reservation in flight instead of reserving another
+		// window, so that IDs keep increasing.
+		alloc.collectPrefetch(true)
+		end := alloc.next
+		alloc.next = 0
+		if end == 0 {
+			var err error
+			if end, err = alloc.generate(); err != nil {
+				return 0, err
+			}
 		}
 
 		alloc.end = end
-		alloc.base = alloc.end - allocStep
+		alloc.base = alloc.end - alloc.step
 	}
 
 	alloc.base++
+	alloc.maybePrefetch()
 
 	return alloc.base, nil
 }
 
+// maybePrefetch reserves the next window in the background if less than half
+// of the current window remains.
+func (alloc *AllocatorImpl) maybePrefetch() {
+	alloc.collectPrefetch(false)
+	if alloc.next != 0 || alloc.prefetch != nil || alloc.end-alloc.base > alloc.step/2 {
+		return
+	}
+	ch := make(chan prefetchResult, 1)
+	alloc.prefetch = ch
+	go func() {
+		end, err := alloc.generate()
+		ch <- prefetchResult{end: end, err: err}
+	}()
+}
+
+// collectPrefetch takes the result of the background reservation if it is
+// finished. It waits for the reservation if wait is true.
+func (alloc *AllocatorImpl) collectPrefetch(wait bool) {
+	if alloc.prefetch == nil {
+		return
+	}
+	var res prefetchResult
+	if wait {
+		res = <-alloc.prefetch
+	} else {
+		select {
+		case res = <-alloc.prefetch:
+		default:
+			return
+		}
+	}
+	alloc.prefetch = nil
+	if res.err != nil {
+		log.Warn("failed to reserve the next id window", zap.Error(res.err))
+		return
+	}
+	alloc.next = res.end
+}
+
+// GetStatus returns the state of the allocation window.
+func (alloc *AllocatorImpl) GetStatus() AllocatorStatus {
+	alloc.mu.Lock()
+	defer alloc.mu.Unlock()
+	alloc.collectPrefetch(false)
+	return AllocatorStatus{
+		Step:        alloc.step,
+		Base:        alloc.base,
+		End:         alloc.end,
+		Remaining:   alloc.end - alloc.base,
+		NextEnd:     alloc.next,
+		Prefetching: alloc.prefetch != nil,
+	}
+}
+
 func (alloc *AllocatorImpl) generate() (uint64, error) {
 	key := alloc.getAllocIDPath()
 	value, err := etcdutil.GetValue(alloc.client, key)
@@ -94,7 +186,7 @@ func (alloc *AllocatorImpl) generate() (uint64, error) {
 		cmp = clientv3.Compare(clientv3.Value(key), "=", string(value))
 	}
 
-	end += allocStep
+	end += alloc.step
 	value = typeutil.Uint64ToBytes(end)
 	txn := kv.NewSlowLogTxn(alloc.client)
 	leaderPath := path.Join(alloc.rootPath, "leader")
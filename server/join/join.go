@@ -24,6 +24,7 @@ import (
 	"github.com/pingcap/failpoint"
 	"github.com/pingcap/log"
 	"github.com/pingcap/pd/pkg/etcdutil"
+	"github.com/pingcap/pd/pkg/netutil"
 	"github.com/pingcap/pd/server/config"
 	"github.com/pkg/errors"
 	"go.etcd.io/etcd/clientv3"
@@ -84,7 +85,7 @@ func PrepareJoinCluster(cfg *config.Config) error {
 		return nil
 	}
 
-	if cfg.Join == cfg.AdvertiseClientUrls {
+	if isJoinSelf(cfg) {
 		return errors.New("join self is forbidden")
 	}
 
@@ -114,7 +115,7 @@ func PrepareJoinCluster(cfg *config.Config) error {
 		return err
 	}
 	client, err := clientv3.New(clientv3.Config{
-		Endpoints:   strings.Split(cfg.Join, ","),
+		Endpoints:   config.SplitUrls(cfg.Join),
 		DialTimeout: etcdutil.DefaultDialTimeout,
 		TLS:         tlsConfig,
 	})
@@ -153,7 +154,7 @@ func PrepareJoinCluster(cfg *config.Config) error {
 	// - A deleted PD joins to previous cluster.
 	{
 		// First adds member through the API
-		addResp, err = etcdutil.AddEtcdMember(client, []string{cfg.AdvertisePeerUrls})
+		addResp, err = etcdutil.AddEtcdMember(client, config.SplitUrls(cfg.AdvertisePeerUrls))
 		if err != nil {
 			return err
 		}
@@ -207,6 +208,19 @@ func PrepareJoinCluster(cfg *config.Config) error {
 	return errors.WithStack(err)
 }
 
+// isJoinSelf checks if any of the join URLs is an advertise client URL of the
+// PD itself, after normalizing the addresses.
+func isJoinSelf(cfg *config.Config) bool {
+	for _, join := range config.SplitUrls(cfg.Join) {
+		for _, advertise := range config.SplitUrls(cfg.AdvertiseClientUrls) {
+			if netutil.SameAddress(join, advertise, false) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func isDataExist(d string) bool {
 	dir, err := os.Open(d)
 	if err != nil {
@@ -0,0 +1,73 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keydecoder
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// KeyDecoder decodes the keys of regions into the logical units that the keys
+// belong to, such as the tables of TiDB. Regions should be split at the
+// boundaries of the units and should not be merged across them.
+type KeyDecoder interface {
+	// UnitStartKey returns the start key of the unit that the key belongs
+	// to. It returns nil if the key does not belong to any unit.
+	UnitStartKey(key []byte) []byte
+	// UnitBoundaries returns at most limit start keys of the units inside
+	// the range (startKey, endKey) in ascending order.
+	UnitBoundaries(startKey, endKey []byte, limit int) [][]byte
+}
+
+// RawDecoder is the decoder for keys without any known encoding. None of the
+// keys belong to a unit.
+var RawDecoder KeyDecoder = rawDecoder{}
+
+type rawDecoder struct{}
+
+func (rawDecoder) UnitStartKey(key []byte) []byte {
+	return nil
+}
+
+func (rawDecoder) UnitBoundaries(startKey, endKey []byte, limit int) [][]byte {
+	return nil
+}
+
+// CreateDecoderFunc is for creating key decoder.
+type CreateDecoderFunc func() KeyDecoder
+
+var decoderMap = make(map[string]CreateDecoderFunc)
+
+// RegisterDecoder binds a decoder creator. It should be called in init()
+// func of a package.
+func RegisterDecoder(name string, createFn CreateDecoderFunc) {
+	if _, ok := decoderMap[name]; ok {
+		panic(fmt.Sprintf("duplicated key decoder name: %v", name))
+	}
+	decoderMap[name] = createFn
+}
+
+// CreateDecoder creates a key decoder with registered creator func.
+func CreateDecoder(name string) (KeyDecoder, error) {
+	fn, ok := decoderMap[name]
+	if !ok {
+		return nil, errors.Errorf("create func of key decoder %v is not registered", name)
+	}
+	return fn(), nil
+}
+
+func init() {
+	RegisterDecoder("raw", func() KeyDecoder { return RawDecoder })
+}
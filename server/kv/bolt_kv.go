@@ -0,0 +1,108 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"bytes"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var boltBucket = []byte("pd")
+
+// BoltKV is a kv store using bbolt.
+type BoltKV struct {
+	*bolt.DB
+}
+
+// NewBoltKV is used to store regions information in a single bbolt file.
+func NewBoltKV(path string) (*BoltKV, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, errors.WithStack(err)
+	}
+	return &BoltKV{db}, nil
+}
+
+// Load gets a value for a given key. It returns an empty string if the key
+// does not exist.
+func (kv *BoltKV) Load(key string) (string, error) {
+	var value string
+	err := kv.View(func(tx *bolt.Tx) error {
+		value = string(tx.Bucket(boltBucket).Get([]byte(key)))
+		return nil
+	})
+	return value, errors.WithStack(err)
+}
+
+// LoadRange gets a range of value for a given key range.
+func (kv *BoltKV) LoadRange(startKey, endKey string, limit int) ([]string, []string, error) {
+	keys := make([]string, 0, limit)
+	values := make([]string, 0, limit)
+	err := kv.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltBucket).Cursor()
+		end := []byte(endKey)
+		for k, v := c.Seek([]byte(startKey)); k != nil && len(keys) < limit; k, v = c.Next() {
+			if len(end) > 0 && bytes.Compare(k, end) >= 0 {
+				break
+			}
+			keys = append(keys, string(k))
+			values = append(values, string(v))
+		}
+		return nil
+	})
+	return keys, values, errors.WithStack(err)
+}
+
+// Save stores a key-value pair.
+func (kv *BoltKV) Save(key, value string) error {
+	return errors.WithStack(kv.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(key), []byte(value))
+	}))
+}
+
+// Remove deletes a key-value pair for a given key.
+func (kv *BoltKV) Remove(key string) error {
+	return errors.WithStack(kv.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete([]byte(key))
+	}))
+}
+
+// SaveRegions stores some regions.
+func (kv *BoltKV) SaveRegions(regions map[string]*metapb.Region) error {
+	return errors.WithStack(kv.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		for key, r := range regions {
+			value, err := proto.Marshal(r)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(key), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
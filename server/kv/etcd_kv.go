@@ -31,6 +31,9 @@ const (
 	kvSlowRequestTime = time.Second * 1
 	requestTimeout    = 10 * time.Second
 	slowRequestTime   = 1 * time.Second
+	// MaxTxnOps is the max number of the operations in a transaction, which
+	// is set to the embedded etcd to replace its default 128.
+	MaxTxnOps = 4096
 )
 
 var (
@@ -99,6 +102,28 @@ func (kv *etcdKVBase) Save(key, value string) error {
 	return nil
 }
 
+// SaveBatch saves the key-values in one transaction.
+func (kv *etcdKVBase) SaveBatch(kvs map[string]string) error {
+	if len(kvs) > MaxTxnOps {
+		return errors.Errorf("too many keys to save in one transaction: %d, the limit is %d", len(kvs), MaxTxnOps)
+	}
+	ops := make([]clientv3.Op, 0, len(kvs))
+	for key, value := range kvs {
+		ops = append(ops, clientv3.OpPut(path.Join(kv.rootPath, key), value))
+	}
+
+	txn := NewSlowLogTxn(kv.client)
+	resp, err := txn.Then(ops...).Commit()
+	if err != nil {
+		log.Error("save batch to etcd meet error", zap.Error(err))
+		return errors.WithStack(err)
+	}
+	if !resp.Succeeded {
+		return errors.WithStack(errTxnFailed)
+	}
+	return nil
+}
+
 func (kv *etcdKVBase) Remove(key string) error {
 	key = path.Join(kv.rootPath, key)
 
@@ -87,6 +87,17 @@ func (s *testEtcdKVSuite) TestEtcdKV(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(v, Equals, "")
 
+	batch := make(map[string]string)
+	for i := 0; i < MaxTxnOps; i++ {
+		batch[fmt.Sprintf("batch/key%d", i)] = "val"
+	}
+	c.Assert(kv.SaveBatch(batch), IsNil)
+	ks, _, err = kv.LoadRange("batch/", "batch/zzz", MaxTxnOps+1)
+	c.Assert(err, IsNil)
+	c.Assert(ks, HasLen, MaxTxnOps)
+	batch["batch/overflow"] = "val"
+	c.Assert(kv.SaveBatch(batch), NotNil)
+
 	etcd.Close()
 	cleanConfig(cfg)
 }
@@ -109,6 +120,7 @@ func newTestSingleConfig() *embed.Config {
 	cfg.StrictReconfigCheck = false
 	cfg.InitialCluster = fmt.Sprintf("%s=%s", cfg.Name, &cfg.LPUrls[0])
 	cfg.ClusterState = embed.ClusterStateFlagNew
+	cfg.MaxTxnOps = MaxTxnOps
 	return cfg
 }
 
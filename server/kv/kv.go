@@ -13,6 +13,11 @@
 
 package kv
 
+import (
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pkg/errors"
+)
+
 // Base is an abstract interface for load/save pd cluster data.
 type Base interface {
 	Load(key string) (string, error)
@@ -20,3 +25,33 @@ type Base interface {
 	Save(key, value string) error
 	Remove(key string) error
 }
+
+// BatchBase is implemented by the kv bases which can save multiple keys
+// atomically.
+type BatchBase interface {
+	SaveBatch(kvs map[string]string) error
+}
+
+// The embedded backends of the region storage.
+const (
+	LeveldbBackend = "leveldb"
+	BoltBackend    = "bbolt"
+)
+
+// RegionBase is the interface of the embedded kv used to save regions.
+type RegionBase interface {
+	Base
+	SaveRegions(regions map[string]*metapb.Region) error
+	Close() error
+}
+
+// NewRegionBase opens the embedded kv of the backend at the path.
+func NewRegionBase(backend, path string) (RegionBase, error) {
+	switch backend {
+	case LeveldbBackend, "":
+		return NewLeveldbKV(path)
+	case BoltBackend:
+		return NewBoltKV(path)
+	}
+	return nil, errors.Errorf("unknown region storage backend %v", backend)
+}
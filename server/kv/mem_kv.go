@@ -69,6 +69,15 @@ func (kv *memoryKV) Save(key, value string) error {
 	return nil
 }
 
+func (kv *memoryKV) SaveBatch(kvs map[string]string) error {
+	kv.Lock()
+	defer kv.Unlock()
+	for key, value := range kvs {
+		kv.tree.ReplaceOrInsert(memoryKVItem{key, value})
+	}
+	return nil
+}
+
 func (kv *memoryKV) Remove(key string) error {
 	kv.Lock()
 	defer kv.Unlock()
@@ -0,0 +1,137 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/pkg/etcdutil"
+	"github.com/pkg/errors"
+	"go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"go.uber.org/zap"
+)
+
+// maxLeaderTransferLag is the most raft log entries which the member to
+// transfer the leadership to can fall behind the current leader.
+const maxLeaderTransferLag = 1000
+
+var statusURL = "/pd/api/v1/status"
+
+// LeaderTransferCheckCode is an error because the member to transfer the
+// leadership to fails the pre-checks.
+var LeaderTransferCheckCode = errcode.StateCode.Child("state.leader_transfer")
+
+func leaderTransferCheckErr(format string, args ...interface{}) error {
+	return errcode.NewCodedError(errors.Errorf(format, args...), LeaderTransferCheckCode)
+}
+
+// TransferLeader moves the etcd leadership, and the PD leadership with it, to
+// the member named nextLeader. It checks that the member is healthy, its raft
+// log is caught up, and its version supports the cluster version before
+// moving the leadership.
+func (s *Server) TransferLeader(ctx context.Context, nextLeader string) error {
+	listResp, err := etcdutil.ListEtcdMembers(s.client)
+	if err != nil {
+		return err
+	}
+	var target *etcdserverpb.Member
+	for _, m := range listResp.Members {
+		if m.Name == nextLeader {
+			target = m
+			break
+		}
+	}
+	if target == nil {
+		return errcode.NewNotFoundErr(errors.Errorf("pd %s is not found", nextLeader))
+	}
+	if target.ID == s.member.ID() {
+		return errcode.NewInvalidInputErr(errors.Errorf("pd %s is already the leader", nextLeader))
+	}
+	if len(target.ClientURLs) == 0 {
+		return leaderTransferCheckErr("pd %s has not started yet", nextLeader)
+	}
+	if err := s.checkTransferTarget(ctx, target); err != nil {
+		return err
+	}
+	log.Info("transfer leader", zap.String("from", s.Name()), zap.String("to", nextLeader))
+	return s.member.MoveEtcdLeader(ctx, s.member.ID(), target.ID)
+}
+
+func (s *Server) checkTransferTarget(ctx context.Context, target *etcdserverpb.Member) error {
+	for _, u := range target.ClientURLs {
+		resp, err := dialClient.Get(u + healthURL)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if err != nil || resp.StatusCode != http.StatusOK {
+			return leaderTransferCheckErr("pd %s is not healthy", target.Name)
+		}
+	}
+
+	statusCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	leaderStatus, err := s.client.Status(statusCtx, s.member.Member().GetClientUrls()[0])
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	targetStatus, err := s.client.Status(statusCtx, target.ClientURLs[0])
+	if err != nil {
+		return leaderTransferCheckErr("failed to get the etcd status of pd %s: %v", target.Name, err)
+	}
+	if leaderStatus.RaftIndex > targetStatus.RaftIndex+maxLeaderTransferLag {
+		return leaderTransferCheckErr("the raft log of pd %s falls behind by %d entries",
+			target.Name, leaderStatus.RaftIndex-targetStatus.RaftIndex)
+	}
+
+	version, err := getMemberVersion(target.ClientURLs[0])
+	if err != nil {
+		return leaderTransferCheckErr("failed to get the version of pd %s: %v", target.Name, err)
+	}
+	// The version of a build without the release version is unknown.
+	if version != nil && version.LessThan(s.GetClusterVersion()) {
+		return leaderTransferCheckErr("the version %s of pd %s is older than the cluster version %s",
+			version, target.Name, s.GetClusterVersion())
+	}
+	return nil
+}
+
+func getMemberVersion(clientURL string) (*semver.Version, error) {
+	resp, err := dialClient.Get(clientURL + statusURL)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status %s", resp.Status)
+	}
+	var status struct {
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if status.Version == "" || status.Version == "None" {
+		return nil, nil
+	}
+	version, err := ParseVersion(status.Version)
+	if err != nil {
+		return nil, errors.Errorf("invalid version %q: %v", status.Version, err)
+	}
+	return version, nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// storeLearnerLagsKey is the gRPC metadata key for stores to report the
+	// applied index lags of the learners in the regions they lead with store
+	// heartbeats, since the heartbeats have no field for them. The lags are a
+	// comma separated list of "<region-id>:<peer-id>:<lag>".
+	storeLearnerLagsKey = "pd-store-learner-lags"
+	// maxStoreLearnerLags bounds the lags taken from a heartbeat, since the
+	// metadata is not trusted.
+	maxStoreLearnerLags = 1 << 16
+)
+
+// storeLearnerLagsFromContext returns the learner lags in the metadata of the
+// store heartbeat keyed by region ID and peer ID. The malformed entries are
+// ignored. It returns false if the store does not report the lags, in which
+// case the lags reported before are kept.
+func storeLearnerLagsFromContext(ctx context.Context) (map[uint64]map[uint64]uint64, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	values := md.Get(storeLearnerLagsKey)
+	if len(values) == 0 {
+		return nil, false
+	}
+	lags := make(map[uint64]map[uint64]uint64)
+	var count int
+	for _, value := range values {
+		for _, entry := range strings.Split(value, ",") {
+			if count >= maxStoreLearnerLags {
+				return lags, true
+			}
+			fields := strings.Split(strings.TrimSpace(entry), ":")
+			if len(fields) != 3 {
+				continue
+			}
+			regionID, err1 := strconv.ParseUint(fields[0], 10, 64)
+			peerID, err2 := strconv.ParseUint(fields[1], 10, 64)
+			lag, err3 := strconv.ParseUint(fields[2], 10, 64)
+			if err1 != nil || err2 != nil || err3 != nil {
+				continue
+			}
+			if lags[regionID] == nil {
+				lags[regionID] = make(map[uint64]uint64)
+			}
+			lags[regionID][peerID] = lag
+			count++
+		}
+	}
+	return lags, true
+}
+
+// reportedLearnerLags is the learner lags of a region reported by a store.
+type reportedLearnerLags struct {
+	storeID uint64
+	lags    map[uint64]uint64
+}
+
+// learnerLags records the learner lags reported by the stores, which are
+// attached to the regions by their heartbeats.
+type learnerLags struct {
+	sync.RWMutex
+	regions map[uint64]*reportedLearnerLags
+}
+
+func (l *learnerLags) reset() {
+	l.Lock()
+	defer l.Unlock()
+	l.regions = make(map[uint64]*reportedLearnerLags)
+}
+
+// update replaces the lags reported by the store. The regions the store does
+// not report any longer, such as after their leaders are transferred, are
+// dropped.
+func (l *learnerLags) update(storeID uint64, lags map[uint64]map[uint64]uint64) {
+	l.Lock()
+	defer l.Unlock()
+	for regionID, reported := range l.regions {
+		if _, ok := lags[regionID]; !ok && reported.storeID == storeID {
+			delete(l.regions, regionID)
+		}
+	}
+	for regionID, peerLags := range lags {
+		l.regions[regionID] = &reportedLearnerLags{storeID: storeID, lags: peerLags}
+	}
+}
+
+// get returns the learner lags of the region reported by the store of its
+// leader, or nil if the leader does not report them.
+func (l *learnerLags) get(regionID, leaderStoreID uint64) map[uint64]uint64 {
+	l.RLock()
+	defer l.RUnlock()
+	reported, ok := l.regions[regionID]
+	if !ok || reported.storeID != leaderStoreID {
+		return nil
+	}
+	return reported.lags
+}
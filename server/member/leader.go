@@ -19,7 +19,6 @@ import (
 	"math/rand"
 	"path"
 	"strconv"
-	"strings"
 	"sync/atomic"
 	"time"
 
@@ -46,6 +45,9 @@ const (
 // Member is used for the election related logic.
 type Member struct {
 	leader atomic.Value
+	// prevLeader is the last leader watched by this member, it is used to
+	// check whether the previous leader stepped down cleanly.
+	prevLeader atomic.Value
 	// Etcd and cluster information.
 	etcd     *embed.Etcd
 	client   *clientv3.Client
@@ -111,6 +113,15 @@ func (m *Member) GetLeader() *pdpb.Member {
 	return member
 }
 
+// GetPrevLeader returns the last leader that this member has watched.
+func (m *Member) GetPrevLeader() *pdpb.Member {
+	leader := m.prevLeader.Load()
+	if leader == nil {
+		return nil
+	}
+	return leader.(*pdpb.Member)
+}
+
 // EnableLeader sets the member to leader.
 func (m *Member) EnableLeader() {
 	m.leader.Store(m.member)
@@ -218,8 +229,8 @@ func (m *Member) MemberInfo(cfg *config.Config, name string, rootPath string) {
 	leader := &pdpb.Member{
 		Name:       name,
 		MemberId:   m.ID(),
-		ClientUrls: strings.Split(cfg.AdvertiseClientUrls, ","),
-		PeerUrls:   strings.Split(cfg.AdvertisePeerUrls, ","),
+		ClientUrls: config.SplitUrls(cfg.AdvertiseClientUrls),
+		PeerUrls:   config.SplitUrls(cfg.AdvertisePeerUrls),
 	}
 
 	data, err := leader.Marshal()
@@ -287,6 +298,63 @@ func (m *Member) getMemberLeaderPriorityPath(id uint64) string {
 	return path.Join(m.rootPath, fmt.Sprintf("member/%d/leader_priority", id))
 }
 
+// memberRootsPath records the cluster roots of the members. It is outside the
+// root prefixes, so that it is shared by the PD clusters sharing the etcd.
+const memberRootsPath = "/pd_member_roots"
+
+func getMemberRootPath(id uint64) string {
+	return path.Join(memberRootsPath, strconv.FormatUint(id, 10))
+}
+
+// RegisterMember records the cluster root of the member, so that it can be
+// told from the members of other PD clusters sharing the same etcd.
+func (m *Member) RegisterMember() error {
+	ctx, cancel := context.WithTimeout(m.client.Ctx(), requestTimeout)
+	defer cancel()
+	_, err := m.client.Put(ctx, getMemberRootPath(m.ID()), m.rootPath)
+	return errors.WithStack(err)
+}
+
+// GetMemberRoot returns the cluster root registered by the member, or an empty
+// string if it is not registered.
+func (m *Member) GetMemberRoot(id uint64) (string, error) {
+	resp, err := etcdutil.EtcdKVGet(m.client, getMemberRootPath(id))
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Kvs) == 0 {
+		return "", nil
+	}
+	return string(resp.Kvs[0].Value), nil
+}
+
+// IsClusterMember checks if the etcd member belongs to the PD cluster. The
+// members not registered, such as the ones of older versions during a rolling
+// upgrade, are considered to belong to it as before.
+func (m *Member) IsClusterMember(id uint64) (bool, error) {
+	if id == m.ID() {
+		return true, nil
+	}
+	root, err := m.GetMemberRoot(id)
+	if err != nil {
+		return false, err
+	}
+	return root == "" || root == m.rootPath, nil
+}
+
+// UnregisterMember removes the record of a member leaving the PD cluster.
+func (m *Member) UnregisterMember(id uint64) error {
+	key := getMemberRootPath(id)
+	res, err := m.LeaderTxn().Then(clientv3.OpDelete(key)).Commit()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if !res.Succeeded {
+		return errors.New("unregister member failed, maybe not leader")
+	}
+	return nil
+}
+
 // SetMemberLeaderPriority saves a member's priority to be elected as the etcd leader.
 func (m *Member) SetMemberLeaderPriority(id uint64, priority int) error {
 	key := m.getMemberLeaderPriorityPath(id)
@@ -351,6 +419,7 @@ func (m *Member) leaderCmp() clientv3.Cmp {
 // WatchLeader is used to watch the changes of the leader.
 func (m *Member) WatchLeader(serverCtx context.Context, leader *pdpb.Member, revision int64) {
 	m.leader.Store(leader)
+	m.prevLeader.Store(leader)
 	defer m.leader.Store(&pdpb.Member{})
 
 	watcher := clientv3.NewWatcher(m.client)
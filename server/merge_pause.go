@@ -0,0 +1,232 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/pkg/typeutil"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// MergePause suspends merging the regions in a key range, and optionally
+// splitting them, such as while the SQL layer runs DDL or ingests data into a
+// table. It is held by a lease, which the owner renews by heartbeats, and is
+// released once the lease lapses, so that a crashed owner does not block
+// merges forever. It is persisted so that it stays after the leader changes.
+// The keys are hex encoded.
+type MergePause struct {
+	ID       uint64 `json:"id"`
+	Owner    string `json:"owner"`
+	Reason   string `json:"reason"`
+	StartKey string `json:"start_key"`
+	EndKey   string `json:"end_key"`
+	// PauseSplit also rejects the stores asking to split the regions.
+	PauseSplit bool              `json:"pause_split"`
+	Lease      typeutil.Duration `json:"lease"`
+	PauseTime  time.Time         `json:"pause_time"`
+	// ExpireTime is when the pause is released unless it is renewed.
+	ExpireTime time.Time `json:"expire_time"`
+
+	startKey, endKey []byte
+}
+
+func (p *MergePause) isExpired(now time.Time) bool {
+	return !now.Before(p.ExpireTime)
+}
+
+// overlaps checks if the pause overlaps [startKey, endKey). An empty end key
+// means the end of the key space.
+func (p *MergePause) overlaps(startKey, endKey []byte) bool {
+	return (len(endKey) == 0 || bytes.Compare(p.startKey, endKey) < 0) &&
+		bytes.Compare(startKey, p.endKey) < 0
+}
+
+// mergePauser keeps the pauses apart from the lock of the cluster, since they
+// are checked by the merge checker with the region heartbeats.
+type mergePauser struct {
+	sync.RWMutex
+	pauses map[uint64]*MergePause
+}
+
+func (c *RaftCluster) loadMergePauses() error {
+	pauses := make(map[uint64]*MergePause)
+	if err := c.storage.LoadMergePauses(func(value string) error {
+		p := &MergePause{}
+		if err := json.Unmarshal([]byte(value), p); err != nil {
+			return errors.WithStack(err)
+		}
+		p.startKey, _ = hex.DecodeString(p.StartKey)
+		p.endKey, _ = hex.DecodeString(p.EndKey)
+		pauses[p.ID] = p
+		return nil
+	}); err != nil {
+		return err
+	}
+	c.mergePauser.Lock()
+	defer c.mergePauser.Unlock()
+	c.mergePauser.pauses = pauses
+	return nil
+}
+
+// PauseMerge suspends merging the regions in [startKey, endKey), and
+// splitting them if pauseSplit is true, until the lease lapses without being
+// renewed by RenewMergePause.
+func (c *RaftCluster) PauseMerge(owner, reason string, startKey, endKey []byte, pauseSplit bool, lease time.Duration) (*MergePause, error) {
+	if lease <= 0 {
+		return nil, errcode.NewInvalidInputErr(errors.New("the lease of the merge pause is required"))
+	}
+	if len(endKey) == 0 || bytes.Compare(startKey, endKey) >= 0 {
+		return nil, errcode.NewInvalidInputErr(errors.New("start key should be less than end key"))
+	}
+	id, err := c.id.Alloc()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	p := &MergePause{
+		ID:         id,
+		Owner:      owner,
+		Reason:     reason,
+		StartKey:   hex.EncodeToString(startKey),
+		EndKey:     hex.EncodeToString(endKey),
+		PauseSplit: pauseSplit,
+		Lease:      typeutil.NewDuration(lease),
+		PauseTime:  now,
+		ExpireTime: now.Add(lease),
+		startKey:   startKey,
+		endKey:     endKey,
+	}
+	c.mergePauser.Lock()
+	defer c.mergePauser.Unlock()
+	if err := c.storage.SaveMergePause(id, p); err != nil {
+		return nil, err
+	}
+	c.mergePauser.pauses[id] = p
+	log.Info("merge is paused",
+		zap.Uint64("pause-id", id),
+		zap.String("owner", owner),
+		zap.String("reason", reason),
+		zap.String("start-key", p.StartKey),
+		zap.String("end-key", p.EndKey),
+		zap.Bool("pause-split", pauseSplit),
+		zap.Duration("lease", lease))
+	return p, nil
+}
+
+// RenewMergePause extends the lease of the pause from now. The pause whose
+// lease has lapsed cannot be renewed, since merges may have happened.
+func (c *RaftCluster) RenewMergePause(id uint64) (*MergePause, error) {
+	c.mergePauser.Lock()
+	defer c.mergePauser.Unlock()
+	now := time.Now()
+	old, ok := c.mergePauser.pauses[id]
+	if !ok || old.isExpired(now) {
+		return nil, errcode.NewNotFoundErr(errors.Errorf("merge pause %d not found", id))
+	}
+	// The pause is replaced rather than modified, since it may be being read.
+	p := *old
+	p.ExpireTime = now.Add(p.Lease.Duration)
+	if err := c.storage.SaveMergePause(id, &p); err != nil {
+		return nil, err
+	}
+	c.mergePauser.pauses[id] = &p
+	return &p, nil
+}
+
+// ResumeMerge removes the pause added by PauseMerge.
+func (c *RaftCluster) ResumeMerge(id uint64) error {
+	c.mergePauser.Lock()
+	defer c.mergePauser.Unlock()
+	if _, ok := c.mergePauser.pauses[id]; !ok {
+		return errcode.NewNotFoundErr(errors.Errorf("merge pause %d not found", id))
+	}
+	return c.removeMergePauseLocked(id)
+}
+
+func (c *RaftCluster) removeMergePauseLocked(id uint64) error {
+	if err := c.storage.DeleteMergePause(id); err != nil {
+		return err
+	}
+	delete(c.mergePauser.pauses, id)
+	log.Info("merge is resumed", zap.Uint64("pause-id", id))
+	return nil
+}
+
+// GetMergePauses returns the pauses in effect in the order of IDs.
+func (c *RaftCluster) GetMergePauses() []*MergePause {
+	c.mergePauser.RLock()
+	defer c.mergePauser.RUnlock()
+	now := time.Now()
+	pauses := make([]*MergePause, 0, len(c.mergePauser.pauses))
+	for _, p := range c.mergePauser.pauses {
+		if !p.isExpired(now) {
+			pauses = append(pauses, p)
+		}
+	}
+	sort.Slice(pauses, func(i, j int) bool { return pauses[i].ID < pauses[j].ID })
+	return pauses
+}
+
+// IsMergePaused returns if merging the regions in [startKey, endKey) is
+// paused. The expired pauses take no effect even before they are removed by
+// checkMergePauses.
+func (c *RaftCluster) IsMergePaused(startKey, endKey []byte) bool {
+	return c.isRangePaused(startKey, endKey, false)
+}
+
+// isSplitPaused returns if splitting the regions in [startKey, endKey) is
+// paused.
+func (c *RaftCluster) isSplitPaused(startKey, endKey []byte) bool {
+	return c.isRangePaused(startKey, endKey, true)
+}
+
+func (c *RaftCluster) isRangePaused(startKey, endKey []byte, split bool) bool {
+	c.mergePauser.RLock()
+	defer c.mergePauser.RUnlock()
+	now := time.Now()
+	for _, p := range c.mergePauser.pauses {
+		if (!split || p.PauseSplit) && !p.isExpired(now) && p.overlaps(startKey, endKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMergePauses removes the pauses whose leases have lapsed.
+func (c *RaftCluster) checkMergePauses() {
+	c.mergePauser.Lock()
+	defer c.mergePauser.Unlock()
+	now := time.Now()
+	for id, p := range c.mergePauser.pauses {
+		if !p.isExpired(now) {
+			continue
+		}
+		log.Warn("the lease of merge pause lapses",
+			zap.Uint64("pause-id", id),
+			zap.String("owner", p.Owner),
+			zap.String("reason", p.Reason))
+		if err := c.removeMergePauseLocked(id); err != nil {
+			log.Error("failed to remove expired merge pause", zap.Uint64("pause-id", id), zap.Error(err))
+		}
+	}
+}
@@ -56,6 +56,14 @@ var (
 			Help:      "Counter of the region event",
 		}, []string{"event"})
 
+	regionCacheDivergenceGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "region_cache_divergent_shards",
+			Help:      "The number of the shards of the region cache of a follower diverging from the leader.",
+		}, []string{"member"})
+
 	regionHeartbeatLatency = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Namespace: "pd",
@@ -98,6 +106,87 @@ var (
 			Buckets:   prometheus.ExponentialBuckets(1, 2, 15),
 		})
 
+	patrolShardCheckRegionsHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "pd",
+			Subsystem: "patrol",
+			Name:      "shard_checks_regions",
+			Help:      "Bucketed histogram of time spend(s) of patrol checks region in each shard.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 15),
+		}, []string{"shard"})
+
+	schedulingHaltedGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "scheduler",
+			Name:      "halted",
+			Help:      "Whether scheduling is halted because the cluster is unstable or it is paused by users.",
+		})
+
+	heartbeatIntervalGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "server",
+			Name:      "recommended_heartbeat_interval_seconds",
+			Help:      "The heartbeat intervals recommended to stores.",
+		}, []string{"type"})
+
+	heartbeatAdmissionGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "server",
+			Name:      "heartbeat_admission",
+			Help:      "The load of PD observed by the heartbeat admission control.",
+		}, []string{"type"})
+
+	regionHeartbeatShedCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "server",
+			Name:      "region_heartbeat_shed_total",
+			Help:      "Counter of region heartbeats shed because PD is overloaded.",
+		})
+
+	checkerInvocationCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "checker",
+			Name:      "invocation_total",
+			Help:      "Counter of checker invocations.",
+		}, []string{"checker"})
+
+	checkerOperatorCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "checker",
+			Name:      "new_operator_total",
+			Help:      "Counter of operators generated by checkers.",
+		}, []string{"checker"})
+
+	regionFragmentationGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "region_fragmentation",
+			Help:      "Fragmentation of regions, the regions that merging could remove.",
+		}, []string{"type"})
+
+	balanceIndexGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "balance_index",
+			Help:      "Imbalance of the stores, the coefficient of variation of each dimension.",
+		}, []string{"type"})
+
+	storeAuthFailedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "store_auth_failed_total",
+			Help:      "Counter of the store requests failing the authentication.",
+		}, []string{"store"})
+
 	tsoHandleDuration = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
 			Namespace: "pd",
@@ -106,10 +195,67 @@ var (
 			Help:      "Bucketed histogram of processing time (s) of handled tso requests.",
 			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 13),
 		})
+
+	grpcRequestCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "server",
+			Name:      "grpc_requests_total",
+			Help:      "Counter of the grpc requests and streams by the caller components.",
+		}, []string{"method", "caller"})
+
+	grpcSlowRequestCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "server",
+			Name:      "grpc_slow_requests_total",
+			Help:      "Counter of the grpc requests slower than the threshold.",
+		}, []string{"method", "caller"})
+
+	unknownStoreHeartbeatCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "server",
+			Name:      "unknown_store_heartbeats_total",
+			Help:      "Counter of the heartbeats from the stores not registered in the cluster.",
+		}, []string{"type", "action"})
+
+	staleStoreEntriesCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "server",
+			Name:      "stale_store_entries_cleaned_total",
+			Help:      "Counter of the cleaned entries referencing the removed stores.",
+		}, []string{"type"})
+
+	scheduleSpeedScaleGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "scheduler",
+			Name:      "speed_scale",
+			Help:      "Scale of the schedule limits adjusted by the governor or overridden by users.",
+		}, []string{"type"})
+
+	statsMemoryGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "server",
+			Name:      "stats_memory_bytes",
+			Help:      "Estimated memory of the statistics histories bounded by the budget.",
+		}, []string{"type"})
+
+	grpcPanicCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "server",
+			Name:      "grpc_panics_total",
+			Help:      "Counter of the recovered panics of the grpc requests.",
+		}, []string{"method"})
 )
 
 func init() {
 	prometheus.MustRegister(timeJumpBackCounter)
+	prometheus.MustRegister(regionCacheDivergenceGauge)
 	prometheus.MustRegister(schedulerStatusGauge)
 	prometheus.MustRegister(regionHeartbeatCounter)
 	prometheus.MustRegister(regionEventCounter)
@@ -118,5 +264,22 @@ func init() {
 	prometheus.MustRegister(metadataGauge)
 	prometheus.MustRegister(etcdStateGauge)
 	prometheus.MustRegister(patrolCheckRegionsHistogram)
+	prometheus.MustRegister(patrolShardCheckRegionsHistogram)
 	prometheus.MustRegister(tsoHandleDuration)
+	prometheus.MustRegister(schedulingHaltedGauge)
+	prometheus.MustRegister(heartbeatIntervalGauge)
+	prometheus.MustRegister(heartbeatAdmissionGauge)
+	prometheus.MustRegister(regionHeartbeatShedCounter)
+	prometheus.MustRegister(checkerInvocationCounter)
+	prometheus.MustRegister(checkerOperatorCounter)
+	prometheus.MustRegister(regionFragmentationGauge)
+	prometheus.MustRegister(balanceIndexGauge)
+	prometheus.MustRegister(storeAuthFailedCounter)
+	prometheus.MustRegister(grpcRequestCounter)
+	prometheus.MustRegister(grpcSlowRequestCounter)
+	prometheus.MustRegister(grpcPanicCounter)
+	prometheus.MustRegister(scheduleSpeedScaleGauge)
+	prometheus.MustRegister(unknownStoreHeartbeatCounter)
+	prometheus.MustRegister(staleStoreEntriesCounter)
+	prometheus.MustRegister(statsMemoryGauge)
 }
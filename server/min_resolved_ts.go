@@ -0,0 +1,161 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/server/core"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// storeMinResolvedTSKey is the gRPC metadata key for stores to report the
+	// minimal resolved timestamp of their regions with store heartbeats, since
+	// the stats have no field for it.
+	storeMinResolvedTSKey = "pd-store-min-resolved-ts"
+	// minResolvedTSHeaderKey is the gRPC metadata key of the cluster-wide
+	// minimal resolved timestamp in the headers of the store heartbeat
+	// responses, since there is no RPC to serve it.
+	minResolvedTSHeaderKey = "pd-min-resolved-ts"
+)
+
+// storeMinResolvedTSFromContext returns the minimal resolved timestamp in the
+// metadata of the store heartbeat. It returns false if the timestamp is
+// missing or malformed.
+func storeMinResolvedTSFromContext(ctx context.Context) (uint64, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return 0, false
+	}
+	values := md.Get(storeMinResolvedTSKey)
+	if len(values) == 0 {
+		return 0, false
+	}
+	ts, err := strconv.ParseUint(values[0], 10, 64)
+	return ts, err == nil && ts > 0
+}
+
+// resolvedTSTracker records the minimal resolved timestamps of the stores
+// and the cluster-wide one. It has its own lock, so that the store heartbeats
+// reporting them do not take the cluster lock.
+type resolvedTSTracker struct {
+	sync.Mutex
+	stores map[uint64]uint64
+	// minTS is the cluster-wide minimal resolved timestamp, and savedTS is
+	// the one in the storage.
+	minTS   uint64
+	savedTS uint64
+}
+
+func (t *resolvedTSTracker) reset() {
+	t.Lock()
+	defer t.Unlock()
+	t.stores = make(map[uint64]uint64)
+	t.minTS, t.savedTS = 0, 0
+}
+
+// loadMinResolvedTS loads the cluster-wide minimal resolved timestamp saved
+// by the former leaders.
+func (c *RaftCluster) loadMinResolvedTS() error {
+	ts, err := c.storage.LoadMinResolvedTS()
+	if err != nil {
+		return err
+	}
+	c.resolvedTS.Lock()
+	defer c.resolvedTS.Unlock()
+	c.resolvedTS.minTS, c.resolvedTS.savedTS = ts, ts
+	return nil
+}
+
+// SetStoreMinResolvedTS records the minimal resolved timestamp of the regions
+// in the store, and advances the cluster-wide one. The timestamp of a store
+// never goes backward.
+func (c *RaftCluster) SetStoreMinResolvedTS(storeID uint64, ts uint64) error {
+	if c.GetStore(storeID) == nil {
+		return core.NewStoreNotFoundErr(storeID)
+	}
+	c.resolvedTS.Lock()
+	defer c.resolvedTS.Unlock()
+	if ts <= c.resolvedTS.stores[storeID] {
+		return nil
+	}
+	c.resolvedTS.stores[storeID] = ts
+	c.advanceMinResolvedTSLocked()
+	return nil
+}
+
+// advanceMinResolvedTSLocked sets the cluster-wide minimal resolved timestamp
+// to the minimal one of the stores which are not tombstone, if it is larger.
+// A store which has not reported the timestamp holds it back.
+func (c *RaftCluster) advanceMinResolvedTSLocked() {
+	minTS := uint64(math.MaxUint64)
+	for _, store := range c.core.GetStores() {
+		if store.IsTombstone() {
+			continue
+		}
+		if ts := c.resolvedTS.stores[store.GetID()]; ts < minTS {
+			minTS = ts
+		}
+	}
+	if minTS != math.MaxUint64 && minTS > c.resolvedTS.minTS {
+		c.resolvedTS.minTS = minTS
+	}
+}
+
+// GetMinResolvedTS returns the cluster-wide minimal resolved timestamp, before
+// which all the data of the cluster can be read consistently. It stays 0 until
+// all the stores have reported their timestamps.
+func (c *RaftCluster) GetMinResolvedTS() uint64 {
+	c.resolvedTS.Lock()
+	defer c.resolvedTS.Unlock()
+	return c.resolvedTS.minTS
+}
+
+// minResolvedTSPairs returns the gRPC metadata of the cluster-wide minimal
+// resolved timestamp for the headers of the store heartbeat responses, or nil
+// if it is not known yet.
+func (c *RaftCluster) minResolvedTSPairs() []string {
+	ts := c.GetMinResolvedTS()
+	if ts == 0 {
+		return nil
+	}
+	return []string{minResolvedTSHeaderKey, strconv.FormatUint(ts, 10)}
+}
+
+// saveMinResolvedTS persists the cluster-wide minimal resolved timestamp if it
+// has advanced, so that it does not go backward after the leader changes.
+func (c *RaftCluster) saveMinResolvedTS() {
+	c.resolvedTS.Lock()
+	ts := c.resolvedTS.minTS
+	saved := c.resolvedTS.savedTS
+	c.resolvedTS.Unlock()
+	if ts == saved {
+		return
+	}
+	if err := c.storage.SaveMinResolvedTS(ts); err != nil {
+		log.Error("failed to save min resolved ts", zap.Uint64("min-resolved-ts", ts), zap.Error(err))
+		return
+	}
+	c.resolvedTS.Lock()
+	if ts > c.resolvedTS.savedTS {
+		c.resolvedTS.savedTS = ts
+	}
+	c.resolvedTS.Unlock()
+}
@@ -0,0 +1,72 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package namespace
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/id"
+	"github.com/pkg/errors"
+)
+
+const (
+	// callbackURLArg is the argument of the URL which returns the namespaces
+	// as a JSON array of KeyPrefixNamespace.
+	callbackURLArg = "url"
+	// callbackTimeoutArg is the argument of the timeout to request the URL.
+	callbackTimeoutArg     = "timeout"
+	defaultCallbackTimeout = 3 * time.Second
+)
+
+func init() {
+	RegisterClassifier("http", func(_ *core.Storage, _ id.Allocator, args map[string]string) (Classifier, error) {
+		return NewHTTPCallbackClassifier(args)
+	})
+}
+
+// NewHTTPCallbackClassifier creates a key prefix classifier with namespaces
+// fetched from an external HTTP service. The namespaces are fetched again
+// when the classifier is reloaded.
+func NewHTTPCallbackClassifier(args map[string]string) (Classifier, error) {
+	url := args[callbackURLArg]
+	if url == "" {
+		return nil, errors.Errorf("argument %v of http classifier is required", callbackURLArg)
+	}
+	timeout := defaultCallbackTimeout
+	if v, ok := args[callbackTimeoutArg]; ok {
+		var err error
+		if timeout, err = time.ParseDuration(v); err != nil {
+			return nil, errors.Wrapf(err, "invalid timeout %v of http classifier", v)
+		}
+	}
+	client := &http.Client{Timeout: timeout}
+	return NewLoadableKeyPrefixClassifier(args[storeLabelKeyArg], func() ([]*KeyPrefixNamespace, error) {
+		resp, err := client.Get(url)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, errors.Errorf("failed to load namespaces from %v, status: %v", url, resp.Status)
+		}
+		var nss []*KeyPrefixNamespace
+		if err := json.NewDecoder(resp.Body).Decode(&nss); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return nss, nil
+	})
+}
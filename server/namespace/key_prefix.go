@@ -0,0 +1,241 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package namespace
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/id"
+	"github.com/pkg/errors"
+)
+
+const (
+	// storeLabelKeyArg is the argument of the label key whose value is the
+	// namespace of a store.
+	storeLabelKeyArg     = "store-label-key"
+	defaultStoreLabelKey = "namespace"
+	// keyPrefixArgPrefix is the prefix of arguments defining namespaces. For
+	// example, "prefix.ns1" = "7480,7481" binds hex encoded key prefixes 7480
+	// and 7481 to namespace ns1.
+	keyPrefixArgPrefix = "prefix."
+)
+
+func init() {
+	RegisterClassifier("key-prefix", func(_ *core.Storage, _ id.Allocator, args map[string]string) (Classifier, error) {
+		return NewKeyPrefixClassifier(args)
+	})
+}
+
+// KeyPrefixNamespace binds regions with key prefixes and stores to a namespace.
+type KeyPrefixNamespace struct {
+	Name string `json:"name"`
+	// KeyPrefixes are hex encoded key prefixes of regions.
+	KeyPrefixes []string `json:"key-prefixes"`
+	StoreIDs    []uint64 `json:"store-ids"`
+}
+
+// LoadKeyPrefixNamespacesFunc loads the namespaces from the backing data of a
+// classifier.
+type LoadKeyPrefixNamespacesFunc func() ([]*KeyPrefixNamespace, error)
+
+type keyPrefixRange struct {
+	namespace string
+	prefix    []byte
+	end       []byte
+}
+
+type keyPrefixNamespaces struct {
+	names  map[string]struct{}
+	ranges []keyPrefixRange
+	stores map[uint64]string
+}
+
+func newKeyPrefixNamespaces(nss []*KeyPrefixNamespace) (*keyPrefixNamespaces, error) {
+	res := &keyPrefixNamespaces{
+		names:  make(map[string]struct{}),
+		stores: make(map[uint64]string),
+	}
+	for _, ns := range nss {
+		if ns.Name == "" || ns.Name == DefaultNamespace {
+			return nil, errors.Errorf("invalid namespace name %q", ns.Name)
+		}
+		if _, ok := res.names[ns.Name]; ok {
+			return nil, errors.Errorf("duplicated namespace %v", ns.Name)
+		}
+		res.names[ns.Name] = struct{}{}
+		for _, p := range ns.KeyPrefixes {
+			prefix, err := hex.DecodeString(p)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid key prefix %v of namespace %v", p, ns.Name)
+			}
+			res.ranges = append(res.ranges, keyPrefixRange{namespace: ns.Name, prefix: prefix, end: prefixEnd(prefix)})
+		}
+		for _, storeID := range ns.StoreIDs {
+			res.stores[storeID] = ns.Name
+		}
+	}
+	return res, nil
+}
+
+// prefixEnd returns the smallest key that is larger than all keys with the
+// prefix. It returns nil if there is no such key.
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+func (nss *keyPrefixNamespaces) getRegionNamespace(startKey, endKey []byte) string {
+	for _, r := range nss.ranges {
+		if !bytes.HasPrefix(startKey, r.prefix) {
+			continue
+		}
+		if len(r.end) == 0 || (len(endKey) > 0 && bytes.Compare(endKey, r.end) <= 0) {
+			return r.namespace
+		}
+	}
+	return DefaultNamespace
+}
+
+// keyPrefixClassifier classifies regions by key prefixes, and classifies
+// stores by store IDs or a store label.
+type keyPrefixClassifier struct {
+	sync.RWMutex
+	labelKey   string
+	load       LoadKeyPrefixNamespacesFunc
+	namespaces *keyPrefixNamespaces
+}
+
+// NewKeyPrefixClassifier creates a classifier with namespaces defined by the
+// arguments.
+func NewKeyPrefixClassifier(args map[string]string) (Classifier, error) {
+	var nss []*KeyPrefixNamespace
+	for k, v := range args {
+		if !strings.HasPrefix(k, keyPrefixArgPrefix) {
+			continue
+		}
+		ns := &KeyPrefixNamespace{Name: strings.TrimPrefix(k, keyPrefixArgPrefix)}
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				ns.KeyPrefixes = append(ns.KeyPrefixes, p)
+			}
+		}
+		nss = append(nss, ns)
+	}
+	sort.Slice(nss, func(i, j int) bool { return nss[i].Name < nss[j].Name })
+	return NewLoadableKeyPrefixClassifier(args[storeLabelKeyArg], func() ([]*KeyPrefixNamespace, error) {
+		return nss, nil
+	})
+}
+
+// NewLoadableKeyPrefixClassifier creates a key prefix classifier with
+// namespaces loaded by the load func. The namespaces are loaded again when
+// ReloadNamespaces is called, so classifiers with external backing data can
+// be reloaded.
+func NewLoadableKeyPrefixClassifier(labelKey string, load LoadKeyPrefixNamespacesFunc) (Classifier, error) {
+	if labelKey == "" {
+		labelKey = defaultStoreLabelKey
+	}
+	c := &keyPrefixClassifier{
+		labelKey: labelKey,
+		load:     load,
+	}
+	if err := c.ReloadNamespaces(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *keyPrefixClassifier) getNamespaces() *keyPrefixNamespaces {
+	c.RLock()
+	defer c.RUnlock()
+	return c.namespaces
+}
+
+func (c *keyPrefixClassifier) GetAllNamespaces() []string {
+	nss := c.getNamespaces()
+	names := make([]string, 0, len(nss.names)+1)
+	for name := range nss.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append(names, DefaultNamespace)
+}
+
+func (c *keyPrefixClassifier) GetStoreNamespace(store *core.StoreInfo) string {
+	nss := c.getNamespaces()
+	if name, ok := nss.stores[store.GetID()]; ok {
+		return name
+	}
+	if name := store.GetLabelValue(c.labelKey); name != "" {
+		if _, ok := nss.names[name]; ok {
+			return name
+		}
+	}
+	return DefaultNamespace
+}
+
+func (c *keyPrefixClassifier) GetRegionNamespace(region *core.RegionInfo) string {
+	return c.getNamespaces().getRegionNamespace(region.GetStartKey(), region.GetEndKey())
+}
+
+func (c *keyPrefixClassifier) IsNamespaceExist(name string) bool {
+	if name == DefaultNamespace {
+		return true
+	}
+	_, ok := c.getNamespaces().names[name]
+	return ok
+}
+
+func (c *keyPrefixClassifier) AllowMerge(one *core.RegionInfo, other *core.RegionInfo) bool {
+	return c.GetRegionNamespace(one) == c.GetRegionNamespace(other)
+}
+
+func (c *keyPrefixClassifier) ReloadNamespaces() error {
+	nss, err := c.load()
+	if err != nil {
+		return err
+	}
+	namespaces, err := newKeyPrefixNamespaces(nss)
+	if err != nil {
+		return err
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.namespaces = namespaces
+	return nil
+}
+
+func (c *keyPrefixClassifier) IsMetaExist() bool {
+	return false
+}
+
+func (c *keyPrefixClassifier) IsTableIDExist(tableID int64) bool {
+	return false
+}
+
+func (c *keyPrefixClassifier) IsStoreIDExist(storeID uint64) bool {
+	_, ok := c.getNamespaces().stores[storeID]
+	return ok
+}
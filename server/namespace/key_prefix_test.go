@@ -0,0 +1,115 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package namespace
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/server/core"
+)
+
+func TestNamespace(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testKeyPrefixClassifierSuite{})
+
+type testKeyPrefixClassifierSuite struct{}
+
+func newTestRegion(startKey, endKey []byte) *core.RegionInfo {
+	return core.NewRegionInfo(&metapb.Region{StartKey: startKey, EndKey: endKey}, nil)
+}
+
+func newTestStore(id uint64, labels map[string]string) *core.StoreInfo {
+	store := &metapb.Store{Id: id}
+	for k, v := range labels {
+		store.Labels = append(store.Labels, &metapb.StoreLabel{Key: k, Value: v})
+	}
+	return core.NewStoreInfo(store)
+}
+
+func (s *testKeyPrefixClassifierSuite) TestPrefixEnd(c *C) {
+	c.Assert(prefixEnd([]byte{0x01, 0x02}), DeepEquals, []byte{0x01, 0x03})
+	c.Assert(prefixEnd([]byte{0x01, 0xff}), DeepEquals, []byte{0x02})
+	c.Assert(prefixEnd([]byte{0xff, 0xff}), IsNil)
+}
+
+func (s *testKeyPrefixClassifierSuite) TestKeyPrefix(c *C) {
+	_, err := CreateClassifier("key-prefix", nil, nil, map[string]string{"prefix.ns1": "zz"})
+	c.Assert(err, NotNil)
+	_, err = CreateClassifier("key-prefix", nil, nil, map[string]string{"prefix.global": "01"})
+	c.Assert(err, NotNil)
+
+	classifier, err := CreateClassifier("key-prefix", nil, nil, map[string]string{
+		"prefix.ns1":      "01, 02",
+		"prefix.ns2":      "03",
+		"store-label-key": "zone",
+	})
+	c.Assert(err, IsNil)
+	c.Assert(classifier.GetAllNamespaces(), DeepEquals, []string{"ns1", "ns2", DefaultNamespace})
+	c.Assert(classifier.IsNamespaceExist("ns1"), IsTrue)
+	c.Assert(classifier.IsNamespaceExist("ns3"), IsFalse)
+
+	r1 := newTestRegion([]byte{0x01, 0x01}, []byte{0x01, 0x05})
+	r2 := newTestRegion([]byte{0x01, 0x05}, []byte{0x02})
+	r3 := newTestRegion([]byte{0x02, 0x01}, []byte{0x03, 0x01})
+	r4 := newTestRegion([]byte{0x03, 0x01}, nil)
+	c.Assert(classifier.GetRegionNamespace(r1), Equals, "ns1")
+	c.Assert(classifier.GetRegionNamespace(r2), Equals, "ns1")
+	c.Assert(classifier.GetRegionNamespace(r3), Equals, DefaultNamespace)
+	c.Assert(classifier.GetRegionNamespace(r4), Equals, DefaultNamespace)
+	c.Assert(classifier.AllowMerge(r1, r2), IsTrue)
+	c.Assert(classifier.AllowMerge(r2, r3), IsFalse)
+
+	c.Assert(classifier.GetStoreNamespace(newTestStore(1, map[string]string{"zone": "ns2"})), Equals, "ns2")
+	c.Assert(classifier.GetStoreNamespace(newTestStore(2, map[string]string{"zone": "ns3"})), Equals, DefaultNamespace)
+	c.Assert(classifier.GetStoreNamespace(newTestStore(3, nil)), Equals, DefaultNamespace)
+}
+
+func (s *testKeyPrefixClassifierSuite) TestHTTPCallback(c *C) {
+	_, err := CreateClassifier("http", nil, nil, nil)
+	c.Assert(err, NotNil)
+
+	nss := []*KeyPrefixNamespace{{Name: "ns1", KeyPrefixes: []string{"01"}, StoreIDs: []uint64{1}}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Assert(json.NewEncoder(w).Encode(nss), IsNil)
+	}))
+	defer server.Close()
+
+	classifier, err := CreateClassifier("http", nil, nil, map[string]string{"url": server.URL})
+	c.Assert(err, IsNil)
+	r := newTestRegion([]byte{0x02, 0x01}, []byte{0x02, 0x02})
+	store := newTestStore(2, nil)
+	c.Assert(classifier.GetStoreNamespace(newTestStore(1, nil)), Equals, "ns1")
+	c.Assert(classifier.GetStoreNamespace(store), Equals, DefaultNamespace)
+	c.Assert(classifier.GetRegionNamespace(r), Equals, DefaultNamespace)
+
+	// Changes of the backing data take effect after reloading.
+	nss = append(nss, &KeyPrefixNamespace{Name: "ns2", KeyPrefixes: []string{"02"}, StoreIDs: []uint64{2}})
+	c.Assert(classifier.GetRegionNamespace(r), Equals, DefaultNamespace)
+	c.Assert(classifier.ReloadNamespaces(), IsNil)
+	c.Assert(classifier.GetStoreNamespace(store), Equals, "ns2")
+	c.Assert(classifier.GetRegionNamespace(r), Equals, "ns2")
+	c.Assert(classifier.IsStoreIDExist(2), IsTrue)
+
+	// Failed reload keeps the old namespaces.
+	server.Close()
+	c.Assert(classifier.ReloadNamespaces(), NotNil)
+	c.Assert(classifier.GetRegionNamespace(r), Equals, "ns2")
+}
@@ -31,6 +31,7 @@ type ScheduleOptions interface {
 	GetRegionScheduleLimit(name string) uint64
 	GetReplicaScheduleLimit(name string) uint64
 	GetMergeScheduleLimit(name string) uint64
+	GetHotRegionScheduleLimit(name string) uint64
 	GetMaxReplicas(name string) int
 }
 
@@ -54,6 +55,14 @@ type Classifier interface {
 	IsStoreIDExist(uint64) bool
 }
 
+// RangeMover is implemented by the classifiers which can move key ranges
+// from one namespace to another.
+type RangeMover interface {
+	// MoveRange moves the part of [startKey, endKey) which belongs to the
+	// source namespace to the target namespace atomically.
+	MoveRange(startKey, endKey []byte, source, target string) error
+}
+
 type defaultClassifier struct{}
 
 func (c defaultClassifier) GetAllNamespaces() []string {
@@ -92,8 +101,9 @@ func (c defaultClassifier) IsStoreIDExist(storeID uint64) bool {
 	return false
 }
 
-// CreateClassifierFunc is for creating namespace classifier.
-type CreateClassifierFunc func(*core.Storage, id.Allocator) (Classifier, error)
+// CreateClassifierFunc is for creating namespace classifier. The args are
+// classifier specific arguments from the configuration.
+type CreateClassifierFunc func(storage *core.Storage, idAlloc id.Allocator, args map[string]string) (Classifier, error)
 
 var classifierMap = make(map[string]CreateClassifierFunc)
 
@@ -107,16 +117,16 @@ func RegisterClassifier(name string, createFn CreateClassifierFunc) {
 }
 
 // CreateClassifier creates a namespace classifier with registered creator func.
-func CreateClassifier(name string, storage *core.Storage, idAlloc id.Allocator) (Classifier, error) {
+func CreateClassifier(name string, storage *core.Storage, idAlloc id.Allocator, args map[string]string) (Classifier, error) {
 	fn, ok := classifierMap[name]
 	if !ok {
 		return nil, errors.Errorf("create func of %v is not registered", name)
 	}
-	return fn(storage, idAlloc)
+	return fn(storage, idAlloc, args)
 }
 
 func init() {
-	RegisterClassifier("default", func(*core.Storage, id.Allocator) (Classifier, error) {
+	RegisterClassifier("default", func(*core.Storage, id.Allocator, map[string]string) (Classifier, error) {
 		return DefaultClassifier, nil
 	})
 }
@@ -0,0 +1,173 @@
+// Copyright 2017 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package namespace
+
+import (
+	"sync/atomic"
+
+	"github.com/pingcap/pd/server/core"
+	"github.com/pkg/errors"
+)
+
+// Divergence is a store or region classified into different namespaces by
+// the current and the next classifiers.
+type Divergence struct {
+	StoreID  uint64 `json:"store_id,omitempty"`
+	RegionID uint64 `json:"region_id,omitempty"`
+	Current  string `json:"current"`
+	Next     string `json:"next"`
+}
+
+type classifierPair struct {
+	current Classifier
+	next    Classifier
+}
+
+// SwitchableClassifier delegates to the current classifier, and holds the
+// next classifier during a transition, so that the classifier can be switched
+// at runtime. The next classifier is only evaluated to find the divergences
+// until it is cut over.
+type SwitchableClassifier struct {
+	pair atomic.Value
+}
+
+// NewSwitchableClassifier creates a SwitchableClassifier using the classifier.
+func NewSwitchableClassifier(current Classifier) *SwitchableClassifier {
+	c := &SwitchableClassifier{}
+	c.pair.Store(&classifierPair{current: current})
+	return c
+}
+
+func (c *SwitchableClassifier) load() *classifierPair {
+	return c.pair.Load().(*classifierPair)
+}
+
+// Current returns the classifier in effect.
+func (c *SwitchableClassifier) Current() Classifier {
+	return c.load().current
+}
+
+// Next returns the classifier to cut over to, or nil if there is no
+// transition.
+func (c *SwitchableClassifier) Next() Classifier {
+	return c.load().next
+}
+
+// SetCurrent replaces the classifier in effect, and stops the transition.
+func (c *SwitchableClassifier) SetCurrent(current Classifier) {
+	c.pair.Store(&classifierPair{current: current})
+}
+
+// SetNext starts a transition to the next classifier, or stops the transition
+// if next is nil. The current classifier stays in effect.
+func (c *SwitchableClassifier) SetNext(next Classifier) {
+	c.pair.Store(&classifierPair{current: c.Current(), next: next})
+}
+
+// CutOver makes the next classifier in effect.
+func (c *SwitchableClassifier) CutOver() error {
+	pair := c.load()
+	if pair.next == nil {
+		return errors.New("no classifier transition in progress")
+	}
+	c.pair.Store(&classifierPair{current: pair.next})
+	return nil
+}
+
+// DiffStores returns the stores classified differently by the current and the
+// next classifiers.
+func (c *SwitchableClassifier) DiffStores(stores []*core.StoreInfo) []*Divergence {
+	pair := c.load()
+	if pair.next == nil {
+		return nil
+	}
+	var divergences []*Divergence
+	for _, store := range stores {
+		current, next := pair.current.GetStoreNamespace(store), pair.next.GetStoreNamespace(store)
+		if current != next {
+			divergences = append(divergences, &Divergence{StoreID: store.GetID(), Current: current, Next: next})
+		}
+	}
+	return divergences
+}
+
+// DiffRegions returns the regions classified differently by the current and
+// the next classifiers.
+func (c *SwitchableClassifier) DiffRegions(regions []*core.RegionInfo) []*Divergence {
+	pair := c.load()
+	if pair.next == nil {
+		return nil
+	}
+	var divergences []*Divergence
+	for _, region := range regions {
+		current, next := pair.current.GetRegionNamespace(region), pair.next.GetRegionNamespace(region)
+		if current != next {
+			divergences = append(divergences, &Divergence{RegionID: region.GetID(), Current: current, Next: next})
+		}
+	}
+	return divergences
+}
+
+// GetAllNamespaces implements Classifier.
+func (c *SwitchableClassifier) GetAllNamespaces() []string {
+	return c.Current().GetAllNamespaces()
+}
+
+// GetStoreNamespace implements Classifier.
+func (c *SwitchableClassifier) GetStoreNamespace(store *core.StoreInfo) string {
+	return c.Current().GetStoreNamespace(store)
+}
+
+// GetRegionNamespace implements Classifier.
+func (c *SwitchableClassifier) GetRegionNamespace(region *core.RegionInfo) string {
+	return c.Current().GetRegionNamespace(region)
+}
+
+// IsNamespaceExist implements Classifier.
+func (c *SwitchableClassifier) IsNamespaceExist(name string) bool {
+	return c.Current().IsNamespaceExist(name)
+}
+
+// AllowMerge implements Classifier.
+func (c *SwitchableClassifier) AllowMerge(one *core.RegionInfo, other *core.RegionInfo) bool {
+	return c.Current().AllowMerge(one, other)
+}
+
+// ReloadNamespaces implements Classifier. Both classifiers are reloaded
+// during a transition.
+func (c *SwitchableClassifier) ReloadNamespaces() error {
+	pair := c.load()
+	if err := pair.current.ReloadNamespaces(); err != nil {
+		return err
+	}
+	if pair.next != nil {
+		return pair.next.ReloadNamespaces()
+	}
+	return nil
+}
+
+// IsMetaExist implements Classifier.
+func (c *SwitchableClassifier) IsMetaExist() bool {
+	return c.Current().IsMetaExist()
+}
+
+// IsTableIDExist implements Classifier.
+func (c *SwitchableClassifier) IsTableIDExist(tableID int64) bool {
+	return c.Current().IsTableIDExist(tableID)
+}
+
+// IsStoreIDExist implements Classifier.
+func (c *SwitchableClassifier) IsStoreIDExist(storeID uint64) bool {
+	return c.Current().IsStoreIDExist(storeID)
+}
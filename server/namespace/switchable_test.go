@@ -0,0 +1,58 @@
+// Copyright 2017 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package namespace
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/pd/server/core"
+)
+
+var _ = Suite(&testSwitchableClassifierSuite{})
+
+type testSwitchableClassifierSuite struct{}
+
+func (s *testSwitchableClassifierSuite) TestSwitch(c *C) {
+	next, err := CreateClassifier("key-prefix", nil, nil, map[string]string{
+		"prefix.ns1":      "01",
+		"store-label-key": "zone",
+	})
+	c.Assert(err, IsNil)
+	classifier := NewSwitchableClassifier(DefaultClassifier)
+	stores := []*core.StoreInfo{newTestStore(1, map[string]string{"zone": "ns1"}), newTestStore(2, nil)}
+	regions := []*core.RegionInfo{newTestRegion([]byte{0x01}, []byte{0x02}), newTestRegion([]byte{0x02}, nil)}
+
+	c.Assert(classifier.Next(), IsNil)
+	c.Assert(classifier.DiffStores(stores), HasLen, 0)
+	c.Assert(classifier.CutOver(), NotNil)
+
+	// Only the current classifier is in effect during the transition.
+	classifier.SetNext(next)
+	c.Assert(classifier.GetStoreNamespace(stores[0]), Equals, DefaultNamespace)
+	c.Assert(classifier.IsNamespaceExist("ns1"), IsFalse)
+	c.Assert(classifier.DiffStores(stores), DeepEquals, []*Divergence{{StoreID: 1, Current: DefaultNamespace, Next: "ns1"}})
+	c.Assert(classifier.DiffRegions(regions), DeepEquals, []*Divergence{{Current: DefaultNamespace, Next: "ns1"}})
+
+	c.Assert(classifier.CutOver(), IsNil)
+	c.Assert(classifier.Next(), IsNil)
+	c.Assert(classifier.Current(), Equals, next)
+	c.Assert(classifier.GetStoreNamespace(stores[0]), Equals, "ns1")
+	c.Assert(classifier.GetRegionNamespace(regions[0]), Equals, "ns1")
+
+	// Abort a transition.
+	classifier.SetNext(DefaultClassifier)
+	classifier.SetNext(nil)
+	c.Assert(classifier.Current(), Equals, next)
+	classifier.SetCurrent(DefaultClassifier)
+	c.Assert(classifier.GetStoreNamespace(stores[0]), Equals, DefaultNamespace)
+}
@@ -16,6 +16,7 @@ package server
 import (
 	"math/rand"
 
+	"github.com/opentracing/opentracing-go"
 	"github.com/pingcap/pd/server/core"
 	"github.com/pingcap/pd/server/namespace"
 	"github.com/pingcap/pd/server/schedule"
@@ -30,13 +31,19 @@ type namespaceCluster struct {
 	classifier namespace.Classifier
 	namespace  string
 	stores     map[uint64]*core.StoreInfo
+	mixedTier  bool
+	// span is the span of the schedule, to which the stores rejected by the
+	// filters are logged.
+	span opentracing.Span
 }
 
 func newNamespaceCluster(c schedule.Cluster, classifier namespace.Classifier, namespace string) *namespaceCluster {
 	stores := make(map[uint64]*core.StoreInfo)
+	var storeList []*core.StoreInfo
 	for _, s := range c.GetStores() {
 		if classifier.GetStoreNamespace(s) == namespace {
 			stores[s.GetID()] = s
+			storeList = append(storeList, s)
 		}
 	}
 	return &namespaceCluster{
@@ -44,6 +51,7 @@ func newNamespaceCluster(c schedule.Cluster, classifier namespace.Classifier, na
 		classifier: classifier,
 		namespace:  namespace,
 		stores:     stores,
+		mixedTier:  core.IsMixedTier(storeList),
 	}
 }
 
@@ -111,6 +119,11 @@ func (c *namespaceCluster) GetStores() []*core.StoreInfo {
 	return stores
 }
 
+// IsMixedTier checks if the stores in the namespace are of different types.
+func (c *namespaceCluster) IsMixedTier() bool {
+	return c.mixedTier
+}
+
 // GetStore searches for a store by ID.
 func (c *namespaceCluster) GetStore(id uint64) *core.StoreInfo {
 	return c.stores[id]
@@ -130,17 +143,29 @@ func (c *namespaceCluster) RegionWriteStats() map[uint64][]*statistics.HotSpotPe
 	return c.Cluster.RegionWriteStats()
 }
 
-func scheduleByNamespace(cluster schedule.Cluster, classifier namespace.Classifier, scheduler schedule.Scheduler) []*operator.Operator {
+// scheduleByNamespace schedules in the namespaces in random order until the
+// scheduler creates operators. The stores rejected by the filters of the
+// scheduler are logged to the span.
+func scheduleByNamespace(cluster schedule.Cluster, classifier namespace.Classifier, scheduler schedule.Scheduler, span opentracing.Span) []*operator.Operator {
 	namespaces := classifier.GetAllNamespaces()
 	for _, i := range rand.Perm(len(namespaces)) {
 		nc := newNamespaceCluster(cluster, classifier, namespaces[i])
-		if op := scheduler.Schedule(nc); op != nil {
-			return op
+		nc.span = span
+		if ops := scheduler.Schedule(nc); ops != nil {
+			for _, op := range ops {
+				op.SetNamespace(namespaces[i])
+			}
+			return ops
 		}
 	}
 	return nil
 }
 
+// FilterSpan returns the span of the schedule which evaluates the filters.
+func (c *namespaceCluster) FilterSpan() opentracing.Span {
+	return c.span
+}
+
 func (c *namespaceCluster) GetLeaderScheduleLimit() uint64 {
 	return c.GetOpt().GetLeaderScheduleLimit(c.namespace)
 }
@@ -157,6 +182,15 @@ func (c *namespaceCluster) GetMergeScheduleLimit() uint64 {
 	return c.GetOpt().GetMergeScheduleLimit(c.namespace)
 }
 
+func (c *namespaceCluster) GetHotRegionScheduleLimit() uint64 {
+	return c.GetOpt().GetHotRegionScheduleLimit(c.namespace)
+}
+
+// GetNamespace returns the namespace of the cluster.
+func (c *namespaceCluster) GetNamespace() string {
+	return c.namespace
+}
+
 func (c *namespaceCluster) GetMaxReplicas() int {
 	return c.GetOpt().GetMaxReplicas(c.namespace)
 }
@@ -0,0 +1,199 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/server/namespace"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// NamespaceMigration records a key range moved from one namespace to another.
+// The keys are hex encoded.
+type NamespaceMigration struct {
+	ID         uint64    `json:"id"`
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	StartKey   string    `json:"start_key"`
+	EndKey     string    `json:"end_key"`
+	CreateTime time.Time `json:"create_time"`
+}
+
+// NamespaceMigrationStatus is a migration with the progress of relocating the
+// peers of its regions to the stores of the target namespace.
+type NamespaceMigrationStatus struct {
+	*NamespaceMigration
+	TotalRegions     int  `json:"total_regions"`
+	RelocatedRegions int  `json:"relocated_regions"`
+	Finished         bool `json:"finished"`
+}
+
+func (m *NamespaceMigration) keys() (startKey, endKey []byte) {
+	startKey, _ = hex.DecodeString(m.StartKey)
+	endKey, _ = hex.DecodeString(m.EndKey)
+	return
+}
+
+func (c *RaftCluster) loadNamespaceMigrations() error {
+	return c.storage.LoadNamespaceMigrations(func(value string) error {
+		m := &NamespaceMigration{}
+		if err := json.Unmarshal([]byte(value), m); err != nil {
+			return errors.WithStack(err)
+		}
+		c.nsMigrations[m.ID] = m
+		return nil
+	})
+}
+
+// CreateNamespaceMigration moves the part of [startKey, endKey) which belongs
+// to the namespace from to the namespace to, and checks the regions in the
+// range at once to start relocating their peers.
+func (c *RaftCluster) CreateNamespaceMigration(from, to string, startKey, endKey []byte) (*NamespaceMigration, error) {
+	if c.s.classifier.Next() != nil {
+		return nil, errcode.NewInvalidInputErr(errors.New("the namespace classifier is in transition"))
+	}
+	classifier := c.GetNamespaceClassifier()
+	mover, ok := classifier.(namespace.RangeMover)
+	if !ok {
+		return nil, errcode.NewInvalidInputErr(errors.New("the namespace classifier cannot move key ranges"))
+	}
+	for _, name := range []string{from, to} {
+		if name != namespace.DefaultNamespace && !classifier.IsNamespaceExist(name) {
+			return nil, errcode.NewInvalidInputErr(errors.Errorf("namespace %s not found", name))
+		}
+	}
+	if len(endKey) == 0 || bytes.Compare(startKey, endKey) >= 0 {
+		return nil, errcode.NewInvalidInputErr(errors.New("start key should be less than end key"))
+	}
+
+	// The ID allocation and the saves hit etcd, so they are done without
+	// holding the cluster lock.
+	id, err := c.id.Alloc()
+	if err != nil {
+		return nil, err
+	}
+	m := &NamespaceMigration{
+		ID:         id,
+		From:       from,
+		To:         to,
+		StartKey:   hex.EncodeToString(startKey),
+		EndKey:     hex.EncodeToString(endKey),
+		CreateTime: time.Now(),
+	}
+	if err = mover.MoveRange(startKey, endKey, from, to); err != nil {
+		return nil, errcode.NewInvalidInputErr(err)
+	}
+	if err = c.storage.SaveNamespaceMigration(id, m); err != nil {
+		// The range has been moved, only the progress is not tracked.
+		log.Error("failed to save namespace migration", zap.Uint64("migration-id", id), zap.Error(err))
+		return nil, err
+	}
+	c.Lock()
+	c.nsMigrations[id] = m
+	c.Unlock()
+
+	count := c.coordinator.checkNamespaceRange(startKey, endKey)
+	log.Info("namespace migration created",
+		zap.Uint64("migration-id", id),
+		zap.String("from", from),
+		zap.String("to", to),
+		zap.String("start-key", m.StartKey),
+		zap.String("end-key", m.EndKey),
+		zap.Int("operator-count", count))
+	return m, nil
+}
+
+// DeleteNamespaceMigration stops tracking the progress of the migration. The
+// moved range stays in the target namespace.
+func (c *RaftCluster) DeleteNamespaceMigration(id uint64) error {
+	c.RLock()
+	_, ok := c.nsMigrations[id]
+	c.RUnlock()
+	if !ok {
+		return errcode.NewNotFoundErr(errors.Errorf("namespace migration %d not found", id))
+	}
+	if err := c.storage.DeleteNamespaceMigration(id); err != nil {
+		return err
+	}
+	c.Lock()
+	delete(c.nsMigrations, id)
+	c.Unlock()
+	return nil
+}
+
+// GetNamespaceMigrations returns the migrations with their progress sorted by
+// id.
+func (c *RaftCluster) GetNamespaceMigrations() []*NamespaceMigrationStatus {
+	c.RLock()
+	migrations := make([]*NamespaceMigration, 0, len(c.nsMigrations))
+	for _, m := range c.nsMigrations {
+		migrations = append(migrations, m)
+	}
+	c.RUnlock()
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].ID < migrations[j].ID })
+
+	statuses := make([]*NamespaceMigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		statuses = append(statuses, c.getNamespaceMigrationStatus(m))
+	}
+	return statuses
+}
+
+// GetNamespaceMigration returns the migration with its progress.
+func (c *RaftCluster) GetNamespaceMigration(id uint64) *NamespaceMigrationStatus {
+	c.RLock()
+	m, ok := c.nsMigrations[id]
+	c.RUnlock()
+	if !ok {
+		return nil
+	}
+	return c.getNamespaceMigrationStatus(m)
+}
+
+// getNamespaceMigrationStatus counts the regions starting in the range, and
+// the regions whose peers are all on the stores of the target namespace.
+func (c *RaftCluster) getNamespaceMigrationStatus(m *NamespaceMigration) *NamespaceMigrationStatus {
+	classifier := c.coordinator.classifier
+	status := &NamespaceMigrationStatus{NamespaceMigration: m}
+	startKey, endKey := m.keys()
+	for _, region := range c.ScanRegions(startKey, endKey, 0) {
+		if bytes.Compare(region.GetStartKey(), startKey) < 0 {
+			continue
+		}
+		status.TotalRegions++
+		if classifier.GetRegionNamespace(region) != m.To {
+			continue
+		}
+		relocated := true
+		for _, store := range c.GetRegionStores(region) {
+			if classifier.GetStoreNamespace(store) != m.To {
+				relocated = false
+				break
+			}
+		}
+		if relocated {
+			status.RelocatedRegions++
+		}
+	}
+	status.Finished = status.RelocatedRegions == status.TotalRegions
+	return status
+}
@@ -137,13 +137,13 @@ func (s *testNamespaceSuite) TestSchedulerBalanceRegion(c *C) {
 	// Balance is limited within a namespace.
 	c.Assert(s.tc.addLeaderRegion(1, 2), IsNil)
 	s.classifier.setRegion(1, "ns1")
-	op := scheduleByNamespace(s.tc, s.classifier, sched)
+	op := scheduleByNamespace(s.tc, s.classifier, sched, nil)
 	testutil.CheckTransferPeer(c, op[0], operator.OpBalance, 2, 1)
 
 	// If no more store in the namespace, balance stops.
 	c.Assert(s.tc.addLeaderRegion(1, 3), IsNil)
 	s.classifier.setRegion(1, "ns2")
-	op = scheduleByNamespace(s.tc, s.classifier, sched)
+	op = scheduleByNamespace(s.tc, s.classifier, sched, nil)
 	c.Assert(op, IsNil)
 
 	// If region is not in the correct namespace, it will not be balanced. The
@@ -153,7 +153,7 @@ func (s *testNamespaceSuite) TestSchedulerBalanceRegion(c *C) {
 	s.classifier.setStore(4, "ns2")
 	c.Assert(s.tc.addLeaderRegion(1, 3), IsNil)
 	s.classifier.setRegion(1, "ns1")
-	op = scheduleByNamespace(s.tc, s.classifier, sched)
+	op = scheduleByNamespace(s.tc, s.classifier, sched, nil)
 	c.Assert(op, IsNil)
 }
 
@@ -178,16 +178,61 @@ func (s *testNamespaceSuite) TestSchedulerBalanceLeader(c *C) {
 	// Balance is limited within a namespace.
 	c.Assert(s.tc.addLeaderRegion(1, 2, 1), IsNil)
 	s.classifier.setRegion(1, "ns1")
-	op := scheduleByNamespace(s.tc, s.classifier, sched)
+	op := scheduleByNamespace(s.tc, s.classifier, sched, nil)
 	testutil.CheckTransferLeader(c, op[0], operator.OpBalance, 2, 1)
 
 	// If region is not in the correct namespace, it will not be balanced.
 	c.Assert(s.tc.addLeaderRegion(1, 4, 1), IsNil)
 	s.classifier.setRegion(1, "ns1")
-	op = scheduleByNamespace(s.tc, s.classifier, sched)
+	op = scheduleByNamespace(s.tc, s.classifier, sched, nil)
 	c.Assert(op, IsNil)
 }
 
+func (s *testNamespaceSuite) TestSchedulerHotRegionLimit(c *C) {
+	// store namespace
+	//     1       ns1
+	//     2       ns1
+	//     3       ns2
+	//     4       ns2
+	for i := uint64(1); i <= 4; i++ {
+		c.Assert(s.tc.addLeaderStore(i, 0), IsNil)
+	}
+	s.classifier.setStore(1, "ns1")
+	s.classifier.setStore(2, "ns1")
+	s.classifier.setStore(3, "ns2")
+	s.classifier.setStore(4, "ns2")
+	c.Assert(s.tc.addLeaderRegion(1, 1, 2), IsNil)
+	s.classifier.setRegion(1, "ns1")
+	for _, name := range []string{"ns1", "ns2"} {
+		cfg := &config.NamespaceConfig{HotRegionScheduleLimit: 1}
+		cfg.Adjust(s.opt)
+		s.opt.SetNS(name, config.NewNamespaceOption(cfg))
+	}
+
+	hbStreams, cleanup := getHeartBeatStreams(c, s.tc)
+	defer cleanup()
+	oc := schedule.NewOperatorController(s.tc, hbStreams)
+	sched, err := schedule.CreateScheduler("hot-read-region", oc)
+	c.Assert(err, IsNil)
+	ns1 := newNamespaceCluster(s.tc, s.classifier, "ns1")
+	ns2 := newNamespaceCluster(s.tc, s.classifier, "ns2")
+	c.Assert(sched.IsScheduleAllowed(ns1), IsTrue)
+
+	// The hot region operator of ns1 only takes up the limit of ns1.
+	op := operator.CreateTransferLeaderOperator("transfer-hot-read-leader", s.tc.GetRegion(1), 1, 2, operator.OpHotRegion)
+	op.SetNamespace("ns1")
+	c.Assert(oc.AddOperator(op), IsTrue)
+	c.Assert(oc.OperatorCountByNamespace("ns1", operator.OpHotRegion), Equals, uint64(1))
+	c.Assert(sched.IsScheduleAllowed(ns1), IsFalse)
+	c.Assert(sched.Schedule(ns1), IsNil)
+	c.Assert(sched.IsScheduleAllowed(ns2), IsTrue)
+	c.Assert(sched.IsScheduleAllowed(s.tc), IsTrue)
+
+	// The operators of all the namespaces are limited by the whole cluster.
+	s.scheduleConfig.HotRegionScheduleLimit = 1
+	c.Assert(sched.IsScheduleAllowed(s.tc), IsFalse)
+}
+
 type mapClassifer struct {
 	stores  map[uint64]string
 	regions map[uint64]string
@@ -0,0 +1,209 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/schedule"
+	"github.com/pingcap/pd/server/schedule/operator"
+	"github.com/pkg/errors"
+)
+
+// OperatorPlan is a hypothetical operator to preview. The fields are the same
+// as the ones to add an operator by the API.
+type OperatorPlan struct {
+	Name           string   `json:"name"`
+	RegionID       uint64   `json:"region_id"`
+	StoreID        uint64   `json:"store_id"`
+	FromStoreID    uint64   `json:"from_store_id"`
+	ToStoreID      uint64   `json:"to_store_id"`
+	ToStoreIDs     []uint64 `json:"to_store_ids"`
+	SourceRegionID uint64   `json:"source_region_id"`
+	TargetRegionID uint64   `json:"target_region_id"`
+}
+
+// StoreInfluencePreview is the influence of a plan on a store. The scores
+// before include the influence of the pending operators, as the schedulers
+// see them, and the scores after include the plan as well.
+type StoreInfluencePreview struct {
+	StoreID           uint64  `json:"store_id"`
+	LeaderCount       int64   `json:"leader_count"`
+	LeaderSize        int64   `json:"leader_size"`
+	RegionCount       int64   `json:"region_count"`
+	RegionSize        int64   `json:"region_size"`
+	BytesWriteRate    float64 `json:"bytes_write_rate"`
+	BytesReadRate     float64 `json:"bytes_read_rate"`
+	LeaderScoreBefore float64 `json:"leader_score_before"`
+	LeaderScoreAfter  float64 `json:"leader_score_after"`
+	RegionScoreBefore float64 `json:"region_score_before"`
+	RegionScoreAfter  float64 `json:"region_score_after"`
+	WriteFlowBefore   float64 `json:"write_flow_before"`
+	WriteFlowAfter    float64 `json:"write_flow_after"`
+	ReadFlowBefore    float64 `json:"read_flow_before"`
+	ReadFlowAfter     float64 `json:"read_flow_after"`
+}
+
+// previewCluster creates the operators of the plans without allocating the
+// IDs of the new peers, since the operators are never added.
+type previewCluster struct {
+	*RaftCluster
+}
+
+func (c previewCluster) AllocPeer(storeID uint64) (*metapb.Peer, error) {
+	return &metapb.Peer{StoreId: storeID}, nil
+}
+
+// PreviewOperators returns the influence of the plans on the stores they
+// touch, sorted by the store IDs. Each plan is created against the current
+// regions, so the plans of a region are not chained.
+func (h *Handler) PreviewOperators(plans []OperatorPlan) ([]*StoreInfluencePreview, error) {
+	c, err := h.getCoordinator()
+	if err != nil {
+		return nil, err
+	}
+	cluster := previewCluster{c.cluster}
+
+	var ops []*operator.Operator
+	for _, plan := range plans {
+		o, err := createPlanOperators(cluster, plan)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, o...)
+	}
+	influence := schedule.NewTotalOpInfluence(ops, c.cluster)
+	pending := c.opController.GetOpInfluence(c.cluster)
+
+	highSpaceRatio, lowSpaceRatio := c.cluster.GetHighSpaceRatio(), c.cluster.GetLowSpaceRatio()
+	storesStats := c.cluster.GetStoresStats()
+	previews := make([]*StoreInfluencePreview, 0, len(influence.StoresInfluence))
+	for storeID, inf := range influence.StoresInfluence {
+		store := c.cluster.GetStore(storeID)
+		if store == nil {
+			return nil, core.NewStoreNotFoundErr(storeID)
+		}
+		base := pending.GetStoreInfluence(storeID)
+		rates := storesStats.GetStoreRates(storeID)
+		writeFlow := rates.BytesWriteRate + base.BytesWriteRate
+		readFlow := rates.BytesReadRate + base.BytesReadRate
+		previews = append(previews, &StoreInfluencePreview{
+			StoreID:           storeID,
+			LeaderCount:       inf.LeaderCount,
+			LeaderSize:        inf.LeaderSize,
+			RegionCount:       inf.RegionCount,
+			RegionSize:        inf.RegionSize,
+			BytesWriteRate:    inf.BytesWriteRate,
+			BytesReadRate:     inf.BytesReadRate,
+			LeaderScoreBefore: store.LeaderScore(base.LeaderSize),
+			LeaderScoreAfter:  store.LeaderScore(base.LeaderSize + inf.LeaderSize),
+			RegionScoreBefore: store.RegionScore(highSpaceRatio, lowSpaceRatio, base.RegionSize),
+			RegionScoreAfter:  store.RegionScore(highSpaceRatio, lowSpaceRatio, base.RegionSize+inf.RegionSize),
+			WriteFlowBefore:   writeFlow,
+			WriteFlowAfter:    writeFlow + inf.BytesWriteRate,
+			ReadFlowBefore:    readFlow,
+			ReadFlowAfter:     readFlow + inf.BytesReadRate,
+		})
+	}
+	sort.Slice(previews, func(i, j int) bool { return previews[i].StoreID < previews[j].StoreID })
+	return previews, nil
+}
+
+func createPlanOperators(cluster previewCluster, plan OperatorPlan) ([]*operator.Operator, error) {
+	if plan.Name == "merge-region" {
+		source := cluster.GetRegion(plan.SourceRegionID)
+		if source == nil {
+			return nil, ErrRegionNotFound(plan.SourceRegionID)
+		}
+		target := cluster.GetRegion(plan.TargetRegionID)
+		if target == nil {
+			return nil, ErrRegionNotFound(plan.TargetRegionID)
+		}
+		if (!bytes.Equal(source.GetStartKey(), target.GetEndKey()) || len(source.GetStartKey()) == 0) &&
+			(!bytes.Equal(source.GetEndKey(), target.GetStartKey()) || len(source.GetEndKey()) == 0) {
+			return nil, ErrRegionNotAdjacent
+		}
+		return operator.CreateMergeRegionOperator("preview-merge-region", cluster, source, target, operator.OpAdmin)
+	}
+
+	region := cluster.GetRegion(plan.RegionID)
+	if region == nil {
+		return nil, ErrRegionNotFound(plan.RegionID)
+	}
+	for _, id := range append([]uint64{plan.StoreID, plan.FromStoreID, plan.ToStoreID}, plan.ToStoreIDs...) {
+		if id == 0 {
+			continue
+		}
+		store := cluster.GetStore(id)
+		if store == nil {
+			return nil, core.NewStoreNotFoundErr(id)
+		}
+		if store.IsTombstone() {
+			return nil, core.StoreTombstonedErr{StoreID: id}
+		}
+	}
+
+	var op *operator.Operator
+	var err error
+	switch plan.Name {
+	case "transfer-leader":
+		if region.GetStoreVoter(plan.ToStoreID) == nil {
+			return nil, errors.Errorf("region has no voter in store %v", plan.ToStoreID)
+		}
+		op = operator.CreateTransferLeaderOperator("preview-transfer-leader", region, region.GetLeader().GetStoreId(), plan.ToStoreID, operator.OpAdmin)
+	case "transfer-region":
+		storeIDs := make(map[uint64]struct{}, len(plan.ToStoreIDs))
+		for _, id := range plan.ToStoreIDs {
+			storeIDs[id] = struct{}{}
+		}
+		if len(storeIDs) == 0 {
+			return nil, errors.New("missing store ids to transfer region to")
+		}
+		op, err = operator.CreateMoveRegionOperator("preview-move-region", cluster, region, operator.OpAdmin, storeIDs)
+	case "transfer-peer":
+		if plan.ToStoreID == 0 {
+			return nil, errors.New("missing store id to transfer peer to")
+		}
+		if region.GetStorePeer(plan.FromStoreID) == nil {
+			return nil, errors.Errorf("region has no peer in store %v", plan.FromStoreID)
+		}
+		op, err = operator.CreateMovePeerOperator("preview-move-peer", cluster, region, operator.OpAdmin, plan.FromStoreID, plan.ToStoreID, 0)
+	case "add-peer", "add-learner":
+		if plan.StoreID == 0 {
+			return nil, errors.New("missing store id to add peer to")
+		}
+		if region.GetStorePeer(plan.StoreID) != nil {
+			return nil, errors.Errorf("region already has peer in store %v", plan.StoreID)
+		}
+		if plan.Name == "add-peer" {
+			op = operator.CreateAddPeerOperator("preview-add-peer", region, 0, plan.StoreID, operator.OpAdmin)
+		} else {
+			op = operator.CreateAddLearnerOperator("preview-add-learner", region, 0, plan.StoreID, operator.OpAdmin)
+		}
+	case "remove-peer":
+		if region.GetStorePeer(plan.StoreID) == nil {
+			return nil, errors.Errorf("region has no peer in store %v", plan.StoreID)
+		}
+		op, err = operator.CreateRemovePeerOperator("preview-remove-peer", cluster, operator.OpAdmin, region, plan.StoreID)
+	default:
+		return nil, errors.Errorf("unsupported operator %q to preview", plan.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []*operator.Operator{op}, nil
+}
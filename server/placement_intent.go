@@ -0,0 +1,463 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/schedule/operator"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// placementIntentDesc is the description of the operators converging regions
+// to their intents.
+const placementIntentDesc = "placement-intent"
+
+// The states of placement intents.
+const (
+	// IntentConverged means all regions of the intent are placed as declared.
+	IntentConverged = "converged"
+	// IntentInProgress means some regions are being moved, or waiting for the
+	// patrol to move them.
+	IntentInProgress = "in-progress"
+	// IntentBlocked means some regions cannot be moved, such as when their
+	// target stores are down.
+	IntentBlocked = "blocked"
+)
+
+// maxIntentBlockedRegions limits the blocked regions listed in the status.
+const maxIntentBlockedRegions = 16
+
+// IntentBlockedRegion is a region which cannot be moved to its intent.
+type IntentBlockedRegion struct {
+	RegionID uint64 `json:"region_id"`
+	Reason   string `json:"reason"`
+}
+
+// PlacementIntentStatus is the intent and how far its regions are converged.
+type PlacementIntentStatus struct {
+	Intent     *core.PlacementIntent `json:"intent"`
+	State      string                `json:"state"`
+	Converged  int                   `json:"converged"`
+	InProgress int                   `json:"in_progress"`
+	// BlockedCount is the number of the blocked regions, and Blocked is some
+	// of them.
+	BlockedCount int                    `json:"blocked_count"`
+	Blocked      []*IntentBlockedRegion `json:"blocked,omitempty"`
+}
+
+// placementIntents keeps the intents apart from the lock of the cluster, since
+// they are checked by the patrol.
+type placementIntents struct {
+	sync.RWMutex
+	intents map[uint64]*core.PlacementIntent
+	// regions maps the regions to their intents.
+	regions map[uint64]*core.PlacementIntent
+	// blocked maps the regions to why they cannot be moved when they are
+	// checked last time.
+	blocked map[uint64]string
+	// merged records the regions of intents which are merged into others.
+	// They are dropped from their intents by checkPlacementIntents, so that
+	// the storage is not written under the lock of the cluster.
+	merged map[uint64]struct{}
+}
+
+func (p *placementIntents) reset(intents map[uint64]*core.PlacementIntent) {
+	p.Lock()
+	defer p.Unlock()
+	p.intents = make(map[uint64]*core.PlacementIntent)
+	p.regions = make(map[uint64]*core.PlacementIntent)
+	p.blocked = make(map[uint64]string)
+	p.merged = make(map[uint64]struct{})
+	for _, intent := range intents {
+		p.addLocked(intent)
+	}
+}
+
+func (p *placementIntents) addLocked(intent *core.PlacementIntent) {
+	p.intents[intent.ID] = intent
+	for _, regionID := range intent.RegionIDs {
+		p.regions[regionID] = intent
+	}
+}
+
+func (p *placementIntents) get(regionID uint64) *core.PlacementIntent {
+	p.RLock()
+	defer p.RUnlock()
+	return p.regions[regionID]
+}
+
+// markMerged records the regions merged into others if they have intents.
+func (p *placementIntents) markMerged(regions []*metapb.Region) {
+	p.Lock()
+	defer p.Unlock()
+	for _, region := range regions {
+		if _, ok := p.regions[region.GetId()]; ok {
+			p.merged[region.GetId()] = struct{}{}
+		}
+	}
+}
+
+func (p *placementIntents) setBlocked(regionID uint64, reason string) {
+	p.Lock()
+	defer p.Unlock()
+	if reason == "" {
+		delete(p.blocked, regionID)
+	} else if _, ok := p.regions[regionID]; ok {
+		p.blocked[regionID] = reason
+	}
+}
+
+func (c *RaftCluster) loadPlacementIntents() error {
+	intents := make(map[uint64]*core.PlacementIntent)
+	if err := c.storage.LoadPlacementIntents(func(value string) error {
+		intent := &core.PlacementIntent{}
+		if err := json.Unmarshal([]byte(value), intent); err != nil {
+			return errors.WithStack(err)
+		}
+		intents[intent.ID] = intent
+		return nil
+	}); err != nil {
+		return err
+	}
+	c.intents.reset(intents)
+	return nil
+}
+
+// sortUniqueIDs sorts the IDs, and returns the duplicated one if there is.
+func sortUniqueIDs(ids []uint64) ([]uint64, uint64) {
+	ids = append([]uint64(nil), ids...)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for i := 1; i < len(ids); i++ {
+		if ids[i] == ids[i-1] {
+			return nil, ids[i]
+		}
+	}
+	return ids, 0
+}
+
+// AddPlacementIntent declares that the leaders of the regions should be in the
+// leader store if it is not 0, and the peers should be in the stores if they
+// are not empty. A region belongs to at most one intent.
+func (c *RaftCluster) AddPlacementIntent(regionIDs []uint64, leaderStoreID uint64, storeIDs []uint64, reason string) (*core.PlacementIntent, error) {
+	if len(regionIDs) == 0 {
+		return nil, errcode.NewInvalidInputErr(errors.New("the regions of the intent are required"))
+	}
+	if leaderStoreID == 0 && len(storeIDs) == 0 {
+		return nil, errcode.NewInvalidInputErr(errors.New("either the leader or the peers should be declared"))
+	}
+	regionIDs, dup := sortUniqueIDs(regionIDs)
+	if dup != 0 {
+		return nil, errcode.NewInvalidInputErr(errors.Errorf("region %d is duplicated", dup))
+	}
+	storeIDs, dup = sortUniqueIDs(storeIDs)
+	if dup != 0 {
+		return nil, errcode.NewInvalidInputErr(errors.Errorf("store %d is duplicated", dup))
+	}
+	// The replica checker would add or remove peers against an intent of
+	// fewer or more stores.
+	if len(storeIDs) > 0 && len(storeIDs) != c.GetMaxReplicas() {
+		return nil, errcode.NewInvalidInputErr(errors.Errorf("the number of stores is %d, not the max replicas %d", len(storeIDs), c.GetMaxReplicas()))
+	}
+	if leaderStoreID != 0 && len(storeIDs) > 0 {
+		if i := sort.Search(len(storeIDs), func(i int) bool { return storeIDs[i] >= leaderStoreID }); i == len(storeIDs) || storeIDs[i] != leaderStoreID {
+			return nil, errcode.NewInvalidInputErr(errors.Errorf("the leader store %d is not one of the stores", leaderStoreID))
+		}
+	}
+	for _, storeID := range append([]uint64{leaderStoreID}, storeIDs...) {
+		if storeID == 0 {
+			continue
+		}
+		store := c.GetStore(storeID)
+		if store == nil {
+			return nil, core.NewStoreNotFoundErr(storeID)
+		}
+		if store.IsTombstone() {
+			return nil, core.StoreTombstonedErr{StoreID: storeID}
+		}
+		if store.IsLearnerOnly() {
+			return nil, errcode.NewInvalidInputErr(errors.Errorf("store %d only holds learners", storeID))
+		}
+	}
+	for _, regionID := range regionIDs {
+		if c.GetRegion(regionID) == nil {
+			return nil, errcode.NewNotFoundErr(errors.Errorf("region %d not found", regionID))
+		}
+	}
+
+	id, err := c.id.Alloc()
+	if err != nil {
+		return nil, err
+	}
+	intent := &core.PlacementIntent{
+		ID:            id,
+		RegionIDs:     regionIDs,
+		LeaderStoreID: leaderStoreID,
+		StoreIDs:      storeIDs,
+		Reason:        reason,
+		CreateTime:    time.Now(),
+	}
+	c.intents.Lock()
+	defer c.intents.Unlock()
+	for _, regionID := range regionIDs {
+		if other, ok := c.intents.regions[regionID]; ok {
+			return nil, errcode.NewInvalidInputErr(errors.Errorf("region %d belongs to intent %d", regionID, other.ID))
+		}
+	}
+	if err := c.storage.SavePlacementIntent(id, intent); err != nil {
+		return nil, err
+	}
+	c.intents.addLocked(intent)
+	log.Info("placement intent is added",
+		zap.Uint64("intent-id", id),
+		zap.Int("region-count", len(regionIDs)),
+		zap.Uint64("leader-store-id", leaderStoreID),
+		zap.Uint64s("store-ids", storeIDs),
+		zap.String("reason", reason))
+	return intent, nil
+}
+
+// splitPlacementIntent adds the regions split from the origin to the intent of
+// the origin, so that the whole range of the origin is still placed as
+// declared.
+func (c *RaftCluster) splitPlacementIntent(originID uint64, regionIDs []uint64) {
+	c.intents.Lock()
+	defer c.intents.Unlock()
+	intent, ok := c.intents.regions[originID]
+	if !ok {
+		return
+	}
+	updated := *intent
+	updated.RegionIDs = append([]uint64(nil), intent.RegionIDs...)
+	for _, regionID := range regionIDs {
+		if _, ok := c.intents.regions[regionID]; !ok {
+			updated.RegionIDs = append(updated.RegionIDs, regionID)
+		}
+	}
+	sort.Slice(updated.RegionIDs, func(i, j int) bool { return updated.RegionIDs[i] < updated.RegionIDs[j] })
+	if err := c.updatePlacementIntentLocked(&updated); err != nil {
+		log.Error("failed to add split regions to placement intent", zap.Uint64("intent-id", intent.ID), zap.Error(err))
+	}
+}
+
+// checkPlacementIntents drops the merged regions from their intents, and
+// removes the intents without regions left.
+func (c *RaftCluster) checkPlacementIntents() {
+	c.intents.Lock()
+	defer c.intents.Unlock()
+	for regionID := range c.intents.merged {
+		intent, ok := c.intents.regions[regionID]
+		if !ok {
+			delete(c.intents.merged, regionID)
+			continue
+		}
+		updated := *intent
+		updated.RegionIDs = make([]uint64, 0, len(intent.RegionIDs))
+		for _, id := range intent.RegionIDs {
+			if id != regionID {
+				updated.RegionIDs = append(updated.RegionIDs, id)
+			}
+		}
+		var err error
+		if len(updated.RegionIDs) == 0 {
+			err = c.deletePlacementIntentLocked(intent)
+		} else {
+			err = c.updatePlacementIntentLocked(&updated)
+		}
+		if err != nil {
+			log.Error("failed to drop merged region from placement intent", zap.Uint64("intent-id", intent.ID), zap.Uint64("region-id", regionID), zap.Error(err))
+			continue
+		}
+		delete(c.intents.regions, regionID)
+		delete(c.intents.blocked, regionID)
+		delete(c.intents.merged, regionID)
+		log.Info("merged region is dropped from placement intent", zap.Uint64("intent-id", intent.ID), zap.Uint64("region-id", regionID))
+	}
+}
+
+// updatePlacementIntentLocked persists the intent and replaces the one of the
+// same ID. The intents are never modified in place since they are read
+// without the lock once returned.
+func (c *RaftCluster) updatePlacementIntentLocked(intent *core.PlacementIntent) error {
+	if err := c.storage.SavePlacementIntent(intent.ID, intent); err != nil {
+		return err
+	}
+	c.intents.addLocked(intent)
+	return nil
+}
+
+// DeletePlacementIntent removes the intent. The regions stay where they are.
+func (c *RaftCluster) DeletePlacementIntent(id uint64) error {
+	c.intents.Lock()
+	defer c.intents.Unlock()
+	intent, ok := c.intents.intents[id]
+	if !ok {
+		return errcode.NewNotFoundErr(errors.Errorf("placement intent %d not found", id))
+	}
+	return c.deletePlacementIntentLocked(intent)
+}
+
+func (c *RaftCluster) deletePlacementIntentLocked(intent *core.PlacementIntent) error {
+	if err := c.storage.DeletePlacementIntent(intent.ID); err != nil {
+		return err
+	}
+	delete(c.intents.intents, intent.ID)
+	for _, regionID := range intent.RegionIDs {
+		delete(c.intents.regions, regionID)
+		delete(c.intents.blocked, regionID)
+		delete(c.intents.merged, regionID)
+	}
+	log.Info("placement intent is deleted", zap.Uint64("intent-id", intent.ID))
+	return nil
+}
+
+// GetPlacementIntentStatus returns the status of the intent.
+func (c *RaftCluster) GetPlacementIntentStatus(id uint64) (*PlacementIntentStatus, error) {
+	c.intents.RLock()
+	defer c.intents.RUnlock()
+	intent, ok := c.intents.intents[id]
+	if !ok {
+		return nil, errcode.NewNotFoundErr(errors.Errorf("placement intent %d not found", id))
+	}
+	return c.getPlacementIntentStatusLocked(intent), nil
+}
+
+// GetPlacementIntentStatuses returns the statuses of the intents in the order
+// of IDs.
+func (c *RaftCluster) GetPlacementIntentStatuses() []*PlacementIntentStatus {
+	c.intents.RLock()
+	defer c.intents.RUnlock()
+	statuses := make([]*PlacementIntentStatus, 0, len(c.intents.intents))
+	for _, intent := range c.intents.intents {
+		statuses = append(statuses, c.getPlacementIntentStatusLocked(intent))
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Intent.ID < statuses[j].Intent.ID })
+	return statuses
+}
+
+func (c *RaftCluster) getPlacementIntentStatusLocked(intent *core.PlacementIntent) *PlacementIntentStatus {
+	status := &PlacementIntentStatus{Intent: intent}
+	for _, regionID := range intent.RegionIDs {
+		region := c.GetRegion(regionID)
+		reason := c.intents.blocked[regionID]
+		switch {
+		case region == nil:
+			reason = "the region is not found"
+		case intent.IsConverged(region):
+			status.Converged++
+			continue
+		case reason == "":
+			status.InProgress++
+			continue
+		}
+		status.BlockedCount++
+		if len(status.Blocked) < maxIntentBlockedRegions {
+			status.Blocked = append(status.Blocked, &IntentBlockedRegion{RegionID: regionID, Reason: reason})
+		}
+	}
+	switch {
+	case status.BlockedCount > 0:
+		status.State = IntentBlocked
+	case status.InProgress > 0:
+		status.State = IntentInProgress
+	default:
+		status.State = IntentConverged
+	}
+	return status
+}
+
+// checkPlacementIntent returns the operator to converge the region to its
+// intent, or nil if the region has no intent, is converged or is blocked. The
+// peers are moved first, and then the leader.
+func (c *RaftCluster) checkPlacementIntent(region *core.RegionInfo) *operator.Operator {
+	intent := c.intents.get(region.GetID())
+	if intent == nil {
+		return nil
+	}
+	op, reason := c.reconcilePlacementIntent(intent, region)
+	c.intents.setBlocked(region.GetID(), reason)
+	return op
+}
+
+// GetPlacementIntent returns the placement intent of the region, or nil if
+// the region has no intent.
+func (c *RaftCluster) GetPlacementIntent(regionID uint64) *core.PlacementIntent {
+	return c.intents.get(regionID)
+}
+
+// setPlacementIntentBlocked records that the region is blocked, such as when
+// the operator to converge it is rejected.
+func (c *RaftCluster) setPlacementIntentBlocked(regionID uint64, reason string) {
+	c.intents.setBlocked(regionID, reason)
+}
+
+// reconcilePlacementIntent returns the operator to converge the region, or
+// why it is blocked.
+func (c *RaftCluster) reconcilePlacementIntent(intent *core.PlacementIntent, region *core.RegionInfo) (*operator.Operator, string) {
+	if len(intent.StoreIDs) > 0 && len(intent.StoreIDs) != c.GetMaxReplicas() {
+		return nil, fmt.Sprintf("the intent has %d stores, not the max replicas %d", len(intent.StoreIDs), c.GetMaxReplicas())
+	}
+	if len(intent.StoreIDs) > 0 && !intent.HasPeers(region) {
+		stores := make(map[uint64]struct{}, len(intent.StoreIDs))
+		for _, storeID := range intent.StoreIDs {
+			if reason := c.checkIntentStore(storeID); reason != "" {
+				return nil, reason
+			}
+			stores[storeID] = struct{}{}
+		}
+		op, err := operator.CreateMoveRegionOperator(placementIntentDesc, c, region, operator.OpRegion, stores)
+		if err != nil {
+			return nil, err.Error()
+		}
+		return op, ""
+	}
+	if intent.LeaderStoreID != 0 && region.GetLeader().GetStoreId() != intent.LeaderStoreID {
+		if region.GetStoreVoter(intent.LeaderStoreID) == nil {
+			return nil, fmt.Sprintf("the region has no voter in store %d", intent.LeaderStoreID)
+		}
+		if reason := c.checkIntentStore(intent.LeaderStoreID); reason != "" {
+			return nil, reason
+		}
+		return operator.CreateTransferLeaderOperator(placementIntentDesc, region, region.GetLeader().GetStoreId(), intent.LeaderStoreID, operator.OpLeader), ""
+	}
+	return nil, ""
+}
+
+// checkIntentStore returns why the regions cannot be moved to the store, or
+// "" if they can.
+func (c *RaftCluster) checkIntentStore(storeID uint64) string {
+	store := c.GetStore(storeID)
+	switch {
+	case store == nil:
+		return fmt.Sprintf("store %d is not found", storeID)
+	case store.IsTombstone():
+		return fmt.Sprintf("store %d is tombstone", storeID)
+	case !store.IsUp():
+		return fmt.Sprintf("store %d is offline", storeID)
+	case store.DownTime() > c.GetMaxStoreDownTime():
+		return fmt.Sprintf("store %d is down", storeID)
+	case store.IsBlocked():
+		return fmt.Sprintf("store %d is blocked", storeID)
+	}
+	return ""
+}
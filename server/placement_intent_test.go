@@ -0,0 +1,175 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/schedule/operator"
+)
+
+var _ = Suite(&testPlacementIntentSuite{})
+
+type testPlacementIntentSuite struct{}
+
+func (s *testPlacementIntentSuite) TestAddPlacementIntent(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	tc := newTestCluster(opt)
+	for i := uint64(1); i <= 4; i++ {
+		c.Assert(tc.addRegionStore(i, 1), IsNil)
+	}
+	c.Assert(tc.addLeaderRegion(1, 1, 2, 3), IsNil)
+	c.Assert(tc.addLeaderRegion(2, 1, 2, 3), IsNil)
+
+	_, err = tc.AddPlacementIntent(nil, 1, nil, "")
+	c.Assert(err, NotNil)
+	_, err = tc.AddPlacementIntent([]uint64{1}, 0, nil, "")
+	c.Assert(err, NotNil)
+	_, err = tc.AddPlacementIntent([]uint64{1, 1}, 1, nil, "")
+	c.Assert(err, NotNil)
+	_, err = tc.AddPlacementIntent([]uint64{1}, 0, []uint64{1, 2, 3, 4}, "")
+	c.Assert(err, NotNil)
+	_, err = tc.AddPlacementIntent([]uint64{1}, 0, []uint64{1, 2}, "")
+	c.Assert(err, NotNil)
+	_, err = tc.AddPlacementIntent([]uint64{1}, 4, []uint64{1, 2, 3}, "")
+	c.Assert(err, NotNil)
+	_, err = tc.AddPlacementIntent([]uint64{1}, 5, nil, "")
+	c.Assert(err, NotNil)
+	_, err = tc.AddPlacementIntent([]uint64{3}, 1, nil, "")
+	c.Assert(err, NotNil)
+
+	intent, err := tc.AddPlacementIntent([]uint64{2, 1}, 2, nil, "test")
+	c.Assert(err, IsNil)
+	c.Assert(intent.RegionIDs, DeepEquals, []uint64{1, 2})
+	// A region belongs to at most one intent.
+	_, err = tc.AddPlacementIntent([]uint64{2}, 3, nil, "")
+	c.Assert(err, NotNil)
+
+	// The intents are persisted.
+	c.Assert(tc.loadPlacementIntents(), IsNil)
+	c.Assert(tc.GetPlacementIntentStatuses(), HasLen, 1)
+	c.Assert(tc.DeletePlacementIntent(intent.ID), IsNil)
+	c.Assert(tc.DeletePlacementIntent(intent.ID), NotNil)
+	c.Assert(tc.loadPlacementIntents(), IsNil)
+	c.Assert(tc.GetPlacementIntentStatuses(), HasLen, 0)
+	c.Assert(tc.checkPlacementIntent(tc.GetRegion(1)), IsNil)
+}
+
+func (s *testPlacementIntentSuite) TestCheckPlacementIntent(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	tc := newTestCluster(opt)
+	for i := uint64(1); i <= 4; i++ {
+		c.Assert(tc.addRegionStore(i, 1), IsNil)
+	}
+	c.Assert(tc.addLeaderRegion(1, 1, 2, 3), IsNil)
+	c.Assert(tc.addLeaderRegion(2, 2, 3, 4), IsNil)
+
+	intent, err := tc.AddPlacementIntent([]uint64{1, 2}, 4, []uint64{2, 3, 4}, "")
+	c.Assert(err, IsNil)
+	status, err := tc.GetPlacementIntentStatus(intent.ID)
+	c.Assert(err, IsNil)
+	c.Assert(status.State, Equals, IntentInProgress)
+	c.Assert(status.InProgress, Equals, 2)
+
+	// The peers are moved first.
+	op := tc.checkPlacementIntent(tc.GetRegion(1))
+	c.Assert(op, NotNil)
+	c.Assert(op.Desc(), Equals, placementIntentDesc)
+	c.Assert(op.Kind()&operator.OpRegion, Equals, operator.OpRegion)
+	// Then the leader.
+	op = tc.checkPlacementIntent(tc.GetRegion(2))
+	c.Assert(op, NotNil)
+	c.Assert(op.Kind()&operator.OpLeader, Equals, operator.OpLeader)
+	c.Assert(op.Step(0), Equals, operator.TransferLeader{FromStore: 2, ToStore: 4})
+
+	// The region is blocked since the target store is offline.
+	store := tc.GetStore(4).Clone(core.SetStoreState(metapb.StoreState_Offline))
+	tc.Lock()
+	c.Assert(tc.putStoreLocked(store), IsNil)
+	tc.Unlock()
+	c.Assert(tc.checkPlacementIntent(tc.GetRegion(2)), IsNil)
+	status, err = tc.GetPlacementIntentStatus(intent.ID)
+	c.Assert(err, IsNil)
+	c.Assert(status.State, Equals, IntentBlocked)
+	c.Assert(status.BlockedCount, Equals, 1)
+	c.Assert(status.Blocked[0].RegionID, Equals, uint64(2))
+
+	// The region is converged.
+	store = tc.GetStore(4).Clone(core.SetStoreState(metapb.StoreState_Up))
+	tc.Lock()
+	c.Assert(tc.putStoreLocked(store), IsNil)
+	tc.Unlock()
+	c.Assert(tc.addLeaderRegion(2, 4, 2, 3), IsNil)
+	c.Assert(tc.checkPlacementIntent(tc.GetRegion(2)), IsNil)
+	status, err = tc.GetPlacementIntentStatus(intent.ID)
+	c.Assert(err, IsNil)
+	c.Assert(status.State, Equals, IntentInProgress)
+	c.Assert(status.Converged, Equals, 1)
+	c.Assert(status.BlockedCount, Equals, 0)
+
+	c.Assert(tc.addLeaderRegion(1, 4, 2, 3), IsNil)
+	status, err = tc.GetPlacementIntentStatus(intent.ID)
+	c.Assert(err, IsNil)
+	c.Assert(status.State, Equals, IntentConverged)
+	c.Assert(status.Converged, Equals, 2)
+}
+
+func (s *testPlacementIntentSuite) TestPlacementIntentSplitMerge(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	tc := newTestCluster(opt)
+	for i := uint64(1); i <= 3; i++ {
+		c.Assert(tc.addRegionStore(i, 1), IsNil)
+	}
+	c.Assert(tc.addLeaderRegion(1, 1, 2, 3), IsNil)
+	c.Assert(tc.addLeaderRegion(2, 1, 2, 3), IsNil)
+	intent, err := tc.AddPlacementIntent([]uint64{1}, 1, nil, "")
+	c.Assert(err, IsNil)
+	getRegionIDs := func() []uint64 {
+		status, err := tc.GetPlacementIntentStatus(intent.ID)
+		if err != nil {
+			return nil
+		}
+		return status.Intent.RegionIDs
+	}
+
+	// The split region is added to the intent of the origin.
+	origin := tc.GetRegion(1)
+	splitKey := append(append([]byte(nil), origin.GetStartKey()...), 'x')
+	left := origin.Clone(core.WithNewRegionID(5), core.WithEndKey(splitKey), core.WithIncVersion())
+	right := origin.Clone(core.WithStartKey(splitKey), core.WithIncVersion())
+	_, err = tc.handleReportSplit(&pdpb.ReportSplitRequest{Left: left.GetMeta(), Right: right.GetMeta()})
+	c.Assert(err, IsNil)
+	c.Assert(getRegionIDs(), DeepEquals, []uint64{1, 5})
+	c.Assert(tc.processRegionHeartbeat(right), IsNil)
+	c.Assert(tc.processRegionHeartbeat(left), IsNil)
+
+	// The merged region is dropped from the intent.
+	merged := right.Clone(core.WithStartKey(origin.GetStartKey()), core.WithIncVersion())
+	c.Assert(tc.processRegionHeartbeat(merged), IsNil)
+	tc.checkPlacementIntents()
+	c.Assert(getRegionIDs(), DeepEquals, []uint64{1})
+	c.Assert(tc.loadPlacementIntents(), IsNil)
+	c.Assert(getRegionIDs(), DeepEquals, []uint64{1})
+
+	// The intent is removed once all its regions are merged.
+	merged = tc.GetRegion(2).Clone(core.WithStartKey(origin.GetStartKey()), core.WithIncVersion())
+	c.Assert(tc.processRegionHeartbeat(merged), IsNil)
+	tc.checkPlacementIntents()
+	c.Assert(tc.GetPlacementIntentStatuses(), HasLen, 0)
+}
@@ -0,0 +1,186 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/pkg/etcdutil"
+	"github.com/pingcap/pd/pkg/logutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// The dependencies checked for the readiness of a PD member.
+const (
+	EtcdQuorumCheck = "etcd-quorum"
+	LeadershipCheck = "leadership"
+	TSOCheck        = "tso"
+	BootstrapCheck  = "bootstrap"
+)
+
+// ReadinessCheck is the result of checking one dependency of a PD member.
+type ReadinessCheck struct {
+	Name    string `json:"name"`
+	Ready   bool   `json:"ready"`
+	Message string `json:"message,omitempty"`
+}
+
+// CheckReadiness checks whether the member is able to serve the clients. It
+// checks the etcd quorum, the leadership, the TSO availability and the
+// bootstrap status separately, and returns true only if all of them pass.
+func (s *Server) CheckReadiness() (bool, []ReadinessCheck) {
+	checks := []ReadinessCheck{
+		s.checkEtcdQuorum(),
+		s.checkLeadership(),
+		s.checkTSO(),
+		s.checkBootstrap(),
+	}
+	for _, check := range checks {
+		if !check.Ready {
+			return false, checks
+		}
+	}
+	return true, checks
+}
+
+// checkEtcdQuorum checks the etcd quorum by a linearizable read, which fails
+// if the etcd cluster loses the quorum.
+func (s *Server) checkEtcdQuorum() ReadinessCheck {
+	check := ReadinessCheck{Name: EtcdQuorumCheck}
+	if _, err := etcdutil.EtcdKVGet(s.client, s.clusterIDPath()); err != nil {
+		check.Message = err.Error()
+		return check
+	}
+	check.Ready = true
+	return check
+}
+
+func (s *Server) checkLeadership() ReadinessCheck {
+	check := ReadinessCheck{Name: LeadershipCheck}
+	leader := s.GetLeader()
+	if leader == nil {
+		check.Message = "no leader is elected"
+		return check
+	}
+	check.Ready = true
+	if s.member.IsLeader() {
+		check.Message = "the member is the leader"
+	} else {
+		check.Message = fmt.Sprintf("the leader is %s", leader.GetName())
+	}
+	return check
+}
+
+// checkTSO checks whether the leader is able to allocate timestamps. The
+// followers only check the existence of the leader, since timestamps are
+// always allocated by the leader.
+func (s *Server) checkTSO() ReadinessCheck {
+	check := ReadinessCheck{Name: TSOCheck}
+	if !s.member.IsLeader() {
+		if s.GetLeader() == nil {
+			check.Message = "no leader allocates timestamps"
+			return check
+		}
+		check.Ready = true
+		check.Message = "timestamps are allocated by the leader"
+		return check
+	}
+	if !s.tso.IsAvailable() {
+		check.Message = "timestamp is not synced or the lease is expired"
+		return check
+	}
+	check.Ready = true
+	return check
+}
+
+// checkBootstrap checks whether the cluster is bootstrapped. The leader also
+// checks that the cluster is running.
+func (s *Server) checkBootstrap() ReadinessCheck {
+	check := ReadinessCheck{Name: BootstrapCheck}
+	if s.member.IsLeader() {
+		if s.GetRaftCluster() == nil {
+			check.Message = "cluster is not bootstrapped or not running"
+			return check
+		}
+		check.Ready = true
+		return check
+	}
+	value, err := etcdutil.GetValue(s.client, s.getClusterRootPath())
+	if err != nil {
+		check.Message = err.Error()
+		return check
+	}
+	if value == nil {
+		check.Message = "cluster is not bootstrapped"
+		return check
+	}
+	check.Ready = true
+	return check
+}
+
+const (
+	// pdServiceName is the service name of PD in the gRPC health checking
+	// protocol. The empty service name reports the health of the embedded
+	// etcd.
+	pdServiceName     = "pdpb.PD"
+	healthServiceName = "grpc.health.v1.Health"
+	// healthCheckInterval is the interval to report the readiness through
+	// the gRPC health checking protocol.
+	healthCheckInterval = 3 * time.Second
+)
+
+// registerHealthServer registers the health server to report the readiness
+// of PD on the gRPC server. The embedded etcd registers its own health service
+// on the gRPC servers shared with PD, which can not be registered again, in
+// which case the readiness is only reported through the HTTP /ready API.
+func (s *Server) registerHealthServer(gs *grpc.Server, hsrv *health.Server) {
+	if _, ok := gs.GetServiceInfo()[healthServiceName]; ok {
+		log.Warn("the gRPC health service is registered already, the readiness is only reported through HTTP")
+		return
+	}
+	hsrv.SetServingStatus(pdServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(gs, hsrv)
+}
+
+func (s *Server) setServingStatus(status healthpb.HealthCheckResponse_ServingStatus) {
+	s.healthServer.SetServingStatus(pdServiceName, status)
+}
+
+// healthCheckLoop reports the readiness of PD through the gRPC health
+// checking protocol periodically.
+func (s *Server) healthCheckLoop() {
+	defer logutil.LogPanic()
+	defer s.serverLoopWg.Done()
+
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		status := healthpb.HealthCheckResponse_NOT_SERVING
+		if ready, _ := s.CheckReadiness(); ready {
+			status = healthpb.HealthCheckResponse_SERVING
+		}
+		s.setServingStatus(status)
+		select {
+		case <-ticker.C:
+		case <-s.serverLoopCtx.Done():
+			s.setServingStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+			log.Info("server is closed, exit health check loop")
+			return
+		}
+	}
+}
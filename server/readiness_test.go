@@ -0,0 +1,84 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/pd/pkg/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Suite(&testReadinessSuite{})
+
+type testReadinessSuite struct {
+	baseCluster
+}
+
+func (s *testReadinessSuite) TestHealthCheck(c *C) {
+	var cleanup func()
+	var err error
+	_, s.svr, cleanup, err = NewTestServer(c)
+	c.Assert(err, IsNil)
+	defer cleanup()
+	mustWaitLeader(c, []*Server{s.svr})
+
+	newHealthClient := func(addr string) (healthpb.HealthClient, func()) {
+		conn, err := grpc.Dial(addr, grpc.WithInsecure())
+		c.Assert(err, IsNil)
+		return healthpb.NewHealthClient(conn), func() { conn.Close() }
+	}
+	checkStatus := func(client healthpb.HealthClient, service string) (healthpb.HealthCheckResponse_ServingStatus, error) {
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		return resp.GetStatus(), err
+	}
+
+	// The embedded etcd registers its own health service on the gRPC server
+	// shared with PD, which only reports the health of etcd.
+	etcdClient, closeEtcd := newHealthClient(strings.TrimPrefix(s.svr.GetAddr(), "http://"))
+	defer closeEtcd()
+	st, err := checkStatus(etcdClient, "")
+	c.Assert(err, IsNil)
+	c.Assert(st, Equals, healthpb.HealthCheckResponse_SERVING)
+	_, err = checkStatus(etcdClient, pdServiceName)
+	c.Assert(status.Code(err), Equals, codes.NotFound)
+
+	// PD reports the readiness on the gRPC servers it registers the health
+	// service on.
+	gs := grpc.NewServer()
+	s.svr.registerHealthServer(gs, s.svr.healthServer)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	go gs.Serve(l)
+	defer gs.Stop()
+	client, closeClient := newHealthClient(l.Addr().String())
+	defer closeClient()
+	st, err = checkStatus(client, pdServiceName)
+	c.Assert(err, IsNil)
+	c.Assert(st, Equals, healthpb.HealthCheckResponse_NOT_SERVING)
+
+	s.grpcPDClient = testutil.MustNewGrpcClient(c, s.svr.GetAddr())
+	_, err = s.svr.bootstrapCluster(s.newBootstrapRequest(c, s.svr.clusterID, "127.0.0.1:0"))
+	c.Assert(err, IsNil)
+	testutil.WaitUntil(c, func(c *C) bool {
+		st, err := checkStatus(client, pdServiceName)
+		return err == nil && st == healthpb.HealthCheckResponse_SERVING
+	})
+}
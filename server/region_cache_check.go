@@ -0,0 +1,261 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"sort"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// regionCacheShardSize is the number of regions in a shard of the region
+	// cache, whose hashes are compared between the leader and the followers.
+	regionCacheShardSize = 1024
+	// maxResyncRegionsPerCheck is the most regions synchronized again in a
+	// check. The rest of the divergent shards are left to the next checks.
+	maxResyncRegionsPerCheck = 4 * regionCacheShardSize
+	regionCacheHashURL       = "/pd/api/v1/region-cache/hash"
+)
+
+// RegionCacheShard is a key range of the region cache with the hash of the
+// regions starting in it. The keys are hex encoded, and an empty end key
+// means the end of the key space.
+type RegionCacheShard struct {
+	StartKey string `json:"start_key"`
+	EndKey   string `json:"end_key"`
+	Count    int    `json:"count"`
+	Hash     uint64 `json:"hash"`
+}
+
+// hashRegions hashes the metas of the regions, which are synchronized to the
+// followers. The leaders and the statistics are not hashed, since they are
+// not synchronized.
+func hashRegions(regions []*core.RegionInfo) uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	writeUint64 := func(v uint64) {
+		binary.BigEndian.PutUint64(buf[:], v)
+		h.Write(buf[:])
+	}
+	writeBytes := func(b []byte) {
+		writeUint64(uint64(len(b)))
+		h.Write(b)
+	}
+	for _, region := range regions {
+		writeUint64(region.GetID())
+		writeBytes(region.GetStartKey())
+		writeBytes(region.GetEndKey())
+		writeUint64(region.GetRegionEpoch().GetVersion())
+		writeUint64(region.GetRegionEpoch().GetConfVer())
+		peers := append([]*metapb.Peer(nil), region.GetPeers()...)
+		sort.Slice(peers, func(i, j int) bool { return peers[i].GetId() < peers[j].GetId() })
+		for _, p := range peers {
+			writeUint64(p.GetId())
+			writeUint64(p.GetStoreId())
+			if p.GetIsLearner() {
+				writeUint64(1)
+			} else {
+				writeUint64(0)
+			}
+		}
+	}
+	return h.Sum64()
+}
+
+// shardRegionCache splits the regions into the shards of shardSize regions,
+// and hashes each shard.
+func shardRegionCache(bc *core.BasicCluster, shardSize int) []*RegionCacheShard {
+	var shards []*RegionCacheShard
+	var startKey []byte
+	for {
+		regions := bc.ScanRange(startKey, nil, shardSize+1)
+		shard := &RegionCacheShard{StartKey: hex.EncodeToString(startKey)}
+		var next []byte
+		if len(regions) > shardSize {
+			next = regions[shardSize].GetStartKey()
+			shard.EndKey = hex.EncodeToString(next)
+			regions = regions[:shardSize]
+		}
+		shard.Count = len(regions)
+		shard.Hash = hashRegions(regions)
+		shards = append(shards, shard)
+		if len(next) == 0 {
+			return shards
+		}
+		startKey = next
+	}
+}
+
+// hashRegionCache hashes the regions in the key ranges of the shards.
+func hashRegionCache(bc *core.BasicCluster, shards []*RegionCacheShard) ([]*RegionCacheShard, error) {
+	hashes := make([]*RegionCacheShard, 0, len(shards))
+	for _, shard := range shards {
+		startKey, endKey, err := decodeShardKeys(shard)
+		if err != nil {
+			return nil, err
+		}
+		regions := bc.ScanRange(startKey, endKey, 0)
+		hashes = append(hashes, &RegionCacheShard{
+			StartKey: shard.StartKey,
+			EndKey:   shard.EndKey,
+			Count:    len(regions),
+			Hash:     hashRegions(regions),
+		})
+	}
+	return hashes, nil
+}
+
+func decodeShardKeys(shard *RegionCacheShard) ([]byte, []byte, error) {
+	startKey, err := hex.DecodeString(shard.StartKey)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	endKey, err := hex.DecodeString(shard.EndKey)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	return startKey, endKey, nil
+}
+
+// HashSyncedRegions hashes the regions synchronized from the leader in the
+// key ranges of the shards. It fails if the member is not synchronizing the
+// regions, such as being the leader.
+func (s *Server) HashSyncedRegions(shards []*RegionCacheShard) ([]*RegionCacheShard, error) {
+	s.cluster.RLock()
+	standby := s.cluster.standby
+	s.cluster.RUnlock()
+	if standby == nil {
+		return nil, errors.New("the regions are not synchronized from the leader")
+	}
+	return hashRegionCache(standby.core, shards)
+}
+
+// checkRegionCaches compares the hashes of the shards of the region cache
+// with the ones of the regions synchronized by the followers, and synchronizes
+// the divergent shards again, so that a stale follower is detected and fixed
+// before it becomes the leader.
+func (c *RaftCluster) checkRegionCaches() {
+	if !c.opt.LoadPDServerConfig().UseRegionStorage {
+		return
+	}
+	members, err := GetMembers(c.s.GetClient())
+	if err != nil {
+		log.Error("failed to get members to check region caches", zap.Error(err))
+		return
+	}
+	shards := shardRegionCache(c.core, regionCacheShardSize)
+	divergent := make(map[int]struct{})
+	for _, m := range members {
+		if m.GetMemberId() == c.s.GetMember().ID() || len(m.GetClientUrls()) == 0 {
+			continue
+		}
+		hashes, err := requestRegionCacheHashes(m.GetClientUrls()[0], shards)
+		if err != nil {
+			log.Warn("failed to get region cache hashes of follower", zap.String("member", m.GetName()), zap.Error(err))
+			continue
+		}
+		indexes := diffRegionCacheShards(shards, hashes)
+		regionCacheDivergenceGauge.WithLabelValues(m.GetName()).Set(float64(len(indexes)))
+		for _, i := range indexes {
+			log.Warn("region cache of follower diverges from leader",
+				zap.String("member", m.GetName()),
+				zap.String("start-key", shards[i].StartKey),
+				zap.String("end-key", shards[i].EndKey),
+				zap.Int("leader-count", shards[i].Count),
+				zap.Int("follower-count", hashes[i].Count))
+			divergent[i] = struct{}{}
+		}
+	}
+	budget := maxResyncRegionsPerCheck
+	for i := range divergent {
+		if budget <= 0 {
+			break
+		}
+		budget -= c.resyncRegionCacheShard(shards[i], budget)
+	}
+}
+
+// diffRegionCacheShards returns the indexes of the shards whose hashes differ.
+func diffRegionCacheShards(leader, follower []*RegionCacheShard) []int {
+	var indexes []int
+	for i, shard := range leader {
+		if i >= len(follower) || follower[i].Hash != shard.Hash || follower[i].Count != shard.Count {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// resyncRegionCacheShard synchronizes at most limit regions in the shard to
+// the followers again, and returns the number of them. The regions
+// overlapping them in the caches of the followers are replaced. It never
+// blocks, and stops once half of the buffer of the changed regions is used,
+// so that the changes of the heartbeats are not dropped for it. The shard
+// still diverges then, and is synchronized again by the next checks.
+func (c *RaftCluster) resyncRegionCacheShard(shard *RegionCacheShard, limit int) int {
+	startKey, endKey, err := decodeShardKeys(shard)
+	if err != nil {
+		return 0
+	}
+	regions := c.core.ScanRange(startKey, endKey, limit)
+	var sent int
+	for _, region := range regions {
+		if len(c.changedRegions) >= cap(c.changedRegions)/2 {
+			break
+		}
+		select {
+		case c.changedRegions <- region:
+			sent++
+		default:
+		}
+	}
+	regionEventCounter.WithLabelValues("resync_cache_shard").Inc()
+	log.Info("synchronize region cache shard again",
+		zap.String("start-key", shard.StartKey),
+		zap.String("end-key", shard.EndKey),
+		zap.Int("regions", sent),
+		zap.Int("total", len(regions)))
+	return sent
+}
+
+func requestRegionCacheHashes(clientURL string, shards []*RegionCacheShard) ([]*RegionCacheShard, error) {
+	data, err := json.Marshal(shards)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	resp, err := dialClient.Post(clientURL+regionCacheHashURL, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status %s", resp.Status)
+	}
+	var hashes []*RegionCacheShard
+	if err := json.NewDecoder(resp.Body).Decode(&hashes); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return hashes, nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/pkg/mock/mockid"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/kv"
+)
+
+var _ = Suite(&testRegionCacheCheckSuite{})
+
+type testRegionCacheCheckSuite struct{}
+
+func (s *testRegionCacheCheckSuite) TestShards(c *C) {
+	regions := newTestRegions(10, 3)
+	leader, follower := core.NewBasicCluster(), core.NewBasicCluster()
+	for _, region := range regions {
+		leader.PutRegion(region)
+		// The leaders are not synchronized to the followers.
+		follower.PutRegion(core.NewRegionInfo(region.GetMeta(), nil))
+	}
+
+	shards := shardRegionCache(leader, 3)
+	c.Assert(shards, HasLen, 4)
+	c.Assert(shards[0].StartKey, Equals, "")
+	c.Assert(shards[0].EndKey, Equals, "03")
+	c.Assert(shards[3].StartKey, Equals, "09")
+	c.Assert(shards[3].EndKey, Equals, "")
+	c.Assert(shards[3].Count, Equals, 1)
+	hashes, err := hashRegionCache(follower, shards)
+	c.Assert(err, IsNil)
+	c.Assert(diffRegionCacheShards(shards, hashes), HasLen, 0)
+
+	// The follower misses a conf change of region 4 and the last region.
+	stale := regions[4].Clone(core.WithAddPeer(&metapb.Peer{Id: 100, StoreId: 9}), core.WithIncConfVer())
+	leader.PutRegion(stale)
+	leader.RemoveRegion(regions[9])
+	shards = shardRegionCache(leader, 3)
+	hashes, err = hashRegionCache(follower, shards)
+	c.Assert(err, IsNil)
+	c.Assert(diffRegionCacheShards(shards, hashes), DeepEquals, []int{1, 2})
+
+	_, err = hashRegionCache(follower, []*RegionCacheShard{{StartKey: "x"}})
+	c.Assert(err, NotNil)
+}
+
+func (s *testRegionCacheCheckSuite) TestResync(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cluster := createTestRaftCluster(mockid.NewIDAllocator(), opt, core.NewStorage(kv.NewMemoryKV()))
+	cluster.quit = make(chan struct{})
+	for _, region := range newTestRegions(10, 3) {
+		cluster.core.PutRegion(region)
+	}
+	shards := shardRegionCache(cluster.core, 4)
+	c.Assert(cluster.resyncRegionCacheShard(shards[1], 0), Equals, 4)
+	c.Assert(cluster.changedRegions, HasLen, 4)
+	for i := uint64(4); i < 8; i++ {
+		c.Assert((<-cluster.changedRegions).GetID(), Equals, i)
+	}
+	c.Assert(cluster.resyncRegionCacheShard(shards[1], 3), Equals, 3)
+	c.Assert(cluster.changedRegions, HasLen, 3)
+
+	// It stops instead of blocking when the buffer is half used.
+	cluster.changedRegions = make(chan *core.RegionInfo, 4)
+	c.Assert(cluster.resyncRegionCacheShard(shards[1], 0), Equals, 2)
+	c.Assert(cluster.changedRegions, HasLen, 2)
+}
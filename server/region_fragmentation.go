@@ -0,0 +1,249 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"github.com/pingcap/pd/server/core"
+)
+
+// fragmentationMetricsInterval is the interval to collect the fragmentation
+// metrics, which scan all the regions. It is slower than the other metrics,
+// since the fragmentation changes slowly.
+const fragmentationMetricsInterval = 10 * time.Minute
+
+// RegionFragmentation is the fragmentation of the regions of a unit decoded
+// from the keys, such as a table. Small regions are the regions under the
+// merge thresholds, and mergeable regions are the ones that merging adjacent
+// small regions could remove.
+type RegionFragmentation struct {
+	StartKey         string  `json:"start_key"`
+	RegionCount      int     `json:"region_count"`
+	SmallRegionCount int     `json:"small_region_count"`
+	StoreCount       int     `json:"store_count"`
+	MergeableCount   int     `json:"mergeable_count"`
+	Score            float64 `json:"score"`
+}
+
+// DefragStep merges the source region into the adjacent target region. Steps
+// of the same round can run at the same time, and a step runs only after the
+// steps of previous rounds finish.
+type DefragStep struct {
+	Round    int    `json:"round"`
+	SourceID uint64 `json:"source_id"`
+	TargetID uint64 `json:"target_id"`
+}
+
+// DefragPlan is a sequence of merges to defragment the regions of a range.
+type DefragPlan struct {
+	Steps []*DefragStep `json:"steps"`
+	// RoundLimit is the max number of steps in a round.
+	RoundLimit int `json:"round_limit"`
+}
+
+// regionUnit is the regions of a unit and the groups of its adjacent small
+// regions. The regions of a group can be merged into one region without
+// exceeding the merge thresholds.
+type regionUnit struct {
+	startKey []byte
+	regions  int
+	small    []*core.RegionInfo
+	groups   [][]*core.RegionInfo
+}
+
+func (u *regionUnit) mergeableCount() int {
+	var count int
+	for _, group := range u.groups {
+		count += len(group) - 1
+	}
+	return count
+}
+
+func (u *regionUnit) fragmentation() *RegionFragmentation {
+	stores := make(map[uint64]struct{})
+	for _, region := range u.small {
+		for storeID := range region.GetStoreIds() {
+			stores[storeID] = struct{}{}
+		}
+	}
+	f := &RegionFragmentation{
+		StartKey:         hex.EncodeToString(u.startKey),
+		RegionCount:      u.regions,
+		SmallRegionCount: len(u.small),
+		StoreCount:       len(stores),
+		MergeableCount:   u.mergeableCount(),
+	}
+	if f.RegionCount > 0 {
+		f.Score = float64(f.MergeableCount) / float64(f.RegionCount)
+	}
+	return f
+}
+
+func (c *RaftCluster) unitStartKey(key []byte) []byte {
+	if c.keyDecoder == nil {
+		return nil
+	}
+	return c.keyDecoder.UnitStartKey(key)
+}
+
+// isSmallRegion checks if the region is under the merge thresholds and is
+// ready to be merged.
+func (c *RaftCluster) isSmallRegion(region *core.RegionInfo) bool {
+	return region.GetApproximateSize() > 0 &&
+		region.GetApproximateSize() <= int64(c.GetMaxMergeRegionSize()) &&
+		region.GetApproximateKeys() <= int64(c.GetMaxMergeRegionKeys()) &&
+		len(region.GetDownPeers()) == 0 && len(region.GetPendingPeers()) == 0 && len(region.GetLearners()) == 0 &&
+		len(region.GetPeers()) == c.GetMaxReplicas() &&
+		!c.IsRegionHot(region)
+}
+
+// scanRegionUnits groups the regions in [startKey, endKey) by units, and packs
+// the adjacent small regions of each unit into groups.
+func (c *RaftCluster) scanRegionUnits(startKey, endKey []byte) []*regionUnit {
+	classifier := c.coordinator.classifier
+	maxSize, maxKeys := int64(c.GetMaxMergeRegionSize()), int64(c.GetMaxMergeRegionKeys())
+	var (
+		units               []*regionUnit
+		unit                *regionUnit
+		group               []*core.RegionInfo
+		groupSize, groupKey int64
+	)
+	flush := func() {
+		if len(group) > 1 {
+			unit.groups = append(unit.groups, group)
+		}
+		group, groupSize, groupKey = nil, 0, 0
+	}
+	for _, region := range c.ScanRegions(startKey, endKey, 0) {
+		unitKey := c.unitStartKey(region.GetStartKey())
+		if unit == nil || !bytes.Equal(unit.startKey, unitKey) {
+			if unit != nil {
+				flush()
+			}
+			unit = &regionUnit{startKey: unitKey}
+			units = append(units, unit)
+		}
+		unit.regions++
+		if !c.isSmallRegion(region) {
+			flush()
+			continue
+		}
+		unit.small = append(unit.small, region)
+		if len(group) > 0 {
+			last := group[len(group)-1]
+			if !bytes.Equal(last.GetEndKey(), region.GetStartKey()) ||
+				!classifier.AllowMerge(last, region) ||
+				groupSize+region.GetApproximateSize() > maxSize ||
+				groupKey+region.GetApproximateKeys() > maxKeys {
+				flush()
+			}
+		}
+		group = append(group, region)
+		groupSize += region.GetApproximateSize()
+		groupKey += region.GetApproximateKeys()
+	}
+	if unit != nil {
+		flush()
+	}
+	return units
+}
+
+// GetRegionFragmentation returns at most limit fragmented units in descending
+// order of the score. limit <= 0 means no limit.
+func (c *RaftCluster) GetRegionFragmentation(limit int) []*RegionFragmentation {
+	var res []*RegionFragmentation
+	for _, unit := range c.scanRegionUnits(nil, nil) {
+		if len(unit.groups) > 0 {
+			res = append(res, unit.fragmentation())
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Score != res[j].Score {
+			return res[i].Score > res[j].Score
+		}
+		return res[i].MergeableCount > res[j].MergeableCount
+	})
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res
+}
+
+// GetDefragPlan proposes at most maxSteps merges for the regions in
+// [startKey, endKey). The small regions of a group are merged one by one into
+// the next region, and each round runs at most merge-schedule-limit merges.
+// maxSteps <= 0 means no limit.
+func (c *RaftCluster) GetDefragPlan(startKey, endKey []byte, maxSteps int) *DefragPlan {
+	plan := &DefragPlan{Steps: []*DefragStep{}, RoundLimit: int(c.GetMergeScheduleLimit())}
+	if plan.RoundLimit == 0 {
+		return plan
+	}
+	var groups [][]*core.RegionInfo
+	for _, unit := range c.scanRegionUnits(startKey, endKey) {
+		groups = append(groups, unit.groups...)
+	}
+	next := make([]int, len(groups))
+	for round := 1; ; round++ {
+		var count int
+		for i, group := range groups {
+			if count >= plan.RoundLimit || (maxSteps > 0 && len(plan.Steps) >= maxSteps) {
+				break
+			}
+			if next[i] >= len(group)-1 {
+				continue
+			}
+			plan.Steps = append(plan.Steps, &DefragStep{
+				Round:    round,
+				SourceID: group[next[i]].GetID(),
+				TargetID: group[next[i]+1].GetID(),
+			})
+			next[i]++
+			count++
+		}
+		if count == 0 {
+			return plan
+		}
+	}
+}
+
+// collectFragmentationMetrics collects the fragmentation metrics if they are
+// not collected in the last fragmentationMetricsInterval. It is only called by
+// the metrics job.
+func (c *RaftCluster) collectFragmentationMetrics() {
+	if time.Since(c.fragmentationCollectTime) < fragmentationMetricsInterval {
+		return
+	}
+	c.fragmentationCollectTime = time.Now()
+	var regions, small, mergeable, fragmented int
+	for _, unit := range c.scanRegionUnits(nil, nil) {
+		regions += unit.regions
+		small += len(unit.small)
+		mergeable += unit.mergeableCount()
+		if len(unit.groups) > 0 {
+			fragmented++
+		}
+	}
+	var score float64
+	if regions > 0 {
+		score = float64(mergeable) / float64(regions)
+	}
+	regionFragmentationGauge.WithLabelValues("small-region").Set(float64(small))
+	regionFragmentationGauge.WithLabelValues("mergeable-region").Set(float64(mergeable))
+	regionFragmentationGauge.WithLabelValues("fragmented-unit").Set(float64(fragmented))
+	regionFragmentationGauge.WithLabelValues("score").Set(score)
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/namespace"
+)
+
+var _ = Suite(&testRegionFragmentationSuite{})
+
+type testRegionFragmentationSuite struct{}
+
+func (s *testRegionFragmentationSuite) TestFragmentation(c *C) {
+	cfg, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	tc := newTestCluster(opt)
+	hbStreams, cleanup := getHeartBeatStreams(c, tc)
+	defer cleanup()
+	defer hbStreams.Close()
+	tc.RaftCluster.coordinator = newCoordinator(tc.RaftCluster, hbStreams, namespace.DefaultClassifier)
+
+	for i := uint64(1); i <= 3; i++ {
+		c.Assert(tc.addRegionStore(i, 0), IsNil)
+	}
+	// Regions 1-3 and 5-7 are small, region 4 is too large to be merged.
+	for i := uint64(1); i <= 7; i++ {
+		c.Assert(tc.addLeaderRegion(i, 1, 2, 3), IsNil)
+	}
+	c.Assert(tc.putRegion(tc.GetRegion(4).Clone(core.SetApproximateSize(100))), IsNil)
+
+	stats := tc.GetRegionFragmentation(0)
+	c.Assert(stats, HasLen, 1)
+	c.Assert(stats[0].RegionCount, Equals, 7)
+	c.Assert(stats[0].SmallRegionCount, Equals, 6)
+	c.Assert(stats[0].StoreCount, Equals, 3)
+	// Regions of 10MB are merged in pairs under the threshold of 20MB.
+	c.Assert(stats[0].MergeableCount, Equals, 2)
+	c.Assert(stats[0].Score, Equals, 2.0/7)
+
+	plan := tc.GetDefragPlan(nil, nil, 0)
+	c.Assert(plan.Steps, DeepEquals, []*DefragStep{
+		{Round: 1, SourceID: 1, TargetID: 2},
+		{Round: 1, SourceID: 5, TargetID: 6},
+	})
+
+	// Merges in a group are sequenced in rounds.
+	cfg.MaxMergeRegionSize = 40
+	plan = tc.GetDefragPlan(nil, nil, 0)
+	c.Assert(plan.Steps, DeepEquals, []*DefragStep{
+		{Round: 1, SourceID: 1, TargetID: 2},
+		{Round: 1, SourceID: 5, TargetID: 6},
+		{Round: 2, SourceID: 2, TargetID: 3},
+		{Round: 2, SourceID: 6, TargetID: 7},
+	})
+	c.Assert(tc.GetDefragPlan(nil, nil, 3).Steps, HasLen, 3)
+	c.Assert(tc.GetDefragPlan(nil, newTestRegionMeta(4).GetStartKey(), 0).Steps, HasLen, 2)
+
+	// Each round runs at most merge-schedule-limit merges.
+	cfg.MergeScheduleLimit = 1
+	plan = tc.GetDefragPlan(nil, nil, 0)
+	c.Assert(plan.RoundLimit, Equals, 1)
+	c.Assert(plan.Steps, DeepEquals, []*DefragStep{
+		{Round: 1, SourceID: 1, TargetID: 2},
+		{Round: 2, SourceID: 2, TargetID: 3},
+		{Round: 3, SourceID: 5, TargetID: 6},
+		{Round: 4, SourceID: 6, TargetID: 7},
+	})
+	cfg.MergeScheduleLimit = 0
+	c.Assert(tc.GetDefragPlan(nil, nil, 0).Steps, HasLen, 0)
+}
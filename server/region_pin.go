@@ -0,0 +1,182 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// regionPinner keeps the pins apart from the lock of the cluster, since they
+// are checked with every operator added.
+type regionPinner struct {
+	sync.RWMutex
+	pins map[uint64]*core.RegionPin
+}
+
+func (c *RaftCluster) loadRegionPins() error {
+	pins := make(map[uint64]*core.RegionPin)
+	if err := c.storage.LoadRegionPins(func(pin *core.RegionPin) {
+		pins[pin.RegionID] = pin
+	}); err != nil {
+		return err
+	}
+	c.pinner.Lock()
+	defer c.pinner.Unlock()
+	c.pinner.pins = pins
+	return nil
+}
+
+// PinRegion pins the leader of the region to the leader store if it is not 0,
+// and the peers to the stores if they are not empty, until ttl passes. The
+// region should be in the stores already. Pinning again replaces the pin.
+func (c *RaftCluster) PinRegion(regionID, leaderStoreID uint64, storeIDs []uint64, reason string, ttl time.Duration) (*core.RegionPin, error) {
+	if ttl <= 0 {
+		return nil, errcode.NewInvalidInputErr(errors.New("the ttl of the pin is required"))
+	}
+	if leaderStoreID == 0 && len(storeIDs) == 0 {
+		return nil, errcode.NewInvalidInputErr(errors.New("either the leader or the peers should be pinned"))
+	}
+	region := c.GetRegion(regionID)
+	if region == nil {
+		return nil, errcode.NewNotFoundErr(errors.Errorf("region %d not found", regionID))
+	}
+	pin := &core.RegionPin{
+		RegionID:      regionID,
+		LeaderStoreID: leaderStoreID,
+		Reason:        reason,
+	}
+	if len(storeIDs) > 0 {
+		pin.StoreIDs = append([]uint64(nil), storeIDs...)
+		sort.Slice(pin.StoreIDs, func(i, j int) bool { return pin.StoreIDs[i] < pin.StoreIDs[j] })
+		for i := 1; i < len(pin.StoreIDs); i++ {
+			if pin.StoreIDs[i] == pin.StoreIDs[i-1] {
+				return nil, errcode.NewInvalidInputErr(errors.Errorf("store %d is duplicated", pin.StoreIDs[i]))
+			}
+		}
+	}
+	for _, storeID := range append([]uint64{leaderStoreID}, pin.StoreIDs...) {
+		if storeID == 0 {
+			continue
+		}
+		store := c.GetStore(storeID)
+		if store == nil {
+			return nil, core.NewStoreNotFoundErr(storeID)
+		}
+		if store.IsTombstone() {
+			return nil, core.StoreTombstonedErr{StoreID: storeID}
+		}
+	}
+	// The region is pinned where it is, so it should be moved to the stores
+	// by the operators added by users before being pinned.
+	if leaderStoreID != 0 && region.GetLeader().GetStoreId() != leaderStoreID {
+		return nil, errcode.NewInvalidInputErr(errors.Errorf("the leader of region %d is not in store %d", regionID, leaderStoreID))
+	}
+	if len(pin.StoreIDs) > 0 {
+		if len(region.GetPeers()) != len(pin.StoreIDs) {
+			return nil, errcode.NewInvalidInputErr(errors.Errorf("region %d has %d peers rather than %d", regionID, len(region.GetPeers()), len(pin.StoreIDs)))
+		}
+		for _, storeID := range pin.StoreIDs {
+			if region.GetStorePeer(storeID) == nil {
+				return nil, errcode.NewInvalidInputErr(errors.Errorf("region %d has no peer in store %d", regionID, storeID))
+			}
+		}
+	}
+
+	c.pinner.Lock()
+	defer c.pinner.Unlock()
+	now := time.Now()
+	pin.PinTime, pin.ExpireTime = now, now.Add(ttl)
+	if err := c.storage.SaveRegionPin(regionID, pin); err != nil {
+		return nil, err
+	}
+	c.pinner.pins[regionID] = pin
+	log.Info("region is pinned",
+		zap.Uint64("region-id", regionID),
+		zap.Uint64("leader-store-id", leaderStoreID),
+		zap.Uint64s("store-ids", pin.StoreIDs),
+		zap.String("reason", reason),
+		zap.Duration("ttl", ttl))
+	return pin, nil
+}
+
+// UnpinRegion removes the pin of the region added by PinRegion.
+func (c *RaftCluster) UnpinRegion(regionID uint64) error {
+	c.pinner.Lock()
+	defer c.pinner.Unlock()
+	if _, ok := c.pinner.pins[regionID]; !ok {
+		return errcode.NewNotFoundErr(errors.Errorf("region %d is not pinned", regionID))
+	}
+	return c.unpinRegionLocked(regionID)
+}
+
+func (c *RaftCluster) unpinRegionLocked(regionID uint64) error {
+	if err := c.storage.DeleteRegionPin(regionID); err != nil {
+		return err
+	}
+	delete(c.pinner.pins, regionID)
+	log.Info("region is unpinned", zap.Uint64("region-id", regionID))
+	return nil
+}
+
+// GetRegionPin returns the pin of the region, or nil if the region is not
+// pinned. The expired pin takes no effect even before it is removed by
+// checkRegionPins.
+func (c *RaftCluster) GetRegionPin(regionID uint64) *core.RegionPin {
+	c.pinner.RLock()
+	defer c.pinner.RUnlock()
+	pin, ok := c.pinner.pins[regionID]
+	if !ok || pin.IsExpired(time.Now()) {
+		return nil
+	}
+	return pin
+}
+
+// GetRegionPins returns the pins in effect in the order of region IDs.
+func (c *RaftCluster) GetRegionPins() []*core.RegionPin {
+	c.pinner.RLock()
+	defer c.pinner.RUnlock()
+	now := time.Now()
+	pins := make([]*core.RegionPin, 0, len(c.pinner.pins))
+	for _, pin := range c.pinner.pins {
+		if !pin.IsExpired(now) {
+			pins = append(pins, pin)
+		}
+	}
+	sort.Slice(pins, func(i, j int) bool { return pins[i].RegionID < pins[j].RegionID })
+	return pins
+}
+
+// checkRegionPins removes the expired pins, and the pins of the regions which
+// no longer exist.
+func (c *RaftCluster) checkRegionPins() {
+	c.pinner.Lock()
+	defer c.pinner.Unlock()
+	now := time.Now()
+	for regionID, pin := range c.pinner.pins {
+		if !pin.IsExpired(now) && c.GetRegion(regionID) != nil {
+			continue
+		}
+		if err := c.unpinRegionLocked(regionID); err != nil {
+			log.Error("failed to remove expired region pin", zap.Uint64("region-id", regionID), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/server/core"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// regionLeaderTierKey and regionStorageLearnerKey are the gRPC header keys
+	// to carry the hints for routing the reads of a region, since
+	// GetRegionResponse has no field for them. The former is the type of the
+	// store of the leader. The latter has a value of
+	// "<store-id>:<peer-id>:<lag>" for each learner on the storage tier, where
+	// lag is the applied index lag of the learner to the leader as the hint of
+	// its staleness, and is empty if the lag is unknown.
+	regionLeaderTierKey     = "pd-region-leader-tier"
+	regionStorageLearnerKey = "pd-region-storage-learner"
+)
+
+// regionRoutingHeaderPairs returns the header pairs of the hints for routing
+// the reads of the region. The learners which are pending or on the stores
+// unable to serve are left out.
+func (c *RaftCluster) regionRoutingHeaderPairs(region *core.RegionInfo) []string {
+	var pairs []string
+	if store := c.GetStore(region.GetLeader().GetStoreId()); store != nil {
+		pairs = append(pairs, regionLeaderTierKey, string(store.GetStoreType()))
+	}
+	for _, learner := range region.GetLearners() {
+		store := c.GetStore(learner.GetStoreId())
+		if store == nil || !store.IsStorageType() || store.IsTombstone() || store.IsDisconnected() {
+			continue
+		}
+		if region.GetPendingLearner(learner.GetId()) != nil {
+			continue
+		}
+		var lag string
+		if l, ok := region.GetLearnerLag(learner.GetId()); ok {
+			lag = strconv.FormatUint(l, 10)
+		}
+		pairs = append(pairs, regionStorageLearnerKey, fmt.Sprintf("%d:%d:%s", store.GetID(), learner.GetId(), lag))
+	}
+	return pairs
+}
+
+// setRegionRoutingHeader sets the hints for routing the reads of the region
+// in the header of the response, so that clients can read the cold data from
+// the learners on the storage tier without asking for the stores.
+func setRegionRoutingHeader(ctx context.Context, cluster *RaftCluster, meta *metapb.Region) {
+	if meta == nil {
+		return
+	}
+	region := cluster.GetRegion(meta.GetId())
+	if region == nil {
+		return
+	}
+	pairs := cluster.regionRoutingHeaderPairs(region)
+	if len(pairs) == 0 {
+		return
+	}
+	if err := grpc.SetHeader(ctx, metadata.Pairs(pairs...)); err != nil {
+		log.Debug("failed to set region routing header", zap.Uint64("region-id", meta.GetId()), zap.Error(err))
+	}
+}
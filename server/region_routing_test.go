@@ -0,0 +1,60 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/server/core"
+)
+
+var _ = Suite(&testRegionRoutingSuite{})
+
+type testRegionRoutingSuite struct{}
+
+func (s *testRegionRoutingSuite) TestRegionRoutingHeaderPairs(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	tc := newTestCluster(opt)
+	for i := uint64(1); i <= 3; i++ {
+		c.Assert(tc.addRegionStore(i, 1), IsNil)
+	}
+	storage := map[string]string{core.StoreTypeLabelKey: string(core.StoreTypeStorage)}
+	for i := uint64(4); i <= 6; i++ {
+		c.Assert(tc.addLabelsStore(i, 1, storage), IsNil)
+	}
+	c.Assert(tc.addLeaderRegion(1, 1, 2, 3), IsNil)
+
+	region := tc.GetRegion(1)
+	c.Assert(tc.regionRoutingHeaderPairs(region), DeepEquals, []string{regionLeaderTierKey, "performance"})
+
+	// The pending learners and the learners on the performance tier are left
+	// out.
+	region = region.Clone(
+		core.WithAddPeer(&metapb.Peer{Id: 10, StoreId: 4, IsLearner: true}),
+		core.WithAddPeer(&metapb.Peer{Id: 11, StoreId: 5, IsLearner: true}),
+		core.WithAddPeer(&metapb.Peer{Id: 12, StoreId: 6, IsLearner: true}),
+		core.WithAddPeer(&metapb.Peer{Id: 13, StoreId: 3, IsLearner: true}),
+		core.WithPendingPeers([]*metapb.Peer{{Id: 12, StoreId: 6, IsLearner: true}}),
+		core.WithLearnerLags(map[uint64]uint64{10: 5}),
+	)
+	c.Assert(tc.regionRoutingHeaderPairs(region), DeepEquals, []string{
+		regionLeaderTierKey, "performance",
+		regionStorageLearnerKey, "4:10:5",
+		regionStorageLearnerKey, "5:11:",
+	})
+
+	region = region.Clone(core.WithLeader(&metapb.Peer{Id: 10, StoreId: 4}))
+	c.Assert(tc.regionRoutingHeaderPairs(region)[:2], DeepEquals, []string{regionLeaderTierKey, "storage"})
+}
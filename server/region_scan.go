@@ -0,0 +1,70 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/pingcap/pd/server/core"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/metadata"
+)
+
+// The gRPC metadata keys for clients to filter the regions returned by
+// ScanRegions, since the request has no field for them. They are the same as
+// the query parameters of the regions API.
+const (
+	// scanLeaderStoreKey selects the regions whose leaders are on the store.
+	scanLeaderStoreKey = "pd-scan-leader-store"
+	// scanPeerStoreKey selects the regions which have peers on the store.
+	scanPeerStoreKey = "pd-scan-peer-store"
+	// scanDownPeerKey selects the regions which have down peers if it is
+	// "true".
+	scanDownPeerKey = "pd-scan-down-peer"
+)
+
+// scanRegionOptionsFromContext returns the predicates of the regions in the
+// metadata of the ScanRegions request.
+func scanRegionOptionsFromContext(ctx context.Context) ([]core.RegionOption, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+	var opts []core.RegionOption
+	if values := md.Get(scanLeaderStoreKey); len(values) > 0 {
+		storeID, err := strconv.ParseUint(values[0], 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid leader store %s", values[0])
+		}
+		opts = append(opts, core.LeaderInStore(storeID))
+	}
+	if values := md.Get(scanPeerStoreKey); len(values) > 0 {
+		storeID, err := strconv.ParseUint(values[0], 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid peer store %s", values[0])
+		}
+		opts = append(opts, core.PeerInStore(storeID))
+	}
+	if values := md.Get(scanDownPeerKey); len(values) > 0 {
+		downPeer, err := strconv.ParseBool(values[0])
+		if err != nil {
+			return nil, errors.Errorf("invalid down peer %s", values[0])
+		}
+		if downPeer {
+			opts = append(opts, core.DownPeerRegion())
+		}
+	}
+	return opts, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"strconv"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/pd/pkg/testutil"
+	"github.com/pingcap/pd/server/core"
+	"google.golang.org/grpc/metadata"
+)
+
+var _ = Suite(&testRegionScanSuite{})
+
+type testRegionScanSuite struct {
+	baseCluster
+}
+
+// TestScanRegions filters the regions scanned through the gRPC service by the
+// metadata of the requests.
+func (s *testRegionScanSuite) TestScanRegions(c *C) {
+	var cleanup func()
+	var err error
+	_, s.svr, cleanup, err = NewTestServer(c)
+	c.Assert(err, IsNil)
+	defer cleanup()
+	mustWaitLeader(c, []*Server{s.svr})
+	s.grpcPDClient = testutil.MustNewGrpcClient(c, s.svr.GetAddr())
+	clusterID := s.svr.clusterID
+	bootstrapReq := s.newBootstrapRequest(c, clusterID, "127.0.0.1:0")
+	_, err = s.svr.bootstrapCluster(bootstrapReq)
+	c.Assert(err, IsNil)
+
+	store1 := bootstrapReq.GetStore()
+	store2 := s.newStore(c, 0, "127.0.0.1:1", "2.1.0")
+	_, err = putStore(c, s.grpcPDClient, clusterID, store2)
+	c.Assert(err, IsNil)
+
+	epoch := &metapb.RegionEpoch{ConfVer: initEpochConfVer, Version: initEpochVersion + 1}
+	newRegion := func(startKey, endKey string, leaderStoreID, followerStoreID uint64) *metapb.Region {
+		peers := []*metapb.Peer{s.newPeer(c, leaderStoreID, 0), s.newPeer(c, followerStoreID, 0)}
+		return s.newRegion(c, 0, []byte(startKey), []byte(endKey), peers, epoch)
+	}
+	r1 := newRegion("", "b", store1.GetId(), store2.GetId())
+	r2 := newRegion("b", "c", store2.GetId(), store1.GetId())
+	r3 := newRegion("c", "", store2.GetId(), store1.GetId())
+	cluster := s.svr.GetRaftCluster()
+	c.Assert(cluster.HandleRegionHeartbeat(core.NewRegionInfo(r1, r1.GetPeers()[0])), IsNil)
+	c.Assert(cluster.HandleRegionHeartbeat(core.NewRegionInfo(r2, r2.GetPeers()[0])), IsNil)
+	downPeers := []*pdpb.PeerStats{{Peer: r3.GetPeers()[1], DownSeconds: 600}}
+	c.Assert(cluster.HandleRegionHeartbeat(core.NewRegionInfo(r3, r3.GetPeers()[0], core.WithDownPeers(downPeers))), IsNil)
+
+	scanRegions := func(ctx context.Context, limit int32) []uint64 {
+		resp, err := s.grpcPDClient.ScanRegions(ctx, &pdpb.ScanRegionsRequest{
+			Header: testutil.NewRequestHeader(clusterID),
+			Limit:  limit,
+		})
+		c.Assert(err, IsNil)
+		var regionIDs []uint64
+		for _, region := range resp.GetRegions() {
+			regionIDs = append(regionIDs, region.GetId())
+		}
+		return regionIDs
+	}
+	c.Assert(scanRegions(context.Background(), 0), DeepEquals, []uint64{r1.GetId(), r2.GetId(), r3.GetId()})
+	ctx := metadata.AppendToOutgoingContext(context.Background(), scanLeaderStoreKey, "abc")
+	_, err = s.grpcPDClient.ScanRegions(ctx, &pdpb.ScanRegionsRequest{Header: testutil.NewRequestHeader(clusterID)})
+	c.Assert(err, NotNil)
+
+	storeID1, storeID2 := strconv.FormatUint(store1.GetId(), 10), strconv.FormatUint(store2.GetId(), 10)
+	for _, t := range []struct {
+		pairs     []string
+		limit     int32
+		regionIDs []uint64
+	}{
+		{[]string{scanLeaderStoreKey, storeID1}, 0, []uint64{r1.GetId()}},
+		{[]string{scanLeaderStoreKey, storeID2}, 1, []uint64{r2.GetId()}},
+		{[]string{scanPeerStoreKey, storeID1}, 0, []uint64{r1.GetId(), r2.GetId(), r3.GetId()}},
+		{[]string{scanDownPeerKey, "true"}, 0, []uint64{r3.GetId()}},
+		{[]string{scanDownPeerKey, "false"}, 2, []uint64{r1.GetId(), r2.GetId()}},
+		{[]string{scanLeaderStoreKey, storeID1, scanDownPeerKey, "true"}, 0, nil},
+	} {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), t.pairs...)
+		c.Assert(scanRegions(ctx, t.limit), DeepEquals, t.regionIDs, Commentf("pairs %v", t.pairs))
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"time"
+
+	"github.com/pingcap/pd/server/core"
+)
+
+// RegionSequence identifies the state of the region metas in the cache. The
+// sequence counts the region meta changes applied to the cache, which are the
+// changes synchronized to the followers by the region syncer, and the
+// generation tells the cache since the cluster started apart from the former
+// ones, whose sequences are unrelated.
+type RegionSequence struct {
+	Generation int64  `json:"generation"`
+	Sequence   uint64 `json:"sequence"`
+}
+
+// RegionSnapshot is the regions in a key range at a point in time, such that
+// no split or merge happens among them. Tools like backup can plan the range
+// assignments with the snapshot, and check whether it is still valid by
+// comparing the sequence later.
+type RegionSnapshot struct {
+	RegionSequence
+	Time    time.Time          `json:"time"`
+	Regions []*core.RegionInfo `json:"-"`
+}
+
+// GetRegionSequence returns the current sequence of the region metas.
+func (c *RaftCluster) GetRegionSequence() RegionSequence {
+	c.RLock()
+	defer c.RUnlock()
+	return RegionSequence{Generation: c.regionGeneration, Sequence: c.regionSequence}
+}
+
+// GetRegionSnapshot returns the regions in [startKey, endKey), with the
+// sequence of the region metas when they are taken. The empty endKey means
+// the end of the key space.
+func (c *RaftCluster) GetRegionSnapshot(startKey, endKey []byte) *RegionSnapshot {
+	c.RLock()
+	defer c.RUnlock()
+	return &RegionSnapshot{
+		RegionSequence: RegionSequence{Generation: c.regionGeneration, Sequence: c.regionSequence},
+		Time:           time.Now(),
+		Regions:        c.core.ScanRange(startKey, endKey, -1),
+	}
+}
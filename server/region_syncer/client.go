@@ -123,10 +123,17 @@ func (s *RegionSyncer) StartSyncWithLeader(addr string) {
 					// reset index
 					s.history.ResetWithIndex(resp.GetStartIndex())
 				}
+				s.RLock()
+				observer := s.observer
+				s.RUnlock()
 				for _, r := range resp.GetRegions() {
 					err = s.server.GetStorage().SaveRegion(r)
 					if err == nil {
-						s.history.Record(core.NewRegionInfo(r, nil))
+						region := core.NewRegionInfo(r, nil)
+						s.history.Record(region)
+						if observer != nil {
+							observer(region)
+						}
 					}
 				}
 			}
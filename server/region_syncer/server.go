@@ -75,6 +75,8 @@ type RegionSyncer struct {
 	history        *historyBuffer
 	limit          *ratelimit.Bucket
 	securityConfig *config.SecurityConfig
+	// observer is called with the regions synchronized from the leader.
+	observer func(*core.RegionInfo)
 }
 
 // NewRegionSyncer returns a region syncer.
@@ -93,6 +95,14 @@ func NewRegionSyncer(s Server) *RegionSyncer {
 	}
 }
 
+// SetRegionObserver sets the function called with each region synchronized
+// from the leader.
+func (s *RegionSyncer) SetRegionObserver(observer func(*core.RegionInfo)) {
+	s.Lock()
+	defer s.Unlock()
+	s.observer = observer
+}
+
 // RunServer runs the server of the region syncer.
 // regionNitifier is used to get the changed regions.
 func (s *RegionSyncer) RunServer(regionNotifier <-chan *core.RegionInfo, quit chan struct{}) {
@@ -0,0 +1,184 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pingcap/pd/pkg/logutil"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/schedule"
+	"github.com/pingcap/pd/server/schedule/operator"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// requestPriorityKey is the gRPC metadata key for applications to set the
+	// priority of the split and scatter requests, since the requests have no
+	// field for it. The value is one of "low", "normal" and "high".
+	requestPriorityKey = "pd-request-priority"
+	// splitPriorityTTL is how long the priority of a split is kept for
+	// scattering the regions created by it.
+	splitPriorityTTL = 10 * time.Minute
+	// maxPendingScatters is the max number of the scatter operators waiting
+	// for the operator limits.
+	maxPendingScatters = 10000
+	// scatterQueueInterval is the interval to add the pending scatter
+	// operators.
+	scatterQueueInterval = 100 * time.Millisecond
+)
+
+// errTooManyPendingScatters is returned when a scatter operator cannot be
+// queued.
+var errTooManyPendingScatters = errors.New("too many pending scatter operators")
+
+// requestPriority returns the priority in the metadata of the request, or nil
+// if it is not set.
+func requestPriority(ctx context.Context) (*core.PriorityLevel, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+	values := md.Get(requestPriorityKey)
+	if len(values) == 0 {
+		return nil, nil
+	}
+	level, err := core.ParsePriorityLevel(values[0])
+	if err != nil {
+		return nil, err
+	}
+	return &level, nil
+}
+
+// scatterQueue holds the scatter operators which exceed the operator limits.
+// They are added in the order of the priorities once the limits allow, so
+// that the scatters after the pre-splits of users are not delayed by the ones
+// after background splits.
+type scatterQueue struct {
+	sync.Mutex
+	// ops are the FIFO queues of the priorities.
+	ops  [core.UrgentPriority + 1][]*operator.Operator
+	size int
+}
+
+func (q *scatterQueue) push(op *operator.Operator) error {
+	q.Lock()
+	defer q.Unlock()
+	if q.size >= maxPendingScatters {
+		return errTooManyPendingScatters
+	}
+	level := op.GetPriorityLevel()
+	q.ops[level] = append(q.ops[level], op)
+	q.size++
+	return nil
+}
+
+// hasPending checks if there are pending operators with the priority or
+// higher ones, which a new operator should not jump ahead of.
+func (q *scatterQueue) hasPending(level core.PriorityLevel) bool {
+	q.Lock()
+	defer q.Unlock()
+	for l := level; l <= core.UrgentPriority; l++ {
+		if len(q.ops[l]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *scatterQueue) len() int {
+	q.Lock()
+	defer q.Unlock()
+	return q.size
+}
+
+// drain adds the pending operators from the highest priority. The operators
+// still exceeding the limits are kept, and the others are removed no matter
+// whether they are added, since the regions may have changed.
+func (q *scatterQueue) drain(oc *schedule.OperatorController) {
+	q.Lock()
+	defer q.Unlock()
+	for level := core.UrgentPriority; level >= core.LowPriority; level-- {
+		var kept []*operator.Operator
+		for _, op := range q.ops[level] {
+			if oc.ExceedLimit(op) {
+				kept = append(kept, op)
+				continue
+			}
+			oc.AddOperator(op)
+			q.size--
+		}
+		q.ops[level] = kept
+	}
+}
+
+// recordSplitPriority records the priority of a split for the regions, which
+// is used when they are scattered without priorities.
+func (c *coordinator) recordSplitPriority(level *core.PriorityLevel, regionIDs ...uint64) {
+	if level == nil {
+		return
+	}
+	for _, id := range regionIDs {
+		c.splitPriorities.PutWithTTL(id, *level, splitPriorityTTL)
+	}
+}
+
+// scatterRegion scatters the region with the priority, or the priority of the
+// split creating it if the priority is nil. The scatter operator waits in the
+// queue if it exceeds the operator limits. It returns false if the operator is
+// neither added nor queued.
+func (c *coordinator) scatterRegion(region *core.RegionInfo, level *core.PriorityLevel) (bool, error) {
+	op, err := c.regionScatterer.Scatter(region)
+	if err != nil {
+		return false, err
+	}
+	if op == nil {
+		return true, nil
+	}
+	if level == nil {
+		if v, ok := c.splitPriorities.Get(region.GetID()); ok {
+			l := v.(core.PriorityLevel)
+			level = &l
+		}
+	}
+	if level != nil {
+		op.SetPriorityLevel(*level)
+	}
+	if c.scatterQueue.hasPending(op.GetPriorityLevel()) || c.opController.ExceedLimit(op) {
+		if err := c.scatterQueue.push(op); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return c.opController.AddOperator(op), nil
+}
+
+func (c *coordinator) driveScatterQueue() {
+	defer logutil.LogPanic()
+
+	defer c.wg.Done()
+	ticker := time.NewTicker(scatterQueueInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.scatterQueue.drain(c.opController)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/pd/pkg/testutil"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/namespace"
+	"github.com/pingcap/pd/server/schedule/operator"
+	"google.golang.org/grpc/metadata"
+)
+
+var _ = Suite(&testScatterQueueSuite{})
+
+type testScatterQueueSuite struct{}
+
+func (s *testScatterQueueSuite) TestRequestPriority(c *C) {
+	level, err := requestPriority(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(level, IsNil)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestPriorityKey, "High"))
+	level, err = requestPriority(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(*level, Equals, core.HighPriority)
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestPriorityKey, "urgent"))
+	_, err = requestPriority(ctx)
+	c.Assert(err, NotNil)
+}
+
+func (s *testScatterQueueSuite) TestScatterQueue(c *C) {
+	cfg, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	cfg.MaxLearnerCount = 1
+	opt.Store(cfg)
+	tc := newTestCluster(opt)
+	hbStreams, cleanup := getHeartBeatStreams(c, tc)
+	defer cleanup()
+	defer hbStreams.Close()
+
+	co := newCoordinator(tc.RaftCluster, hbStreams, namespace.DefaultClassifier)
+	oc := co.opController
+	for id := uint64(1); id <= 2; id++ {
+		c.Assert(tc.addRegionStore(id, 0), IsNil)
+	}
+	newLearnerOp := func(regionID uint64, level core.PriorityLevel) *operator.Operator {
+		op := newTestOperator(regionID, tc.GetRegion(regionID).GetRegionEpoch(), operator.OpRegion,
+			operator.AddLearner{ToStore: 2, PeerID: 100 + regionID}, operator.PromoteLearner{ToStore: 2, PeerID: 100 + regionID})
+		op.SetPriorityLevel(level)
+		return op
+	}
+	for id := uint64(1); id <= 3; id++ {
+		c.Assert(tc.addLeaderRegion(id, 1), IsNil)
+	}
+
+	// The learner limit is reached.
+	running := newLearnerOp(1, core.HighPriority)
+	c.Assert(oc.AddOperator(running), IsTrue)
+	low, high := newLearnerOp(2, core.LowPriority), newLearnerOp(3, core.HighPriority)
+	c.Assert(oc.ExceedLimit(low), IsTrue)
+	c.Assert(co.scatterQueue.push(low), IsNil)
+	c.Assert(co.scatterQueue.push(high), IsNil)
+	c.Assert(co.scatterQueue.hasPending(core.NormalPriority), IsTrue)
+	c.Assert(co.scatterQueue.hasPending(core.UrgentPriority), IsFalse)
+	co.scatterQueue.drain(oc)
+	c.Assert(co.scatterQueue.len(), Equals, 2)
+
+	// The operator of the higher priority is added first.
+	c.Assert(oc.RemoveOperator(running), IsTrue)
+	co.scatterQueue.drain(oc)
+	c.Assert(co.scatterQueue.len(), Equals, 1)
+	c.Assert(oc.GetOperator(3), Equals, high)
+	c.Assert(oc.GetOperator(2), IsNil)
+	c.Assert(co.scatterQueue.hasPending(core.NormalPriority), IsFalse)
+
+	c.Assert(oc.RemoveOperator(high), IsTrue)
+	co.scatterQueue.drain(oc)
+	c.Assert(co.scatterQueue.len(), Equals, 0)
+	c.Assert(oc.GetOperator(2), Equals, low)
+
+	// The queue is bounded.
+	for i := 0; i < maxPendingScatters; i++ {
+		c.Assert(co.scatterQueue.push(low), IsNil)
+	}
+	c.Assert(co.scatterQueue.push(high), Equals, errTooManyPendingScatters)
+}
+
+func (s *testScatterQueueSuite) TestSplitPriority(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	tc := newTestCluster(opt)
+	hbStreams, cleanup := getHeartBeatStreams(c, tc)
+	defer cleanup()
+	defer hbStreams.Close()
+
+	co := newCoordinator(tc.RaftCluster, hbStreams, namespace.DefaultClassifier)
+	co.recordSplitPriority(nil, 1)
+	_, ok := co.splitPriorities.Get(1)
+	c.Assert(ok, IsFalse)
+
+	level := core.LowPriority
+	co.recordSplitPriority(&level, 1, 2)
+	for _, id := range []uint64{1, 2} {
+		v, ok := co.splitPriorities.Get(id)
+		c.Assert(ok, IsTrue)
+		c.Assert(v, Equals, core.LowPriority)
+	}
+}
+
+var _ = Suite(&testScatterPriorityClusterSuite{})
+
+type testScatterPriorityClusterSuite struct {
+	baseCluster
+}
+
+// TestScatterPriority splits and scatters the regions through the gRPC
+// service, where the priorities are set by the metadata of the requests.
+func (s *testScatterPriorityClusterSuite) TestScatterPriority(c *C) {
+	var cleanup func()
+	var err error
+	_, s.svr, cleanup, err = NewTestServer(c)
+	c.Assert(err, IsNil)
+	defer cleanup()
+	mustWaitLeader(c, []*Server{s.svr})
+	s.grpcPDClient = testutil.MustNewGrpcClient(c, s.svr.GetAddr())
+	clusterID := s.svr.clusterID
+	bootstrapReq := s.newBootstrapRequest(c, clusterID, "127.0.0.1:0")
+	_, err = s.svr.bootstrapCluster(bootstrapReq)
+	c.Assert(err, IsNil)
+
+	// Only the scatters add operators, and one learner is allowed at a time.
+	scheduleCfg := s.svr.GetScheduleConfig()
+	scheduleCfg.LeaderScheduleLimit, scheduleCfg.RegionScheduleLimit = 0, 0
+	scheduleCfg.ReplicaScheduleLimit, scheduleCfg.MergeScheduleLimit = 0, 0
+	scheduleCfg.HotRegionScheduleLimit = 0
+	scheduleCfg.MaxLearnerCount = 1
+	c.Assert(s.svr.SetScheduleConfig(*scheduleCfg), IsNil)
+	replicationCfg := s.svr.GetReplicationConfig()
+	replicationCfg.MaxReplicas = 1
+	c.Assert(s.svr.SetReplicationConfig(*replicationCfg), IsNil)
+
+	stores := []*metapb.Store{bootstrapReq.GetStore()}
+	for i := 0; i < 3; i++ {
+		store := s.newStore(c, 0, fmt.Sprintf("127.0.0.1:%d", i+1), "2.1.0")
+		_, err = putStore(c, s.grpcPDClient, clusterID, store)
+		c.Assert(err, IsNil)
+		stores = append(stores, store)
+	}
+	for _, store := range stores {
+		_, err = s.grpcPDClient.StoreHeartbeat(context.Background(), &pdpb.StoreHeartbeatRequest{
+			Header: testutil.NewRequestHeader(clusterID),
+			Stats:  &pdpb.StoreStats{StoreId: store.GetId(), Capacity: 1 << 30, Available: 1 << 30},
+		})
+		c.Assert(err, IsNil)
+	}
+
+	cluster := s.svr.GetRaftCluster()
+	co := cluster.GetCoordinator()
+	region := bootstrapReq.GetRegion()
+	leader := region.GetPeers()[0]
+	c.Assert(cluster.HandleRegionHeartbeat(core.NewRegionInfo(region, leader)), IsNil)
+	// The first scatter keeps the region on its store and marks the store
+	// selected, so the next scatters move the regions to the other stores.
+	_, err = s.grpcPDClient.ScatterRegion(context.Background(), &pdpb.ScatterRegionRequest{
+		Header:   testutil.NewRequestHeader(clusterID),
+		RegionId: region.GetId(),
+	})
+	c.Assert(err, IsNil)
+	c.Assert(co.opController.GetOperator(region.GetId()), IsNil)
+
+	splitRegion := func(ctx context.Context, startKey, endKey []byte) *metapb.Region {
+		resp, err := s.grpcPDClient.AskSplit(ctx, &pdpb.AskSplitRequest{
+			Header: testutil.NewRequestHeader(clusterID),
+			Region: region,
+		})
+		c.Assert(err, IsNil)
+		peer := &metapb.Peer{Id: resp.GetNewPeerIds()[0], StoreId: leader.GetStoreId()}
+		return s.newRegion(c, resp.GetNewRegionId(), startKey, endKey, []*metapb.Peer{peer}, nil)
+	}
+	scatterRegion := func(ctx context.Context, region *metapb.Region) {
+		_, err := s.grpcPDClient.ScatterRegion(ctx, &pdpb.ScatterRegionRequest{
+			Header:   testutil.NewRequestHeader(clusterID),
+			RegionId: region.GetId(),
+		})
+		c.Assert(err, IsNil)
+	}
+	low := metadata.AppendToOutgoingContext(context.Background(), requestPriorityKey, "low")
+	high := metadata.AppendToOutgoingContext(context.Background(), requestPriorityKey, "high")
+	background := splitRegion(low, []byte("b"), []byte("c"))
+	user := splitRegion(context.Background(), []byte("c"), []byte(""))
+	region.EndKey = []byte("b")
+	region.RegionEpoch.Version += 2
+	for _, r := range []*metapb.Region{region, background, user} {
+		c.Assert(cluster.HandleRegionHeartbeat(core.NewRegionInfo(r, r.GetPeers()[0])), IsNil)
+	}
+
+	// The learner limit is reached.
+	running := newTestOperator(region.GetId(), region.GetRegionEpoch(), operator.OpRegion,
+		operator.AddLearner{ToStore: stores[1].GetId(), PeerID: s.allocID(c)})
+	c.Assert(co.opController.AddOperator(running), IsTrue)
+
+	// The scatter after the background split waits with the priority of the
+	// split, and the one of the user waits with the priority of its request.
+	scatterRegion(context.Background(), background)
+	c.Assert(co.scatterQueue.len(), Equals, 1)
+	c.Assert(co.scatterQueue.hasPending(core.NormalPriority), IsFalse)
+	scatterRegion(high, user)
+	c.Assert(co.scatterQueue.len(), Equals, 2)
+	c.Assert(co.scatterQueue.hasPending(core.HighPriority), IsTrue)
+
+	// The scatter of the user goes first once the limit allows.
+	c.Assert(co.opController.RemoveOperator(running), IsTrue)
+	co.scatterQueue.drain(co.opController)
+	c.Assert(co.opController.GetOperator(user.GetId()), NotNil)
+	c.Assert(co.opController.GetOperator(background.GetId()), IsNil)
+	c.Assert(co.scatterQueue.len(), Equals, 1)
+}
@@ -0,0 +1,146 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedule
+
+import (
+	"sync"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/schedule/operator"
+)
+
+// EventType is the type of the events published to the EventBus.
+type EventType int
+
+// Types of the events.
+const (
+	// EventRegionSplit is published when a region is split, with the region
+	// before and after the split.
+	EventRegionSplit EventType = iota
+	// EventRegionMerge is published when a region merges others, with the
+	// region before and after the merge.
+	EventRegionMerge
+	// EventStoreStateChange is published when the state of a store changes.
+	EventStoreStateChange
+	// EventOperatorFinish is published when an operator finishes successfully.
+	EventOperatorFinish
+	// EventOperatorFail is published when an operator times out or becomes
+	// stale before it finishes.
+	EventOperatorFail
+)
+
+var eventTypeToName = map[EventType]string{
+	EventRegionSplit:      "region-split",
+	EventRegionMerge:      "region-merge",
+	EventStoreStateChange: "store-state-change",
+	EventOperatorFinish:   "operator-finish",
+	EventOperatorFail:     "operator-fail",
+}
+
+func (t EventType) String() string {
+	if name, ok := eventTypeToName[t]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Event is something happened in the cluster. Only the fields related to the
+// type are set.
+type Event struct {
+	Type EventType
+	// Region is the region after the split or the merge, or the region of the
+	// finished or failed operator.
+	Region *core.RegionInfo
+	// Origin is the region before the split or the merge.
+	Origin *core.RegionInfo
+	// StoreID is the store whose state changes from OldState to NewState.
+	StoreID  uint64
+	OldState metapb.StoreState
+	NewState metapb.StoreState
+	// Operator is the finished or failed operator.
+	Operator *operator.Operator
+}
+
+// Subscription receives the events of the subscribed types from C. The events
+// are dropped if C is full, so the subscriber should not rely on receiving all
+// events, such as keeping the periodic patrol as a fallback.
+type Subscription struct {
+	C     <-chan *Event
+	ch    chan *Event
+	name  string
+	types map[EventType]struct{}
+}
+
+// EventBus delivers the events published by the cluster and the operator
+// controller to the checkers and schedulers subscribing them, so that they can
+// react to the changes instead of rediscovering them by periodic scans.
+// Publishing never blocks.
+type EventBus struct {
+	sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+// NewEventBus creates an EventBus.
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe subscribes the events of the types, buffering at most size
+// events. The name identifies the subscriber in metrics.
+func (b *EventBus) Subscribe(name string, size int, types ...EventType) *Subscription {
+	ch := make(chan *Event, size)
+	sub := &Subscription{
+		C:     ch,
+		ch:    ch,
+		name:  name,
+		types: make(map[EventType]struct{}, len(types)),
+	}
+	for _, t := range types {
+		sub.types[t] = struct{}{}
+	}
+	b.Lock()
+	defer b.Unlock()
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe stops delivering events to the subscription. C is not closed,
+// since the subscriber usually selects it with a context.
+func (b *EventBus) Unsubscribe(sub *Subscription) {
+	b.Lock()
+	defer b.Unlock()
+	delete(b.subs, sub)
+}
+
+// Publish delivers the event to the subscriptions of its type. It is a no-op
+// if the bus is nil.
+func (b *EventBus) Publish(e *Event) {
+	if b == nil {
+		return
+	}
+	b.RLock()
+	defer b.RUnlock()
+	for sub := range b.subs {
+		if _, ok := sub.types[e.Type]; !ok {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+			eventBusCounter.WithLabelValues(sub.name, e.Type.String(), "deliver").Inc()
+		default:
+			eventBusCounter.WithLabelValues(sub.name, e.Type.String(), "drop").Inc()
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedule
+
+import (
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&testEventBusSuite{})
+
+type testEventBusSuite struct{}
+
+func (s *testEventBusSuite) TestEventBus(c *C) {
+	bus := NewEventBus()
+	regionSub := bus.Subscribe("region", 2, EventRegionSplit, EventRegionMerge)
+	storeSub := bus.Subscribe("store", 1, EventStoreStateChange)
+
+	bus.Publish(&Event{Type: EventRegionSplit})
+	bus.Publish(&Event{Type: EventStoreStateChange, StoreID: 1})
+	bus.Publish(&Event{Type: EventOperatorFinish})
+	bus.Publish(&Event{Type: EventRegionMerge})
+	// The events are dropped if the subscription is full.
+	bus.Publish(&Event{Type: EventRegionSplit})
+	bus.Publish(&Event{Type: EventStoreStateChange, StoreID: 2})
+
+	c.Assert((<-regionSub.C).Type, Equals, EventRegionSplit)
+	c.Assert((<-regionSub.C).Type, Equals, EventRegionMerge)
+	c.Assert(regionSub.C, HasLen, 0)
+	c.Assert((<-storeSub.C).StoreID, Equals, uint64(1))
+	c.Assert(storeSub.C, HasLen, 0)
+
+	bus.Unsubscribe(regionSub)
+	bus.Publish(&Event{Type: EventRegionSplit})
+	c.Assert(regionSub.C, HasLen, 0)
+
+	// Publishing to a nil bus is a no-op.
+	var nilBus *EventBus
+	nilBus.Publish(&Event{Type: EventRegionSplit})
+}
@@ -16,6 +16,8 @@ package filter
 import (
 	"fmt"
 
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
 	"github.com/pingcap/pd/pkg/cache"
 	"github.com/pingcap/pd/server/core"
 	"github.com/pingcap/pd/server/namespace"
@@ -35,6 +37,12 @@ type Filter interface {
 	Target(opt opt.Options, store *core.StoreInfo) bool
 }
 
+// spanCarrier is implemented by the clusters which carry the span of the
+// schedule evaluating the filters.
+type spanCarrier interface {
+	FilterSpan() opentracing.Span
+}
+
 // Source checks if store can pass all Filters as source store.
 func Source(opt opt.Options, store *core.StoreInfo, filters []Filter) bool {
 	storeAddress := store.GetAddress()
@@ -42,6 +50,7 @@ func Source(opt opt.Options, store *core.StoreInfo, filters []Filter) bool {
 	for _, filter := range filters {
 		if filter.Source(opt, store) {
 			filterCounter.WithLabelValues("filter-source", storeAddress, storeID, filter.Scope(), filter.Type()).Inc()
+			traceFiltered(opt, "filter-source", store, filter)
 			return true
 		}
 	}
@@ -55,12 +64,25 @@ func Target(opt opt.Options, store *core.StoreInfo, filters []Filter) bool {
 	for _, filter := range filters {
 		if filter.Target(opt, store) {
 			filterCounter.WithLabelValues("filter-target", storeAddress, storeID, filter.Scope(), filter.Type()).Inc()
+			traceFiltered(opt, "filter-target", store, filter)
 			return true
 		}
 	}
 	return false
 }
 
+// traceFiltered logs the store rejected by the filter to the span carried by
+// the cluster, if any.
+func traceFiltered(opt opt.Options, event string, store *core.StoreInfo, filter Filter) {
+	carrier, ok := opt.(spanCarrier)
+	if !ok {
+		return
+	}
+	if span := carrier.FilterSpan(); span != nil {
+		span.LogFields(log.String("event", event), log.Uint64("store-id", store.GetID()), log.String("scope", filter.Scope()), log.String("filter", filter.Type()))
+	}
+}
+
 type excludedFilter struct {
 	scope   string
 	sources map[uint64]struct{}
@@ -94,6 +116,247 @@ func (f *excludedFilter) Target(opt opt.Options, store *core.StoreInfo) bool {
 	return ok
 }
 
+type evacuateTargetFilter struct {
+	scope   string
+	targets map[uint64]struct{}
+}
+
+// NewEvacuateTargetFilter creates a Filter that filters all stores except the
+// preferred targets to evacuate a store.
+func NewEvacuateTargetFilter(scope string, targets []uint64) Filter {
+	f := &evacuateTargetFilter{
+		scope:   scope,
+		targets: make(map[uint64]struct{}, len(targets)),
+	}
+	for _, id := range targets {
+		f.targets[id] = struct{}{}
+	}
+	return f
+}
+
+func (f *evacuateTargetFilter) Scope() string {
+	return f.scope
+}
+
+func (f *evacuateTargetFilter) Type() string {
+	return "evacuate-target-filter"
+}
+
+func (f *evacuateTargetFilter) Source(opt opt.Options, store *core.StoreInfo) bool {
+	return false
+}
+
+func (f *evacuateTargetFilter) Target(opt opt.Options, store *core.StoreInfo) bool {
+	_, ok := f.targets[store.GetID()]
+	return !ok
+}
+
+type regionPinFilter struct {
+	scope          string
+	pin            *core.RegionPin
+	transferLeader bool
+}
+
+// NewRegionPinFilter creates a Filter that keeps the region in the stores it
+// is pinned to. For transferring the leader, it filters the pinned leader
+// store as the source and other stores as the target. Otherwise it filters the
+// pinned stores as the source and other stores as the target. The pin can be
+// nil if the region is not pinned.
+func NewRegionPinFilter(scope string, pin *core.RegionPin, transferLeader bool) Filter {
+	return &regionPinFilter{scope: scope, pin: pin, transferLeader: transferLeader}
+}
+
+func (f *regionPinFilter) Scope() string {
+	return f.scope
+}
+
+func (f *regionPinFilter) Type() string {
+	return "region-pin-filter"
+}
+
+func (f *regionPinFilter) Source(opt opt.Options, store *core.StoreInfo) bool {
+	if f.pin == nil {
+		return false
+	}
+	if store.GetID() == f.pin.LeaderStoreID {
+		return true
+	}
+	return !f.transferLeader && f.pin.IsPeerPinned(store.GetID())
+}
+
+func (f *regionPinFilter) Target(opt opt.Options, store *core.StoreInfo) bool {
+	if f.pin == nil {
+		return false
+	}
+	if f.transferLeader {
+		return f.pin.LeaderStoreID != 0 && store.GetID() != f.pin.LeaderStoreID
+	}
+	return len(f.pin.StoreIDs) > 0 && !f.pin.IsPeerPinned(store.GetID())
+}
+
+type placementIntentFilter struct {
+	scope          string
+	intent         *core.PlacementIntent
+	transferLeader bool
+}
+
+// NewPlacementIntentFilter creates a Filter that keeps the schedulers from
+// moving the region against its placement intent, which works like the region
+// pin filter on the declared stores. The intent can be nil if the region has
+// no intent.
+func NewPlacementIntentFilter(scope string, intent *core.PlacementIntent, transferLeader bool) Filter {
+	return &placementIntentFilter{scope: scope, intent: intent, transferLeader: transferLeader}
+}
+
+func (f *placementIntentFilter) Scope() string {
+	return f.scope
+}
+
+func (f *placementIntentFilter) Type() string {
+	return "placement-intent-filter"
+}
+
+func (f *placementIntentFilter) Source(opt opt.Options, store *core.StoreInfo) bool {
+	if f.intent == nil {
+		return false
+	}
+	if store.GetID() == f.intent.LeaderStoreID {
+		return true
+	}
+	return !f.transferLeader && f.intent.IsPeerPlaced(store.GetID())
+}
+
+func (f *placementIntentFilter) Target(opt opt.Options, store *core.StoreInfo) bool {
+	if f.intent == nil {
+		return false
+	}
+	if f.transferLeader {
+		return f.intent.LeaderStoreID != 0 && store.GetID() != f.intent.LeaderStoreID
+	}
+	return len(f.intent.StoreIDs) > 0 && !f.intent.IsPeerPlaced(store.GetID())
+}
+
+type schedulerDenyFilter struct{ scope string }
+
+// NewSchedulerDenyFilter creates a Filter that filters all stores denied for
+// the scheduler by the deny list in its configuration. The scope must be the
+// name of the scheduler.
+func NewSchedulerDenyFilter(scope string) Filter {
+	return &schedulerDenyFilter{scope: scope}
+}
+
+func (f *schedulerDenyFilter) Scope() string {
+	return f.scope
+}
+
+func (f *schedulerDenyFilter) Type() string {
+	return "scheduler-deny-filter"
+}
+
+func (f *schedulerDenyFilter) Source(opt opt.Options, store *core.StoreInfo) bool {
+	return f.isDenied(opt, store)
+}
+
+func (f *schedulerDenyFilter) Target(opt opt.Options, store *core.StoreInfo) bool {
+	return f.isDenied(opt, store)
+}
+
+func (f *schedulerDenyFilter) isDenied(opt opt.Options, store *core.StoreInfo) bool {
+	for _, id := range opt.GetSchedulerDeniedStores(f.scope) {
+		if id == store.GetID() {
+			return true
+		}
+	}
+	return false
+}
+
+type storeTypeFilter struct {
+	scope string
+	typ   core.StoreType
+}
+
+// NewStoreTypeFilter creates a Filter that filters all stores not of the
+// type, to keep the scheduling within a tier.
+func NewStoreTypeFilter(scope string, typ core.StoreType) Filter {
+	return &storeTypeFilter{scope: scope, typ: typ}
+}
+
+func (f *storeTypeFilter) Scope() string {
+	return f.scope
+}
+
+func (f *storeTypeFilter) Type() string {
+	return "store-type-filter"
+}
+
+func (f *storeTypeFilter) Source(opt opt.Options, store *core.StoreInfo) bool {
+	return store.GetStoreType() != f.typ
+}
+
+func (f *storeTypeFilter) Target(opt opt.Options, store *core.StoreInfo) bool {
+	return store.GetStoreType() != f.typ
+}
+
+type learnerOnlyFilter struct{ scope string }
+
+// NewLearnerOnlyFilter creates a Filter that filters all learner-only stores
+// from being the targets of voters and leaders.
+func NewLearnerOnlyFilter(scope string) Filter {
+	return &learnerOnlyFilter{scope: scope}
+}
+
+func (f *learnerOnlyFilter) Scope() string {
+	return f.scope
+}
+
+func (f *learnerOnlyFilter) Type() string {
+	return "learner-only-filter"
+}
+
+func (f *learnerOnlyFilter) Source(opt opt.Options, store *core.StoreInfo) bool {
+	return false
+}
+
+func (f *learnerOnlyFilter) Target(opt opt.Options, store *core.StoreInfo) bool {
+	return store.IsLearnerOnly()
+}
+
+type leaderFractionFilter struct {
+	scope        string
+	totalLeaders int
+}
+
+// NewLeaderFractionFilter creates a Filter that filters all stores which would
+// hold more than the max leader fraction of the leaders in stores after
+// receiving one more leader.
+func NewLeaderFractionFilter(scope string, stores []*core.StoreInfo) Filter {
+	f := &leaderFractionFilter{scope: scope}
+	for _, store := range stores {
+		f.totalLeaders += store.GetLeaderCount()
+	}
+	return f
+}
+
+func (f *leaderFractionFilter) Scope() string {
+	return f.scope
+}
+
+func (f *leaderFractionFilter) Type() string {
+	return "leader-fraction-filter"
+}
+
+func (f *leaderFractionFilter) Source(opt opt.Options, store *core.StoreInfo) bool {
+	return false
+}
+
+func (f *leaderFractionFilter) Target(opt opt.Options, store *core.StoreInfo) bool {
+	fraction := opt.GetMaxLeaderFractionPerStore()
+	if fraction <= 0 {
+		return false
+	}
+	return float64(store.GetLeaderCount()+1) > fraction*float64(f.totalLeaders)
+}
+
 type overloadFilter struct{ scope string }
 
 // NewOverloadFilter creates a Filter that filters all stores that are overloaded from balance.
@@ -281,6 +544,72 @@ func (f *storageThresholdFilter) Target(opt opt.Options, store *core.StoreInfo)
 	return store.IsLowSpace(opt.GetLowSpaceRatio())
 }
 
+type regionCountFilter struct{ scope string }
+
+// NewRegionCountFilter creates a Filter that filters all stores whose region
+// count reaches the limit, so that no more peers are added to them.
+func NewRegionCountFilter(scope string) Filter {
+	return &regionCountFilter{scope: scope}
+}
+
+func (f *regionCountFilter) Scope() string {
+	return f.scope
+}
+
+func (f *regionCountFilter) Type() string {
+	return "region-count-filter"
+}
+
+func (f *regionCountFilter) Source(opt opt.Options, store *core.StoreInfo) bool {
+	return false
+}
+
+func (f *regionCountFilter) Target(opt opt.Options, store *core.StoreInfo) bool {
+	return isRegionCountFull(opt, store)
+}
+
+type memoryPressureFilter struct{ scope string }
+
+// NewMemoryPressureFilter creates a Filter that filters all stores under
+// sustained memory pressure, so that no more peers are added to them.
+func NewMemoryPressureFilter(scope string) Filter {
+	return &memoryPressureFilter{scope: scope}
+}
+
+func (f *memoryPressureFilter) Scope() string {
+	return f.scope
+}
+
+func (f *memoryPressureFilter) Type() string {
+	return "memory-pressure-filter"
+}
+
+func (f *memoryPressureFilter) Source(opt opt.Options, store *core.StoreInfo) bool {
+	return false
+}
+
+func (f *memoryPressureFilter) Target(opt opt.Options, store *core.StoreInfo) bool {
+	return store.IsMemoryPressureSustained(opt.GetMemoryPressureRatio())
+}
+
+// isRegionCountFull checks if the region count of the store reaches the
+// smaller one of the absolute limit and the limit relative to its capacity.
+// The relative limit is at least 1, so that the stores smaller than 1GB are
+// not regarded as full without any region.
+func isRegionCountFull(opt opt.Options, store *core.StoreInfo) bool {
+	limit := opt.GetMaxStoreRegionCount()
+	if perGB := opt.GetMaxStoreRegionCountPerGB(); perGB > 0 && store.GetCapacity() > 0 {
+		relative := uint64(float64(perGB) * float64(store.GetCapacity()) / (1 << 30))
+		if relative == 0 {
+			relative = 1
+		}
+		if limit == 0 || relative < limit {
+			return uint64(store.GetRegionCount()) >= relative
+		}
+	}
+	return limit > 0 && uint64(store.GetRegionCount()) >= limit
+}
+
 // distinctScoreFilter ensures that distinct score will not decrease.
 type distinctScoreFilter struct {
 	scope     string
@@ -394,6 +723,10 @@ func (f StoreStateFilter) Source(opt opt.Options, store *core.StoreInfo) bool {
 	if f.MoveRegion && f.filterMoveRegion(opt, store) {
 		return true
 	}
+	// The learners on learner-only stores must not be moved as voters.
+	if f.MoveRegion && store.IsLearnerOnly() {
+		return true
+	}
 	return false
 }
 
@@ -412,6 +745,14 @@ func (f StoreStateFilter) Target(opts opt.Options, store *core.StoreInfo) bool {
 			opts.CheckLabelProperty(opt.RejectLeader, store.GetLabels())) {
 		return true
 	}
+	// Leaders serve the requests, so they are kept away from the stores once
+	// the memory pressure starts.
+	if f.TransferLeader && store.IsMemoryPressured(opts.GetMemoryPressureRatio()) {
+		return true
+	}
+	if (f.TransferLeader || f.MoveRegion) && store.IsLearnerOnly() {
+		return true
+	}
 
 	if f.MoveRegion {
 		// only target consider the pending peers because pending more means the disk is slower.
@@ -419,6 +760,14 @@ func (f StoreStateFilter) Target(opts opt.Options, store *core.StoreInfo) bool {
 			return true
 		}
 
+		if isRegionCountFull(opts, store) {
+			return true
+		}
+
+		if store.IsMemoryPressureSustained(opts.GetMemoryPressureRatio()) {
+			return true
+		}
+
 		if f.filterMoveRegion(opts, store) {
 			return true
 		}
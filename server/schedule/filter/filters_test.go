@@ -13,10 +13,15 @@
 package filter
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
 	. "github.com/pingcap/check"
 	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/pingcap/pd/pkg/mock/mockcluster"
 	"github.com/pingcap/pd/pkg/mock/mockoption"
 	"github.com/pingcap/pd/server/core"
@@ -30,6 +35,36 @@ var _ = Suite(&testFiltersSuite{})
 
 type testFiltersSuite struct{}
 
+type tracedCluster struct {
+	*mockcluster.Cluster
+	span opentracing.Span
+}
+
+func (c *tracedCluster) FilterSpan() opentracing.Span {
+	return c.span
+}
+
+func (s *testFiltersSuite) TestTraceFiltered(c *C) {
+	tracer := mocktracer.New()
+	span := tracer.StartSpan("schedule")
+	tc := &tracedCluster{Cluster: mockcluster.NewCluster(mockoption.NewScheduleOptions()), span: span}
+	filters := []Filter{NewExcludedFilter("test", map[uint64]struct{}{1: {}}, map[uint64]struct{}{2: {}})}
+	c.Assert(Source(tc, core.NewStoreInfo(&metapb.Store{Id: 1}), filters), IsTrue)
+	c.Assert(Source(tc, core.NewStoreInfo(&metapb.Store{Id: 2}), filters), IsFalse)
+	c.Assert(Target(tc, core.NewStoreInfo(&metapb.Store{Id: 2}), filters), IsTrue)
+	span.Finish()
+
+	// Only the rejected stores are logged.
+	records := span.(*mocktracer.MockSpan).Logs()
+	c.Assert(records, HasLen, 2)
+	for i, event := range []string{"filter-source", "filter-target"} {
+		fields := records[i].Fields
+		c.Assert(fields[0].ValueString, Equals, event)
+		c.Assert(fields[1].ValueString, Equals, fmt.Sprint(i+1))
+		c.Assert(fields[3].ValueString, Equals, "exclude-filter")
+	}
+}
+
 func (s *testFiltersSuite) TestPendingPeerFilter(c *C) {
 	filter := NewPendingPeerCountFilter("")
 	opt := mockoption.NewScheduleOptions()
@@ -44,3 +79,185 @@ func (s *testFiltersSuite) TestPendingPeerFilter(c *C) {
 	c.Assert(filter.Source(tc, newStore), IsFalse)
 	c.Assert(filter.Target(tc, newStore), IsFalse)
 }
+
+func (s *testFiltersSuite) TestLeaderFractionFilter(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	tc := mockcluster.NewCluster(opt)
+	stores := []*core.StoreInfo{
+		core.NewStoreInfo(&metapb.Store{Id: 1}, core.SetLeaderCount(6)),
+		core.NewStoreInfo(&metapb.Store{Id: 2}, core.SetLeaderCount(4)),
+	}
+	filter := NewLeaderFractionFilter("", stores)
+	// 0 means no limit.
+	c.Assert(filter.Target(tc, stores[0]), IsFalse)
+	opt.MaxLeaderFractionPerStore = 0.5
+	c.Assert(filter.Source(tc, stores[0]), IsFalse)
+	c.Assert(filter.Target(tc, stores[0]), IsTrue)
+	c.Assert(filter.Target(tc, stores[1]), IsFalse)
+}
+
+func (s *testFiltersSuite) TestSchedulerDenyFilter(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	tc := mockcluster.NewCluster(opt)
+	store := core.NewStoreInfo(&metapb.Store{Id: 1})
+	filter := NewSchedulerDenyFilter("balance-region-scheduler")
+	c.Assert(filter.Source(tc, store), IsFalse)
+	opt.SchedulerDeniedStores = map[string][]uint64{"balance-leader-scheduler": {1}}
+	c.Assert(filter.Source(tc, store), IsFalse)
+	opt.SchedulerDeniedStores["balance-region-scheduler"] = []uint64{2, 1}
+	c.Assert(filter.Source(tc, store), IsTrue)
+	c.Assert(filter.Target(tc, store), IsTrue)
+}
+
+func (s *testFiltersSuite) TestRegionPinFilter(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	tc := mockcluster.NewCluster(opt)
+	stores := make([]*core.StoreInfo, 0, 4)
+	for id := uint64(1); id <= 4; id++ {
+		stores = append(stores, core.NewStoreInfo(&metapb.Store{Id: id}))
+	}
+
+	// Nothing is filtered if the region is not pinned.
+	filter := NewRegionPinFilter("", nil, false)
+	c.Assert(filter.Source(tc, stores[0]), IsFalse)
+	c.Assert(filter.Target(tc, stores[3]), IsFalse)
+
+	// Only the leader is pinned.
+	pin := &core.RegionPin{RegionID: 1, LeaderStoreID: 1}
+	leaderFilter := NewRegionPinFilter("", pin, true)
+	peerFilter := NewRegionPinFilter("", pin, false)
+	c.Assert(leaderFilter.Source(tc, stores[0]), IsTrue)
+	c.Assert(leaderFilter.Source(tc, stores[1]), IsFalse)
+	c.Assert(leaderFilter.Target(tc, stores[0]), IsFalse)
+	c.Assert(leaderFilter.Target(tc, stores[1]), IsTrue)
+	c.Assert(peerFilter.Source(tc, stores[0]), IsTrue)
+	c.Assert(peerFilter.Source(tc, stores[1]), IsFalse)
+	c.Assert(peerFilter.Target(tc, stores[3]), IsFalse)
+
+	// Only the peers are pinned.
+	pin = &core.RegionPin{RegionID: 1, StoreIDs: []uint64{1, 2, 3}}
+	leaderFilter = NewRegionPinFilter("", pin, true)
+	peerFilter = NewRegionPinFilter("", pin, false)
+	c.Assert(leaderFilter.Source(tc, stores[0]), IsFalse)
+	c.Assert(leaderFilter.Target(tc, stores[1]), IsFalse)
+	c.Assert(peerFilter.Source(tc, stores[0]), IsTrue)
+	c.Assert(peerFilter.Source(tc, stores[3]), IsFalse)
+	c.Assert(peerFilter.Target(tc, stores[2]), IsFalse)
+	c.Assert(peerFilter.Target(tc, stores[3]), IsTrue)
+}
+
+func (s *testFiltersSuite) TestPlacementIntentFilter(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	tc := mockcluster.NewCluster(opt)
+	stores := make([]*core.StoreInfo, 0, 4)
+	for id := uint64(1); id <= 4; id++ {
+		stores = append(stores, core.NewStoreInfo(&metapb.Store{Id: id}))
+	}
+
+	filter := NewPlacementIntentFilter("", nil, false)
+	c.Assert(filter.Source(tc, stores[0]), IsFalse)
+	c.Assert(filter.Target(tc, stores[3]), IsFalse)
+
+	intent := &core.PlacementIntent{RegionIDs: []uint64{1}, LeaderStoreID: 1, StoreIDs: []uint64{1, 2, 3}}
+	leaderFilter := NewPlacementIntentFilter("", intent, true)
+	peerFilter := NewPlacementIntentFilter("", intent, false)
+	c.Assert(leaderFilter.Source(tc, stores[0]), IsTrue)
+	c.Assert(leaderFilter.Source(tc, stores[1]), IsFalse)
+	c.Assert(leaderFilter.Target(tc, stores[0]), IsFalse)
+	c.Assert(leaderFilter.Target(tc, stores[1]), IsTrue)
+	c.Assert(peerFilter.Source(tc, stores[1]), IsTrue)
+	c.Assert(peerFilter.Source(tc, stores[3]), IsFalse)
+	c.Assert(peerFilter.Target(tc, stores[2]), IsFalse)
+	c.Assert(peerFilter.Target(tc, stores[3]), IsTrue)
+}
+
+func (s *testFiltersSuite) TestRegionCountFilter(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	tc := mockcluster.NewCluster(opt)
+	store := core.NewStoreInfo(&metapb.Store{Id: 1},
+		core.SetStoreStats(&pdpb.StoreStats{Capacity: 2 << 30}),
+		core.SetRegionCount(100),
+		core.SetLastHeartbeatTS(time.Now()))
+	filter := NewRegionCountFilter("")
+	// 0 means no limit.
+	c.Assert(filter.Target(tc, store), IsFalse)
+	c.Assert(StoreStateFilter{MoveRegion: true}.Target(tc, store), IsFalse)
+	opt.MaxStoreRegionCount = 100
+	c.Assert(filter.Source(tc, store), IsFalse)
+	c.Assert(filter.Target(tc, store), IsTrue)
+	c.Assert(StoreStateFilter{MoveRegion: true}.Target(tc, store), IsTrue)
+	opt.MaxStoreRegionCount = 200
+	c.Assert(filter.Target(tc, store), IsFalse)
+	// The smaller limit takes effect.
+	opt.MaxStoreRegionCountPerGB = 50
+	c.Assert(filter.Target(tc, store), IsTrue)
+	opt.MaxStoreRegionCountPerGB = 60
+	c.Assert(filter.Target(tc, store), IsFalse)
+	opt.MaxStoreRegionCount = 0
+	opt.MaxStoreRegionCountPerGB = 50
+	c.Assert(filter.Target(tc, store), IsTrue)
+
+	// The stores smaller than 1GB are limited by their fractional capacity,
+	// and accept at least one region.
+	small := core.NewStoreInfo(&metapb.Store{Id: 2},
+		core.SetStoreStats(&pdpb.StoreStats{Capacity: 512 << 20}),
+		core.SetRegionCount(20),
+		core.SetLastHeartbeatTS(time.Now()))
+	c.Assert(filter.Target(tc, small), IsFalse)
+	c.Assert(filter.Target(tc, small.Clone(core.SetRegionCount(25))), IsTrue)
+	opt.MaxStoreRegionCountPerGB = 1
+	c.Assert(filter.Target(tc, small.Clone(core.SetRegionCount(0))), IsFalse)
+	c.Assert(filter.Target(tc, small.Clone(core.SetRegionCount(1))), IsTrue)
+}
+
+func (s *testFiltersSuite) TestMemoryPressureFilter(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	tc := mockcluster.NewCluster(opt)
+	newStore := func(used uint64, since time.Time) *core.StoreInfo {
+		return core.NewStoreInfo(&metapb.Store{Id: 1},
+			core.SetMemoryUsage(100, used),
+			core.SetMemoryPressureSince(since),
+			core.SetLastHeartbeatTS(time.Now()))
+	}
+	filter := NewMemoryPressureFilter("")
+	leaderFilter := StoreStateFilter{TransferLeader: true}
+	peerFilter := StoreStateFilter{MoveRegion: true}
+
+	store := newStore(50, time.Time{})
+	c.Assert(filter.Target(tc, store), IsFalse)
+	c.Assert(leaderFilter.Target(tc, store), IsFalse)
+	c.Assert(peerFilter.Target(tc, store), IsFalse)
+	// The leaders are filtered once the pressure starts.
+	store = newStore(95, time.Now())
+	c.Assert(filter.Source(tc, store), IsFalse)
+	c.Assert(filter.Target(tc, store), IsFalse)
+	c.Assert(leaderFilter.Target(tc, store), IsTrue)
+	c.Assert(peerFilter.Target(tc, store), IsFalse)
+	// The peers are filtered once the pressure is sustained.
+	store = newStore(95, time.Now().Add(-core.MemoryPressureSustainTime))
+	c.Assert(filter.Target(tc, store), IsTrue)
+	c.Assert(peerFilter.Target(tc, store), IsTrue)
+	// 0 means the memory usage is ignored.
+	opt.MemoryPressureRatio = 0
+	c.Assert(filter.Target(tc, store), IsFalse)
+	c.Assert(leaderFilter.Target(tc, store), IsFalse)
+	// The store not reporting memory is never pressured.
+	opt.MemoryPressureRatio = 0.9
+	store = core.NewStoreInfo(&metapb.Store{Id: 1}, core.SetLastHeartbeatTS(time.Now()))
+	c.Assert(leaderFilter.Target(tc, store), IsFalse)
+}
+
+func (s *testFiltersSuite) TestLearnerOnlyFilter(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	tc := mockcluster.NewCluster(opt)
+	labels := []*metapb.StoreLabel{{Key: core.StoreRoleLabelKey, Value: core.StoreRoleLearnerOnly}}
+	store := core.NewStoreInfo(&metapb.Store{Id: 1, Labels: labels}, core.SetLastHeartbeatTS(time.Now()))
+	filter := NewLearnerOnlyFilter("")
+	c.Assert(filter.Source(tc, store), IsFalse)
+	c.Assert(filter.Target(tc, store), IsTrue)
+	c.Assert(StoreStateFilter{}.Target(tc, store), IsFalse)
+	c.Assert(StoreStateFilter{TransferLeader: true}.Target(tc, store), IsTrue)
+	c.Assert(StoreStateFilter{MoveRegion: true}.Source(tc, store), IsTrue)
+	c.Assert(StoreStateFilter{MoveRegion: true}.Target(tc, store), IsTrue)
+	c.Assert(filter.Target(tc, core.NewStoreInfo(&metapb.Store{Id: 2})), IsFalse)
+}
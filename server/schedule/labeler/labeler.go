@@ -0,0 +1,189 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package labeler
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/pingcap/pd/server/core"
+	"github.com/pkg/errors"
+)
+
+const (
+	// HotServing is the label of latency-sensitive ranges, whose leaders
+	// should be kept on performance stores.
+	HotServing = "hot-serving"
+	// Cold is the label of cold ranges, whose leaders are allowed to be on
+	// storage stores.
+	Cold = "cold"
+)
+
+// LabelRule binds a label to a key range. The keys are hex encoded.
+type LabelRule struct {
+	ID       string `json:"id"`
+	Label    string `json:"label"`
+	StartKey string `json:"start_key"`
+	EndKey   string `json:"end_key"`
+
+	startKey, endKey []byte
+}
+
+func (r *LabelRule) adjust() error {
+	if r.ID == "" {
+		return errors.New("empty rule id")
+	}
+	if r.Label == "" {
+		return errors.New("empty label")
+	}
+	var err error
+	if r.startKey, err = hex.DecodeString(r.StartKey); err != nil {
+		return errors.Wrap(err, "start key should be in hex format")
+	}
+	if r.endKey, err = hex.DecodeString(r.EndKey); err != nil {
+		return errors.Wrap(err, "end key should be in hex format")
+	}
+	if len(r.endKey) > 0 && bytes.Compare(r.startKey, r.endKey) >= 0 {
+		return errors.New("start key should be less than end key")
+	}
+	return nil
+}
+
+// contains checks if the range [startKey, endKey) is covered by the rule.
+func (r *LabelRule) contains(startKey, endKey []byte) bool {
+	if bytes.Compare(startKey, r.startKey) < 0 {
+		return false
+	}
+	if len(r.endKey) == 0 {
+		return true
+	}
+	return len(endKey) > 0 && bytes.Compare(endKey, r.endKey) <= 0
+}
+
+// RangeLabeler labels key ranges by persisted rules, so that checkers and
+// schedulers can treat regions differently according to their labels.
+type RangeLabeler struct {
+	sync.RWMutex
+	storage *core.Storage
+	rules   map[string]*LabelRule
+}
+
+// NewRangeLabeler creates a RangeLabeler and loads the rules from storage.
+func NewRangeLabeler(storage *core.Storage) (*RangeLabeler, error) {
+	l := &RangeLabeler{
+		storage: storage,
+		rules:   make(map[string]*LabelRule),
+	}
+	if storage == nil {
+		return l, nil
+	}
+	err := storage.LoadRangeLabelRules(func(value string) error {
+		rule := &LabelRule{}
+		if err := json.Unmarshal([]byte(value), rule); err != nil {
+			return errors.WithStack(err)
+		}
+		if err := rule.adjust(); err != nil {
+			return err
+		}
+		l.rules[rule.ID] = rule
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// SetLabelRule creates or updates a rule.
+func (l *RangeLabeler) SetLabelRule(rule *LabelRule) error {
+	if err := rule.adjust(); err != nil {
+		return err
+	}
+	l.Lock()
+	defer l.Unlock()
+	if l.storage != nil {
+		if err := l.storage.SaveRangeLabelRule(rule.ID, rule); err != nil {
+			return err
+		}
+	}
+	l.rules[rule.ID] = rule
+	return nil
+}
+
+// DeleteLabelRule removes a rule.
+func (l *RangeLabeler) DeleteLabelRule(id string) error {
+	l.Lock()
+	defer l.Unlock()
+	if l.storage != nil {
+		if err := l.storage.DeleteRangeLabelRule(id); err != nil {
+			return err
+		}
+	}
+	delete(l.rules, id)
+	return nil
+}
+
+// GetLabelRule returns the rule with the id.
+func (l *RangeLabeler) GetLabelRule(id string) *LabelRule {
+	l.RLock()
+	defer l.RUnlock()
+	return l.rules[id]
+}
+
+// GetLabelRules returns all rules sorted by id.
+func (l *RangeLabeler) GetLabelRules() []*LabelRule {
+	l.RLock()
+	defer l.RUnlock()
+	rules := make([]*LabelRule, 0, len(l.rules))
+	for _, rule := range l.rules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules
+}
+
+// GetRegionLabels returns the labels of the rules which cover the region.
+func (l *RangeLabeler) GetRegionLabels(region *core.RegionInfo) []string {
+	l.RLock()
+	defer l.RUnlock()
+	var labels []string
+	for _, rule := range l.rules {
+		if rule.contains(region.GetStartKey(), region.GetEndKey()) {
+			labels = append(labels, rule.Label)
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// HasLabel checks if the region is covered by a rule with the label.
+func (l *RangeLabeler) HasLabel(region *core.RegionInfo, label string) bool {
+	return l.HasRangeLabel(region.GetStartKey(), region.GetEndKey(), label)
+}
+
+// HasRangeLabel checks if the range [startKey, endKey) is covered by a rule
+// with the label.
+func (l *RangeLabeler) HasRangeLabel(startKey, endKey []byte, label string) bool {
+	l.RLock()
+	defer l.RUnlock()
+	for _, rule := range l.rules {
+		if rule.Label == label && rule.contains(startKey, endKey) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package labeler
+
+import (
+	"testing"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/kv"
+)
+
+func TestLabeler(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testLabelerSuite{})
+
+type testLabelerSuite struct{}
+
+func newTestRegion(startKey, endKey string) *core.RegionInfo {
+	return core.NewRegionInfo(&metapb.Region{StartKey: []byte(startKey), EndKey: []byte(endKey)}, nil)
+}
+
+func (s *testLabelerSuite) TestAdjustRule(c *C) {
+	l, err := NewRangeLabeler(nil)
+	c.Assert(err, IsNil)
+	c.Assert(l.SetLabelRule(&LabelRule{Label: HotServing}), NotNil)
+	c.Assert(l.SetLabelRule(&LabelRule{ID: "1"}), NotNil)
+	c.Assert(l.SetLabelRule(&LabelRule{ID: "1", Label: HotServing, StartKey: "zz"}), NotNil)
+	c.Assert(l.SetLabelRule(&LabelRule{ID: "1", Label: HotServing, StartKey: "62", EndKey: "61"}), NotNil)
+	c.Assert(l.GetLabelRules(), HasLen, 0)
+}
+
+func (s *testLabelerSuite) TestRegionLabels(c *C) {
+	storage := core.NewStorage(kv.NewMemoryKV())
+	l, err := NewRangeLabeler(storage)
+	c.Assert(err, IsNil)
+	c.Assert(l.SetLabelRule(&LabelRule{ID: "1", Label: HotServing, StartKey: "61", EndKey: "63"}), IsNil)
+	c.Assert(l.SetLabelRule(&LabelRule{ID: "2", Label: Cold, StartKey: "62", EndKey: ""}), IsNil)
+
+	c.Assert(l.GetRegionLabels(newTestRegion("a", "b")), DeepEquals, []string{HotServing})
+	c.Assert(l.GetRegionLabels(newTestRegion("b", "c")), DeepEquals, []string{Cold, HotServing})
+	c.Assert(l.GetRegionLabels(newTestRegion("b", "")), DeepEquals, []string{Cold})
+	c.Assert(l.GetRegionLabels(newTestRegion("", "a")), HasLen, 0)
+	c.Assert(l.HasLabel(newTestRegion("a", "d"), HotServing), IsFalse)
+	c.Assert(l.HasLabel(newTestRegion("x", "y"), Cold), IsTrue)
+
+	// Rules are persisted.
+	l2, err := NewRangeLabeler(storage)
+	c.Assert(err, IsNil)
+	c.Assert(l2.GetLabelRules(), HasLen, 2)
+	c.Assert(l2.HasLabel(newTestRegion("a", "b"), HotServing), IsTrue)
+
+	c.Assert(l.DeleteLabelRule("1"), IsNil)
+	c.Assert(l.GetLabelRule("1"), IsNil)
+	l2, err = NewRangeLabeler(storage)
+	c.Assert(err, IsNil)
+	c.Assert(l2.GetLabelRules(), HasLen, 1)
+	c.Assert(l2.GetLabelRules()[0].ID, Equals, "2")
+}
@@ -57,6 +57,22 @@ var (
 			Name:      "store_limit",
 			Help:      "Limit of store.",
 		}, []string{"store", "type"})
+
+	snapshotBandwidthGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "schedule",
+			Name:      "snapshot_bandwidth",
+			Help:      "Budget and allocation of the snapshot bandwidth of operators.",
+		}, []string{"type"})
+
+	eventBusCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "schedule",
+			Name:      "event_bus_events_total",
+			Help:      "Counter of the events delivered to or dropped by the subscribers of the event bus.",
+		}, []string{"subscriber", "type", "event"})
 )
 
 func init() {
@@ -64,5 +80,7 @@ func init() {
 	prometheus.MustRegister(operatorDuration)
 	prometheus.MustRegister(operatorWaitDuration)
 	prometheus.MustRegister(storeLimitGauge)
+	prometheus.MustRegister(snapshotBandwidthGauge)
 	prometheus.MustRegister(operatorWaitCounter)
+	prometheus.MustRegister(eventBusCounter)
 }
@@ -0,0 +1,58 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+// The capabilities a store advertises for the steps and the controls which
+// are newer than the base protocol. The steps are only issued to the regions
+// whose stores all advertise the capabilities they require, and the controls
+// only to the stores advertising them, so that the stores of old versions in
+// a cluster being upgraded do not misinterpret them.
+const (
+	// CapabilityDemoteVoter is demoting a voter to learner in place, which is
+	// sent as adding a learner on the store of the voter.
+	CapabilityDemoteVoter = "demote-voter"
+	// CapabilitySnapshotBandwidthCap is capping the bandwidth of the
+	// snapshots sent by the store to its share of the snapshot bandwidth
+	// budget, which is sent in the store heartbeat responses.
+	CapabilitySnapshotBandwidthCap = "snapshot-bandwidth-cap"
+)
+
+// StepCapability returns the capability required by the step, or "" if the
+// step is in the base protocol.
+func StepCapability(step OpStep) string {
+	switch step.(type) {
+	case DemoteVoter:
+		return CapabilityDemoteVoter
+	default:
+		return ""
+	}
+}
+
+// RequiredCapabilities returns the capabilities required by the steps of the
+// operator, in the order of the steps.
+func (o *Operator) RequiredCapabilities() []string {
+	var capabilities []string
+	seen := make(map[string]struct{})
+	for _, step := range o.steps {
+		c := StepCapability(step)
+		if c == "" {
+			continue
+		}
+		if _, ok := seen[c]; !ok {
+			seen[c] = struct{}{}
+			capabilities = append(capabilities, c)
+		}
+	}
+	return capabilities
+}
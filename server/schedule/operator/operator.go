@@ -24,6 +24,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/opentracing/opentracing-go"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/pingcap/log"
@@ -39,6 +40,10 @@ const (
 	// RegionOperatorWaitTime is the duration that when a region operator lives
 	// longer than it, the operator will be considered timeout.
 	RegionOperatorWaitTime = 10 * time.Minute
+	// expectedDurationTimeoutFactor is the multiple of the expected duration,
+	// after which a region operator is considered timeout if it is longer
+	// than RegionOperatorWaitTime.
+	expectedDurationTimeoutFactor = 2
 	// RegionInfluence represents the influence of a operator step, which is used by ratelimit.
 	RegionInfluence int64 = 1000
 	// smallRegionInfluence represents the influence of a operator step
@@ -77,6 +82,10 @@ type StoreInfluence struct {
 	LeaderSize  int64
 	LeaderCount int64
 	StepCost    int64
+	// BytesWriteRate and BytesReadRate are the flows in bytes per second.
+	// Writes are served by all peers and reads are served by the leader.
+	BytesWriteRate float64
+	BytesReadRate  float64
 }
 
 // ResourceSize returns delta size of leader/region by influence.
@@ -91,6 +100,31 @@ func (s StoreInfluence) ResourceSize(kind core.ResourceKind) int64 {
 	}
 }
 
+// ResourceCount returns delta count of leader/region by influence.
+func (s StoreInfluence) ResourceCount(kind core.ResourceKind) int64 {
+	switch kind {
+	case core.LeaderKind:
+		return s.LeaderCount
+	case core.RegionKind:
+		return s.RegionCount
+	default:
+		return 0
+	}
+}
+
+// BytesRate returns delta flow that moves with leader/region by influence.
+// Leaders carry the read flow, and peers carry the write flow.
+func (s StoreInfluence) BytesRate(kind core.ResourceKind) float64 {
+	switch kind {
+	case core.LeaderKind:
+		return s.BytesReadRate
+	case core.RegionKind:
+		return s.BytesWriteRate
+	default:
+		return 0
+	}
+}
+
 type u64Set map[uint64]struct{}
 
 type u64Slice []uint64
@@ -150,8 +184,10 @@ func (tl TransferLeader) Influence(opInfluence OpInfluence, region *core.RegionI
 
 	from.LeaderSize -= region.GetApproximateSize()
 	from.LeaderCount--
+	from.BytesReadRate -= region.GetBytesReadRate()
 	to.LeaderSize += region.GetApproximateSize()
 	to.LeaderCount++
+	to.BytesReadRate += region.GetBytesReadRate()
 }
 
 // AddPeer is an OpStep that adds a region peer.
@@ -189,6 +225,7 @@ func (ap AddPeer) Influence(opInfluence OpInfluence, region *core.RegionInfo) {
 	regionSize := region.GetApproximateSize()
 	to.RegionSize += regionSize
 	to.RegionCount++
+	to.BytesWriteRate += region.GetBytesWriteRate()
 	if regionSize > smallRegionThreshold {
 		to.StepCost += RegionInfluence
 	} else if regionSize <= smallRegionThreshold && regionSize > core.EmptyRegionApproximateSize {
@@ -232,6 +269,7 @@ func (al AddLearner) Influence(opInfluence OpInfluence, region *core.RegionInfo)
 	regionSize := region.GetApproximateSize()
 	to.RegionSize += regionSize
 	to.RegionCount++
+	to.BytesWriteRate += region.GetBytesWriteRate()
 	if regionSize > smallRegionThreshold {
 		to.StepCost += RegionInfluence
 	} else if regionSize <= smallRegionThreshold && regionSize > core.EmptyRegionApproximateSize {
@@ -270,6 +308,38 @@ func (pl PromoteLearner) IsFinish(region *core.RegionInfo) bool {
 // Influence calculates the store difference that current step makes.
 func (pl PromoteLearner) Influence(opInfluence OpInfluence, region *core.RegionInfo) {}
 
+// DemoteVoter is an OpStep that demotes a region voter peer to learner in
+// place, without moving data.
+type DemoteVoter struct {
+	ToStore, PeerID uint64
+}
+
+// ConfVerChanged returns true if the conf version has been changed by this step
+func (dv DemoteVoter) ConfVerChanged(region *core.RegionInfo) bool {
+	if p := region.GetStoreLearner(dv.ToStore); p != nil {
+		return p.GetId() == dv.PeerID
+	}
+	return false
+}
+
+func (dv DemoteVoter) String() string {
+	return fmt.Sprintf("demote voter peer %v on store %v to learner", dv.PeerID, dv.ToStore)
+}
+
+// IsFinish checks if current step is finished.
+func (dv DemoteVoter) IsFinish(region *core.RegionInfo) bool {
+	if p := region.GetStoreLearner(dv.ToStore); p != nil {
+		if p.GetId() != dv.PeerID {
+			log.Warn("obtain unexpected peer", zap.String("expect", dv.String()), zap.Uint64("obtain-learner", p.GetId()))
+		}
+		return p.GetId() == dv.PeerID
+	}
+	return false
+}
+
+// Influence calculates the store difference that current step makes.
+func (dv DemoteVoter) Influence(opInfluence OpInfluence, region *core.RegionInfo) {}
+
 // RemovePeer is an OpStep that removes a region peer.
 type RemovePeer struct {
 	FromStore uint64
@@ -295,6 +365,7 @@ func (rp RemovePeer) Influence(opInfluence OpInfluence, region *core.RegionInfo)
 
 	from.RegionSize -= region.GetApproximateSize()
 	from.RegionCount--
+	from.BytesWriteRate -= region.GetBytesWriteRate()
 }
 
 // MergeRegion is an OpStep that merge two regions.
@@ -407,6 +478,7 @@ func (ap AddLightPeer) Influence(opInfluence OpInfluence, region *core.RegionInf
 
 	to.RegionSize += region.GetApproximateSize()
 	to.RegionCount++
+	to.BytesWriteRate += region.GetBytesWriteRate()
 }
 
 // AddLightLearner is an OpStep that adds a region learner peer without considering the influence.
@@ -444,6 +516,7 @@ func (al AddLightLearner) Influence(opInfluence OpInfluence, region *core.Region
 
 	to.RegionSize += region.GetApproximateSize()
 	to.RegionCount++
+	to.BytesWriteRate += region.GetBytesWriteRate()
 }
 
 // Operator contains execution steps generated by scheduler.
@@ -460,6 +533,21 @@ type Operator struct {
 	startTime time.Time
 	stepTime  int64
 	level     core.PriorityLevel
+	// snapshotStep is the step whose snapshot is admitted by the snapshot
+	// bandwidth budget, -1 if none.
+	snapshotStep int32
+	// expectedDuration is the estimated duration to finish the operator, 0 if
+	// not estimated.
+	expectedDuration time.Duration
+	// spanContext is the context of the span which creates the operator, so
+	// that the dispatch of the operator can be traced back to its creation.
+	spanContext opentracing.SpanContext
+	// locationLabel is the location label of the violation repaired by an
+	// OpLocation operator.
+	locationLabel string
+	// namespace is the namespace that the operator is scheduled in, which is
+	// empty if it is not scheduled in a namespace.
+	namespace string
 }
 
 // NewOperator creates a new operator.
@@ -478,6 +566,8 @@ func NewOperator(desc, brief string, regionID uint64, regionEpoch *metapb.Region
 		createTime:  time.Now(),
 		stepTime:    time.Now().UnixNano(),
 		level:       level,
+
+		snapshotStep: -1,
 	}
 }
 
@@ -546,6 +636,17 @@ func (o *Operator) SetStartTime(t time.Time) {
 	o.startTime = t
 }
 
+// SetExpectedDuration sets the estimated duration to finish the operator.
+func (o *Operator) SetExpectedDuration(d time.Duration) {
+	o.expectedDuration = d
+}
+
+// GetExpectedDuration returns the estimated duration to finish the operator,
+// or 0 if it is not estimated.
+func (o *Operator) GetExpectedDuration() time.Duration {
+	return o.expectedDuration
+}
+
 // GetStartTime ges the start time for operator.
 func (o *Operator) GetStartTime() time.Time {
 	return o.startTime
@@ -564,6 +665,51 @@ func (o *Operator) Step(i int) OpStep {
 	return nil
 }
 
+// LearnerStores returns the stores which the operator is going to add
+// learners to. The learners which have been added are not counted.
+func (o *Operator) LearnerStores() []uint64 {
+	var stores []uint64
+	for _, step := range o.steps[atomic.LoadInt32(&o.currentStep):] {
+		switch st := step.(type) {
+		case AddLearner:
+			stores = append(stores, st.ToStore)
+		case AddLightLearner:
+			stores = append(stores, st.ToStore)
+		}
+	}
+	return stores
+}
+
+// IsSendingSnapshot checks if the current step adds a peer, which receives a
+// snapshot of the region.
+func (o *Operator) IsSendingSnapshot() bool {
+	switch o.Step(int(atomic.LoadInt32(&o.currentStep))).(type) {
+	case AddPeer, AddLightPeer, AddLearner, AddLightLearner:
+		return true
+	}
+	return false
+}
+
+// IsMerging checks if the current step merges the region into the other one,
+// which means the merge request has been sent to TiKV. The passive operator
+// of the target region never sends it.
+func (o *Operator) IsMerging() bool {
+	step, ok := o.Step(int(atomic.LoadInt32(&o.currentStep))).(MergeRegion)
+	return ok && !step.IsPassive
+}
+
+// IsSnapshotAdmitted checks if the snapshot of the current step is admitted by
+// the snapshot bandwidth budget.
+func (o *Operator) IsSnapshotAdmitted() bool {
+	return atomic.LoadInt32(&o.snapshotStep) == atomic.LoadInt32(&o.currentStep)
+}
+
+// AdmitSnapshot marks the snapshot of the current step admitted, so that
+// dispatching the step again takes no more budget.
+func (o *Operator) AdmitSnapshot() {
+	atomic.StoreInt32(&o.snapshotStep, atomic.LoadInt32(&o.currentStep))
+}
+
 // Check checks if current step is finished, returns next step to take action.
 // It's safe to be called by multiple goroutine concurrently.
 func (o *Operator) Check(region *core.RegionInfo) OpStep {
@@ -596,6 +742,96 @@ func (o *Operator) ConfVerChanged(region *core.RegionInfo) int {
 	return total
 }
 
+// Progress describes the progress of the current step according to the
+// latest region info.
+func (o *Operator) Progress(region *core.RegionInfo) string {
+	current := atomic.LoadInt32(&o.currentStep)
+	if int(current) >= len(o.steps) {
+		return "finished"
+	}
+	step := o.steps[current]
+	progress := fmt.Sprintf("step %d/%d: %s", current+1, len(o.steps), step)
+	if region == nil {
+		return progress
+	}
+	return progress + ", " + stepProgress(step, region)
+}
+
+func stepProgress(step OpStep, region *core.RegionInfo) string {
+	if step.IsFinish(region) {
+		return "finished, waiting for the next heartbeat"
+	}
+	switch s := step.(type) {
+	case AddPeer:
+		return addPeerProgress(region, s.ToStore, s.PeerID)
+	case AddLightPeer:
+		return addPeerProgress(region, s.ToStore, s.PeerID)
+	case AddLearner:
+		return addPeerProgress(region, s.ToStore, s.PeerID)
+	case AddLightLearner:
+		return addPeerProgress(region, s.ToStore, s.PeerID)
+	case PromoteLearner:
+		return "waiting for the learner to be promoted"
+	case DemoteVoter:
+		return "waiting for the voter to be demoted"
+	case RemovePeer:
+		return "waiting for the peer to be removed"
+	case TransferLeader:
+		return fmt.Sprintf("waiting for the leader to be transferred, current leader is on store %v", region.GetLeader().GetStoreId())
+	case MergeRegion:
+		return "waiting for the regions to be merged"
+	case SplitRegion:
+		return "waiting for the region to be split"
+	}
+	return "running"
+}
+
+func addPeerProgress(region *core.RegionInfo, storeID, peerID uint64) string {
+	p := region.GetStorePeer(storeID)
+	if p == nil {
+		return "waiting for the peer to be created"
+	}
+	if p.GetId() != peerID {
+		return fmt.Sprintf("unexpected peer %v on store %v", p.GetId(), storeID)
+	}
+	if region.GetPendingPeer(peerID) != nil {
+		return "peer is created but not caught up, the snapshot may be being applied"
+	}
+	return "waiting for the peer to be updated"
+}
+
+// SetSpanContext sets the context of the span which creates the operator.
+func (o *Operator) SetSpanContext(ctx opentracing.SpanContext) {
+	o.spanContext = ctx
+}
+
+// SpanContext returns the context of the span which creates the operator.
+func (o *Operator) SpanContext() opentracing.SpanContext {
+	return o.spanContext
+}
+
+// SetLocationLabel sets the location label of the violation that the operator
+// repairs.
+func (o *Operator) SetLocationLabel(label string) {
+	o.locationLabel = label
+}
+
+// LocationLabel returns the location label of the violation that the operator
+// repairs, or empty if it is not an OpLocation operator.
+func (o *Operator) LocationLabel() string {
+	return o.locationLabel
+}
+
+// SetNamespace sets the namespace that the operator is scheduled in.
+func (o *Operator) SetNamespace(namespace string) {
+	o.namespace = namespace
+}
+
+// Namespace returns the namespace that the operator is scheduled in.
+func (o *Operator) Namespace() string {
+	return o.namespace
+}
+
 // SetPriorityLevel sets the priority level for operator.
 func (o *Operator) SetPriorityLevel(level core.PriorityLevel) {
 	o.level = level
@@ -621,7 +857,13 @@ func (o *Operator) IsTimeout() bool {
 		return false
 	}
 	if o.kind&OpRegion != 0 {
-		timeout = time.Since(o.startTime) > RegionOperatorWaitTime
+		// The operators moving large regions to slow stores are given more
+		// time than usual.
+		waitTime := RegionOperatorWaitTime
+		if d := o.expectedDuration * expectedDurationTimeoutFactor; d > waitTime {
+			waitTime = d
+		}
+		timeout = time.Since(o.startTime) > waitTime
 	} else {
 		timeout = time.Since(o.startTime) > LeaderOperatorWaitTime
 	}
@@ -652,6 +894,9 @@ type OpHistory struct {
 	FinishTime time.Time
 	From, To   uint64
 	Kind       core.ResourceKind
+	// Expected and Actual are the estimated and the actual durations of the
+	// operator, which are used to tune the estimation.
+	Expected, Actual time.Duration
 }
 
 // History transfers the operator's steps to operator histories.
@@ -687,6 +932,8 @@ func (o *Operator) History() []OpHistory {
 				From:       removePeerStores[i],
 				To:         addPeerStores[i],
 				Kind:       core.RegionKind,
+				Expected:   o.expectedDuration,
+				Actual:     o.RunningTime(),
 			})
 		}
 	}
@@ -717,6 +964,46 @@ func CreatePromoteLearnerOperator(desc string, region *core.RegionInfo, peer *me
 	return NewOperator(desc, brief, region.GetID(), region.GetRegionEpoch(), OpRegion, step)
 }
 
+// CreateDemoteVoterOperator creates an operator that demotes a voter to
+// learner. The leadership is transferred first if the voter is the leader.
+func CreateDemoteVoterOperator(desc string, cluster Cluster, region *core.RegionInfo, peer *metapb.Peer) (*Operator, error) {
+	kind, steps, err := demoteVoterSteps(cluster, region, peer, getRegionFollowerIDs(region))
+	if err != nil {
+		return nil, err
+	}
+	brief := fmt.Sprintf("demote voter: store %v", peer.GetStoreId())
+	return NewOperator(desc, brief, region.GetID(), region.GetRegionEpoch(), kind|OpRole, steps...), nil
+}
+
+// CreateSwitchRoleOperator creates an operator that promotes the learner and
+// demotes the voter in place, which flips the replicas carrying votes, such as
+// between the tiers of stores. The learner is promoted first so that the
+// region never runs with fewer voters.
+func CreateSwitchRoleOperator(desc string, cluster Cluster, region *core.RegionInfo, learner, voter *metapb.Peer) (*Operator, error) {
+	// The promoted learner is able to take over the leadership.
+	kind, steps, err := demoteVoterSteps(cluster, region, voter, append(getRegionFollowerIDs(region), learner.GetStoreId()))
+	if err != nil {
+		return nil, err
+	}
+	steps = append([]OpStep{PromoteLearner{ToStore: learner.GetStoreId(), PeerID: learner.GetId()}}, steps...)
+	brief := fmt.Sprintf("switch role: promote store %v, demote store %v", learner.GetStoreId(), voter.GetStoreId())
+	return NewOperator(desc, brief, region.GetID(), region.GetRegionEpoch(), kind|OpRole, steps...), nil
+}
+
+// demoteVoterSteps returns the steps to demote a voter. It prevents demoting
+// the leader by transferring its leadership first.
+func demoteVoterSteps(cluster Cluster, region *core.RegionInfo, peer *metapb.Peer, followerIDs []uint64) (kind OpKind, steps []OpStep, err error) {
+	if region.GetLeader() != nil && region.GetLeader().GetId() == peer.GetId() {
+		kind, steps, err = transferLeaderToSuitableSteps(cluster, peer.GetStoreId(), followerIDs)
+		if err != nil {
+			log.Debug("failed to create demote voter operator", zap.Uint64("region-id", region.GetID()), zap.Error(err))
+			return
+		}
+	}
+	steps = append(steps, DemoteVoter{ToStore: peer.GetStoreId(), PeerID: peer.GetId()})
+	return
+}
+
 // CreateRemovePeerOperator creates an operator that removes a peer from region.
 func CreateRemovePeerOperator(desc string, cluster Cluster, kind OpKind, region *core.RegionInfo, storeID uint64) (*Operator, error) {
 	removeKind, steps, err := removePeerSteps(cluster, region, storeID, getRegionFollowerIDs(region))
@@ -938,11 +1225,12 @@ func removePeerSteps(cluster Cluster, region *core.RegionInfo, storeID uint64, f
 }
 
 // findNoLabelProperty finds the first store without given label property.
+// The learner-only stores are skipped since they never hold voters.
 func findNoLabelProperty(cluster Cluster, prop string, storeIDs []uint64) (int, uint64) {
 	for i, id := range storeIDs {
 		store := cluster.GetStore(id)
 		if store != nil {
-			if !cluster.CheckLabelProperty(prop, store.GetLabels()) {
+			if !store.IsLearnerOnly() && !cluster.CheckLabelProperty(prop, store.GetLabels()) {
 				return i, id
 			}
 		} else {
@@ -1019,8 +1307,17 @@ func matchPeerSteps(cluster Cluster, source *core.RegionInfo, target *core.Regio
 
 // CreateScatterRegionOperator creates an operator that scatters the specified region.
 func CreateScatterRegionOperator(desc string, cluster Cluster, origin *core.RegionInfo, replacedPeers, targetPeers []*metapb.Peer) *Operator {
-	// Randomly pick a leader
-	i := rand.Intn(len(targetPeers))
+	// Randomly pick a leader from the voters.
+	voters := make([]int, 0, len(targetPeers))
+	for i, peer := range targetPeers {
+		if !peer.GetIsLearner() {
+			voters = append(voters, i)
+		}
+	}
+	if len(voters) == 0 {
+		return nil
+	}
+	i := voters[rand.Intn(len(voters))]
 	targetLeaderPeer := targetPeers[i]
 	originLeaderStoreID := origin.GetLeader().GetStoreId()
 
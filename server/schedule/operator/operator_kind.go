@@ -33,6 +33,10 @@ const (
 	OpBalance                      // Initiated by balancers.
 	OpMerge                        // Initiated by merge checkers or merge schedulers.
 	OpRange                        // Initiated by range scheduler.
+	OpLocation                     // Initiated by replica checkers to repair location violations.
+	OpRole                         // Include peer role switching without data movement.
+	OpNamespace                    // Initiated by namespace checkers.
+	OpRepair                       // Initiated by replica checkers to repair down, offline or missing replicas.
 	opMax
 )
 
@@ -46,6 +50,10 @@ var flagToName = map[OpKind]string{
 	OpBalance:   "balance",
 	OpMerge:     "merge",
 	OpRange:     "range",
+	OpLocation:  "location",
+	OpRole:      "role",
+	OpNamespace: "namespace",
+	OpRepair:    "repair",
 }
 
 var nameToFlag = map[string]OpKind{
@@ -58,6 +66,10 @@ var nameToFlag = map[string]OpKind{
 	"balance":    OpBalance,
 	"merge":      OpMerge,
 	"range":      OpRange,
+	"location":   OpLocation,
+	"role":       OpRole,
+	"namespace":  OpNamespace,
+	"repair":     OpRepair,
 }
 
 func (k OpKind) String() string {
@@ -86,3 +98,53 @@ func ParseOperatorKind(str string) (OpKind, error) {
 	return k, nil
 
 }
+
+// SchedulePriority is the priority of an operator by the kind of scheduling
+// which initiates it. With preemption enabled for its kind, an operator may
+// replace the in-flight operator of a lower schedule priority on the same
+// region.
+type SchedulePriority int
+
+// Schedule priorities from low to high. The operators of the other kinds, such
+// as the admin and hot region operators, are never preempted.
+const (
+	OtherSchedule SchedulePriority = iota
+	MergeSchedule
+	BalanceSchedule
+	NamespaceSchedule
+	RepairSchedule
+)
+
+var schedulePriorityToName = map[SchedulePriority]string{
+	OtherSchedule:     "other",
+	MergeSchedule:     "merge",
+	BalanceSchedule:   "balance",
+	NamespaceSchedule: "namespace",
+	RepairSchedule:    "replica",
+}
+
+func (p SchedulePriority) String() string {
+	return schedulePriorityToName[p]
+}
+
+// SchedulePriority returns the schedule priority of the operators of the kind.
+// Replica repair takes precedence over namespace relocation, which takes
+// precedence over balance and then merge. The other operators of the replica
+// checkers, such as moving replicas to better locations, only improve the
+// placement, and are ranked as balance.
+func (k OpKind) SchedulePriority() SchedulePriority {
+	switch {
+	case k&OpAdmin != 0:
+		return OtherSchedule
+	case k&OpNamespace != 0:
+		return NamespaceSchedule
+	case k&OpRepair != 0:
+		return RepairSchedule
+	case k&(OpReplica|OpBalance) != 0:
+		return BalanceSchedule
+	case k&OpMerge != 0:
+		return MergeSchedule
+	default:
+		return OtherSchedule
+	}
+}
@@ -173,6 +173,19 @@ func (s *testOperatorSuite) TestOperator(c *C) {
 	c.Assert(op.IsTimeout(), IsFalse)
 	op.startTime = op.startTime.Add(-LeaderOperatorWaitTime - time.Second)
 	c.Assert(op.IsTimeout(), IsTrue)
+
+	// check long timeout for the operators expected to be slow.
+	steps = []OpStep{AddPeer{ToStore: 1, PeerID: 1}, RemovePeer{FromStore: 2}}
+	op = s.newTestOperator(1, OpRegion, steps...)
+	op.SetExpectedDuration(RegionOperatorWaitTime)
+	op.startTime = time.Now().Add(-RegionOperatorWaitTime - time.Second)
+	c.Assert(op.IsTimeout(), IsFalse)
+	op.startTime = op.startTime.Add(-RegionOperatorWaitTime)
+	c.Assert(op.IsTimeout(), IsTrue)
+	// the expected duration never shortens the timeout.
+	op.SetExpectedDuration(time.Second)
+	op.startTime = time.Now().Add(-time.Minute)
+	c.Assert(op.IsTimeout(), IsFalse)
 }
 
 func (s *testOperatorSuite) TestInfluence(c *C) {
@@ -256,6 +269,49 @@ func (s *testOperatorSuite) TestInfluence(c *C) {
 	})
 }
 
+func (s *testOperatorSuite) TestFlowInfluence(c *C) {
+	region := s.newTestRegion(1, 1, [2]uint64{1, 1}, [2]uint64{2, 2}).Clone(
+		core.SetWrittenBytes(600),
+		core.SetReadBytes(1200),
+		core.SetReportInterval(60),
+	)
+	opInfluence := OpInfluence{StoresInfluence: make(map[uint64]*StoreInfluence)}
+
+	AddLearner{ToStore: 3, PeerID: 3}.Influence(opInfluence, region)
+	TransferLeader{FromStore: 1, ToStore: 2}.Influence(opInfluence, region)
+	RemovePeer{FromStore: 1}.Influence(opInfluence, region)
+	c.Assert(opInfluence.GetStoreInfluence(1).BytesRate(core.LeaderKind), Equals, -20.0)
+	c.Assert(opInfluence.GetStoreInfluence(1).BytesRate(core.RegionKind), Equals, -10.0)
+	c.Assert(opInfluence.GetStoreInfluence(2).BytesRate(core.LeaderKind), Equals, 20.0)
+	c.Assert(opInfluence.GetStoreInfluence(2).BytesRate(core.RegionKind), Equals, 0.0)
+	c.Assert(opInfluence.GetStoreInfluence(3).BytesRate(core.RegionKind), Equals, 10.0)
+	c.Assert(opInfluence.GetStoreInfluence(3).ResourceCount(core.RegionKind), Equals, int64(1))
+	c.Assert(opInfluence.GetStoreInfluence(2).ResourceCount(core.LeaderKind), Equals, int64(1))
+}
+
+func (s *testOperatorSuite) TestProgress(c *C) {
+	region := s.newTestRegion(1, 1, [2]uint64{1, 1}, [2]uint64{2, 2})
+	op := s.newTestOperator(1, OpRegion, AddLearner{ToStore: 3, PeerID: 3}, PromoteLearner{ToStore: 3, PeerID: 3}, RemovePeer{FromStore: 1})
+	c.Assert(op.Progress(nil), Equals, "step 1/3: add learner peer 3 on store 3")
+	c.Assert(op.LearnerStores(), DeepEquals, []uint64{3})
+	c.Assert(op.Progress(region), Equals, "step 1/3: add learner peer 3 on store 3, waiting for the peer to be created")
+
+	learner := &metapb.Peer{Id: 3, StoreId: 3, IsLearner: true}
+	region = region.Clone(core.WithAddPeer(learner), core.WithPendingPeers([]*metapb.Peer{learner}))
+	c.Assert(op.Check(region), Equals, op.Step(0))
+	c.Assert(op.Progress(region), Equals, "step 1/3: add learner peer 3 on store 3, peer is created but not caught up, the snapshot may be being applied")
+
+	region = region.Clone(core.WithPendingPeers(nil))
+	c.Assert(op.Check(region), Equals, op.Step(1))
+	// The added learner is not counted.
+	c.Assert(op.LearnerStores(), HasLen, 0)
+	c.Assert(op.Progress(region), Equals, "step 2/3: promote learner peer 3 on store 3 to voter, waiting for the learner to be promoted")
+
+	region = s.newTestRegion(1, 1, [2]uint64{2, 2}, [2]uint64{3, 3})
+	c.Assert(op.Check(region), IsNil)
+	c.Assert(op.Progress(region), Equals, "finished")
+}
+
 func (s *testOperatorSuite) TestOperatorKind(c *C) {
 	c.Assert((OpLeader | OpReplica).String(), Equals, "leader,replica")
 	c.Assert(OpKind(0).String(), Equals, "unknown")
@@ -267,3 +323,59 @@ func (s *testOperatorSuite) TestOperatorKind(c *C) {
 	_, err = ParseOperatorKind("foobar")
 	c.Assert(err, NotNil)
 }
+
+func (s *testOperatorSuite) TestSchedulePriority(c *C) {
+	c.Assert((OpRegion | OpReplica | OpRepair).SchedulePriority(), Equals, RepairSchedule)
+	c.Assert((OpRegion | OpReplica).SchedulePriority(), Equals, BalanceSchedule)
+	c.Assert((OpRegion | OpReplica | OpLocation).SchedulePriority(), Equals, BalanceSchedule)
+	c.Assert((OpRegion | OpReplica | OpNamespace).SchedulePriority(), Equals, NamespaceSchedule)
+	c.Assert((OpRegion | OpBalance).SchedulePriority(), Equals, BalanceSchedule)
+	c.Assert((OpRegion | OpLeader | OpMerge).SchedulePriority(), Equals, MergeSchedule)
+	c.Assert((OpAdmin | OpMerge).SchedulePriority(), Equals, OtherSchedule)
+	c.Assert((OpHotRegion | OpLeader).SchedulePriority(), Equals, OtherSchedule)
+	c.Assert(RepairSchedule > NamespaceSchedule && NamespaceSchedule > BalanceSchedule && BalanceSchedule > MergeSchedule, IsTrue)
+	c.Assert(NamespaceSchedule.String(), Equals, "namespace")
+}
+
+func (s *testOperatorSuite) TestRoleSwitch(c *C) {
+	region := s.newTestRegion(1, 1, [2]uint64{1, 1}, [2]uint64{2, 2}, [2]uint64{3, 3})
+	region = region.Clone(core.WithDemoteVoter(3))
+	c.Assert(DemoteVoter{ToStore: 3, PeerID: 3}.IsFinish(region), IsTrue)
+	c.Assert(DemoteVoter{ToStore: 2, PeerID: 2}.IsFinish(region), IsFalse)
+	c.Assert(DemoteVoter{ToStore: 2, PeerID: 2}.ConfVerChanged(region), IsFalse)
+
+	// Promoting a learner is counted as a region operator as before.
+	region4 := region.Clone(core.WithAddPeer(&metapb.Peer{Id: 4, StoreId: 4, IsLearner: true}))
+	c.Assert(CreatePromoteLearnerOperator("test", region4, region4.GetStorePeer(4)).Kind(), Equals, OpRegion)
+
+	// Demoting a follower needs no leader transfer.
+	op, err := CreateDemoteVoterOperator("test", s.cluster, region, region.GetStorePeer(2))
+	c.Assert(err, IsNil)
+	c.Assert(op.Kind(), Equals, OpRole)
+	c.Assert(op.Len(), Equals, 1)
+	c.Assert(op.Step(0), Equals, DemoteVoter{ToStore: 2, PeerID: 2})
+
+	// The leadership is transferred before demoting the leader.
+	op, err = CreateDemoteVoterOperator("test", s.cluster, region, region.GetStorePeer(1))
+	c.Assert(err, IsNil)
+	c.Assert(op.Kind(), Equals, OpRole|OpLeader)
+	c.Assert(op.Len(), Equals, 2)
+	c.Assert(op.Step(0), Equals, TransferLeader{FromStore: 1, ToStore: 2})
+	c.Assert(op.Step(1), Equals, DemoteVoter{ToStore: 1, PeerID: 1})
+	c.Assert(op.Progress(region), Equals, "step 1/2: transfer leader from store 1 to store 2, waiting for the leader to be transferred, current leader is on store 1")
+
+	// The learner is promoted before the voter is demoted, and can take over
+	// the leadership.
+	op, err = CreateSwitchRoleOperator("test", s.cluster, region.Clone(core.WithRemoveStorePeer(2)), region.GetStorePeer(3), region.GetStorePeer(1))
+	c.Assert(err, IsNil)
+	c.Assert(op.Kind(), Equals, OpRole|OpLeader)
+	c.Assert(op.Len(), Equals, 3)
+	c.Assert(op.Step(0), Equals, PromoteLearner{ToStore: 3, PeerID: 3})
+	c.Assert(op.Step(1), Equals, TransferLeader{FromStore: 1, ToStore: 3})
+	c.Assert(op.Step(2), Equals, DemoteVoter{ToStore: 1, PeerID: 1})
+
+	// The leader is not demoted if no voter can take over the leadership.
+	region = s.newTestRegion(2, 1, [2]uint64{1, 1}, [2]uint64{7, 7})
+	_, err = CreateDemoteVoterOperator("test", s.cluster, region, region.GetStorePeer(1))
+	c.Assert(err, NotNil)
+}
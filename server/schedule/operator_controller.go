@@ -21,6 +21,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/docker/go-units"
 	"github.com/juju/ratelimit"
 	"github.com/pingcap/failpoint"
 	"github.com/pingcap/kvproto/pkg/eraftpb"
@@ -29,6 +30,7 @@ import (
 	"github.com/pingcap/log"
 	"github.com/pingcap/pd/pkg/cache"
 	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/schedule/filter"
 	"github.com/pingcap/pd/server/schedule/operator"
 	"go.uber.org/zap"
 )
@@ -64,11 +66,22 @@ type OperatorController struct {
 	histories *list.List
 	counts    map[operator.OpKind]uint64
 	opRecords *OperatorRecords
+	// applyRates is used to estimate the durations of the operators adding
+	// peers.
+	applyRates *storeApplyRates
 	// TODO: Need to clean up the unused store ID.
 	storesLimit     map[uint64]*ratelimit.Bucket
 	wop             WaitingOperator
 	wopStatus       *WaitingOperatorStatus
 	opNotifierQueue operatorQueue
+	// snapshotLimit limits the snapshots sent for the operators adding peers
+	// by the snapshot bandwidth budget, which is snapshotBudget when the
+	// limit is created.
+	snapshotMu     sync.Mutex
+	snapshotLimit  *ratelimit.Bucket
+	snapshotBudget uint64
+	// events receives the finished operators if it is not nil.
+	events *EventBus
 }
 
 // NewOperatorController creates a OperatorController.
@@ -80,6 +93,7 @@ func NewOperatorController(cluster Cluster, hbStreams HeartbeatStreams) *Operato
 		histories:       list.New(),
 		counts:          make(map[operator.OpKind]uint64),
 		opRecords:       NewOperatorRecords(),
+		applyRates:      newStoreApplyRates(),
 		storesLimit:     make(map[uint64]*ratelimit.Bucket),
 		wop:             NewRandBuckets(),
 		wopStatus:       NewWaitingOperatorStatus(),
@@ -87,6 +101,13 @@ func NewOperatorController(cluster Cluster, hbStreams HeartbeatStreams) *Operato
 	}
 }
 
+// SetEventBus sets the EventBus that the finished operators are published to.
+func (oc *OperatorController) SetEventBus(events *EventBus) {
+	oc.Lock()
+	defer oc.Unlock()
+	oc.events = events
+}
+
 // Dispatch is used to dispatch the operator of a region.
 func (oc *OperatorController) Dispatch(region *core.RegionInfo, source string) {
 	// Check existed operator.
@@ -114,11 +135,15 @@ func (oc *OperatorController) Dispatch(region *core.RegionInfo, source string) {
 					operatorCounter.WithLabelValues(op.Desc(), "stale").Inc()
 					oc.opRecords.Put(op, pdpb.OperatorStatus_CANCEL)
 					oc.PromoteWaitingOperator()
+					oc.publishOperatorEvent(EventOperatorFail, region, op)
 				}
 
 				return
 			}
 
+			if !oc.admitSnapshot(op, region, step) {
+				return
+			}
 			oc.SendScheduleCommand(region, step, source)
 			return
 		}
@@ -126,22 +151,32 @@ func (oc *OperatorController) Dispatch(region *core.RegionInfo, source string) {
 			log.Info("operator finish", zap.Uint64("region-id", region.GetID()), zap.Duration("takes", op.RunningTime()), zap.Reflect("operator", op))
 			operatorCounter.WithLabelValues(op.Desc(), "finish").Inc()
 			operatorDuration.WithLabelValues(op.Desc()).Observe(op.RunningTime().Seconds())
+			oc.observeApplyRate(op, region)
 			oc.pushHistory(op)
 			oc.opRecords.Put(op, pdpb.OperatorStatus_SUCCESS)
 			oc.PromoteWaitingOperator()
+			oc.publishOperatorEvent(EventOperatorFinish, region, op)
 		} else if timeout && oc.RemoveOperator(op) {
 			log.Info("operator timeout", zap.Uint64("region-id", region.GetID()), zap.Duration("takes", op.RunningTime()), zap.Reflect("operator", op))
 			operatorCounter.WithLabelValues(op.Desc(), "timeout").Inc()
 			oc.opRecords.Put(op, pdpb.OperatorStatus_TIMEOUT)
 			oc.PromoteWaitingOperator()
+			oc.publishOperatorEvent(EventOperatorFail, region, op)
 		}
 	}
 }
 
+func (oc *OperatorController) publishOperatorEvent(typ EventType, region *core.RegionInfo, op *operator.Operator) {
+	oc.RLock()
+	events := oc.events
+	oc.RUnlock()
+	events.Publish(&Event{Type: typ, Region: region, Operator: op})
+}
+
 func (oc *OperatorController) getNextPushOperatorTime(step operator.OpStep, now time.Time) time.Time {
 	nextTime := slowNotifyInterval
 	switch step.(type) {
-	case operator.TransferLeader, operator.PromoteLearner:
+	case operator.TransferLeader, operator.PromoteLearner, operator.DemoteVoter:
 		nextTime = fastNotifyInterval
 	}
 	return now.Add(nextTime)
@@ -240,7 +275,7 @@ func (oc *OperatorController) AddOperator(ops ...*operator.Operator) bool {
 	oc.Lock()
 	defer oc.Unlock()
 
-	if oc.exceedStoreLimit(ops...) || !oc.checkAddOperator(ops...) {
+	if oc.exceedStoreLimit(ops...) || oc.exceedLearnerLimit(ops...) || !oc.checkAddOperator(ops...) {
 		for _, op := range ops {
 			operatorCounter.WithLabelValues(op.Desc(), "cancel").Inc()
 			oc.opRecords.Put(op, pdpb.OperatorStatus_CANCEL)
@@ -253,6 +288,14 @@ func (oc *OperatorController) AddOperator(ops ...*operator.Operator) bool {
 	return true
 }
 
+// ExceedLimit checks if adding the operators exceeds the store limits or the
+// learner limits, in which case they can be added later.
+func (oc *OperatorController) ExceedLimit(ops ...*operator.Operator) bool {
+	oc.Lock()
+	defer oc.Unlock()
+	return oc.exceedStoreLimit(ops...) || oc.exceedLearnerLimit(ops...)
+}
+
 // PromoteWaitingOperator promotes operators from waiting operators.
 func (oc *OperatorController) PromoteWaitingOperator() {
 	oc.Lock()
@@ -265,7 +308,7 @@ func (oc *OperatorController) PromoteWaitingOperator() {
 		}
 		operatorWaitCounter.WithLabelValues(ops[0].Desc(), "get").Inc()
 
-		if oc.exceedStoreLimit(ops...) || !oc.checkAddOperator(ops...) {
+		if oc.exceedStoreLimit(ops...) || oc.exceedLearnerLimit(ops...) || !oc.checkAddOperator(ops...) {
 			for _, op := range ops {
 				operatorWaitCounter.WithLabelValues(op.Desc(), "promote_canceled").Inc()
 				oc.opRecords.Put(op, pdpb.OperatorStatus_CANCEL)
@@ -298,18 +341,271 @@ func (oc *OperatorController) checkAddOperator(ops ...*operator.Operator) bool {
 			log.Debug("region epoch not match, cancel add operator", zap.Uint64("region-id", op.RegionID()), zap.Reflect("old", region.GetRegionEpoch()), zap.Reflect("new", op.RegionEpoch()))
 			return false
 		}
-		if old := oc.operators[op.RegionID()]; old != nil && !isHigherPriorityOperator(op, old) {
+		if old := oc.operators[op.RegionID()]; old != nil && !isHigherPriorityOperator(op, old) && !oc.canPreemptOperator(op, old) {
 			log.Debug("already have operator, cancel add operator", zap.Uint64("region-id", op.RegionID()), zap.Reflect("old", old))
 			return false
 		}
+		if storeID, ok := oc.outOfDecommissionWindow(op, region); ok {
+			log.Debug("out of decommission window, cancel add operator", zap.Uint64("region-id", op.RegionID()), zap.Uint64("store-id", storeID))
+			operatorCounter.WithLabelValues(op.Desc(), "out-of-window").Inc()
+			return false
+		}
+		if oc.violatesRegionPin(op) {
+			log.Debug("region is pinned, cancel add operator", zap.Uint64("region-id", op.RegionID()))
+			operatorCounter.WithLabelValues(op.Desc(), "region-pinned").Inc()
+			return false
+		}
+		if oc.violatesPlacementIntent(op) {
+			log.Debug("region has placement intent, cancel add operator", zap.Uint64("region-id", op.RegionID()))
+			operatorCounter.WithLabelValues(op.Desc(), "placement-intent").Inc()
+			return false
+		}
+		if op.Kind()&operator.OpMerge != 0 && op.Kind()&operator.OpAdmin == 0 &&
+			oc.cluster.IsMergePaused(region.GetStartKey(), region.GetEndKey()) {
+			log.Debug("merge is paused, cancel add operator", zap.Uint64("region-id", op.RegionID()))
+			operatorCounter.WithLabelValues(op.Desc(), "merge-paused").Inc()
+			return false
+		}
+		if storeID, capability, ok := oc.lackCapability(op, region); ok {
+			log.Debug("store does not support the operator, cancel add operator",
+				zap.Uint64("region-id", op.RegionID()),
+				zap.Uint64("store-id", storeID),
+				zap.String("capability", capability))
+			operatorCounter.WithLabelValues(op.Desc(), "unsupported-step").Inc()
+			return false
+		}
 	}
 	return true
 }
 
+// lackCapability returns a store of the region not advertising a capability
+// required by the operator, if any. All stores of the region are checked,
+// since any of them may become the leader executing the steps.
+func (oc *OperatorController) lackCapability(op *operator.Operator, region *core.RegionInfo) (uint64, string, bool) {
+	capabilities := op.RequiredCapabilities()
+	if len(capabilities) == 0 {
+		return 0, "", false
+	}
+	for storeID := range region.GetStoreIds() {
+		store := oc.cluster.GetStore(storeID)
+		if store == nil {
+			continue
+		}
+		for _, c := range capabilities {
+			if !store.HasCapability(c) {
+				return storeID, c, true
+			}
+		}
+	}
+	return 0, "", false
+}
+
+// outOfDecommissionWindow returns the store the operator evacuates out of its
+// decommission window, if any. The operators added by users are not limited,
+// and neither are the replica checker operators repairing the down peers,
+// which are lost whether the store is in its window or not.
+func (oc *OperatorController) outOfDecommissionWindow(op *operator.Operator, region *core.RegionInfo) (uint64, bool) {
+	if op.Kind()&operator.OpAdmin != 0 {
+		return 0, false
+	}
+	for i := 0; i < op.Len(); i++ {
+		step, ok := op.Step(i).(operator.RemovePeer)
+		if !ok {
+			continue
+		}
+		store := oc.cluster.GetStore(step.FromStore)
+		if store == nil || !store.IsOffline() || oc.cluster.IsEvacuationAllowed(step.FromStore) {
+			continue
+		}
+		if op.Kind()&operator.OpReplica != 0 && oc.isDownPeer(region, store) {
+			continue
+		}
+		return step.FromStore, true
+	}
+	return 0, false
+}
+
+// isDownPeer checks if the peer of the region on the store is down, or the
+// store itself is down.
+func (oc *OperatorController) isDownPeer(region *core.RegionInfo, store *core.StoreInfo) bool {
+	if store.DownTime() >= oc.cluster.GetMaxStoreDownTime() {
+		return true
+	}
+	peer := region.GetStorePeer(store.GetID())
+	return peer != nil && region.GetDownPeer(peer.GetId()) != nil
+}
+
+// violatesRegionPin checks if the operator moves the region out of the stores
+// it is pinned to. The operators added by users are not limited.
+func (oc *OperatorController) violatesRegionPin(op *operator.Operator) bool {
+	if op.Kind()&operator.OpAdmin != 0 {
+		return false
+	}
+	pin := oc.cluster.GetRegionPin(op.RegionID())
+	if pin == nil {
+		return false
+	}
+	peerFilter := filter.NewRegionPinFilter("operator-controller", pin, false)
+	leaderFilter := filter.NewRegionPinFilter("operator-controller", pin, true)
+	filteredSource := func(f filter.Filter, storeID uint64) bool {
+		store := oc.cluster.GetStore(storeID)
+		return store != nil && filter.Source(oc.cluster, store, []filter.Filter{f})
+	}
+	filteredTarget := func(f filter.Filter, storeID uint64) bool {
+		store := oc.cluster.GetStore(storeID)
+		return store != nil && filter.Target(oc.cluster, store, []filter.Filter{f})
+	}
+	for i := 0; i < op.Len(); i++ {
+		switch step := op.Step(i).(type) {
+		case operator.TransferLeader:
+			if filteredSource(leaderFilter, step.FromStore) || filteredTarget(leaderFilter, step.ToStore) {
+				return true
+			}
+		case operator.AddPeer:
+			if filteredTarget(peerFilter, step.ToStore) {
+				return true
+			}
+		case operator.AddLearner:
+			if filteredTarget(peerFilter, step.ToStore) {
+				return true
+			}
+		case operator.AddLightPeer:
+			if filteredTarget(peerFilter, step.ToStore) {
+				return true
+			}
+		case operator.AddLightLearner:
+			if filteredTarget(peerFilter, step.ToStore) {
+				return true
+			}
+		case operator.RemovePeer:
+			if filteredSource(peerFilter, step.FromStore) {
+				return true
+			}
+		case operator.MergeRegion:
+			return true
+		}
+	}
+	return false
+}
+
+// violatesPlacementIntent checks if the operator moves the region away from
+// its placement intent, which is to add peers out of the declared stores,
+// remove peers from them, or transfer the leader out of the declared leader
+// store. The operators added by users and the ones repairing the replicas are
+// not limited, and neither are the operators of the intent checker since they
+// only move the region towards the intent.
+func (oc *OperatorController) violatesPlacementIntent(op *operator.Operator) bool {
+	if op.Kind()&(operator.OpAdmin|operator.OpReplica) != 0 {
+		return false
+	}
+	intent := oc.cluster.GetPlacementIntent(op.RegionID())
+	if intent == nil {
+		return false
+	}
+	outOfStores := func(storeID uint64) bool {
+		return len(intent.StoreIDs) > 0 && !intent.IsPeerPlaced(storeID)
+	}
+	for i := 0; i < op.Len(); i++ {
+		switch step := op.Step(i).(type) {
+		case operator.TransferLeader:
+			if intent.LeaderStoreID != 0 && step.FromStore == intent.LeaderStoreID {
+				return true
+			}
+		case operator.AddPeer:
+			if outOfStores(step.ToStore) {
+				return true
+			}
+		case operator.AddLearner:
+			if outOfStores(step.ToStore) {
+				return true
+			}
+		case operator.AddLightPeer:
+			if outOfStores(step.ToStore) {
+				return true
+			}
+		case operator.AddLightLearner:
+			if outOfStores(step.ToStore) {
+				return true
+			}
+		case operator.RemovePeer:
+			if len(intent.StoreIDs) > 0 && intent.IsPeerPlaced(step.FromStore) {
+				return true
+			}
+		case operator.MergeRegion:
+			return true
+		}
+	}
+	return false
+}
+
 func isHigherPriorityOperator(new, old *operator.Operator) bool {
 	return new.GetPriorityLevel() > old.GetPriorityLevel()
 }
 
+// canPreemptOperator returns if the new operator can preempt the old one on
+// the same region by a higher schedule priority. It never preempts an operator
+// of a higher priority level, or one of the kinds without a schedule priority,
+// or a merge operator whose merge request has been sent.
+func (oc *OperatorController) canPreemptOperator(new, old *operator.Operator) bool {
+	priority, oldPriority := new.Kind().SchedulePriority(), old.Kind().SchedulePriority()
+	if oldPriority == operator.OtherSchedule || priority <= oldPriority || new.GetPriorityLevel() < old.GetPriorityLevel() {
+		return false
+	}
+	if old.IsMerging() {
+		return false
+	}
+	if partner := oc.mergePartner(old); partner != nil && partner.IsMerging() {
+		return false
+	}
+	return oc.cluster.IsOperatorPreemptionEnabled(priority.String())
+}
+
+// mergePartner returns the running operator merging the other region of the
+// merge operator, or nil if there is none.
+func (oc *OperatorController) mergePartner(op *operator.Operator) *operator.Operator {
+	merge, ok := lastMergeStep(op)
+	if !ok {
+		return nil
+	}
+	partnerID := merge.ToRegion.GetId()
+	if merge.IsPassive {
+		partnerID = merge.FromRegion.GetId()
+	}
+	partner := oc.operators[partnerID]
+	if partner == nil {
+		return nil
+	}
+	if m, ok := lastMergeStep(partner); !ok || m.IsPassive == merge.IsPassive ||
+		m.FromRegion.GetId() != merge.FromRegion.GetId() || m.ToRegion.GetId() != merge.ToRegion.GetId() {
+		return nil
+	}
+	return partner
+}
+
+func lastMergeStep(op *operator.Operator) (operator.MergeRegion, bool) {
+	if op.Kind()&operator.OpMerge == 0 || op.Len() == 0 {
+		return operator.MergeRegion{}, false
+	}
+	merge, ok := op.Step(op.Len() - 1).(operator.MergeRegion)
+	return merge, ok
+}
+
+// IsPreemptible returns if the operator can be preempted by the operators of
+// the higher schedule priorities with the preemption enabled, so that the
+// checkers should still check its region.
+func (oc *OperatorController) IsPreemptible(op *operator.Operator) bool {
+	priority := op.Kind().SchedulePriority()
+	if priority == operator.OtherSchedule {
+		return false
+	}
+	for p := priority + 1; p <= operator.RepairSchedule; p++ {
+		if oc.cluster.IsOperatorPreemptionEnabled(p.String()) {
+			return true
+		}
+	}
+	return false
+}
+
 func (oc *OperatorController) addOperatorLocked(op *operator.Operator) bool {
 	regionID := op.RegionID()
 
@@ -318,30 +614,45 @@ func (oc *OperatorController) addOperatorLocked(op *operator.Operator) bool {
 	// If there is an old operator, replace it. The priority should be checked
 	// already.
 	if old, ok := oc.operators[regionID]; ok {
+		partner := oc.mergePartner(old)
 		_ = oc.removeOperatorLocked(old)
 		log.Info("replace old operator", zap.Uint64("region-id", regionID), zap.Duration("takes", old.RunningTime()), zap.Reflect("operator", old))
 		operatorCounter.WithLabelValues(old.Desc(), "replace").Inc()
+		if !isHigherPriorityOperator(op, old) {
+			operatorCounter.WithLabelValues(old.Desc(), "preempted").Inc()
+		}
 		oc.opRecords.Put(old, pdpb.OperatorStatus_REPLACE)
+		// The merge operators come in pairs, and the other one of the pair
+		// cannot finish alone.
+		if partner != nil && oc.removeOperatorLocked(partner) {
+			log.Info("cancel merge partner of replaced operator", zap.Uint64("region-id", partner.RegionID()), zap.Reflect("operator", partner))
+			operatorCounter.WithLabelValues(partner.Desc(), "cancel").Inc()
+			oc.opRecords.PutWithReason(partner, pdpb.OperatorStatus_CANCEL, "merge partner replaced")
+		}
 	}
 
 	oc.operators[regionID] = op
+	if region := oc.cluster.GetRegion(regionID); region != nil {
+		op.SetExpectedDuration(oc.estimateDuration(op, region))
+	}
 	op.SetStartTime(time.Now())
 	operatorCounter.WithLabelValues(op.Desc(), "start").Inc()
 	operatorWaitDuration.WithLabelValues(op.Desc()).Observe(op.ElapsedTime().Seconds())
 	opInfluence := NewTotalOpInfluence([]*operator.Operator{op}, oc.cluster)
+	scale := oc.cluster.GetScheduleSpeedScale()
 	for storeID := range opInfluence.StoresInfluence {
 		stepCost := opInfluence.GetStoreInfluence(storeID).StepCost
 		if stepCost == 0 {
 			continue
 		}
 		storeLimitGauge.WithLabelValues(strconv.FormatUint(storeID, 10), "take").Set(float64(stepCost) / float64(operator.RegionInfluence))
-		oc.storesLimit[storeID].Take(stepCost)
+		oc.storesLimit[storeID].Take(scaleStepCost(stepCost, scale))
 	}
 	oc.updateCounts(oc.operators)
 
 	var step operator.OpStep
 	if region := oc.cluster.GetRegion(op.RegionID()); region != nil {
-		if step = op.Check(region); step != nil {
+		if step = op.Check(region); step != nil && oc.admitSnapshot(op, region, step) {
 			oc.SendScheduleCommand(region, step, DispatchFromCreate)
 		}
 	}
@@ -358,14 +669,67 @@ func (oc *OperatorController) RemoveOperator(op *operator.Operator) (found bool)
 	return oc.removeOperatorLocked(op)
 }
 
+// CancelOperator removes the operator and records the reason. If the current
+// step of the operator adds a peer which is still pending, the peer is removed
+// to abort the snapshot sending to it.
+func (oc *OperatorController) CancelOperator(op *operator.Operator, reason string) bool {
+	if !oc.RemoveOperator(op) {
+		return false
+	}
+	log.Info("operator canceled", zap.Uint64("region-id", op.RegionID()), zap.Duration("takes", op.RunningTime()),
+		zap.Reflect("operator", op), zap.String("reason", reason))
+	operatorCounter.WithLabelValues(op.Desc(), "cancel").Inc()
+	oc.opRecords.PutWithReason(op, pdpb.OperatorStatus_CANCEL, reason)
+	oc.abortPendingPeer(op)
+	oc.PromoteWaitingOperator()
+	return true
+}
+
+// abortPendingPeer removes the pending peer added by the current step of the
+// canceled operator, so that the leader stops sending the snapshot to it.
+func (oc *OperatorController) abortPendingPeer(op *operator.Operator) {
+	region := oc.cluster.GetRegion(op.RegionID())
+	if region == nil {
+		return
+	}
+	var peerID uint64
+	switch st := op.Check(region).(type) {
+	case operator.AddPeer:
+		peerID = st.PeerID
+	case operator.AddLightPeer:
+		peerID = st.PeerID
+	case operator.AddLearner:
+		peerID = st.PeerID
+	case operator.AddLightLearner:
+		peerID = st.PeerID
+	default:
+		return
+	}
+	peer := region.GetPendingPeer(peerID)
+	if peer == nil {
+		return
+	}
+	log.Info("abort snapshot of canceled operator", zap.Uint64("region-id", region.GetID()), zap.Stringer("peer", peer))
+	operatorCounter.WithLabelValues(op.Desc(), "abort-snapshot").Inc()
+	cmd := &pdpb.RegionHeartbeatResponse{
+		ChangePeer: &pdpb.ChangePeer{
+			ChangeType: eraftpb.ConfChangeType_RemoveNode,
+			Peer:       peer,
+		},
+	}
+	oc.hbStreams.SendMsg(region, cmd)
+}
+
 // GetOperatorStatus gets the operator and its status with the specify id.
 func (oc *OperatorController) GetOperatorStatus(id uint64) *OperatorWithStatus {
 	oc.Lock()
 	defer oc.Unlock()
 	if op, ok := oc.operators[id]; ok {
 		return &OperatorWithStatus{
-			Op:     op,
-			Status: pdpb.OperatorStatus_RUNNING,
+			Op:                op,
+			Status:            pdpb.OperatorStatus_RUNNING,
+			Progress:          op.Progress(oc.cluster.GetRegion(id)),
+			SnapshotBandwidth: oc.getSnapshotBandwidthLocked(op),
 		}
 	}
 	return oc.opRecords.Get(id)
@@ -494,6 +858,19 @@ func (oc *OperatorController) SendScheduleCommand(region *core.RegionInfo, step
 			},
 		}
 		oc.hbStreams.SendMsg(region, cmd)
+	case operator.DemoteVoter:
+		cmd := &pdpb.RegionHeartbeatResponse{
+			ChangePeer: &pdpb.ChangePeer{
+				// reuse AddLearnerNode type
+				ChangeType: eraftpb.ConfChangeType_AddLearnerNode,
+				Peer: &metapb.Peer{
+					Id:        st.PeerID,
+					StoreId:   st.ToStore,
+					IsLearner: true,
+				},
+			},
+		}
+		oc.hbStreams.SendMsg(region, cmd)
 	case operator.RemovePeer:
 		cmd := &pdpb.RegionHeartbeatResponse{
 			ChangePeer: &pdpb.ChangePeer{
@@ -583,6 +960,34 @@ func (oc *OperatorController) OperatorCount(mask operator.OpKind) uint64 {
 	return total
 }
 
+// OperatorCountByNamespace gets the count of operators scheduled in the
+// namespace filtered by mask.
+func (oc *OperatorController) OperatorCountByNamespace(namespace string, mask operator.OpKind) uint64 {
+	oc.RLock()
+	defer oc.RUnlock()
+	var total uint64
+	for _, op := range oc.operators {
+		if op.Namespace() == namespace && op.Kind()&mask != 0 {
+			total++
+		}
+	}
+	return total
+}
+
+// OperatorCountByLocation gets the count of running operators repairing the
+// location violations of the label.
+func (oc *OperatorController) OperatorCountByLocation(label string) uint64 {
+	oc.RLock()
+	defer oc.RUnlock()
+	var total uint64
+	for _, op := range oc.operators {
+		if op.Kind()&operator.OpLocation != 0 && op.LocationLabel() == label {
+			total++
+		}
+	}
+	return total
+}
+
 // GetOpInfluence gets OpInfluence.
 func (oc *OperatorController) GetOpInfluence(cluster Cluster) operator.OpInfluence {
 	oc.RLock()
@@ -645,11 +1050,34 @@ func (oc *OperatorController) SetOperator(op *operator.Operator) {
 type OperatorWithStatus struct {
 	Op     *operator.Operator
 	Status pdpb.OperatorStatus
+	// Progress describes the current step of a running operator.
+	Progress string
+	// SnapshotBandwidth is the snapshot bandwidth per second allocated to a
+	// running operator adding a peer. 0 means no limit.
+	SnapshotBandwidth uint64
+	// Reason is the reason to cancel the operator.
+	Reason string
+	// Expected and Actual are the estimated and the actual durations of a
+	// finished operator, if it is estimated.
+	Expected, Actual time.Duration
 }
 
 // MarshalJSON returns the status of operator as a JSON string
 func (o *OperatorWithStatus) MarshalJSON() ([]byte, error) {
-	return []byte(`"` + fmt.Sprintf("status: %s, operator: %s", o.Status.String(), o.Op.String()) + `"`), nil
+	s := fmt.Sprintf("status: %s", o.Status.String())
+	if o.Progress != "" {
+		s += fmt.Sprintf(", progress: %s", o.Progress)
+	}
+	if o.SnapshotBandwidth > 0 {
+		s += fmt.Sprintf(", snapshot bandwidth: %s/s", units.BytesSize(float64(o.SnapshotBandwidth)))
+	}
+	if o.Reason != "" {
+		s += fmt.Sprintf(", reason: %s", o.Reason)
+	}
+	if o.Expected > 0 {
+		s += fmt.Sprintf(", expected: %s, actual: %s", o.Expected, o.Actual)
+	}
+	return []byte(`"` + fmt.Sprintf("%s, operator: %s", s, o.Op.String()) + `"`), nil
 }
 
 // OperatorRecords remains the operator and its status for a while.
@@ -677,14 +1105,150 @@ func (o *OperatorRecords) Get(id uint64) *OperatorWithStatus {
 
 // Put puts the operator and its status.
 func (o *OperatorRecords) Put(op *operator.Operator, status pdpb.OperatorStatus) {
+	o.PutWithReason(op, status, "")
+}
+
+// PutWithReason puts the operator and its status with the reason of the
+// status.
+func (o *OperatorRecords) PutWithReason(op *operator.Operator, status pdpb.OperatorStatus, reason string) {
 	id := op.RegionID()
 	record := &OperatorWithStatus{
 		Op:     op,
 		Status: status,
+		Reason: reason,
+	}
+	if !op.GetStartTime().IsZero() {
+		record.Expected, record.Actual = op.GetExpectedDuration(), op.RunningTime()
 	}
 	o.ttl.Put(id, record)
 }
 
+// GetSnapshotBandwidth returns the snapshot bandwidth per second allocated to
+// the operator of the region. The snapshot bandwidth budget is shared evenly
+// by the running operators adding peers. 0 means no limit.
+func (oc *OperatorController) GetSnapshotBandwidth(regionID uint64) uint64 {
+	oc.RLock()
+	defer oc.RUnlock()
+	op, ok := oc.operators[regionID]
+	if !ok {
+		return 0
+	}
+	return oc.getSnapshotBandwidthLocked(op)
+}
+
+func (oc *OperatorController) getSnapshotBandwidthLocked(op *operator.Operator) uint64 {
+	budget := oc.cluster.GetSnapshotBandwidthBudget()
+	if budget == 0 || !op.IsSendingSnapshot() {
+		return 0
+	}
+	return budget / oc.countSendingSnapshotLocked()
+}
+
+func (oc *OperatorController) countSendingSnapshotLocked() uint64 {
+	var sending uint64
+	for _, op := range oc.operators {
+		if op.IsSendingSnapshot() {
+			sending++
+		}
+	}
+	return sending
+}
+
+// CollectSnapshotBandwidthMetrics collects the metrics of the snapshot
+// bandwidth budget and the share of each running operator adding a peer.
+func (oc *OperatorController) CollectSnapshotBandwidthMetrics() {
+	budget := oc.cluster.GetSnapshotBandwidthBudget()
+	oc.RLock()
+	sending := oc.countSendingSnapshotLocked()
+	oc.RUnlock()
+	var bandwidth uint64
+	if budget > 0 && sending > 0 {
+		bandwidth = budget / sending
+	}
+	snapshotBandwidthGauge.WithLabelValues("budget").Set(float64(budget))
+	snapshotBandwidthGauge.WithLabelValues("sending-operators").Set(float64(sending))
+	snapshotBandwidthGauge.WithLabelValues("operator-bandwidth").Set(float64(bandwidth))
+}
+
+// GetStoreSnapshotBandwidth returns the snapshot bandwidth per second
+// allocated to the running operators adding peers whose snapshots are sent by
+// the store, which leads their regions. A store sending none of them gets the
+// share of one, since raft still sends snapshots to the lagging peers. 0 means
+// no limit.
+func (oc *OperatorController) GetStoreSnapshotBandwidth(storeID uint64) uint64 {
+	budget := oc.cluster.GetSnapshotBandwidthBudget()
+	if budget == 0 {
+		return 0
+	}
+	oc.RLock()
+	defer oc.RUnlock()
+	var sending, sendingFromStore uint64
+	for _, op := range oc.operators {
+		if !op.IsSendingSnapshot() {
+			continue
+		}
+		sending++
+		if region := oc.cluster.GetRegion(op.RegionID()); region != nil && region.GetLeader().GetStoreId() == storeID {
+			sendingFromStore++
+		}
+	}
+	if sending == 0 {
+		return budget
+	}
+	if sendingFromStore == 0 {
+		sendingFromStore = 1
+	}
+	return budget / sending * sendingFromStore
+}
+
+// admitSnapshot checks if the step of the operator can be dispatched under
+// the snapshot bandwidth budget. The snapshot of a step adding a peer is
+// assumed to be as large as the region, and takes the budget once when the
+// step is dispatched for the first time. The step is not dispatched until the
+// budget is available, and is retried when the operator is pushed again. A
+// region larger than the budget takes the whole budget.
+func (oc *OperatorController) admitSnapshot(op *operator.Operator, region *core.RegionInfo, step operator.OpStep) bool {
+	budget := oc.cluster.GetSnapshotBandwidthBudget()
+	if budget == 0 || !op.IsSendingSnapshot() || op.IsSnapshotAdmitted() {
+		return true
+	}
+	var toStore uint64
+	switch st := step.(type) {
+	case operator.AddPeer:
+		toStore = st.ToStore
+	case operator.AddLightPeer:
+		toStore = st.ToStore
+	case operator.AddLearner:
+		toStore = st.ToStore
+	case operator.AddLightLearner:
+		toStore = st.ToStore
+	default:
+		return true
+	}
+	if region.GetStorePeer(toStore) != nil {
+		// The peer is created and receiving the snapshot.
+		return true
+	}
+	size := uint64(region.GetApproximateSize()) << 20
+	if size > budget {
+		size = budget
+	}
+
+	oc.snapshotMu.Lock()
+	defer oc.snapshotMu.Unlock()
+	if oc.snapshotLimit == nil || oc.snapshotBudget != budget {
+		oc.snapshotLimit = ratelimit.NewBucketWithRate(float64(budget), int64(budget))
+		oc.snapshotBudget = budget
+	}
+	if oc.snapshotLimit.Available() < int64(size) {
+		operatorCounter.WithLabelValues(op.Desc(), "snapshot-throttled").Inc()
+		return false
+	}
+	oc.snapshotLimit.Take(int64(size))
+	op.AdmitSnapshot()
+	return true
+}
+
 // exceedStoreLimit returns true if the store exceeds the cost limit after adding the operator. Otherwise, returns false.
 func (oc *OperatorController) exceedStoreLimit(ops ...*operator.Operator) bool {
 	opInfluence := NewTotalOpInfluence(ops, oc.cluster)
@@ -703,6 +1267,50 @@ func (oc *OperatorController) exceedStoreLimit(ops ...*operator.Operator) bool {
 	return false
 }
 
+// exceedLearnerLimit returns true if the number of learners being added to a
+// store or to the cluster exceeds the limit after adding the operators.
+// Operators which will be replaced by the new ones are not counted.
+func (oc *OperatorController) exceedLearnerLimit(ops ...*operator.Operator) bool {
+	storeLimit, clusterLimit := oc.cluster.GetMaxStoreLearnerCount(), oc.cluster.GetMaxLearnerCount()
+	if storeLimit == 0 && clusterLimit == 0 {
+		return false
+	}
+	var newStores []uint64
+	replaced := make(map[uint64]struct{}, len(ops))
+	for _, op := range ops {
+		newStores = append(newStores, op.LearnerStores()...)
+		replaced[op.RegionID()] = struct{}{}
+	}
+	if len(newStores) == 0 {
+		return false
+	}
+
+	var total uint64
+	storeCounts := make(map[uint64]uint64)
+	for regionID, op := range oc.operators {
+		if _, ok := replaced[regionID]; ok {
+			continue
+		}
+		for _, storeID := range op.LearnerStores() {
+			storeCounts[storeID]++
+			total++
+		}
+	}
+	for _, storeID := range newStores {
+		storeCounts[storeID]++
+		total++
+		if storeLimit > 0 && storeCounts[storeID] > storeLimit {
+			log.Debug("exceed store learner limit", zap.Uint64("store-id", storeID), zap.Uint64("limit", storeLimit))
+			return true
+		}
+	}
+	if clusterLimit > 0 && total > clusterLimit {
+		log.Debug("exceed cluster learner limit", zap.Uint64("limit", clusterLimit))
+		return true
+	}
+	return false
+}
+
 // SetAllStoresLimit is used to set limit of all stores.
 func (oc *OperatorController) SetAllStoresLimit(rate float64) {
 	oc.Lock()
@@ -730,6 +1338,16 @@ func (oc *OperatorController) newStoreLimit(storeID uint64, rate float64) {
 	oc.storesLimit[storeID] = ratelimit.NewBucketWithRate(rate, capacity)
 }
 
+// scaleStepCost divides the step cost by the schedule speed scale, so that
+// the store limits are slowed down along with the schedule limits while the
+// rates of the stores, including the ones set by users, are kept.
+func scaleStepCost(stepCost int64, scale float64) int64 {
+	if scale <= 0 || scale >= 1 {
+		return stepCost
+	}
+	return int64(float64(stepCost) / scale)
+}
+
 // getOrCreateStoreLimit is used to get or create the limit of a store.
 func (oc *OperatorController) getOrCreateStoreLimit(storeID uint64) *ratelimit.Bucket {
 	if oc.storesLimit[storeID] == nil {
@@ -744,6 +1362,17 @@ func (oc *OperatorController) getOrCreateStoreLimit(storeID uint64) *ratelimit.B
 	return oc.storesLimit[storeID]
 }
 
+// GetStoreLimit returns the limit of a store, which is the default rate if
+// it has not been set or created.
+func (oc *OperatorController) GetStoreLimit(storeID uint64) float64 {
+	oc.RLock()
+	defer oc.RUnlock()
+	if limit, ok := oc.storesLimit[storeID]; ok {
+		return limit.Rate() / float64(operator.RegionInfluence)
+	}
+	return oc.cluster.GetStoreBalanceRate() / StoreBalanceBaseTime
+}
+
 // GetAllStoresLimit is used to get limit of all stores.
 func (oc *OperatorController) GetAllStoresLimit() map[uint64]float64 {
 	oc.RLock()
@@ -21,6 +21,7 @@ import (
 
 	. "github.com/pingcap/check"
 	"github.com/pingcap/failpoint"
+	"github.com/pingcap/kvproto/pkg/eraftpb"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/pingcap/pd/pkg/mock/mockcluster"
@@ -244,6 +245,56 @@ func (t *testOperatorControllerSuite) TestStorelimit(c *C) {
 	op = operator.NewOperator("test", "test", 1, &metapb.RegionEpoch{}, operator.OpRegion, operator.AddPeer{ToStore: 2, PeerID: 1})
 	c.Assert(oc.AddOperator(op), IsFalse)
 	c.Assert(oc.RemoveOperator(op), IsFalse)
+
+	// The store limits are slowed down by the schedule speed scale.
+	opt.ScheduleSpeedScale = 0.5
+	oc.SetStoreLimit(2, 1)
+	for i := uint64(1); i <= 3; i++ {
+		op = operator.NewOperator("test", "test", i, &metapb.RegionEpoch{}, operator.OpRegion, operator.AddPeer{ToStore: 2, PeerID: i})
+		c.Assert(oc.AddOperator(op), IsTrue)
+		c.Assert(oc.RemoveOperator(op), IsTrue)
+	}
+	op = operator.NewOperator("test", "test", 1, &metapb.RegionEpoch{}, operator.OpRegion, operator.AddPeer{ToStore: 2, PeerID: 1})
+	c.Assert(oc.AddOperator(op), IsFalse)
+	c.Assert(oc.GetStoreLimit(2), Equals, 1.0)
+}
+
+func (t *testOperatorControllerSuite) TestLearnerLimit(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	opt.MaxStoreLearnerCount = 2
+	opt.MaxLearnerCount = 3
+	tc := mockcluster.NewCluster(opt)
+	oc := NewOperatorController(tc, mockhbstream.NewHeartbeatStream())
+	for i := uint64(1); i <= 3; i++ {
+		tc.AddLeaderStore(i, 0)
+	}
+	for i := uint64(1); i <= 10; i++ {
+		tc.AddLeaderRegion(i, 1)
+	}
+	newOp := func(regionID, storeID uint64) *operator.Operator {
+		return operator.NewOperator("test", "test", regionID, &metapb.RegionEpoch{}, operator.OpRegion,
+			operator.AddLearner{ToStore: storeID, PeerID: regionID}, operator.PromoteLearner{ToStore: storeID, PeerID: regionID})
+	}
+
+	c.Assert(oc.AddOperator(newOp(1, 2)), IsTrue)
+	c.Assert(oc.AddOperator(newOp(2, 2)), IsTrue)
+	// Exceeds the store limit.
+	c.Assert(oc.AddOperator(newOp(3, 2)), IsFalse)
+	c.Assert(oc.AddOperator(newOp(3, 3)), IsTrue)
+	// Exceeds the cluster limit.
+	c.Assert(oc.AddOperator(newOp(4, 3)), IsFalse)
+	// Operators without learners are not limited.
+	c.Assert(oc.AddOperator(operator.NewOperator("test", "test", 4, &metapb.RegionEpoch{}, operator.OpRegion, operator.RemovePeer{FromStore: 1})), IsTrue)
+
+	// Replacing an operator does not count the old one.
+	op := newOp(3, 3)
+	op.SetPriorityLevel(core.HighPriority)
+	c.Assert(oc.AddOperator(op), IsTrue)
+
+	c.Assert(oc.RemoveOperator(oc.GetOperator(1)), IsTrue)
+	c.Assert(oc.AddOperator(newOp(5, 3)), IsTrue)
+	opt.MaxStoreLearnerCount, opt.MaxLearnerCount = 0, 0
+	c.Assert(oc.AddOperator(newOp(6, 3)), IsTrue)
 }
 
 // #1652
@@ -394,3 +445,370 @@ func (t *testOperatorControllerSuite) TestDispatchUnfinishedStep(c *C) {
 	c.Assert(len(stream.MsgCh()), Equals, 4)
 	c.Assert(controller.GetOperator(1), IsNil)
 }
+
+func (t *testOperatorControllerSuite) TestDispatchDemoteVoter(c *C) {
+	cluster := mockcluster.NewCluster(mockoption.NewScheduleOptions())
+	stream := mockhbstream.NewHeartbeatStreams(cluster.ID)
+	controller := NewOperatorController(cluster, stream)
+
+	epoch := &metapb.RegionEpoch{ConfVer: 0, Version: 0}
+	region := cluster.MockRegionInfo(1, 1, []uint64{2, 3}, epoch)
+	cluster.PutRegion(region)
+	op := operator.NewOperator("test", "test", 1, epoch, operator.OpRole, operator.DemoteVoter{ToStore: 2, PeerID: 2})
+	c.Assert(controller.AddOperator(op), IsTrue)
+	msg := <-stream.MsgCh()
+	c.Assert(msg.GetChangePeer().GetChangeType(), Equals, eraftpb.ConfChangeType_AddLearnerNode)
+	c.Assert(msg.GetChangePeer().GetPeer(), DeepEquals, &metapb.Peer{Id: 2, StoreId: 2, IsLearner: true})
+
+	// The peer is demoted in place.
+	region = region.Clone(core.WithDemoteVoter(2), core.WithIncConfVer())
+	controller.Dispatch(region, DispatchFromHeartBeat)
+	c.Assert(controller.GetOperator(1), IsNil)
+	c.Assert(op.IsFinish(), IsTrue)
+}
+
+func (t *testOperatorControllerSuite) TestStoreCapabilities(c *C) {
+	cluster := mockcluster.NewCluster(mockoption.NewScheduleOptions())
+	oc := NewOperatorController(cluster, mockhbstream.NewHeartbeatStreams(cluster.ID))
+	cluster.AddLeaderStore(1, 0)
+	cluster.AddLeaderStore(2, 0)
+	cluster.AddLeaderStore(3, 0)
+	cluster.AddLeaderRegion(1, 1, 2, 3)
+	region := cluster.GetRegion(1)
+	newOp := func() *operator.Operator {
+		return operator.NewOperator("test", "test", 1, region.GetRegionEpoch(), operator.OpRole,
+			operator.DemoteVoter{ToStore: 2, PeerID: region.GetStorePeer(2).GetId()})
+	}
+	c.Assert(newOp().RequiredCapabilities(), DeepEquals, []string{operator.CapabilityDemoteVoter})
+
+	// The steps of the base protocol are issued to any store.
+	c.Assert(oc.AddOperator(operator.NewOperator("test", "test", 1, region.GetRegionEpoch(), operator.OpLeader,
+		operator.TransferLeader{FromStore: 1, ToStore: 2})), IsTrue)
+	oc.RemoveOperator(oc.GetOperator(1))
+
+	// All stores of the region have to advertise the capability, including
+	// the ones not executing the step, since they may become the leader.
+	for _, id := range []uint64{1, 2} {
+		cluster.PutStore(cluster.GetStore(id).Clone(core.SetStoreCapabilities([]string{operator.CapabilityDemoteVoter})))
+	}
+	c.Assert(oc.AddOperator(newOp()), IsFalse)
+	cluster.PutStore(cluster.GetStore(3).Clone(core.SetStoreCapabilities([]string{operator.CapabilityDemoteVoter, "witness"})))
+	c.Assert(oc.AddOperator(newOp()), IsTrue)
+}
+
+func (t *testOperatorControllerSuite) TestDecommissionWindow(c *C) {
+	cluster := mockcluster.NewCluster(mockoption.NewScheduleOptions())
+	oc := NewOperatorController(cluster, mockhbstream.NewHeartbeatStreams(cluster.ID))
+	cluster.AddLeaderStore(1, 0)
+	cluster.AddLeaderStore(2, 0)
+	cluster.AddLeaderStore(3, 0)
+	cluster.AddLeaderRegion(1, 1, 2)
+	cluster.SetStoreOffline(2)
+	cluster.SetEvacuationAllowed(2, false)
+
+	region := cluster.GetRegion(1)
+	newOp := func(kind operator.OpKind) *operator.Operator {
+		return operator.NewOperator("test", "test", 1, region.GetRegionEpoch(), kind,
+			operator.AddPeer{ToStore: 3, PeerID: 3}, operator.RemovePeer{FromStore: 2})
+	}
+	// The store out of its window is not evacuated, unless by users.
+	c.Assert(oc.AddOperator(newOp(operator.OpRegion)), IsFalse)
+	c.Assert(oc.AddOperator(newOp(operator.OpRegion|operator.OpAdmin)), IsTrue)
+	c.Assert(oc.RemoveOperator(oc.GetOperator(1)), IsTrue)
+
+	// The replica checker still repairs the down peers.
+	c.Assert(oc.AddOperator(newOp(operator.OpRegion|operator.OpReplica)), IsFalse)
+	region = region.Clone(core.WithDownPeers([]*pdpb.PeerStats{{Peer: region.GetStorePeer(2), DownSeconds: 3600}}))
+	cluster.PutRegion(region)
+	c.Assert(oc.AddOperator(newOp(operator.OpRegion)), IsFalse)
+	c.Assert(oc.AddOperator(newOp(operator.OpRegion|operator.OpReplica)), IsTrue)
+	c.Assert(oc.RemoveOperator(oc.GetOperator(1)), IsTrue)
+
+	cluster.SetEvacuationAllowed(2, true)
+	c.Assert(oc.AddOperator(newOp(operator.OpRegion)), IsTrue)
+}
+
+func (t *testOperatorControllerSuite) TestRegionPin(c *C) {
+	cluster := mockcluster.NewCluster(mockoption.NewScheduleOptions())
+	oc := NewOperatorController(cluster, mockhbstream.NewHeartbeatStreams(cluster.ID))
+	for id := uint64(1); id <= 4; id++ {
+		cluster.AddLeaderStore(id, 0)
+	}
+	cluster.AddLeaderRegion(1, 1, 2, 3)
+	cluster.PinRegion(1, &core.RegionPin{RegionID: 1, LeaderStoreID: 1, StoreIDs: []uint64{1, 2, 3}})
+
+	region := cluster.GetRegion(1)
+	newOp := func(kind operator.OpKind, steps ...operator.OpStep) *operator.Operator {
+		return operator.NewOperator("test", "test", 1, region.GetRegionEpoch(), kind, steps...)
+	}
+	// The pinned region is not moved, unless by users.
+	c.Assert(oc.AddOperator(newOp(operator.OpLeader, operator.TransferLeader{FromStore: 1, ToStore: 2})), IsFalse)
+	c.Assert(oc.AddOperator(newOp(operator.OpRegion, operator.AddPeer{ToStore: 4, PeerID: 4})), IsFalse)
+	c.Assert(oc.AddOperator(newOp(operator.OpRegion, operator.RemovePeer{FromStore: 3})), IsFalse)
+	c.Assert(oc.AddOperator(newOp(operator.OpMerge, operator.MergeRegion{})), IsFalse)
+	c.Assert(oc.AddOperator(newOp(operator.OpLeader|operator.OpAdmin, operator.TransferLeader{FromStore: 1, ToStore: 2})), IsTrue)
+	c.Assert(oc.RemoveOperator(oc.GetOperator(1)), IsTrue)
+
+	// The leader can be transferred if only the peers are pinned.
+	cluster.PinRegion(1, &core.RegionPin{RegionID: 1, StoreIDs: []uint64{1, 2, 3}})
+	c.Assert(oc.AddOperator(newOp(operator.OpLeader, operator.TransferLeader{FromStore: 1, ToStore: 2})), IsTrue)
+	c.Assert(oc.RemoveOperator(oc.GetOperator(1)), IsTrue)
+
+	cluster.PinRegion(1, nil)
+	c.Assert(oc.AddOperator(newOp(operator.OpRegion, operator.AddPeer{ToStore: 4, PeerID: 4})), IsTrue)
+}
+
+func (t *testOperatorControllerSuite) TestPlacementIntent(c *C) {
+	cluster := mockcluster.NewCluster(mockoption.NewScheduleOptions())
+	oc := NewOperatorController(cluster, mockhbstream.NewHeartbeatStreams(cluster.ID))
+	for id := uint64(1); id <= 5; id++ {
+		cluster.AddLeaderStore(id, 0)
+	}
+	cluster.AddLeaderRegion(1, 1, 2, 4)
+	cluster.AddPlacementIntent(&core.PlacementIntent{RegionIDs: []uint64{1}, LeaderStoreID: 2, StoreIDs: []uint64{1, 2, 3}})
+
+	region := cluster.GetRegion(1)
+	newOp := func(kind operator.OpKind, steps ...operator.OpStep) *operator.Operator {
+		return operator.NewOperator("test", "test", 1, region.GetRegionEpoch(), kind, steps...)
+	}
+	// The region is not moved away from the intent.
+	c.Assert(oc.AddOperator(newOp(operator.OpRegion, operator.AddPeer{ToStore: 5, PeerID: 5})), IsFalse)
+	c.Assert(oc.AddOperator(newOp(operator.OpRegion, operator.RemovePeer{FromStore: 2})), IsFalse)
+	c.Assert(oc.AddOperator(newOp(operator.OpMerge, operator.MergeRegion{})), IsFalse)
+	// The region is moved towards the intent.
+	c.Assert(oc.AddOperator(newOp(operator.OpRegion, operator.AddPeer{ToStore: 3, PeerID: 5}, operator.RemovePeer{FromStore: 4})), IsTrue)
+	c.Assert(oc.RemoveOperator(oc.GetOperator(1)), IsTrue)
+	c.Assert(oc.AddOperator(newOp(operator.OpLeader, operator.TransferLeader{FromStore: 1, ToStore: 2})), IsTrue)
+	c.Assert(oc.RemoveOperator(oc.GetOperator(1)), IsTrue)
+	// The replicas are repaired regardless of the intent.
+	c.Assert(oc.AddOperator(newOp(operator.OpReplica, operator.RemovePeer{FromStore: 2})), IsTrue)
+	c.Assert(oc.RemoveOperator(oc.GetOperator(1)), IsTrue)
+
+	cluster.AddLeaderRegion(1, 2, 1, 3)
+	region = cluster.GetRegion(1)
+	c.Assert(oc.AddOperator(newOp(operator.OpLeader, operator.TransferLeader{FromStore: 2, ToStore: 1})), IsFalse)
+}
+
+func (t *testOperatorControllerSuite) TestPreemptOperator(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	cluster := mockcluster.NewCluster(opt)
+	oc := NewOperatorController(cluster, mockhbstream.NewHeartbeatStreams(cluster.ID))
+	for id := uint64(1); id <= 4; id++ {
+		cluster.AddLeaderStore(id, 0)
+	}
+	cluster.AddLeaderRegion(1, 1, 2, 3)
+
+	region := cluster.GetRegion(1)
+	newOp := func(kind operator.OpKind) *operator.Operator {
+		return operator.NewOperator("test", "test", 1, region.GetRegionEpoch(), kind|operator.OpRegion, operator.AddPeer{ToStore: 4, PeerID: 4})
+	}
+	merge := newOp(operator.OpMerge)
+	c.Assert(oc.AddOperator(merge), IsTrue)
+	// The operators are not preempted without the preemption enabled.
+	c.Assert(oc.AddOperator(newOp(operator.OpReplica|operator.OpRepair)), IsFalse)
+	c.Assert(oc.GetOperator(1), Equals, merge)
+
+	opt.PreemptiveOperatorKinds = []string{"balance", "replica"}
+	balance := newOp(operator.OpBalance)
+	c.Assert(oc.AddOperator(balance), IsTrue)
+	c.Assert(oc.GetOperator(1), Equals, balance)
+	// The namespace relocation is not enabled to preempt.
+	c.Assert(oc.AddOperator(newOp(operator.OpReplica|operator.OpNamespace)), IsFalse)
+	// Moving a replica to a better location is not a repair.
+	c.Assert(oc.AddOperator(newOp(operator.OpReplica)), IsFalse)
+	replica := newOp(operator.OpReplica | operator.OpRepair)
+	c.Assert(oc.AddOperator(replica), IsTrue)
+	c.Assert(oc.GetOperator(1), Equals, replica)
+	// An operator never preempts the ones of higher or equal priorities.
+	c.Assert(oc.AddOperator(newOp(operator.OpBalance)), IsFalse)
+	c.Assert(oc.AddOperator(newOp(operator.OpReplica|operator.OpRepair)), IsFalse)
+	c.Assert(oc.RemoveOperator(replica), IsTrue)
+
+	// The operators of a higher priority level and the ones without a schedule
+	// priority are not preempted.
+	hot := newOp(operator.OpHotRegion)
+	c.Assert(oc.AddOperator(hot), IsTrue)
+	c.Assert(oc.AddOperator(newOp(operator.OpReplica|operator.OpRepair)), IsFalse)
+	c.Assert(oc.RemoveOperator(hot), IsTrue)
+	urgent := newOp(operator.OpMerge)
+	urgent.SetPriorityLevel(core.HighPriority)
+	c.Assert(oc.AddOperator(urgent), IsTrue)
+	c.Assert(oc.AddOperator(newOp(operator.OpReplica|operator.OpRepair)), IsFalse)
+	c.Assert(oc.RemoveOperator(urgent), IsTrue)
+
+	// Preempting a merge operator cancels the other one of the pair.
+	newRepair := func(regionID uint64) *operator.Operator {
+		region := cluster.GetRegion(regionID)
+		return operator.NewOperator("test", "test", regionID, region.GetRegionEpoch(), operator.OpLeader|operator.OpReplica|operator.OpRepair, operator.TransferLeader{FromStore: 1, ToStore: 2})
+	}
+	cluster.AddLeaderStore(5, 0)
+	cluster.AddLeaderRegion(2, 1, 2, 5)
+	ops, err := operator.CreateMergeRegionOperator("merge-region", cluster, cluster.GetRegion(1), cluster.GetRegion(2), operator.OpMerge)
+	c.Assert(err, IsNil)
+	c.Assert(oc.AddOperator(ops...), IsTrue)
+	c.Assert(ops[0].IsMerging(), IsFalse)
+	c.Assert(oc.AddOperator(newRepair(1)), IsTrue)
+	c.Assert(oc.GetOperator(2), IsNil)
+	c.Assert(oc.GetOperatorStatus(2).Status, Equals, pdpb.OperatorStatus_CANCEL)
+
+	// The merge operators are never preempted once the merge is sent.
+	cluster.AddLeaderRegion(3, 1, 2, 3)
+	cluster.AddLeaderRegion(4, 1, 2, 3)
+	ops, err = operator.CreateMergeRegionOperator("merge-region", cluster, cluster.GetRegion(3), cluster.GetRegion(4), operator.OpMerge)
+	c.Assert(err, IsNil)
+	c.Assert(oc.AddOperator(ops...), IsTrue)
+	c.Assert(ops[0].IsMerging(), IsTrue)
+	c.Assert(ops[1].IsMerging(), IsFalse)
+	for _, op := range ops {
+		c.Assert(oc.AddOperator(newRepair(op.RegionID())), IsFalse)
+		c.Assert(oc.GetOperator(op.RegionID()), Equals, op)
+	}
+}
+
+func (t *testOperatorControllerSuite) TestEstimateDuration(c *C) {
+	cluster := mockcluster.NewCluster(mockoption.NewScheduleOptions())
+	oc := NewOperatorController(cluster, mockhbstream.NewHeartbeatStreams(cluster.ID))
+	cluster.AddLeaderStore(1, 0)
+	cluster.AddLeaderStore(2, 0)
+	cluster.AddLeaderRegion(1, 1)
+	region := cluster.GetRegion(1).Clone(core.SetApproximateSize(100))
+	cluster.PutRegion(region)
+
+	// The default apply rate is assumed for the store never observed.
+	op := operator.NewOperator("test", "test", 1, region.GetRegionEpoch(), operator.OpRegion, operator.AddPeer{ToStore: 2, PeerID: 2})
+	c.Assert(oc.AddOperator(op), IsTrue)
+	c.Assert(op.GetExpectedDuration(), Equals, 10*time.Second+logCatchUpTime)
+
+	// The slow store leads to longer durations.
+	oc.applyRates.observe(2, 100, 100*time.Second)
+	c.Assert(oc.applyRates.get(2), Equals, 1.0)
+	oc.applyRates.observe(2, 100, 10*time.Second)
+	c.Assert(oc.applyRates.get(2), Equals, 3.7)
+	op.SetStartTime(time.Now().Add(-time.Minute))
+	oc.observeApplyRate(op, region)
+	c.Assert(oc.applyRates.get(2) < 3.7, IsTrue)
+	c.Assert(oc.estimateDuration(op, region) > op.GetExpectedDuration(), IsTrue)
+
+	// The operators not adding peers are not estimated.
+	op = operator.NewOperator("test", "test", 1, region.GetRegionEpoch(), operator.OpLeader, operator.TransferLeader{FromStore: 1, ToStore: 2})
+	c.Assert(oc.estimateDuration(op, region), Equals, time.Duration(0))
+}
+
+func (t *testOperatorControllerSuite) TestCancelOperator(c *C) {
+	cluster := mockcluster.NewCluster(mockoption.NewScheduleOptions())
+	stream := mockhbstream.NewHeartbeatStreams(cluster.ID)
+	controller := NewOperatorController(cluster, stream)
+
+	epoch := &metapb.RegionEpoch{ConfVer: 0, Version: 0}
+	region := cluster.MockRegionInfo(1, 1, []uint64{2}, epoch)
+	cluster.PutRegion(region)
+	steps := []operator.OpStep{
+		operator.AddLearner{ToStore: 3, PeerID: 3},
+		operator.PromoteLearner{ToStore: 3, PeerID: 3},
+		operator.RemovePeer{FromStore: 1},
+	}
+
+	// The learner is not added yet, so nothing is sent when it is canceled.
+	op := operator.NewOperator("test", "test", 1, epoch, operator.OpRegion, steps...)
+	c.Assert(controller.AddOperator(op), IsTrue)
+	c.Assert(len(stream.MsgCh()), Equals, 1)
+	<-stream.MsgCh()
+	c.Assert(controller.CancelOperator(op, "test"), IsTrue)
+	c.Assert(controller.CancelOperator(op, "test"), IsFalse)
+	c.Assert(len(stream.MsgCh()), Equals, 0)
+	status := controller.GetOperatorStatus(1)
+	c.Assert(status.Status, Equals, pdpb.OperatorStatus_CANCEL)
+	c.Assert(status.Reason, Equals, "test")
+
+	// The pending learner is removed to abort the snapshot.
+	op = operator.NewOperator("test", "test", 1, epoch, operator.OpRegion, steps...)
+	c.Assert(controller.AddOperator(op), IsTrue)
+	<-stream.MsgCh()
+	learner := &metapb.Peer{Id: 3, StoreId: 3, IsLearner: true}
+	cluster.PutRegion(region.Clone(
+		core.WithAddPeer(learner),
+		core.WithPendingPeers([]*metapb.Peer{learner}),
+		core.WithIncConfVer(),
+	))
+	c.Assert(controller.CancelOperator(op, "slow snapshot"), IsTrue)
+	c.Assert(len(stream.MsgCh()), Equals, 1)
+	msg := <-stream.MsgCh()
+	c.Assert(msg.GetChangePeer().GetChangeType(), Equals, eraftpb.ConfChangeType_RemoveNode)
+	c.Assert(msg.GetChangePeer().GetPeer(), DeepEquals, learner)
+	c.Assert(controller.GetOperatorStatus(1).Reason, Equals, "slow snapshot")
+}
+
+func (t *testOperatorControllerSuite) TestSnapshotBandwidth(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	tc := mockcluster.NewCluster(opt)
+	oc := NewOperatorController(tc, mockhbstream.NewHeartbeatStream())
+	tc.AddLeaderStore(1, 0)
+	tc.AddLeaderStore(2, 0)
+	tc.AddLeaderRegion(1, 1)
+	tc.AddLeaderRegion(2, 1)
+	tc.AddLeaderRegion(3, 1, 2)
+	op1 := operator.NewOperator("test", "test", 1, &metapb.RegionEpoch{}, operator.OpRegion, operator.AddPeer{ToStore: 2, PeerID: 11})
+	op2 := operator.NewOperator("test", "test", 2, &metapb.RegionEpoch{}, operator.OpRegion, operator.AddLearner{ToStore: 2, PeerID: 12})
+	op3 := operator.NewOperator("test", "test", 3, &metapb.RegionEpoch{}, operator.OpRegion, operator.RemovePeer{FromStore: 2})
+	oc.SetOperator(op1)
+	oc.SetOperator(op2)
+	oc.SetOperator(op3)
+
+	// No budget.
+	c.Assert(oc.GetSnapshotBandwidth(1), Equals, uint64(0))
+	c.Assert(oc.GetStoreSnapshotBandwidth(1), Equals, uint64(0))
+
+	// The budget is shared by the operators adding peers.
+	opt.SnapshotBandwidthBudget = 100 << 20
+	c.Assert(oc.GetSnapshotBandwidth(1), Equals, uint64(50<<20))
+	c.Assert(oc.GetSnapshotBandwidth(2), Equals, uint64(50<<20))
+	c.Assert(oc.GetSnapshotBandwidth(3), Equals, uint64(0))
+	c.Assert(oc.GetSnapshotBandwidth(4), Equals, uint64(0))
+	c.Assert(oc.GetOperatorStatus(1).SnapshotBandwidth, Equals, uint64(50<<20))
+	// Store 1 leads both regions, and store 2 gets the share of one.
+	c.Assert(oc.GetStoreSnapshotBandwidth(1), Equals, uint64(100<<20))
+	c.Assert(oc.GetStoreSnapshotBandwidth(2), Equals, uint64(50<<20))
+
+	// The peer of region 2 is added.
+	op2.Check(tc.GetRegion(2).Clone(core.WithAddPeer(&metapb.Peer{Id: 12, StoreId: 2, IsLearner: true})))
+	c.Assert(op2.IsSendingSnapshot(), IsFalse)
+	c.Assert(oc.GetSnapshotBandwidth(1), Equals, uint64(100<<20))
+	c.Assert(oc.RemoveOperator(op1), IsTrue)
+	c.Assert(oc.GetSnapshotBandwidth(1), Equals, uint64(0))
+	c.Assert(oc.GetStoreSnapshotBandwidth(1), Equals, uint64(100<<20))
+}
+
+func (t *testOperatorControllerSuite) TestSnapshotBandwidthThrottle(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	tc := mockcluster.NewCluster(opt)
+	stream := mockhbstream.NewHeartbeatStreams(tc.ID)
+	oc := NewOperatorController(tc, stream)
+	tc.AddLeaderStore(1, 0)
+	tc.AddLeaderStore(2, 0)
+	tc.AddLeaderRegion(1, 1)
+	tc.AddLeaderRegion(2, 1)
+	opt.SnapshotBandwidthBudget = 15 << 20
+	op1 := operator.NewOperator("test", "test", 1, &metapb.RegionEpoch{}, operator.OpRegion, operator.AddLearner{ToStore: 2, PeerID: 11})
+	op2 := operator.NewOperator("test", "test", 2, &metapb.RegionEpoch{}, operator.OpRegion, operator.AddLearner{ToStore: 2, PeerID: 12})
+
+	// The snapshot of region 1 takes 10MB of the budget.
+	c.Assert(oc.AddOperator(op1), IsTrue)
+	c.Assert(len(stream.MsgCh()), Equals, 1)
+	<-stream.MsgCh()
+	c.Assert(op1.IsSnapshotAdmitted(), IsTrue)
+
+	// The snapshot of region 2 waits for the budget.
+	c.Assert(oc.AddOperator(op2), IsTrue)
+	c.Assert(len(stream.MsgCh()), Equals, 0)
+	c.Assert(op2.IsSnapshotAdmitted(), IsFalse)
+
+	// Resending the admitted step does not take the budget again.
+	oc.Dispatch(tc.GetRegion(1), DispatchFromHeartBeat)
+	c.Assert(len(stream.MsgCh()), Equals, 1)
+	<-stream.MsgCh()
+
+	// No budget means no throttling.
+	opt.SnapshotBandwidthBudget = 0
+	oc.Dispatch(tc.GetRegion(2), DispatchFromHeartBeat)
+	c.Assert(len(stream.MsgCh()), Equals, 1)
+}
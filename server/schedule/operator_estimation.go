@@ -0,0 +1,117 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedule
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/schedule/operator"
+)
+
+const (
+	// defaultStoreApplyRate is the rate in MB per second a store is assumed to
+	// receive and apply snapshots at before any operator to it finishes.
+	defaultStoreApplyRate = 10.0
+	// minStoreApplyRate bounds the observed rates, since an operator may also
+	// wait for other reasons such as the store limit.
+	minStoreApplyRate = 0.1
+	// logCatchUpTime is the time for a new peer to catch up the logs
+	// appended after its snapshot is generated.
+	logCatchUpTime = 10 * time.Second
+	// applyRateWeight is the weight of the latest observation in the apply
+	// rate of a store.
+	applyRateWeight = 0.3
+)
+
+// storeApplyRates records the historical rates in MB per second of the stores
+// receiving and applying snapshots, which are observed from the finished
+// operators adding peers.
+type storeApplyRates struct {
+	sync.RWMutex
+	rates map[uint64]float64
+}
+
+func newStoreApplyRates() *storeApplyRates {
+	return &storeApplyRates{rates: make(map[uint64]float64)}
+}
+
+func (r *storeApplyRates) get(storeID uint64) float64 {
+	r.RLock()
+	defer r.RUnlock()
+	if rate, ok := r.rates[storeID]; ok {
+		return rate
+	}
+	return defaultStoreApplyRate
+}
+
+func (r *storeApplyRates) observe(storeID uint64, size int64, d time.Duration) {
+	if size <= 0 || d <= 0 {
+		return
+	}
+	rate := float64(size) / d.Seconds()
+	if rate < minStoreApplyRate {
+		rate = minStoreApplyRate
+	}
+	r.Lock()
+	defer r.Unlock()
+	if old, ok := r.rates[storeID]; ok {
+		rate = old*(1-applyRateWeight) + rate*applyRateWeight
+	}
+	r.rates[storeID] = rate
+}
+
+// addPeerStores returns the stores which the operator adds peers or learners
+// to, which receive snapshots.
+func addPeerStores(op *operator.Operator) []uint64 {
+	var stores []uint64
+	for i := 0; i < op.Len(); i++ {
+		switch s := op.Step(i).(type) {
+		case operator.AddPeer:
+			stores = append(stores, s.ToStore)
+		case operator.AddLightPeer:
+			stores = append(stores, s.ToStore)
+		case operator.AddLearner:
+			stores = append(stores, s.ToStore)
+		case operator.AddLightLearner:
+			stores = append(stores, s.ToStore)
+		}
+	}
+	return stores
+}
+
+// estimateDuration estimates the duration for the new peers of the operator
+// to apply the snapshots of the region and catch up the logs, or 0 if the
+// operator adds no peer.
+func (oc *OperatorController) estimateDuration(op *operator.Operator, region *core.RegionInfo) time.Duration {
+	var d time.Duration
+	for _, storeID := range addPeerStores(op) {
+		seconds := float64(region.GetApproximateSize()) / oc.applyRates.get(storeID)
+		d += time.Duration(seconds*float64(time.Second)) + logCatchUpTime
+	}
+	return d
+}
+
+// observeApplyRate updates the apply rate of the store with the finished
+// operator adding a peer to it, where the whole running time is regarded as
+// the time to apply the snapshot. The operators adding several peers are not
+// observed since the time of each one is unknown.
+func (oc *OperatorController) observeApplyRate(op *operator.Operator, region *core.RegionInfo) {
+	stores := addPeerStores(op)
+	if len(stores) != 1 {
+		return
+	}
+	oc.applyRates.observe(stores[0], region.GetApproximateSize(), op.RunningTime())
+}
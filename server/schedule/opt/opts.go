@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/server/core"
 )
 
 // Options for schedulers.
@@ -29,14 +30,25 @@ type Options interface {
 
 	// store limit
 	GetStoreBalanceRate() float64
+	// GetScheduleSpeedScale returns the factor in (0, 1] the schedule limits
+	// and the store limits are multiplied by.
+	GetScheduleSpeedScale() float64
 
 	GetMaxSnapshotCount() uint64
 	GetMaxPendingPeerCount() uint64
+	GetSnapshotBandwidthBudget() uint64
+	GetMaxStoreLearnerCount() uint64
+	GetMaxLearnerCount() uint64
+	GetMaxLearnerPromoteLag() uint64
 	GetMaxStoreDownTime() time.Duration
 	GetMaxMergeRegionSize() uint64
 	GetMaxMergeRegionKeys() uint64
 	GetSplitMergeInterval() time.Duration
 	IsOneWayMergeEnabled() bool
+	IsCrossTierMergeEnabled() bool
+	GetMaxMergeChainLength() uint64
+	GetLongEmptyRegionDuration() time.Duration
+	IsEmptyRegionMergeEnabled() bool
 
 	GetMaxReplicas() int
 	GetLocationLabels() []string
@@ -46,7 +58,14 @@ type Options interface {
 	GetTolerantSizeRatio() float64
 	GetLowSpaceRatio() float64
 	GetHighSpaceRatio() float64
+	GetMaxLeaderFractionPerStore() float64
+	GetMaxAdjacentLeaderCount() uint64
+	GetMaxStoreRegionCount() uint64
+	GetNetworkDistance(from, to *core.StoreInfo) float64
+	GetMaxStoreRegionCountPerGB() uint64
+	GetMemoryPressureRatio() float64
 	GetSchedulerMaxWaitingOperator() uint64
+	GetSchedulerDeniedStores(name string) []uint64
 
 	IsRemoveDownReplicaEnabled() bool
 	IsReplaceOfflineReplicaEnabled() bool
@@ -54,6 +73,7 @@ type Options interface {
 	IsRemoveExtraReplicaEnabled() bool
 	IsLocationReplacementEnabled() bool
 	IsNamespaceRelocationEnabled() bool
+	IsOperatorPreemptionEnabled(kind string) bool
 
 	CheckLabelProperty(typ string, labels []*metapb.StoreLabel) bool
 }
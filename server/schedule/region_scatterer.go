@@ -84,6 +84,7 @@ func NewRegionScatterer(cluster Cluster, classifier namespace.Classifier) *Regio
 		classifier: classifier,
 		filters: []filter.Filter{
 			filter.StoreStateFilter{ActionScope: regionScatterName},
+			filter.NewLearnerOnlyFilter(regionScatterName),
 		},
 		selected: newSelectedStores(),
 	}
@@ -91,7 +92,13 @@ func NewRegionScatterer(cluster Cluster, classifier namespace.Classifier) *Regio
 
 // Scatter relocates the region.
 func (r *RegionScatterer) Scatter(region *core.RegionInfo) (*operator.Operator, error) {
-	if len(region.GetPeers()) != r.cluster.GetMaxReplicas() {
+	var replicas int
+	for _, peer := range region.GetPeers() {
+		if store := r.cluster.GetStore(peer.GetStoreId()); store == nil || !store.IsLearnerOnly() {
+			replicas++
+		}
+	}
+	if replicas != r.cluster.GetMaxReplicas() {
 		return nil, errors.Errorf("the number replicas of region %d is not expected", region.GetID())
 	}
 
@@ -115,6 +122,12 @@ func (r *RegionScatterer) scatterRegion(region *core.RegionInfo) *operator.Opera
 			stores = r.collectAvailableStores(region)
 		}
 
+		// The learners on learner-only stores are kept in place.
+		if store := r.cluster.GetStore(peer.GetStoreId()); store != nil && store.IsLearnerOnly() {
+			targetPeers = append(targetPeers, peer)
+			replacedPeers = append(replacedPeers, peer)
+			continue
+		}
 		if r.selected.put(peer.GetStoreId()) {
 			delete(stores, peer.GetStoreId())
 			targetPeers = append(targetPeers, peer)
@@ -80,7 +80,25 @@ type Cluster interface {
 
 	// get config methods
 	GetOpt() namespace.ScheduleOptions
+	// GetNamespace returns the namespace that the cluster is limited to, or
+	// empty if it is the whole cluster.
+	GetNamespace() string
 	// TODO: it should be removed. Schedulers don't need to know anything
 	// about peers.
 	AllocPeer(storeID uint64) (*metapb.Peer, error)
+	// GetEvacuateTargets returns the preferred stores to move the replicas of
+	// the store to when it is evacuated.
+	GetEvacuateTargets(storeID uint64) []uint64
+	// IsEvacuationAllowed returns whether the replicas can be moved out of
+	// the removed store now.
+	IsEvacuationAllowed(storeID uint64) bool
+	// GetRegionPin returns the pin of the region, or nil if the region is not
+	// pinned.
+	GetRegionPin(regionID uint64) *core.RegionPin
+	// GetPlacementIntent returns the placement intent of the region, or nil
+	// if the region has no intent.
+	GetPlacementIntent(regionID uint64) *core.PlacementIntent
+	// IsMergePaused returns if merging the regions in [startKey, endKey) is
+	// paused.
+	IsMergePaused(startKey, endKey []byte) bool
 }
@@ -24,8 +24,9 @@ import (
 // BalanceSelector selects source/target from store candidates based on their
 // resource scores.
 type BalanceSelector struct {
-	kind    core.ResourceKind
-	filters []filter.Filter
+	kind      core.ResourceKind
+	filters   []filter.Filter
+	tierScore bool
 }
 
 // NewBalanceSelector creates a BalanceSelector instance.
@@ -36,6 +37,24 @@ func NewBalanceSelector(kind core.ResourceKind, filters []filter.Filter) *Balanc
 	}
 }
 
+// NewTierBalanceSelector creates a BalanceSelector which compares the stores
+// by the scoring functions of their tiers. The candidates should be the stores
+// of the same type.
+func NewTierBalanceSelector(kind core.ResourceKind, filters []filter.Filter) *BalanceSelector {
+	return &BalanceSelector{
+		kind:      kind,
+		filters:   filters,
+		tierScore: true,
+	}
+}
+
+func (s *BalanceSelector) score(opt opt.Options, store *core.StoreInfo) float64 {
+	if s.tierScore {
+		return store.TierResourceScore(s.kind, opt.GetHighSpaceRatio(), opt.GetLowSpaceRatio(), 0)
+	}
+	return store.ResourceScore(s.kind, opt.GetHighSpaceRatio(), opt.GetLowSpaceRatio(), 0)
+}
+
 // SelectSource selects the store that can pass all filters and has the maximal
 // resource score.
 func (s *BalanceSelector) SelectSource(opt opt.Options, stores []*core.StoreInfo, filters ...filter.Filter) *core.StoreInfo {
@@ -45,9 +64,7 @@ func (s *BalanceSelector) SelectSource(opt opt.Options, stores []*core.StoreInfo
 		if filter.Source(opt, store, filters) {
 			continue
 		}
-		if result == nil ||
-			result.ResourceScore(s.kind, opt.GetHighSpaceRatio(), opt.GetLowSpaceRatio(), 0) <
-				store.ResourceScore(s.kind, opt.GetHighSpaceRatio(), opt.GetLowSpaceRatio(), 0) {
+		if result == nil || s.score(opt, result) < s.score(opt, store) {
 			result = store
 		}
 	}
@@ -63,9 +80,7 @@ func (s *BalanceSelector) SelectTarget(opt opt.Options, stores []*core.StoreInfo
 		if filter.Target(opt, store, filters) {
 			continue
 		}
-		if result == nil ||
-			result.ResourceScore(s.kind, opt.GetHighSpaceRatio(), opt.GetLowSpaceRatio(), 0) >
-				store.ResourceScore(s.kind, opt.GetHighSpaceRatio(), opt.GetLowSpaceRatio(), 0) {
+		if result == nil || s.score(opt, result) > s.score(opt, store) {
 			result = store
 		}
 	}
@@ -78,6 +93,8 @@ type ReplicaSelector struct {
 	regionStores []*core.StoreInfo
 	labels       []string
 	filters      []filter.Filter
+	// snapshotSource is the store sending snapshots to the target.
+	snapshotSource *core.StoreInfo
 }
 
 // NewReplicaSelector creates a ReplicaSelector instance.
@@ -89,6 +106,25 @@ func NewReplicaSelector(regionStores []*core.StoreInfo, labels []string, filters
 	}
 }
 
+// NewSnapshotReplicaSelector creates a ReplicaSelector which prefers the
+// targets closer to the store sending the snapshots, if their distinct scores
+// are the same.
+func NewSnapshotReplicaSelector(regionStores []*core.StoreInfo, labels []string, snapshotSource *core.StoreInfo, filters ...filter.Filter) *ReplicaSelector {
+	return &ReplicaSelector{
+		regionStores:   regionStores,
+		labels:         labels,
+		filters:        filters,
+		snapshotSource: snapshotSource,
+	}
+}
+
+func (s *ReplicaSelector) distance(opt opt.Options, store *core.StoreInfo) float64 {
+	if s.snapshotSource == nil {
+		return 0
+	}
+	return opt.GetNetworkDistance(s.snapshotSource, store)
+}
+
 // SelectSource selects the store that can pass all filters and has the minimal
 // distinct score.
 func (s *ReplicaSelector) SelectSource(opt opt.Options, stores []*core.StoreInfo) *core.StoreInfo {
@@ -98,7 +134,7 @@ func (s *ReplicaSelector) SelectSource(opt opt.Options, stores []*core.StoreInfo
 	)
 	for _, store := range stores {
 		score := core.DistinctScore(s.labels, s.regionStores, store)
-		if best == nil || compareStoreScore(opt, store, score, best, bestScore) < 0 {
+		if best == nil || compareStoreScore(opt, store, score, 0, best, bestScore, 0) < 0 {
 			best, bestScore = store, score
 		}
 	}
@@ -112,16 +148,18 @@ func (s *ReplicaSelector) SelectSource(opt opt.Options, stores []*core.StoreInfo
 // distinct score.
 func (s *ReplicaSelector) SelectTarget(opt opt.Options, stores []*core.StoreInfo, filters ...filter.Filter) *core.StoreInfo {
 	var (
-		best      *core.StoreInfo
-		bestScore float64
+		best         *core.StoreInfo
+		bestScore    float64
+		bestDistance float64
 	)
 	for _, store := range stores {
 		if filter.Target(opt, store, filters) {
 			continue
 		}
 		score := core.DistinctScore(s.labels, s.regionStores, store)
-		if best == nil || compareStoreScore(opt, store, score, best, bestScore) > 0 {
-			best, bestScore = store, score
+		distance := s.distance(opt, store)
+		if best == nil || compareStoreScore(opt, store, score, distance, best, bestScore, bestDistance) > 0 {
+			best, bestScore, bestDistance = store, score, distance
 		}
 	}
 	if best == nil || filter.Target(opt, best, s.filters) {
@@ -134,7 +172,7 @@ func (s *ReplicaSelector) SelectTarget(opt opt.Options, stores []*core.StoreInfo
 // Returns 0 if store A is as good as store B.
 // Returns 1 if store A is better than store B.
 // Returns -1 if store B is better than store A.
-func compareStoreScore(opt opt.Options, storeA *core.StoreInfo, scoreA float64, storeB *core.StoreInfo, scoreB float64) int {
+func compareStoreScore(opt opt.Options, storeA *core.StoreInfo, scoreA, distanceA float64, storeB *core.StoreInfo, scoreB, distanceB float64) int {
 	// The store with higher score is better.
 	if scoreA > scoreB {
 		return 1
@@ -142,6 +180,13 @@ func compareStoreScore(opt opt.Options, storeA *core.StoreInfo, scoreA float64,
 	if scoreA < scoreB {
 		return -1
 	}
+	// The store closer to the snapshot source is better.
+	if distanceA < distanceB {
+		return 1
+	}
+	if distanceA > distanceB {
+		return -1
+	}
 	// The store with lower region score is better.
 	if storeA.RegionScore(opt.GetHighSpaceRatio(), opt.GetLowSpaceRatio(), 0) <
 		storeB.RegionScore(opt.GetHighSpaceRatio(), opt.GetLowSpaceRatio(), 0) {
@@ -42,11 +42,40 @@ func (s *testSelectorSuite) TestCompareStoreScore(c *C) {
 	store2 := core.NewStoreInfoWithLabel(2, 1, nil)
 	store3 := core.NewStoreInfoWithLabel(3, 3, nil)
 
-	c.Assert(compareStoreScore(s.tc, store1, 2, store2, 1), Equals, 1)
-	c.Assert(compareStoreScore(s.tc, store1, 1, store2, 1), Equals, 0)
-	c.Assert(compareStoreScore(s.tc, store1, 1, store2, 2), Equals, -1)
+	c.Assert(compareStoreScore(s.tc, store1, 2, 0, store2, 1, 0), Equals, 1)
+	c.Assert(compareStoreScore(s.tc, store1, 1, 0, store2, 1, 0), Equals, 0)
+	c.Assert(compareStoreScore(s.tc, store1, 1, 0, store2, 2, 0), Equals, -1)
 
-	c.Assert(compareStoreScore(s.tc, store1, 2, store3, 1), Equals, 1)
-	c.Assert(compareStoreScore(s.tc, store1, 1, store3, 1), Equals, 1)
-	c.Assert(compareStoreScore(s.tc, store1, 1, store3, 2), Equals, -1)
+	c.Assert(compareStoreScore(s.tc, store1, 2, 0, store3, 1, 0), Equals, 1)
+	c.Assert(compareStoreScore(s.tc, store1, 1, 0, store3, 1, 0), Equals, 1)
+	c.Assert(compareStoreScore(s.tc, store1, 1, 0, store3, 2, 0), Equals, -1)
+
+	// The closer store is better if the distinct scores are the same.
+	c.Assert(compareStoreScore(s.tc, store3, 1, 1, store1, 1, 2), Equals, 1)
+	c.Assert(compareStoreScore(s.tc, store1, 1, 2, store3, 1, 1), Equals, -1)
+	c.Assert(compareStoreScore(s.tc, store3, 2, 2, store1, 1, 1), Equals, 1)
+}
+
+func (s *testSelectorSuite) TestSnapshotReplicaSelector(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	tc := mockcluster.NewCluster(opt)
+	tc.AddLabelsStore(1, 10, map[string]string{"zone": "z1"})
+	tc.AddLabelsStore(2, 10, map[string]string{"zone": "z2"})
+	tc.AddLabelsStore(3, 5, map[string]string{"zone": "z3"})
+	tc.AddLabelsStore(4, 10, map[string]string{"zone": "z3"})
+	regionStores := []*core.StoreInfo{tc.GetStore(1), tc.GetStore(2)}
+	labels := []string{"zone"}
+
+	// The store with fewer regions is selected without the distances.
+	selector := NewSnapshotReplicaSelector(regionStores, labels, tc.GetStore(1))
+	c.Assert(selector.SelectTarget(tc, tc.GetStores()).GetID(), Equals, uint64(3))
+
+	// The store closer to the leader is preferred.
+	opt.NetworkDistances = map[[2]uint64]float64{{1, 3}: 10, {1, 4}: 1}
+	c.Assert(selector.SelectTarget(tc, tc.GetStores()).GetID(), Equals, uint64(4))
+	c.Assert(NewReplicaSelector(regionStores, labels).SelectTarget(tc, tc.GetStores()).GetID(), Equals, uint64(3))
+
+	// The isolation goes first.
+	opt.NetworkDistances[[2]uint64{1, 2}] = 0
+	c.Assert(selector.SelectTarget(tc, []*core.StoreInfo{tc.GetStore(2), tc.GetStore(3)}).GetID(), Equals, uint64(3))
 }
@@ -81,6 +81,11 @@ func ApplyOperatorStep(region *core.RegionInfo, op *operator.Operator) *core.Reg
 				StoreId: s.ToStore,
 			}
 			region = region.Clone(core.WithRemoveStorePeer(s.ToStore), core.WithAddPeer(peer))
+		case operator.DemoteVoter:
+			if region.GetStoreVoter(s.ToStore) == nil {
+				panic("Demote peer that doesn't exist")
+			}
+			region = region.Clone(core.WithDemoteVoter(s.PeerID))
 		default:
 			panic("Unknown operator step")
 		}
@@ -17,11 +17,14 @@ import (
 	"math/rand"
 	"time"
 
+	"github.com/pingcap/pd/server/core"
 	"github.com/pingcap/pd/server/schedule/operator"
 )
 
 // PriorityWeight is used to represent the weight of different priorities of operators.
-var PriorityWeight = []float64{1.0, 4.0, 9.0}
+// The weight of UrgentPriority is unused since urgent operators are always
+// promoted first.
+var PriorityWeight = []float64{1.0, 4.0, 9.0, 16.0}
 
 // WaitingOperator is an interface of waiting operators.
 type WaitingOperator interface {
@@ -75,11 +78,15 @@ func (b *RandBuckets) ListOperator() []*operator.Operator {
 	return ops
 }
 
-// GetOperator gets an operator from the random buckets.
+// GetOperator gets an operator from the random buckets. Operators with
+// UrgentPriority are returned before the others in FIFO order.
 func (b *RandBuckets) GetOperator() []*operator.Operator {
 	if b.totalWeight == 0 {
 		return nil
 	}
+	if bucket := b.buckets[core.UrgentPriority]; len(bucket.ops) > 0 {
+		return b.popOperator(bucket)
+	}
 	r := rand.Float64()
 	var sum float64
 	for i := range b.buckets {
@@ -89,25 +96,29 @@ func (b *RandBuckets) GetOperator() []*operator.Operator {
 		}
 		proportion := bucket.weight / b.totalWeight
 		if r >= sum && r < sum+proportion {
-			var res []*operator.Operator
-			res = append(res, bucket.ops[0])
-			// Merge operation has two operators, and thus it should be handled specifically.
-			if bucket.ops[0].Kind()&operator.OpMerge != 0 {
-				res = append(res, bucket.ops[1])
-				bucket.ops = bucket.ops[2:]
-			} else {
-				bucket.ops = bucket.ops[1:]
-			}
-			if len(bucket.ops) == 0 {
-				b.totalWeight -= bucket.weight
-			}
-			return res
+			return b.popOperator(bucket)
 		}
 		sum += proportion
 	}
 	return nil
 }
 
+func (b *RandBuckets) popOperator(bucket *Bucket) []*operator.Operator {
+	var res []*operator.Operator
+	res = append(res, bucket.ops[0])
+	// Merge operation has two operators, and thus it should be handled specifically.
+	if bucket.ops[0].Kind()&operator.OpMerge != 0 {
+		res = append(res, bucket.ops[1])
+		bucket.ops = bucket.ops[2:]
+	} else {
+		bucket.ops = bucket.ops[1:]
+	}
+	if len(bucket.ops) == 0 {
+		b.totalWeight -= bucket.weight
+	}
+	return res
+}
+
 // WaitingOperatorStatus is used to limit the count of each kind of operators.
 type WaitingOperatorStatus struct {
 	ops map[string]uint64
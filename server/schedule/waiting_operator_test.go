@@ -51,6 +51,27 @@ func addOperators(wop WaitingOperator) {
 	wop.PutOperator(op)
 }
 
+func (s *testWaitingOperatorSuite) TestUrgentOperator(c *C) {
+	rb := NewRandBuckets()
+	addOperators(rb)
+	for i := uint64(4); i < 6; i++ {
+		op := operator.NewOperator("testOperatorUrgent", "test", i, &metapb.RegionEpoch{}, operator.OpRegion, []operator.OpStep{
+			operator.RemovePeer{FromStore: i},
+		}...)
+		op.SetPriorityLevel(core.UrgentPriority)
+		rb.PutOperator(op)
+	}
+	for i := uint64(4); i < 6; i++ {
+		ops := rb.GetOperator()
+		c.Assert(ops, HasLen, 1)
+		c.Assert(ops[0].RegionID(), Equals, i)
+	}
+	for i := 0; i < 3; i++ {
+		c.Assert(rb.GetOperator()[0].GetPriorityLevel(), Not(Equals), core.UrgentPriority)
+	}
+	c.Assert(rb.GetOperator(), IsNil)
+}
+
 func (s *testWaitingOperatorSuite) TestListOperator(c *C) {
 	rb := NewRandBuckets()
 	addOperators(rb)
@@ -63,6 +63,7 @@ func newBalanceLeaderScheduler(opController *schedule.OperatorController, opts .
 	filters := []filter.Filter{
 		filter.StoreStateFilter{ActionScope: s.GetName(), TransferLeader: true},
 		filter.NewCacheFilter(s.GetName(), taintStores),
+		filter.NewSchedulerDenyFilter(s.GetName()),
 	}
 	s.selector = selector.NewBalanceSelector(core.LeaderKind, filters)
 	return s
@@ -96,6 +97,12 @@ func (l *balanceLeaderScheduler) GetType() string {
 	return "balance-leader"
 }
 
+// RemoveStaleStores removes the stores which do not exist from the taint
+// cache, and returns the number of the removed stores.
+func (l *balanceLeaderScheduler) RemoveStaleStores(exists func(storeID uint64) bool) int {
+	return l.taintStores.RemoveIf(func(storeID uint64) bool { return !exists(storeID) })
+}
+
 func (l *balanceLeaderScheduler) IsScheduleAllowed(cluster schedule.Cluster) bool {
 	return l.opController.OperatorCount(operator.OpLeader) < cluster.GetLeaderScheduleLimit()
 }
@@ -104,11 +111,13 @@ func (l *balanceLeaderScheduler) Schedule(cluster schedule.Cluster) []*operator.
 	schedulerCounter.WithLabelValues(l.GetName(), "schedule").Inc()
 
 	stores := cluster.GetStores()
+	// It keeps any store from holding too many leaders regardless of its weight.
+	fractionFilter := filter.NewLeaderFractionFilter(l.GetName(), stores)
 
 	// source/target is the store with highest/lowest leader score in the list that
 	// can be selected as balance source/target.
 	source := l.selector.SelectSource(cluster, stores)
-	target := l.selector.SelectTarget(cluster, stores)
+	target := l.selector.SelectTarget(cluster, stores, fractionFilter)
 
 	// No store can be selected as source or target.
 	if source == nil || target == nil {
@@ -136,7 +145,7 @@ func (l *balanceLeaderScheduler) Schedule(cluster schedule.Cluster) []*operator.
 	l.counter.WithLabelValues("low-score", targetAddress, targetStoreLabel).Inc()
 
 	for i := 0; i < balanceLeaderRetryLimit; i++ {
-		if op := l.transferLeaderOut(cluster, source); op != nil {
+		if op := l.transferLeaderOut(cluster, source, fractionFilter); op != nil {
 			l.counter.WithLabelValues("transfer-out", sourceAddress, sourceStoreLabel).Inc()
 			return op
 		}
@@ -158,7 +167,7 @@ func (l *balanceLeaderScheduler) Schedule(cluster schedule.Cluster) []*operator.
 // transferLeaderOut transfers leader from the source store.
 // It randomly selects a health region from the source store, then picks
 // the best follower peer and transfers the leader.
-func (l *balanceLeaderScheduler) transferLeaderOut(cluster schedule.Cluster, source *core.StoreInfo) []*operator.Operator {
+func (l *balanceLeaderScheduler) transferLeaderOut(cluster schedule.Cluster, source *core.StoreInfo, filters ...filter.Filter) []*operator.Operator {
 	sourceID := source.GetID()
 	region := cluster.RandLeaderRegion(sourceID, core.HealthRegion())
 	if region == nil {
@@ -166,7 +175,7 @@ func (l *balanceLeaderScheduler) transferLeaderOut(cluster schedule.Cluster, sou
 		schedulerCounter.WithLabelValues(l.GetName(), "no-leader-region").Inc()
 		return nil
 	}
-	target := l.selector.SelectTarget(cluster, cluster.GetFollowerStores(region))
+	target := l.selector.SelectTarget(cluster, cluster.GetFollowerStores(region), filters...)
 	if target == nil {
 		log.Debug("region has no target store", zap.String("scheduler", l.GetName()), zap.Uint64("region-id", region.GetID()))
 		schedulerCounter.WithLabelValues(l.GetName(), "no-target-store").Inc()
@@ -197,6 +206,10 @@ func (l *balanceLeaderScheduler) transferLeaderIn(cluster schedule.Cluster, targ
 		schedulerCounter.WithLabelValues(l.GetName(), "no-leader").Inc()
 		return nil
 	}
+	if filter.Source(cluster, source, []filter.Filter{filter.NewSchedulerDenyFilter(l.GetName())}) {
+		schedulerCounter.WithLabelValues(l.GetName(), "denied-leader-store").Inc()
+		return nil
+	}
 	return l.createOperator(cluster, region, source, target)
 }
 
@@ -205,6 +218,18 @@ func (l *balanceLeaderScheduler) transferLeaderIn(cluster schedule.Cluster, targ
 // no new operator need to be created, otherwise create an operator that transfers
 // the leader from the source store to the target store for the region.
 func (l *balanceLeaderScheduler) createOperator(cluster schedule.Cluster, region *core.RegionInfo, source, target *core.StoreInfo) []*operator.Operator {
+	pinFilter := filter.NewRegionPinFilter(l.GetName(), cluster.GetRegionPin(region.GetID()), true)
+	if filter.Source(cluster, source, []filter.Filter{pinFilter}) || filter.Target(cluster, target, []filter.Filter{pinFilter}) {
+		log.Debug("region is pinned, ignore it", zap.String("scheduler", l.GetName()), zap.Uint64("region-id", region.GetID()))
+		schedulerCounter.WithLabelValues(l.GetName(), "region-pinned").Inc()
+		return nil
+	}
+	intentFilter := filter.NewPlacementIntentFilter(l.GetName(), cluster.GetPlacementIntent(region.GetID()), true)
+	if filter.Source(cluster, source, []filter.Filter{intentFilter}) || filter.Target(cluster, target, []filter.Filter{intentFilter}) {
+		log.Debug("region has placement intent, ignore it", zap.String("scheduler", l.GetName()), zap.Uint64("region-id", region.GetID()))
+		schedulerCounter.WithLabelValues(l.GetName(), "placement-intent").Inc()
+		return nil
+	}
 	if cluster.IsRegionHot(region) {
 		log.Debug("region is hot region, ignore it", zap.String("scheduler", l.GetName()), zap.Uint64("region-id", region.GetID()))
 		schedulerCounter.WithLabelValues(l.GetName(), "region-hot").Inc()
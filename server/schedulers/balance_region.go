@@ -14,8 +14,12 @@
 package schedulers
 
 import (
+	"bytes"
+	"encoding/hex"
 	"fmt"
+	"sort"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/pingcap/kvproto/pkg/metapb"
@@ -26,13 +30,34 @@ import (
 	"github.com/pingcap/pd/server/schedule/filter"
 	"github.com/pingcap/pd/server/schedule/operator"
 	"github.com/pingcap/pd/server/schedule/selector"
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 )
 
 func init() {
 	schedule.RegisterScheduler("balance-region", func(opController *schedule.OperatorController, args []string) (schedule.Scheduler, error) {
-		return newBalanceRegionScheduler(opController), nil
+		// The args are the start and end keys of the ranges that the
+		// scheduler is restricted to in hex format.
+		if len(args)%2 != 0 {
+			return nil, errors.New("should specify the start and end keys of each range")
+		}
+		var ranges []*balanceRegionRange
+		for i := 0; i < len(args); i += 2 {
+			startKey, err := hex.DecodeString(args[i])
+			if err != nil {
+				return nil, errors.Errorf("the start key %q is not in hex format", args[i])
+			}
+			endKey, err := hex.DecodeString(args[i+1])
+			if err != nil {
+				return nil, errors.Errorf("the end key %q is not in hex format", args[i+1])
+			}
+			if len(endKey) != 0 && bytes.Compare(startKey, endKey) >= 0 {
+				return nil, errors.Errorf("the start key %q should be less than the end key %q", args[i], args[i+1])
+			}
+			ranges = append(ranges, &balanceRegionRange{startKey: startKey, endKey: endKey})
+		}
+		return newBalanceRegionScheduler(opController, withBalanceRegionRanges(ranges)), nil
 	})
 }
 
@@ -48,15 +73,77 @@ const (
 	// q = 1.3, and n = 30, so t = 87299ms ≈ 87s.
 	hitsStoreCountThreshold = 30 * balanceRegionRetryLimit
 	balanceRegionName       = "balance-region-scheduler"
+	// balanceRegionRangeScanInterval is how long the regions in the ranges
+	// are reused before the ranges are scanned again.
+	balanceRegionRangeScanInterval = time.Minute
 )
 
+// balanceRegionRange is a key range that the scheduler is restricted to, with
+// the progress of balancing it.
+type balanceRegionRange struct {
+	startKey, endKey []byte
+	// regionCount is the number of the regions in the range when it is
+	// scheduled last time.
+	regionCount int
+	// operatorCount is the number of the operators created for the range.
+	operatorCount uint64
+}
+
+// overlaps checks if the region has any keys in the range.
+func (r *balanceRegionRange) overlaps(region *core.RegionInfo) bool {
+	return (len(r.endKey) == 0 || bytes.Compare(region.GetStartKey(), r.endKey) < 0) &&
+		(len(region.GetEndKey()) == 0 || bytes.Compare(region.GetEndKey(), r.startKey) > 0)
+}
+
+// BalanceRegionRangeStatus is the progress of balancing a key range. The keys
+// are in hex format.
+type BalanceRegionRangeStatus struct {
+	StartKey      string `json:"start_key"`
+	EndKey        string `json:"end_key"`
+	RegionCount   int    `json:"region_count"`
+	OperatorCount uint64 `json:"operator_count"`
+}
+
+// BalanceRegionHitStatus is how many times a store fails to be picked as the
+// source, or a pair of stores fails to be picked as the source and the
+// target. The target is 0 for the source only. The stores are skipped until
+// the record expires once the count reaches the threshold.
+type BalanceRegionHitStatus struct {
+	SourceStoreID uint64    `json:"source_store_id"`
+	TargetStoreID uint64    `json:"target_store_id,omitempty"`
+	Count         int       `json:"count"`
+	Skipped       bool      `json:"skipped"`
+	ExpireTime    time.Time `json:"expire_time"`
+}
+
+// BalanceRegionConfig is the config of the balance-region scheduler. It
+// balances the regions of all ranges if there is no range.
+type BalanceRegionConfig struct {
+	Ranges []*BalanceRegionRangeStatus `json:"ranges"`
+	Hits   []*BalanceRegionHitStatus   `json:"hits"`
+}
+
+// regionPicker picks the regions to move out of the source store.
+type regionPicker interface {
+	RandPendingRegion(storeID uint64, opts ...core.RegionOption) *core.RegionInfo
+	RandFollowerRegion(storeID uint64, opts ...core.RegionOption) *core.RegionInfo
+	RandLeaderRegion(storeID uint64, opts ...core.RegionOption) *core.RegionInfo
+}
+
 type balanceRegionScheduler struct {
 	*baseScheduler
 	name         string
 	selector     *selector.BalanceSelector
+	tierSelector *selector.BalanceSelector
 	opController *schedule.OperatorController
 	hitsCounter  *hitsStoreBuilder
 	counter      *prometheus.CounterVec
+	// rangesMu protects the progress of the ranges, which is read by the API.
+	rangesMu sync.RWMutex
+	ranges   []*balanceRegionRange
+	// rangeRegions is the regions in the ranges scanned at rangeScanTime.
+	rangeRegions  *core.RegionsInfo
+	rangeScanTime time.Time
 }
 
 // newBalanceRegionScheduler creates a scheduler that tends to keep regions on
@@ -74,8 +161,10 @@ func newBalanceRegionScheduler(opController *schedule.OperatorController, opts .
 	}
 	filters := []filter.Filter{
 		filter.StoreStateFilter{ActionScope: s.GetName(), MoveRegion: true},
+		filter.NewSchedulerDenyFilter(s.GetName()),
 	}
 	s.selector = selector.NewBalanceSelector(core.RegionKind, filters)
+	s.tierSelector = selector.NewTierBalanceSelector(core.RegionKind, filters)
 	return s
 }
 
@@ -89,6 +178,14 @@ func WithBalanceRegionCounter(counter *prometheus.CounterVec) BalanceRegionCreat
 	}
 }
 
+// withBalanceRegionRanges restricts the scheduler to the regions in the key
+// ranges.
+func withBalanceRegionRanges(ranges []*balanceRegionRange) BalanceRegionCreateOption {
+	return func(s *balanceRegionScheduler) {
+		s.ranges = ranges
+	}
+}
+
 // WithBalanceRegionName sets the name for the scheduler.
 func WithBalanceRegionName(name string) BalanceRegionCreateOption {
 	return func(s *balanceRegionScheduler) {
@@ -111,13 +208,154 @@ func (s *balanceRegionScheduler) IsScheduleAllowed(cluster schedule.Cluster) boo
 	return s.opController.OperatorCount(operator.OpRegion) < cluster.GetRegionScheduleLimit()
 }
 
+// RemoveStaleStores removes the hit records of the stores which do not exist,
+// and returns the number of the removed records.
+func (s *balanceRegionScheduler) RemoveStaleStores(exists func(storeID uint64) bool) int {
+	return s.hitsCounter.removeStaleStores(exists)
+}
+
+// GetConfig returns the ranges that the scheduler is restricted to, with the
+// progress of balancing them.
+func (s *balanceRegionScheduler) GetConfig() interface{} {
+	s.rangesMu.RLock()
+	defer s.rangesMu.RUnlock()
+	cfg := &BalanceRegionConfig{Ranges: make([]*BalanceRegionRangeStatus, 0, len(s.ranges))}
+	for _, r := range s.ranges {
+		cfg.Ranges = append(cfg.Ranges, &BalanceRegionRangeStatus{
+			StartKey:      string(core.HexRegionKey(r.startKey)),
+			EndKey:        string(core.HexRegionKey(r.endKey)),
+			RegionCount:   r.regionCount,
+			OperatorCount: r.operatorCount,
+		})
+	}
+	cfg.Hits = s.hitsCounter.statuses()
+	return cfg
+}
+
+// ResetHits clears the hit records, so that the skipped stores are picked
+// again.
+func (s *balanceRegionScheduler) ResetHits() {
+	s.hitsCounter.reset()
+}
+
 func (s *balanceRegionScheduler) Schedule(cluster schedule.Cluster) []*operator.Operator {
 	schedulerCounter.WithLabelValues(s.GetName(), "schedule").Inc()
 	stores := cluster.GetStores()
+	// The scores of the stores are of all regions, while only the regions in
+	// the ranges are moved, so the ranges are scattered as the cluster is
+	// balanced.
+	picker, sources := regionPicker(cluster), stores
+	if len(s.ranges) > 0 {
+		picker, sources = s.collectRangeRegions(cluster), nil
+		for _, store := range stores {
+			if s.rangeRegions.GetStoreRegionCount(store.GetID()) > 0 {
+				sources = append(sources, store)
+			}
+		}
+	}
+	if !cluster.IsMixedTier() {
+		return s.scheduleStores(cluster, picker, sources, s.selector, nil)
+	}
+	// Regions are balanced within each tier of mixed clusters, by the scoring
+	// function of the tier.
+	for _, typ := range []core.StoreType{core.StoreTypePerformance, core.StoreTypeStorage} {
+		tierFilter := filter.NewStoreTypeFilter(s.GetName(), typ)
+		if ops := s.scheduleStores(cluster, picker, sources, s.tierSelector, tierFilter); ops != nil {
+			return ops
+		}
+	}
+	return nil
+}
+
+// collectRangeRegions returns the regions overlapping the ranges, and updates
+// the region counts of the ranges. The ranges are scanned again only after
+// balanceRegionRangeScanInterval, since they may hold a large part of the
+// regions, and the picked regions are checked against the cluster.
+func (s *balanceRegionScheduler) collectRangeRegions(cluster schedule.Cluster) regionPicker {
+	if s.rangeRegions == nil || time.Since(s.rangeScanTime) >= balanceRegionRangeScanInterval {
+		regions := core.NewRegionsInfo()
+		counts := make([]int, len(s.ranges))
+		for i, r := range s.ranges {
+			for _, region := range cluster.ScanRegions(r.startKey, r.endKey, -1) {
+				regions.AddRegion(region)
+				counts[i]++
+			}
+		}
+		s.rangeRegions, s.rangeScanTime = regions, time.Now()
+		s.rangesMu.Lock()
+		for i, r := range s.ranges {
+			r.regionCount = counts[i]
+		}
+		s.rangesMu.Unlock()
+	}
+	return &rangeRegionPicker{cluster: cluster, regions: s.rangeRegions, ranges: s.ranges}
+}
+
+// rangeRegionPicker picks the regions from the ones scanned in the ranges, and
+// returns the current ones of the cluster, which are skipped if they move out
+// of the ranges or the store.
+type rangeRegionPicker struct {
+	cluster schedule.Cluster
+	regions *core.RegionsInfo
+	ranges  []*balanceRegionRange
+}
+
+func (p *rangeRegionPicker) current(region *core.RegionInfo, storeID uint64, opts []core.RegionOption) *core.RegionInfo {
+	if region == nil {
+		return nil
+	}
+	region = p.cluster.GetRegion(region.GetID())
+	if region == nil || region.GetStorePeer(storeID) == nil {
+		return nil
+	}
+	for _, opt := range opts {
+		if !opt(region) {
+			return nil
+		}
+	}
+	for _, r := range p.ranges {
+		if r.overlaps(region) {
+			return region
+		}
+	}
+	return nil
+}
+
+func (p *rangeRegionPicker) RandPendingRegion(storeID uint64, opts ...core.RegionOption) *core.RegionInfo {
+	return p.current(p.regions.RandPendingRegion(storeID, opts...), storeID, opts)
+}
 
+func (p *rangeRegionPicker) RandFollowerRegion(storeID uint64, opts ...core.RegionOption) *core.RegionInfo {
+	return p.current(p.regions.RandFollowerRegion(storeID, opts...), storeID, opts)
+}
+
+func (p *rangeRegionPicker) RandLeaderRegion(storeID uint64, opts ...core.RegionOption) *core.RegionInfo {
+	return p.current(p.regions.RandLeaderRegion(storeID, opts...), storeID, opts)
+}
+
+// recordRangeOperator counts the operator in the progress of the first range
+// that the region overlaps.
+func (s *balanceRegionScheduler) recordRangeOperator(region *core.RegionInfo) {
+	s.rangesMu.Lock()
+	defer s.rangesMu.Unlock()
+	for _, r := range s.ranges {
+		if r.overlaps(region) {
+			r.operatorCount++
+			return
+		}
+	}
+}
+
+// scheduleStores moves a region picked by the picker from the source store
+// with the highest score to another store. The tier filter keeps both stores
+// in a tier if it is not nil.
+func (s *balanceRegionScheduler) scheduleStores(cluster schedule.Cluster, picker regionPicker, sources []*core.StoreInfo, storeSelector *selector.BalanceSelector, tierFilter filter.Filter) []*operator.Operator {
 	// source is the store with highest region score in the list that can be selected as balance source.
-	f := s.hitsCounter.buildSourceFilter(s.GetName(), cluster)
-	source := s.selector.SelectSource(cluster, stores, f)
+	filters := []filter.Filter{s.hitsCounter.buildSourceFilter(s.GetName(), cluster)}
+	if tierFilter != nil {
+		filters = append(filters, tierFilter)
+	}
+	source := storeSelector.SelectSource(cluster, sources, filters...)
 	if source == nil {
 		schedulerCounter.WithLabelValues(s.GetName(), "no-source-store").Inc()
 		// Unlike the balanceLeaderScheduler, we don't need to clear the taintCache
@@ -135,14 +373,14 @@ func (s *balanceRegionScheduler) Schedule(cluster schedule.Cluster) []*operator.
 	for i := 0; i < balanceRegionRetryLimit; i++ {
 		// Priority picks the region that has a pending peer.
 		// Pending region may means the disk is overload, remove the pending region firstly.
-		region := cluster.RandPendingRegion(sourceID, core.HealthRegionAllowPending())
+		region := picker.RandPendingRegion(sourceID, core.HealthRegionAllowPending())
 		if region == nil {
 			// Then picks the region that has a follower in the source store.
-			region = cluster.RandFollowerRegion(sourceID, core.HealthRegion())
+			region = picker.RandFollowerRegion(sourceID, core.HealthRegion())
 		}
 		if region == nil {
 			// Last, picks the region has the leader in the source store.
-			region = cluster.RandLeaderRegion(sourceID, core.HealthRegion())
+			region = picker.RandLeaderRegion(sourceID, core.HealthRegion())
 		}
 		if region == nil {
 			schedulerCounter.WithLabelValues(s.GetName(), "no-region").Inc()
@@ -168,8 +406,9 @@ func (s *balanceRegionScheduler) Schedule(cluster schedule.Cluster) []*operator.
 		}
 
 		oldPeer := region.GetStorePeer(sourceID)
-		if op := s.transferPeer(cluster, region, oldPeer); op != nil {
+		if op := s.transferPeer(cluster, region, oldPeer, tierFilter); op != nil {
 			schedulerCounter.WithLabelValues(s.GetName(), "new-operator").Inc()
+			s.recordRangeOperator(region)
 			return []*operator.Operator{op}
 		}
 	}
@@ -177,7 +416,7 @@ func (s *balanceRegionScheduler) Schedule(cluster schedule.Cluster) []*operator.
 }
 
 // transferPeer selects the best store to create a new peer to replace the old peer.
-func (s *balanceRegionScheduler) transferPeer(cluster schedule.Cluster, region *core.RegionInfo, oldPeer *metapb.Peer) *operator.Operator {
+func (s *balanceRegionScheduler) transferPeer(cluster schedule.Cluster, region *core.RegionInfo, oldPeer *metapb.Peer, tierFilter filter.Filter) *operator.Operator {
 	// scoreGuard guarantees that the distinct score will not decrease.
 	stores := cluster.GetRegionStores(region)
 	sourceStoreID := oldPeer.GetStoreId()
@@ -185,10 +424,25 @@ func (s *balanceRegionScheduler) transferPeer(cluster schedule.Cluster, region *
 	if source == nil {
 		log.Error("failed to get the source store", zap.Uint64("store-id", sourceStoreID))
 	}
+	pinFilter := filter.NewRegionPinFilter(s.GetName(), cluster.GetRegionPin(region.GetID()), false)
+	if source != nil && filter.Source(cluster, source, []filter.Filter{pinFilter}) {
+		schedulerCounter.WithLabelValues(s.GetName(), "region-pinned").Inc()
+		return nil
+	}
+	intentFilter := filter.NewPlacementIntentFilter(s.GetName(), cluster.GetPlacementIntent(region.GetID()), false)
+	if source != nil && filter.Source(cluster, source, []filter.Filter{intentFilter}) {
+		schedulerCounter.WithLabelValues(s.GetName(), "placement-intent").Inc()
+		return nil
+	}
 	scoreGuard := filter.NewDistinctScoreFilter(s.GetName(), cluster.GetLocationLabels(), stores, source)
 	hitsFilter := s.hitsCounter.buildTargetFilter(s.GetName(), cluster, source)
 	checker := checker.NewReplicaChecker(cluster, nil, s.GetName())
-	storeID, _ := checker.SelectBestReplacementStore(region, oldPeer, scoreGuard, hitsFilter)
+	denyFilter := filter.NewSchedulerDenyFilter(s.GetName())
+	filters := []filter.Filter{scoreGuard, hitsFilter, denyFilter, pinFilter, intentFilter}
+	if tierFilter != nil {
+		filters = append(filters, tierFilter)
+	}
+	storeID, _ := checker.SelectBestReplacementStore(region, oldPeer, filters...)
 	if storeID == 0 {
 		schedulerCounter.WithLabelValues(s.GetName(), "no-replacement").Inc()
 		s.hitsCounter.put(source, nil)
@@ -241,8 +495,15 @@ func (s *balanceRegionScheduler) transferPeer(cluster schedule.Cluster, region *
 type record struct {
 	lastTime time.Time
 	count    int
+	sourceID uint64
+	targetID uint64
 }
+
+// hitsStoreBuilder records how many times the stores fail to be picked as the
+// source or the target. It is locked since the records of the removed stores
+// are cleaned up outside the scheduling.
 type hitsStoreBuilder struct {
+	sync.Mutex
 	hits      map[string]*record
 	ttl       time.Duration
 	threshold int
@@ -272,6 +533,8 @@ func (h *hitsStoreBuilder) filter(source, target *core.StoreInfo) bool {
 	if key == "" {
 		return false
 	}
+	h.Lock()
+	defer h.Unlock()
 	if item, ok := h.hits[key]; ok {
 		if time.Since(item.lastTime) > h.ttl {
 			delete(h.hits, key)
@@ -286,6 +549,8 @@ func (h *hitsStoreBuilder) filter(source, target *core.StoreInfo) bool {
 
 func (h *hitsStoreBuilder) remove(source, target *core.StoreInfo) {
 	key := h.getKey(source, target)
+	h.Lock()
+	defer h.Unlock()
 	if _, ok := h.hits[key]; ok && key != "" {
 		delete(h.hits, key)
 	}
@@ -296,6 +561,8 @@ func (h *hitsStoreBuilder) put(source, target *core.StoreInfo) {
 	if key == "" {
 		return
 	}
+	h.Lock()
+	defer h.Unlock()
 	if item, ok := h.hits[key]; ok {
 		if time.Since(item.lastTime) >= h.ttl {
 			item.count = 0
@@ -304,11 +571,61 @@ func (h *hitsStoreBuilder) put(source, target *core.StoreInfo) {
 		}
 		item.lastTime = time.Now()
 	} else {
-		item := &record{lastTime: time.Now()}
+		item := &record{lastTime: time.Now(), sourceID: source.GetID()}
+		if target != nil {
+			item.targetID = target.GetID()
+		}
 		h.hits[key] = item
 	}
 }
 
+// statuses returns the records which are not expired, ordered by the stores.
+func (h *hitsStoreBuilder) statuses() []*BalanceRegionHitStatus {
+	h.Lock()
+	defer h.Unlock()
+	statuses := make([]*BalanceRegionHitStatus, 0, len(h.hits))
+	for _, item := range h.hits {
+		if time.Since(item.lastTime) > h.ttl {
+			continue
+		}
+		statuses = append(statuses, &BalanceRegionHitStatus{
+			SourceStoreID: item.sourceID,
+			TargetStoreID: item.targetID,
+			Count:         item.count,
+			Skipped:       item.count >= h.threshold,
+			ExpireTime:    item.lastTime.Add(h.ttl),
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].SourceStoreID != statuses[j].SourceStoreID {
+			return statuses[i].SourceStoreID < statuses[j].SourceStoreID
+		}
+		return statuses[i].TargetStoreID < statuses[j].TargetStoreID
+	})
+	return statuses
+}
+
+func (h *hitsStoreBuilder) reset() {
+	h.Lock()
+	defer h.Unlock()
+	h.hits = make(map[string]*record)
+}
+
+// removeStaleStores removes the records of the stores which do not exist, and
+// returns the number of the removed records.
+func (h *hitsStoreBuilder) removeStaleStores(exists func(storeID uint64) bool) int {
+	h.Lock()
+	defer h.Unlock()
+	var count int
+	for key, item := range h.hits {
+		if !exists(item.sourceID) || (item.targetID != 0 && !exists(item.targetID)) {
+			delete(h.hits, key)
+			count++
+		}
+	}
+	return count
+}
+
 func (h *hitsStoreBuilder) buildSourceFilter(scope string, cluster schedule.Cluster) filter.Filter {
 	f := filter.NewBlacklistStoreFilter(scope, filter.BlacklistSource)
 	for _, source := range cluster.GetStores() {
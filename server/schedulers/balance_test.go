@@ -17,7 +17,9 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"time"
 
+	"github.com/gogo/protobuf/proto"
 	. "github.com/pingcap/check"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
@@ -104,6 +106,41 @@ func (s *testBalanceSpeedSuite) TestShouldBalance(c *C) {
 	}
 }
 
+func (s *testBalanceSpeedSuite) TestShouldBalanceWithInfluence(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	opt.TolerantSizeRatio = 1
+	tc := mockcluster.NewCluster(opt)
+	tc.AddLeaderStore(1, 20)
+	tc.AddLeaderStore(2, 10)
+	tc.AddLeaderRegion(1, 1, 2)
+	region := tc.GetRegion(1).Clone(core.SetApproximateSize(10))
+	tc.PutRegion(region)
+	source, target := tc.GetStore(1), tc.GetStore(2)
+
+	newInfluence := func(target *operator.StoreInfluence) operator.OpInfluence {
+		return operator.OpInfluence{StoresInfluence: map[uint64]*operator.StoreInfluence{2: target}}
+	}
+	// Pending leader transfers whose sizes are small still count.
+	c.Assert(shouldBalance(tc, source, target, region, core.LeaderKind, newInfluence(&operator.StoreInfluence{LeaderCount: 8, LeaderSize: 8})), IsTrue)
+	c.Assert(shouldBalance(tc, source, target, region, core.LeaderKind, newInfluence(&operator.StoreInfluence{LeaderCount: 9, LeaderSize: 9})), IsFalse)
+
+	// Pending read flow to the target.
+	stats := proto.Clone(source.GetStoreStats()).(*pdpb.StoreStats)
+	stats.BytesRead = 10000
+	stats.Interval = &pdpb.TimeInterval{StartTimestamp: 0, EndTimestamp: 10}
+	source = source.Clone(core.SetStoreStats(stats))
+	region = region.Clone(core.SetReadBytes(6000), core.SetReportInterval(60))
+	c.Assert(shouldBalance(tc, source, target, region, core.LeaderKind, newInfluence(&operator.StoreInfluence{BytesReadRate: 800})), IsTrue)
+	c.Assert(shouldBalance(tc, source, target, region, core.LeaderKind, newInfluence(&operator.StoreInfluence{BytesReadRate: 850})), IsFalse)
+	// Write flow does not move with leaders.
+	c.Assert(shouldBalance(tc, source, target, region, core.LeaderKind, newInfluence(&operator.StoreInfluence{BytesWriteRate: 850})), IsTrue)
+
+	// The counts are weighted as the scores are.
+	tc.UpdateStoreLeaderWeight(2, 4)
+	target = tc.GetStore(2)
+	c.Assert(shouldBalance(tc, tc.GetStore(1), target, region, core.LeaderKind, newInfluence(&operator.StoreInfluence{LeaderCount: 9, LeaderSize: 9})), IsTrue)
+}
+
 func (s *testBalanceSpeedSuite) TestBalanceLimit(c *C) {
 	opt := mockoption.NewScheduleOptions()
 	tc := mockcluster.NewCluster(opt)
@@ -255,6 +292,34 @@ func (s *testBalanceLeaderSchedulerSuite) TestLeaderWeight(c *C) {
 	testutil.CheckTransferLeader(c, s.schedule()[0], operator.OpBalance, 1, 3)
 }
 
+func (s *testBalanceLeaderSchedulerSuite) TestMaxLeaderFraction(c *C) {
+	// Stores:	1	2	3	4
+	// Leaders:    20      10      10      10
+	// Weight:      1       1       1       2
+	// Region1:     L       F       F       F
+
+	s.tc.AddLeaderStore(1, 20)
+	s.tc.AddLeaderStore(2, 10)
+	s.tc.AddLeaderStore(3, 10)
+	s.tc.AddLeaderStore(4, 10)
+	s.tc.UpdateStoreLeaderWeight(4, 2)
+	s.tc.AddLeaderRegion(1, 1, 2, 3, 4)
+	testutil.CheckTransferLeader(c, s.schedule()[0], operator.OpBalance, 1, 4)
+
+	// No store can hold more than 10 leaders.
+	s.tc.MaxLeaderFractionPerStore = 0.2
+	c.Assert(s.schedule(), HasLen, 0)
+
+	// A store can hold at most 15 leaders.
+	s.tc.MaxLeaderFractionPerStore = 0.3
+	testutil.CheckTransferLeader(c, s.schedule()[0], operator.OpBalance, 1, 4)
+	// Store 4 has the lowest score, but it cannot hold more than 16.8 leaders.
+	s.tc.UpdateLeaderCount(4, 16)
+	ops := s.schedule()
+	c.Assert(ops, HasLen, 1)
+	c.Assert(ops[0].Step(0).(operator.TransferLeader).ToStore, Not(Equals), uint64(4))
+}
+
 func (s *testBalanceLeaderSchedulerSuite) TestBalanceSelector(c *C) {
 	// Stores:     1    2    3    4
 	// Leaders:    1    2    3   16
@@ -356,6 +421,139 @@ func (s *testBalanceRegionSchedulerSuite) TestBalance(c *C) {
 	c.Assert(sb.Schedule(tc), NotNil)
 }
 
+func (s *testBalanceRegionSchedulerSuite) TestKeyRanges(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	tc := mockcluster.NewCluster(opt)
+	oc := schedule.NewOperatorController(nil, nil)
+
+	_, err := schedule.CreateScheduler("balance-region", oc, "61")
+	c.Assert(err, NotNil)
+	_, err = schedule.CreateScheduler("balance-region", oc, "62", "61")
+	c.Assert(err, NotNil)
+	_, err = schedule.CreateScheduler("balance-region", oc, "c", "d")
+	c.Assert(err, NotNil)
+	sb, err := schedule.CreateScheduler("balance-region", oc, "63", "64", "78", "")
+	c.Assert(err, IsNil)
+
+	opt.SetMaxReplicas(1)
+	tc.AddRegionStore(1, 6)
+	tc.AddRegionStore(2, 8)
+	tc.AddRegionStore(3, 8)
+	tc.AddRegionStore(4, 16)
+	// Only the region in the ranges is moved, even if the store with the most
+	// regions is store 4.
+	tc.AddLeaderRegionWithRange(1, "a", "c", 4)
+	tc.AddLeaderRegionWithRange(2, "c", "e", 2)
+	testutil.CheckTransferPeerWithLeaderTransfer(c, sb.Schedule(tc)[0], operator.OpBalance, 2, 1)
+	cfg := sb.(*balanceRegionScheduler).GetConfig().(*BalanceRegionConfig)
+	c.Assert(cfg.Ranges, DeepEquals, []*BalanceRegionRangeStatus{
+		{StartKey: "63", EndKey: "64", RegionCount: 1, OperatorCount: 1},
+		{StartKey: "78", EndKey: "", RegionCount: 0, OperatorCount: 0},
+	})
+
+	// The region moving out of the ranges is skipped before the ranges are
+	// scanned again.
+	tc.AddLeaderRegionWithRange(2, "e", "f", 2)
+	c.Assert(sb.Schedule(tc), IsNil)
+	cfg = sb.(*balanceRegionScheduler).GetConfig().(*BalanceRegionConfig)
+	c.Assert(cfg.Ranges[0].RegionCount, Equals, 1)
+
+	// No region is in the ranges.
+	sb.(*balanceRegionScheduler).rangeScanTime = time.Time{}
+	c.Assert(sb.Schedule(tc), IsNil)
+	cfg = sb.(*balanceRegionScheduler).GetConfig().(*BalanceRegionConfig)
+	c.Assert(cfg.Ranges[0].RegionCount, Equals, 0)
+}
+
+func (s *testBalanceRegionSchedulerSuite) TestMaxStoreRegionCount(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	tc := mockcluster.NewCluster(opt)
+	oc := schedule.NewOperatorController(nil, nil)
+
+	sb, err := schedule.CreateScheduler("balance-region", oc)
+	c.Assert(err, IsNil)
+
+	opt.SetMaxReplicas(1)
+
+	tc.AddRegionStore(1, 4)
+	tc.AddRegionStore(2, 6)
+	tc.AddRegionStore(3, 8)
+	tc.AddRegionStore(4, 16)
+	tc.AddLeaderRegion(1, 4)
+	testutil.CheckTransferPeerWithLeaderTransfer(c, sb.Schedule(tc)[0], operator.OpBalance, 4, 1)
+
+	// Store 1 reaches the limit relative to its capacity.
+	opt.MaxStoreRegionCountPerGB = 10
+	stats := &pdpb.StoreStats{Capacity: 100 * (1 << 20), Available: 100 * (1 << 20)}
+	tc.PutStore(tc.GetStore(1).Clone(core.SetStoreStats(stats)))
+	testutil.CheckTransferPeerWithLeaderTransfer(c, sb.Schedule(tc)[0], operator.OpBalance, 4, 2)
+	// Store 2 and 3 reach the absolute limit.
+	opt.MaxStoreRegionCount = 6
+	c.Assert(sb.Schedule(tc), IsNil)
+}
+
+func (s *testBalanceRegionSchedulerSuite) TestDeniedStores(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	tc := mockcluster.NewCluster(opt)
+	oc := schedule.NewOperatorController(nil, nil)
+
+	sb, err := schedule.CreateScheduler("balance-region", oc)
+	c.Assert(err, IsNil)
+
+	opt.SetMaxReplicas(1)
+
+	tc.AddRegionStore(1, 6)
+	tc.AddRegionStore(2, 8)
+	tc.AddRegionStore(3, 9)
+	tc.AddRegionStore(4, 16)
+	tc.AddLeaderRegion(1, 4)
+
+	// Store 1 is denied as the target.
+	opt.SchedulerDeniedStores = map[string][]uint64{sb.GetName(): {1}}
+	testutil.CheckTransferPeerWithLeaderTransfer(c, sb.Schedule(tc)[0], operator.OpBalance, 4, 2)
+	// Store 4 is denied as the source.
+	opt.SchedulerDeniedStores[sb.GetName()] = []uint64{4}
+	c.Assert(sb.Schedule(tc), IsNil)
+	// The deny list of other schedulers does not matter.
+	opt.SchedulerDeniedStores = map[string][]uint64{"balance-leader-scheduler": {1, 4}}
+	testutil.CheckTransferPeerWithLeaderTransfer(c, sb.Schedule(tc)[0], operator.OpBalance, 4, 1)
+}
+
+func (s *testBalanceRegionSchedulerSuite) TestTierScore(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	tc := mockcluster.NewCluster(opt)
+	oc := schedule.NewOperatorController(nil, nil)
+
+	sb, err := schedule.CreateScheduler("balance-region", oc)
+	c.Assert(err, IsNil)
+
+	opt.SetMaxReplicas(1)
+
+	performance := map[string]string{core.StoreTypeLabelKey: string(core.StoreTypePerformance)}
+	storage := map[string]string{core.StoreTypeLabelKey: string(core.StoreTypeStorage)}
+	tc.AddLabelsStore(1, 10, performance)
+	tc.AddLabelsStore(2, 10, performance)
+	tc.AddLabelsStore(3, 40, storage)
+	tc.AddLabelsStore(4, 50, storage)
+	// Store 4 holds more regions but has 4 times the capacity of store 3.
+	store := tc.GetStore(4)
+	stats := proto.Clone(store.GetStoreStats()).(*pdpb.StoreStats)
+	stats.Capacity *= 4
+	stats.Available = stats.Capacity - uint64(store.GetRegionSize())<<20
+	tc.PutStore(store.Clone(core.SetStoreStats(stats)))
+	tc.AddLeaderRegion(1, 3)
+	tc.AddLeaderRegion(2, 4)
+
+	// The regions are moved within the storage tier, from the store with less
+	// free capacity in proportion.
+	testutil.CheckTransferPeer(c, sb.Schedule(tc)[0], operator.OpBalance, 3, 4)
+
+	// The performance tier is balanced by the flow as well.
+	tc.AddLeaderRegion(3, 2)
+	tc.UpdateStorageWrittenBytes(2, 100*(1<<20)*10)
+	testutil.CheckTransferPeerWithLeaderTransfer(c, sb.Schedule(tc)[0], operator.OpBalance, 2, 1)
+}
+
 func (s *testBalanceRegionSchedulerSuite) TestReplicas3(c *C) {
 	opt := mockoption.NewScheduleOptions()
 	tc := mockcluster.NewCluster(opt)
@@ -425,6 +623,33 @@ func (s *testBalanceRegionSchedulerSuite) TestReplicas3(c *C) {
 	c.Assert(sb.Schedule(tc), IsNil)
 }
 
+func (s *testBalanceRegionSchedulerSuite) TestHitsRemoveStaleStores(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	tc := mockcluster.NewCluster(opt)
+	for i := uint64(1); i <= 3; i++ {
+		tc.AddRegionStore(i, 1)
+	}
+	hit := newHitsStoreBuilder(hitsStoreTTL, hitsStoreCountThreshold)
+	hit.put(tc.GetStore(1), nil)
+	hit.put(tc.GetStore(1), tc.GetStore(2))
+	hit.put(tc.GetStore(2), tc.GetStore(3))
+	hit.put(tc.GetStore(3), nil)
+
+	statuses := hit.statuses()
+	c.Assert(statuses, HasLen, 4)
+	c.Assert(statuses[0].SourceStoreID, Equals, uint64(1))
+	c.Assert(statuses[0].TargetStoreID, Equals, uint64(0))
+	c.Assert(statuses[1].TargetStoreID, Equals, uint64(2))
+	c.Assert(statuses[1].Skipped, IsFalse)
+
+	exists := func(storeID uint64) bool { return storeID != 3 }
+	c.Assert(hit.removeStaleStores(exists), Equals, 2)
+	c.Assert(hit.hits, HasLen, 2)
+	c.Assert(hit.removeStaleStores(exists), Equals, 0)
+	hit.reset()
+	c.Assert(hit.statuses(), HasLen, 0)
+}
+
 func (s *testBalanceRegionSchedulerSuite) TestReplicas5(c *C) {
 	opt := mockoption.NewScheduleOptions()
 	tc := mockcluster.NewCluster(opt)
@@ -654,6 +879,27 @@ func (s *testReplicaCheckerSuite) TestBasic(c *C) {
 	testutil.CheckTransferPeer(c, rc.Check(region), operator.OpReplica, 3, 1)
 }
 
+func (s *testReplicaCheckerSuite) TestMaxStoreRegionCount(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	tc := mockcluster.NewCluster(opt)
+
+	rc := checker.NewReplicaChecker(tc, namespace.DefaultClassifier)
+
+	tc.AddRegionStore(1, 4)
+	tc.AddRegionStore(2, 3)
+	tc.AddRegionStore(3, 2)
+	tc.AddRegionStore(4, 1)
+	tc.AddLeaderRegion(1, 1, 2)
+	region := tc.GetRegion(1)
+	testutil.CheckAddPeer(c, rc.Check(region), operator.OpReplica, 4)
+
+	// Store 4 has the fewest regions but too little capacity to hold more.
+	opt.MaxStoreRegionCountPerGB = 10
+	stats := &pdpb.StoreStats{Capacity: 100 * (1 << 20), Available: 100 * (1 << 20)}
+	tc.PutStore(tc.GetStore(4).Clone(core.SetStoreStats(stats)))
+	testutil.CheckAddPeer(c, rc.Check(region), operator.OpReplica, 3)
+}
+
 func (s *testReplicaCheckerSuite) TestLostStore(c *C) {
 	opt := mockoption.NewScheduleOptions()
 	tc := mockcluster.NewCluster(opt)
@@ -1081,6 +1327,12 @@ func (s *testBalanceHotReadRegionSchedulerSuite) TestBalance(c *C) {
 	r := tc.RandHotRegionFromStore(2, statistics.ReadFlow)
 	c.Assert(r, NotNil)
 	c.Assert(r.GetID(), Equals, uint64(2))
+	// check picking with options
+	r = tc.RandHotRegionFromStore(2, statistics.ReadFlow, core.HealthRegion(), core.LeaderInStore(2))
+	c.Assert(r, NotNil)
+	c.Assert(r.GetID(), Equals, uint64(2))
+	c.Assert(tc.RandHotRegionFromStore(2, statistics.ReadFlow, core.LeaderInStore(1)), IsNil)
+	c.Assert(tc.RandHotRegionFromStore(2, statistics.ReadFlow, core.RegionSizeAtLeast(r.GetApproximateSize()+1)), IsNil)
 	// check hot items
 	stats := tc.HotSpotCache.RegionStats(statistics.ReadFlow)
 	c.Assert(len(stats), Equals, 2)
@@ -1116,6 +1368,54 @@ func (s *testBalanceHotReadRegionSchedulerSuite) TestBalance(c *C) {
 	hb.Schedule(tc)
 }
 
+func (s *testBalanceHotReadRegionSchedulerSuite) TestWarmRegion(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	tc := mockcluster.NewCluster(opt)
+	hb, err := schedule.CreateScheduler("hot-read-region", schedule.NewOperatorController(nil, nil))
+	c.Assert(err, IsNil)
+
+	// Store 3 is a storage store, which is the best target by flow.
+	tc.AddRegionStore(1, 3)
+	tc.AddRegionStore(2, 2)
+	tc.AddLabelsStore(3, 2, map[string]string{core.StoreTypeLabelKey: string(core.StoreTypeStorage)})
+	tc.AddRegionStore(4, 2)
+	tc.AddRegionStore(5, 0)
+	tc.UpdateStorageReadBytes(1, 75*1024*1024)
+	tc.UpdateStorageReadBytes(2, 45*1024*1024)
+	tc.UpdateStorageReadBytes(3, 45*1024*1024)
+	tc.UpdateStorageReadBytes(4, 60*1024*1024)
+	tc.UpdateStorageReadBytes(5, 0)
+	tc.AddLeaderRegionWithReadInfo(1, 1, 512*1024*statistics.RegionHeartBeatReportInterval, statistics.RegionHeartBeatReportInterval, 2, 3)
+	tc.AddLeaderRegionWithReadInfo(2, 2, 512*1024*statistics.RegionHeartBeatReportInterval, statistics.RegionHeartBeatReportInterval, 1, 3)
+	tc.AddLeaderRegionWithReadInfo(3, 1, 512*1024*statistics.RegionHeartBeatReportInterval, statistics.RegionHeartBeatReportInterval, 2, 3)
+	opt.HotRegionCacheHitsThreshold = 0
+	testutil.CheckTransferLeader(c, hb.Schedule(tc)[0], operator.OpHotRegion, 1, 3)
+
+	// The leaders of the warm regions are not moved to the storage store.
+	for i := uint64(0); i < opt.WarmRegionAccessThreshold; i++ {
+		for id := uint64(1); id <= 3; id++ {
+			tc.AccessSketch.Observe(id)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		for _, op := range hb.Schedule(tc) {
+			// The leader may be transferred to the store temporarily when the
+			// leader peer is moved.
+			if tl, ok := op.Step(0).(operator.TransferLeader); ok && op.Len() == 1 {
+				c.Assert(tl.ToStore, Not(Equals), uint64(3))
+			}
+			for j := 0; j < op.Len(); j++ {
+				switch step := op.Step(j).(type) {
+				case operator.AddPeer:
+					c.Assert(step.ToStore, Not(Equals), uint64(3))
+				case operator.AddLearner:
+					c.Assert(step.ToStore, Not(Equals), uint64(3))
+				}
+			}
+		}
+	}
+}
+
 var _ = Suite(&testBalanceHotCacheSuite{})
 
 type testBalanceHotCacheSuite struct{}
@@ -1321,3 +1621,70 @@ func (s *testScatterRangeLeaderSuite) TestBalanceWhenRegionNotHeartbeat(c *C) {
 		schedule.ApplyOperator(tc, ops[0])
 	}
 }
+
+var _ = Suite(&testRebalanceStoreSuite{})
+
+type testRebalanceStoreSuite struct{}
+
+func (s *testRebalanceStoreSuite) TestRebalanceLeader(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	tc := mockcluster.NewCluster(opt)
+	oc := schedule.NewOperatorController(tc, mockhbstream.NewHeartbeatStream())
+
+	// Stores:     1    2    3    4
+	// Leaders:    40   40   40   40
+	// Region:     L    F    F    F, and so on.
+	for i := uint64(1); i <= 4; i++ {
+		tc.AddLeaderStore(i, 40)
+	}
+	for i := uint64(1); i <= 16; i++ {
+		leader := (i-1)%4 + 1
+		var followers []uint64
+		for j := uint64(1); j <= 4; j++ {
+			if j != leader {
+				followers = append(followers, j)
+			}
+		}
+		tc.AddLeaderRegion(i, leader, followers...)
+	}
+	c.Assert(RebalanceStore(tc, oc, 4, 10), HasLen, 0)
+
+	// Store 4 is expected to hold more leaders after its weight is doubled.
+	tc.UpdateStoreLeaderWeight(4, 2)
+	ops := RebalanceStore(tc, oc, 4, 2)
+	c.Assert(ops, HasLen, 2)
+	for _, op := range ops {
+		c.Assert(op.Step(0).(operator.TransferLeader).ToStore, Equals, uint64(4))
+		c.Assert(oc.GetOperator(op.RegionID()), Equals, op)
+	}
+	c.Assert(ops[0].RegionID(), Not(Equals), ops[1].RegionID())
+
+	// No more operators are added once the schedule limit is reached.
+	opt.LeaderScheduleLimit = 2
+	c.Assert(RebalanceStore(tc, oc, 4, 10), HasLen, 0)
+	opt.LeaderScheduleLimit = 3
+	c.Assert(RebalanceStore(tc, oc, 4, 10), HasLen, 1)
+}
+
+func (s *testRebalanceStoreSuite) TestRebalanceRegion(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	tc := mockcluster.NewCluster(opt)
+	oc := schedule.NewOperatorController(tc, mockhbstream.NewHeartbeatStream())
+	opt.SetMaxReplicas(1)
+
+	for i := uint64(1); i <= 4; i++ {
+		tc.AddRegionStore(i, 100)
+	}
+	for i := uint64(1); i <= 10; i++ {
+		tc.AddLeaderRegion(i, 4)
+	}
+	c.Assert(RebalanceStore(tc, oc, 4, 10), HasLen, 0)
+
+	// Store 4 is expected to hold less regions after its weight is halved.
+	tc.UpdateStoreRegionWeight(4, 0.5)
+	ops := RebalanceStore(tc, oc, 4, 3)
+	c.Assert(ops, HasLen, 3)
+	for _, op := range ops {
+		testutil.CheckTransferPeerWithLeaderTransferFrom(c, op, operator.OpBalance, 4)
+	}
+}
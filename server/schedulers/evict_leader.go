@@ -88,7 +88,8 @@ func (s *evictLeaderScheduler) Schedule(cluster schedule.Cluster) []*operator.Op
 		schedulerCounter.WithLabelValues(s.GetName(), "no-leader").Inc()
 		return nil
 	}
-	target := s.selector.SelectTarget(cluster, cluster.GetFollowerStores(region))
+	f := filter.NewLeaderFractionFilter(s.GetName(), cluster.GetStores())
+	target := s.selector.SelectTarget(cluster, cluster.GetFollowerStores(region), f)
 	if target == nil {
 		schedulerCounter.WithLabelValues(s.GetName(), "no-target-store").Inc()
 		return nil
@@ -134,16 +134,35 @@ func (h *balanceHotRegionsScheduler) IsScheduleAllowed(cluster schedule.Cluster)
 }
 
 func (h *balanceHotRegionsScheduler) allowBalanceLeader(cluster schedule.Cluster) bool {
-	return h.opController.OperatorCount(operator.OpHotRegion) < minUint64(h.leaderLimit, cluster.GetHotRegionScheduleLimit()) &&
+	return h.allowHotOperator(cluster, h.leaderLimit) &&
 		h.opController.OperatorCount(operator.OpLeader) < cluster.GetLeaderScheduleLimit()
 }
 
 func (h *balanceHotRegionsScheduler) allowBalanceRegion(cluster schedule.Cluster) bool {
-	return h.opController.OperatorCount(operator.OpHotRegion) < minUint64(h.peerLimit, cluster.GetHotRegionScheduleLimit())
+	return h.allowHotOperator(cluster, h.peerLimit)
+}
+
+// allowHotOperator checks the hot region operators scheduled in the namespace
+// of the cluster against the limit of the namespace, so that the hotspots of a
+// namespace do not take up the limit of the others. The operators of all the
+// namespaces are also checked against the limit of the whole cluster.
+func (h *balanceHotRegionsScheduler) allowHotOperator(cluster schedule.Cluster, limit uint64) bool {
+	hotLimit := cluster.GetHotRegionScheduleLimit()
+	namespace := cluster.GetNamespace()
+	if namespace == "" && h.opController.OperatorCount(operator.OpHotRegion) >= hotLimit {
+		return false
+	}
+	return h.opController.OperatorCountByNamespace(namespace, operator.OpHotRegion) < minUint64(limit, hotLimit)
 }
 
 func (h *balanceHotRegionsScheduler) Schedule(cluster schedule.Cluster) []*operator.Operator {
 	schedulerCounter.WithLabelValues(h.GetName(), "schedule").Inc()
+	// The whole cluster is checked before scheduling, and the namespace is
+	// checked here.
+	if cluster.GetNamespace() != "" && !h.IsScheduleAllowed(cluster) {
+		schedulerCounter.WithLabelValues(h.GetName(), "namespace-limit").Inc()
+		return nil
+	}
 	return h.dispatch(h.types[h.r.Int()%len(h.types)], cluster)
 }
 
@@ -274,7 +293,7 @@ func (h *balanceHotRegionsScheduler) balanceByPeer(cluster schedule.Cluster, sto
 		return nil, nil, nil
 	}
 
-	srcStoreID := h.selectSrcStore(storesStat)
+	srcStoreID := h.selectSrcStore(cluster, storesStat)
 	if srcStoreID == 0 {
 		return nil, nil, nil
 	}
@@ -292,7 +311,7 @@ func (h *balanceHotRegionsScheduler) balanceByPeer(cluster schedule.Cluster, sto
 			continue
 		}
 
-		if isRegionUnhealthy(srcRegion) {
+		if !core.SelectRegion(srcRegion, hotRegionOptions...) {
 			schedulerCounter.WithLabelValues(h.GetName(), "unhealthy-replica").Inc()
 			continue
 		}
@@ -307,11 +326,19 @@ func (h *balanceHotRegionsScheduler) balanceByPeer(cluster schedule.Cluster, sto
 		if srcStore == nil {
 			log.Error("failed to get the source store", zap.Uint64("store-id", srcStoreID))
 		}
+		intentFilter := filter.NewPlacementIntentFilter(h.GetName(), cluster.GetPlacementIntent(srcRegion.GetID()), false)
+		if srcStore != nil && filter.Source(cluster, srcStore, []filter.Filter{intentFilter}) {
+			schedulerCounter.WithLabelValues(h.GetName(), "placement-intent").Inc()
+			continue
+		}
 		filters := []filter.Filter{
 			filter.StoreStateFilter{ActionScope: h.GetName(), MoveRegion: true},
 			filter.NewExcludedFilter(h.GetName(), srcRegion.GetStoreIds(), srcRegion.GetStoreIds()),
 			filter.NewDistinctScoreFilter(h.GetName(), cluster.GetLocationLabels(), cluster.GetRegionStores(srcRegion), srcStore),
+			filter.NewSchedulerDenyFilter(h.GetName()),
+			intentFilter,
 		}
+		filters = append(filters, warmRegionFilters(cluster, h.GetName(), srcRegion)...)
 		candidateStoreIDs := make([]uint64, 0, len(stores))
 		for _, store := range stores {
 			if filter.Target(cluster, store, filters) {
@@ -350,11 +377,16 @@ func (h *balanceHotRegionsScheduler) balanceByLeader(cluster schedule.Cluster, s
 		return nil, nil
 	}
 
-	srcStoreID := h.selectSrcStore(storesStat)
+	srcStoreID := h.selectSrcStore(cluster, storesStat)
 	if srcStoreID == 0 {
 		return nil, nil
 	}
 
+	filters := []filter.Filter{
+		filter.StoreStateFilter{ActionScope: h.GetName(), TransferLeader: true},
+		filter.NewLeaderFractionFilter(h.GetName(), cluster.GetStores()),
+		filter.NewSchedulerDenyFilter(h.GetName()),
+	}
 	// select destPeer
 	for _, i := range h.r.Perm(storesStat[srcStoreID].RegionsStat.Len()) {
 		rs := storesStat[srcStoreID].RegionsStat[i]
@@ -364,15 +396,20 @@ func (h *balanceHotRegionsScheduler) balanceByLeader(cluster schedule.Cluster, s
 			continue
 		}
 
-		if isRegionUnhealthy(srcRegion) {
+		if !core.SelectRegion(srcRegion, hotRegionOptions...) {
 			schedulerCounter.WithLabelValues(h.GetName(), "unhealthy-replica").Inc()
 			continue
 		}
 
-		filters := []filter.Filter{filter.StoreStateFilter{ActionScope: h.GetName(), TransferLeader: true}}
+		intentFilter := filter.NewPlacementIntentFilter(h.GetName(), cluster.GetPlacementIntent(srcRegion.GetID()), true)
+		if srcStore := cluster.GetStore(srcStoreID); srcStore != nil && filter.Source(cluster, srcStore, []filter.Filter{intentFilter}) {
+			schedulerCounter.WithLabelValues(h.GetName(), "placement-intent").Inc()
+			continue
+		}
+		warmFilters := warmRegionFilters(cluster, h.GetName(), srcRegion)
 		candidateStoreIDs := make([]uint64, 0, len(srcRegion.GetPeers())-1)
 		for _, store := range cluster.GetFollowerStores(srcRegion) {
-			if !filter.Target(cluster, store, filters) {
+			if !filter.Target(cluster, store, append(append(filters, intentFilter), warmFilters...)) {
 				candidateStoreIDs = append(candidateStoreIDs, store.GetID())
 			}
 		}
@@ -394,16 +431,35 @@ func (h *balanceHotRegionsScheduler) balanceByLeader(cluster schedule.Cluster, s
 	return nil, nil
 }
 
+// hotRegionOptions select the hot regions to balance. The regions with
+// pending peers are skipped too, since their peers or leaders moved to the
+// stores still catching up are not available for long.
+var hotRegionOptions = []core.RegionOption{core.HealthRegionAllowPending(), core.NoPendingPeerRegion()}
+
+// warmRegionFilters keeps the warm regions on the performance stores of a
+// mixed-tier cluster, so that balancing the hot regions does not move the
+// frequently accessed data to the storage tier.
+func warmRegionFilters(cluster schedule.Cluster, scope string, region *core.RegionInfo) []filter.Filter {
+	if !cluster.IsRegionWarm(region) || !cluster.IsMixedTier() {
+		return nil
+	}
+	return []filter.Filter{filter.NewStoreTypeFilter(scope, core.StoreTypePerformance)}
+}
+
 // Select the store to move hot regions from.
 // We choose the store with the maximum number of hot region first.
 // Inside these stores, we choose the one with maximum flow bytes.
-func (h *balanceHotRegionsScheduler) selectSrcStore(stats statistics.StoreHotRegionsStat) (srcStoreID uint64) {
+func (h *balanceHotRegionsScheduler) selectSrcStore(cluster schedule.Cluster, stats statistics.StoreHotRegionsStat) (srcStoreID uint64) {
 	var (
 		maxFlowBytes           uint64
 		maxHotStoreRegionCount int
 	)
 
+	denyFilters := []filter.Filter{filter.NewSchedulerDenyFilter(h.GetName())}
 	for storeID, statistics := range stats {
+		if store := cluster.GetStore(storeID); store == nil || filter.Source(cluster, store, denyFilters) {
+			continue
+		}
 		count, flowBytes := statistics.RegionsStat.Len(), statistics.TotalFlowBytes
 		if count >= 2 && (count > maxHotStoreRegionCount || (count == maxHotStoreRegionCount && flowBytes > maxFlowBytes)) {
 			maxHotStoreRegionCount = count
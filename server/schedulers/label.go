@@ -44,6 +44,7 @@ type labelScheduler struct {
 func newLabelScheduler(opController *schedule.OperatorController) schedule.Scheduler {
 	filters := []filter.Filter{
 		filter.StoreStateFilter{ActionScope: labelSchedulerName, TransferLeader: true},
+		filter.NewSchedulerDenyFilter(labelSchedulerName),
 	}
 	return &labelScheduler{
 		name:          labelSchedulerName,
@@ -68,8 +69,9 @@ func (s *labelScheduler) Schedule(cluster schedule.Cluster) []*operator.Operator
 	schedulerCounter.WithLabelValues(s.GetName(), "schedule").Inc()
 	stores := cluster.GetStores()
 	rejectLeaderStores := make(map[uint64]struct{})
+	denyFilters := []filter.Filter{filter.NewSchedulerDenyFilter(s.GetName())}
 	for _, s := range stores {
-		if cluster.CheckLabelProperty(opt.RejectLeader, s.GetLabels()) {
+		if cluster.CheckLabelProperty(opt.RejectLeader, s.GetLabels()) && !filter.Source(cluster, s, denyFilters) {
 			rejectLeaderStores[s.GetID()] = struct{}{}
 		}
 	}
@@ -78,6 +80,7 @@ func (s *labelScheduler) Schedule(cluster schedule.Cluster) []*operator.Operator
 		return nil
 	}
 	log.Debug("label scheduler reject leader store list", zap.Reflect("stores", rejectLeaderStores))
+	fractionFilter := filter.NewLeaderFractionFilter(s.GetName(), stores)
 	for id := range rejectLeaderStores {
 		if region := cluster.RandLeaderRegion(id); region != nil {
 			log.Debug("label scheduler selects region to transfer leader", zap.Uint64("region-id", region.GetID()))
@@ -89,7 +92,7 @@ func (s *labelScheduler) Schedule(cluster schedule.Cluster) []*operator.Operator
 				excludeStores[p.GetStoreId()] = struct{}{}
 			}
 			f := filter.NewExcludedFilter(s.GetName(), nil, excludeStores)
-			target := s.selector.SelectTarget(cluster, cluster.GetFollowerStores(region), f)
+			target := s.selector.SelectTarget(cluster, cluster.GetFollowerStores(region), f, fractionFilter)
 			if target == nil {
 				log.Debug("label scheduler no target found for region", zap.Uint64("region-id", region.GetID()))
 				schedulerCounter.WithLabelValues(s.GetName(), "no-target").Inc()
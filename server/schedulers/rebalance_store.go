@@ -0,0 +1,171 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedulers
+
+import (
+	"math"
+	"sort"
+
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/schedule"
+	"github.com/pingcap/pd/server/schedule/filter"
+	"github.com/pingcap/pd/server/schedule/operator"
+)
+
+const (
+	rebalanceStoreName = "rebalance-store"
+	// rebalanceStoreRetryLimit is the limit to pick regions for a pair of
+	// stores.
+	rebalanceStoreRetryLimit = 10
+)
+
+// RebalanceStore moves leaders and regions between the store and the other
+// stores at once, so that the store approaches the distribution under its
+// weights quickly after they are changed instead of waiting for the balance
+// schedulers. It adds at most limit operators to the controller, and returns
+// the added operators. Like the balance schedulers, it stops adding the
+// operators of a kind once its schedule limit is reached.
+func RebalanceStore(cluster schedule.Cluster, opController *schedule.OperatorController, storeID uint64, limit int) []*operator.Operator {
+	var ops []*operator.Operator
+	for _, kind := range []core.ResourceKind{core.LeaderKind, core.RegionKind} {
+		for len(ops) < limit && isRebalanceAllowed(cluster, opController, kind) {
+			op := rebalanceStoreOnce(cluster, opController, storeID, kind)
+			if op == nil {
+				break
+			}
+			schedulerCounter.WithLabelValues(rebalanceStoreName, "new-operator").Inc()
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+// isRebalanceAllowed returns if the operators of the kind are under the
+// schedule limit, which is the same as the balance schedulers.
+func isRebalanceAllowed(cluster schedule.Cluster, opController *schedule.OperatorController, kind core.ResourceKind) bool {
+	if kind == core.LeaderKind {
+		return opController.OperatorCount(operator.OpLeader) < cluster.GetLeaderScheduleLimit()
+	}
+	return opController.OperatorCount(operator.OpRegion) < cluster.GetRegionScheduleLimit()
+}
+
+// rebalanceStoreOnce tries the other stores in descending order of the
+// difference of scores to the store, and adds an operator to move a leader
+// or a region from the store with the higher score to the other one.
+func rebalanceStoreOnce(cluster schedule.Cluster, opController *schedule.OperatorController, storeID uint64, kind core.ResourceKind) *operator.Operator {
+	store := cluster.GetStore(storeID)
+	if store == nil {
+		return nil
+	}
+	opInfluence := opController.GetOpInfluence(cluster)
+	score := func(s *core.StoreInfo) float64 {
+		delta := opInfluence.GetStoreInfluence(s.GetID()).ResourceSize(kind)
+		return s.ResourceScore(kind, cluster.GetHighSpaceRatio(), cluster.GetLowSpaceRatio(), delta)
+	}
+	storeScore := score(store)
+
+	var others []*core.StoreInfo
+	for _, s := range cluster.GetStores() {
+		if s.GetID() != storeID {
+			others = append(others, s)
+		}
+	}
+	sort.Slice(others, func(i, j int) bool {
+		return math.Abs(score(others[i])-storeScore) > math.Abs(score(others[j])-storeScore)
+	})
+
+	stateFilter := filter.StoreStateFilter{ActionScope: rebalanceStoreName, TransferLeader: kind == core.LeaderKind, MoveRegion: kind == core.RegionKind}
+	for _, other := range others {
+		source, target := other, store
+		if score(other) < storeScore {
+			source, target = store, other
+		}
+		if filter.Source(cluster, source, []filter.Filter{stateFilter}) || filter.Target(cluster, target, []filter.Filter{stateFilter}) {
+			continue
+		}
+		for i := 0; i < rebalanceStoreRetryLimit; i++ {
+			var op *operator.Operator
+			if kind == core.LeaderKind {
+				op = rebalanceLeader(cluster, source, target, opInfluence)
+			} else {
+				op = rebalanceRegion(cluster, source, target, opInfluence)
+			}
+			if op != nil && opController.AddOperator(op) {
+				return op
+			}
+		}
+	}
+	return nil
+}
+
+// rebalanceLeader transfers a leader from the source store to the target store.
+func rebalanceLeader(cluster schedule.Cluster, source, target *core.StoreInfo, opInfluence operator.OpInfluence) *operator.Operator {
+	region := cluster.RandLeaderRegion(source.GetID(), core.HealthRegion())
+	if region == nil || region.GetStoreVoter(target.GetID()) == nil || cluster.IsRegionHot(region) {
+		return nil
+	}
+	fractionFilter := filter.NewLeaderFractionFilter(rebalanceStoreName, cluster.GetStores())
+	if filter.Target(cluster, target, []filter.Filter{fractionFilter}) ||
+		!shouldRebalance(cluster, source, target, region, core.LeaderKind, opInfluence) {
+		return nil
+	}
+	return operator.CreateTransferLeaderOperator(rebalanceStoreName, region, source.GetID(), target.GetID(), operator.OpBalance)
+}
+
+// rebalanceRegion moves a peer from the source store to the target store.
+func rebalanceRegion(cluster schedule.Cluster, source, target *core.StoreInfo, opInfluence operator.OpInfluence) *operator.Operator {
+	region := cluster.RandFollowerRegion(source.GetID(), core.HealthRegion())
+	if region == nil {
+		region = cluster.RandLeaderRegion(source.GetID(), core.HealthRegion())
+	}
+	if region == nil || region.GetStorePeer(target.GetID()) != nil ||
+		len(region.GetPeers()) != cluster.GetMaxReplicas() || cluster.IsRegionHot(region) {
+		return nil
+	}
+	filters := []filter.Filter{
+		filter.NewDistinctScoreFilter(rebalanceStoreName, cluster.GetLocationLabels(), cluster.GetRegionStores(region), source),
+		filter.NewRegionCountFilter(rebalanceStoreName),
+		filter.NewMemoryPressureFilter(rebalanceStoreName),
+	}
+	if filter.Target(cluster, target, filters) ||
+		!shouldRebalance(cluster, source, target, region, core.RegionKind, opInfluence) {
+		return nil
+	}
+	newPeer, err := cluster.AllocPeer(target.GetID())
+	if err != nil {
+		return nil
+	}
+	op, err := operator.CreateMovePeerOperator(rebalanceStoreName, cluster, region, operator.OpBalance, source.GetID(), target.GetID(), newPeer.GetId())
+	if err != nil {
+		return nil
+	}
+	return op
+}
+
+// shouldRebalance checks if the source score is still greater than the target
+// score after moving the region. Unlike shouldBalance, it does not keep the
+// pending operators from piling up on the target, because the counts and
+// flows are expected to be unbalanced under different weights, and the store
+// limits already bound the pending operators.
+func shouldRebalance(cluster schedule.Cluster, source, target *core.StoreInfo, region *core.RegionInfo, kind core.ResourceKind, opInfluence operator.OpInfluence) bool {
+	regionSize := region.GetApproximateSize()
+	if regionSize < cluster.GetAverageRegionSize() {
+		regionSize = cluster.GetAverageRegionSize()
+	}
+	regionSize = int64(float64(regionSize) * adjustTolerantRatio(cluster))
+	sourceDelta := opInfluence.GetStoreInfluence(source.GetID()).ResourceSize(kind) - regionSize
+	targetDelta := opInfluence.GetStoreInfluence(target.GetID()).ResourceSize(kind) + regionSize
+	return source.ResourceScore(kind, cluster.GetHighSpaceRatio(), cluster.GetLowSpaceRatio(), sourceDelta) >
+		target.ResourceScore(kind, cluster.GetHighSpaceRatio(), cluster.GetLowSpaceRatio(), targetDelta)
+}
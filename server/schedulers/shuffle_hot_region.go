@@ -90,21 +90,19 @@ func (s *shuffleHotRegionScheduler) dispatch(typ BalanceType, cluster schedule.C
 	switch typ {
 	case hotReadRegionBalance:
 		s.stats.readStatAsLeader = calcScore(cluster.RegionReadStats(), cluster, core.LeaderKind)
-		return s.randomSchedule(cluster, s.stats.readStatAsLeader)
+		return s.randomSchedule(cluster, s.stats.readStatAsLeader, statistics.ReadFlow)
 	case hotWriteRegionBalance:
 		s.stats.writeStatAsLeader = calcScore(cluster.RegionWriteStats(), cluster, core.LeaderKind)
-		return s.randomSchedule(cluster, s.stats.writeStatAsLeader)
+		return s.randomSchedule(cluster, s.stats.writeStatAsLeader, statistics.WriteFlow)
 	}
 	return nil
 }
 
-func (s *shuffleHotRegionScheduler) randomSchedule(cluster schedule.Cluster, storeStats statistics.StoreHotRegionsStat) []*operator.Operator {
-	for _, stats := range storeStats {
-		i := s.r.Intn(stats.RegionsStat.Len())
-		r := stats.RegionsStat[i]
-		// select src region
-		srcRegion := cluster.GetRegion(r.RegionID)
-		if srcRegion == nil || len(srcRegion.GetDownPeers()) != 0 || len(srcRegion.GetPendingPeers()) != 0 {
+func (s *shuffleHotRegionScheduler) randomSchedule(cluster schedule.Cluster, storeStats statistics.StoreHotRegionsStat, kind statistics.FlowKind) []*operator.Operator {
+	for storeID := range storeStats {
+		// select src region led by the store
+		srcRegion := cluster.RandHotRegionFromStore(storeID, kind, core.HealthRegion(), core.LeaderInStore(storeID))
+		if srcRegion == nil {
 			continue
 		}
 		srcStoreID := srcRegion.GetLeader().GetStoreId()
@@ -40,6 +40,7 @@ type shuffleLeaderScheduler struct {
 func newShuffleLeaderScheduler(opController *schedule.OperatorController) schedule.Scheduler {
 	filters := []filter.Filter{
 		filter.StoreStateFilter{ActionScope: shuffleLeaderName, TransferLeader: true},
+		filter.NewSchedulerDenyFilter(shuffleLeaderName),
 	}
 	base := newBaseScheduler(opController)
 	return &shuffleLeaderScheduler{
@@ -77,6 +78,11 @@ func (s *shuffleLeaderScheduler) Schedule(cluster schedule.Cluster) []*operator.
 		schedulerCounter.WithLabelValues(s.GetName(), "no-follower").Inc()
 		return nil
 	}
+	if leaderStore := cluster.GetLeaderStore(region); leaderStore == nil ||
+		filter.Source(cluster, leaderStore, []filter.Filter{filter.NewSchedulerDenyFilter(s.GetName())}) {
+		schedulerCounter.WithLabelValues(s.GetName(), "denied-leader-store").Inc()
+		return nil
+	}
 	schedulerCounter.WithLabelValues(s.GetName(), "new-operator").Inc()
 	op := operator.CreateTransferLeaderOperator("shuffle-leader", region, region.GetLeader().GetId(), targetStore.GetID(), operator.OpAdmin)
 	op.SetPriorityLevel(core.HighPriority)
@@ -43,6 +43,7 @@ type shuffleRegionScheduler struct {
 func newShuffleRegionScheduler(opController *schedule.OperatorController) schedule.Scheduler {
 	filters := []filter.Filter{
 		filter.StoreStateFilter{ActionScope: shuffleRegionName, MoveRegion: true},
+		filter.NewSchedulerDenyFilter(shuffleRegionName),
 	}
 	base := newBaseScheduler(opController)
 	return &shuffleRegionScheduler{
@@ -50,10 +50,6 @@ func minDuration(a, b time.Duration) time.Duration {
 	return b
 }
 
-func isRegionUnhealthy(region *core.RegionInfo) bool {
-	return len(region.GetDownPeers()) != 0 || len(region.GetLearners()) != 0
-}
-
 func shouldBalance(cluster schedule.Cluster, source, target *core.StoreInfo, region *core.RegionInfo, kind core.ResourceKind, opInfluence operator.OpInfluence) bool {
 	// The reason we use max(regionSize, averageRegionSize) to check is:
 	// 1. prevent moving small regions between stores with close scores, leading to unnecessary balance.
@@ -64,12 +60,62 @@ func shouldBalance(cluster schedule.Cluster, source, target *core.StoreInfo, reg
 	}
 
 	regionSize = int64(float64(regionSize) * adjustTolerantRatio(cluster))
-	sourceDelta := opInfluence.GetStoreInfluence(source.GetID()).ResourceSize(kind) - regionSize
-	targetDelta := opInfluence.GetStoreInfluence(target.GetID()).ResourceSize(kind) + regionSize
+	sourceInfluence := opInfluence.GetStoreInfluence(source.GetID())
+	targetInfluence := opInfluence.GetStoreInfluence(target.GetID())
+	sourceDelta := sourceInfluence.ResourceSize(kind) - regionSize
+	targetDelta := targetInfluence.ResourceSize(kind) + regionSize
 
 	// Make sure after move, source score is still greater than target score.
-	return source.ResourceScore(kind, cluster.GetHighSpaceRatio(), cluster.GetLowSpaceRatio(), sourceDelta) >
-		target.ResourceScore(kind, cluster.GetHighSpaceRatio(), cluster.GetLowSpaceRatio(), targetDelta)
+	// The stores of the same tier in mixed clusters are compared by the
+	// scoring function of the tier.
+	score := (*core.StoreInfo).ResourceScore
+	if source.GetStoreType() == target.GetStoreType() && cluster.IsMixedTier() {
+		score = (*core.StoreInfo).TierResourceScore
+	}
+	if score(source, kind, cluster.GetHighSpaceRatio(), cluster.GetLowSpaceRatio(), sourceDelta) <=
+		score(target, kind, cluster.GetHighSpaceRatio(), cluster.GetLowSpaceRatio(), targetDelta) {
+		return false
+	}
+
+	// The counts and flows moved by pending operators are not reported by the
+	// target yet. Make sure they do not pile up on the target, so that the
+	// target is not overloaded when they are finished. They are weighted as
+	// the scores are, so that a store of a larger weight or capacity takes
+	// more.
+	sourceWeight, targetWeight := balanceWeights(source, target, kind)
+	if targetInfluence.ResourceCount(kind) > 0 {
+		sourceCount := int64(source.ResourceCount(kind)) + sourceInfluence.ResourceCount(kind) - 1
+		targetCount := int64(target.ResourceCount(kind)) + targetInfluence.ResourceCount(kind) + 1
+		if float64(sourceCount)/sourceWeight < float64(targetCount)/targetWeight {
+			return false
+		}
+	}
+	if targetInfluence.BytesRate(kind) > 0 {
+		regionRate := region.GetBytesWriteRate()
+		sourceRate, targetRate := source.GetBytesWriteRate(), target.GetBytesWriteRate()
+		if kind == core.LeaderKind {
+			regionRate = region.GetBytesReadRate()
+			sourceRate, targetRate = source.GetBytesReadRate(), target.GetBytesReadRate()
+		}
+		sourceRate += sourceInfluence.BytesRate(kind) - regionRate
+		targetRate += targetInfluence.BytesRate(kind) + regionRate
+		if sourceRate/sourceWeight < targetRate/targetWeight {
+			return false
+		}
+	}
+	return true
+}
+
+// balanceWeights returns the weights of the source and the target, by which
+// the counts and the flows are divided before being compared. They are the
+// weights of the scores, scaled by the capacities for the regions if both
+// stores report them.
+func balanceWeights(source, target *core.StoreInfo, kind core.ResourceKind) (float64, float64) {
+	sourceWeight, targetWeight := source.ResourceWeight(kind), target.ResourceWeight(kind)
+	if kind == core.RegionKind && source.GetCapacity() > 0 && target.GetCapacity() > 0 {
+		sourceWeight *= float64(source.GetCapacity()) / float64(target.GetCapacity())
+	}
+	return sourceWeight, targetWeight
 }
 
 func adjustTolerantRatio(cluster schedule.Cluster) float64 {
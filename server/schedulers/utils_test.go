@@ -53,7 +53,7 @@ var _ = Suite(&testRegionUnhealthySuite{})
 
 type testRegionUnhealthySuite struct{}
 
-func (s *testRegionUnhealthySuite) TestIsRegionUnhealthy(c *C) {
+func (s *testRegionUnhealthySuite) TestHotRegionOptions(c *C) {
 	peers := make([]*metapb.Peer, 0, 3)
 	for i := uint64(0); i < 2; i++ {
 		p := &metapb.Peer{
@@ -72,8 +72,8 @@ func (s *testRegionUnhealthySuite) TestIsRegionUnhealthy(c *C) {
 	r2 := core.NewRegionInfo(&metapb.Region{Peers: peers[:2]}, peers[0], core.WithPendingPeers([]*metapb.Peer{peers[1]}))
 	r3 := core.NewRegionInfo(&metapb.Region{Peers: peers[:3]}, peers[0], core.WithLearners([]*metapb.Peer{peers[2]}))
 	r4 := core.NewRegionInfo(&metapb.Region{Peers: peers[:2]}, peers[0])
-	c.Assert(isRegionUnhealthy(r1), IsTrue)
-	c.Assert(isRegionUnhealthy(r2), IsFalse)
-	c.Assert(isRegionUnhealthy(r3), IsTrue)
-	c.Assert(isRegionUnhealthy(r4), IsFalse)
+	c.Assert(core.SelectRegion(r1, hotRegionOptions...), IsFalse)
+	c.Assert(core.SelectRegion(r2, hotRegionOptions...), IsFalse)
+	c.Assert(core.SelectRegion(r3, hotRegionOptions...), IsFalse)
+	c.Assert(core.SelectRegion(r4, hotRegionOptions...), IsTrue)
 }
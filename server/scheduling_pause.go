@@ -0,0 +1,192 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/log"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// maxSchedulingPauseEvents is the number of the recent events of pausing and
+// resuming scheduling which are kept.
+const maxSchedulingPauseEvents = 100
+
+// The events of pausing and resuming scheduling.
+const (
+	SchedulingPauseEventPause  = "pause"
+	SchedulingPauseEventResume = "resume"
+	SchedulingPauseEventExpire = "expire"
+)
+
+// SchedulingPause records the scheduling of the whole cluster paused by
+// users. It is persisted so that others see who paused scheduling and why,
+// and scheduling stays paused after the leader changes.
+type SchedulingPause struct {
+	Owner     string    `json:"owner"`
+	Reason    string    `json:"reason"`
+	PauseTime time.Time `json:"pause_time"`
+	// ExpireTime is when scheduling is resumed automatically. Scheduling is
+	// paused until being resumed by users if it is nil.
+	ExpireTime *time.Time `json:"expire_time,omitempty"`
+}
+
+func (p *SchedulingPause) isExpired(now time.Time) bool {
+	return p.ExpireTime != nil && !now.Before(*p.ExpireTime)
+}
+
+// SchedulingPauseEvent is an event of pausing or resuming scheduling. The
+// owner is the one resuming scheduling for the resume events, and the one of
+// the pause otherwise.
+type SchedulingPauseEvent struct {
+	Time   time.Time `json:"time"`
+	Event  string    `json:"event"`
+	Owner  string    `json:"owner"`
+	Reason string    `json:"reason"`
+}
+
+// SchedulingPauseStatus is the current pause, which is nil if scheduling is
+// not paused, and the recent events.
+type SchedulingPauseStatus struct {
+	Pause  *SchedulingPause        `json:"pause,omitempty"`
+	Events []*SchedulingPauseEvent `json:"events"`
+}
+
+// schedulingPauser keeps the pause apart from the lock of the cluster, since
+// it is checked by the schedulers frequently.
+type schedulingPauser struct {
+	sync.RWMutex
+	status SchedulingPauseStatus
+}
+
+func (c *RaftCluster) loadSchedulingPause() error {
+	var status SchedulingPauseStatus
+	if _, err := c.storage.LoadSchedulingPause(&status); err != nil {
+		return err
+	}
+	c.pauser.Lock()
+	defer c.pauser.Unlock()
+	c.pauser.status = status
+	return nil
+}
+
+// saveSchedulingPauseLocked persists the new pause with the event, and
+// applies them if it succeeds.
+func (c *RaftCluster) saveSchedulingPauseLocked(pause *SchedulingPause, event *SchedulingPauseEvent) error {
+	events := append(c.pauser.status.Events, event)
+	if len(events) > maxSchedulingPauseEvents {
+		events = events[len(events)-maxSchedulingPauseEvents:]
+	}
+	status := SchedulingPauseStatus{Pause: pause, Events: events}
+	if err := c.storage.SaveSchedulingPause(&status); err != nil {
+		return err
+	}
+	c.pauser.status = status
+	return nil
+}
+
+// PauseScheduling pauses the scheduling of the whole cluster until it is
+// resumed by ResumeScheduling, or ttl passes if ttl is positive. Pausing
+// again updates the owner, the reason and the expiry.
+func (c *RaftCluster) PauseScheduling(owner, reason string, ttl time.Duration) (*SchedulingPause, error) {
+	if reason == "" {
+		return nil, errcode.NewInvalidInputErr(errors.New("the reason to pause scheduling is required"))
+	}
+	c.pauser.Lock()
+	defer c.pauser.Unlock()
+
+	now := time.Now()
+	pause := &SchedulingPause{
+		Owner:     owner,
+		Reason:    reason,
+		PauseTime: now,
+	}
+	if ttl > 0 {
+		expireTime := now.Add(ttl)
+		pause.ExpireTime = &expireTime
+	}
+	if old := c.pauser.status.Pause; old != nil && !old.isExpired(now) {
+		pause.PauseTime = old.PauseTime
+	}
+	event := &SchedulingPauseEvent{Time: now, Event: SchedulingPauseEventPause, Owner: owner, Reason: reason}
+	if err := c.saveSchedulingPauseLocked(pause, event); err != nil {
+		return nil, err
+	}
+	log.Warn("scheduling is paused",
+		zap.String("owner", owner),
+		zap.String("reason", reason),
+		zap.Duration("ttl", ttl))
+	return pause, nil
+}
+
+// ResumeScheduling resumes the scheduling paused by PauseScheduling.
+func (c *RaftCluster) ResumeScheduling(owner string) error {
+	c.pauser.Lock()
+	defer c.pauser.Unlock()
+	pause := c.pauser.status.Pause
+	if pause == nil {
+		return errcode.NewNotFoundErr(errors.New("scheduling is not paused"))
+	}
+	event := &SchedulingPauseEvent{Time: time.Now(), Event: SchedulingPauseEventResume, Owner: owner, Reason: pause.Reason}
+	if err := c.saveSchedulingPauseLocked(nil, event); err != nil {
+		return err
+	}
+	log.Info("scheduling is resumed", zap.String("owner", owner), zap.String("paused-by", pause.Owner))
+	return nil
+}
+
+// GetSchedulingPause returns the current pause and the recent events.
+func (c *RaftCluster) GetSchedulingPause() SchedulingPauseStatus {
+	c.pauser.RLock()
+	defer c.pauser.RUnlock()
+	status := SchedulingPauseStatus{
+		Events: append([]*SchedulingPauseEvent(nil), c.pauser.status.Events...),
+	}
+	if pause := c.pauser.status.Pause; pause != nil && !pause.isExpired(time.Now()) {
+		status.Pause = pause
+	}
+	return status
+}
+
+// isSchedulingPaused returns if scheduling is paused by users. The expired
+// pause takes no effect even before it is removed by checkSchedulingPause.
+func (c *RaftCluster) isSchedulingPaused() bool {
+	c.pauser.RLock()
+	defer c.pauser.RUnlock()
+	pause := c.pauser.status.Pause
+	return pause != nil && !pause.isExpired(time.Now())
+}
+
+// checkSchedulingPause removes the expired pause with an event.
+func (c *RaftCluster) checkSchedulingPause() {
+	c.pauser.Lock()
+	defer c.pauser.Unlock()
+	now := time.Now()
+	pause := c.pauser.status.Pause
+	if pause == nil || !pause.isExpired(now) {
+		return
+	}
+	event := &SchedulingPauseEvent{Time: now, Event: SchedulingPauseEventExpire, Owner: pause.Owner, Reason: pause.Reason}
+	if err := c.saveSchedulingPauseLocked(nil, event); err != nil {
+		log.Error("failed to remove expired scheduling pause", zap.Error(err))
+		return
+	}
+	log.Info("scheduling pause is expired, scheduling is resumed",
+		zap.String("owner", pause.Owner),
+		zap.String("reason", pause.Reason))
+}
@@ -33,11 +33,13 @@ import (
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/pingcap/log"
 	"github.com/pingcap/pd/pkg/etcdutil"
+	"github.com/pingcap/pd/pkg/heartbeatlog"
 	"github.com/pingcap/pd/pkg/logutil"
 	"github.com/pingcap/pd/pkg/typeutil"
 	"github.com/pingcap/pd/server/config"
 	"github.com/pingcap/pd/server/core"
 	"github.com/pingcap/pd/server/id"
+	"github.com/pingcap/pd/server/keydecoder"
 	"github.com/pingcap/pd/server/kv"
 	"github.com/pingcap/pd/server/member"
 	"github.com/pingcap/pd/server/namespace"
@@ -48,6 +50,7 @@ import (
 	"go.etcd.io/etcd/pkg/types"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
 )
 
 const (
@@ -55,10 +58,7 @@ const (
 	etcdStartTimeout      = time.Minute * 5
 	serverMetricsInterval = time.Minute
 	leaderTickInterval    = 50 * time.Millisecond
-	// pdRootPath for all pd servers.
-	pdRootPath      = "/pd"
-	pdAPIPrefix     = "/pd/"
-	pdClusterIDPath = "/pd/cluster_id"
+	pdAPIPrefix           = "/pd/"
 )
 
 // EnableZap enable the zap logger in embed etcd.
@@ -94,16 +94,38 @@ type Server struct {
 	// for tso.
 	tso *tso.TimestampOracle
 	// for namespace.
-	classifier namespace.Classifier
+	classifier    *namespace.SwitchableClassifier
+	classifierMgr classifierManager
+	// for decoding region keys.
+	keyDecoder keydecoder.KeyDecoder
 	// for raft cluster
 	cluster *RaftCluster
 	// For async region heartbeat.
 	hbStreams *heartbeatStreams
+	// For reporting the readiness through the gRPC health checking protocol.
+	healthServer *health.Server
+	// For capturing heartbeats to replay offline.
+	hbCapture *heartbeatCapture
+	// For tracking and blocking the heartbeats from unknown stores.
+	quarantine *storeQuarantine
 	// Zap logger
 	lg       *zap.Logger
 	logProps *log.ZapProperties
 }
 
+// newSlowLogger returns the logger of the slow log file, or nil to use the
+// main logger if no file is configured.
+func newSlowLogger(cfg *config.Config) (*zap.Logger, error) {
+	if cfg.SlowLogFile == "" {
+		return nil, nil
+	}
+	logCfg := cfg.Log
+	logCfg.Level = "info"
+	logCfg.File.Filename = cfg.SlowLogFile
+	lg, _, err := log.InitLogger(&logCfg)
+	return lg, errors.WithStack(err)
+}
+
 // CreateServer creates the UNINITIALIZED pd server with given configuration.
 func CreateServer(cfg *config.Config, apiRegister func(*Server) http.Handler) (*Server, error) {
 	log.Info("PD Config", zap.Reflect("config", cfg))
@@ -115,18 +137,34 @@ func CreateServer(cfg *config.Config, apiRegister func(*Server) http.Handler) (*
 		member:      &member.Member{},
 	}
 	s.handler = newHandler(s)
+	s.healthServer = health.NewServer()
+	s.quarantine = newStoreQuarantine(func() uint64 {
+		return s.scheduleOpt.LoadPDServerConfig().UnknownStoreBlockThreshold
+	})
 
 	// Adjust etcd config.
 	etcdCfg, err := s.cfg.GenEmbedEtcdConfig()
 	if err != nil {
 		return nil, err
 	}
+	advertiseUrls := append(config.SplitUrls(cfg.AdvertiseClientUrls), config.SplitUrls(cfg.AdvertisePeerUrls)...)
+	if err := cfg.Security.VerifyCertHosts(advertiseUrls...); err != nil {
+		log.Warn("the certificate is not valid for the advertise urls", zap.Error(err))
+	}
 	if apiRegister != nil {
 		etcdCfg.UserHandlers = map[string]http.Handler{
 			pdAPIPrefix: apiRegister(s),
 		}
 	}
-	etcdCfg.ServiceRegister = func(gs *grpc.Server) { pdpb.RegisterPDServer(gs, s) }
+	slowLog, err := newSlowLogger(cfg)
+	if err != nil {
+		return nil, err
+	}
+	intercepted := newInterceptedServer(s, slowLog)
+	etcdCfg.ServiceRegister = func(gs *grpc.Server) {
+		pdpb.RegisterPDServer(gs, intercepted)
+		s.registerHealthServer(gs, s.healthServer)
+	}
 	s.etcdCfg = etcdCfg
 	if EnableZap {
 		// The etcd master version has removed embed.Config.SetupLogging.
@@ -207,6 +245,15 @@ func (s *Server) startEtcd(ctx context.Context) error {
 	return nil
 }
 
+// regionStoragePath returns the path of the region storage. The backends use
+// different paths, so that switching the backend starts from a clean storage.
+func (s *Server) regionStoragePath() string {
+	if s.cfg.PDServerCfg.RegionStorageBackend == kv.BoltBackend {
+		return filepath.Join(s.cfg.DataDir, "region-meta.db")
+	}
+	return filepath.Join(s.cfg.DataDir, "region-meta")
+}
+
 func (s *Server) startServer() error {
 	var err error
 	if err = s.initClusterID(); err != nil {
@@ -217,23 +264,43 @@ func (s *Server) startServer() error {
 	// cluster id in label.
 	metadataGauge.WithLabelValues(fmt.Sprintf("cluster%d", s.clusterID)).Set(0)
 
-	s.rootPath = path.Join(pdRootPath, strconv.FormatUint(s.clusterID, 10))
+	s.rootPath = path.Join(s.cfg.RootPrefix, strconv.FormatUint(s.clusterID, 10))
 	s.member.MemberInfo(s.cfg, s.Name(), s.rootPath)
+	if err = s.member.RegisterMember(); err != nil {
+		return err
+	}
 
-	s.idAllocator = id.NewAllocatorImpl(s.client, s.rootPath, s.member.MemberValue())
-	s.tso = tso.NewTimestampOracle(s.client, s.rootPath, s.member.MemberValue(), s.cfg.TsoSaveInterval.Duration)
+	s.idAllocator = id.NewAllocatorImpl(s.client, s.rootPath, s.member.MemberValue(), s.cfg.PDServerCfg.IDAllocStep)
+	s.tso = tso.NewTimestampOracle(s.client, s.rootPath, s.member.MemberValue(), s.cfg.TsoSaveInterval.Duration, s.cfg.MaxResetTSGap.Duration)
 	kvBase := kv.NewEtcdKVBase(s.client, s.rootPath)
-	path := filepath.Join(s.cfg.DataDir, "region-meta")
-	regionStorage, err := core.NewRegionStorage(path)
+	regionStorage, err := core.NewRegionStorageWithBackend(s.cfg.PDServerCfg.RegionStorageBackend, s.regionStoragePath())
 	if err != nil {
 		return err
 	}
 	s.storage = core.NewStorage(kvBase).SetRegionStorage(regionStorage)
 	s.cluster = newRaftCluster(s, s.clusterID)
 	s.hbStreams = newHeartbeatStreams(s.clusterID, s.cluster)
-	if s.classifier, err = namespace.CreateClassifier(s.cfg.NamespaceClassifier, s.storage, s.idAllocator); err != nil {
+	classifier, err := s.createClassifier(s.cfg.NamespaceClassifier, s.cfg.ClassifierCfg.Args)
+	if err != nil {
+		return err
+	}
+	s.classifier = namespace.NewSwitchableClassifier(classifier)
+	s.classifierMgr.state = classifierState{Name: s.cfg.NamespaceClassifier, Args: s.cfg.ClassifierCfg.Args}
+	if err = s.loadClassifierState(); err != nil {
 		return err
 	}
+	if s.keyDecoder, err = keydecoder.CreateDecoder(s.cfg.KeyDecoder); err != nil {
+		return err
+	}
+	if s.cfg.HeartbeatCaptureFile != "" {
+		rotation := heartbeatlog.Rotation{
+			MaxSize:    int64(s.cfg.HeartbeatCaptureMaxSize),
+			MaxBackups: s.cfg.HeartbeatCaptureMaxBackups,
+		}
+		if s.hbCapture, err = newHeartbeatCapture(s.cfg.HeartbeatCaptureFile, rotation); err != nil {
+			return err
+		}
+	}
 	// Server has started.
 	atomic.StoreInt64(&s.isServing, 1)
 	return nil
@@ -241,20 +308,26 @@ func (s *Server) startServer() error {
 
 func (s *Server) initClusterID() error {
 	// Get any cluster key to parse the cluster ID.
-	resp, err := etcdutil.EtcdKVGet(s.client, pdClusterIDPath)
+	resp, err := etcdutil.EtcdKVGet(s.client, s.clusterIDPath())
 	if err != nil {
 		return err
 	}
 
 	// If no key exist, generate a random cluster ID.
 	if len(resp.Kvs) == 0 {
-		s.clusterID, err = initOrGetClusterID(s.client, pdClusterIDPath)
+		s.clusterID, err = initOrGetClusterID(s.client, s.clusterIDPath())
 		return err
 	}
 	s.clusterID, err = typeutil.BytesToUint64(resp.Kvs[0].Value)
 	return err
 }
 
+// clusterIDPath is the key of the cluster ID, which is under the root prefix so
+// that PD clusters sharing one etcd have their own IDs.
+func (s *Server) clusterIDPath() string {
+	return path.Join(s.cfg.RootPrefix, "cluster_id")
+}
+
 // Close closes the server.
 func (s *Server) Close() {
 	if !atomic.CompareAndSwapInt64(&s.isServing, 1, 0) {
@@ -277,6 +350,7 @@ func (s *Server) Close() {
 	if s.hbStreams != nil {
 		s.hbStreams.Close()
 	}
+	s.hbCapture.close()
 	if err := s.storage.Close(); err != nil {
 		log.Error("close storage meet error", zap.Error(err))
 	}
@@ -320,10 +394,12 @@ func (s *Server) Context() context.Context {
 
 func (s *Server) startServerLoop() {
 	s.serverLoopCtx, s.serverLoopCancel = context.WithCancel(context.Background())
-	s.serverLoopWg.Add(3)
+	s.serverLoopWg.Add(5)
 	go s.leaderLoop()
 	go s.etcdLeaderLoop()
 	go s.serverMetricsLoop()
+	go s.classifierReloadLoop()
+	go s.healthCheckLoop()
 }
 
 func (s *Server) stopServerLoop() {
@@ -348,6 +424,41 @@ func (s *Server) serverMetricsLoop() {
 	}
 }
 
+// classifierReloadLoop reloads the namespaces periodically, so that changes of
+// the backing data of the classifier can take effect. It also cuts over the
+// classifier when the grace period of the transition passes.
+func (s *Server) classifierReloadLoop() {
+	defer logutil.LogPanic()
+	defer s.serverLoopWg.Done()
+
+	var reload <-chan time.Time
+	if interval := s.cfg.ClassifierCfg.ReloadInterval.Duration; interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		reload = ticker.C
+	}
+	transitionTicker := time.NewTicker(classifierTransitionCheckInterval)
+	defer transitionTicker.Stop()
+	for {
+		select {
+		case <-reload:
+			if !s.member.IsLeader() {
+				continue
+			}
+			if err := s.classifier.ReloadNamespaces(); err != nil {
+				log.Error("failed to reload namespaces", zap.String("classifier", s.GetClassifierName()), zap.Error(err))
+			}
+		case <-transitionTicker.C:
+			if s.member.IsLeader() {
+				s.checkClassifierTransition()
+			}
+		case <-s.serverLoopCtx.Done():
+			log.Info("server is closed, exit classifier reload loop")
+			return
+		}
+	}
+}
+
 func (s *Server) collectEtcdStateMetrics() {
 	etcdStateGauge.WithLabelValues("term").Set(float64(s.member.Etcd().Server.Term()))
 	etcdStateGauge.WithLabelValues("appliedIndex").Set(float64(s.member.Etcd().Server.AppliedIndex()))
@@ -518,6 +629,12 @@ func (s *Server) GetConfig() *config.Config {
 	return cfg
 }
 
+// GetEffectiveConfig returns the items of the schedule, the replication and
+// the PD server configs in effect with where they come from.
+func (s *Server) GetEffectiveConfig() []*config.EffectiveConfigItem {
+	return s.scheduleOpt.GetEffectiveConfig()
+}
+
 // GetScheduleConfig gets the balance config information.
 func (s *Server) GetScheduleConfig() *config.ScheduleConfig {
 	cfg := &config.ScheduleConfig{}
@@ -573,8 +690,18 @@ func (s *Server) SetReplicationConfig(cfg config.ReplicationConfig) error {
 	return nil
 }
 
+// GetPDServerConfig gets the server config.
+func (s *Server) GetPDServerConfig() *config.PDServerConfig {
+	cfg := &config.PDServerConfig{}
+	*cfg = *s.scheduleOpt.LoadPDServerConfig()
+	return cfg
+}
+
 // SetPDServerConfig sets the server config.
 func (s *Server) SetPDServerConfig(cfg config.PDServerConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
 	old := s.scheduleOpt.LoadPDServerConfig()
 	s.scheduleOpt.SetPDServerConfig(&cfg)
 	if err := s.scheduleOpt.Persist(s.storage); err != nil {
@@ -822,22 +949,33 @@ func (s *Server) leaderLoop() {
 				log.Error("reload config failed", zap.Error(err))
 				continue
 			}
+			ctx, cancel := context.WithCancel(s.serverLoopCtx)
 			if s.scheduleOpt.LoadPDServerConfig().UseRegionStorage {
+				if err = s.cluster.startStandby(s.scheduleOpt, s.storage, s.classifier); err != nil {
+					log.Error("failed to start standby cluster", zap.Error(err))
+				}
 				s.cluster.regionSyncer.StartSyncWithLeader(leader.GetClientUrls()[0])
+				go s.cluster.warmStandbyHotCache(ctx, leader.GetClientUrls()[0])
 			}
 			log.Info("start watch leader", zap.Stringer("leader", leader))
 			s.member.WatchLeader(s.serverLoopCtx, leader, rev)
+			cancel()
 			s.cluster.regionSyncer.StopSyncWithLeader()
 			log.Info("leader changed, try to campaign leader")
 		}
 
 		etcdLeader := s.member.GetEtcdLeader()
 		if etcdLeader != s.member.ID() {
-			log.Info("skip campaign leader and check later",
-				zap.String("server-name", s.Name()),
-				zap.Uint64("etcd-leader-id", etcdLeader))
-			time.Sleep(200 * time.Millisecond)
-			continue
+			// The etcd leader may serve another PD cluster sharing the etcd,
+			// which does not prevent this member from campaigning.
+			isMember, err := s.member.IsClusterMember(etcdLeader)
+			if err != nil || isMember {
+				log.Info("skip campaign leader and check later",
+					zap.String("server-name", s.Name()),
+					zap.Uint64("etcd-leader-id", etcdLeader))
+				time.Sleep(200 * time.Millisecond)
+				continue
+			}
 		}
 		s.campaignLeader()
 	}
@@ -875,6 +1013,8 @@ func (s *Server) campaignLeader() {
 		log.Error("failed to reload configuration", zap.Error(err))
 		return
 	}
+	// The regions are saved by the next leader after the cluster is stopped.
+	defer s.storage.ResetRegionHashes()
 	// Try to create raft cluster.
 	err = s.createRaftCluster()
 	if err != nil {
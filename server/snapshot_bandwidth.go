@@ -0,0 +1,40 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"strconv"
+
+	"github.com/pingcap/pd/server/schedule/operator"
+)
+
+// snapshotBandwidthCapKey is the gRPC metadata key in the store heartbeat
+// responses for the bandwidth per second in bytes the snapshots sent by the
+// store are capped at. It is absent if there is no cap.
+const snapshotBandwidthCapKey = "pd-snapshot-bandwidth-cap"
+
+// snapshotBandwidthCapPairs returns the metadata capping the snapshot
+// bandwidth of the store, which is only sent to the stores advertising the
+// capability.
+func (c *RaftCluster) snapshotBandwidthCapPairs(storeID uint64) []string {
+	store := c.GetStore(storeID)
+	if store == nil || !store.HasCapability(operator.CapabilitySnapshotBandwidthCap) {
+		return nil
+	}
+	bandwidth := c.GetOperatorController().GetStoreSnapshotBandwidth(storeID)
+	if bandwidth == 0 {
+		return nil
+	}
+	return []string{snapshotBandwidthCapKey, strconv.FormatUint(bandwidth, 10)}
+}
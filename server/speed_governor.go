@@ -0,0 +1,285 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/pkg/logutil"
+	"github.com/pingcap/pd/server/namespace"
+	"github.com/pingcap/pd/server/schedule"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// speedGovernorInterval is the window in which the ended operators are
+	// counted to adjust the speed.
+	speedGovernorInterval = time.Minute
+	// speedGovernorMinSamples is the least number of the ended operators in
+	// a window to slow down scheduling, so that a few failures in an idle
+	// cluster do not throttle it.
+	speedGovernorMinSamples = 10
+	// Scheduling is slowed down if the ratio of the failed operators in a
+	// window is above speedGovernorHighFailureRatio, and sped up if it is
+	// below speedGovernorLowFailureRatio.
+	speedGovernorHighFailureRatio = 0.3
+	speedGovernorLowFailureRatio  = 0.05
+	// minScheduleSpeedScale is the least scale the governor slows down to.
+	minScheduleSpeedScale = 0.125
+	// speedGovernorEventBufferSize is the number of the operator events
+	// buffered for the governor.
+	speedGovernorEventBufferSize = 1024
+)
+
+// ScheduleSpeedOverride is the scale of the schedule limits set by users,
+// which replaces the one adjusted by the governor. It is persisted so that it
+// stays after the leader changes.
+type ScheduleSpeedOverride struct {
+	Scale   float64   `json:"scale"`
+	SetTime time.Time `json:"set_time"`
+}
+
+// ScheduleSpeedStatus is the status of the schedule speed governor.
+type ScheduleSpeedStatus struct {
+	// Scale is the factor all schedule limits are multiplied by.
+	Scale float64 `json:"scale"`
+	// AutoScale is the scale adjusted by the governor according to the
+	// recent operator failures.
+	AutoScale float64                `json:"auto_scale"`
+	Override  *ScheduleSpeedOverride `json:"override,omitempty"`
+	// Finished and Failed are the operators ended in the current window.
+	Finished uint64 `json:"finished"`
+	Failed   uint64 `json:"failed"`
+	// AdjustTime is when the auto scale was changed last time.
+	AdjustTime time.Time `json:"adjust_time"`
+}
+
+// speedGovernor scales the schedule limits down when the operators fail or
+// time out frequently, such as when the stores are flapping or the network
+// is unstable, and back up once they are healthy. It is kept apart from the
+// lock of the cluster, since the limits are read by the schedulers
+// frequently.
+type speedGovernor struct {
+	sync.RWMutex
+	autoScale  float64
+	override   *ScheduleSpeedOverride
+	finished   uint64
+	failed     uint64
+	adjustTime time.Time
+}
+
+func newSpeedGovernor() *speedGovernor {
+	return &speedGovernor{autoScale: 1}
+}
+
+func (g *speedGovernor) observe(e *schedule.Event) {
+	g.Lock()
+	defer g.Unlock()
+	switch e.Type {
+	case schedule.EventOperatorFinish:
+		g.finished++
+	case schedule.EventOperatorFail:
+		g.failed++
+	}
+}
+
+// adjust changes the auto scale by the operators ended in the window, and
+// starts a new window. It returns the old and the new auto scale.
+func (g *speedGovernor) adjust(now time.Time) (float64, float64) {
+	g.Lock()
+	defer g.Unlock()
+	old := g.autoScale
+	total := g.finished + g.failed
+	ratio := 0.0
+	if total > 0 {
+		ratio = float64(g.failed) / float64(total)
+	}
+	switch {
+	case total >= speedGovernorMinSamples && ratio > speedGovernorHighFailureRatio:
+		g.autoScale = math.Max(g.autoScale/2, minScheduleSpeedScale)
+	case ratio < speedGovernorLowFailureRatio:
+		g.autoScale = math.Min(g.autoScale*2, 1)
+	}
+	if g.autoScale != old {
+		g.adjustTime = now
+	}
+	g.finished, g.failed = 0, 0
+	return old, g.autoScale
+}
+
+func (g *speedGovernor) getScale() float64 {
+	g.RLock()
+	defer g.RUnlock()
+	if g.override != nil {
+		return g.override.Scale
+	}
+	return g.autoScale
+}
+
+// scaleLimit scales the limit, keeping positive limits at least 1 so that
+// scheduling is slowed down rather than stopped.
+func (g *speedGovernor) scaleLimit(limit uint64) uint64 {
+	if limit == 0 {
+		return 0
+	}
+	scale := g.getScale()
+	if scale >= 1 {
+		return limit
+	}
+	return uint64(math.Max(math.Ceil(float64(limit)*scale), 1))
+}
+
+func (g *speedGovernor) status() *ScheduleSpeedStatus {
+	g.RLock()
+	defer g.RUnlock()
+	status := &ScheduleSpeedStatus{
+		Scale:      g.autoScale,
+		AutoScale:  g.autoScale,
+		Override:   g.override,
+		Finished:   g.finished,
+		Failed:     g.failed,
+		AdjustTime: g.adjustTime,
+	}
+	if g.override != nil {
+		status.Scale = g.override.Scale
+	}
+	return status
+}
+
+func (g *speedGovernor) updateMetrics() {
+	g.RLock()
+	defer g.RUnlock()
+	scheduleSpeedScaleGauge.WithLabelValues("auto").Set(g.autoScale)
+	override := 0.0
+	if g.override != nil {
+		override = g.override.Scale
+	}
+	scheduleSpeedScaleGauge.WithLabelValues("override").Set(override)
+}
+
+// governedScheduleOptions scales the schedule limits of the namespaces by the
+// governor. It is the only place the limits are scaled, so that the cluster
+// and the namespace clusters read the same limits.
+type governedScheduleOptions struct {
+	namespace.ScheduleOptions
+	governor *speedGovernor
+}
+
+func (o governedScheduleOptions) GetLeaderScheduleLimit(name string) uint64 {
+	return o.governor.scaleLimit(o.ScheduleOptions.GetLeaderScheduleLimit(name))
+}
+
+func (o governedScheduleOptions) GetRegionScheduleLimit(name string) uint64 {
+	return o.governor.scaleLimit(o.ScheduleOptions.GetRegionScheduleLimit(name))
+}
+
+func (o governedScheduleOptions) GetReplicaScheduleLimit(name string) uint64 {
+	return o.governor.scaleLimit(o.ScheduleOptions.GetReplicaScheduleLimit(name))
+}
+
+func (o governedScheduleOptions) GetMergeScheduleLimit(name string) uint64 {
+	return o.governor.scaleLimit(o.ScheduleOptions.GetMergeScheduleLimit(name))
+}
+
+func (o governedScheduleOptions) GetHotRegionScheduleLimit(name string) uint64 {
+	return o.governor.scaleLimit(o.ScheduleOptions.GetHotRegionScheduleLimit(name))
+}
+
+func (c *RaftCluster) loadScheduleSpeedOverride() error {
+	var override ScheduleSpeedOverride
+	ok, err := c.storage.LoadScheduleSpeedOverride(&override)
+	if err != nil {
+		return err
+	}
+	c.governor.Lock()
+	defer c.governor.Unlock()
+	c.governor.override = nil
+	if ok {
+		c.governor.override = &override
+	}
+	return nil
+}
+
+// SetScheduleSpeed overrides the scale of the schedule limits, which must be
+// in (0, 1], until it is reset by ResetScheduleSpeed.
+func (c *RaftCluster) SetScheduleSpeed(scale float64) (*ScheduleSpeedOverride, error) {
+	if scale <= 0 || scale > 1 {
+		return nil, errcode.NewInvalidInputErr(errors.Errorf("schedule speed scale %v should be in (0, 1]", scale))
+	}
+	override := &ScheduleSpeedOverride{Scale: scale, SetTime: time.Now()}
+	c.governor.Lock()
+	defer c.governor.Unlock()
+	if err := c.storage.SaveScheduleSpeedOverride(override); err != nil {
+		return nil, err
+	}
+	c.governor.override = override
+	log.Warn("schedule speed is overridden", zap.Float64("scale", scale))
+	return override, nil
+}
+
+// ResetScheduleSpeed removes the override of the scale of the schedule
+// limits, so that the governor takes effect again.
+func (c *RaftCluster) ResetScheduleSpeed() error {
+	c.governor.Lock()
+	defer c.governor.Unlock()
+	if c.governor.override == nil {
+		return errcode.NewNotFoundErr(errors.New("schedule speed is not overridden"))
+	}
+	if err := c.storage.DeleteScheduleSpeedOverride(); err != nil {
+		return err
+	}
+	c.governor.override = nil
+	log.Info("schedule speed override is reset")
+	return nil
+}
+
+// GetScheduleSpeed returns the status of the schedule speed governor.
+func (c *RaftCluster) GetScheduleSpeed() *ScheduleSpeedStatus {
+	return c.governor.status()
+}
+
+// governScheduleSpeed counts the ended operators and adjusts the speed once
+// a window.
+func (c *RaftCluster) governScheduleSpeed(sub *schedule.Subscription) {
+	defer logutil.LogPanic()
+	defer c.wg.Done()
+	defer c.events.Unsubscribe(sub)
+
+	ticker := time.NewTicker(speedGovernorInterval)
+	defer ticker.Stop()
+	c.governor.updateMetrics()
+	for {
+		select {
+		case <-c.quit:
+			log.Info("schedule speed governor has been stopped")
+			return
+		case e := <-sub.C:
+			c.governor.observe(e)
+		case now := <-ticker.C:
+			if old, scale := c.governor.adjust(now); scale < old {
+				log.Warn("operators fail frequently, slow down scheduling",
+					zap.Float64("old-scale", old), zap.Float64("new-scale", scale))
+			} else if scale > old {
+				log.Info("operators recover, speed up scheduling",
+					zap.Float64("old-scale", old), zap.Float64("new-scale", scale))
+			}
+			c.governor.updateMetrics()
+		}
+	}
+}
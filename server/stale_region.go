@@ -0,0 +1,156 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"time"
+
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// defaultAcceptStaleTTL is how long the stale regions from a store are
+// accepted if the TTL is not specified.
+const defaultAcceptStaleTTL = 10 * time.Minute
+
+// ErrAcceptStaleNotConfirmed is returned when accepting the stale regions of a
+// store is not confirmed.
+var ErrAcceptStaleNotConfirmed = errors.New("the cached regions led by the store will be replaced by the stale ones it reports, which may roll back splits, merges and membership changes; confirm to proceed")
+
+// StaleAcceptance records that the region heartbeats led by a store are
+// accepted even if their epochs are older than the cached ones. It is for the
+// store restored from an old backup, whose regions are rejected forever
+// otherwise. It is not persisted, so it has to be confirmed again after the PD
+// leader changes.
+type StaleAcceptance struct {
+	StoreID    uint64    `json:"store_id"`
+	Reason     string    `json:"reason"`
+	StartTime  time.Time `json:"start_time"`
+	ExpireTime time.Time `json:"expire_time"`
+	// AcceptedRegions are the regions whose stale epochs have been accepted.
+	AcceptedRegions []uint64 `json:"accepted_regions"`
+}
+
+func (a *StaleAcceptance) clone() *StaleAcceptance {
+	cloned := *a
+	cloned.AcceptedRegions = append([]uint64(nil), a.AcceptedRegions...)
+	return &cloned
+}
+
+// AcceptStaleFromStore accepts the stale region heartbeats led by the store
+// for ttl, or the default TTL if ttl is not positive. It must be confirmed
+// since the newer region metas in PD are overwritten.
+func (c *RaftCluster) AcceptStaleFromStore(storeID uint64, reason string, ttl time.Duration, confirm bool) (*StaleAcceptance, error) {
+	if !confirm {
+		return nil, errcode.NewInvalidInputErr(ErrAcceptStaleNotConfirmed)
+	}
+	if ttl <= 0 {
+		ttl = defaultAcceptStaleTTL
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	store := c.GetStore(storeID)
+	if store == nil {
+		return nil, core.NewStoreNotFoundErr(storeID)
+	}
+	if store.IsTombstone() {
+		return nil, core.StoreTombstonedErr{StoreID: storeID}
+	}
+	now := time.Now()
+	a := &StaleAcceptance{
+		StoreID:    storeID,
+		Reason:     reason,
+		StartTime:  now,
+		ExpireTime: now.Add(ttl),
+	}
+	if old, ok := c.staleAcceptances[storeID]; ok {
+		a.StartTime = old.StartTime
+		a.AcceptedRegions = old.AcceptedRegions
+	}
+	c.staleAcceptances[storeID] = a
+	log.Warn("start accepting stale regions from store",
+		zap.Uint64("store-id", storeID),
+		zap.String("reason", reason),
+		zap.Duration("ttl", ttl))
+	return a.clone(), nil
+}
+
+// StopAcceptStaleFromStore stops accepting the stale regions from the store.
+func (c *RaftCluster) StopAcceptStaleFromStore(storeID uint64) error {
+	c.Lock()
+	defer c.Unlock()
+	a, ok := c.staleAcceptances[storeID]
+	if !ok {
+		return errcode.NewNotFoundErr(errors.Errorf("store %d is not accepting stale regions", storeID))
+	}
+	c.stopAcceptStaleLocked(a)
+	return nil
+}
+
+func (c *RaftCluster) stopAcceptStaleLocked(a *StaleAcceptance) {
+	delete(c.staleAcceptances, a.StoreID)
+	log.Warn("stop accepting stale regions from store",
+		zap.Uint64("store-id", a.StoreID),
+		zap.Int("accepted-regions", len(a.AcceptedRegions)))
+}
+
+// GetStaleAcceptance returns the acceptance of the stale regions from the
+// store, or nil if the store is not accepting stale regions.
+func (c *RaftCluster) GetStaleAcceptance(storeID uint64) *StaleAcceptance {
+	c.RLock()
+	defer c.RUnlock()
+	if a, ok := c.staleAcceptances[storeID]; ok {
+		return a.clone()
+	}
+	return nil
+}
+
+// acceptsStaleLocked checks if the stale epoch of the region is accepted,
+// which requires the region to be led by a store accepting stale regions.
+func (c *RaftCluster) acceptsStaleLocked(region *core.RegionInfo) bool {
+	a, ok := c.staleAcceptances[region.GetLeader().GetStoreId()]
+	return ok && time.Now().Before(a.ExpireTime)
+}
+
+// recordStaleLocked records the stale region which replaces the newer one in
+// the cache.
+func (c *RaftCluster) recordStaleLocked(region *core.RegionInfo, newer *metapb.Region) {
+	a, ok := c.staleAcceptances[region.GetLeader().GetStoreId()]
+	if !ok {
+		return
+	}
+	a.AcceptedRegions = append(a.AcceptedRegions, region.GetID())
+	log.Warn("stale region is accepted",
+		zap.Uint64("store-id", a.StoreID),
+		zap.Uint64("region-id", region.GetID()),
+		zap.Stringer("region-meta", core.RegionToHexMeta(region.GetMeta())),
+		zap.Stringer("newer-region-meta", core.RegionToHexMeta(newer)))
+}
+
+// checkStaleAcceptances stops the expired acceptances of stale regions.
+func (c *RaftCluster) checkStaleAcceptances() {
+	c.Lock()
+	defer c.Unlock()
+	now := time.Now()
+	for _, a := range c.staleAcceptances {
+		if !now.Before(a.ExpireTime) {
+			c.stopAcceptStaleLocked(a)
+		}
+	}
+}
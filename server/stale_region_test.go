@@ -0,0 +1,75 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/server/core"
+)
+
+var _ = Suite(&testStaleRegionSuite{})
+
+type testStaleRegionSuite struct{}
+
+func newStaleTestRegion(regionID uint64, start, end string, version uint64, leaderStore uint64) *core.RegionInfo {
+	peers := []*metapb.Peer{{Id: regionID*10 + 1, StoreId: 1}, {Id: regionID*10 + 2, StoreId: 2}}
+	meta := &metapb.Region{
+		Id:          regionID,
+		StartKey:    []byte(start),
+		EndKey:      []byte(end),
+		Peers:       peers,
+		RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: version},
+	}
+	return core.NewRegionInfo(meta, peers[leaderStore-1])
+}
+
+func (s *testStaleRegionSuite) TestAcceptStale(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	tc := newTestCluster(opt)
+	for id := uint64(1); id <= 2; id++ {
+		c.Assert(tc.addRegionStore(id, 0), IsNil)
+	}
+	// The region is split after store 1 is backed up.
+	c.Assert(tc.processRegionHeartbeat(newStaleTestRegion(1, "a", "b", 2, 2)), IsNil)
+	c.Assert(tc.processRegionHeartbeat(newStaleTestRegion(2, "b", "c", 2, 2)), IsNil)
+	stale := newStaleTestRegion(1, "a", "c", 1, 1)
+	c.Assert(tc.processRegionHeartbeat(stale), NotNil)
+
+	_, err = tc.AcceptStaleFromStore(1, "restored", time.Hour, false)
+	c.Assert(err, NotNil)
+	_, err = tc.AcceptStaleFromStore(3, "restored", time.Hour, true)
+	c.Assert(err, NotNil)
+	_, err = tc.AcceptStaleFromStore(1, "restored", time.Hour, true)
+	c.Assert(err, IsNil)
+
+	// Only the regions led by store 1 are accepted.
+	c.Assert(tc.processRegionHeartbeat(newStaleTestRegion(2, "b", "c", 1, 2)), NotNil)
+	c.Assert(tc.processRegionHeartbeat(stale), IsNil)
+	c.Assert(tc.GetRegion(1).GetEndKey(), DeepEquals, []byte("c"))
+	c.Assert(tc.GetRegion(2), IsNil)
+	// The region is not stale anymore.
+	c.Assert(tc.processRegionHeartbeat(stale), IsNil)
+	c.Assert(tc.GetStaleAcceptance(1).AcceptedRegions, DeepEquals, []uint64{1})
+
+	// The acceptance expires.
+	tc.staleAcceptances[1].ExpireTime = time.Now()
+	c.Assert(tc.processRegionHeartbeat(newStaleTestRegion(1, "a", "c", 0, 1)), NotNil)
+	tc.checkStaleAcceptances()
+	c.Assert(tc.GetStaleAcceptance(1), IsNil)
+	c.Assert(tc.StopAcceptStaleFromStore(1), NotNil)
+}
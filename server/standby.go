@@ -0,0 +1,187 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/pkg/logutil"
+	"github.com/pingcap/pd/server/config"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/namespace"
+	"github.com/pingcap/pd/server/statistics"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// standbyHotCacheSyncInterval is the interval to take the hot regions
+	// from the leader, which is the interval the regions report their flows.
+	standbyHotCacheSyncInterval = statistics.RegionHeartBeatReportInterval * time.Second
+	hotWriteRegionsURL          = "/pd/api/v1/hotspot/regions/write"
+	hotReadRegionsURL           = "/pd/api/v1/hotspot/regions/read"
+)
+
+// standbyCluster is the region cache, the region statistics and the hot cache
+// kept warm by a follower with the regions synchronized from the leader and
+// the hot regions taken from it. The follower reuses them once it becomes the
+// leader, so that the regions and the hot regions are known without waiting
+// for them to be reported by heartbeats from scratch.
+//
+// The synchronized regions carry no leader, so they are not counted by the
+// prepare checker. The promoted cluster still waits for the regions to be
+// reported before scheduling, and their first heartbeats are counted since
+// the cached regions have no leader.
+type standbyCluster struct {
+	sync.Mutex
+	core         *core.BasicCluster
+	regionStats  *statistics.RegionStatistics
+	hotSpotCache *statistics.HotSpotCache
+	since        time.Time
+}
+
+// startStandby loads the stores and the regions into a standby cluster, which
+// is fed by the region syncer until the cluster starts. It is a no-op if the
+// cluster is running, already a standby, or not bootstrapped.
+func (c *RaftCluster) startStandby(opt *config.ScheduleOption, storage *core.Storage, classifier namespace.Classifier) error {
+	c.Lock()
+	defer c.Unlock()
+	if c.running || c.standby != nil {
+		return nil
+	}
+	if ok, err := storage.LoadMeta(&metapb.Cluster{}); err != nil || !ok {
+		return err
+	}
+
+	start := time.Now()
+	standby := &standbyCluster{
+		core:         core.NewBasicCluster(),
+		regionStats:  statistics.NewRegionStatistics(opt, classifier),
+		hotSpotCache: statistics.NewHotSpotCache(),
+		since:        start,
+	}
+	if err := storage.LoadStores(standby.core.PutStore); err != nil {
+		return err
+	}
+	if err := storage.LoadRegions(standby.core.PutRegion); err != nil {
+		return err
+	}
+	for _, region := range standby.core.GetRegions() {
+		standby.regionStats.Observe(region, standby.core.GetRegionStores(region))
+	}
+	c.standby = standby
+	log.Info("standby cluster is started",
+		zap.Int("stores", standby.core.GetStoreCount()),
+		zap.Int("regions", standby.core.GetRegionCount()),
+		zap.Duration("cost", time.Since(start)))
+	return nil
+}
+
+// observeSyncedRegion applies the region synchronized from the leader to the
+// standby cluster.
+func (c *RaftCluster) observeSyncedRegion(region *core.RegionInfo) {
+	c.RLock()
+	standby := c.standby
+	c.RUnlock()
+	if standby == nil {
+		return
+	}
+	standby.Lock()
+	defer standby.Unlock()
+	standby.core.PutRegion(region)
+	standby.regionStats.Observe(region, standby.core.GetRegionStores(region))
+}
+
+// syncStandbyHotRegions takes the hot regions from the leader into the hot
+// cache of the standby cluster. It is a no-op if there is no standby.
+func (c *RaftCluster) syncStandbyHotRegions(leaderURL string) error {
+	c.RLock()
+	standby := c.standby
+	c.RUnlock()
+	if standby == nil {
+		return nil
+	}
+	for kind, url := range map[statistics.FlowKind]string{
+		statistics.WriteFlow: hotWriteRegionsURL,
+		statistics.ReadFlow:  hotReadRegionsURL,
+	} {
+		infos, err := requestHotRegions(leaderURL + url)
+		if err != nil {
+			return err
+		}
+		standby.hotSpotCache.Restore(kind, infos)
+	}
+	return nil
+}
+
+// warmStandbyHotCache takes the hot regions from the leader periodically
+// until the context is done.
+func (c *RaftCluster) warmStandbyHotCache(ctx context.Context, leaderURL string) {
+	defer logutil.LogPanic()
+	ticker := time.NewTicker(standbyHotCacheSyncInterval)
+	defer ticker.Stop()
+	for {
+		if err := c.syncStandbyHotRegions(leaderURL); err != nil {
+			log.Warn("failed to take hot regions from leader", zap.String("leader", leaderURL), zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func requestHotRegions(url string) (*statistics.StoreHotRegionInfos, error) {
+	resp, err := dialClient.Get(url)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status %s", resp.Status)
+	}
+	var infos *statistics.StoreHotRegionInfos
+	if err := json.NewDecoder(resp.Body).Decode(&infos); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return infos, nil
+}
+
+// restore hands the warm region cache, region statistics and hot cache to the
+// cluster being started. The stores are reloaded by the cluster, since the
+// leader may have changed them. The cluster keeps its own prepare checker, so
+// the collect timeout counts from the promotion. It is a no-op for nil.
+func (s *standbyCluster) restore(c *RaftCluster) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	for _, store := range s.core.GetStores() {
+		s.core.DeleteStore(store)
+	}
+	c.core = s.core
+	c.regionStats = s.regionStats
+	c.hotSpotCache = s.hotSpotCache
+	log.Info("promote the standby cluster",
+		zap.Int("regions", s.core.GetRegionCount()),
+		zap.Duration("standby-time", time.Since(s.since)))
+}
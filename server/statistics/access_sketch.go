@@ -0,0 +1,112 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statistics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	accessSketchDepth = 4
+	accessSketchWidth = 1 << 16
+	// accessSketchDecayInterval is the interval to halve the counters, so
+	// that only the recent accesses are counted.
+	accessSketchDecayInterval = 10 * time.Minute
+	// maxAccessCount is the count the counters saturate at.
+	maxAccessCount = 1<<8 - 1
+)
+
+// accessSketchSeeds are the seeds of the hash functions of the rows.
+var accessSketchSeeds = [accessSketchDepth]uint64{
+	0x9e3779b97f4a7c15, 0xbf58476d1ce4e5b9, 0x94d049bb133111eb, 0x2545f4914f6cdd1d,
+}
+
+// AccessSketch is a count-min sketch of the recent access epochs of the
+// regions, where an epoch is a region heartbeat reporting reads or writes. A
+// region is regarded warm only if it is accessed in several epochs, so the
+// regions briefly touched by a scan stay cold. The counters are halved
+// periodically, and the memory does not grow with the number of regions.
+//
+// It is lock free, since it is written by every region heartbeat and read by
+// the checkers and the schedulers for every region they visit. The racing
+// updates may lose an increment, which only underestimates the accesses of
+// a region slightly.
+type AccessSketch struct {
+	counters [accessSketchDepth][accessSketchWidth]uint32
+	// lastDecay is the unix nanoseconds the counters are halved last time.
+	lastDecay int64
+}
+
+// NewAccessSketch creates an AccessSketch.
+func NewAccessSketch() *AccessSketch {
+	return &AccessSketch{lastDecay: time.Now().UnixNano()}
+}
+
+func accessSketchIndex(regionID uint64, row int) int {
+	// splitmix64 finalizer
+	x := regionID ^ accessSketchSeeds[row]
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	x ^= x >> 31
+	return int(x % accessSketchWidth)
+}
+
+// Observe records an access epoch of the region. Only the minimal counters
+// are increased, which reduces the overestimation caused by collisions.
+func (s *AccessSketch) Observe(regionID uint64) {
+	now := time.Now().UnixNano()
+	if last := atomic.LoadInt64(&s.lastDecay); now-last >= int64(accessSketchDecayInterval) &&
+		atomic.CompareAndSwapInt64(&s.lastDecay, last, now) {
+		s.decay()
+	}
+	min := s.estimate(regionID)
+	if min >= maxAccessCount {
+		return
+	}
+	for row := 0; row < accessSketchDepth; row++ {
+		atomic.CompareAndSwapUint32(&s.counters[row][accessSketchIndex(regionID, row)], min, min+1)
+	}
+}
+
+// Estimate returns the estimated number of the recent access epochs of the
+// region, which is never less than the real one unless updates race.
+func (s *AccessSketch) Estimate(regionID uint64) uint64 {
+	return uint64(s.estimate(regionID))
+}
+
+func (s *AccessSketch) estimate(regionID uint64) uint32 {
+	min := uint32(maxAccessCount)
+	for row := 0; row < accessSketchDepth; row++ {
+		if c := atomic.LoadUint32(&s.counters[row][accessSketchIndex(regionID, row)]); c < min {
+			min = c
+		}
+	}
+	return min
+}
+
+// decay halves the counters. It is run by the only observer which advances
+// lastDecay.
+func (s *AccessSketch) decay() {
+	for row := range s.counters {
+		for i := range s.counters[row] {
+			for {
+				c := atomic.LoadUint32(&s.counters[row][i])
+				if c == 0 || atomic.CompareAndSwapUint32(&s.counters[row][i], c, c>>1) {
+					break
+				}
+			}
+		}
+	}
+}
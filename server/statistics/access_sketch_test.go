@@ -0,0 +1,82 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statistics
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&testAccessSketchSuite{})
+
+type testAccessSketchSuite struct{}
+
+func (t *testAccessSketchSuite) TestEstimate(c *C) {
+	s := NewAccessSketch()
+	c.Assert(s.Estimate(1), Equals, uint64(0))
+	for i := 0; i < 5; i++ {
+		s.Observe(1)
+	}
+	s.Observe(2)
+	c.Assert(s.Estimate(1), Equals, uint64(5))
+	c.Assert(s.Estimate(2), Equals, uint64(1))
+
+	// The estimations are never less than the real counts with collisions.
+	for id := uint64(100); id < 100+accessSketchWidth*2; id++ {
+		s.Observe(id)
+	}
+	c.Assert(s.Estimate(1) >= 5, IsTrue)
+	c.Assert(s.Estimate(2) >= 1, IsTrue)
+}
+
+func (t *testAccessSketchSuite) TestSaturate(c *C) {
+	s := NewAccessSketch()
+	for i := 0; i < 300; i++ {
+		s.Observe(1)
+	}
+	c.Assert(s.Estimate(1), Equals, uint64(255))
+}
+
+func (t *testAccessSketchSuite) TestDecay(c *C) {
+	s := NewAccessSketch()
+	for i := 0; i < 5; i++ {
+		s.Observe(1)
+	}
+	s.lastDecay = time.Now().Add(-accessSketchDecayInterval).UnixNano()
+	s.Observe(1)
+	c.Assert(s.Estimate(1), Equals, uint64(3))
+	s.Observe(1)
+	c.Assert(s.Estimate(1), Equals, uint64(4))
+}
+
+func (t *testAccessSketchSuite) TestConcurrent(c *C) {
+	s := NewAccessSketch()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := uint64(1); id <= 1000; id++ {
+				s.Observe(id)
+				s.Estimate(id)
+			}
+		}()
+	}
+	wg.Wait()
+	for id := uint64(1); id <= 1000; id++ {
+		c.Assert(s.Estimate(id) >= 1, IsTrue)
+	}
+}
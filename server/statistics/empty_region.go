@@ -0,0 +1,97 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statistics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pingcap/pd/server/core"
+)
+
+// EmptyRegionTracker records since when the regions have been empty, so that
+// the regions left empty for long, such as the ones of dropped tables, can be
+// told from the ones just split. A region is empty if it has no keys and its
+// approximate size is the minimum. It is observed with every heartbeat, since
+// the heartbeats of an empty region rarely change it.
+type EmptyRegionTracker struct {
+	sync.RWMutex
+	since map[uint64]time.Time
+}
+
+// NewEmptyRegionTracker creates an EmptyRegionTracker.
+func NewEmptyRegionTracker() *EmptyRegionTracker {
+	return &EmptyRegionTracker{since: make(map[uint64]time.Time)}
+}
+
+func isRegionEmpty(region *core.RegionInfo) bool {
+	return region.GetApproximateSize() <= core.EmptyRegionApproximateSize && region.GetApproximateKeys() == 0
+}
+
+// Observe records whether the region is empty now.
+func (t *EmptyRegionTracker) Observe(region *core.RegionInfo) {
+	empty := isRegionEmpty(region)
+	t.RLock()
+	_, tracked := t.since[region.GetID()]
+	t.RUnlock()
+	if empty == tracked {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+	if empty {
+		if _, ok := t.since[region.GetID()]; !ok {
+			t.since[region.GetID()] = time.Now()
+		}
+	} else {
+		delete(t.since, region.GetID())
+	}
+}
+
+// Remove stops tracking the region, which is merged or replaced.
+func (t *EmptyRegionTracker) Remove(regionID uint64) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.since, regionID)
+}
+
+// IsLongEmpty returns if the region has been empty for at least minDuration.
+func (t *EmptyRegionTracker) IsLongEmpty(regionID uint64, minDuration time.Duration) bool {
+	t.RLock()
+	defer t.RUnlock()
+	since, ok := t.since[regionID]
+	return ok && time.Since(since) >= minDuration
+}
+
+// GetEmptyRegions returns the IDs of the regions which have been empty for at
+// least minDuration.
+func (t *EmptyRegionTracker) GetEmptyRegions(minDuration time.Duration) []uint64 {
+	t.RLock()
+	defer t.RUnlock()
+	var ids []uint64
+	now := time.Now()
+	for id, since := range t.since {
+		if now.Sub(since) >= minDuration {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Len returns the number of the empty regions.
+func (t *EmptyRegionTracker) Len() int {
+	t.RLock()
+	defer t.RUnlock()
+	return len(t.since)
+}
@@ -0,0 +1,55 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statistics
+
+import (
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/server/core"
+)
+
+var _ = Suite(&testEmptyRegionSuite{})
+
+type testEmptyRegionSuite struct{}
+
+func (t *testEmptyRegionSuite) TestEmptyRegionTracker(c *C) {
+	tracker := NewEmptyRegionTracker()
+	region := func(id uint64, size, keys int64) *core.RegionInfo {
+		return core.NewRegionInfo(&metapb.Region{Id: id}, nil, core.SetApproximateSize(size), core.SetApproximateKeys(keys))
+	}
+	tracker.Observe(region(1, 1, 0))
+	tracker.Observe(region(2, 1, 1))
+	tracker.Observe(region(3, 2, 0))
+	c.Assert(tracker.Len(), Equals, 1)
+	c.Assert(tracker.IsLongEmpty(1, 0), IsTrue)
+	c.Assert(tracker.IsLongEmpty(1, time.Hour), IsFalse)
+	c.Assert(tracker.IsLongEmpty(2, 0), IsFalse)
+
+	// Observing again does not reset the time it becomes empty.
+	tracker.Observe(region(1, 1, 0))
+	time.Sleep(10 * time.Millisecond)
+	tracker.Observe(region(1, 1, 0))
+	tracker.Observe(region(4, 0, 0))
+	c.Assert(tracker.IsLongEmpty(1, 10*time.Millisecond), IsTrue)
+	c.Assert(tracker.GetEmptyRegions(10*time.Millisecond), DeepEquals, []uint64{1})
+	c.Assert(tracker.GetEmptyRegions(0), HasLen, 2)
+
+	// The region is written.
+	tracker.Observe(region(1, 1, 10))
+	c.Assert(tracker.IsLongEmpty(1, 0), IsFalse)
+	tracker.Remove(4)
+	c.Assert(tracker.Len(), Equals, 0)
+}
@@ -16,6 +16,7 @@ package statistics
 import (
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/pingcap/kvproto/pkg/metapb"
@@ -60,18 +61,97 @@ func (k FlowKind) String() string {
 	return "unimplemented"
 }
 
-// HotStoresStats saves the hotspot peer's statistics.
+// HotStoresStats saves the hotspot peer's statistics. It is sharded by the
+// stores so that the heartbeats of different stores do not contend, and the
+// hot peers are read without locks.
 type HotStoresStats struct {
-	hotStoreStats  map[uint64]cache.Cache         // storeID -> hot regions
-	storesOfRegion map[uint64]map[uint64]struct{} // regionID -> storeIDs
+	shards sync.Map // storeID -> *hotStoreShard
+	// storesOfRegion is sharded by the region IDs.
+	storesOfRegion [regionIndexShards]regionIndexShard
+	// capacity is the number of the hot peers kept for each store, which is
+	// bounded by the stats memory budget. It is accessed atomically.
+	capacity int64
 }
 
 // NewHotStoresStats creates a HotStoresStats
 func NewHotStoresStats() *HotStoresStats {
-	return &HotStoresStats{
-		hotStoreStats:  make(map[uint64]cache.Cache),
-		storesOfRegion: make(map[uint64]map[uint64]struct{}),
+	f := &HotStoresStats{}
+	for i := range f.storesOfRegion {
+		f.storesOfRegion[i].stores = make(map[uint64]map[uint64]struct{})
 	}
+	return f
+}
+
+const regionIndexShards = 16
+
+type regionIndexShard struct {
+	sync.Mutex
+	stores map[uint64]map[uint64]struct{} // regionID -> storeIDs
+}
+
+func (f *HotStoresStats) regionIndex(regionID uint64) *regionIndexShard {
+	return &f.storesOfRegion[regionID%regionIndexShards]
+}
+
+// hotStoreShard is the hot peers of a store. The updates are serialized by
+// its lock, and peers mirrors stats for the reads without locks.
+type hotStoreShard struct {
+	sync.Mutex
+	stats cache.Cache
+	peers sync.Map // regionID -> *HotSpotPeerStat
+}
+
+func newHotStoreShard() *hotStoreShard {
+	shard := &hotStoreShard{}
+	shard.stats = cache.NewCacheWithEvict(statCacheMaxLen, cache.TwoQueueCache, func(regionID uint64, _ interface{}) {
+		shard.peers.Delete(regionID)
+	})
+	return shard
+}
+
+func (s *hotStoreShard) get(regionID uint64) *HotSpotPeerStat {
+	if v, ok := s.peers.Load(regionID); ok {
+		return v.(*HotSpotPeerStat)
+	}
+	return nil
+}
+
+func (s *hotStoreShard) elems() []*HotSpotPeerStat {
+	values := s.stats.Elems()
+	stats := make([]*HotSpotPeerStat, len(values))
+	for i := range values {
+		stats[i] = values[i].Value.(*HotSpotPeerStat)
+	}
+	return stats
+}
+
+func (f *HotStoresStats) getShard(storeID uint64) *hotStoreShard {
+	if v, ok := f.shards.Load(storeID); ok {
+		return v.(*hotStoreShard)
+	}
+	return nil
+}
+
+func (f *HotStoresStats) getOrCreateShard(storeID uint64) *hotStoreShard {
+	if shard := f.getShard(storeID); shard != nil {
+		return shard
+	}
+	v, _ := f.shards.LoadOrStore(storeID, newHotStoreShard())
+	return v.(*hotStoreShard)
+}
+
+func (f *HotStoresStats) getPeerStat(storeID, regionID uint64) *HotSpotPeerStat {
+	if shard := f.getShard(storeID); shard != nil {
+		return shard.get(regionID)
+	}
+	return nil
+}
+
+func (f *HotStoresStats) forEachShard(fn func(storeID uint64, shard *hotStoreShard)) {
+	f.shards.Range(func(k, v interface{}) bool {
+		fn(k.(uint64), v.(*hotStoreShard))
+		return true
+	})
 }
 
 // CheckRegionFlow checks the flow information of region.
@@ -89,12 +169,12 @@ func (f *HotStoresStats) CheckRegionFlow(region *core.RegionInfo, kind FlowKind)
 
 	storeIDs := make(map[uint64]struct{})
 	// gets the storeIDs, including old region and new region
-	ids, ok := f.storesOfRegion[region.GetID()]
-	if ok {
-		for storeID := range ids {
-			storeIDs[storeID] = struct{}{}
-		}
+	index := f.regionIndex(region.GetID())
+	index.Lock()
+	for storeID := range index.stores[region.GetID()] {
+		storeIDs[storeID] = struct{}{}
 	}
+	index.Unlock()
 
 	for _, peer := range region.GetPeers() {
 		// ReadFlow no need consider the followers.
@@ -142,19 +222,12 @@ func (f *HotStoresStats) CheckRegionFlow(region *core.RegionInfo, kind FlowKind)
 	bytesPerSec = uint64(float64(getBytesFlow()) / float64(reportInterval))
 	keysPerSec = uint64(float64(getKeysFlow()) / float64(reportInterval))
 	for storeID := range storeIDs {
-		var oldRegionStat *HotSpotPeerStat
-
-		hotStoreStats, ok := f.hotStoreStats[storeID]
-		if ok {
-			if v, isExist := hotStoreStats.Peek(region.GetID()); isExist {
-				oldRegionStat = v.(*HotSpotPeerStat)
-				// This is used for the simulator.
-				if Denoising {
-					interval := time.Since(oldRegionStat.LastUpdateTime).Seconds()
-					if interval < minHotRegionReportInterval && !isExpiredInStore(region, storeID) {
-						continue
-					}
-				}
+		oldRegionStat := f.getPeerStat(storeID, region.GetID())
+		// This is used for the simulator.
+		if oldRegionStat != nil && Denoising {
+			interval := time.Since(oldRegionStat.LastUpdateTime).Seconds()
+			if interval < minHotRegionReportInterval && !isExpiredInStore(region, storeID) {
+				continue
 			}
 		}
 
@@ -179,28 +252,83 @@ func (f *HotStoresStats) CheckRegionFlow(region *core.RegionInfo, kind FlowKind)
 // Update updates the items in statistics.
 func (f *HotStoresStats) Update(item *HotSpotPeerStat) {
 	if item.IsNeedDelete() {
-		if hotStoreStat, ok := f.hotStoreStats[item.StoreID]; ok {
-			hotStoreStat.Remove(item.RegionID)
+		if shard := f.getShard(item.StoreID); shard != nil {
+			shard.Lock()
+			shard.stats.Remove(item.RegionID)
+			shard.peers.Delete(item.RegionID)
+			shard.Unlock()
 		}
-		if index, ok := f.storesOfRegion[item.RegionID]; ok {
-			delete(index, item.StoreID)
+		index := f.regionIndex(item.RegionID)
+		index.Lock()
+		if stores, ok := index.stores[item.RegionID]; ok {
+			delete(stores, item.StoreID)
 		}
+		index.Unlock()
 	} else {
-		hotStoreStat, ok := f.hotStoreStats[item.StoreID]
+		shard := f.getOrCreateShard(item.StoreID)
+		shard.Lock()
+		shard.peers.Store(item.RegionID, item)
+		shard.stats.Put(item.RegionID, item)
+		evicted := shard.shrinkLocked(f.getCapacity())
+		shard.Unlock()
+		index := f.regionIndex(item.RegionID)
+		index.Lock()
+		stores, ok := index.stores[item.RegionID]
 		if !ok {
-			hotStoreStat = cache.NewCache(statCacheMaxLen, cache.TwoQueueCache)
-			f.hotStoreStats[item.StoreID] = hotStoreStat
+			stores = make(map[uint64]struct{})
+			index.stores[item.RegionID] = stores
 		}
-		hotStoreStat.Put(item.RegionID, item)
-		index, ok := f.storesOfRegion[item.RegionID]
-		if !ok {
-			index = make(map[uint64]struct{})
+		stores[item.StoreID] = struct{}{}
+		index.Unlock()
+		for _, regionID := range evicted {
+			index := f.regionIndex(regionID)
+			index.Lock()
+			if stores, ok := index.stores[regionID]; ok {
+				delete(stores, item.StoreID)
+				if len(stores) == 0 {
+					delete(index.stores, regionID)
+				}
+			}
+			index.Unlock()
 		}
-		index[item.StoreID] = struct{}{}
-		f.storesOfRegion[item.RegionID] = index
 	}
 }
 
+// removeStaleStores removes the hot peers on the stores which do not exist,
+// and returns the number of the removed peers.
+func (f *HotStoresStats) removeStaleStores(exists func(storeID uint64) bool) int {
+	var count int
+	f.forEachShard(func(storeID uint64, shard *hotStoreShard) {
+		if exists(storeID) {
+			return
+		}
+		f.shards.Delete(storeID)
+		shard.Lock()
+		stats := shard.elems()
+		shard.Unlock()
+		for _, stat := range stats {
+			index := f.regionIndex(stat.RegionID)
+			index.Lock()
+			if stores, ok := index.stores[stat.RegionID]; ok {
+				delete(stores, storeID)
+				if len(stores) == 0 {
+					delete(index.stores, stat.RegionID)
+				}
+			}
+			index.Unlock()
+		}
+		count += len(stats)
+	})
+	return count
+}
+
+func (f *HotStoresStats) hasRegion(regionID uint64) bool {
+	index := f.regionIndex(regionID)
+	index.Lock()
+	defer index.Unlock()
+	return len(index.stores[regionID]) > 0
+}
+
 func (f *HotStoresStats) isRegionHotWithAnyPeers(region *core.RegionInfo, hotThreshold int) bool {
 	for _, peer := range region.GetPeers() {
 		if f.isRegionHotWithPeer(region, peer, hotThreshold) {
@@ -215,13 +343,8 @@ func (f *HotStoresStats) isRegionHotWithPeer(region *core.RegionInfo, peer *meta
 	if peer == nil {
 		return false
 	}
-	storeID := peer.GetStoreId()
-	stats, ok := f.hotStoreStats[storeID]
-	if !ok {
-		return false
-	}
-	if stat, ok := stats.Peek(region.GetID()); ok {
-		return stat.(*HotSpotPeerStat).HotDegree >= hotThreshold
+	if stat := f.getPeerStat(peer.GetStoreId(), region.GetID()); stat != nil {
+		return stat.HotDegree >= hotThreshold
 	}
 	return false
 }
@@ -306,7 +429,7 @@ func (flowStats *hotSpotPeerStatGenerator) GenHotSpotPeerStats(stats *StoresStat
 	return newItem
 }
 
-// HotSpotCache is a cache hold hot regions.
+// HotSpotCache is a cache hold hot regions. It is safe for concurrent use.
 type HotSpotCache struct {
 	writeFlow *HotStoresStats
 	readFlow  *HotStoresStats
@@ -375,59 +498,120 @@ func (w *HotSpotCache) Update(item *HotSpotPeerStat) {
 	}
 }
 
+// RemoveStaleStores removes the hot peers on the stores which do not exist,
+// and returns the number of the removed peers.
+func (w *HotSpotCache) RemoveStaleStores(exists func(storeID uint64) bool) int {
+	return w.writeFlow.removeStaleStores(exists) + w.readFlow.removeStaleStores(exists)
+}
+
 // RegionStats returns hot items according to kind
 func (w *HotSpotCache) RegionStats(kind FlowKind) map[uint64][]*HotSpotPeerStat {
-	var flowMap map[uint64]cache.Cache
+	var flowStats *HotStoresStats
 	switch kind {
 	case WriteFlow:
-		flowMap = w.writeFlow.hotStoreStats
+		flowStats = w.writeFlow
 	case ReadFlow:
-		flowMap = w.readFlow.hotStoreStats
+		flowStats = w.readFlow
 	}
 	res := make(map[uint64][]*HotSpotPeerStat)
-	for storeID, elements := range flowMap {
-		values := elements.Elems()
-		stat, ok := res[storeID]
-		if !ok {
-			stat = make([]*HotSpotPeerStat, len(values))
-			res[storeID] = stat
-		}
-		for i := range values {
-			stat[i] = values[i].Value.(*HotSpotPeerStat)
-		}
-	}
+	flowStats.forEachShard(func(storeID uint64, shard *hotStoreShard) {
+		res[storeID] = shard.elems()
+	})
 	return res
 }
 
-// RandHotRegionFromStore random picks a hot region in specify store.
-func (w *HotSpotCache) RandHotRegionFromStore(storeID uint64, kind FlowKind, hotThreshold int) *HotSpotPeerStat {
-	stats, ok := w.RegionStats(kind)[storeID]
-	if !ok {
+// RandHotRegionFromStore random picks a hot region in specify store, which
+// passes all the filters.
+func (w *HotSpotCache) RandHotRegionFromStore(storeID uint64, kind FlowKind, hotThreshold int, filters ...func(*HotSpotPeerStat) bool) *HotSpotPeerStat {
+	flowStats := w.writeFlow
+	if kind == ReadFlow {
+		flowStats = w.readFlow
+	}
+	shard := flowStats.getShard(storeID)
+	if shard == nil {
 		return nil
 	}
+	stats := shard.elems()
 	for _, i := range rand.Perm(len(stats)) {
-		if stats[i].HotDegree >= hotThreshold {
+		if stats[i].HotDegree < hotThreshold {
+			continue
+		}
+		if passHotFilters(stats[i], filters) {
 			return stats[i]
 		}
 	}
 	return nil
 }
 
+func passHotFilters(stat *HotSpotPeerStat, filters []func(*HotSpotPeerStat) bool) bool {
+	for _, f := range filters {
+		if !f(stat) {
+			return false
+		}
+	}
+	return true
+}
+
 // CollectMetrics collect the hot cache metrics
 func (w *HotSpotCache) CollectMetrics(stats *StoresStats) {
-	for storeID, flowStats := range w.writeFlow.hotStoreStats {
+	w.writeFlow.forEachShard(func(storeID uint64, shard *hotStoreShard) {
 		storeTag := fmt.Sprintf("store-%d", storeID)
 		threshold := calculateWriteHotThresholdWithStore(stats, storeID)
-		hotCacheStatusGauge.WithLabelValues("total_length", storeTag, "write").Set(float64(flowStats.Len()))
+		hotCacheStatusGauge.WithLabelValues("total_length", storeTag, "write").Set(float64(shard.stats.Len()))
 		hotCacheStatusGauge.WithLabelValues("hotThreshold", storeTag, "write").Set(float64(threshold))
-	}
+	})
 
-	for storeID, flowStats := range w.readFlow.hotStoreStats {
+	w.readFlow.forEachShard(func(storeID uint64, shard *hotStoreShard) {
 		storeTag := fmt.Sprintf("store-%d", storeID)
 		threshold := calculateReadHotThresholdWithStore(stats, storeID)
-		hotCacheStatusGauge.WithLabelValues("total_length", storeTag, "read").Set(float64(flowStats.Len()))
+		hotCacheStatusGauge.WithLabelValues("total_length", storeTag, "read").Set(float64(shard.stats.Len()))
 		hotCacheStatusGauge.WithLabelValues("hotThreshold", storeTag, "read").Set(float64(threshold))
+	})
+}
+
+// Restore puts the hot peers taken from another member, such as the leader,
+// into the cache. The peers in infos.AsLeader are the leaders. The peers
+// already in the cache are kept, since they are updated by the heartbeats.
+func (w *HotSpotCache) Restore(kind FlowKind, infos *StoreHotRegionInfos) {
+	if infos == nil {
+		return
+	}
+	flowStats := w.writeFlow
+	if kind == ReadFlow {
+		flowStats = w.readFlow
+	}
+	restore := func(stats StoreHotRegionsStat, isLeader bool) {
+		for storeID, stat := range stats {
+			for _, r := range stat.RegionsStat {
+				if old := flowStats.getPeerStat(storeID, r.RegionID); old != nil && !old.restored {
+					continue
+				}
+				item := &HotSpotPeerStat{
+					RegionID:       r.RegionID,
+					FlowBytes:      r.FlowBytes,
+					FlowKeys:       r.FlowKeys,
+					HotDegree:      r.HotDegree,
+					LastUpdateTime: r.LastUpdateTime,
+					StoreID:        storeID,
+					Kind:           kind,
+					AntiCount:      r.AntiCount,
+					Version:        r.Version,
+					Stats:          NewRollingStats(rollingWindowsSize),
+					isLeader:       isLeader,
+					restored:       true,
+				}
+				item.Stats.Add(float64(r.FlowBytes))
+				flowStats.Update(item)
+			}
+		}
 	}
+	restore(infos.AsPeer, false)
+	restore(infos.AsLeader, true)
+}
+
+// HasRegion checks if any peer of the region is in the cache.
+func (w *HotSpotCache) HasRegion(regionID uint64) bool {
+	return w.writeFlow.hasRegion(regionID) || w.readFlow.hasRegion(regionID)
 }
 
 // IsRegionHot checks if the region is hot.
@@ -493,7 +677,15 @@ func calculateReadHotThreshold(stats *StoresStats) uint64 {
 // RegionStatInformer provides access to a shared informer of statistics.
 type RegionStatInformer interface {
 	IsRegionHot(region *core.RegionInfo) bool
+	// IsRegionWarm returns if the region is accessed in enough recent
+	// heartbeats to be regarded warm.
+	IsRegionWarm(region *core.RegionInfo) bool
+	// IsRegionLongEmpty returns if the region has been empty for
+	// long-empty-region-duration.
+	IsRegionLongEmpty(region *core.RegionInfo) bool
 	RegionWriteStats() map[uint64][]*HotSpotPeerStat
 	RegionReadStats() map[uint64][]*HotSpotPeerStat
-	RandHotRegionFromStore(store uint64, kind FlowKind) *core.RegionInfo
+	// RandHotRegionFromStore randomly picks a hot region in the store, which
+	// is selected by all the options.
+	RandHotRegionFromStore(store uint64, kind FlowKind, opts ...core.RegionOption) *core.RegionInfo
 }
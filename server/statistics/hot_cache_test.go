@@ -0,0 +1,210 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statistics
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/pkg/mock/mockoption"
+	"github.com/pingcap/pd/server/core"
+)
+
+var _ = Suite(&testHotCacheSuite{})
+
+type testHotCacheSuite struct{}
+
+// newHotWriteRegion creates a region with the leader in leaderStore and the
+// followers in the next two stores, which writes enough to be hot.
+func newHotWriteRegion(regionID, leaderStore uint64) *core.RegionInfo {
+	meta := &metapb.Region{Id: regionID}
+	for i := uint64(0); i < 3; i++ {
+		meta.Peers = append(meta.Peers, &metapb.Peer{Id: regionID*10 + i, StoreId: leaderStore + i})
+	}
+	return core.NewRegionInfo(meta, meta.Peers[0],
+		core.SetWrittenBytes(hotWriteRegionMinFlowRate*RegionHeartBeatReportInterval*2),
+		core.SetReportInterval(RegionHeartBeatReportInterval))
+}
+
+func heartbeatHotCache(cache *HotSpotCache, stats *StoresStats, region *core.RegionInfo) {
+	for _, item := range cache.CheckWrite(region, stats) {
+		cache.Update(item)
+	}
+}
+
+func (t *testHotCacheSuite) TestConcurrentUpdate(c *C) {
+	Denoising = false
+	defer func() { Denoising = true }()
+	cache := NewHotSpotCache()
+	stats := NewStoresStats(mockoption.NewScheduleOptions())
+
+	var wg sync.WaitGroup
+	for i := uint64(0); i < 4; i++ {
+		wg.Add(1)
+		go func(leaderStore uint64) {
+			defer wg.Done()
+			for n := 0; n < 3; n++ {
+				for id := uint64(1); id <= 10; id++ {
+					region := newHotWriteRegion(leaderStore*100+id, leaderStore)
+					heartbeatHotCache(cache, stats, region)
+					cache.IsRegionHot(region, 1)
+				}
+				cache.RegionStats(WriteFlow)
+			}
+		}(i*3 + 1)
+	}
+	wg.Wait()
+
+	regionStats := cache.RegionStats(WriteFlow)
+	c.Assert(regionStats, HasLen, 12)
+	for _, peers := range regionStats {
+		c.Assert(peers, HasLen, 10)
+	}
+	c.Assert(cache.IsRegionHot(newHotWriteRegion(101, 1), 2), IsTrue)
+	c.Assert(cache.IsRegionHot(newHotWriteRegion(101, 1), 3), IsFalse)
+	c.Assert(cache.RandHotRegionFromStore(2, WriteFlow, 2), NotNil)
+	c.Assert(cache.RandHotRegionFromStore(2, ReadFlow, 2), IsNil)
+	notRegion101 := func(stat *HotSpotPeerStat) bool { return stat.RegionID != 101 }
+	for i := 0; i < 10; i++ {
+		c.Assert(cache.RandHotRegionFromStore(2, WriteFlow, 2, notRegion101).RegionID, Not(Equals), uint64(101))
+	}
+	none := func(*HotSpotPeerStat) bool { return false }
+	c.Assert(cache.RandHotRegionFromStore(2, WriteFlow, 2, none), IsNil)
+
+	// The region moved out of store 1 is removed from the store.
+	region := newHotWriteRegion(101, 1)
+	region = region.Clone(core.WithRemoveStorePeer(1), core.WithLeader(region.GetStorePeer(2)))
+	heartbeatHotCache(cache, stats, region)
+	c.Assert(cache.RegionStats(WriteFlow)[1], HasLen, 9)
+}
+
+func (t *testHotCacheSuite) TestEvict(c *C) {
+	cache := NewHotSpotCache()
+	stats := NewStoresStats(mockoption.NewScheduleOptions())
+	for id := uint64(1); id <= statCacheMaxLen+10; id++ {
+		heartbeatHotCache(cache, stats, newHotWriteRegion(id, 1))
+	}
+	shard := cache.writeFlow.getShard(1)
+	c.Assert(shard.stats.Len(), Equals, statCacheMaxLen)
+	// The evicted peers are not read any more.
+	var peers int
+	shard.peers.Range(func(k, v interface{}) bool {
+		_, ok := shard.stats.Peek(k.(uint64))
+		c.Assert(ok, IsTrue)
+		peers++
+		return true
+	})
+	c.Assert(peers, Equals, statCacheMaxLen)
+	c.Assert(cache.IsRegionHot(newHotWriteRegion(1, 1), 0), IsFalse)
+	c.Assert(cache.IsRegionHot(newHotWriteRegion(statCacheMaxLen+10, 1), 0), IsTrue)
+}
+
+// BenchmarkHotCacheHeartbeat measures the heartbeats of the regions on
+// different stores updating the hot cache concurrently.
+func BenchmarkHotCacheHeartbeat(b *testing.B) {
+	benchmarkHotCacheHeartbeat(b, nil)
+}
+
+// BenchmarkHotCacheHeartbeatLocked serializes the heartbeats as they were
+// under the lock of the cluster, to compare with BenchmarkHotCacheHeartbeat.
+func BenchmarkHotCacheHeartbeatLocked(b *testing.B) {
+	benchmarkHotCacheHeartbeat(b, &sync.Mutex{})
+}
+
+func benchmarkHotCacheHeartbeat(b *testing.B, mu *sync.Mutex) {
+	Denoising = false
+	defer func() { Denoising = true }()
+	cache := NewHotSpotCache()
+	stats := NewStoresStats(mockoption.NewScheduleOptions())
+	var workers uint64
+	b.RunParallel(func(pb *testing.PB) {
+		leaderStore := atomic.AddUint64(&workers, 1) * 3
+		var id uint64
+		for pb.Next() {
+			id++
+			region := newHotWriteRegion(leaderStore*100000+id%statCacheMaxLen, leaderStore)
+			if mu != nil {
+				mu.Lock()
+			}
+			heartbeatHotCache(cache, stats, region)
+			if mu != nil {
+				mu.Unlock()
+			}
+		}
+	})
+}
+
+func (t *testHotCacheSuite) TestRestore(c *C) {
+	Denoising = false
+	defer func() { Denoising = true }()
+	cache := NewHotSpotCache()
+	stats := NewStoresStats(mockoption.NewScheduleOptions())
+	heartbeatHotCache(cache, stats, newHotWriteRegion(1, 1))
+	heartbeatHotCache(cache, stats, newHotWriteRegion(1, 1))
+
+	restored := &HotRegionsStat{RegionsStat: RegionsStat{
+		{RegionID: 1, FlowBytes: 1, HotDegree: 10},
+		{RegionID: 2, FlowBytes: 1, HotDegree: 10},
+	}}
+	cache.Restore(WriteFlow, &StoreHotRegionInfos{
+		AsPeer:   StoreHotRegionsStat{1: restored},
+		AsLeader: StoreHotRegionsStat{1: restored},
+	})
+	// The peers reported by the heartbeats are kept.
+	c.Assert(cache.IsRegionHot(newHotWriteRegion(1, 1), 1), IsTrue)
+	c.Assert(cache.IsRegionHot(newHotWriteRegion(1, 1), 2), IsFalse)
+	c.Assert(cache.IsRegionHot(newHotWriteRegion(2, 1), 10), IsTrue)
+	for _, stat := range cache.RegionStats(WriteFlow)[1] {
+		if stat.RegionID == 2 {
+			c.Assert(stat.IsLeader(), IsTrue)
+		}
+	}
+	c.Assert(cache.RegionStats(ReadFlow), HasLen, 0)
+	cache.Restore(ReadFlow, nil)
+}
+
+func (t *testHotCacheSuite) TestPeerCapacity(c *C) {
+	Denoising = false
+	defer func() { Denoising = true }()
+	cache := NewHotSpotCache()
+	stats := NewStoresStats(mockoption.NewScheduleOptions())
+	c.Assert(HotPeerCapacity(0, 0, 3), Equals, statCacheMaxLen)
+	c.Assert(HotPeerCapacity(1, 0, 3), Equals, minHotPeersPerStore)
+	capacity := HotPeerCapacity(2*3*hotPeerStatMemory*20+1000, 1000, 3)
+	c.Assert(capacity, Equals, 20)
+
+	cache.SetPeerCapacity(capacity)
+	for id := uint64(1); id <= 30; id++ {
+		heartbeatHotCache(cache, stats, newHotWriteRegion(id, 1))
+	}
+	var count int
+	for _, peers := range cache.RegionStats(WriteFlow) {
+		c.Assert(len(peers) <= capacity, IsTrue)
+		count += len(peers)
+	}
+	c.Assert(cache.EstimateMemory(), Equals, int64(count*hotPeerStatMemory))
+	// The region index drops the evicted peers too.
+	for id := uint64(1); id <= 30; id++ {
+		var hasPeer bool
+		for _, peers := range cache.RegionStats(WriteFlow) {
+			for _, peer := range peers {
+				hasPeer = hasPeer || peer.RegionID == id
+			}
+		}
+		c.Assert(cache.HasRegion(id), Equals, hasPeer)
+	}
+}
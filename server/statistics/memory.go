@@ -0,0 +1,122 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statistics
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+const (
+	// hotPeerStatMemory is the estimated memory of a hot peer, including its
+	// rolling stats and its entries in the cache and the region index.
+	hotPeerStatMemory = 512
+	// minHotPeersPerStore is the least number of the hot peers kept for each
+	// store and flow kind however small the budget is, so that the hot
+	// region scheduling still works.
+	minHotPeersPerStore = 16
+	// rollingStatsOverhead is the estimated memory of a RollingStats besides
+	// its records.
+	rollingStatsOverhead = 64
+)
+
+func estimateRollingStatsMemory(r *RollingStats) int64 {
+	return rollingStatsOverhead + 8*int64(len(r.records))
+}
+
+// EstimateMemory returns the estimated memory of the histories of the store
+// statistics, which are the rolling stats, the used size samples and the long
+// windows.
+func (s *StoresStats) EstimateMemory() int64 {
+	s.RLock()
+	defer s.RUnlock()
+	var memory int64
+	for _, r := range s.rollingStoresStats {
+		r.RLock()
+		memory += estimateRollingStatsMemory(r.bytesWriteRate) + estimateRollingStatsMemory(r.bytesReadRate) +
+			estimateRollingStatsMemory(r.keysWriteRate) + estimateRollingStatsMemory(r.keysReadRate)
+		r.RUnlock()
+	}
+	for _, history := range s.usedSizes {
+		memory += 16 * int64(cap(history.samples))
+	}
+	for _, windows := range s.windows {
+		memory += 48 * storeWindowBuckets * int64(len(windows.windows))
+	}
+	return memory
+}
+
+// HotPeerCapacity returns the number of the hot peers kept for each store and
+// flow kind, so that the hot peers fit in what is left of the budget by the
+// store statistics. It is the default capacity if the budget is 0.
+func HotPeerCapacity(budget, storesMemory int64, stores int) int {
+	if budget <= 0 || stores == 0 {
+		return statCacheMaxLen
+	}
+	capacity := (budget - storesMemory) / (2 * int64(stores) * hotPeerStatMemory)
+	if capacity < minHotPeersPerStore {
+		return minHotPeersPerStore
+	}
+	if capacity > statCacheMaxLen {
+		return statCacheMaxLen
+	}
+	return int(capacity)
+}
+
+func (f *HotStoresStats) setCapacity(capacity int) {
+	atomic.StoreInt64(&f.capacity, int64(capacity))
+}
+
+func (f *HotStoresStats) getCapacity() int {
+	if capacity := atomic.LoadInt64(&f.capacity); capacity > 0 {
+		return int(capacity)
+	}
+	return statCacheMaxLen
+}
+
+// shrinkLocked evicts the peers of the lowest flows if the shard holds more
+// than the capacity, and returns the IDs of their regions. It evicts a tenth
+// more than needed, so that the peers are not sorted for every update.
+func (s *hotStoreShard) shrinkLocked(capacity int) []uint64 {
+	if s.stats.Len() <= capacity || capacity >= statCacheMaxLen {
+		return nil
+	}
+	stats := s.elems()
+	sort.Slice(stats, func(i, j int) bool { return stats[i].FlowBytes < stats[j].FlowBytes })
+	evicted := make([]uint64, 0, len(stats)-capacity+capacity/10)
+	for _, stat := range stats[:len(stats)-capacity+capacity/10] {
+		s.stats.Remove(stat.RegionID)
+		s.peers.Delete(stat.RegionID)
+		evicted = append(evicted, stat.RegionID)
+	}
+	return evicted
+}
+
+// SetPeerCapacity sets the number of the hot peers kept for each store and
+// flow kind. The peers of the lowest flows are evicted when it is exceeded.
+func (w *HotSpotCache) SetPeerCapacity(capacity int) {
+	w.writeFlow.setCapacity(capacity)
+	w.readFlow.setCapacity(capacity)
+}
+
+// EstimateMemory returns the estimated memory of the hot peers.
+func (w *HotSpotCache) EstimateMemory() int64 {
+	var count int
+	for _, flowStats := range []*HotStoresStats{w.writeFlow, w.readFlow} {
+		flowStats.forEachShard(func(_ uint64, shard *hotStoreShard) {
+			count += shard.stats.Len()
+		})
+	}
+	return int64(count) * hotPeerStatMemory
+}
@@ -64,6 +64,14 @@ var (
 			Help:      "Status of the scheduling configurations.",
 		}, []string{"type", "namespace"})
 
+	labelValueStatusGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "label_value_status",
+			Help:      "Totals of the stores with each value of the location labels.",
+		}, []string{"label", "value", "type"})
+
 	regionLabelLevelGauge = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: "pd",
@@ -81,4 +89,5 @@ func init() {
 	prometheus.MustRegister(placementStatusGauge)
 	prometheus.MustRegister(configStatusGauge)
 	prometheus.MustRegister(regionLabelLevelGauge)
+	prometheus.MustRegister(labelValueStatusGauge)
 }
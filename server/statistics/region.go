@@ -41,6 +41,9 @@ type HotSpotPeerStat struct {
 	needDelete bool
 	isLeader   bool
 	isNew      bool
+	// restored is set if the peer is taken from another member rather than
+	// reported by the heartbeats.
+	restored bool
 }
 
 // IsNeedDelete to delete the item in cache.
@@ -31,16 +31,97 @@ const (
 	IncorrectNamespace
 	LearnerPeer
 	EmptyRegion
+	LaggingLearner
 )
 
+var regionStatisticTypeNames = map[RegionStatisticType]string{
+	MissPeer:           "miss-peer",
+	ExtraPeer:          "extra-peer",
+	DownPeer:           "down-peer",
+	PendingPeer:        "pending-peer",
+	OfflinePeer:        "offline-peer",
+	IncorrectNamespace: "incorrect-namespace",
+	LearnerPeer:        "learner-peer",
+	EmptyRegion:        "empty-region",
+	LaggingLearner:     "lagging-learner",
+}
+
 const nonIsolation = "none"
 
+// NamespaceRegionStats records the statistics of the regions in a namespace.
+type NamespaceRegionStats struct {
+	Namespace    string `json:"namespace"`
+	Count        int    `json:"count"`
+	StorageSize  int64  `json:"storage_size"`
+	StorageKeys  int64  `json:"storage_keys"`
+	WrittenBytes uint64 `json:"written_bytes"`
+	ReadBytes    uint64 `json:"read_bytes"`
+	WrittenKeys  uint64 `json:"written_keys"`
+	ReadKeys     uint64 `json:"read_keys"`
+	// AbnormalCount is the number of regions of each status type, such as
+	// "miss-peer" and "down-peer".
+	AbnormalCount map[string]int `json:"abnormal_count"`
+}
+
+// namespaceRecord is what a region adds to the statistics of its namespace.
+type namespaceRecord struct {
+	namespace string
+	region    *core.RegionInfo
+	typ       RegionStatisticType
+}
+
+// namespaceStats sums the records of the regions in a namespace.
+type namespaceStats struct {
+	count         int
+	storageSize   int64
+	storageKeys   int64
+	writtenBytes  uint64
+	readBytes     uint64
+	writtenKeys   uint64
+	readKeys      uint64
+	abnormalCount map[RegionStatisticType]int
+}
+
+func (s *namespaceStats) add(record *namespaceRecord) {
+	s.count++
+	s.storageSize += record.region.GetApproximateSize()
+	s.storageKeys += record.region.GetApproximateKeys()
+	s.writtenBytes += record.region.GetBytesWritten()
+	s.readBytes += record.region.GetBytesRead()
+	s.writtenKeys += record.region.GetKeysWritten()
+	s.readKeys += record.region.GetKeysRead()
+	for typ := range regionStatisticTypeNames {
+		if record.typ&typ != 0 {
+			s.abnormalCount[typ]++
+		}
+	}
+}
+
+func (s *namespaceStats) remove(record *namespaceRecord) {
+	s.count--
+	s.storageSize -= record.region.GetApproximateSize()
+	s.storageKeys -= record.region.GetApproximateKeys()
+	s.writtenBytes -= record.region.GetBytesWritten()
+	s.readBytes -= record.region.GetBytesRead()
+	s.writtenKeys -= record.region.GetKeysWritten()
+	s.readKeys -= record.region.GetKeysRead()
+	for typ := range regionStatisticTypeNames {
+		if record.typ&typ != 0 {
+			s.abnormalCount[typ]--
+		}
+	}
+}
+
 // RegionStatistics is used to record the status of regions.
 type RegionStatistics struct {
 	opt        ScheduleOptions
 	classifier namespace.Classifier
 	stats      map[RegionStatisticType]map[uint64]*core.RegionInfo
 	index      map[uint64]RegionStatisticType
+	// namespaceStats is updated incrementally with namespaceRecords, so that
+	// the statistics of a namespace can be got without scanning regions.
+	namespaceStats   map[string]*namespaceStats
+	namespaceRecords map[uint64]*namespaceRecord
 }
 
 // NewRegionStatistics creates a new RegionStatistics.
@@ -50,6 +131,9 @@ func NewRegionStatistics(opt ScheduleOptions, classifier namespace.Classifier) *
 		classifier: classifier,
 		stats:      make(map[RegionStatisticType]map[uint64]*core.RegionInfo),
 		index:      make(map[uint64]RegionStatisticType),
+
+		namespaceStats:   make(map[string]*namespaceStats),
+		namespaceRecords: make(map[uint64]*namespaceRecord),
 	}
 	r.stats[MissPeer] = make(map[uint64]*core.RegionInfo)
 	r.stats[ExtraPeer] = make(map[uint64]*core.RegionInfo)
@@ -59,6 +143,7 @@ func NewRegionStatistics(opt ScheduleOptions, classifier namespace.Classifier) *
 	r.stats[IncorrectNamespace] = make(map[uint64]*core.RegionInfo)
 	r.stats[LearnerPeer] = make(map[uint64]*core.RegionInfo)
 	r.stats[EmptyRegion] = make(map[uint64]*core.RegionInfo)
+	r.stats[LaggingLearner] = make(map[uint64]*core.RegionInfo)
 	return r
 }
 
@@ -71,6 +156,55 @@ func (r *RegionStatistics) GetRegionStatsByType(typ RegionStatisticType) []*core
 	return res
 }
 
+// GetNamespaceRegionStats gets the statistics of the regions in the namespace.
+func (r *RegionStatistics) GetNamespaceRegionStats(name string) *NamespaceRegionStats {
+	res := &NamespaceRegionStats{
+		Namespace:     name,
+		AbnormalCount: make(map[string]int),
+	}
+	s, ok := r.namespaceStats[name]
+	if !ok {
+		return res
+	}
+	res.Count = s.count
+	res.StorageSize = s.storageSize
+	res.StorageKeys = s.storageKeys
+	res.WrittenBytes = s.writtenBytes
+	res.ReadBytes = s.readBytes
+	res.WrittenKeys = s.writtenKeys
+	res.ReadKeys = s.readKeys
+	for typ, count := range s.abnormalCount {
+		if count > 0 {
+			res.AbnormalCount[regionStatisticTypeNames[typ]] = count
+		}
+	}
+	return res
+}
+
+func (r *RegionStatistics) putNamespaceRecord(record *namespaceRecord) {
+	r.deleteNamespaceRecord(record.region.GetID())
+	s, ok := r.namespaceStats[record.namespace]
+	if !ok {
+		s = &namespaceStats{abnormalCount: make(map[RegionStatisticType]int)}
+		r.namespaceStats[record.namespace] = s
+	}
+	s.add(record)
+	r.namespaceRecords[record.region.GetID()] = record
+}
+
+func (r *RegionStatistics) deleteNamespaceRecord(regionID uint64) {
+	record, ok := r.namespaceRecords[regionID]
+	if !ok {
+		return
+	}
+	s := r.namespaceStats[record.namespace]
+	s.remove(record)
+	if s.count == 0 {
+		delete(r.namespaceStats, record.namespace)
+	}
+	delete(r.namespaceRecords, regionID)
+}
+
 func (r *RegionStatistics) deleteEntry(deleteIndex RegionStatisticType, regionID uint64) {
 	for typ := RegionStatisticType(1); typ <= deleteIndex; typ <<= 1 {
 		if deleteIndex&typ != 0 {
@@ -116,6 +250,11 @@ func (r *RegionStatistics) Observe(region *core.RegionInfo, stores []*core.Store
 		peerTypeIndex |= EmptyRegion
 	}
 
+	if isLearnerLagging(region, r.opt.GetMaxLearnerPromoteLag()) {
+		r.stats[LaggingLearner][regionID] = region
+		peerTypeIndex |= LaggingLearner
+	}
+
 	for _, store := range stores {
 		if store.IsOffline() {
 			peer := region.GetStorePeer(store.GetID())
@@ -138,6 +277,7 @@ func (r *RegionStatistics) Observe(region *core.RegionInfo, stores []*core.Store
 	}
 	r.deleteEntry(deleteIndex, regionID)
 	r.index[regionID] = peerTypeIndex
+	r.putNamespaceRecord(&namespaceRecord{namespace: namespace, region: region, typ: peerTypeIndex})
 }
 
 // ClearDefunctRegion is used to handle the overlap region.
@@ -145,6 +285,7 @@ func (r *RegionStatistics) ClearDefunctRegion(regionID uint64) {
 	if oldIndex, ok := r.index[regionID]; ok {
 		r.deleteEntry(oldIndex, regionID)
 	}
+	r.deleteNamespaceRecord(regionID)
 }
 
 // Collect collects the metrics of the regions' status.
@@ -157,6 +298,21 @@ func (r *RegionStatistics) Collect() {
 	regionStatusGauge.WithLabelValues("incorrect-namespace-region-count").Set(float64(len(r.stats[IncorrectNamespace])))
 	regionStatusGauge.WithLabelValues("learner-peer-region-count").Set(float64(len(r.stats[LearnerPeer])))
 	regionStatusGauge.WithLabelValues("empty-region-count").Set(float64(len(r.stats[EmptyRegion])))
+	regionStatusGauge.WithLabelValues("lagging-learner-region-count").Set(float64(len(r.stats[LaggingLearner])))
+}
+
+// isLearnerLagging checks if any learner of the region lags behind the leader
+// more than maxLag. maxLag == 0 means no learner is regarded as lagging.
+func isLearnerLagging(region *core.RegionInfo, maxLag uint64) bool {
+	if maxLag == 0 {
+		return false
+	}
+	for _, p := range region.GetLearners() {
+		if lag, ok := region.GetLearnerLag(p.GetId()); ok && lag > maxLag {
+			return true
+		}
+	}
+	return false
 }
 
 // LabelStatistics is the statistics of the level of labels.
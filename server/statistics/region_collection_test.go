@@ -104,6 +104,54 @@ func (t *testRegionStatisticsSuite) TestRegionStatistics(c *C) {
 	c.Assert(len(regionStats.stats[OfflinePeer]), Equals, 0)
 }
 
+func (t *testRegionStatisticsSuite) TestNamespaceRegionStats(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	peers := []*metapb.Peer{
+		{Id: 1, StoreId: 1},
+		{Id: 2, StoreId: 2},
+		{Id: 3, StoreId: 3},
+	}
+	var stores []*core.StoreInfo
+	for _, p := range peers {
+		stores = append(stores, core.NewStoreInfo(&metapb.Store{Id: p.GetStoreId()}))
+	}
+	region1 := core.NewRegionInfo(&metapb.Region{Id: 1, Peers: peers}, peers[0],
+		core.SetApproximateSize(100), core.SetApproximateKeys(1000), core.SetWrittenBytes(10))
+	region2 := core.NewRegionInfo(&metapb.Region{Id: 2, Peers: peers[:2]}, peers[0],
+		core.SetApproximateSize(50), core.SetApproximateKeys(500), core.SetReadBytes(20))
+	regionStats := NewRegionStatistics(opt, mockclassifier.Classifier{})
+	regionStats.Observe(region1, stores)
+	regionStats.Observe(region2, stores[:2])
+	c.Assert(regionStats.GetNamespaceRegionStats("global"), DeepEquals, &NamespaceRegionStats{
+		Namespace:     "global",
+		Count:         2,
+		StorageSize:   150,
+		StorageKeys:   1500,
+		WrittenBytes:  10,
+		ReadBytes:     20,
+		AbnormalCount: map[string]int{"miss-peer": 1},
+	})
+
+	// The old statistics of a region are replaced.
+	region2 = region2.Clone(core.WithAddPeer(peers[2]), core.SetApproximateSize(60), core.SetReadBytes(0))
+	regionStats.Observe(region2, stores)
+	c.Assert(regionStats.GetNamespaceRegionStats("global"), DeepEquals, &NamespaceRegionStats{
+		Namespace:     "global",
+		Count:         2,
+		StorageSize:   160,
+		StorageKeys:   1500,
+		WrittenBytes:  10,
+		AbnormalCount: map[string]int{},
+	})
+
+	regionStats.ClearDefunctRegion(1)
+	stats := regionStats.GetNamespaceRegionStats("global")
+	c.Assert(stats.Count, Equals, 1)
+	c.Assert(stats.StorageSize, Equals, int64(60))
+	c.Assert(stats.WrittenBytes, Equals, uint64(0))
+	c.Assert(regionStats.GetNamespaceRegionStats("unknown").Count, Equals, 0)
+}
+
 func (t *testRegionStatisticsSuite) TestRegionLabelIsolationLevel(c *C) {
 	locationLabels := []string{"zone", "rack", "host"}
 	labelLevelStats := NewLabelStatistics()
@@ -190,3 +238,27 @@ func (t *testRegionStatisticsSuite) TestRegionLabelIsolationLevel(c *C) {
 		c.Assert(labelLevelStats.labelCounter[i], Equals, res)
 	}
 }
+
+func (t *testRegionStatisticsSuite) TestLaggingLearner(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	peers := []*metapb.Peer{
+		{Id: 1, StoreId: 1},
+		{Id: 2, StoreId: 2},
+		{Id: 3, StoreId: 3, IsLearner: true},
+	}
+	region := core.NewRegionInfo(&metapb.Region{Id: 1, Peers: peers}, peers[0],
+		core.WithLearnerLags(map[uint64]uint64{3: 100}))
+	regionStats := NewRegionStatistics(opt, mockclassifier.Classifier{})
+
+	// No learner is lagging without the limit.
+	regionStats.Observe(region, nil)
+	c.Assert(regionStats.stats[LaggingLearner], HasLen, 0)
+
+	opt.MaxLearnerPromoteLag = 50
+	regionStats.Observe(region, nil)
+	c.Assert(regionStats.stats[LaggingLearner], HasLen, 1)
+
+	region = region.Clone(core.WithLearnerLags(map[uint64]uint64{3: 50}))
+	regionStats.Observe(region, nil)
+	c.Assert(regionStats.stats[LaggingLearner], HasLen, 0)
+}
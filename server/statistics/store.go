@@ -23,15 +23,23 @@ import (
 // StoresStats is a cache hold hot regions.
 type StoresStats struct {
 	sync.RWMutex
+	opt                StoreStatsOptions
 	rollingStoresStats map[uint64]*RollingStoreStats
-	bytesReadRate      float64
-	bytesWriteRate     float64
+	// usedSizes and windows are kept apart from the rolling stats, which are
+	// reset when the store is updated.
+	usedSizes      map[uint64]*usedSizeHistory
+	windows        map[uint64]*storeWindows
+	bytesReadRate  float64
+	bytesWriteRate float64
 }
 
 // NewStoresStats creates a new hot spot cache.
-func NewStoresStats() *StoresStats {
+func NewStoresStats(opt StoreStatsOptions) *StoresStats {
 	return &StoresStats{
+		opt:                opt,
 		rollingStoresStats: make(map[uint64]*RollingStoreStats),
+		usedSizes:          make(map[uint64]*usedSizeHistory),
+		windows:            make(map[uint64]*storeWindows),
 	}
 }
 
@@ -39,7 +47,7 @@ func NewStoresStats() *StoresStats {
 func (s *StoresStats) CreateRollingStoreStats(storeID uint64) {
 	s.Lock()
 	defer s.Unlock()
-	s.rollingStoresStats[storeID] = newRollingStoreStats()
+	s.rollingStoresStats[storeID] = newRollingStoreStats(s.opt.GetStoreStatsWindowSize())
 }
 
 // RemoveRollingStoreStats removes RollingStoreStats with a given store ID.
@@ -47,6 +55,35 @@ func (s *StoresStats) RemoveRollingStoreStats(storeID uint64) {
 	s.Lock()
 	defer s.Unlock()
 	delete(s.rollingStoresStats, storeID)
+	delete(s.usedSizes, storeID)
+	delete(s.windows, storeID)
+}
+
+// RemoveStaleStores removes the statistics of the stores which do not exist,
+// and returns the number of the removed entries.
+func (s *StoresStats) RemoveStaleStores(exists func(storeID uint64) bool) int {
+	s.Lock()
+	defer s.Unlock()
+	var count int
+	for storeID := range s.rollingStoresStats {
+		if !exists(storeID) {
+			delete(s.rollingStoresStats, storeID)
+			count++
+		}
+	}
+	for storeID := range s.usedSizes {
+		if !exists(storeID) {
+			delete(s.usedSizes, storeID)
+			count++
+		}
+	}
+	for storeID := range s.windows {
+		if !exists(storeID) {
+			delete(s.windows, storeID)
+			count++
+		}
+	}
+	return count
 }
 
 // GetRollingStoreStats gets RollingStoreStats with a given store ID.
@@ -58,9 +95,26 @@ func (s *StoresStats) GetRollingStoreStats(storeID uint64) *RollingStoreStats {
 
 // Observe records the current store status with a given store.
 func (s *StoresStats) Observe(storeID uint64, stats *pdpb.StoreStats) {
-	s.RLock()
-	defer s.RUnlock()
-	s.rollingStoresStats[storeID].Observe(stats)
+	s.Lock()
+	defer s.Unlock()
+	rolling := s.rollingStoresStats[storeID]
+	rolling.configure(s.opt.GetStoreStatsWindowSize(), s.opt.GetStoreStatsEWMADecay())
+	rolling.Observe(stats)
+	history, ok := s.usedSizes[storeID]
+	if !ok {
+		history = &usedSizeHistory{}
+		s.usedSizes[storeID] = history
+	}
+	history.observe(stats)
+	if rates, ok := storeRatesOf(stats); ok {
+		windows, ok := s.windows[storeID]
+		if !ok {
+			windows = &storeWindows{}
+			s.windows[storeID] = windows
+		}
+		windows.reconfigure(s.opt.GetStoreStatsLongWindows())
+		windows.observe(stats.GetInterval().GetEndTimestamp(), rates)
+	}
 }
 
 // UpdateTotalBytesRate updates the total bytes write rate and read rate.
@@ -102,6 +156,42 @@ func (s *StoresStats) GetStoreBytesRate(storeID uint64) (writeRate float64, read
 	return 0, 0
 }
 
+// GetStoreRates returns the rates of the recent heartbeats of the specified
+// store.
+func (s *StoresStats) GetStoreRates(storeID uint64) StoreRates {
+	s.RLock()
+	defer s.RUnlock()
+	if storeStat, ok := s.rollingStoresStats[storeID]; ok {
+		storeStat.RLock()
+		defer storeStat.RUnlock()
+		return storeStat.ratesLocked()
+	}
+	return StoreRates{}
+}
+
+// GetStoreWindowRates returns the average rates of the specified store in the
+// long windows, which are more stable than the rates of the recent
+// heartbeats.
+func (s *StoresStats) GetStoreWindowRates(storeID uint64) []StoreWindowRates {
+	s.RLock()
+	defer s.RUnlock()
+	if windows, ok := s.windows[storeID]; ok {
+		return windows.rates()
+	}
+	return nil
+}
+
+// GetStoreUsedSizeGrowthRate returns the growth rate of the used size of the
+// specified store in bytes per second.
+func (s *StoresStats) GetStoreUsedSizeGrowthRate(storeID uint64) float64 {
+	s.RLock()
+	defer s.RUnlock()
+	if history, ok := s.usedSizes[storeID]; ok {
+		return history.growthRate()
+	}
+	return 0
+}
+
 // GetStoresBytesWriteStat returns the bytes write stat of all StoreInfo.
 func (s *StoresStats) GetStoresBytesWriteStat() map[uint64]uint64 {
 	s.RLock()
@@ -160,56 +250,184 @@ type StoreHotRegionsStat map[uint64]*HotRegionsStat
 // RollingStoreStats are multiple sets of recent historical records with specified windows size.
 type RollingStoreStats struct {
 	sync.RWMutex
+	size           int
 	bytesWriteRate *RollingStats
 	bytesReadRate  *RollingStats
 	keysWriteRate  *RollingStats
 	keysReadRate   *RollingStats
+	// decay is the weight of the history in the EWMA of the medians. The
+	// medians are returned if it is 0.
+	decay    float64
+	ewma     StoreRates
+	observed bool
 }
 
-const storeStatsRollingWindows = 3
-
 // NewRollingStoreStats creates a RollingStoreStats.
-func newRollingStoreStats() *RollingStoreStats {
-	return &RollingStoreStats{
-		bytesWriteRate: NewRollingStats(storeStatsRollingWindows),
-		bytesReadRate:  NewRollingStats(storeStatsRollingWindows),
-		keysWriteRate:  NewRollingStats(storeStatsRollingWindows),
-		keysReadRate:   NewRollingStats(storeStatsRollingWindows),
+func newRollingStoreStats(size int) *RollingStoreStats {
+	r := &RollingStoreStats{}
+	r.resize(size)
+	return r
+}
+
+func (r *RollingStoreStats) resize(size int) {
+	r.size = size
+	r.bytesWriteRate = NewRollingStats(size)
+	r.bytesReadRate = NewRollingStats(size)
+	r.keysWriteRate = NewRollingStats(size)
+	r.keysReadRate = NewRollingStats(size)
+}
+
+// configure applies the window size and the EWMA decay. The records are
+// dropped if the window size is changed.
+func (r *RollingStoreStats) configure(size int, decay float64) {
+	r.Lock()
+	defer r.Unlock()
+	if size != r.size {
+		r.resize(size)
+	}
+	if decay != r.decay {
+		r.decay = decay
+		r.observed = false
 	}
 }
 
-// Observe records current statistics.
-func (r *RollingStoreStats) Observe(stats *pdpb.StoreStats) {
+// storeRatesOf returns the rates of the store heartbeat, or false if the
+// heartbeat has no interval.
+func storeRatesOf(stats *pdpb.StoreStats) (StoreRates, bool) {
 	statInterval := stats.GetInterval()
 	interval := statInterval.GetEndTimestamp() - statInterval.GetStartTimestamp()
 	if interval == 0 {
+		return StoreRates{}, false
+	}
+	return StoreRates{
+		BytesWriteRate: float64(stats.BytesWritten / interval),
+		BytesReadRate:  float64(stats.BytesRead / interval),
+		KeysWriteRate:  float64(stats.KeysWritten / interval),
+		KeysReadRate:   float64(stats.KeysRead / interval),
+	}, true
+}
+
+// Observe records current statistics.
+func (r *RollingStoreStats) Observe(stats *pdpb.StoreStats) {
+	rates, ok := storeRatesOf(stats)
+	if !ok {
 		return
 	}
 	r.Lock()
 	defer r.Unlock()
-	r.bytesWriteRate.Add(float64(stats.BytesWritten / interval))
-	r.bytesReadRate.Add(float64(stats.BytesRead / interval))
-	r.keysWriteRate.Add(float64(stats.KeysWritten / interval))
-	r.keysReadRate.Add(float64(stats.KeysRead / interval))
+	r.bytesWriteRate.Add(rates.BytesWriteRate)
+	r.bytesReadRate.Add(rates.BytesReadRate)
+	r.keysWriteRate.Add(rates.KeysWriteRate)
+	r.keysReadRate.Add(rates.KeysReadRate)
+	if r.decay <= 0 {
+		return
+	}
+	medians := r.mediansLocked()
+	if !r.observed {
+		r.ewma, r.observed = medians, true
+		return
+	}
+	r.ewma.scale(r.decay)
+	medians.scale(1 - r.decay)
+	r.ewma.add(medians)
+}
+
+func (r *RollingStoreStats) mediansLocked() StoreRates {
+	return StoreRates{
+		BytesWriteRate: r.bytesWriteRate.Median(),
+		BytesReadRate:  r.bytesReadRate.Median(),
+		KeysWriteRate:  r.keysWriteRate.Median(),
+		KeysReadRate:   r.keysReadRate.Median(),
+	}
+}
+
+func (r *RollingStoreStats) ratesLocked() StoreRates {
+	if r.decay > 0 {
+		return r.ewma
+	}
+	return r.mediansLocked()
 }
 
 // GetBytesRate returns the bytes write rate and the bytes read rate.
 func (r *RollingStoreStats) GetBytesRate() (writeRate float64, readRate float64) {
 	r.RLock()
 	defer r.RUnlock()
-	return r.bytesWriteRate.Median(), r.bytesReadRate.Median()
+	rates := r.ratesLocked()
+	return rates.BytesWriteRate, rates.BytesReadRate
 }
 
 // GetKeysWriteRate returns the keys write rate.
 func (r *RollingStoreStats) GetKeysWriteRate() float64 {
 	r.RLock()
 	defer r.RUnlock()
-	return r.keysWriteRate.Median()
+	return r.ratesLocked().KeysWriteRate
 }
 
 // GetKeysReadRate returns the keys read rate.
 func (r *RollingStoreStats) GetKeysReadRate() float64 {
 	r.RLock()
 	defer r.RUnlock()
-	return r.keysReadRate.Median()
+	return r.ratesLocked().KeysReadRate
+}
+
+const (
+	// usedSizeSampleInterval is the interval in seconds to sample the used
+	// size of a store, which is much longer than the heartbeat interval to
+	// filter out the fluctuation caused by compactions.
+	usedSizeSampleInterval = 10 * 60
+	// usedSizeSamples is the number of the samples to estimate the growth
+	// rate of the used size, which cover a day.
+	usedSizeSamples = 144
+)
+
+type usedSizeSample struct {
+	timestamp uint64
+	usedSize  uint64
+}
+
+// usedSizeHistory is the sampled used sizes of a store in the last day.
+type usedSizeHistory struct {
+	samples []usedSizeSample
+}
+
+func (h *usedSizeHistory) observe(stats *pdpb.StoreStats) {
+	now := stats.GetInterval().GetEndTimestamp()
+	if now == 0 {
+		return
+	}
+	if n := len(h.samples); n > 0 && now < h.samples[n-1].timestamp+usedSizeSampleInterval {
+		return
+	}
+	h.samples = append(h.samples, usedSizeSample{timestamp: now, usedSize: stats.GetUsedSize()})
+	if len(h.samples) > usedSizeSamples {
+		h.samples = h.samples[1:]
+	}
+}
+
+// growthRate returns the growth rate of the used size in bytes per second,
+// which is the slope of the least squares fit of the samples. It is 0 before
+// two samples are taken.
+func (h *usedSizeHistory) growthRate() float64 {
+	n := float64(len(h.samples))
+	if n < 2 {
+		return 0
+	}
+	// The timestamps are relative to the first sample to keep the precision.
+	start := h.samples[0].timestamp
+	var sumX, sumY float64
+	for _, sample := range h.samples {
+		sumX += float64(sample.timestamp - start)
+		sumY += float64(sample.usedSize)
+	}
+	meanX, meanY := sumX/n, sumY/n
+	var cov, variance float64
+	for _, sample := range h.samples {
+		dx := float64(sample.timestamp-start) - meanX
+		cov += dx * (float64(sample.usedSize) - meanY)
+		variance += dx * dx
+	}
+	if variance == 0 {
+		return 0
+	}
+	return cov / variance
 }
@@ -15,6 +15,7 @@ package statistics
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"time"
 
@@ -48,6 +49,7 @@ type ScheduleOptions interface {
 	GetHotRegionCacheHitsThreshold() int
 	GetMaxSnapshotCount() uint64
 	GetMaxPendingPeerCount() uint64
+	GetMaxLearnerPromoteLag() uint64
 	GetMaxMergeRegionSize() uint64
 	GetMaxMergeRegionKeys() uint64
 
@@ -220,18 +222,41 @@ func (s *storeStatistics) resetStoreStatistics(storeAddress string, id string) {
 	storeStatusGauge.WithLabelValues(s.namespace, storeAddress, id, "store_capacity").Set(0)
 }
 
+// LabelValueStats is the totals of the stores with a value of a location
+// label, such as the stores in a zone, which makes the imbalance between the
+// zones observable.
+type LabelValueStats struct {
+	Label          string  `json:"label"`
+	Value          string  `json:"value"`
+	StoreCount     int     `json:"store_count"`
+	Capacity       uint64  `json:"capacity"`
+	Available      uint64  `json:"available"`
+	RegionCount    int     `json:"region_count"`
+	RegionSize     int64   `json:"region_size"`
+	LeaderCount    int     `json:"leader_count"`
+	LeaderSize     int64   `json:"leader_size"`
+	BytesWriteRate float64 `json:"bytes_write_rate"`
+	BytesReadRate  float64 `json:"bytes_read_rate"`
+	KeysWriteRate  float64 `json:"keys_write_rate"`
+	KeysReadRate   float64 `json:"keys_read_rate"`
+}
+
 type storeStatisticsMap struct {
 	opt        ScheduleOptions
 	classifier namespace.Classifier
 	stats      map[string]*storeStatistics
+	// labelValues is the stats of the stores of all namespaces keyed by the
+	// location labels and their values.
+	labelValues map[string]map[string]*LabelValueStats
 }
 
 // NewStoreStatisticsMap creates a new storeStatisticsMap.
 func NewStoreStatisticsMap(opt ScheduleOptions, classifier namespace.Classifier) *storeStatisticsMap {
 	return &storeStatisticsMap{
-		opt:        opt,
-		classifier: classifier,
-		stats:      make(map[string]*storeStatistics),
+		opt:         opt,
+		classifier:  classifier,
+		stats:       make(map[string]*storeStatistics),
+		labelValues: make(map[string]map[string]*LabelValueStats),
 	}
 }
 
@@ -243,10 +268,87 @@ func (m *storeStatisticsMap) Observe(store *core.StoreInfo, stats *StoresStats)
 		m.stats[namespace] = stat
 	}
 	stat.Observe(store, stats)
+	m.ObserveLabelValues(store, stats)
+}
+
+// ObserveLabelValues adds the store to the stats of the values of its
+// location labels only. The stores without a label are counted in the
+// unknown value. Tombstone stores are skipped.
+func (m *storeStatisticsMap) ObserveLabelValues(store *core.StoreInfo, stats *StoresStats) {
+	if store.IsTombstone() {
+		return
+	}
+	var bytesWriteRate, bytesReadRate, keysWriteRate, keysReadRate float64
+	if flow := stats.GetRollingStoreStats(store.GetID()); flow != nil {
+		bytesWriteRate, bytesReadRate = flow.GetBytesRate()
+		keysWriteRate, keysReadRate = flow.GetKeysWriteRate(), flow.GetKeysReadRate()
+	}
+	for _, k := range m.opt.GetLocationLabels() {
+		v := store.GetLabelValue(k)
+		if v == "" {
+			v = unknown
+		}
+		values, ok := m.labelValues[k]
+		if !ok {
+			values = make(map[string]*LabelValueStats)
+			m.labelValues[k] = values
+		}
+		s, ok := values[v]
+		if !ok {
+			s = &LabelValueStats{Label: k, Value: v}
+			values[v] = s
+		}
+		s.StoreCount++
+		s.Capacity += store.GetCapacity()
+		s.Available += store.GetAvailable()
+		s.RegionCount += store.GetRegionCount()
+		s.RegionSize += store.GetRegionSize()
+		s.LeaderCount += store.GetLeaderCount()
+		s.LeaderSize += store.GetLeaderSize()
+		s.BytesWriteRate += bytesWriteRate
+		s.BytesReadRate += bytesReadRate
+		s.KeysWriteRate += keysWriteRate
+		s.KeysReadRate += keysReadRate
+	}
+}
+
+// GetLabelValueStats returns the stats of the values in the order of the
+// location labels and the values.
+func (m *storeStatisticsMap) GetLabelValueStats() []*LabelValueStats {
+	var res []*LabelValueStats
+	for _, k := range m.opt.GetLocationLabels() {
+		start := len(res)
+		for _, s := range m.labelValues[k] {
+			res = append(res, s)
+		}
+		values := res[start:]
+		sort.Slice(values, func(i, j int) bool { return values[i].Value < values[j].Value })
+	}
+	return res
 }
 
 func (m *storeStatisticsMap) Collect() {
 	for _, s := range m.stats {
 		s.Collect()
 	}
+	// The values of the removed stores should not be kept.
+	labelValueStatusGauge.Reset()
+	for _, s := range m.GetLabelValueStats() {
+		metrics := map[string]float64{
+			"store_count":      float64(s.StoreCount),
+			"store_capacity":   float64(s.Capacity),
+			"store_available":  float64(s.Available),
+			"region_count":     float64(s.RegionCount),
+			"region_size":      float64(s.RegionSize),
+			"leader_count":     float64(s.LeaderCount),
+			"leader_size":      float64(s.LeaderSize),
+			"write_rate_bytes": s.BytesWriteRate,
+			"read_rate_bytes":  s.BytesReadRate,
+			"write_rate_keys":  s.KeysWriteRate,
+			"read_rate_keys":   s.KeysReadRate,
+		}
+		for typ, value := range metrics {
+			labelValueStatusGauge.WithLabelValues(s.Label, s.Value, typ).Set(value)
+		}
+	}
 }
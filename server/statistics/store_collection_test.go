@@ -41,7 +41,7 @@ func (t *testStoreStatisticsSuite) TestStoreStatistics(c *C) {
 		{Id: 7, Address: "mock://tikv-7", Labels: []*metapb.StoreLabel{{Key: "host", Value: "h1"}}},
 		{Id: 8, Address: "mock://tikv-8", Labels: []*metapb.StoreLabel{{Key: "host", Value: "h2"}}},
 	}
-	storesStats := NewStoresStats()
+	storesStats := NewStoresStats(opt)
 	var stores []*core.StoreInfo
 	for _, m := range metaStores {
 		s := core.NewStoreInfo(m, core.SetLastHeartbeatTS(time.Now()))
@@ -74,3 +74,44 @@ func (t *testStoreStatisticsSuite) TestStoreStatistics(c *C) {
 	c.Assert(stats.LabelCounter["host:h2"], Equals, 4)
 	c.Assert(stats.LabelCounter["zone:unknown"], Equals, 2)
 }
+
+func (t *testStoreStatisticsSuite) TestLabelValueStatistics(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	opt.LocationLabels = []string{"zone", "host"}
+
+	metaStores := []*metapb.Store{
+		{Id: 1, Labels: []*metapb.StoreLabel{{Key: "zone", Value: "z2"}, {Key: "host", Value: "h1"}}},
+		{Id: 2, Labels: []*metapb.StoreLabel{{Key: "zone", Value: "z1"}, {Key: "host", Value: "h2"}}},
+		{Id: 3, Labels: []*metapb.StoreLabel{{Key: "zone", Value: "z1"}, {Key: "host", Value: "h1"}}},
+		{Id: 4, Labels: []*metapb.StoreLabel{{Key: "host", Value: "h1"}}},
+		{Id: 5, State: metapb.StoreState_Tombstone, Labels: []*metapb.StoreLabel{{Key: "zone", Value: "z1"}}},
+	}
+	storesStats := NewStoresStats(opt)
+	storeStats := NewStoreStatisticsMap(opt, namespace.DefaultClassifier)
+	for _, m := range metaStores {
+		store := core.NewStoreInfo(m,
+			core.SetLastHeartbeatTS(time.Now()),
+			core.SetRegionCount(int(m.GetId())*10),
+			core.SetLeaderCount(int(m.GetId())),
+			core.SetRegionSize(int64(m.GetId())*100),
+		)
+		storesStats.CreateRollingStoreStats(m.GetId())
+		storeStats.Observe(store, storesStats)
+	}
+
+	stats := storeStats.GetLabelValueStats()
+	var keys []string
+	for _, s := range stats {
+		keys = append(keys, s.Label+":"+s.Value)
+	}
+	c.Assert(keys, DeepEquals, []string{"zone:unknown", "zone:z1", "zone:z2", "host:h1", "host:h2"})
+	z1 := stats[1]
+	c.Assert(z1.StoreCount, Equals, 2)
+	c.Assert(z1.RegionCount, Equals, 50)
+	c.Assert(z1.LeaderCount, Equals, 5)
+	c.Assert(z1.RegionSize, Equals, int64(500))
+	h1 := stats[3]
+	c.Assert(h1.StoreCount, Equals, 3)
+	c.Assert(h1.RegionCount, Equals, 80)
+	storeStats.Collect()
+}
@@ -0,0 +1,162 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statistics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// StoreStatsUpdate is the score and flow of a store after a store heartbeat
+// is observed.
+type StoreStatsUpdate struct {
+	StoreID        uint64    `json:"store_id"`
+	LeaderScore    float64   `json:"leader_score"`
+	RegionScore    float64   `json:"region_score"`
+	BytesWriteRate float64   `json:"bytes_write_rate"`
+	BytesReadRate  float64   `json:"bytes_read_rate"`
+	KeysWriteRate  float64   `json:"keys_write_rate"`
+	KeysReadRate   float64   `json:"keys_read_rate"`
+	Time           time.Time `json:"time"`
+}
+
+// StoreStatsBroadcaster pushes store stats updates to subscribers. Publishing
+// never blocks, so that slow subscribers do not delay store heartbeats.
+type StoreStatsBroadcaster struct {
+	sync.RWMutex
+	subscribers map[*StoreStatsSubscriber]struct{}
+	closed      bool
+}
+
+// NewStoreStatsBroadcaster creates a StoreStatsBroadcaster.
+func NewStoreStatsBroadcaster() *StoreStatsBroadcaster {
+	return &StoreStatsBroadcaster{
+		subscribers: make(map[*StoreStatsSubscriber]struct{}),
+	}
+}
+
+// Subscribe creates a subscriber which receives updates at most once per
+// interval.
+func (b *StoreStatsBroadcaster) Subscribe(interval time.Duration) *StoreStatsSubscriber {
+	sub := &StoreStatsSubscriber{
+		broadcaster: b,
+		interval:    interval,
+		pending:     make(map[uint64]*StoreStatsUpdate),
+		notify:      make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+	b.Lock()
+	defer b.Unlock()
+	if b.closed {
+		close(sub.done)
+		return sub
+	}
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Publish pushes the update to all subscribers.
+func (b *StoreStatsBroadcaster) Publish(update *StoreStatsUpdate) {
+	b.RLock()
+	defer b.RUnlock()
+	for sub := range b.subscribers {
+		sub.put(update)
+	}
+}
+
+// Close closes all subscribers. Subscribing after closing gets a closed
+// subscriber.
+func (b *StoreStatsBroadcaster) Close() {
+	b.Lock()
+	defer b.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for sub := range b.subscribers {
+		close(sub.done)
+	}
+	b.subscribers = nil
+}
+
+func (b *StoreStatsBroadcaster) unsubscribe(sub *StoreStatsSubscriber) {
+	b.Lock()
+	defer b.Unlock()
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.done)
+	}
+}
+
+// StoreStatsSubscriber receives the updates of a StoreStatsBroadcaster. Only
+// the latest update of each store is kept between two receives.
+type StoreStatsSubscriber struct {
+	broadcaster *StoreStatsBroadcaster
+	interval    time.Duration
+	last        time.Time
+
+	mu      sync.Mutex
+	pending map[uint64]*StoreStatsUpdate
+	notify  chan struct{}
+	done    chan struct{}
+}
+
+func (s *StoreStatsSubscriber) put(update *StoreStatsUpdate) {
+	s.mu.Lock()
+	s.pending[update.StoreID] = update
+	s.mu.Unlock()
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Receive waits for the updates sorted by store ID. It waits at least the
+// interval since the last receive. It returns false if the subscriber is
+// closed or the stop channel is closed.
+func (s *StoreStatsSubscriber) Receive(stop <-chan struct{}) ([]*StoreStatsUpdate, bool) {
+	if wait := s.interval - time.Since(s.last); wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-s.done:
+			return nil, false
+		case <-stop:
+			return nil, false
+		}
+	}
+	select {
+	case <-s.notify:
+	case <-s.done:
+		return nil, false
+	case <-stop:
+		return nil, false
+	}
+	s.last = time.Now()
+
+	s.mu.Lock()
+	updates := make([]*StoreStatsUpdate, 0, len(s.pending))
+	for _, update := range s.pending {
+		updates = append(updates, update)
+	}
+	s.pending = make(map[uint64]*StoreStatsUpdate)
+	s.mu.Unlock()
+	sort.Slice(updates, func(i, j int) bool { return updates[i].StoreID < updates[j].StoreID })
+	return updates, true
+}
+
+// Close unsubscribes the updates.
+func (s *StoreStatsSubscriber) Close() {
+	s.broadcaster.unsubscribe(s)
+}
@@ -0,0 +1,89 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statistics
+
+import (
+	"time"
+
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&testStoreStatsBroadcasterSuite{})
+
+type testStoreStatsBroadcasterSuite struct{}
+
+func (t *testStoreStatsBroadcasterSuite) TestCoalesce(c *C) {
+	b := NewStoreStatsBroadcaster()
+	defer b.Close()
+	sub := b.Subscribe(0)
+	defer sub.Close()
+
+	b.Publish(&StoreStatsUpdate{StoreID: 2, LeaderScore: 1})
+	b.Publish(&StoreStatsUpdate{StoreID: 1, LeaderScore: 1})
+	b.Publish(&StoreStatsUpdate{StoreID: 2, LeaderScore: 2})
+	updates, ok := sub.Receive(nil)
+	c.Assert(ok, IsTrue)
+	c.Assert(updates, HasLen, 2)
+	c.Assert(updates[0].StoreID, Equals, uint64(1))
+	c.Assert(updates[1].StoreID, Equals, uint64(2))
+	c.Assert(updates[1].LeaderScore, Equals, 2.0)
+
+	// Nothing is pending after receiving.
+	stop := make(chan struct{})
+	close(stop)
+	_, ok = sub.Receive(stop)
+	c.Assert(ok, IsFalse)
+}
+
+func (t *testStoreStatsBroadcasterSuite) TestThrottle(c *C) {
+	b := NewStoreStatsBroadcaster()
+	defer b.Close()
+	interval := 100 * time.Millisecond
+	sub := b.Subscribe(interval)
+	defer sub.Close()
+
+	b.Publish(&StoreStatsUpdate{StoreID: 1})
+	updates, ok := sub.Receive(nil)
+	c.Assert(ok, IsTrue)
+	c.Assert(updates, HasLen, 1)
+
+	start := time.Now()
+	b.Publish(&StoreStatsUpdate{StoreID: 1})
+	updates, ok = sub.Receive(nil)
+	c.Assert(ok, IsTrue)
+	c.Assert(updates, HasLen, 1)
+	c.Assert(time.Since(start) >= interval/2, IsTrue)
+}
+
+func (t *testStoreStatsBroadcasterSuite) TestClose(c *C) {
+	b := NewStoreStatsBroadcaster()
+	sub1 := b.Subscribe(0)
+	sub2 := b.Subscribe(0)
+
+	sub1.Close()
+	b.Publish(&StoreStatsUpdate{StoreID: 1})
+	_, ok := sub1.Receive(nil)
+	c.Assert(ok, IsFalse)
+	_, ok = sub2.Receive(nil)
+	c.Assert(ok, IsTrue)
+
+	b.Close()
+	_, ok = sub2.Receive(nil)
+	c.Assert(ok, IsFalse)
+	sub2.Close()
+
+	// Subscribers of a closed broadcaster are closed.
+	_, ok = b.Subscribe(0).Receive(nil)
+	c.Assert(ok, IsFalse)
+}
@@ -0,0 +1,140 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statistics
+
+import (
+	"math"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/pd/pkg/mock/mockoption"
+)
+
+var _ = Suite(&testStoresStatsSuite{})
+
+type testStoresStatsSuite struct{}
+
+func (t *testStoresStatsSuite) TestUsedSizeGrowthRate(c *C) {
+	stats := NewStoresStats(mockoption.NewScheduleOptions())
+	stats.CreateRollingStoreStats(1)
+	observe := func(ts uint64, used uint64) {
+		stats.Observe(1, &pdpb.StoreStats{
+			StoreId:  1,
+			UsedSize: used,
+			Interval: &pdpb.TimeInterval{StartTimestamp: ts - 10, EndTimestamp: ts},
+		})
+	}
+	c.Assert(stats.GetStoreUsedSizeGrowthRate(1), Equals, 0.0)
+	observe(1000, 1000)
+	c.Assert(stats.GetStoreUsedSizeGrowthRate(1), Equals, 0.0)
+	// The heartbeats within the sample interval are ignored.
+	observe(1010, 5000)
+	c.Assert(stats.GetStoreUsedSizeGrowthRate(1), Equals, 0.0)
+
+	for i := uint64(1); i <= usedSizeSamples; i++ {
+		observe(1000+i*usedSizeSampleInterval, 1000+i*usedSizeSampleInterval*2)
+	}
+	c.Assert(stats.GetStoreUsedSizeGrowthRate(1), Equals, 2.0)
+
+	// The old samples are dropped.
+	for i := uint64(1); i <= usedSizeSamples; i++ {
+		observe(1000+(usedSizeSamples+i)*usedSizeSampleInterval, 1000+usedSizeSamples*usedSizeSampleInterval*2)
+	}
+	c.Assert(stats.GetStoreUsedSizeGrowthRate(1), Equals, 0.0)
+
+	// The history survives the reset of the rolling stats.
+	observe(1000+(usedSizeSamples*2+1)*usedSizeSampleInterval, 2000+usedSizeSamples*usedSizeSampleInterval*2)
+	stats.CreateRollingStoreStats(1)
+	c.Assert(stats.GetStoreUsedSizeGrowthRate(1), Not(Equals), 0.0)
+	stats.RemoveRollingStoreStats(1)
+	c.Assert(stats.GetStoreUsedSizeGrowthRate(1), Equals, 0.0)
+}
+
+func observeWrittenBytes(stats *StoresStats, storeID uint64, ts uint64, rate uint64) {
+	stats.Observe(storeID, &pdpb.StoreStats{
+		StoreId:      storeID,
+		BytesWritten: rate * 10,
+		Interval:     &pdpb.TimeInterval{StartTimestamp: ts - 10, EndTimestamp: ts},
+	})
+}
+
+func (t *testStoresStatsSuite) TestRollingWindow(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	stats := NewStoresStats(opt)
+	stats.CreateRollingStoreStats(1)
+	for i, rate := range []uint64{10, 100, 20} {
+		observeWrittenBytes(stats, 1, uint64(1000+i*10), rate)
+	}
+	writeRate, _ := stats.GetStoreBytesRate(1)
+	c.Assert(writeRate, Equals, 20.0)
+
+	// The records are dropped when the window size is changed.
+	opt.StoreStatsWindowSize = 1
+	observeWrittenBytes(stats, 1, 1030, 100)
+	writeRate, _ = stats.GetStoreBytesRate(1)
+	c.Assert(writeRate, Equals, 100.0)
+
+	// The medians are smoothed by the EWMA.
+	opt.StoreStatsEWMADecay = 0.5
+	observeWrittenBytes(stats, 1, 1040, 100)
+	observeWrittenBytes(stats, 1, 1050, 20)
+	writeRate, _ = stats.GetStoreBytesRate(1)
+	c.Assert(writeRate, Equals, 60.0)
+	c.Assert(stats.GetRollingStoreStats(1).GetKeysWriteRate(), Equals, 0.0)
+}
+
+func (t *testStoresStatsSuite) TestLongWindows(c *C) {
+	opt := mockoption.NewScheduleOptions()
+	opt.StoreStatsLongWindows = []time.Duration{10 * time.Minute, time.Hour}
+	stats := NewStoresStats(opt)
+	stats.CreateRollingStoreStats(1)
+	c.Assert(stats.GetStoreWindowRates(1), HasLen, 0)
+
+	// The 10m window has a bucket of 10s, and the 1h window has a bucket of
+	// 1m. The busy minute weighs as much as the others.
+	start := uint64(60000)
+	for i := uint64(0); i < 6; i++ {
+		observeWrittenBytes(stats, 1, start+i*10, 600)
+	}
+	for i := uint64(1); i <= 5; i++ {
+		observeWrittenBytes(stats, 1, start+i*60, 0)
+	}
+	rates := stats.GetStoreWindowRates(1)
+	c.Assert(rates, HasLen, 2)
+	c.Assert(rates[0].Window.Duration, Equals, 10*time.Minute)
+	c.Assert(rates[0].Coverage, Equals, 11.0/60)
+	c.Assert(math.Abs(rates[0].BytesWriteRate-600.0*6/11) < 1e-6, IsTrue)
+	c.Assert(rates[1].Coverage, Equals, 6.0/60)
+	c.Assert(rates[1].BytesWriteRate, Equals, 100.0)
+
+	// The buckets out of the window are dropped.
+	observeWrittenBytes(stats, 1, start+600, 0)
+	rates = stats.GetStoreWindowRates(1)
+	c.Assert(math.Abs(rates[0].BytesWriteRate-600.0*5/11) < 1e-6, IsTrue)
+	c.Assert(rates[1].BytesWriteRate, Equals, 600.0/7)
+
+	// The windows survive the reset of the rolling stats, and the kept
+	// windows survive the reconfiguration.
+	stats.CreateRollingStoreStats(1)
+	opt.StoreStatsLongWindows = []time.Duration{time.Hour, 24 * time.Hour}
+	observeWrittenBytes(stats, 1, start+660, 0)
+	rates = stats.GetStoreWindowRates(1)
+	c.Assert(rates, HasLen, 2)
+	c.Assert(rates[0].BytesWriteRate, Equals, 600.0/8)
+	c.Assert(rates[1].Coverage, Equals, 1.0/60)
+
+	stats.RemoveRollingStoreStats(1)
+	c.Assert(stats.GetStoreWindowRates(1), HasLen, 0)
+}
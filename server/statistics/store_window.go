@@ -0,0 +1,176 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statistics
+
+import (
+	"time"
+
+	"github.com/pingcap/pd/pkg/typeutil"
+)
+
+// storeWindowBuckets is the number of the buckets that a long window is
+// downsampled into, so a window of 1h keeps a bucket for each minute.
+const storeWindowBuckets = 60
+
+// StoreStatsOptions is an interface to access the configurations of the
+// rolling windows of the store statistics.
+type StoreStatsOptions interface {
+	GetStoreStatsWindowSize() int
+	GetStoreStatsEWMADecay() float64
+	GetStoreStatsLongWindows() []time.Duration
+}
+
+// StoreRates is the flow rates of a store.
+type StoreRates struct {
+	BytesWriteRate float64 `json:"bytes_write_rate"`
+	BytesReadRate  float64 `json:"bytes_read_rate"`
+	KeysWriteRate  float64 `json:"keys_write_rate"`
+	KeysReadRate   float64 `json:"keys_read_rate"`
+}
+
+func (r *StoreRates) add(o StoreRates) {
+	r.BytesWriteRate += o.BytesWriteRate
+	r.BytesReadRate += o.BytesReadRate
+	r.KeysWriteRate += o.KeysWriteRate
+	r.KeysReadRate += o.KeysReadRate
+}
+
+func (r *StoreRates) scale(f float64) {
+	r.BytesWriteRate *= f
+	r.BytesReadRate *= f
+	r.KeysWriteRate *= f
+	r.KeysReadRate *= f
+}
+
+// StoreWindowRates is the average rates of a store in a long window.
+type StoreWindowRates struct {
+	StoreRates
+	Window typeutil.Duration `json:"window"`
+	// Coverage is the ratio of the window covered by the heartbeats, which is
+	// less than 1 if the store has not been observed for the whole window.
+	Coverage float64 `json:"coverage"`
+}
+
+type storeWindowBucket struct {
+	// index is the index of the bucket since the epoch.
+	index uint64
+	sum   StoreRates
+	count int
+}
+
+// storeWindow keeps the rates of the heartbeats in a long window. The rates
+// are downsampled into buckets, each of which is the average of the
+// heartbeats in it.
+type storeWindow struct {
+	window   time.Duration
+	interval uint64
+	buckets  []storeWindowBucket
+}
+
+func newStoreWindow(window time.Duration) *storeWindow {
+	interval := uint64(window.Seconds()) / storeWindowBuckets
+	if interval == 0 {
+		interval = 1
+	}
+	return &storeWindow{
+		window:   window,
+		interval: interval,
+		buckets:  make([]storeWindowBucket, storeWindowBuckets),
+	}
+}
+
+func (w *storeWindow) add(timestamp uint64, rates StoreRates) {
+	index := timestamp / w.interval
+	b := &w.buckets[index%storeWindowBuckets]
+	if b.index != index || b.count == 0 {
+		*b = storeWindowBucket{index: index}
+	}
+	b.sum.add(rates)
+	b.count++
+}
+
+// rates returns the average of the buckets in the window before the
+// timestamp, so that the busy periods do not weigh more for having more
+// heartbeats.
+func (w *storeWindow) rates(timestamp uint64) StoreWindowRates {
+	result := StoreWindowRates{Window: typeutil.NewDuration(w.window)}
+	current := timestamp / w.interval
+	var n int
+	for _, b := range w.buckets {
+		if b.count == 0 || b.index > current || b.index+storeWindowBuckets <= current {
+			continue
+		}
+		avg := b.sum
+		avg.scale(1 / float64(b.count))
+		result.add(avg)
+		n++
+	}
+	if n > 0 {
+		result.scale(1 / float64(n))
+		result.Coverage = float64(n) / storeWindowBuckets
+	}
+	return result
+}
+
+// storeWindows is the long windows of a store, which are kept apart from the
+// rolling stats since they are reset when the store is updated.
+type storeWindows struct {
+	windows []*storeWindow
+	// lastTimestamp is the end of the last heartbeat.
+	lastTimestamp uint64
+}
+
+// reconfigure keeps the windows with the same lengths as the configuration
+// and creates the others.
+func (s *storeWindows) reconfigure(windows []time.Duration) {
+	if len(s.windows) == len(windows) {
+		same := true
+		for i, w := range s.windows {
+			if w.window != windows[i] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return
+		}
+	}
+	old := make(map[time.Duration]*storeWindow, len(s.windows))
+	for _, w := range s.windows {
+		old[w.window] = w
+	}
+	s.windows = make([]*storeWindow, 0, len(windows))
+	for _, window := range windows {
+		if w, ok := old[window]; ok {
+			s.windows = append(s.windows, w)
+		} else {
+			s.windows = append(s.windows, newStoreWindow(window))
+		}
+	}
+}
+
+func (s *storeWindows) observe(timestamp uint64, rates StoreRates) {
+	for _, w := range s.windows {
+		w.add(timestamp, rates)
+	}
+	s.lastTimestamp = timestamp
+}
+
+func (s *storeWindows) rates() []StoreWindowRates {
+	result := make([]StoreWindowRates, 0, len(s.windows))
+	for _, w := range s.windows {
+		result = append(result, w.rates(s.lastTimestamp))
+	}
+	return result
+}
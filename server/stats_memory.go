@@ -0,0 +1,29 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import "github.com/pingcap/pd/server/statistics"
+
+// boundStatsMemory bounds the hot peers kept for each store by what is left
+// of the stats memory budget after the histories of the store statistics.
+// The region metas and their flow stats are never bounded, since they are
+// read by every flow reader.
+func (c *RaftCluster) boundStatsMemory() {
+	budget := int64(c.opt.LoadPDServerConfig().StatsMemoryBudget)
+	storesMemory := c.storesStats.EstimateMemory()
+	capacity := statistics.HotPeerCapacity(budget, storesMemory, c.getStoreCount())
+	c.hotSpotCache.SetPeerCapacity(capacity)
+	statsMemoryGauge.WithLabelValues("store").Set(float64(storesMemory))
+	statsMemoryGauge.WithLabelValues("hot_peer").Set(float64(c.hotSpotCache.EstimateMemory()))
+}
@@ -0,0 +1,61 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// StorageUsage is the usage of etcd by PD.
+type StorageUsage struct {
+	// DBSize is the size of the etcd backend, which includes the space not
+	// reclaimed by compaction and defragmentation yet.
+	DBSize            int64                  `json:"db_size"`
+	QuotaBackendBytes int64                  `json:"quota_backend_bytes"`
+	Families          []*core.KeyFamilyUsage `json:"families"`
+}
+
+// GetStorageUsage breaks down the usage of etcd by the families of the keys
+// saved by PD.
+func (s *Server) GetStorageUsage() (*StorageUsage, error) {
+	families, err := s.storage.AnalyzeUsage()
+	if err != nil {
+		return nil, err
+	}
+	return &StorageUsage{
+		DBSize:            s.member.Etcd().Server.Backend().Size(),
+		QuotaBackendBytes: s.etcdQuotaBackendBytes(),
+		Families:          families,
+	}, nil
+}
+
+// MigrateRegionsOutOfEtcd moves the regions left in etcd to the region
+// storage, for the clusters whose etcd is hitting the backend quota. The
+// space is reclaimed after etcd is compacted and defragmented. It returns the
+// number of the regions removed from etcd.
+func (s *Server) MigrateRegionsOutOfEtcd() (int, error) {
+	if !s.scheduleOpt.LoadPDServerConfig().UseRegionStorage {
+		return 0, errors.New("use-region-storage is disabled, regions are still saved in etcd")
+	}
+	count, err := s.storage.MigrateRegionsToRegionStorage()
+	if err != nil {
+		log.Error("failed to migrate regions out of etcd", zap.Int("migrated-regions", count), zap.Error(err))
+		return count, err
+	}
+	log.Info("regions are migrated out of etcd", zap.Int("migrated-regions", count))
+	return count, nil
+}
@@ -0,0 +1,177 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/server/config"
+	"github.com/pingcap/pd/server/core"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// storeBenchmarkRandReadIOPSKey and storeBenchmarkSeqThroughputKey are the
+	// gRPC metadata keys for stores to report the benchmark scores of their
+	// disks when they register, which are the random read IOPS and the
+	// sequential throughput in bytes per second.
+	storeBenchmarkRandReadIOPSKey  = "pd-store-benchmark-rand-read-iops"
+	storeBenchmarkSeqThroughputKey = "pd-store-benchmark-seq-throughput"
+	// minBenchmarkWeight and maxBenchmarkWeight bound the benchmark weights,
+	// so that a store with a bogus score is neither drained nor flooded.
+	minBenchmarkWeight = 0.1
+	maxBenchmarkWeight = 10
+)
+
+// storeBenchmarkFromContext returns the benchmark scores in the metadata of
+// the request registering the store, or nil if there is none. The malformed
+// scores are ignored.
+func storeBenchmarkFromContext(ctx context.Context) *core.StoreBenchmark {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+	parse := func(key string) uint64 {
+		values := md.Get(key)
+		if len(values) == 0 {
+			return 0
+		}
+		v, err := strconv.ParseUint(values[0], 10, 64)
+		if err != nil {
+			return 0
+		}
+		return v
+	}
+	benchmark := &core.StoreBenchmark{
+		RandReadIOPS:  parse(storeBenchmarkRandReadIOPSKey),
+		SeqThroughput: parse(storeBenchmarkSeqThroughputKey),
+	}
+	if benchmark.RandReadIOPS == 0 && benchmark.SeqThroughput == 0 {
+		return nil
+	}
+	return benchmark
+}
+
+// updateStoreBenchmark sets the benchmark scores reported by the store when it
+// registers. The scores are cleared if the store reports none, since they are
+// measured again at every registration. They are saved, so that they survive
+// the change of the PD leader, which the stores do not register again for.
+func (c *RaftCluster) updateStoreBenchmark(storeID uint64, benchmark *core.StoreBenchmark) {
+	c.Lock()
+	defer c.Unlock()
+	store := c.GetStore(storeID)
+	if store == nil {
+		return
+	}
+	old := store.GetBenchmark()
+	if old == benchmark || (old != nil && benchmark != nil && *old == *benchmark) {
+		return
+	}
+	if err := c.storage.SaveStoreBenchmark(storeID, benchmark); err != nil {
+		log.Error("failed to save store benchmark", zap.Uint64("store-id", storeID), zap.Error(err))
+	}
+	log.Info("store benchmark changed",
+		zap.Uint64("store-id", storeID),
+		zap.Reflect("old", old),
+		zap.Reflect("new", benchmark))
+	store = store.Clone(core.SetStoreBenchmark(benchmark))
+	c.core.PutStore(store)
+	c.refreshBenchmarkMediansLocked()
+	c.core.PutStore(store.Clone(core.SetBenchmarkWeight(c.benchmarkWeight(store))))
+}
+
+// refreshBenchmarkMedians refreshes the median benchmark scores of the stores
+// of each type, which follow the stores changing types or becoming tombstone.
+func (c *RaftCluster) refreshBenchmarkMedians() {
+	c.Lock()
+	defer c.Unlock()
+	c.refreshBenchmarkMediansLocked()
+}
+
+func (c *RaftCluster) refreshBenchmarkMediansLocked() {
+	iops := make(map[core.StoreType][]uint64)
+	throughput := make(map[core.StoreType][]uint64)
+	for _, s := range c.core.GetStores() {
+		b := s.GetBenchmark()
+		if b == nil || s.IsTombstone() {
+			continue
+		}
+		if b.RandReadIOPS > 0 {
+			iops[s.GetStoreType()] = append(iops[s.GetStoreType()], b.RandReadIOPS)
+		}
+		if b.SeqThroughput > 0 {
+			throughput[s.GetStoreType()] = append(throughput[s.GetStoreType()], b.SeqThroughput)
+		}
+	}
+	medians := make(map[core.StoreType]benchmarkMedians)
+	for t, scores := range iops {
+		m := medians[t]
+		m.randReadIOPS = median(scores)
+		medians[t] = m
+	}
+	for t, scores := range throughput {
+		m := medians[t]
+		m.seqThroughput = median(scores)
+		medians[t] = m
+	}
+	c.benchmarkMedians = medians
+}
+
+// benchmarkMedians is the median benchmark scores of the stores of a type,
+// which are 0 if no store reports them.
+type benchmarkMedians struct {
+	randReadIOPS  float64
+	seqThroughput float64
+}
+
+func median(scores []uint64) float64 {
+	sort.Slice(scores, func(i, j int) bool { return scores[i] < scores[j] })
+	n := len(scores)
+	if n%2 == 1 {
+		return float64(scores[n/2])
+	}
+	return (float64(scores[n/2-1]) + float64(scores[n/2])) / 2
+}
+
+// benchmarkWeight returns the weight of the store by its benchmark scores in
+// the benchmark scoring mode, which is the mean of its scores relative to the
+// median scores of the stores of the same type reporting them. It is 1 for
+// the stores reporting none and in the other modes, and is refreshed with the
+// store heartbeats.
+func (c *RaftCluster) benchmarkWeight(store *core.StoreInfo) float64 {
+	benchmark := store.GetBenchmark()
+	if c.opt.GetPlacementScoreMode() != config.PlacementScoreBenchmark || benchmark == nil {
+		return 1
+	}
+	medians := c.benchmarkMedians[store.GetStoreType()]
+	var sum float64
+	var n int
+	if benchmark.RandReadIOPS > 0 && medians.randReadIOPS > 0 {
+		sum += float64(benchmark.RandReadIOPS) / medians.randReadIOPS
+		n++
+	}
+	if benchmark.SeqThroughput > 0 && medians.seqThroughput > 0 {
+		sum += float64(benchmark.SeqThroughput) / medians.seqThroughput
+		n++
+	}
+	if n == 0 {
+		return 1
+	}
+	return math.Min(math.Max(sum/float64(n), minBenchmarkWeight), maxBenchmarkWeight)
+}
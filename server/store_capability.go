@@ -0,0 +1,90 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/server/core"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// storeCapabilitiesKey is the gRPC metadata key for stores to advertise
+	// the operator steps they support when opening the region heartbeat
+	// stream, as a comma separated list such as "demote-voter". The stores
+	// not advertising any are only sent the steps of the base protocol.
+	storeCapabilitiesKey = "pd-store-capabilities"
+	// maxStoreCapabilityLength bounds the length of a capability, since the
+	// metadata is not trusted.
+	maxStoreCapabilityLength = 64
+)
+
+// storeCapabilitiesFromContext returns the capabilities in the metadata of the
+// region heartbeat stream sorted, without duplicates. The capabilities
+// unknown to PD are kept, since they do no harm.
+func storeCapabilitiesFromContext(ctx context.Context) []string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+	seen := make(map[string]struct{})
+	var capabilities []string
+	for _, value := range md.Get(storeCapabilitiesKey) {
+		for _, c := range strings.Split(value, ",") {
+			c = strings.ToLower(strings.TrimSpace(c))
+			if c == "" || len(c) > maxStoreCapabilityLength {
+				continue
+			}
+			if _, ok := seen[c]; !ok {
+				seen[c] = struct{}{}
+				capabilities = append(capabilities, c)
+			}
+		}
+	}
+	sort.Strings(capabilities)
+	return capabilities
+}
+
+// updateStoreCapabilities sets the capabilities advertised by the store if
+// they are changed, such as after the store is upgraded or downgraded.
+func (c *RaftCluster) updateStoreCapabilities(storeID uint64, capabilities []string) {
+	c.Lock()
+	defer c.Unlock()
+	store := c.GetStore(storeID)
+	if store == nil || equalStrings(store.GetCapabilities(), capabilities) {
+		return
+	}
+	log.Info("store capabilities changed",
+		zap.Uint64("store-id", storeID),
+		zap.Strings("old", store.GetCapabilities()),
+		zap.Strings("new", capabilities))
+	c.core.PutStore(store.Clone(core.SetStoreCapabilities(capabilities)))
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,207 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// storeTokenKey is the gRPC metadata key to carry the token of a store, since
+// the store requests have no field for it.
+const storeTokenKey = "pd-store-token"
+
+// storeTokenSize is the number of the random bytes of a token.
+const storeTokenSize = 32
+
+// StoreCredential is the credential to authenticate a store, so that a node
+// cannot impersonate another store. A store is authenticated by either the
+// token in the gRPC metadata, or the SAN of its TLS client certificate.
+type StoreCredential struct {
+	StoreID uint64 `json:"store_id"`
+	// TokenHash is the hex encoded SHA-256 of the token. The token itself is
+	// only known when it is generated.
+	TokenHash string `json:"token_hash,omitempty"`
+	// SAN is a DNS name, an IP address or a URI in the subject alternative
+	// names of the certificate of the store.
+	SAN        string    `json:"san,omitempty"`
+	UpdateTime time.Time `json:"update_time"`
+}
+
+func hashStoreToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (sc *StoreCredential) matchToken(ctx context.Context) bool {
+	if sc.TokenHash == "" {
+		return false
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return false
+	}
+	for _, token := range md.Get(storeTokenKey) {
+		if subtle.ConstantTimeCompare([]byte(hashStoreToken(token)), []byte(sc.TokenHash)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func (sc *StoreCredential) matchSAN(ctx context.Context) bool {
+	if sc.SAN == "" {
+		return false
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.PeerCertificates) == 0 {
+		return false
+	}
+	// The leaf certificate is verified by the TLS handshake.
+	cert := info.State.PeerCertificates[0]
+	for _, name := range cert.DNSNames {
+		if name == sc.SAN {
+			return true
+		}
+	}
+	for _, ip := range cert.IPAddresses {
+		if ip.String() == sc.SAN {
+			return true
+		}
+	}
+	for _, uri := range cert.URIs {
+		if uri.String() == sc.SAN {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *RaftCluster) loadStoreCredentials() error {
+	return c.storage.LoadStoreCredentials(func(value string) error {
+		sc := &StoreCredential{}
+		if err := json.Unmarshal([]byte(value), sc); err != nil {
+			return errors.WithStack(err)
+		}
+		c.storeCredentials[sc.StoreID] = sc
+		return nil
+	})
+}
+
+// RotateStoreCredential replaces the credential of the store. A new token is
+// generated and returned if withToken is true, and the store is also
+// authenticated by the SAN of its certificate if san is not empty. The
+// credential can be set before the store joins the cluster.
+func (c *RaftCluster) RotateStoreCredential(storeID uint64, san string, withToken bool) (string, error) {
+	if !withToken && san == "" {
+		return "", errcode.NewInvalidInputErr(errors.New("either a token or a SAN is required"))
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	if store := c.GetStore(storeID); store != nil && store.IsTombstone() {
+		return "", core.StoreTombstonedErr{StoreID: storeID}
+	}
+	sc := &StoreCredential{
+		StoreID:    storeID,
+		SAN:        san,
+		UpdateTime: time.Now(),
+	}
+	var token string
+	if withToken {
+		b := make([]byte, storeTokenSize)
+		if _, err := rand.Read(b); err != nil {
+			return "", errors.WithStack(err)
+		}
+		token = hex.EncodeToString(b)
+		sc.TokenHash = hashStoreToken(token)
+	}
+	if err := c.storage.SaveStoreCredential(storeID, sc); err != nil {
+		return "", err
+	}
+	c.storeCredentials[storeID] = sc
+	log.Info("store credential is rotated",
+		zap.Uint64("store-id", storeID),
+		zap.String("san", san),
+		zap.Bool("token", withToken))
+	return token, nil
+}
+
+// RevokeStoreCredential deletes the credential of the store.
+func (c *RaftCluster) RevokeStoreCredential(storeID uint64) error {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.storeCredentials[storeID]; !ok {
+		return errcode.NewNotFoundErr(errors.Errorf("store %d has no credential", storeID))
+	}
+	if err := c.storage.DeleteStoreCredential(storeID); err != nil {
+		return err
+	}
+	delete(c.storeCredentials, storeID)
+	log.Info("store credential is revoked", zap.Uint64("store-id", storeID))
+	return nil
+}
+
+// GetStoreCredentials returns the credentials of the stores in the order of
+// store IDs.
+func (c *RaftCluster) GetStoreCredentials() []*StoreCredential {
+	c.RLock()
+	defer c.RUnlock()
+	stores := make([]*StoreCredential, 0, len(c.storeCredentials))
+	for _, sc := range c.storeCredentials {
+		stores = append(stores, sc)
+	}
+	sort.Slice(stores, func(i, j int) bool { return stores[i].StoreID < stores[j].StoreID })
+	return stores
+}
+
+// authenticateStore checks that the request of the store is sent by the store
+// itself. The stores without credentials are trusted unless credentials are
+// required by the configuration.
+func (c *RaftCluster) authenticateStore(ctx context.Context, storeID uint64) error {
+	c.RLock()
+	sc, ok := c.storeCredentials[storeID]
+	c.RUnlock()
+	if !ok {
+		if c.opt.LoadPDServerConfig().RequireStoreCredential {
+			return errors.Errorf("store %d has no credential", storeID)
+		}
+		return nil
+	}
+	if sc.matchToken(ctx) || sc.matchSAN(ctx) {
+		return nil
+	}
+	storeAuthFailedCounter.WithLabelValues(strconv.FormatUint(storeID, 10)).Inc()
+	return errors.Errorf("store %d is not authenticated", storeID)
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+
+	. "github.com/pingcap/check"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+var _ = Suite(&testStoreCredentialSuite{})
+
+type testStoreCredentialSuite struct{}
+
+func (s *testStoreCredentialSuite) newCluster(c *C) *testCluster {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	return newTestCluster(opt)
+}
+
+func withToken(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(storeTokenKey, token))
+}
+
+func withCert(dnsNames ...string) context.Context {
+	info := credentials.TLSInfo{State: tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{{DNSNames: dnsNames}},
+	}}
+	return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: info})
+}
+
+func (s *testStoreCredentialSuite) TestToken(c *C) {
+	tc := s.newCluster(c)
+	c.Assert(tc.authenticateStore(context.Background(), 1), IsNil)
+
+	token, err := tc.RotateStoreCredential(1, "", true)
+	c.Assert(err, IsNil)
+	c.Assert(tc.authenticateStore(context.Background(), 1), NotNil)
+	c.Assert(tc.authenticateStore(withToken("abc"), 1), NotNil)
+	c.Assert(tc.authenticateStore(withToken(token), 1), IsNil)
+	// The token of a store cannot be used by the others.
+	_, err = tc.RotateStoreCredential(2, "", true)
+	c.Assert(err, IsNil)
+	c.Assert(tc.authenticateStore(withToken(token), 2), NotNil)
+
+	// The old token is invalid after rotation.
+	newToken, err := tc.RotateStoreCredential(1, "", true)
+	c.Assert(err, IsNil)
+	c.Assert(tc.authenticateStore(withToken(token), 1), NotNil)
+	c.Assert(tc.authenticateStore(withToken(newToken), 1), IsNil)
+
+	// The credentials are persisted.
+	tc.storeCredentials = make(map[uint64]*StoreCredential)
+	c.Assert(tc.loadStoreCredentials(), IsNil)
+	c.Assert(tc.GetStoreCredentials(), HasLen, 2)
+	c.Assert(tc.authenticateStore(withToken(newToken), 1), IsNil)
+
+	c.Assert(tc.RevokeStoreCredential(1), IsNil)
+	c.Assert(tc.RevokeStoreCredential(1), NotNil)
+	c.Assert(tc.authenticateStore(context.Background(), 1), IsNil)
+	tc.storeCredentials = make(map[uint64]*StoreCredential)
+	c.Assert(tc.loadStoreCredentials(), IsNil)
+	c.Assert(tc.GetStoreCredentials(), HasLen, 1)
+}
+
+func (s *testStoreCredentialSuite) TestSAN(c *C) {
+	tc := s.newCluster(c)
+	_, err := tc.RotateStoreCredential(1, "", false)
+	c.Assert(err, NotNil)
+
+	_, err = tc.RotateStoreCredential(1, "tikv-1.example.com", false)
+	c.Assert(err, IsNil)
+	c.Assert(tc.authenticateStore(withCert("tikv-1.example.com", "tikv"), 1), IsNil)
+	c.Assert(tc.authenticateStore(withCert("tikv-2.example.com"), 1), NotNil)
+	c.Assert(tc.authenticateStore(context.Background(), 1), NotNil)
+}
+
+func (s *testStoreCredentialSuite) TestRequireCredential(c *C) {
+	tc := s.newCluster(c)
+	tc.opt.LoadPDServerConfig().RequireStoreCredential = true
+	c.Assert(tc.authenticateStore(context.Background(), 1), NotNil)
+	token, err := tc.RotateStoreCredential(1, "", true)
+	c.Assert(err, IsNil)
+	c.Assert(tc.authenticateStore(withToken(token), 1), IsNil)
+}
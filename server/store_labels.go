@@ -0,0 +1,142 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// StoreSelector selects the stores which match all of its non-empty fields.
+// The tombstone stores are never selected.
+type StoreSelector struct {
+	AddressPrefix string            `json:"address_prefix,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	StoreType     core.StoreType    `json:"store_type,omitempty"`
+}
+
+func (s *StoreSelector) isEmpty() bool {
+	return s.AddressPrefix == "" && len(s.Labels) == 0 && s.StoreType == ""
+}
+
+func (s *StoreSelector) match(store *core.StoreInfo) bool {
+	if store.IsTombstone() {
+		return false
+	}
+	if s.AddressPrefix != "" && !strings.HasPrefix(store.GetAddress(), s.AddressPrefix) {
+		return false
+	}
+	for k, v := range s.Labels {
+		if !strings.EqualFold(store.GetLabelValue(k), v) {
+			return false
+		}
+	}
+	return s.StoreType == "" || store.GetStoreType() == s.StoreType
+}
+
+// StoreLabelsUpdate is the labels of a store before and after an update.
+type StoreLabelsUpdate struct {
+	StoreID   uint64               `json:"store_id"`
+	Address   string               `json:"address"`
+	OldLabels []*metapb.StoreLabel `json:"old_labels"`
+	NewLabels []*metapb.StoreLabel `json:"new_labels"`
+}
+
+// mergeStoreLabels is the same as StoreInfo.MergeLabels, but it leaves the
+// labels of the store untouched.
+func mergeStoreLabels(store *core.StoreInfo, labels []*metapb.StoreLabel) []*metapb.StoreLabel {
+	merged := make([]*metapb.StoreLabel, 0, len(store.GetLabels())+len(labels))
+	for _, label := range store.GetLabels() {
+		merged = append(merged, &metapb.StoreLabel{Key: label.GetKey(), Value: label.GetValue()})
+	}
+L:
+	for _, newLabel := range labels {
+		for _, label := range merged {
+			if strings.EqualFold(label.Key, newLabel.Key) {
+				label.Value = newLabel.Value
+				continue L
+			}
+		}
+		merged = append(merged, &metapb.StoreLabel{Key: newLabel.GetKey(), Value: newLabel.GetValue()})
+	}
+	return merged
+}
+
+// UpdateStoresLabels merges the labels into the labels of all the stores
+// selected by the selector. Either all the stores are updated or none of them
+// is, and nothing is updated if dryRun is true. It returns the updates of the
+// stores ordered by the store IDs.
+func (c *RaftCluster) UpdateStoresLabels(selector *StoreSelector, labels []*metapb.StoreLabel, dryRun bool) ([]*StoreLabelsUpdate, error) {
+	if selector == nil || selector.isEmpty() {
+		return nil, errcode.NewInvalidInputErr(errors.New("the selector should not be empty"))
+	}
+	if len(labels) == 0 {
+		return nil, errcode.NewInvalidInputErr(errors.New("the labels should not be empty"))
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	// The meta is shared by the clones of a store, so the stores are checked
+	// with the copies of their meta, which are not put until all of them are
+	// saved.
+	var (
+		updates []*StoreLabelsUpdate
+		stores  []*core.StoreInfo
+		metas   []*metapb.Store
+	)
+	for _, store := range c.GetStores() {
+		if !selector.match(store) {
+			continue
+		}
+		meta := proto.Clone(store.GetMeta()).(*metapb.Store)
+		meta.Labels = mergeStoreLabels(store, labels)
+		if err := c.checkStoreLabels(core.NewStoreInfo(meta)); err != nil {
+			return nil, errcode.NewInvalidInputErr(errors.Wrapf(err, "store %d", store.GetID()))
+		}
+		updates = append(updates, &StoreLabelsUpdate{
+			StoreID:   store.GetID(),
+			Address:   store.GetAddress(),
+			OldLabels: store.GetLabels(),
+			NewLabels: meta.GetLabels(),
+		})
+		stores = append(stores, store)
+		metas = append(metas, meta)
+	}
+	sort.Slice(updates, func(i, j int) bool { return updates[i].StoreID < updates[j].StoreID })
+	if dryRun || len(stores) == 0 {
+		return updates, nil
+	}
+
+	if c.storage != nil {
+		if err := c.storage.SaveStores(metas); err != nil {
+			return nil, err
+		}
+	}
+	for i, store := range stores {
+		c.core.PutStore(store.Clone(core.SetStoreLabels(metas[i].GetLabels())))
+	}
+	log.Info("stores labels are updated",
+		zap.Int("store-count", len(stores)),
+		zap.Reflect("labels", labels))
+	return updates, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/server/config"
+	"github.com/pingcap/pd/server/core"
+)
+
+var _ = Suite(&testStoreLabelsSuite{})
+
+type testStoreLabelsSuite struct{}
+
+func (s *testStoreLabelsSuite) TestUpdateStoresLabels(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	opt.GetReplication().Store(&config.ReplicationConfig{MaxReplicas: 3, LocationLabels: []string{"zone", "host"}})
+	tc := newTestCluster(opt)
+
+	putStore := func(storeID uint64, address string, state metapb.StoreState, labels ...*metapb.StoreLabel) {
+		store := core.NewStoreInfo(&metapb.Store{Id: storeID, Address: address, State: state, Labels: labels})
+		tc.Lock()
+		defer tc.Unlock()
+		c.Assert(tc.putStoreLocked(store), IsNil)
+	}
+	zone := func(v string) *metapb.StoreLabel { return &metapb.StoreLabel{Key: "zone", Value: v} }
+	storage := &metapb.StoreLabel{Key: core.StoreTypeLabelKey, Value: string(core.StoreTypeStorage)}
+	putStore(1, "10.0.1.1:20160", metapb.StoreState_Up, zone("z1"))
+	putStore(2, "10.0.1.2:20160", metapb.StoreState_Up, zone("z1"), storage)
+	putStore(3, "10.0.2.1:20160", metapb.StoreState_Up, zone("z2"))
+	putStore(4, "10.0.1.3:20160", metapb.StoreState_Tombstone, zone("z1"))
+
+	labels := []*metapb.StoreLabel{{Key: "host", Value: "h1"}}
+	_, err = tc.UpdateStoresLabels(&StoreSelector{}, labels, false)
+	c.Assert(err, NotNil)
+
+	// Nothing is updated in the dry run.
+	selector := &StoreSelector{AddressPrefix: "10.0.1.", Labels: map[string]string{"zone": "z1"}}
+	updates, err := tc.UpdateStoresLabels(selector, labels, true)
+	c.Assert(err, IsNil)
+	c.Assert(updates, HasLen, 2)
+	c.Assert(updates[0].StoreID, Equals, uint64(1))
+	c.Assert(updates[0].OldLabels, HasLen, 1)
+	c.Assert(updates[0].NewLabels, HasLen, 2)
+	c.Assert(updates[1].StoreID, Equals, uint64(2))
+	c.Assert(tc.GetStore(1).GetLabelValue("host"), Equals, "")
+
+	updates, err = tc.UpdateStoresLabels(selector, labels, false)
+	c.Assert(err, IsNil)
+	c.Assert(updates, HasLen, 2)
+	for _, id := range []uint64{1, 2} {
+		c.Assert(tc.GetStore(id).GetLabelValue("host"), Equals, "h1")
+		c.Assert(tc.GetStore(id).GetLabelValue("zone"), Equals, "z1")
+		meta := &metapb.Store{}
+		ok, err := tc.storage.LoadStore(id, meta)
+		c.Assert(ok, IsTrue)
+		c.Assert(err, IsNil)
+		c.Assert(meta.GetLabels(), HasLen, len(tc.GetStore(id).GetLabels()))
+	}
+	c.Assert(tc.GetStore(3).GetLabelValue("host"), Equals, "")
+	c.Assert(tc.GetStore(4).GetLabelValue("host"), Equals, "")
+
+	// Either all the stores are updated or none of them is. Store 2 fails the
+	// strict check since store-type is not a location label.
+	opt.GetReplication().Store(&config.ReplicationConfig{MaxReplicas: 3, LocationLabels: []string{"zone", "host"}, StrictlyMatchLabel: true})
+	_, err = tc.UpdateStoresLabels(&StoreSelector{AddressPrefix: "10.0."}, []*metapb.StoreLabel{{Key: "host", Value: "h2"}}, false)
+	c.Assert(err, NotNil)
+	c.Assert(tc.GetStore(1).GetLabelValue("host"), Equals, "h1")
+	c.Assert(tc.GetStore(3).GetLabelValue("host"), Equals, "")
+
+	updates, err = tc.UpdateStoresLabels(&StoreSelector{StoreType: core.StoreTypePerformance}, []*metapb.StoreLabel{{Key: "host", Value: "h2"}}, false)
+	c.Assert(err, IsNil)
+	c.Assert(updates, HasLen, 2)
+	c.Assert(tc.GetStore(1).GetLabelValue("host"), Equals, "h2")
+	c.Assert(tc.GetStore(2).GetLabelValue("host"), Equals, "h1")
+	c.Assert(tc.GetStore(3).GetLabelValue("host"), Equals, "h2")
+}
@@ -0,0 +1,62 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"strconv"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// storeMemoryTotalKey and storeMemoryUsedKey are the gRPC metadata keys
+	// for stores to report their total and used memory in bytes with store
+	// heartbeats, since the stats have no field for them.
+	storeMemoryTotalKey = "pd-store-memory-total"
+	storeMemoryUsedKey  = "pd-store-memory-used"
+)
+
+// storeMemoryUsage is the memory reported by a store. It is zero if the store
+// does not report it.
+type storeMemoryUsage struct {
+	total uint64
+	used  uint64
+}
+
+// storeMemoryUsageFromContext returns the memory in the metadata of the store
+// heartbeat. The memory is ignored if it is missing or malformed.
+func storeMemoryUsageFromContext(ctx context.Context) storeMemoryUsage {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return storeMemoryUsage{}
+	}
+	parse := func(key string) (uint64, bool) {
+		values := md.Get(key)
+		if len(values) == 0 {
+			return 0, false
+		}
+		v, err := strconv.ParseUint(values[0], 10, 64)
+		return v, err == nil
+	}
+	total, ok := parse(storeMemoryTotalKey)
+	if !ok {
+		return storeMemoryUsage{}
+	}
+	used, ok := parse(storeMemoryUsedKey)
+	if !ok || used > total {
+		return storeMemoryUsage{}
+	}
+	return storeMemoryUsage{total: total, used: used}
+}
@@ -0,0 +1,248 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// unknownStoreRecordTTL is how long a record of an unknown store is kept
+	// after its last heartbeat. A blocked store is released this long after it
+	// is blocked, and is blocked again if it still sends heartbeats.
+	unknownStoreRecordTTL = 10 * time.Minute
+	// maxUnknownStoreRecords bounds the memory of the records, since the
+	// addresses are not trusted.
+	maxUnknownStoreRecords = 1024
+)
+
+// UnknownStoreRecord is the heartbeats sent from an address by a store which
+// is not registered in the cluster, such as a store removed from the cluster
+// but still running, or a store of another cluster misconfigured with the
+// address of PD.
+type UnknownStoreRecord struct {
+	// Address is the host the heartbeats are sent from.
+	Address string `json:"address"`
+	StoreID uint64 `json:"store_id"`
+	// Heartbeats is the store and region heartbeats rejected since the store
+	// is not registered, and Blocked is the ones rejected at the gRPC level
+	// without being processed.
+	Heartbeats uint64    `json:"heartbeats"`
+	Blocked    uint64    `json:"blocked"`
+	FirstTime  time.Time `json:"first_time"`
+	LastTime   time.Time `json:"last_time"`
+	// IsBlocked is whether the heartbeats are rejected at the gRPC level,
+	// since BlockedTime.
+	IsBlocked   bool      `json:"is_blocked"`
+	BlockedTime time.Time `json:"blocked_time,omitempty"`
+}
+
+// isExpired checks if the record should be forgotten. The blocked heartbeats
+// do not refresh the record, or the store would never be released.
+func (r *UnknownStoreRecord) isExpired(now time.Time) bool {
+	if r.IsBlocked {
+		return now.Sub(r.BlockedTime) >= unknownStoreRecordTTL
+	}
+	return now.Sub(r.LastTime) >= unknownStoreRecordTTL
+}
+
+type unknownStoreKey struct {
+	address string
+	storeID uint64
+}
+
+// storeQuarantine tracks the heartbeats from the unknown stores, and blocks
+// the repeat offenders at the gRPC level once they send more heartbeats than
+// the threshold, so that they cost PD as little as possible. It belongs to the
+// server rather than the cluster since the interceptors outlive the cluster.
+type storeQuarantine struct {
+	sync.Mutex
+	// threshold returns the number of heartbeats after which an unknown
+	// store is blocked. 0 means never.
+	threshold func() uint64
+	records   map[unknownStoreKey]*UnknownStoreRecord
+	lastPrune time.Time
+}
+
+func newStoreQuarantine(threshold func() uint64) *storeQuarantine {
+	return &storeQuarantine{
+		threshold: threshold,
+		records:   make(map[unknownStoreKey]*UnknownStoreRecord),
+		lastPrune: time.Now(),
+	}
+}
+
+// peerHost returns the host of the peer of the request.
+func peerHost(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "unknown"
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// observe records a heartbeat of typ from an unknown store.
+func (q *storeQuarantine) observe(ctx context.Context, storeID uint64, typ string) {
+	unknownStoreHeartbeatCounter.WithLabelValues(typ, "reject").Inc()
+	key := unknownStoreKey{address: peerHost(ctx), storeID: storeID}
+	q.Lock()
+	defer q.Unlock()
+	now := time.Now()
+	q.pruneLocked(now)
+	r, ok := q.records[key]
+	if !ok {
+		if len(q.records) >= maxUnknownStoreRecords {
+			return
+		}
+		r = &UnknownStoreRecord{Address: key.address, StoreID: storeID, FirstTime: now}
+		q.records[key] = r
+		log.Warn("receive heartbeats from unknown store",
+			zap.String("address", key.address),
+			zap.Uint64("store-id", storeID))
+	}
+	r.Heartbeats++
+	r.LastTime = now
+	if threshold := q.threshold(); !r.IsBlocked && threshold > 0 && r.Heartbeats >= threshold {
+		r.IsBlocked, r.BlockedTime = true, now
+		log.Warn("block heartbeats from unknown store",
+			zap.String("address", key.address),
+			zap.Uint64("store-id", storeID),
+			zap.Uint64("heartbeats", r.Heartbeats))
+	}
+}
+
+// checkBlocked returns an error if the heartbeats of the store from the
+// address are blocked.
+func (q *storeQuarantine) checkBlocked(ctx context.Context, storeID uint64, typ string) error {
+	key := unknownStoreKey{address: peerHost(ctx), storeID: storeID}
+	q.Lock()
+	defer q.Unlock()
+	r, ok := q.records[key]
+	if !ok || !r.IsBlocked {
+		return nil
+	}
+	if r.isExpired(time.Now()) {
+		delete(q.records, key)
+		return nil
+	}
+	r.Blocked++
+	unknownStoreHeartbeatCounter.WithLabelValues(typ, "block").Inc()
+	return status.Errorf(codes.PermissionDenied, "store %d from %s is unknown and blocked", storeID, key.address)
+}
+
+func (q *storeQuarantine) pruneLocked(now time.Time) {
+	if now.Sub(q.lastPrune) < unknownStoreRecordTTL {
+		return
+	}
+	for key, r := range q.records {
+		if r.isExpired(now) {
+			delete(q.records, key)
+		}
+	}
+	q.lastPrune = now
+}
+
+// getRecords returns the records ordered by the addresses and the store IDs.
+func (q *storeQuarantine) getRecords() []*UnknownStoreRecord {
+	q.Lock()
+	defer q.Unlock()
+	now := time.Now()
+	records := make([]*UnknownStoreRecord, 0, len(q.records))
+	for _, r := range q.records {
+		if !r.isExpired(now) {
+			c := *r
+			records = append(records, &c)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Address != records[j].Address {
+			return records[i].Address < records[j].Address
+		}
+		return records[i].StoreID < records[j].StoreID
+	})
+	return records
+}
+
+// release removes the records of the address, or all records if the address
+// is empty, which unblocks the stores. It returns the number of the removed
+// records.
+func (q *storeQuarantine) release(address string) int {
+	q.Lock()
+	defer q.Unlock()
+	n := 0
+	for key := range q.records {
+		if address == "" || key.address == address {
+			delete(q.records, key)
+			n++
+		}
+	}
+	return n
+}
+
+// unaryInterceptor rejects the store heartbeats of the blocked stores.
+func (q *storeQuarantine) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if request, ok := req.(*pdpb.StoreHeartbeatRequest); ok {
+		if err := q.checkBlocked(ctx, request.GetStats().GetStoreId(), "store"); err != nil {
+			return nil, err
+		}
+	}
+	return handler(ctx, req)
+}
+
+// quarantinedHeartbeatStream rejects the region heartbeats of the blocked
+// stores, which closes the stream.
+type quarantinedHeartbeatStream struct {
+	pdpb.PD_RegionHeartbeatServer
+	q *storeQuarantine
+}
+
+func (s quarantinedHeartbeatStream) Recv() (*pdpb.RegionHeartbeatRequest, error) {
+	req, err := s.PD_RegionHeartbeatServer.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.q.checkBlocked(s.Context(), req.GetLeader().GetStoreId(), "region"); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// GetUnknownStores returns the unknown stores sending heartbeats recently.
+func (h *Handler) GetUnknownStores() []*UnknownStoreRecord {
+	return h.s.quarantine.getRecords()
+}
+
+// ReleaseUnknownStores forgets the unknown stores sending heartbeats from the
+// address, or all of them if the address is empty, which unblocks them.
+func (h *Handler) ReleaseUnknownStores(address string) int {
+	n := h.s.quarantine.release(address)
+	log.Info("release unknown stores", zap.String("address", address), zap.Int("count", n))
+	return n
+}
@@ -0,0 +1,109 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"net"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Suite(&testStoreQuarantineSuite{})
+
+type testStoreQuarantineSuite struct{}
+
+func peerContext(ip string) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 20160}})
+}
+
+func (s *testStoreQuarantineSuite) TestPeerHost(c *C) {
+	c.Assert(peerHost(context.Background()), Equals, "unknown")
+	c.Assert(peerHost(peerContext("10.0.1.1")), Equals, "10.0.1.1")
+}
+
+func (s *testStoreQuarantineSuite) TestBlock(c *C) {
+	q := newStoreQuarantine(func() uint64 { return 3 })
+	ctx1, ctx2 := peerContext("10.0.1.1"), peerContext("10.0.1.2")
+
+	for i := 0; i < 2; i++ {
+		q.observe(ctx1, 5, "store")
+	}
+	q.observe(ctx2, 5, "region")
+	c.Assert(q.checkBlocked(ctx1, 5, "store"), IsNil)
+
+	// The third heartbeat from the same host blocks the store from the host.
+	q.observe(ctx1, 5, "region")
+	err := q.checkBlocked(ctx1, 5, "store")
+	c.Assert(status.Code(err), Equals, codes.PermissionDenied)
+	c.Assert(q.checkBlocked(ctx2, 5, "store"), IsNil)
+	c.Assert(q.checkBlocked(ctx1, 6, "store"), IsNil)
+
+	records := q.getRecords()
+	c.Assert(records, HasLen, 2)
+	c.Assert(records[0].Address, Equals, "10.0.1.1")
+	c.Assert(records[0].Heartbeats, Equals, uint64(3))
+	c.Assert(records[0].Blocked, Equals, uint64(1))
+	c.Assert(records[0].IsBlocked, IsTrue)
+	c.Assert(records[1].Address, Equals, "10.0.1.2")
+	c.Assert(records[1].IsBlocked, IsFalse)
+
+	// The unary interceptor rejects the store heartbeats of the blocked store.
+	handled := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		handled = true
+		return nil, nil
+	}
+	req := &pdpb.StoreHeartbeatRequest{Stats: &pdpb.StoreStats{StoreId: 5}}
+	_, err = q.unaryInterceptor(ctx1, req, &grpc.UnaryServerInfo{}, handler)
+	c.Assert(status.Code(err), Equals, codes.PermissionDenied)
+	c.Assert(handled, IsFalse)
+	_, err = q.unaryInterceptor(ctx2, req, &grpc.UnaryServerInfo{}, handler)
+	c.Assert(err, IsNil)
+	c.Assert(handled, IsTrue)
+
+	// The blocked heartbeats do not refresh the record, so the store is
+	// released once the record of the block expires.
+	blockedTime := records[0].BlockedTime
+	c.Assert(q.checkBlocked(ctx1, 5, "store"), NotNil)
+	c.Assert(q.getRecords()[0].BlockedTime, Equals, blockedTime)
+	key := unknownStoreKey{address: "10.0.1.1", storeID: 5}
+	q.records[key].BlockedTime = blockedTime.Add(-unknownStoreRecordTTL)
+	c.Assert(q.checkBlocked(ctx1, 5, "store"), IsNil)
+	c.Assert(q.getRecords(), HasLen, 1)
+	q.observe(ctx1, 5, "store")
+	c.Assert(q.getRecords()[0].IsBlocked, IsFalse)
+	c.Assert(q.getRecords()[0].Heartbeats, Equals, uint64(1))
+
+	c.Assert(q.release("10.0.1.1"), Equals, 1)
+	c.Assert(q.checkBlocked(ctx1, 5, "store"), IsNil)
+	c.Assert(q.getRecords(), HasLen, 1)
+	c.Assert(q.release(""), Equals, 1)
+	c.Assert(q.getRecords(), HasLen, 0)
+}
+
+func (s *testStoreQuarantineSuite) TestNoThreshold(c *C) {
+	q := newStoreQuarantine(func() uint64 { return 0 })
+	ctx := peerContext("10.0.1.1")
+	for i := 0; i < 100; i++ {
+		q.observe(ctx, 5, "store")
+	}
+	c.Assert(q.checkBlocked(ctx, 5, "store"), IsNil)
+	c.Assert(q.getRecords()[0].Heartbeats, Equals, uint64(100))
+}
@@ -0,0 +1,182 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+
+	"github.com/pingcap/pd/server/core"
+)
+
+// The kinds of the violations of removing a store.
+const (
+	// RemovalViolationReplica means some regions have no store to move their
+	// replicas on the store to.
+	RemovalViolationReplica = "replica"
+	// RemovalViolationIsolation means some regions can only move their
+	// replicas to the stores less isolated from their other replicas.
+	RemovalViolationIsolation = "isolation"
+	// RemovalViolationCapacity means the other stores of the same type run
+	// low on space before taking the data of the store.
+	RemovalViolationCapacity = "capacity"
+	// RemovalViolationQuorum means some regions need the replicas on the
+	// store for their quorums, since their other replicas are unhealthy.
+	RemovalViolationQuorum = "quorum"
+)
+
+// maxRemovalViolationRegions limits the regions listed in a violation.
+const maxRemovalViolationRegions = 16
+
+// StoreRemovalViolation is what goes wrong if a store is removed.
+type StoreRemovalViolation struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	// RegionCount is the number of the affected regions, and Regions is some
+	// of them.
+	RegionCount int      `json:"region_count,omitempty"`
+	Regions     []uint64 `json:"regions,omitempty"`
+}
+
+func (v *StoreRemovalViolation) addRegion(regionID uint64) {
+	v.RegionCount++
+	if len(v.Regions) < maxRemovalViolationRegions {
+		v.Regions = append(v.Regions, regionID)
+	}
+}
+
+// StoreRemovalCheck is the verdict of whether a store can be removed safely,
+// which is checked before removing it.
+type StoreRemovalCheck struct {
+	StoreID   uint64 `json:"store_id"`
+	Removable bool   `json:"removable"`
+	// Violations is empty if the store is removable.
+	Violations  []*StoreRemovalViolation `json:"violations,omitempty"`
+	StoreType   core.StoreType           `json:"store_type"`
+	RegionCount int                      `json:"region_count"`
+	// UsedSize is the data to be moved off the store, and Headroom is the
+	// space the other stores of the same type can take before running low on
+	// space, both in bytes.
+	UsedSize uint64 `json:"used_size"`
+	Headroom uint64 `json:"headroom"`
+}
+
+// CheckStoreRemoval checks if removing the store would leave regions without
+// stores for their replicas or with less isolated replicas, exhaust the space
+// of the other stores of the same type, or drop regions below quorum.
+func (c *RaftCluster) CheckStoreRemoval(storeID uint64) (*StoreRemovalCheck, error) {
+	store := c.GetStore(storeID)
+	if store == nil {
+		return nil, core.NewStoreNotFoundErr(storeID)
+	}
+	if store.IsTombstone() {
+		return nil, core.StoreTombstonedErr{StoreID: storeID}
+	}
+	check := &StoreRemovalCheck{
+		StoreID:     storeID,
+		StoreType:   store.GetStoreType(),
+		RegionCount: store.GetRegionCount(),
+		UsedSize:    store.GetUsedSize(),
+	}
+
+	// The candidates are the stores of the same type which can take the
+	// replicas, since the replicas are not moved across the tiers.
+	maxDownTime := c.GetMaxStoreDownTime()
+	lowSpaceRatio := c.GetLowSpaceRatio()
+	var candidates []*core.StoreInfo
+	for _, s := range c.GetStores() {
+		if s.GetID() == storeID || !s.IsUp() || s.DownTime() > maxDownTime || s.GetStoreType() != check.StoreType {
+			continue
+		}
+		candidates = append(candidates, s)
+		reserved := uint64((1 - lowSpaceRatio) * float64(s.GetCapacity()))
+		if s.GetAvailable() > reserved {
+			check.Headroom += s.GetAvailable() - reserved
+		}
+	}
+
+	replica := &StoreRemovalViolation{Kind: RemovalViolationReplica}
+	isolation := &StoreRemovalViolation{Kind: RemovalViolationIsolation}
+	quorum := &StoreRemovalViolation{Kind: RemovalViolationQuorum}
+	labels := c.GetLocationLabels()
+	for _, region := range c.GetStoreRegions(storeID) {
+		regionStores := c.GetRegionStores(region)
+		var others []*core.StoreInfo
+		for _, s := range regionStores {
+			if s.GetID() != storeID {
+				others = append(others, s)
+			}
+		}
+
+		// The best candidate should be as isolated from the other replicas
+		// as the store.
+		found, isolated := false, false
+		score := core.DistinctScore(labels, others, store)
+		storeIDs := region.GetStoreIds()
+		for _, s := range candidates {
+			if _, ok := storeIDs[s.GetID()]; ok {
+				continue
+			}
+			found = true
+			if core.DistinctScore(labels, others, s) >= score {
+				isolated = true
+				break
+			}
+		}
+		if !found {
+			replica.addRegion(region.GetID())
+		} else if !isolated {
+			isolation.addRegion(region.GetID())
+		}
+
+		// The replica on the store is needed for the quorum if the healthy
+		// voters on the other stores are not a majority.
+		voters := region.GetVoters()
+		if region.GetStorePeer(storeID) == nil || len(voters) == 0 {
+			continue
+		}
+		healthy := 0
+		for _, p := range voters {
+			if p.GetStoreId() == storeID || region.GetDownPeer(p.GetId()) != nil || region.GetPendingPeer(p.GetId()) != nil {
+				continue
+			}
+			if s := c.GetStore(p.GetStoreId()); s != nil && s.IsUp() && s.DownTime() <= maxDownTime {
+				healthy++
+			}
+		}
+		if healthy < len(voters)/2+1 {
+			quorum.addRegion(region.GetID())
+		}
+	}
+
+	if replica.RegionCount > 0 {
+		replica.Message = fmt.Sprintf("%d regions have no store to move their replicas to", replica.RegionCount)
+		check.Violations = append(check.Violations, replica)
+	}
+	if isolation.RegionCount > 0 {
+		isolation.Message = fmt.Sprintf("%d regions can only move their replicas to less isolated stores", isolation.RegionCount)
+		check.Violations = append(check.Violations, isolation)
+	}
+	if check.UsedSize > check.Headroom {
+		check.Violations = append(check.Violations, &StoreRemovalViolation{
+			Kind:    RemovalViolationCapacity,
+			Message: fmt.Sprintf("the %s stores have %d bytes of headroom for %d bytes to move", check.StoreType, check.Headroom, check.UsedSize),
+		})
+	}
+	if quorum.RegionCount > 0 {
+		quorum.Message = fmt.Sprintf("%d regions need the replicas on the store for their quorums", quorum.RegionCount)
+		check.Violations = append(check.Violations, quorum)
+	}
+	check.Removable = len(check.Violations) == 0
+	return check, nil
+}
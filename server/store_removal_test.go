@@ -0,0 +1,114 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/pd/server/config"
+	"github.com/pingcap/pd/server/core"
+)
+
+var _ = Suite(&testStoreRemovalSuite{})
+
+type testStoreRemovalSuite struct{}
+
+func (s *testStoreRemovalSuite) violationKinds(check *StoreRemovalCheck) []string {
+	kinds := make([]string, 0, len(check.Violations))
+	for _, v := range check.Violations {
+		kinds = append(kinds, v.Kind)
+	}
+	return kinds
+}
+
+func (s *testStoreRemovalSuite) TestCheckStoreRemoval(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	tc := newTestCluster(opt)
+
+	_, err = tc.CheckStoreRemoval(1)
+	c.Assert(err, NotNil)
+
+	for i := uint64(1); i <= 3; i++ {
+		c.Assert(tc.addRegionStore(i, 1), IsNil)
+	}
+	c.Assert(tc.addLeaderRegion(1, 1, 2, 3), IsNil)
+
+	// No store to move the replica to.
+	check, err := tc.CheckStoreRemoval(1)
+	c.Assert(err, IsNil)
+	c.Assert(check.Removable, IsFalse)
+	c.Assert(s.violationKinds(check), DeepEquals, []string{RemovalViolationReplica})
+	c.Assert(check.Violations[0].RegionCount, Equals, 1)
+	c.Assert(check.Violations[0].Regions, DeepEquals, []uint64{1})
+
+	// The store of another type does not take the replica.
+	c.Assert(tc.addLabelsStore(5, 0, map[string]string{core.StoreTypeLabelKey: string(core.StoreTypeStorage)}), IsNil)
+	check, err = tc.CheckStoreRemoval(1)
+	c.Assert(err, IsNil)
+	c.Assert(s.violationKinds(check), DeepEquals, []string{RemovalViolationReplica})
+
+	c.Assert(tc.addRegionStore(4, 0), IsNil)
+	check, err = tc.CheckStoreRemoval(1)
+	c.Assert(err, IsNil)
+	c.Assert(check.Removable, IsTrue)
+	c.Assert(check.Violations, HasLen, 0)
+
+	// Too much data to move.
+	store := tc.GetStore(1).Clone(core.SetStoreStats(&pdpb.StoreStats{Capacity: 100 * gb, UsedSize: 10 * gb, Available: 90 * gb}))
+	tc.Lock()
+	c.Assert(tc.putStoreLocked(store), IsNil)
+	tc.Unlock()
+	check, err = tc.CheckStoreRemoval(1)
+	c.Assert(err, IsNil)
+	c.Assert(s.violationKinds(check), DeepEquals, []string{RemovalViolationCapacity})
+	c.Assert(check.UsedSize, Equals, uint64(10*gb))
+
+	// The other voters are not a majority after store 2 is down.
+	c.Assert(tc.setStoreDown(2), IsNil)
+	check, err = tc.CheckStoreRemoval(4)
+	c.Assert(err, IsNil)
+	c.Assert(check.Removable, IsTrue)
+	check, err = tc.CheckStoreRemoval(3)
+	c.Assert(err, IsNil)
+	c.Assert(s.violationKinds(check), DeepEquals, []string{RemovalViolationQuorum})
+
+	tc.Lock()
+	c.Assert(tc.putStoreLocked(tc.GetStore(4).Clone(core.SetStoreState(metapb.StoreState_Tombstone))), IsNil)
+	tc.Unlock()
+	_, err = tc.CheckStoreRemoval(4)
+	c.Assert(err, NotNil)
+}
+
+func (s *testStoreRemovalSuite) TestIsolation(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	opt.GetReplication().Store(&config.ReplicationConfig{MaxReplicas: 3, LocationLabels: []string{"zone"}})
+	tc := newTestCluster(opt)
+
+	c.Assert(tc.addLabelsStore(1, 1, map[string]string{"zone": "z1"}), IsNil)
+	c.Assert(tc.addLabelsStore(2, 1, map[string]string{"zone": "z2"}), IsNil)
+	c.Assert(tc.addLabelsStore(3, 1, map[string]string{"zone": "z3"}), IsNil)
+	c.Assert(tc.addLabelsStore(4, 0, map[string]string{"zone": "z2"}), IsNil)
+	c.Assert(tc.addLeaderRegion(1, 1, 2, 3), IsNil)
+
+	// Store 4 shares the zone with store 2.
+	check, err := tc.CheckStoreRemoval(1)
+	c.Assert(err, IsNil)
+	c.Assert(s.violationKinds(check), DeepEquals, []string{RemovalViolationIsolation})
+	check, err = tc.CheckStoreRemoval(2)
+	c.Assert(err, IsNil)
+	c.Assert(check.Removable, IsTrue)
+}
@@ -0,0 +1,99 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// storeRTTsKey is the gRPC metadata key for stores to report the RTTs to
+	// other stores measured by them in milliseconds with store heartbeats,
+	// since the heartbeats have no field for them. The RTTs are a comma
+	// separated list of "<store-id>:<rtt>".
+	storeRTTsKey = "pd-store-rtts"
+	// maxStoreRTTs bounds the RTTs taken from a heartbeat, since the metadata
+	// is not trusted.
+	maxStoreRTTs = 1 << 12
+)
+
+// storeRTTsFromContext returns the RTTs in the metadata of the store
+// heartbeat keyed by the store IDs they are measured to. The malformed
+// entries are ignored. It returns false if the store does not report the
+// RTTs, in which case the RTTs reported before are kept.
+func storeRTTsFromContext(ctx context.Context) (map[uint64]float64, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	values := md.Get(storeRTTsKey)
+	if len(values) == 0 {
+		return nil, false
+	}
+	rtts := make(map[uint64]float64)
+	for _, value := range values {
+		for _, entry := range strings.Split(value, ",") {
+			if len(rtts) >= maxStoreRTTs {
+				return rtts, true
+			}
+			fields := strings.Split(strings.TrimSpace(entry), ":")
+			if len(fields) != 2 {
+				continue
+			}
+			storeID, err1 := strconv.ParseUint(fields[0], 10, 64)
+			rtt, err2 := strconv.ParseFloat(fields[1], 64)
+			if err1 != nil || err2 != nil || rtt < 0 || math.IsInf(rtt, 0) || math.IsNaN(rtt) {
+				continue
+			}
+			rtts[storeID] = rtt
+		}
+	}
+	return rtts, true
+}
+
+// storeRTTs records the RTTs between the stores measured by them, which are
+// the network distances between the stores without configured distances.
+type storeRTTs struct {
+	sync.RWMutex
+	stores map[uint64]map[uint64]float64
+}
+
+func (s *storeRTTs) reset() {
+	s.Lock()
+	defer s.Unlock()
+	s.stores = make(map[uint64]map[uint64]float64)
+}
+
+// update replaces the RTTs measured by the store.
+func (s *storeRTTs) update(storeID uint64, rtts map[uint64]float64) {
+	s.Lock()
+	defer s.Unlock()
+	s.stores[storeID] = rtts
+}
+
+// get returns the RTT between the stores. It is the larger one if both stores
+// measure it, and false if neither does.
+func (s *storeRTTs) get(from, to uint64) (float64, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	rtt1, ok1 := s.stores[from][to]
+	rtt2, ok2 := s.stores[to][from]
+	return math.Max(rtt1, rtt2), ok1 || ok2
+}
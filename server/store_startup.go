@@ -0,0 +1,80 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/schedule"
+)
+
+// StoreStartupState is the state that PD expects a store to have, which the
+// store fetches on boot instead of inferring it from several heartbeats.
+type StoreStartupState struct {
+	StoreID uint64               `json:"store_id"`
+	State   string               `json:"state"`
+	Labels  []*metapb.StoreLabel `json:"labels"`
+	// Blocked is whether the store is blocked from scheduling, either by users
+	// or by schedulers. BlockedStore is set if it is blocked by users.
+	Blocked      bool          `json:"blocked"`
+	BlockedStore *BlockedStore `json:"blocked_store,omitempty"`
+	// EvacuateTargets and Decommission are set if the store is being
+	// evacuated with preferred targets or in scheduled windows.
+	EvacuateTargets []uint64                   `json:"evacuate_targets,omitempty"`
+	Decommission    *core.DecommissionSchedule `json:"decommission,omitempty"`
+	// AcceptingStale is whether the stale regions led by the store are
+	// accepted.
+	AcceptingStale bool `json:"accepting_stale"`
+	// StoreLimit is the balance rate limit of the store in operators per
+	// minute, which is the same as the one set by the API.
+	StoreLimit     float64 `json:"store_limit"`
+	ClusterVersion string  `json:"cluster_version"`
+}
+
+// GetStoreStartupState returns the state expected for the store in one call.
+func (c *RaftCluster) GetStoreStartupState(storeID uint64) (*StoreStartupState, error) {
+	store := c.GetStore(storeID)
+	if store == nil {
+		return nil, core.NewStoreNotFoundErr(storeID)
+	}
+	if store.IsTombstone() {
+		return nil, core.StoreTombstonedErr{StoreID: storeID}
+	}
+	state := &StoreStartupState{
+		StoreID:        storeID,
+		State:          store.GetState().String(),
+		Labels:         store.GetLabels(),
+		Blocked:        store.IsBlocked(),
+		ClusterVersion: c.opt.LoadClusterVersion().String(),
+	}
+
+	c.RLock()
+	co := c.coordinator
+	state.BlockedStore = c.blockedStores[storeID]
+	state.EvacuateTargets = c.evacuateTargets[storeID]
+	state.Decommission = c.decommissionSchedules[storeID]
+	if a, ok := c.staleAcceptances[storeID]; ok {
+		state.AcceptingStale = time.Now().Before(a.ExpireTime)
+	}
+	c.RUnlock()
+
+	if co != nil {
+		state.StoreLimit = co.opController.GetStoreLimit(storeID) * schedule.StoreBalanceBaseTime
+	} else {
+		state.StoreLimit = c.GetStoreBalanceRate()
+	}
+	return state, nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"time"
+
+	"github.com/pingcap/errcode"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/statistics"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ErrSyntheticLoadDisabled is returned when the synthetic load is injected
+// while it is not enabled by the configuration.
+var ErrSyntheticLoadDisabled = errors.New("synthetic load is disabled, set pd-server.enable-synthetic-load to enable it")
+
+// SyntheticFlow is the flow in bytes and keys per second.
+type SyntheticFlow struct {
+	BytesWritten uint64 `json:"bytes_written"`
+	KeysWritten  uint64 `json:"keys_written"`
+	BytesRead    uint64 `json:"bytes_read"`
+	KeysRead     uint64 `json:"keys_read"`
+}
+
+// SyntheticRegionFlow is the flow injected into a region.
+type SyntheticRegionFlow struct {
+	RegionID uint64 `json:"region_id"`
+	SyntheticFlow
+}
+
+// SyntheticStoreFlow is the flow injected into a store.
+type SyntheticStoreFlow struct {
+	StoreID uint64 `json:"store_id"`
+	SyntheticFlow
+}
+
+// SyntheticLoad is the load injected into the statistics, which pretends the
+// regions and stores have reported the flows in heartbeats. It is only used
+// to exercise the hot region scheduling in tests, and the injection should be
+// repeated since the flows are not kept up by real heartbeats.
+type SyntheticLoad struct {
+	Regions []*SyntheticRegionFlow `json:"regions"`
+	Stores  []*SyntheticStoreFlow  `json:"stores"`
+}
+
+// InjectSyntheticLoad injects the load into the hot cache and the store
+// statistics. The regions and the stores in the cluster are not changed.
+func (c *RaftCluster) InjectSyntheticLoad(load *SyntheticLoad) error {
+	if !c.opt.LoadPDServerConfig().EnableSyntheticLoad {
+		return ErrSyntheticLoadDisabled
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	regions := make([]*core.RegionInfo, 0, len(load.Regions))
+	for _, flow := range load.Regions {
+		region := c.GetRegion(flow.RegionID)
+		if region == nil {
+			return errcode.NewNotFoundErr(errors.Errorf("region %d not found", flow.RegionID))
+		}
+		interval := uint64(statistics.RegionHeartBeatReportInterval)
+		regions = append(regions, region.Clone(
+			core.SetWrittenBytes(flow.BytesWritten*interval),
+			core.SetWrittenKeys(flow.KeysWritten*interval),
+			core.SetReadBytes(flow.BytesRead*interval),
+			core.SetReadKeys(flow.KeysRead*interval),
+			core.SetReportInterval(interval),
+		))
+	}
+	for _, flow := range load.Stores {
+		if c.GetStore(flow.StoreID) == nil {
+			return core.NewStoreNotFoundErr(flow.StoreID)
+		}
+	}
+
+	// The stores are observed first, since the hot thresholds of the regions
+	// depend on the flows of the stores.
+	now := uint64(time.Now().Unix())
+	for _, flow := range load.Stores {
+		interval := uint64(statistics.StoreHeartBeatReportInterval)
+		c.storesStats.Observe(flow.StoreID, &pdpb.StoreStats{
+			StoreId:      flow.StoreID,
+			BytesWritten: flow.BytesWritten * interval,
+			KeysWritten:  flow.KeysWritten * interval,
+			BytesRead:    flow.BytesRead * interval,
+			KeysRead:     flow.KeysRead * interval,
+			Interval:     &pdpb.TimeInterval{StartTimestamp: now - interval, EndTimestamp: now},
+		})
+	}
+	if len(load.Stores) > 0 {
+		c.storesStats.UpdateTotalBytesRate(c.core.GetStores)
+	}
+	for _, region := range regions {
+		for _, item := range c.CheckWriteStatus(region) {
+			c.hotSpotCache.Update(item)
+		}
+		for _, item := range c.CheckReadStatus(region) {
+			c.hotSpotCache.Update(item)
+		}
+	}
+	log.Info("synthetic load is injected", zap.Int("regions", len(load.Regions)), zap.Int("stores", len(load.Stores)))
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	. "github.com/pingcap/check"
+)
+
+var _ = Suite(&testSyntheticLoadSuite{})
+
+type testSyntheticLoadSuite struct{}
+
+func (s *testSyntheticLoadSuite) TestInjectSyntheticLoad(c *C) {
+	_, opt, err := newTestScheduleConfig()
+	c.Assert(err, IsNil)
+	tc := newTestCluster(opt)
+	for id := uint64(1); id <= 3; id++ {
+		c.Assert(tc.addRegionStore(id, 1), IsNil)
+	}
+	c.Assert(tc.addLeaderRegion(1, 1, 2, 3), IsNil)
+
+	load := &SyntheticLoad{
+		Regions: []*SyntheticRegionFlow{{RegionID: 1, SyntheticFlow: SyntheticFlow{BytesWritten: 1 << 20, BytesRead: 1 << 20}}},
+		Stores:  []*SyntheticStoreFlow{{StoreID: 1, SyntheticFlow: SyntheticFlow{BytesWritten: 1 << 20, BytesRead: 1 << 20}}},
+	}
+	c.Assert(tc.InjectSyntheticLoad(load), Equals, ErrSyntheticLoadDisabled)
+
+	opt.LoadPDServerConfig().EnableSyntheticLoad = true
+	c.Assert(tc.InjectSyntheticLoad(load), IsNil)
+	writeStats := tc.RegionWriteStats()
+	for id := uint64(1); id <= 3; id++ {
+		c.Assert(writeStats[id], HasLen, 1)
+		c.Assert(writeStats[id][0].RegionID, Equals, uint64(1))
+		c.Assert(writeStats[id][0].FlowBytes, Equals, uint64(1<<20))
+	}
+	// The reads are served by the leader.
+	readStats := tc.RegionReadStats()
+	c.Assert(readStats[1], HasLen, 1)
+	c.Assert(readStats[2], HasLen, 0)
+	writeRate, readRate := tc.storesStats.GetStoreBytesRate(1)
+	c.Assert(writeRate, Equals, float64(1<<20))
+	c.Assert(readRate, Equals, float64(1<<20))
+
+	// The region itself is not changed.
+	c.Assert(tc.GetRegion(1).GetBytesWritten(), Equals, uint64(0))
+
+	load.Regions[0].RegionID = 100
+	c.Assert(tc.InjectSyntheticLoad(load), NotNil)
+	load.Regions = nil
+	load.Stores[0].StoreID = 100
+	c.Assert(tc.InjectSyntheticLoad(load), NotNil)
+}
@@ -0,0 +1,135 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/schedule/labeler"
+)
+
+// TierStoreStats is the statistics of the stores of a type.
+type TierStoreStats struct {
+	StoreType      core.StoreType `json:"store_type"`
+	StoreCount     int            `json:"store_count"`
+	LeaderCount    int            `json:"leader_count"`
+	RegionCount    int            `json:"region_count"`
+	RegionSize     int64          `json:"region_size"`
+	Capacity       uint64         `json:"capacity"`
+	Available      uint64         `json:"available"`
+	BytesWriteRate float64        `json:"bytes_write_rate"`
+	BytesReadRate  float64        `json:"bytes_read_rate"`
+}
+
+// TierRangeStats is the statistics of the regions with a range label. The
+// regions without hot-serving or cold labels are counted with an empty label.
+type TierRangeStats struct {
+	Label       string `json:"label"`
+	RegionCount int    `json:"region_count"`
+	RegionSize  int64  `json:"region_size"`
+	// StorageLeaderCount is the number of the regions whose leaders are on
+	// storage stores.
+	StorageLeaderCount int `json:"storage_leader_count"`
+	// WarmRegionCount is the number of the regions accessed in enough recent
+	// heartbeats, and WarmStorageLeaderCount is the number of them whose
+	// leaders are on storage stores.
+	WarmRegionCount        int `json:"warm_region_count"`
+	WarmStorageLeaderCount int `json:"warm_storage_leader_count"`
+}
+
+// TierMigrationStatus is the progress of moving the leaders of hot-serving
+// ranges off storage stores.
+type TierMigrationStatus struct {
+	// PendingLeaders is the number of the hot-serving regions whose leaders
+	// are still on storage stores.
+	PendingLeaders int `json:"pending_leaders"`
+	// MigratingLeaders is the number of the pending regions which have
+	// running operators.
+	MigratingLeaders int `json:"migrating_leaders"`
+}
+
+// TierStats is the statistics of the tiers of the cluster.
+type TierStats struct {
+	Stores    []*TierStoreStats    `json:"stores"`
+	Ranges    []*TierRangeStats    `json:"ranges"`
+	Migration *TierMigrationStatus `json:"migration"`
+}
+
+// GetTierStats returns the statistics of the store types and the range
+// labels, and the status of migrating the leaders between the tiers.
+func (c *RaftCluster) GetTierStats() *TierStats {
+	stores := map[core.StoreType]*TierStoreStats{
+		core.StoreTypePerformance: {StoreType: core.StoreTypePerformance},
+		core.StoreTypeStorage:     {StoreType: core.StoreTypeStorage},
+	}
+	for _, store := range c.GetStores() {
+		if store.IsTombstone() {
+			continue
+		}
+		s := stores[store.GetStoreType()]
+		s.StoreCount++
+		s.LeaderCount += store.GetLeaderCount()
+		s.RegionCount += store.GetRegionCount()
+		s.RegionSize += store.GetRegionSize()
+		s.Capacity += store.GetCapacity()
+		s.Available += store.GetAvailable()
+		s.BytesWriteRate += store.GetBytesWriteRate()
+		s.BytesReadRate += store.GetBytesReadRate()
+	}
+
+	ranges := map[string]*TierRangeStats{
+		labeler.HotServing: {Label: labeler.HotServing},
+		labeler.Cold:       {Label: labeler.Cold},
+		"":                 {},
+	}
+	migration := &TierMigrationStatus{}
+	rangeLabeler := c.GetRangeLabeler()
+	oc := c.GetOperatorController()
+	for _, region := range c.GetRegions() {
+		label := ""
+		if rangeLabeler != nil {
+			if rangeLabeler.HasLabel(region, labeler.HotServing) {
+				label = labeler.HotServing
+			} else if rangeLabeler.HasLabel(region, labeler.Cold) {
+				label = labeler.Cold
+			}
+		}
+		r := ranges[label]
+		r.RegionCount++
+		r.RegionSize += region.GetApproximateSize()
+		warm := c.IsRegionWarm(region)
+		if warm {
+			r.WarmRegionCount++
+		}
+		leaderStore := c.GetLeaderStore(region)
+		if leaderStore == nil || !leaderStore.IsStorageType() {
+			continue
+		}
+		r.StorageLeaderCount++
+		if warm {
+			r.WarmStorageLeaderCount++
+		}
+		if label == labeler.HotServing {
+			migration.PendingLeaders++
+			if oc != nil && oc.GetOperator(region.GetID()) != nil {
+				migration.MigratingLeaders++
+			}
+		}
+	}
+
+	return &TierStats{
+		Stores:    []*TierStoreStats{stores[core.StoreTypePerformance], stores[core.StoreTypeStorage]},
+		Ranges:    []*TierRangeStats{ranges[labeler.HotServing], ranges[labeler.Cold], ranges[""]},
+		Migration: migration,
+	}
+}
@@ -24,6 +24,16 @@ var (
 			Help:      "Counter of tso events",
 		}, []string{"type"})
 
+	// catchUpGauge is 1 while the timestamp is catching up with the system
+	// time after it jumps, which is worth an alert.
+	catchUpGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "tso",
+			Name:      "catch_up",
+			Help:      "Whether the timestamp is catching up with the system time after it jumps.",
+		}, []string{"direction"})
+
 	tsoGauge = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: "pd",
@@ -36,4 +46,5 @@ var (
 func init() {
 	prometheus.MustRegister(tsoCounter)
 	prometheus.MustRegister(tsoGauge)
+	prometheus.MustRegister(catchUpGauge)
 }
@@ -35,8 +35,76 @@ const (
 	UpdateTimestampStep  = 50 * time.Millisecond
 	updateTimestampGuard = time.Millisecond
 	maxLogical           = int64(1 << 18)
+	// backwardJumpThreshold is how far the system time falls behind the
+	// physical time before it is regarded as jumping backward.
+	backwardJumpThreshold = UpdateTimestampStep
+	// catchUpForwardStep is how much the physical time advances every update
+	// while catching up with the system time jumping forward, which is twice
+	// the speed of the system time.
+	catchUpForwardStep = 2 * UpdateTimestampStep
 )
 
+// clockMode is how the physical time follows the system time.
+type clockMode int
+
+const (
+	// clockFollow follows the system time.
+	clockFollow clockMode = iota
+	// clockCatchUpBackward keeps advancing the physical time by a millisecond
+	// every update after the system time jumps backward, until the system
+	// time catches up with it, so that the logical time never runs out.
+	clockCatchUpBackward
+	// clockCatchUpForward advances the physical time by catchUpForwardStep
+	// every update after the system time jumps forward by more than the max
+	// reset gap, until it catches up with the system time, so that a wrong
+	// system time does not push the timestamps far into the future at once.
+	clockCatchUpForward
+)
+
+func (m clockMode) String() string {
+	switch m {
+	case clockCatchUpBackward:
+		return "backward"
+	case clockCatchUpForward:
+		return "forward"
+	default:
+		return "follow"
+	}
+}
+
+// nextPhysical returns the next physical time and clock mode from the previous
+// ones and the system time. It returns false if the physical time does not
+// need to change.
+func nextPhysical(prev time.Time, prevLogical int64, now time.Time, mode clockMode, maxResetGap time.Duration) (time.Time, clockMode, bool) {
+	jetLag := typeutil.SubTimeByWallClock(now, prev)
+	switch {
+	case mode == clockCatchUpForward || (maxResetGap > 0 && jetLag > maxResetGap):
+		if jetLag > catchUpForwardStep {
+			return prev.Add(catchUpForwardStep), clockCatchUpForward, true
+		}
+		mode = clockFollow
+	case jetLag < -backwardJumpThreshold:
+		mode = clockCatchUpBackward
+	case jetLag > updateTimestampGuard:
+		mode = clockFollow
+	}
+
+	// If the system time is greater, it will be synchronized with the system time.
+	if jetLag > updateTimestampGuard {
+		return now, mode, true
+	}
+	if mode == clockCatchUpBackward {
+		return prev.Add(time.Millisecond), mode, true
+	}
+	if prevLogical > maxLogical/2 {
+		// The reason choosing maxLogical/2 here is that it's big enough for common cases.
+		// Because there is enough timestamp can be allocated before next update.
+		log.Warn("the logical time may be not enough", zap.Int64("prev-logical", prevLogical))
+		return prev.Add(time.Millisecond), mode, true
+	}
+	return prev, mode, false
+}
+
 // TimestampOracle is used to maintain the logic of tso.
 type TimestampOracle struct {
 	// For tso, set after pd becomes leader.
@@ -48,15 +116,20 @@ type TimestampOracle struct {
 	member       string
 	client       *clientv3.Client
 	saveInterval time.Duration
+	// maxResetGap is the largest gap the physical time jumps forward at once.
+	maxResetGap time.Duration
+	// mode is only accessed by UpdateTimestamp.
+	mode clockMode
 }
 
 // NewTimestampOracle creates a new TimestampOracle.
-func NewTimestampOracle(client *clientv3.Client, rootPath string, member string, saveInterval time.Duration) *TimestampOracle {
+func NewTimestampOracle(client *clientv3.Client, rootPath string, member string, saveInterval, maxResetGap time.Duration) *TimestampOracle {
 	return &TimestampOracle{
 		rootPath:     rootPath,
 		client:       client,
 		saveInterval: saveInterval,
 		member:       member,
+		maxResetGap:  maxResetGap,
 	}
 }
 
@@ -135,6 +208,8 @@ func (t *TimestampOracle) SyncTimestamp(lease *member.LeaderLease) error {
 	}
 	t.lease = lease
 	t.ts.Store(current)
+	// The physical time follows the system time again, since it is synced.
+	t.setClockMode(clockFollow, 0)
 
 	return nil
 }
@@ -150,6 +225,10 @@ func (t *TimestampOracle) SyncTimestamp(lease *member.LeaderLease) error {
 // 1. The physical time is monotonically increasing.
 // 2. The saved time is monotonically increasing.
 // 3. The physical time is always less than the saved timestamp.
+//
+// If the system time jumps backward, or forward by more than the max reset
+// gap, the physical time catches up with it gradually rather than blocking
+// or following it, see clockMode.
 func (t *TimestampOracle) UpdateTimestamp() error {
 	prev := t.ts.Load().(*atomicObject)
 	now := time.Now()
@@ -161,7 +240,7 @@ func (t *TimestampOracle) UpdateTimestamp() error {
 	tsoCounter.WithLabelValues("save").Inc()
 
 	jetLag := typeutil.SubTimeByWallClock(now, prev.physical)
-	if jetLag > 3*UpdateTimestampStep {
+	if jetLag > 3*UpdateTimestampStep && t.mode != clockCatchUpForward {
 		log.Warn("clock offset", zap.Duration("jet-lag", jetLag), zap.Time("prev-physical", prev.physical), zap.Time("now", now))
 		tsoCounter.WithLabelValues("slow_save").Inc()
 	}
@@ -170,17 +249,10 @@ func (t *TimestampOracle) UpdateTimestamp() error {
 		tsoCounter.WithLabelValues("system_time_slow").Inc()
 	}
 
-	var next time.Time
 	prevLogical := atomic.LoadInt64(&prev.logical)
-	// If the system time is greater, it will be synchronized with the system time.
-	if jetLag > updateTimestampGuard {
-		next = now
-	} else if prevLogical > maxLogical/2 {
-		// The reason choosing maxLogical/2 here is that it's big enough for common cases.
-		// Because there is enough timestamp can be allocated before next update.
-		log.Warn("the logical time may be not enough", zap.Int64("prev-logical", prevLogical))
-		next = prev.physical.Add(time.Millisecond)
-	} else {
+	next, mode, ok := nextPhysical(prev.physical, prevLogical, now, t.mode, t.maxResetGap)
+	t.setClockMode(mode, jetLag)
+	if !ok {
 		// It will still use the previous physical time to alloc the timestamp.
 		tsoCounter.WithLabelValues("skip_save").Inc()
 		return nil
@@ -206,6 +278,43 @@ func (t *TimestampOracle) UpdateTimestamp() error {
 	return nil
 }
 
+// setClockMode switches the clock mode, which raises or clears the alert.
+func (t *TimestampOracle) setClockMode(mode clockMode, jetLag time.Duration) {
+	if mode == t.mode {
+		return
+	}
+	if mode == clockFollow {
+		log.Info("timestamp caught up with system time", zap.Stringer("from", t.mode))
+		tsoCounter.WithLabelValues("catch_up_done").Inc()
+	} else {
+		log.Error("system time jumps, timestamp starts catching up",
+			zap.Stringer("direction", mode),
+			zap.Duration("jet-lag", jetLag),
+			zap.Duration("max-reset-gap", t.maxResetGap))
+		tsoCounter.WithLabelValues("clock_jump_" + mode.String()).Inc()
+	}
+	catchUpGauge.WithLabelValues(clockCatchUpBackward.String()).Set(boolToFloat(mode == clockCatchUpBackward))
+	catchUpGauge.WithLabelValues(clockCatchUpForward.String()).Set(boolToFloat(mode == clockCatchUpForward))
+	t.mode = mode
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// IsAvailable returns whether the timestamp is synced with the lease not
+// expired, so that timestamps can be allocated.
+func (t *TimestampOracle) IsAvailable() bool {
+	current, ok := t.ts.Load().(*atomicObject)
+	if !ok || current.physical == typeutil.ZeroTime {
+		return false
+	}
+	return t.lease != nil && !t.lease.IsExpired()
+}
+
 // ResetTimestamp is used to reset the timestamp.
 func (t *TimestampOracle) ResetTimestamp() {
 	t.ts.Store(&atomicObject{
@@ -0,0 +1,100 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tso
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/pingcap/check"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testClockSuite{})
+
+type testClockSuite struct{}
+
+func (s *testClockSuite) TestFollow(c *C) {
+	prev := time.Now()
+	next, mode, ok := nextPhysical(prev, 0, prev.Add(UpdateTimestampStep), clockFollow, time.Hour)
+	c.Assert(ok, IsTrue)
+	c.Assert(next.Equal(prev.Add(UpdateTimestampStep)), IsTrue)
+	c.Assert(mode, Equals, clockFollow)
+
+	// The physical time is kept unless the logical time is running out.
+	_, _, ok = nextPhysical(prev, 0, prev, clockFollow, time.Hour)
+	c.Assert(ok, IsFalse)
+	next, _, ok = nextPhysical(prev, maxLogical/2+1, prev, clockFollow, time.Hour)
+	c.Assert(ok, IsTrue)
+	c.Assert(next.Equal(prev.Add(time.Millisecond)), IsTrue)
+}
+
+func (s *testClockSuite) TestBackwardJump(c *C) {
+	prev := time.Now()
+	now := prev.Add(-time.Second)
+	mode := clockFollow
+	for i := 0; i < 10; i++ {
+		next, m, ok := nextPhysical(prev, 0, now, mode, time.Hour)
+		c.Assert(ok, IsTrue)
+		c.Assert(m, Equals, clockCatchUpBackward)
+		c.Assert(next.Equal(prev.Add(time.Millisecond)), IsTrue)
+		prev, mode = next, m
+		now = now.Add(UpdateTimestampStep)
+	}
+	// The mode lasts while the system time is within the threshold behind.
+	now = prev.Add(-time.Millisecond)
+	next, mode, ok := nextPhysical(prev, 0, now, mode, time.Hour)
+	c.Assert(ok, IsTrue)
+	c.Assert(mode, Equals, clockCatchUpBackward)
+	c.Assert(next.After(prev), IsTrue)
+
+	// The system time catches up.
+	prev, now = next, next.Add(UpdateTimestampStep)
+	next, mode, ok = nextPhysical(prev, 0, now, mode, time.Hour)
+	c.Assert(ok, IsTrue)
+	c.Assert(mode, Equals, clockFollow)
+	c.Assert(next.Equal(now), IsTrue)
+}
+
+func (s *testClockSuite) TestForwardJump(c *C) {
+	prev := time.Now()
+	// The jumps within the max reset gap are followed.
+	next, mode, ok := nextPhysical(prev, 0, prev.Add(time.Minute), clockFollow, time.Hour)
+	c.Assert(ok, IsTrue)
+	c.Assert(mode, Equals, clockFollow)
+	c.Assert(next.Equal(prev.Add(time.Minute)), IsTrue)
+	// The jumps are always followed without the max reset gap.
+	next, mode, _ = nextPhysical(prev, 0, prev.Add(2*time.Hour), clockFollow, 0)
+	c.Assert(mode, Equals, clockFollow)
+	c.Assert(next.Equal(prev.Add(2*time.Hour)), IsTrue)
+
+	now := prev.Add(time.Hour + time.Second)
+	mode = clockFollow
+	for i := 0; mode != clockFollow || i == 0; i++ {
+		c.Assert(i, Less, 100000)
+		next, mode, ok = nextPhysical(prev, 0, now, mode, time.Hour)
+		c.Assert(ok, IsTrue)
+		c.Assert(next.After(prev), IsTrue)
+		if mode == clockCatchUpForward {
+			c.Assert(next.Equal(prev.Add(catchUpForwardStep)), IsTrue)
+		} else {
+			c.Assert(next.Equal(now), IsTrue)
+		}
+		prev = next
+		now = now.Add(UpdateTimestampStep)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"bytes"
+
+	"github.com/pingcap/pd/server/keydecoder"
+)
+
+func init() {
+	keydecoder.RegisterDecoder("tidb", func() keydecoder.KeyDecoder { return tidbKeyDecoder{} })
+}
+
+// tidbKeyDecoder decodes keys by the codec of TiDB. Each table is a unit, and
+// so is each partition since partitions have their own table IDs. All meta
+// keys are in one unit.
+type tidbKeyDecoder struct{}
+
+func (tidbKeyDecoder) UnitStartKey(key []byte) []byte {
+	isMeta, tableID := Key(key).MetaOrTable()
+	switch {
+	case isMeta:
+		return EncodeBytes(metaPrefix)
+	case tableID != 0:
+		return EncodeBytes(GenerateTableKey(tableID))
+	default:
+		return nil
+	}
+}
+
+// UnitBoundaries returns the start keys of the tables in the range. The IDs
+// of tables are unknown, so it generates the keys of all IDs between the
+// tables of the start key and the end key. Nothing is returned if the end
+// key is not a table key.
+func (tidbKeyDecoder) UnitBoundaries(startKey, endKey []byte, limit int) [][]byte {
+	_, startID := Key(startKey).MetaOrTable()
+	_, endID := Key(endKey).MetaOrTable()
+	var keys [][]byte
+	for id := startID + 1; id <= endID && len(keys) < limit; id++ {
+		key := EncodeBytes(GenerateTableKey(id))
+		if bytes.Compare(key, startKey) <= 0 {
+			continue
+		}
+		if bytes.Compare(key, endKey) >= 0 {
+			break
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
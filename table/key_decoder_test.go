@@ -0,0 +1,54 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/pd/server/keydecoder"
+)
+
+var _ = Suite(&testKeyDecoderSuite{})
+
+type testKeyDecoderSuite struct{}
+
+func (s *testKeyDecoderSuite) TestUnitStartKey(c *C) {
+	decoder, err := keydecoder.CreateDecoder("tidb")
+	c.Assert(err, IsNil)
+
+	tableStart := []byte(EncodeBytes(GenerateTableKey(5)))
+	c.Assert(decoder.UnitStartKey(EncodeBytes(GenerateRowKey(5, 100))), DeepEquals, tableStart)
+	c.Assert(decoder.UnitStartKey(tableStart), DeepEquals, tableStart)
+	c.Assert(decoder.UnitStartKey(EncodeBytes([]byte("mDB"))), DeepEquals, []byte(EncodeBytes(metaPrefix)))
+	c.Assert(decoder.UnitStartKey(nil), IsNil)
+	c.Assert(decoder.UnitStartKey([]byte("abc")), IsNil)
+}
+
+func (s *testKeyDecoderSuite) TestUnitBoundaries(c *C) {
+	decoder, err := keydecoder.CreateDecoder("tidb")
+	c.Assert(err, IsNil)
+	tableKey := func(id int64) []byte { return EncodeBytes(GenerateTableKey(id)) }
+
+	keys := decoder.UnitBoundaries(EncodeBytes(GenerateRowKey(5, 100)), EncodeBytes(GenerateRowKey(8, 1)), 10)
+	c.Assert(keys, DeepEquals, [][]byte{tableKey(6), tableKey(7), tableKey(8)})
+	// The end key is exclusive.
+	keys = decoder.UnitBoundaries(tableKey(5), tableKey(8), 10)
+	c.Assert(keys, DeepEquals, [][]byte{tableKey(6), tableKey(7)})
+	keys = decoder.UnitBoundaries(nil, tableKey(3), 10)
+	c.Assert(keys, DeepEquals, [][]byte{tableKey(1), tableKey(2)})
+	keys = decoder.UnitBoundaries(tableKey(1), tableKey(100), 2)
+	c.Assert(keys, DeepEquals, [][]byte{tableKey(2), tableKey(3)})
+	// The end key is unknown.
+	c.Assert(decoder.UnitBoundaries(tableKey(1), nil, 10), HasLen, 0)
+	c.Assert(decoder.UnitBoundaries(EncodeBytes(GenerateRowKey(5, 1)), EncodeBytes(GenerateRowKey(5, 100)), 10), HasLen, 0)
+}
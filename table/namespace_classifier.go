@@ -14,6 +14,7 @@
 package table
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -32,7 +33,9 @@ import (
 )
 
 func init() {
-	namespace.RegisterClassifier("table", NewTableNamespaceClassifier)
+	namespace.RegisterClassifier("table", func(storage *core.Storage, idAlloc id.Allocator, _ map[string]string) (namespace.Classifier, error) {
+		return NewTableNamespaceClassifier(storage, idAlloc)
+	})
 }
 
 // Namespace defines two things:
@@ -89,6 +92,18 @@ func (ns *Namespace) AddStoreID(storeID uint64) {
 	ns.StoreIDs[storeID] = true
 }
 
+func (ns *Namespace) clone() *Namespace {
+	n := NewNamespace(ns.ID, ns.Name)
+	for id := range ns.TableIDs {
+		n.TableIDs[id] = true
+	}
+	for id := range ns.StoreIDs {
+		n.StoreIDs[id] = true
+	}
+	n.Meta = ns.Meta
+	return n
+}
+
 // tableNamespaceClassifier implements Classifier interface
 type tableNamespaceClassifier struct {
 	sync.RWMutex
@@ -333,6 +348,102 @@ func (c *tableNamespaceClassifier) RemoveNamespaceStoreID(name string, storeID u
 	return c.putNamespaceLocked(n)
 }
 
+// maxMoveTableCount limits the range moved from the default namespace, whose
+// tables are not listed and have to be enumerated by ID.
+const maxMoveTableCount = 1024
+
+// MoveRange moves the tables in [startKey, endKey) from the source namespace
+// to the target namespace. Both keys should be start keys of tables. The
+// namespaces are persisted together, so that a table never belongs to both.
+func (c *tableNamespaceClassifier) MoveRange(startKey, endKey []byte, source, target string) error {
+	startID, err := boundaryTableID(startKey)
+	if err != nil {
+		return err
+	}
+	endID, err := boundaryTableID(endKey)
+	if err != nil {
+		return err
+	}
+	if startID >= endID {
+		return errors.New("start key should be less than end key")
+	}
+	if source == target {
+		return errors.New("source and target should be different namespaces")
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	var src, dst *Namespace
+	if source != namespace.DefaultNamespace {
+		if src = c.nsInfo.getNamespaceByName(source); src == nil {
+			return errors.Errorf("invalid namespace Name %s, not found", source)
+		}
+	}
+	if target != namespace.DefaultNamespace {
+		if dst = c.nsInfo.getNamespaceByName(target); dst == nil {
+			return errors.Errorf("invalid namespace Name %s, not found", target)
+		}
+	}
+
+	var tableIDs []int64
+	if src != nil {
+		for id := range src.TableIDs {
+			if id >= startID && id < endID {
+				tableIDs = append(tableIDs, id)
+			}
+		}
+	} else {
+		if endID-startID > maxMoveTableCount {
+			return errors.Errorf("cannot move more than %d tables from %s", maxMoveTableCount, source)
+		}
+		for id := startID; id < endID; id++ {
+			if !c.nsInfo.isTableIDExist(id) {
+				tableIDs = append(tableIDs, id)
+			}
+		}
+	}
+	if len(tableIDs) == 0 {
+		return errors.Errorf("no table of %s in the range", source)
+	}
+
+	// Changes copies, so that nothing is changed if failing to persist.
+	var changed []*Namespace
+	if src != nil {
+		src = src.clone()
+		for _, id := range tableIDs {
+			delete(src.TableIDs, id)
+		}
+		changed = append(changed, src)
+	}
+	if dst != nil {
+		dst = dst.clone()
+		for _, id := range tableIDs {
+			dst.AddTableID(id)
+		}
+		changed = append(changed, dst)
+	}
+	if c.storage != nil {
+		if err := c.nsInfo.saveNamespaces(c.storage, changed...); err != nil {
+			return err
+		}
+	}
+	for _, ns := range changed {
+		c.nsInfo.setNamespace(ns)
+	}
+	return nil
+}
+
+// boundaryTableID returns the table ID of the key if it is the start key of
+// a table.
+func boundaryTableID(key []byte) (int64, error) {
+	tableID := Key(key).TableID()
+	if tableID == 0 || !bytes.Equal(key, EncodeBytes(GenerateTableKey(tableID))) {
+		return 0, errors.Errorf("key %x is not the start key of a table", key)
+	}
+	return tableID, nil
+}
+
 // ReloadNamespaces reloads ns info from storage.
 func (c *tableNamespaceClassifier) ReloadNamespaces() error {
 	nsInfo := newNamespacesInfo()
@@ -436,6 +547,18 @@ func (namespaceInfo *namespacesInfo) saveNamespace(storage *core.Storage, ns *Na
 	return err
 }
 
+func (namespaceInfo *namespacesInfo) saveNamespaces(storage *core.Storage, nss ...*Namespace) error {
+	kvs := make(map[string]string, len(nss))
+	for _, ns := range nss {
+		value, err := json.Marshal(ns)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		kvs[namespaceInfo.namespacePath(ns.GetID())] = string(value)
+	}
+	return storage.SaveBatch(kvs)
+}
+
 func (namespaceInfo *namespacesInfo) loadNamespaces(storage *core.Storage, rangeLimit int) error {
 	start := time.Now()
 
@@ -206,6 +206,37 @@ func (s *testTableNamespaceSuite) TestNamespaceOperation(c *C) {
 	c.Assert(tableClassifier.AddMetaToNamespace("test2"), IsNil)
 }
 
+func (s *testTableNamespaceSuite) TestMoveRange(c *C) {
+	classifier := s.newClassifier(c)
+	tableKey := func(id int64) []byte { return EncodeBytes(GenerateTableKey(id)) }
+
+	// Keys should be table boundaries.
+	c.Assert(classifier.MoveRange([]byte("a"), tableKey(4), "global", "ns1"), NotNil)
+	c.Assert(classifier.MoveRange(tableKey(4), tableKey(3), "global", "ns1"), NotNil)
+	c.Assert(classifier.MoveRange(tableKey(3), tableKey(4), "ns1", "ns1"), NotNil)
+	c.Assert(classifier.MoveRange(tableKey(3), tableKey(4), "ns3", "ns1"), NotNil)
+	// No table of ns1 in the range.
+	c.Assert(classifier.MoveRange(tableKey(3), tableKey(4), "ns1", "ns2"), NotNil)
+
+	// Moves table 3 and 4 from global, table 2 is skipped since it is in ns2.
+	c.Assert(classifier.MoveRange(tableKey(2), tableKey(5), "global", "ns1"), IsNil)
+	ns1 := classifier.nsInfo.getNamespaceByName("ns1")
+	c.Assert(ns1.TableIDs, DeepEquals, map[int64]bool{testTable1: true, 3: true, 4: true})
+
+	// Moves table 1 and 3 from ns1 to ns2.
+	c.Assert(classifier.MoveRange(tableKey(1), tableKey(4), "ns1", "ns2"), IsNil)
+	c.Assert(classifier.nsInfo.getNamespaceByName("ns1").TableIDs, DeepEquals, map[int64]bool{4: true})
+	c.Assert(classifier.nsInfo.getNamespaceByName("ns2").TableIDs, DeepEquals, map[int64]bool{1: true, 2: true, 3: true})
+	// The namespace got before moving is not changed.
+	c.Assert(ns1.TableIDs, HasLen, 3)
+
+	// Moves table 4 back to global and checks both are persisted.
+	c.Assert(classifier.MoveRange(tableKey(4), tableKey(5), "ns1", "global"), IsNil)
+	c.Assert(classifier.ReloadNamespaces(), IsNil)
+	c.Assert(classifier.IsTableIDExist(4), IsFalse)
+	c.Assert(classifier.nsInfo.getNamespaceByName("ns2").TableIDs, HasLen, 3)
+}
+
 func (s *testTableNamespaceSuite) TestTableNameSpaceReloadNamespaces(c *C) {
 	classifier := s.newClassifier(c)
 
@@ -0,0 +1,116 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client_test
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/pd/client/tier"
+	"github.com/pingcap/pd/server"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/tests"
+	"github.com/pkg/errors"
+)
+
+var _ = Suite(&tierClientTestSuite{})
+
+type tierClientTestSuite struct{}
+
+func (s *tierClientTestSuite) TestTierClient(c *C) {
+	c.Parallel()
+
+	cluster, err := tests.NewTestCluster(1)
+	c.Assert(err, IsNil)
+	defer cluster.Destroy()
+	c.Assert(cluster.RunInitialServers(), IsNil)
+	leaderServer := cluster.GetServer(cluster.WaitLeader())
+	c.Assert(leaderServer.BootstrapCluster(), IsNil)
+	svr := leaderServer.GetServer()
+	for _, id := range []uint64{2, 3} {
+		_, err = svr.PutStore(context.Background(), &pdpb.PutStoreRequest{
+			Header: &pdpb.RequestHeader{ClusterId: svr.ClusterID()},
+			Store: &metapb.Store{
+				Id:      id,
+				Address: fmt.Sprintf("mock://%d", id),
+				State:   metapb.StoreState_Up,
+				Labels:  []*metapb.StoreLabel{{Key: "zone", Value: "z1"}},
+				Version: (*server.MinSupportedVersion(server.Version2_0)).String(),
+			},
+		})
+		c.Assert(err, IsNil)
+	}
+
+	ctx := context.Background()
+	cli := tier.NewClient(leaderServer.GetAddr())
+
+	// Store types.
+	c.Assert(cli.SetStoreType(ctx, 3, tier.StoreTypeStorage), IsNil)
+	c.Assert(cli.SetStoreType(ctx, 2, tier.StoreType("unknown")), NotNil)
+	typ, err := cli.GetStoreType(ctx, 3)
+	c.Assert(err, IsNil)
+	c.Assert(typ, Equals, tier.StoreTypeStorage)
+	c.Assert(leaderServer.GetStore(3).GetLabelValue("zone"), Equals, "z1")
+	types, err := cli.GetStoreTypes(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(types, DeepEquals, map[uint64]tier.StoreType{
+		1: tier.StoreTypePerformance,
+		2: tier.StoreTypePerformance,
+		3: tier.StoreTypeStorage,
+	})
+
+	// Label rules.
+	rule := &tier.LabelRule{ID: "hot", Label: tier.LabelHotServing, StartKey: []byte{}, EndKey: []byte{}}
+	c.Assert(cli.SetLabelRule(ctx, rule), IsNil)
+	c.Assert(cli.SetLabelRule(ctx, &tier.LabelRule{ID: "bad", Label: tier.LabelCold, StartKey: []byte("b"), EndKey: []byte("a")}), NotNil)
+	rules, err := cli.GetLabelRules(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(rules, DeepEquals, []*tier.LabelRule{rule})
+	got, err := cli.GetLabelRule(ctx, "hot")
+	c.Assert(err, IsNil)
+	c.Assert(got, DeepEquals, rule)
+	_, err = cli.GetLabelRule(ctx, "bad")
+	c.Assert(errors.Cause(err), Equals, tier.ErrNotFound)
+
+	// The leader of the hot-serving region is on the storage store.
+	region := &metapb.Region{
+		Id:          2,
+		Peers:       []*metapb.Peer{{Id: 10, StoreId: 3}},
+		RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: 1},
+	}
+	c.Assert(cluster.HandleRegionHeartbeat(core.NewRegionInfo(region, region.Peers[0], core.SetApproximateSize(10))), IsNil)
+	stats, err := cli.GetStats(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(stats.Stores, HasLen, 2)
+	c.Assert(stats.Stores[0].StoreType, Equals, tier.StoreTypePerformance)
+	c.Assert(stats.Stores[0].StoreCount, Equals, 2)
+	c.Assert(stats.Stores[1].StoreType, Equals, tier.StoreTypeStorage)
+	c.Assert(stats.Stores[1].StoreCount, Equals, 1)
+	c.Assert(stats.Ranges, HasLen, 3)
+	c.Assert(*stats.Ranges[0], DeepEquals, tier.RangeStats{Label: tier.LabelHotServing, RegionCount: 1, RegionSize: 10, StorageLeaderCount: 1})
+	status, err := cli.GetMigrationStatus(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(status.PendingLeaders, Equals, 1)
+
+	c.Assert(cli.DeleteLabelRule(ctx, "hot"), IsNil)
+	rules, err = cli.GetLabelRules(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(rules, HasLen, 0)
+	status, err = cli.GetMigrationStatus(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(status.PendingLeaders, Equals, 0)
+}
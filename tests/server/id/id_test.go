@@ -22,6 +22,7 @@ import (
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/pingcap/pd/pkg/testutil"
 	"github.com/pingcap/pd/server"
+	"github.com/pingcap/pd/server/config"
 	"github.com/pingcap/pd/tests"
 )
 
@@ -107,3 +108,40 @@ func (s *testAllocIDSuite) TestCommand(c *C) {
 		last = resp.GetId()
 	}
 }
+
+func (s *testAllocIDSuite) TestPrefetch(c *C) {
+	step := uint64(10)
+	cluster, err := tests.NewTestCluster(1, func(conf *config.Config) { conf.PDServerCfg.IDAllocStep = step })
+	defer cluster.Destroy()
+	c.Assert(err, IsNil)
+
+	err = cluster.RunInitialServers()
+	c.Assert(err, IsNil)
+	cluster.WaitLeader()
+
+	alloc := cluster.GetServer(cluster.GetLeader()).GetAllocator()
+	var last uint64
+	allocN := func(n uint64) {
+		for i := uint64(0); i < n; i++ {
+			id, err := alloc.Alloc()
+			c.Assert(err, IsNil)
+			c.Assert(id, Greater, last)
+			last = id
+		}
+	}
+	allocN(step / 2)
+	// The next window is reserved when half of the window is used.
+	testutil.WaitUntil(c, func(c *C) bool {
+		return alloc.GetStatus().NextEnd != 0
+	})
+	status := alloc.GetStatus()
+	c.Assert(status.Step, Equals, step)
+	c.Assert(status.Remaining, Equals, step/2)
+	c.Assert(status.NextEnd, Equals, status.End+step)
+
+	// Allocations move to the reserved window.
+	allocN(step/2 + 1)
+	c.Assert(last, Equals, status.End+1)
+	c.Assert(alloc.GetStatus().End, Equals, status.NextEnd)
+	allocN(3 * step)
+}
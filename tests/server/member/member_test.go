@@ -20,6 +20,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"path"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -64,6 +66,12 @@ func (s *serverTestSuite) TestMemberDelete(c *C) {
 		}
 	}
 	c.Assert(members, HasLen, 2)
+	rootPath := path.Join(leader.GetConfig().RootPrefix, strconv.FormatUint(leader.GetClusterID(), 10))
+	for _, m := range members {
+		root, err := leader.GetServer().GetMember().GetMemberRoot(m.GetServerID())
+		c.Assert(err, IsNil)
+		c.Assert(root, Equals, rootPath)
+	}
 
 	var table = []struct {
 		path    string
@@ -104,6 +112,12 @@ func (s *serverTestSuite) TestMemberDelete(c *C) {
 			return true
 		})
 	}
+	// The deleted members are not registered in the PD cluster any longer.
+	for _, m := range members {
+		root, err := leader.GetServer().GetMember().GetMemberRoot(m.GetServerID())
+		c.Assert(err, IsNil)
+		c.Assert(root, Equals, "")
+	}
 }
 
 func (s *serverTestSuite) checkMemberList(c *C, clientURL string, configs []*config.Config) error {
@@ -214,6 +228,42 @@ func (s *serverTestSuite) TestLeaderResign(c *C) {
 	c.Assert(leader3, Equals, leader1)
 }
 
+func (s *serverTestSuite) TestLeaderTransferCheck(c *C) {
+	c.Parallel()
+
+	cluster, err := tests.NewTestCluster(3)
+	defer cluster.Destroy()
+	c.Assert(err, IsNil)
+
+	err = cluster.RunInitialServers()
+	c.Assert(err, IsNil)
+
+	leader := cluster.WaitLeader()
+	addr := cluster.GetServer(leader).GetConfig().ClientUrls + "/pd/api/v1/leader/transfer/"
+	transfer := func(name string) int {
+		res, err := http.Post(addr+name, "", nil)
+		c.Assert(err, IsNil)
+		res.Body.Close()
+		return res.StatusCode
+	}
+	c.Assert(transfer(leader), Equals, http.StatusBadRequest)
+	c.Assert(transfer("unknown"), Equals, http.StatusNotFound)
+
+	var followers []string
+	for name := range cluster.GetServers() {
+		if name != leader {
+			followers = append(followers, name)
+		}
+	}
+	// The stopped member is not healthy.
+	c.Assert(cluster.GetServer(followers[0]).Stop(), IsNil)
+	c.Assert(transfer(followers[0]), Equals, http.StatusBadRequest)
+	c.Assert(cluster.GetLeader(), Equals, leader)
+
+	c.Assert(transfer(followers[1]), Equals, http.StatusOK)
+	c.Assert(s.waitLeaderChange(c, cluster, leader), Equals, followers[1])
+}
+
 func (s *serverTestSuite) waitLeaderChange(c *C, cluster *tests.TestCluster, old string) string {
 	var leader string
 	testutil.WaitUntil(c, func(c *C) bool {
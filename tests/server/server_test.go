@@ -14,12 +14,15 @@
 package server_test
 
 import (
+	"context"
 	"testing"
 
 	. "github.com/pingcap/check"
+	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/pingcap/pd/pkg/tempurl"
 	"github.com/pingcap/pd/pkg/testutil"
 	"github.com/pingcap/pd/server"
+	"github.com/pingcap/pd/server/config"
 	"github.com/pingcap/pd/tests"
 
 	// Register schedulers.
@@ -125,3 +128,42 @@ func (s *serverTestSuite) TestLeader(c *C) {
 		return leader != leader1
 	})
 }
+
+func (s *serverTestSuite) TestClustersSharingEtcd(c *C) {
+	c.Parallel()
+
+	// pd1 and pd2 are members of one etcd but serve different PD clusters.
+	cluster, err := tests.NewTestCluster(2, func(conf *config.Config) {
+		conf.RootPrefix = "/pd-" + conf.Name
+	})
+	defer cluster.Destroy()
+	c.Assert(err, IsNil)
+
+	err = cluster.RunInitialServers()
+	c.Assert(err, IsNil)
+
+	pd1, pd2 := cluster.GetServer("pd1"), cluster.GetServer("pd2")
+	testutil.WaitUntil(c, func(c *C) bool {
+		return pd1.IsLeader() && pd2.IsLeader()
+	})
+	c.Assert(pd1.GetClusterID(), Not(Equals), pd2.GetClusterID())
+	c.Assert(pd1.GetLeader().GetName(), Equals, "pd1")
+	c.Assert(pd2.GetLeader().GetName(), Equals, "pd2")
+
+	resp, err := pd1.GetEtcdClient().Get(context.Background(), "/pd-pd2/cluster_id")
+	c.Assert(err, IsNil)
+	c.Assert(resp.Kvs, HasLen, 1)
+
+	// Members of the other cluster are not listed.
+	members, err := pd1.GetServer().GetMembers(context.Background(), &pdpb.GetMembersRequest{})
+	c.Assert(err, IsNil)
+	c.Assert(members.GetMembers(), HasLen, 1)
+	c.Assert(members.GetMembers()[0].GetName(), Equals, "pd1")
+
+	// The members not registered, such as the ones of older versions during
+	// a rolling upgrade, are listed as before.
+	c.Assert(pd2.GetServer().GetMember().UnregisterMember(pd2.GetServerID()), IsNil)
+	members, err = pd1.GetServer().GetMembers(context.Background(), &pdpb.GetMembersRequest{})
+	c.Assert(err, IsNil)
+	c.Assert(members.GetMembers(), HasLen, 2)
+}
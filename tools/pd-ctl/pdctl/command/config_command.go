@@ -16,6 +16,7 @@ package command
 import (
 	"bytes"
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"path"
 	"strconv"
@@ -30,6 +31,7 @@ var (
 	namespacePrefix      = "pd/api/v1/config/namespace"
 	labelPropertyPrefix  = "pd/api/v1/config/label-property"
 	clusterVersionPrefix = "pd/api/v1/config/cluster-version"
+	configBundlePrefix   = "pd/api/v1/config/bundle"
 )
 
 // NewConfigCommand return a config subcommand of rootCmd
@@ -41,9 +43,32 @@ func NewConfigCommand() *cobra.Command {
 	conf.AddCommand(NewShowConfigCommand())
 	conf.AddCommand(NewSetConfigCommand())
 	conf.AddCommand(NewDeleteConfigCommand())
+	conf.AddCommand(NewConfigBundleCommand())
 	return conf
 }
 
+// NewConfigBundleCommand returns a bundle subcommand of configCmd.
+func NewConfigBundleCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "bundle [export|import]",
+		Short: "export or import the schedule config, schedulers, label properties and namespace configs as a signed bundle",
+	}
+	export := &cobra.Command{
+		Use:   "export [--key=<key>]",
+		Short: "export the bundle signed with the key",
+		Run:   exportConfigBundleCommandFunc,
+	}
+	export.Flags().String("key", "", "the key to sign the bundle")
+	imp := &cobra.Command{
+		Use:   "import <file> [--key=<key>]",
+		Short: "import the bundle in the file signed with the key",
+		Run:   importConfigBundleCommandFunc,
+	}
+	imp.Flags().String("key", "", "the key the bundle is signed with")
+	c.AddCommand(export, imp)
+	return c
+}
+
 // NewShowConfigCommand return a show subcommand of configCmd
 func NewShowConfigCommand() *cobra.Command {
 	sc := &cobra.Command{
@@ -367,3 +392,48 @@ func setClusterVersionCommandFunc(cmd *cobra.Command, args []string) {
 	}
 	postJSON(cmd, clusterVersionPrefix, input)
 }
+
+func exportConfigBundleCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.Println(cmd.UsageString())
+		return
+	}
+	key, _ := cmd.Flags().GetString("key")
+	data, err := json.Marshal(map[string]interface{}{"key": key})
+	if err != nil {
+		cmd.Println(err)
+		return
+	}
+	r, err := doRequest(cmd, path.Join(configBundlePrefix, "export"), http.MethodPost,
+		WithBody("application/json", bytes.NewBuffer(data)))
+	if err != nil {
+		cmd.Printf("Failed to export config bundle: %s\n", err)
+		return
+	}
+	cmd.Println(r)
+}
+
+func importConfigBundleCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Println(cmd.UsageString())
+		return
+	}
+	bundle, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		cmd.Printf("Failed to read config bundle: %s\n", err)
+		return
+	}
+	key, _ := cmd.Flags().GetString("key")
+	data, err := json.Marshal(map[string]interface{}{"key": key, "bundle": json.RawMessage(bundle)})
+	if err != nil {
+		cmd.Printf("Failed to parse config bundle: %s\n", err)
+		return
+	}
+	r, err := doRequest(cmd, path.Join(configBundlePrefix, "import"), http.MethodPost,
+		WithBody("application/json", bytes.NewBuffer(data)))
+	if err != nil {
+		cmd.Printf("Failed to import config bundle: %s\n", err)
+		return
+	}
+	cmd.Println(r)
+}
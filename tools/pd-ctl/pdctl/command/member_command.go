@@ -18,6 +18,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -30,11 +31,12 @@ var (
 // NewMemberCommand return a member subcommand of rootCmd
 func NewMemberCommand() *cobra.Command {
 	m := &cobra.Command{
-		Use:   "member [leader|delete|leader_priority]",
+		Use:   "member [leader|add|delete|leader_priority]",
 		Short: "show the pd member status",
 		Run:   showMemberCommandFunc,
 	}
 	m.AddCommand(NewLeaderMemberCommand())
+	m.AddCommand(NewAddMemberCommand())
 	m.AddCommand(NewDeleteMemberCommand())
 
 	m.AddCommand(&cobra.Command{
@@ -45,6 +47,15 @@ func NewMemberCommand() *cobra.Command {
 	return m
 }
 
+// NewAddMemberCommand return a add subcommand of memberCmd
+func NewAddMemberCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "add <member_name> <peer_urls>",
+		Short: "add a pd member, which should be started with the returned initial-cluster",
+		Run:   addMemberCommandFunc,
+	}
+}
+
 // NewDeleteMemberCommand return a delete subcommand of memberCmd
 func NewDeleteMemberCommand() *cobra.Command {
 	d := &cobra.Command{
@@ -97,6 +108,24 @@ func showMemberCommandFunc(cmd *cobra.Command, args []string) {
 	cmd.Println(r)
 }
 
+func addMemberCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		cmd.Println("Usage: member add <member_name> <peer_urls>")
+		return
+	}
+	input := map[string]interface{}{
+		"name":      args[0],
+		"peer_urls": strings.Split(args[1], ","),
+	}
+	reqData, _ := json.Marshal(input)
+	r, err := doRequest(cmd, membersPrefix, http.MethodPost, WithBody("application/json", bytes.NewBuffer(reqData)))
+	if err != nil {
+		cmd.Printf("Failed to add member %s: %s\n", args[0], err)
+		return
+	}
+	cmd.Println(r)
+}
+
 func deleteMemberByNameCommandFunc(cmd *cobra.Command, args []string) {
 	if len(args) != 1 {
 		cmd.Println("Usage: member delete <member_name>")
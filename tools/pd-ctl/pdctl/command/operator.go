@@ -16,6 +16,7 @@ package command
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"github.com/pkg/errors"
@@ -108,6 +109,9 @@ func NewAddOperatorCommand() *cobra.Command {
 	c.AddCommand(NewTransferPeerCommand())
 	c.AddCommand(NewAddPeerCommand())
 	c.AddCommand(NewAddLearnerCommand())
+	c.AddCommand(NewPromoteLearnerCommand())
+	c.AddCommand(NewDemoteVoterCommand())
+	c.AddCommand(NewSwitchRoleCommand())
 	c.AddCommand(NewRemovePeerCommand())
 	c.AddCommand(NewMergeRegionCommand())
 	c.AddCommand(NewSplitRegionCommand())
@@ -261,6 +265,78 @@ func addLearnerCommandFunc(cmd *cobra.Command, args []string) {
 	postJSON(cmd, operatorsPrefix, input)
 }
 
+// NewPromoteLearnerCommand returns a command to promote a region learner to
+// voter in place.
+func NewPromoteLearnerCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "promote-learner <region_id> <store_id>",
+		Short: "promote the region learner on specified store to voter",
+		Run:   regionStoreOperatorCommandFunc,
+	}
+	return c
+}
+
+// NewDemoteVoterCommand returns a command to demote a region voter to learner
+// in place.
+func NewDemoteVoterCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "demote-voter <region_id> <store_id>",
+		Short: "demote the region voter on specified store to learner",
+		Run:   regionStoreOperatorCommandFunc,
+	}
+	return c
+}
+
+func regionStoreOperatorCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		cmd.Println(cmd.UsageString())
+		return
+	}
+
+	ids, err := parseUint64s(args)
+	if err != nil {
+		cmd.Println(err)
+		return
+	}
+
+	input := make(map[string]interface{})
+	input["name"] = cmd.Name()
+	input["region_id"] = ids[0]
+	input["store_id"] = ids[1]
+	postJSON(cmd, operatorsPrefix, input)
+}
+
+// NewSwitchRoleCommand returns a command to promote a region learner and
+// demote a region voter in place.
+func NewSwitchRoleCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "switch-role <region_id> <learner_store_id> <voter_store_id>",
+		Short: "promote the region learner and demote the region voter on specified stores",
+		Run:   switchRoleCommandFunc,
+	}
+	return c
+}
+
+func switchRoleCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 3 {
+		cmd.Println(cmd.UsageString())
+		return
+	}
+
+	ids, err := parseUint64s(args)
+	if err != nil {
+		cmd.Println(err)
+		return
+	}
+
+	input := make(map[string]interface{})
+	input["name"] = cmd.Name()
+	input["region_id"] = ids[0]
+	input["learner_store_id"] = ids[1]
+	input["voter_store_id"] = ids[2]
+	postJSON(cmd, operatorsPrefix, input)
+}
+
 // NewMergeRegionCommand returns a command to merge two regions.
 func NewMergeRegionCommand() *cobra.Command {
 	c := &cobra.Command{
@@ -389,10 +465,11 @@ func scatterRegionCommandFunc(cmd *cobra.Command, args []string) {
 // NewRemoveOperatorCommand returns a command to remove operators.
 func NewRemoveOperatorCommand() *cobra.Command {
 	c := &cobra.Command{
-		Use:   "remove <region_id>",
+		Use:   "remove <region_id> [--reason=<reason>]",
 		Short: "remove the region operator",
 		Run:   removeOperatorCommandFunc,
 	}
+	c.Flags().String("reason", "", "the reason to cancel the operator")
 	return c
 }
 
@@ -403,6 +480,9 @@ func removeOperatorCommandFunc(cmd *cobra.Command, args []string) {
 	}
 
 	path := operatorsPrefix + "/" + args[0]
+	if reason := cmd.Flags().Lookup("reason").Value.String(); reason != "" {
+		path += "?reason=" + url.QueryEscape(reason)
+	}
 	_, err := doRequest(cmd, path, http.MethodDelete)
 	if err != nil {
 		cmd.Println(err)
@@ -43,6 +43,8 @@ var (
 	regionsSiblingPrefix   = "pd/api/v1/regions/sibling"
 	regionIDPrefix         = "pd/api/v1/region/id"
 	regionKeyPrefix        = "pd/api/v1/region/key"
+	regionsPinnedPrefix    = "pd/api/v1/regions/pinned"
+	regionsSnapshotPrefix  = "pd/api/v1/regions/snapshot"
 )
 
 // NewRegionCommand returns a region subcommand of rootCmd
@@ -57,6 +59,10 @@ func NewRegionCommand() *cobra.Command {
 	r.AddCommand(NewRegionWithSiblingCommand())
 	r.AddCommand(NewRegionWithStoreCommand())
 	r.AddCommand(NewRegionsWithStartKeyCommand())
+	r.AddCommand(NewRegionPinCommand())
+	r.AddCommand(NewRegionUnpinCommand())
+	r.AddCommand(NewRegionPinnedCommand())
+	r.AddCommand(NewRegionSnapshotCommand())
 
 	topRead := &cobra.Command{
 		Use:   `topread <limit> [--jq="<query string>"]`,
@@ -486,6 +492,140 @@ func showRegionWithStoreCommandFunc(cmd *cobra.Command, args []string) {
 	cmd.Println(r)
 }
 
+// NewRegionPinCommand returns a pin subcommand of regionCmd.
+func NewRegionPinCommand() *cobra.Command {
+	r := &cobra.Command{
+		Use:   "pin <region_id> [--leader=<store_id>] [--stores=<store_id>,...] --ttl=<duration> [--reason=<reason>]",
+		Short: "pin the leader or the peers of a region to the stores they are in for a while",
+		Run:   pinRegionCommandFunc,
+	}
+	r.Flags().Uint64("leader", 0, "the store that the leader is pinned to")
+	r.Flags().UintSlice("stores", nil, "the stores that the peers are pinned to")
+	r.Flags().Duration("ttl", 0, "how long the region is pinned, such as 30m")
+	r.Flags().String("reason", "", "why the region is pinned")
+	return r
+}
+
+func pinRegionCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Println(cmd.UsageString())
+		return
+	}
+	if _, err := strconv.ParseUint(args[0], 10, 64); err != nil {
+		cmd.Println("region_id should be a number")
+		return
+	}
+	leader, _ := cmd.Flags().GetUint64("leader")
+	stores, _ := cmd.Flags().GetUintSlice("stores")
+	ttl, _ := cmd.Flags().GetDuration("ttl")
+	reason, _ := cmd.Flags().GetString("reason")
+	if (leader == 0 && len(stores) == 0) || ttl <= 0 {
+		cmd.Println(cmd.UsageString())
+		return
+	}
+	input := map[string]interface{}{
+		"leader_store_id": leader,
+		"store_ids":       stores,
+		"ttl":             ttl.String(),
+		"reason":          reason,
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		cmd.Println(err)
+		return
+	}
+	prefix := regionIDPrefix + "/" + args[0] + "/pin"
+	r, err := doRequest(cmd, prefix, http.MethodPost, WithBody("application/json", bytes.NewBuffer(data)))
+	if err != nil {
+		cmd.Printf("Failed to pin region: %s\n", err)
+		return
+	}
+	cmd.Println(r)
+}
+
+// NewRegionUnpinCommand returns an unpin subcommand of regionCmd.
+func NewRegionUnpinCommand() *cobra.Command {
+	r := &cobra.Command{
+		Use:   "unpin <region_id>",
+		Short: "unpin a region pinned by region pin",
+		Run:   unpinRegionCommandFunc,
+	}
+	return r
+}
+
+func unpinRegionCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Println(cmd.UsageString())
+		return
+	}
+	prefix := regionIDPrefix + "/" + args[0] + "/pin"
+	_, err := doRequest(cmd, prefix, http.MethodDelete)
+	if err != nil {
+		cmd.Printf("Failed to unpin region: %s\n", err)
+		return
+	}
+	cmd.Println("Success!")
+}
+
+// NewRegionPinnedCommand returns a pinned subcommand of regionCmd.
+func NewRegionPinnedCommand() *cobra.Command {
+	r := &cobra.Command{
+		Use:   "pinned",
+		Short: "show the pins of the regions in effect",
+		Run:   showRegionPinnedCommandFunc,
+	}
+	return r
+}
+
+func showRegionPinnedCommandFunc(cmd *cobra.Command, args []string) {
+	r, err := doRequest(cmd, regionsPinnedPrefix, http.MethodGet)
+	if err != nil {
+		cmd.Printf("Failed to get pinned regions: %s\n", err)
+		return
+	}
+	cmd.Println(r)
+}
+
+// NewRegionSnapshotCommand returns a snapshot subcommand of regionCmd.
+func NewRegionSnapshotCommand() *cobra.Command {
+	r := &cobra.Command{
+		Use:   "snapshot [--format=raw|encode|hex] [<start_key> [<end_key>]]",
+		Short: "show the boundaries and the replica placements of the regions at a point in time",
+		Run:   showRegionSnapshotCommandFunc,
+	}
+	r.Flags().String("format", "hex", "the key format")
+	return r
+}
+
+func showRegionSnapshotCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) > 2 {
+		cmd.Println(cmd.UsageString())
+		return
+	}
+	query := url.Values{}
+	for i, name := range []string{"start_key", "end_key"} {
+		if i >= len(args) {
+			break
+		}
+		key, err := parseKey(cmd.Flags(), args[i])
+		if err != nil {
+			cmd.Println("Error: ", err)
+			return
+		}
+		query.Set(name, hex.EncodeToString([]byte(key)))
+	}
+	prefix := regionsSnapshotPrefix
+	if len(query) > 0 {
+		prefix += "?" + query.Encode()
+	}
+	r, err := doRequest(cmd, prefix, http.MethodGet)
+	if err != nil {
+		cmd.Printf("Failed to get region snapshot: %s\n", err)
+		return
+	}
+	cmd.Println(r)
+}
+
 func printWithJQFilter(data, filter string) {
 	cmd := exec.Command("jq", "-c", filter)
 	stdin, err := cmd.StdinPipe()
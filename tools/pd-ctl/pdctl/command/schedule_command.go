@@ -0,0 +1,217 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os/user"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	schedulePausePrefix = "pd/api/v1/schedule/pause"
+	scheduleSpeedPrefix = "pd/api/v1/schedule/speed"
+)
+
+// NewScheduleCommand returns a schedule command.
+func NewScheduleCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "schedule",
+		Short: "pause or resume the scheduling of the whole cluster",
+	}
+	c.AddCommand(NewPauseScheduleCommand())
+	c.AddCommand(NewResumeScheduleCommand())
+	c.AddCommand(NewShowSchedulePauseCommand())
+	c.AddCommand(NewScheduleSpeedCommand())
+	return c
+}
+
+// NewScheduleSpeedCommand returns a command to show or override the scale of
+// the schedule limits.
+func NewScheduleSpeedCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "speed",
+		Short: "show or override the scale of all schedule limits",
+	}
+	c.AddCommand(&cobra.Command{
+		Use:   "show",
+		Short: "show the scale adjusted by the governor and the override",
+		Run:   showScheduleSpeedCommandFunc,
+	})
+	c.AddCommand(&cobra.Command{
+		Use:   "set <scale>",
+		Short: "override the scale, which should be in (0, 1]",
+		Run:   setScheduleSpeedCommandFunc,
+	})
+	c.AddCommand(&cobra.Command{
+		Use:   "reset",
+		Short: "remove the override so that the governor adjusts the scale",
+		Run:   resetScheduleSpeedCommandFunc,
+	})
+	return c
+}
+
+// NewPauseScheduleCommand returns a command to pause scheduling.
+func NewPauseScheduleCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "pause --reason=<reason> [--ttl=<duration>] [--owner=<owner>]",
+		Short: "pause the scheduling of the whole cluster until resumed or the ttl passes",
+		Run:   pauseScheduleCommandFunc,
+	}
+	c.Flags().String("reason", "", "why scheduling is paused, which is required")
+	c.Flags().Duration("ttl", 0, "how long scheduling is paused, such as 2h, 0 means until resumed")
+	c.Flags().String("owner", "", "who pauses scheduling, the current user by default")
+	return c
+}
+
+// NewResumeScheduleCommand returns a command to resume scheduling.
+func NewResumeScheduleCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "resume [--owner=<owner>]",
+		Short: "resume the scheduling paused by schedule pause",
+		Run:   resumeScheduleCommandFunc,
+	}
+	c.Flags().String("owner", "", "who resumes scheduling, the current user by default")
+	return c
+}
+
+// NewShowSchedulePauseCommand returns a command to show the pause.
+func NewShowSchedulePauseCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show",
+		Short: "show who paused scheduling, why and until when, with the recent events",
+		Run:   showSchedulePauseCommandFunc,
+	}
+}
+
+// scheduleOwner returns the owner in the flags, or the current user.
+func scheduleOwner(cmd *cobra.Command) string {
+	if owner, _ := cmd.Flags().GetString("owner"); owner != "" {
+		return owner
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
+
+func pauseScheduleCommandFunc(cmd *cobra.Command, args []string) {
+	reason, _ := cmd.Flags().GetString("reason")
+	if len(args) != 0 || reason == "" {
+		cmd.Println(cmd.UsageString())
+		return
+	}
+	ttl, err := cmd.Flags().GetDuration("ttl")
+	if err != nil {
+		cmd.Println(err)
+		return
+	}
+	input := map[string]interface{}{
+		"owner":  scheduleOwner(cmd),
+		"reason": reason,
+	}
+	if ttl > 0 {
+		input["ttl"] = ttl.String()
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		cmd.Println(err)
+		return
+	}
+	r, err := doRequest(cmd, schedulePausePrefix, http.MethodPost, WithBody("application/json", bytes.NewBuffer(data)))
+	if err != nil {
+		cmd.Printf("Failed to pause scheduling: %s\n", err)
+		return
+	}
+	cmd.Println(r)
+}
+
+func resumeScheduleCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.Println(cmd.UsageString())
+		return
+	}
+	path := schedulePausePrefix + "?owner=" + url.QueryEscape(scheduleOwner(cmd))
+	if _, err := doRequest(cmd, path, http.MethodDelete); err != nil {
+		cmd.Printf("Failed to resume scheduling: %s\n", err)
+		return
+	}
+	cmd.Println("Success!")
+}
+
+func showSchedulePauseCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.Println(cmd.UsageString())
+		return
+	}
+	r, err := doRequest(cmd, schedulePausePrefix, http.MethodGet)
+	if err != nil {
+		cmd.Printf("Failed to get the scheduling pause: %s\n", err)
+		return
+	}
+	cmd.Println(r)
+}
+
+func showScheduleSpeedCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.Println(cmd.UsageString())
+		return
+	}
+	r, err := doRequest(cmd, scheduleSpeedPrefix, http.MethodGet)
+	if err != nil {
+		cmd.Printf("Failed to get the schedule speed: %s\n", err)
+		return
+	}
+	cmd.Println(r)
+}
+
+func setScheduleSpeedCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Println(cmd.UsageString())
+		return
+	}
+	scale, err := strconv.ParseFloat(args[0], 64)
+	if err != nil {
+		cmd.Println("scale should be a number")
+		return
+	}
+	data, err := json.Marshal(map[string]interface{}{"scale": scale})
+	if err != nil {
+		cmd.Println(err)
+		return
+	}
+	r, err := doRequest(cmd, scheduleSpeedPrefix, http.MethodPost, WithBody("application/json", bytes.NewBuffer(data)))
+	if err != nil {
+		cmd.Printf("Failed to set the schedule speed: %s\n", err)
+		return
+	}
+	cmd.Println(r)
+}
+
+func resetScheduleSpeedCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.Println(cmd.UsageString())
+		return
+	}
+	if _, err := doRequest(cmd, scheduleSpeedPrefix, http.MethodDelete); err != nil {
+		cmd.Printf("Failed to reset the schedule speed: %s\n", err)
+		return
+	}
+	cmd.Println("Success!")
+}
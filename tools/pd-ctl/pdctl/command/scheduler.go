@@ -14,6 +14,9 @@
 package command
 
 import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -34,6 +37,8 @@ func NewSchedulerCommand() *cobra.Command {
 	c.AddCommand(NewShowSchedulerCommand())
 	c.AddCommand(NewAddSchedulerCommand())
 	c.AddCommand(NewRemoveSchedulerCommand())
+	c.AddCommand(NewDeniedStoresSchedulerCommand())
+	c.AddCommand(NewConfigSchedulerCommand())
 	return c
 }
 
@@ -183,13 +188,46 @@ func NewBalanceLeaderSchedulerCommand() *cobra.Command {
 // NewBalanceRegionSchedulerCommand returns a command to add a balance-region-scheduler.
 func NewBalanceRegionSchedulerCommand() *cobra.Command {
 	c := &cobra.Command{
-		Use:   "balance-region-scheduler",
+		Use:   "balance-region-scheduler [--format=raw|encode|hex] [<start_key> <end_key>]...",
 		Short: "add a scheduler to balance regions between stores",
-		Run:   addSchedulerCommandFunc,
+		Long:  "add a scheduler to balance regions between stores, which only moves the regions in the key ranges if any",
+		Run:   addSchedulerForBalanceRegionCommandFunc,
 	}
+	c.Flags().String("format", "hex", "the key format")
 	return c
 }
 
+func addSchedulerForBalanceRegionCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args)%2 != 0 {
+		cmd.Println(cmd.UsageString())
+		return
+	}
+	ranges := make([]map[string]string, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		startKey, err := parseKey(cmd.Flags(), args[i])
+		if err != nil {
+			cmd.Println("Error: ", err)
+			return
+		}
+		endKey, err := parseKey(cmd.Flags(), args[i+1])
+		if err != nil {
+			cmd.Println("Error: ", err)
+			return
+		}
+		ranges = append(ranges, map[string]string{
+			"start_key": hex.EncodeToString([]byte(startKey)),
+			"end_key":   hex.EncodeToString([]byte(endKey)),
+		})
+	}
+
+	input := make(map[string]interface{})
+	input["name"] = cmd.Name()
+	if len(ranges) > 0 {
+		input["ranges"] = ranges
+	}
+	postJSON(cmd, schedulersPrefix, input)
+}
+
 // NewBalanceHotRegionSchedulerCommand returns a command to add a balance-hot-region-scheduler.
 func NewBalanceHotRegionSchedulerCommand() *cobra.Command {
 	c := &cobra.Command{
@@ -317,3 +355,81 @@ func removeSchedulerCommandFunc(cmd *cobra.Command, args []string) {
 	}
 	cmd.Println("Success!")
 }
+
+// NewDeniedStoresSchedulerCommand returns a command to show or set the stores
+// denied for a scheduler.
+func NewDeniedStoresSchedulerCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "denied-stores <scheduler> [<store_id>...|none]",
+		Short: "show or set the stores denied for a scheduler",
+		Long:  "show the stores denied for a scheduler, or replace them with the given stores, where none allows all stores",
+		Run:   deniedStoresSchedulerCommandFunc,
+	}
+	return c
+}
+
+func deniedStoresSchedulerCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		cmd.Println(cmd.UsageString())
+		return
+	}
+
+	path := schedulersPrefix + "/" + args[0] + "/denied-stores"
+	if len(args) == 1 {
+		r, err := doRequest(cmd, path, http.MethodGet)
+		if err != nil {
+			cmd.Println(err)
+			return
+		}
+		cmd.Println(r)
+		return
+	}
+
+	storeIDs := make([]uint64, 0, len(args)-1)
+	if len(args) != 2 || args[1] != "none" {
+		for _, arg := range args[1:] {
+			id, err := strconv.ParseUint(arg, 10, 64)
+			if err != nil {
+				cmd.Println(cmd.UsageString())
+				return
+			}
+			storeIDs = append(storeIDs, id)
+		}
+	}
+	data, err := json.Marshal(storeIDs)
+	if err != nil {
+		cmd.Println(err)
+		return
+	}
+	_, err = doRequest(cmd, path, http.MethodPost,
+		WithBody("application/json", bytes.NewBuffer(data)))
+	if err != nil {
+		cmd.Println(err)
+		return
+	}
+	cmd.Println("Success!")
+}
+
+// NewConfigSchedulerCommand returns a command to show the config and the
+// progress of a scheduler.
+func NewConfigSchedulerCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "config <scheduler>",
+		Short: "show the config and the progress of a scheduler",
+		Run:   configSchedulerCommandFunc,
+	}
+	return c
+}
+
+func configSchedulerCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Println(cmd.UsageString())
+		return
+	}
+	r, err := doRequest(cmd, schedulersPrefix+"/"+args[0]+"/config", http.MethodGet)
+	if err != nil {
+		cmd.Println(err)
+		return
+	}
+	cmd.Println(r)
+}
@@ -17,6 +17,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"path"
 	"strconv"
 
@@ -31,7 +32,7 @@ var (
 // NewStoreCommand return a stores subcommand of rootCmd
 func NewStoreCommand() *cobra.Command {
 	s := &cobra.Command{
-		Use:   `store [delete|label|weight|limit] <store_id> [--jq="<query string>"]`,
+		Use:   `store [delete|label|weight|limit|decommission] <store_id> [--jq="<query string>"]`,
 		Short: "show the store status",
 		Run:   showStoreCommandFunc,
 	}
@@ -39,6 +40,7 @@ func NewStoreCommand() *cobra.Command {
 	s.AddCommand(NewLabelStoreCommand())
 	s.AddCommand(NewSetStoreWeightCommand())
 	s.AddCommand(NewSetStoreLimitCommand())
+	s.AddCommand(NewShowStoreDecommissionCommand())
 	s.Flags().String("jq", "", "jq query")
 	return s
 }
@@ -56,14 +58,24 @@ func NewDeleteStoreByAddrCommand() *cobra.Command {
 // NewDeleteStoreCommand return a  delete subcommand of storeCmd
 func NewDeleteStoreCommand() *cobra.Command {
 	d := &cobra.Command{
-		Use:   "delete <store_id>",
+		Use:   "delete <store_id> [--window=<start>-<end>]",
 		Short: "delete the store",
 		Run:   deleteStoreCommandFunc,
 	}
+	d.Flags().String("window", "", "only evacuate the store in the daily window, such as 00:00-06:00")
 	d.AddCommand(NewDeleteStoreByAddrCommand())
 	return d
 }
 
+// NewShowStoreDecommissionCommand returns a decommission subcommand of storeCmd.
+func NewShowStoreDecommissionCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "decommission <store_id>",
+		Short: "show the window and the progress to evacuate the removed store",
+		Run:   showStoreDecommissionCommandFunc,
+	}
+}
+
 // NewLabelStoreCommand returns a label subcommand of storeCmd.
 func NewLabelStoreCommand() *cobra.Command {
 	l := &cobra.Command{
@@ -76,11 +88,13 @@ func NewLabelStoreCommand() *cobra.Command {
 
 // NewSetStoreWeightCommand returns a weight subcommand of storeCmd.
 func NewSetStoreWeightCommand() *cobra.Command {
-	return &cobra.Command{
-		Use:   "weight <store_id> <leader_weight> <region_weight>",
+	c := &cobra.Command{
+		Use:   "weight <store_id> <leader_weight> <region_weight> [--rebalance=<limit>]",
 		Short: "set a store's leader and region balance weight",
 		Run:   setStoreWeightCommandFunc,
 	}
+	c.Flags().Int("rebalance", 0, "the max number of operators to add at once to rebalance the store")
+	return c
 }
 
 // NewSetStoreLimitCommand returns a limit subcommand of storeCmd.
@@ -108,8 +122,8 @@ func NewStoresCommand() *cobra.Command {
 // NewRemoveTombStoneCommand returns a tombstone subcommand of storesCmd.
 func NewRemoveTombStoneCommand() *cobra.Command {
 	return &cobra.Command{
-		Use:   "remove-tombstone",
-		Short: "remove tombstone record if only safe",
+		Use:   "remove-tombstone [address]",
+		Short: "remove tombstone record if only safe, or the ones on the address to reuse it",
 		Run:   removeTombStoneCommandFunc,
 	}
 }
@@ -185,6 +199,9 @@ func deleteStoreCommandFunc(cmd *cobra.Command, args []string) {
 		return
 	}
 	prefix := fmt.Sprintf(storePrefix, args[0])
+	if window, err := cmd.Flags().GetString("window"); err == nil && window != "" {
+		prefix += "?window=" + url.QueryEscape(window)
+	}
 	_, err := doRequest(cmd, prefix, http.MethodDelete)
 	if err != nil {
 		cmd.Printf("Failed to delete store %s: %s\n", args[0], err)
@@ -193,6 +210,24 @@ func deleteStoreCommandFunc(cmd *cobra.Command, args []string) {
 	cmd.Println("Success!")
 }
 
+func showStoreDecommissionCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Usage()
+		return
+	}
+	if _, err := strconv.Atoi(args[0]); err != nil {
+		cmd.Println("store_id should be a number")
+		return
+	}
+	prefix := path.Join(fmt.Sprintf(storePrefix, args[0]), "decommission")
+	r, err := doRequest(cmd, prefix, http.MethodGet)
+	if err != nil {
+		cmd.Printf("Failed to get the decommission of store %s: %s\n", args[0], err)
+		return
+	}
+	cmd.Println(r)
+}
+
 func deleteStoreCommandByAddrFunc(cmd *cobra.Command, args []string) {
 	if len(args) != 1 {
 		cmd.Usage()
@@ -272,11 +307,20 @@ func setStoreWeightCommandFunc(cmd *cobra.Command, args []string) {
 		cmd.Println("region_weight should be a number that >= 0")
 		return
 	}
-	prefix := fmt.Sprintf(path.Join(storePrefix, "weight"), args[0])
-	postJSON(cmd, prefix, map[string]interface{}{
+	input := map[string]interface{}{
 		"leader": leader,
 		"region": region,
-	})
+	}
+	rebalance, err := cmd.Flags().GetInt("rebalance")
+	if err != nil || rebalance < 0 {
+		cmd.Println("rebalance should be a number that >= 0")
+		return
+	}
+	if rebalance > 0 {
+		input["rebalance"] = rebalance
+	}
+	prefix := fmt.Sprintf(path.Join(storePrefix, "weight"), args[0])
+	postJSON(cmd, prefix, input)
 }
 
 func setStoreLimitCommandFunc(cmd *cobra.Command, args []string) {
@@ -320,7 +364,14 @@ func showAllLimitCommandFunc(cmd *cobra.Command, args []string) {
 }
 
 func removeTombStoneCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) > 1 {
+		cmd.Println(cmd.UsageString())
+		return
+	}
 	prefix := path.Join(storesPrefix, "remove-tombstone")
+	if len(args) == 1 {
+		prefix += "?address=" + url.QueryEscape(args[0])
+	}
 	_, err := doRequest(cmd, prefix, http.MethodDelete)
 	if err != nil {
 		cmd.Printf("Failed to remove tombstone store %s \n", err)
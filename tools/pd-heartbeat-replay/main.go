@@ -0,0 +1,95 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/pd/pkg/heartbeatlog"
+	"github.com/pingcap/pd/server"
+	"github.com/pingcap/pd/server/config"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	filePath   = flag.String("file", "", "the heartbeat log captured by PD with heartbeat-capture-file")
+	speed      = flag.Float64("speed", 0, "the multiple of the pace of the captured heartbeats, 0 means as fast as possible")
+	configPath = flag.String("config", "", "the PD config file, whose schedule and replication configurations take effect")
+	logLevel   = flag.String("L", "warn", "log level: debug, info, warn, error, fatal")
+)
+
+func checkErr(err error) {
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+}
+
+func main() {
+	flag.Parse()
+	if *filePath == "" {
+		checkErr(fmt.Errorf("the heartbeat log is not specified"))
+	}
+	var level zapcore.Level
+	checkErr(level.UnmarshalText([]byte(*logLevel)))
+	log.SetLevel(level)
+
+	cfg := config.NewConfig()
+	if *configPath != "" {
+		checkErr(cfg.Parse([]string{"-config", *configPath}))
+	} else {
+		checkErr(cfg.Adjust(nil))
+	}
+
+	f, err := os.Open(*filePath)
+	checkErr(err)
+	defer f.Close()
+	reader, err := heartbeatlog.NewReader(f)
+	checkErr(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sc := make(chan os.Signal, 1)
+	signal.Notify(sc, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	go func() {
+		<-sc
+		cancel()
+	}()
+
+	stats, err := server.ReplayHeartbeats(ctx, reader, cfg, *speed)
+	if stats != nil {
+		printStats(stats)
+	}
+	checkErr(err)
+}
+
+func printStats(stats *server.ReplayStats) {
+	fmt.Printf("elapsed: %s\n", stats.Elapsed)
+	fmt.Printf("store heartbeats: %d, total: %s, avg: %s\n", stats.StoreHeartbeats, stats.StoreHeartbeatTime, avg(stats.StoreHeartbeatTime, stats.StoreHeartbeats))
+	fmt.Printf("region heartbeats: %d, total: %s, avg: %s\n", stats.RegionHeartbeats, stats.RegionHeartbeatTime, avg(stats.RegionHeartbeatTime, stats.RegionHeartbeats))
+	fmt.Printf("errors: %d\n", stats.Errors)
+}
+
+func avg(total time.Duration, count int) time.Duration {
+	if count == 0 {
+		return 0
+	}
+	return total / time.Duration(count)
+}
@@ -17,20 +17,18 @@ import (
 )
 
 var (
-	endpoints = flag.String("endpoints", "http://127.0.0.1:2379", "endpoints urls")
-	allocID   = flag.Uint64("alloc-id", 0, "please make sure alloced ID is safe")
-	clusterID = flag.Uint64("cluster-id", 0, "please make cluster ID match with tikv")
-	caPath    = flag.String("cacert", "", "path of file that contains list of trusted SSL CAs.")
-	certPath  = flag.String("cert", "", "path of file that contains X509 certificate in PEM format..")
-	keyPath   = flag.String("key", "", "path of file that contains X509 key in PEM format.")
+	endpoints  = flag.String("endpoints", "http://127.0.0.1:2379", "endpoints urls")
+	allocID    = flag.Uint64("alloc-id", 0, "please make sure alloced ID is safe")
+	clusterID  = flag.Uint64("cluster-id", 0, "please make cluster ID match with tikv")
+	rootPrefix = flag.String("root-prefix", "/pd", "the etcd key prefix of the PD cluster")
+	caPath     = flag.String("cacert", "", "path of file that contains list of trusted SSL CAs.")
+	certPath   = flag.String("cert", "", "path of file that contains X509 certificate in PEM format..")
+	keyPath    = flag.String("key", "", "path of file that contains X509 key in PEM format.")
 )
 
 const (
 	requestTimeout = 10 * time.Second
 	etcdTimeout    = 3 * time.Second
-
-	pdRootPath      = "/pd"
-	pdClusterIDPath = "/pd/cluster_id"
 )
 
 func exitErr(err error) {
@@ -49,7 +47,7 @@ func main() {
 		return
 	}
 
-	rootPath := path.Join(pdRootPath, strconv.FormatUint(*clusterID, 10))
+	rootPath := path.Join(*rootPrefix, strconv.FormatUint(*clusterID, 10))
 	clusterRootPath := path.Join(rootPath, "raft")
 	raftBootstrapTimeKey := path.Join(clusterRootPath, "status", "raft_bootstrap_time")
 
@@ -79,7 +77,7 @@ func main() {
 
 	var ops []clientv3.Op
 	// recover cluster_id
-	ops = append(ops, clientv3.OpPut(pdClusterIDPath, string(typeutil.Uint64ToBytes(*clusterID))))
+	ops = append(ops, clientv3.OpPut(path.Join(*rootPrefix, "cluster_id"), string(typeutil.Uint64ToBytes(*clusterID))))
 	// recover alloc_id
 	allocIDPath := path.Join(rootPath, "alloc_id")
 	ops = append(ops, clientv3.OpPut(allocIDPath, string(typeutil.Uint64ToBytes(*allocID))))
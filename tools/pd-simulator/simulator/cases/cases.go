@@ -101,6 +101,8 @@ var CaseMap = map[string]func() *Case{
 	"hot-write":                newHotWrite,
 	"makeup-down-replicas":     newMakeupDownReplicas,
 	"import-data":              newImportData,
+	"tier-migration":           newTierMigration,
+	"tier-cold-aging":          newTierColdAging,
 }
 
 // NewCase creates a new case.
@@ -67,3 +67,14 @@ type DeleteNodesDescriptor struct {
 func (w *DeleteNodesDescriptor) Type() string {
 	return "delete-nodes"
 }
+
+// LabelRegionsDescriptor labels the key ranges of some regions, such as
+// marking the ranges of aged data cold.
+type LabelRegionsDescriptor struct {
+	Step func(tick int64) map[uint64]string
+}
+
+// Type implements the EventDescriptor interface.
+func (w *LabelRegionsDescriptor) Type() string {
+	return "label-regions"
+}
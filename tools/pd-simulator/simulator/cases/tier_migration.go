@@ -0,0 +1,190 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cases
+
+import (
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/schedule/labeler"
+	"github.com/pingcap/pd/tools/pd-simulator/simulator/info"
+	"github.com/pingcap/pd/tools/pd-simulator/simulator/simutil"
+	"go.uber.org/zap"
+)
+
+const (
+	tierStoreCount  = 3
+	tierRegionCount = 120
+	// tierReadFlow is the read flow of a hot-serving region per tick.
+	tierReadFlow = 4 * MB
+	// tierAgingInterval is the ticks between the steps of aging, each of
+	// which turns tierAgingRegions hot-serving regions cold.
+	tierAgingInterval = 50
+	tierAgingRegions  = 10
+	// tierSteadyTicks is the ticks that the cluster should stay converged,
+	// during which the regions should change at most tierMaxChurn times.
+	tierSteadyTicks = 100
+	tierMaxChurn    = 5
+)
+
+// newTierMigration creates a cluster with performance and storage stores, in
+// which the leaders of all regions are on storage stores, such as after
+// importing data. The leaders of the hot-serving half of the ranges should be
+// moved to performance stores, and the cluster should stay still afterwards.
+func newTierMigration() *Case {
+	return newTierCase(0)
+}
+
+// newTierColdAging is the same as newTierMigration, but the hot-serving ranges
+// age into cold ones step by step, and their reads stop. The leaders of the
+// aged ranges are allowed to stay anywhere, so the aging should not cause the
+// leaders to be moved back and forth.
+func newTierColdAging() *Case {
+	return newTierCase(4)
+}
+
+func newTierCase(agingSteps int) *Case {
+	var simCase Case
+
+	// Initialize the cluster. The first stores are performance stores and the
+	// others are storage stores.
+	storageStores := make(map[uint64]struct{})
+	for i := 0; i < 2*tierStoreCount; i++ {
+		s := &Store{
+			ID:        IDAllocator.nextID(),
+			Status:    metapb.StoreState_Up,
+			Capacity:  1 * TB,
+			Available: 900 * GB,
+			Version:   "2.1.0",
+		}
+		if i >= tierStoreCount {
+			s.Labels = []*metapb.StoreLabel{{Key: core.StoreTypeLabelKey, Value: string(core.StoreTypeStorage)}}
+			storageStores[s.ID] = struct{}{}
+		}
+		simCase.Stores = append(simCase.Stores, s)
+	}
+
+	// Each region has a peer on a performance store and two peers on storage
+	// stores, and its leader is on a storage store.
+	for i := 0; i < tierRegionCount; i++ {
+		peers := []*metapb.Peer{
+			{Id: IDAllocator.nextID(), StoreId: uint64(tierStoreCount + i%tierStoreCount + 1)},
+			{Id: IDAllocator.nextID(), StoreId: uint64(tierStoreCount + (i+1)%tierStoreCount + 1)},
+			{Id: IDAllocator.nextID(), StoreId: uint64(i%tierStoreCount + 1)},
+		}
+		simCase.Regions = append(simCase.Regions, Region{
+			ID:     IDAllocator.nextID(),
+			Peers:  peers,
+			Leader: peers[0],
+			Size:   96 * MB,
+			Keys:   960000,
+		})
+	}
+
+	// The first half of the regions are hot-serving, and the others are cold.
+	// The oldest hot-serving regions age into cold ones at every step.
+	hot := make(map[uint64]struct{})
+	var agingOrder []uint64
+	for i, r := range simCase.Regions {
+		if i < tierRegionCount/2 {
+			hot[r.ID] = struct{}{}
+			agingOrder = append(agingOrder, r.ID)
+		}
+	}
+	lastAgingTick := int64(agingSteps * tierAgingInterval)
+	label := &LabelRegionsDescriptor{}
+	label.Step = func(tick int64) map[uint64]string {
+		if tick == 1 {
+			labels := make(map[uint64]string, len(simCase.Regions))
+			for _, r := range simCase.Regions {
+				if _, ok := hot[r.ID]; ok {
+					labels[r.ID] = labeler.HotServing
+				} else {
+					labels[r.ID] = labeler.Cold
+				}
+			}
+			return labels
+		}
+		if tick > lastAgingTick || tick%tierAgingInterval != 0 {
+			return nil
+		}
+		labels := make(map[uint64]string, tierAgingRegions)
+		for len(labels) < tierAgingRegions && len(agingOrder) > 0 {
+			id := agingOrder[0]
+			agingOrder = agingOrder[1:]
+			delete(hot, id)
+			labels[id] = labeler.Cold
+		}
+		simutil.Logger.Info("hot-serving regions age", zap.Int64("tick", tick), zap.Int("aged", len(labels)), zap.Int("hot", len(hot)))
+		return labels
+	}
+	read := &ReadFlowOnRegionDescriptor{}
+	read.Step = func(tick int64) map[uint64]int64 {
+		flow := make(map[uint64]int64, len(hot))
+		for id := range hot {
+			flow[id] = tierReadFlow
+		}
+		return flow
+	}
+	simCase.Events = []EventDescriptor{label, read}
+
+	// Checker description
+	var (
+		tick        int64
+		lastLeaders map[uint64]uint64
+		lastPeers   map[uint64]int
+		steadyTicks int
+		churn       int
+	)
+	simCase.Checker = func(regions *core.RegionsInfo, stats []info.StoreStats) bool {
+		tick++
+		leaders := make(map[uint64]uint64, len(simCase.Regions))
+		peers := make(map[uint64]int, len(simCase.Regions))
+		var pending, changed int
+		for _, r := range simCase.Regions {
+			region := regions.GetRegion(r.ID)
+			if region == nil {
+				continue
+			}
+			leaders[r.ID] = region.GetLeader().GetStoreId()
+			peers[r.ID] = len(region.GetPeers()) + int(region.GetRegionEpoch().GetConfVer())
+			if _, ok := hot[r.ID]; ok {
+				if _, ok := storageStores[leaders[r.ID]]; ok {
+					pending++
+				}
+			}
+			if lastLeaders != nil && (lastLeaders[r.ID] != leaders[r.ID] || lastPeers[r.ID] != peers[r.ID]) {
+				changed++
+			}
+		}
+		lastLeaders, lastPeers = leaders, peers
+
+		// The cluster converges once the aging is done and no hot-serving
+		// leader is left on storage stores. Then it should stay still for
+		// the steady ticks.
+		if tick <= lastAgingTick || pending > 0 {
+			steadyTicks, churn = 0, 0
+			simutil.Logger.Info("tier migration is pending", zap.Int64("tick", tick), zap.Int("pending-leaders", pending), zap.Int("changed", changed))
+			return false
+		}
+		steadyTicks++
+		churn += changed
+		if churn > tierMaxChurn {
+			simutil.Logger.Info("too much churn after tier migration converges", zap.Int64("tick", tick), zap.Int("churn", churn))
+			steadyTicks, churn = 0, 0
+		}
+		return steadyTicks >= tierSteadyTicks
+	}
+
+	return &simCase
+}
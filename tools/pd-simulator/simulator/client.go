@@ -14,7 +14,11 @@
 package simulator
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
@@ -24,6 +28,7 @@ import (
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/schedule/labeler"
 	"github.com/pingcap/pd/tools/pd-simulator/simulator/simutil"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
@@ -316,3 +321,23 @@ func (c *client) requestHeader() *pdpb.RequestHeader {
 		ClusterId: c.clusterID,
 	}
 }
+
+// setLabelRule creates or updates a range label rule by the HTTP API of PD,
+// since the rules cannot be set by gRPC.
+func setLabelRule(pdAddr string, rule *labeler.LabelRule) error {
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	httpClient := &http.Client{Timeout: pdTimeout}
+	resp, err := httpClient.Post(strings.TrimSuffix(pdAddr, "/")+"/pd/api/v1/config/label-rule", "application/json", bytes.NewReader(data))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("failed to set label rule %s: %s", rule.ID, body)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/pd/server"
+	"github.com/pingcap/pd/server/api"
+	"github.com/pingcap/pd/server/statistics"
+	"github.com/pingcap/pd/tools/pd-simulator/simulator/simutil"
+
+	// Register schedulers.
+	_ "github.com/pingcap/pd/server/schedulers"
+)
+
+func TestSimulator(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testDriverSuite{})
+
+type testDriverSuite struct{}
+
+const (
+	// maxTicks bounds the ticks of a case, since a failed case never finishes.
+	maxTicks = 1000
+	// tickInterval is shorter than the default one to keep the cases fast,
+	// while PD still has the time to schedule between the ticks.
+	tickInterval = 20 * time.Millisecond
+)
+
+func (s *testDriverSuite) SetUpSuite(c *C) {
+	simutil.InitLogger("fatal")
+	statistics.Denoising = false
+}
+
+func (s *testDriverSuite) TestTierCases(c *C) {
+	if testing.Short() {
+		c.Skip("the tier cases run a PD for tens of seconds")
+	}
+	for _, name := range []string{"tier-migration", "tier-cold-aging"} {
+		ticks, ok := s.runCase(c, name)
+		c.Assert(ok, IsTrue, Commentf("case %s does not finish in %d ticks", name, ticks))
+	}
+}
+
+// runCase runs the case with a PD started inside, and returns the ticks taken
+// and if the case is finished.
+func (s *testDriverSuite) runCase(c *C, name string) (int64, bool) {
+	simConfig := NewSimConfig("fatal")
+	c.Assert(simConfig.Adjust(), IsNil)
+	c.Assert(simConfig.ServerConfig.SetupLogger(), IsNil)
+	svr, err := server.CreateServer(simConfig.ServerConfig, api.NewHandler)
+	c.Assert(err, IsNil)
+	defer func() {
+		svr.Close()
+		os.RemoveAll(simConfig.ServerConfig.DataDir)
+	}()
+	c.Assert(svr.Run(context.Background()), IsNil)
+	for svr.IsClosed() || !svr.GetMember().IsLeader() {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	driver, err := NewDriver(svr.GetAddr(), name, simConfig)
+	c.Assert(err, IsNil)
+	c.Assert(driver.Prepare(), IsNil)
+	defer driver.Stop()
+	for driver.TickCount() < maxTicks {
+		time.Sleep(tickInterval)
+		driver.Tick()
+		if driver.Check() {
+			return driver.TickCount(), true
+		}
+	}
+	return driver.TickCount(), false
+}
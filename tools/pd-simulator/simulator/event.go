@@ -14,9 +14,13 @@
 package simulator
 
 import (
+	"encoding/hex"
+	"fmt"
+
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/pingcap/pd/server/core"
+	"github.com/pingcap/pd/server/schedule/labeler"
 	"github.com/pingcap/pd/tools/pd-simulator/simulator/cases"
 	"github.com/pingcap/pd/tools/pd-simulator/simulator/simutil"
 	"go.uber.org/zap"
@@ -57,6 +61,8 @@ func parserEvent(e cases.EventDescriptor) Event {
 		return &AddNodes{descriptor: t}
 	case *cases.DeleteNodesDescriptor:
 		return &DeleteNodes{descriptor: t}
+	case *cases.LabelRegionsDescriptor:
+		return &LabelRegions{descriptor: t}
 	}
 	return nil
 }
@@ -198,3 +204,31 @@ func (e *DeleteNodes) Run(raft *RaftEngine, tickCount int64) bool {
 	}
 	return false
 }
+
+// LabelRegions labels the key ranges of some regions by the range label rules
+// of PD. The rule of a region is replaced when the region is labeled again.
+type LabelRegions struct {
+	descriptor *cases.LabelRegionsDescriptor
+}
+
+// Run implements the event interface.
+func (e *LabelRegions) Run(raft *RaftEngine, tickCount int64) bool {
+	res := e.descriptor.Step(tickCount)
+	for id, label := range res {
+		region := raft.GetRegion(id)
+		if region == nil {
+			simutil.Logger.Error("region is not found", zap.Uint64("region-id", id))
+			continue
+		}
+		rule := &labeler.LabelRule{
+			ID:       fmt.Sprintf("simulator-region-%d", id),
+			Label:    label,
+			StartKey: hex.EncodeToString(region.GetStartKey()),
+			EndKey:   hex.EncodeToString(region.GetEndKey()),
+		}
+		if err := setLabelRule(raft.conn.pdAddr, rule); err != nil {
+			simutil.Logger.Error("label region failed", zap.Uint64("region-id", id), zap.Error(err))
+		}
+	}
+	return false
+}
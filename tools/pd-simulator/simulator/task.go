@@ -384,6 +384,14 @@ func (a *addLearner) Step(r *RaftEngine) {
 			r.SetRegion(newRegion)
 			r.recordRegionChange(newRegion)
 			r.schedulerStats.taskStats.incAddLeaner(region.GetID())
+		} else {
+			// The voter is demoted in place.
+			newRegion := region.Clone(
+				core.WithDemoteVoter(a.peer.GetId()),
+				core.WithIncConfVer(),
+			)
+			r.SetRegion(newRegion)
+			r.recordRegionChange(newRegion)
 		}
 		a.finished = true
 		if analysis.GetTransferCounter().IsValid {
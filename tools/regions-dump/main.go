@@ -20,22 +20,21 @@ import (
 )
 
 var (
-	clusterID = flag.Uint64("cluster-id", 0, "please make cluster ID match with tikv")
-	endpoints = flag.String("endpoints", "http://127.0.0.1:2379", "endpoints urls")
-	startID   = flag.Uint64("start-id", 0, "the id of the start region")
-	endID     = flag.Uint64("end-id", 0, "the id of the last region")
-	filePath  = flag.String("file", "regions.dump", "the dump file path and name")
-	caPath    = flag.String("cacert", "", "path of file that contains list of trusted SSL CAs.")
-	certPath  = flag.String("cert", "", "path of file that contains X509 certificate in PEM format..")
-	keyPath   = flag.String("key", "", "path of file that contains X509 key in PEM format.")
+	clusterID  = flag.Uint64("cluster-id", 0, "please make cluster ID match with tikv")
+	rootPrefix = flag.String("root-prefix", "/pd", "the etcd key prefix of the PD cluster")
+	endpoints  = flag.String("endpoints", "http://127.0.0.1:2379", "endpoints urls")
+	startID    = flag.Uint64("start-id", 0, "the id of the start region")
+	endID      = flag.Uint64("end-id", 0, "the id of the last region")
+	filePath   = flag.String("file", "regions.dump", "the dump file path and name")
+	caPath     = flag.String("cacert", "", "path of file that contains list of trusted SSL CAs.")
+	certPath   = flag.String("cert", "", "path of file that contains X509 certificate in PEM format..")
+	keyPath    = flag.String("key", "", "path of file that contains X509 key in PEM format.")
 )
 
 const (
 	requestTimeout = 10 * time.Second
 	etcdTimeout    = 1200 * time.Second
 
-	pdRootPath      = "/pd"
-	pdClusterIDPath = "/pd/cluster_id"
 	maxKVRangeLimit = 10000
 	minKVRangeLimit = 100
 )
@@ -56,7 +55,7 @@ func main() {
 	if *endID != 0 && *endID < *startID {
 		checkErr(errors.New("The end id should great or equal than start id"))
 	}
-	rootPath = path.Join(pdRootPath, strconv.FormatUint(*clusterID, 10))
+	rootPath = path.Join(*rootPrefix, strconv.FormatUint(*clusterID, 10))
 	f, err := os.Create(*filePath)
 	checkErr(err)
 	defer f.Close()